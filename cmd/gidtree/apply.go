@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"slices"
+
+	"github.com/thuanlegit/git-identitree/internal/cli"
+	"github.com/thuanlegit/git-identitree/internal/manifest"
+	"github.com/thuanlegit/git-identitree/internal/mapping"
+	"github.com/thuanlegit/git-identitree/internal/utils"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	applyUpdate bool
+	applyQuiet  bool
+)
+
+var applyCmd = &cobra.Command{
+	Use:   "apply <manifest.yaml>",
+	Short: "Create the profiles and mappings declared in a manifest",
+	Long: `Bring this machine in line with a manifest listing profiles and the
+directories mapped to each:
+
+  profiles:
+    - name: work
+      email: me@work.example
+      ssh_key_path: ~/.ssh/id_work
+      mappings:
+        - ~/code/work
+
+Missing profiles are created and unmapped directories are mapped; directories
+that do not exist yet are mapped anyway. A profile whose settings differ is only
+replaced with --update. Nothing is ever removed, and a directory mapped to
+another profile is left alone. On a machine without ~/.gitconfig, apply asks
+before its first mapping creates it, as map does; --create-gitconfig skips the
+question.
+
+With --dry-run the plan is printed and nothing changes. Otherwise each step is
+reported as it is carried out, followed by a summary; a failed step does not
+stop the others. With --quiet only the failures and the summary are printed.
+Applying the same manifest again changes nothing.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		m, err := manifest.Load(args[0])
+		if err != nil {
+			return err
+		}
+		return applyManifest(cmd.OutOrStdout(), m, manifest.PlanOptions{Update: applyUpdate}, dryRun, applyQuiet, confirmer())
+	},
+}
+
+// applyManifest carries out the plan for m, reporting each step to w, or
+// only prints the plan when dry is set or it changes nothing. quiet reports
+// only failures and the summary. confirm is asked before a mapping creates
+// ~/.gitconfig.
+func applyManifest(w io.Writer, m *manifest.Manifest, opts manifest.PlanOptions, dry, quiet bool, confirm cli.Confirmer) error {
+	actions, err := manifest.Plan(m, opts)
+	if err != nil {
+		return err
+	}
+	// The first mapping creates ~/.gitconfig when the machine has none
+	gitConfigPath, createsGitConfig := mapping.MissingGitConfig()
+	createsGitConfig = createsGitConfig && slices.ContainsFunc(actions, func(a manifest.Action) bool {
+		return a.Kind == manifest.CreateMapping
+	})
+
+	changes := manifest.CountChanges(actions)
+	if changes == 0 || dry {
+		for _, a := range actions {
+			mark := "+"
+			switch a.Kind {
+			case manifest.UpdateProfile:
+				mark = "~"
+			case manifest.SkipProfile, manifest.SkipMapping:
+				mark = "⚠"
+			}
+			_, _ = fmt.Fprintf(w, "%s %s\n", mark, a)
+		}
+		if createsGitConfig {
+			_, _ = fmt.Fprintf(w, "+ would create %s\n", utils.AbbreviateHome(gitConfigPath))
+		}
+		if changes == 0 {
+			_, _ = fmt.Fprintln(w, "✓ Nothing to change")
+		} else {
+			_, _ = fmt.Fprintf(w, "%d change(s) planned; run without --dry-run to apply them\n", changes)
+		}
+		return nil
+	}
+
+	if createsGitConfig {
+		if err := checkGitConfig(confirm); err != nil {
+			return fmt.Errorf("failed to apply manifest: %w", err)
+		}
+	}
+
+	report := newReporter(w, "applied", quiet)
+	_, err = manifest.ApplyEach(actions, func(a manifest.Action, err error) {
+		switch {
+		case err != nil:
+			report.Fail(a.String(), err)
+		case a.Kind == manifest.SkipProfile:
+			report.Skip(fmt.Sprintf("profile '%s'", a.Profile), a.Reason)
+		case a.Kind == manifest.SkipMapping:
+			report.Skip(a.Directory, a.Reason)
+		default:
+			report.Done(a.String())
+		}
+	})
+	if err != nil && report.Err() == nil {
+		// Nothing was attempted
+		return err
+	}
+	report.Summary()
+	if err := report.Err(); err != nil {
+		return fmt.Errorf("failed to apply manifest: %w", err)
+	}
+	return nil
+}
+
+func init() {
+	applyCmd.Flags().BoolVar(&applyUpdate, "update", false, "Replace existing profiles whose settings differ from the manifest")
+	applyCmd.Flags().BoolVarP(&applyQuiet, "quiet", "q", false, "Print only failures and the summary")
+}
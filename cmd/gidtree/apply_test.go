@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/thuanlegit/git-identitree/internal/cli"
+	"github.com/thuanlegit/git-identitree/internal/manifest"
+	"github.com/thuanlegit/git-identitree/internal/mapping"
+	"github.com/thuanlegit/git-identitree/pkg/gidtree/gidtreetest"
+)
+
+func TestApplyManifest(t *testing.T) {
+	env := gidtreetest.NewEnv(t).Build()
+	path := filepath.Join(t.TempDir(), "manifest.yaml")
+	content := "profiles:\n  - name: work\n    email: me@work.example\n    mappings: [~/code/work]\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write manifest: %v", err)
+	}
+	m, err := manifest.Load(path)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	// --dry-run prints the plan and changes nothing
+	var out bytes.Buffer
+	if err := applyManifest(&out, m, manifest.PlanOptions{}, true, false, nil); err != nil {
+		t.Fatalf("applyManifest(dry) error = %v", err)
+	}
+	if !strings.Contains(out.String(), "+ create profile 'work'") || !strings.Contains(out.String(), "2 change(s) planned") {
+		t.Errorf("dry run output = %q", out.String())
+	}
+	if !strings.Contains(out.String(), "+ would create ~/.gitconfig") {
+		t.Errorf("dry run output = %q, want the creation of ~/.gitconfig", out.String())
+	}
+	if _, err := os.Stat(env.ProfilesPath()); err == nil {
+		t.Error("dry run should not write profiles.yaml")
+	}
+
+	// Without a terminal to confirm creating ~/.gitconfig in, nothing is applied
+	noTTY := func(string, string) (bool, error) { return false, cli.ErrNoConfirmation }
+	if err := applyManifest(&out, m, manifest.PlanOptions{}, false, false, noTTY); !errors.Is(err, mapping.ErrGitConfigMissing) {
+		t.Fatalf("applyManifest() without ~/.gitconfig error = %v, want ErrGitConfigMissing", err)
+	}
+	if _, err := os.Stat(env.ProfilesPath()); err == nil {
+		t.Error("a refused apply should not write profiles.yaml")
+	}
+
+	out.Reset()
+	yes := func(string, string) (bool, error) { return true, nil }
+	if err := applyManifest(&out, m, manifest.PlanOptions{}, false, false, yes); err != nil {
+		t.Fatalf("applyManifest() error = %v", err)
+	}
+	if want := "✓ create profile 'work'\n✓ map " + env.Path("code/work") + "/ to profile 'work'\n2 applied\n"; out.String() != want {
+		t.Errorf("output = %q", out.String())
+	}
+	if got, _ := mapping.GetMappingForDirectory(env.Path("code/work")); got == nil || got.Profile != "work" {
+		t.Errorf("code/work mapping = %+v, want work", got)
+	}
+
+	out.Reset()
+	if err := applyManifest(&out, m, manifest.PlanOptions{}, false, false, nil); err != nil {
+		t.Fatalf("applyManifest() again error = %v", err)
+	}
+	if out.String() != "✓ Nothing to change\n" {
+		t.Errorf("second apply output = %q, want nothing to change", out.String())
+	}
+}
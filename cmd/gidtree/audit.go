@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/thuanlegit/git-identitree/internal/audit"
+	"github.com/thuanlegit/git-identitree/internal/mapping"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	auditSince    string
+	auditMaxCount int
+	auditJSON     bool
+)
+
+var auditCmd = &cobra.Command{
+	Use:   "audit [path...]",
+	Short: "Find commits made with the wrong identity",
+	Long:  "Walk the given directories (default: all mapped directories), run git log in every repository found and report commits whose author email does not match the profile mapped to that repository.",
+	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return nil, cobra.ShellCompDirectiveFilterDirs
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		roots := args
+		if len(roots) == 0 {
+			mappings, err := mapping.ParseMappings()
+			if err != nil {
+				return fmt.Errorf("failed to parse mappings: %w", err)
+			}
+			for _, m := range mappings {
+				if _, err := os.Stat(m.Directory); err == nil {
+					roots = append(roots, m.Directory)
+				}
+			}
+		}
+
+		auditor := audit.NewAuditor(audit.LogOptions{Since: auditSince, MaxCount: auditMaxCount})
+		reports, err := auditor.Run(roots)
+		if err != nil {
+			return fmt.Errorf("failed to audit repositories: %w", err)
+		}
+
+		asJSON, err := jsonOutput(cmd, auditJSON)
+		if err != nil {
+			return err
+		}
+		if asJSON {
+			return writeAuditJSON(os.Stdout, reports)
+		}
+		writeAuditReport(os.Stdout, reports)
+		return nil
+	},
+}
+
+// writeAuditJSON prints audit reports as JSON.
+func writeAuditJSON(w io.Writer, reports []audit.RepoReport) error {
+	if reports == nil {
+		reports = []audit.RepoReport{}
+	}
+	return writeJSON(w, reports)
+}
+
+// writeAuditReport prints mismatched commits grouped by repository.
+func writeAuditReport(w io.Writer, reports []audit.RepoReport) {
+	mismatched := 0
+	for _, r := range reports {
+		if len(r.Mismatches) == 0 {
+			continue
+		}
+		mismatched += len(r.Mismatches)
+		expected := r.ExpectedEmail
+		if len(r.EmailAliases) > 0 {
+			expected += " or " + strings.Join(r.EmailAliases, ", ")
+		}
+		_, _ = fmt.Fprintf(w, "%s (profile '%s', expected %s)\n", r.Repo, r.Profile, expected)
+		for _, c := range r.Mismatches {
+			hash := c.Hash
+			if len(hash) > 7 {
+				hash = hash[:7]
+			}
+			_, _ = fmt.Fprintf(w, "  %s  %s <%s>\n", hash, c.Name, c.Email)
+		}
+		_, _ = fmt.Fprintln(w)
+	}
+
+	if mismatched == 0 {
+		_, _ = fmt.Fprintf(w, "✓ No mismatched commits found in %d repositories\n", len(reports))
+		return
+	}
+	_, _ = fmt.Fprintf(w, "✗ Found %d mismatched commits\n", mismatched)
+}
+
+func init() {
+	auditCmd.Flags().StringVar(&auditSince, "since", "", "Only inspect commits more recent than this date (passed to git log --since)")
+	auditCmd.Flags().IntVar(&auditMaxCount, "max-count", 0, "Maximum number of commits to inspect per repository")
+	auditCmd.Flags().BoolVar(&auditJSON, "json", false, "Output the report as JSON")
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/thuanlegit/git-identitree/internal/audit"
+)
+
+func TestWriteAuditReport(t *testing.T) {
+	reports := []audit.RepoReport{
+		{Repo: "/src/clean", Profile: "work", ExpectedEmail: "work@example.com", Checked: 2, Mismatches: []audit.Commit{}},
+		{
+			Repo:          "/src/dirty",
+			Profile:       "work",
+			ExpectedEmail: "work@example.com",
+			Checked:       3,
+			Mismatches: []audit.Commit{
+				{Hash: "0123456789abcdef", Email: "me@personal.com", Name: "Jane"},
+			},
+		},
+	}
+
+	var buf bytes.Buffer
+	writeAuditReport(&buf, reports)
+	output := buf.String()
+
+	if strings.Contains(output, "/src/clean") {
+		t.Error("writeAuditReport() should omit repositories without mismatches")
+	}
+	if !strings.Contains(output, "/src/dirty (profile 'work', expected work@example.com)") {
+		t.Errorf("writeAuditReport() missing repository header:\n%s", output)
+	}
+	if !strings.Contains(output, "0123456  Jane <me@personal.com>") {
+		t.Errorf("writeAuditReport() missing mismatched commit:\n%s", output)
+	}
+	if !strings.Contains(output, "Found 1 mismatched commits") {
+		t.Errorf("writeAuditReport() missing summary:\n%s", output)
+	}
+
+	buf.Reset()
+	reports[1].EmailAliases = []string{"work@old.example.com"}
+	writeAuditReport(&buf, reports)
+	if !strings.Contains(buf.String(), "expected work@example.com or work@old.example.com)") {
+		t.Errorf("writeAuditReport() should list the aliases:\n%s", buf.String())
+	}
+
+	buf.Reset()
+	writeAuditReport(&buf, reports[:1])
+	if !strings.Contains(buf.String(), "No mismatched commits found in 1 repositories") {
+		t.Errorf("writeAuditReport() clean summary = %q", buf.String())
+	}
+}
+
+func TestWriteAuditJSON(t *testing.T) {
+	var buf bytes.Buffer
+	if err := writeAuditJSON(&buf, nil); err != nil {
+		t.Fatalf("writeAuditJSON() error = %v", err)
+	}
+	if strings.TrimSpace(buf.String()) != "[]" {
+		t.Errorf("writeAuditJSON(nil) = %q, want []", buf.String())
+	}
+
+	buf.Reset()
+	reports := []audit.RepoReport{{Repo: "/src/a", Profile: "work", ExpectedEmail: "w@example.com", Mismatches: []audit.Commit{{Hash: "a", Email: "x@example.com", Name: "X"}}}}
+	if err := writeAuditJSON(&buf, reports); err != nil {
+		t.Fatalf("writeAuditJSON() error = %v", err)
+	}
+	var decoded []map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("writeAuditJSON() produced invalid JSON: %v", err)
+	}
+	if decoded[0]["repo"] != "/src/a" || decoded[0]["expected_email"] != "w@example.com" {
+		t.Errorf("writeAuditJSON() = %v", decoded)
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/thuanlegit/git-identitree/internal/profile"
+	"github.com/thuanlegit/git-identitree/internal/ui"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	cloneEmail  string
+	cloneSSHKey string
+)
+
+// cloneProfileForm asks for the new profile's values; tests replace it.
+var cloneProfileForm = ui.CloneProfileForm
+
+var profileCloneCmd = &cobra.Command{
+	Use:   "clone <source> [new-name]",
+	Short: "Create a profile from a copy of another",
+	Long: `Create a new profile by copying an existing one.
+
+With --email or --ssh-key the copy is saved under new-name with those values
+replaced. Without them, the update form opens pre-populated with the source's
+values so the new name and anything else can be edited.`,
+	Args: cobra.RangeArgs(1, 2),
+	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) != 0 {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		manager, err := profile.NewDefaultManager()
+		if err != nil {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		profiles := manager.ListProfiles()
+		var names []string
+		for _, p := range profiles {
+			names = append(names, p.Name)
+		}
+		return names, cobra.ShellCompDirectiveNoFileComp
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		manager, err := profile.NewDefaultManager()
+		if err != nil {
+			return fmt.Errorf("failed to initialize profile manager: %w", err)
+		}
+
+		source, err := manager.GetProfile(args[0])
+		if err != nil {
+			return fmt.Errorf("profile not found: %w", err)
+		}
+
+		newName := ""
+		if len(args) == 2 {
+			newName = args[1]
+		}
+
+		var clone profile.Profile
+		if cmd.Flags().Changed("email") || cmd.Flags().Changed("ssh-key") {
+			if newName == "" {
+				return errors.New("a new profile name is required with --email or --ssh-key")
+			}
+			clone = source.CloneAs(newName)
+			if cmd.Flags().Changed("email") {
+				clone.Email = cloneEmail
+			}
+			if cmd.Flags().Changed("ssh-key") {
+				clone.SSHKeyPath = cloneSSHKey
+			}
+		} else {
+			prof, err := cloneProfileForm(source, newName)
+			if err != nil {
+				return fmt.Errorf("failed to clone profile: %w", err)
+			}
+			clone = *prof
+		}
+
+		if err := manager.AddProfile(clone); err != nil {
+			return fmt.Errorf("failed to save profile: %w", err)
+		}
+
+		fmt.Printf("✓ Profile '%s' cloned to '%s'\n", source.Name, clone.Name)
+		return nil
+	},
+}
+
+func init() {
+	profileCloneCmd.Flags().StringVar(&cloneEmail, "email", "", "Email for the new profile")
+	profileCloneCmd.Flags().StringVar(&cloneSSHKey, "ssh-key", "", "SSH private key path for the new profile (empty for none)")
+	_ = profileCloneCmd.RegisterFlagCompletionFunc("email", completeProfileEmails)
+	_ = profileCloneCmd.RegisterFlagCompletionFunc("ssh-key", completeProfileSSHKeys)
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/thuanlegit/git-identitree/internal/profile"
+	"github.com/thuanlegit/git-identitree/pkg/gidtree/gidtreetest"
+)
+
+// setCloneFlags sets clone flags for one test, as if given on the command line.
+func setCloneFlags(t *testing.T, values map[string]string) {
+	t.Helper()
+	for name, value := range values {
+		if err := profileCloneCmd.Flags().Set(name, value); err != nil {
+			t.Fatalf("Set(%s) error = %v", name, err)
+		}
+	}
+	t.Cleanup(func() {
+		cloneEmail, cloneSSHKey = "", ""
+		for name := range values {
+			profileCloneCmd.Flags().Lookup(name).Changed = false
+		}
+	})
+}
+
+func buildCloneSource(t *testing.T) *profile.Manager {
+	t.Helper()
+	gidtreetest.NewEnv(t).
+		WithProfile(profile.Profile{Name: "work", Email: "jane@company.com", AuthorName: "Jane Doe", GPGKeyID: "ABC123"}).
+		Build()
+	manager, err := profile.NewDefaultManager()
+	if err != nil {
+		t.Fatalf("NewDefaultManager() error = %v", err)
+	}
+	return manager
+}
+
+func TestProfileCloneCommand_Flags(t *testing.T) {
+	buildCloneSource(t)
+	setCloneFlags(t, map[string]string{"email": "jane@client.com"})
+
+	if err := profileCloneCmd.RunE(profileCloneCmd, []string{"work", "client"}); err != nil {
+		t.Fatalf("clone error = %v", err)
+	}
+
+	manager, err := profile.NewDefaultManager()
+	if err != nil {
+		t.Fatalf("NewDefaultManager() error = %v", err)
+	}
+	clone, err := manager.GetProfile("client")
+	if err != nil {
+		t.Fatalf("GetProfile(client) error = %v", err)
+	}
+	got := *clone
+	if got.CreatedAt.IsZero() {
+		t.Error("clone has no creation time")
+	}
+	got.CreatedAt = time.Time{}
+	want := profile.Profile{Name: "client", Email: "jane@client.com", AuthorName: "Jane Doe", GPGKeyID: "ABC123"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("clone = %+v, want %+v", got, want)
+	}
+
+	// The clone is stored separately from its source
+	clone.AuthorName = "Changed"
+	source, _ := manager.GetProfile("work")
+	if source.AuthorName != "Jane Doe" || source.Email != "jane@company.com" {
+		t.Errorf("source changed with the clone: %+v", *source)
+	}
+}
+
+func TestProfileCloneCommand_FlagsResetUsage(t *testing.T) {
+	manager := buildCloneSource(t)
+	source, err := manager.GetProfile("work")
+	if err != nil {
+		t.Fatalf("GetProfile(work) error = %v", err)
+	}
+	created := time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC)
+	source.CreatedAt = created
+	source.LastUsed = time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)
+	if err := manager.UpdateProfile("work", *source); err != nil {
+		t.Fatalf("UpdateProfile() error = %v", err)
+	}
+	setCloneFlags(t, map[string]string{"email": "jane@client.com"})
+
+	if err := profileCloneCmd.RunE(profileCloneCmd, []string{"work", "client"}); err != nil {
+		t.Fatalf("clone error = %v", err)
+	}
+
+	manager, err = profile.NewDefaultManager()
+	if err != nil {
+		t.Fatalf("NewDefaultManager() error = %v", err)
+	}
+	clone, err := manager.GetProfile("client")
+	if err != nil {
+		t.Fatalf("GetProfile(client) error = %v", err)
+	}
+	if clone.CreatedAt.IsZero() || clone.CreatedAt.Equal(created) {
+		t.Errorf("clone CreatedAt = %v, want the time of cloning", clone.CreatedAt)
+	}
+	if !clone.LastUsed.IsZero() {
+		t.Errorf("clone LastUsed = %v, want it unset", clone.LastUsed)
+	}
+}
+
+func TestProfileCloneCommand_Validation(t *testing.T) {
+	buildCloneSource(t)
+
+	setCloneFlags(t, map[string]string{"email": "jane@client.com"})
+	if err := profileCloneCmd.RunE(profileCloneCmd, []string{"work"}); err == nil {
+		t.Error("clone with --email but no new name should fail")
+	}
+	if err := profileCloneCmd.RunE(profileCloneCmd, []string{"work", "work"}); !errors.Is(err, profile.ErrProfileExists) {
+		t.Errorf("clone onto an existing name error = %v, want ErrProfileExists", err)
+	}
+	if err := profileCloneCmd.RunE(profileCloneCmd, []string{"missing", "client"}); !errors.Is(err, profile.ErrProfileNotFound) {
+		t.Errorf("clone of a missing profile error = %v, want ErrProfileNotFound", err)
+	}
+
+	setCloneFlags(t, map[string]string{"ssh-key": "/does/not/exist"})
+	if err := profileCloneCmd.RunE(profileCloneCmd, []string{"work", "client"}); !errors.Is(err, profile.ErrSSHKeyMissing) {
+		t.Errorf("clone with a missing SSH key error = %v, want ErrSSHKeyMissing", err)
+	}
+}
+
+func TestProfileCloneCommand_Form(t *testing.T) {
+	buildCloneSource(t)
+
+	var gotName string
+	orig := cloneProfileForm
+	defer func() { cloneProfileForm = orig }()
+	cloneProfileForm = func(source *profile.Profile, name string) (*profile.Profile, error) {
+		gotName = name
+		prof := source.Clone()
+		prof.Name = "personal"
+		prof.Email = "jane@example.com"
+		return &prof, nil
+	}
+
+	if err := profileCloneCmd.RunE(profileCloneCmd, []string{"work"}); err != nil {
+		t.Fatalf("clone error = %v", err)
+	}
+	if gotName != "" {
+		t.Errorf("form name = %q, want it empty when no new name is given", gotName)
+	}
+
+	manager, err := profile.NewDefaultManager()
+	if err != nil {
+		t.Fatalf("NewDefaultManager() error = %v", err)
+	}
+	if clone, err := manager.GetProfile("personal"); err != nil || clone.AuthorName != "Jane Doe" {
+		t.Errorf("GetProfile(personal) = %+v, %v; want the source's author name", clone, err)
+	}
+	if source, _ := manager.GetProfile("work"); source.Email != "jane@company.com" {
+		t.Errorf("source changed by the form: %+v", *source)
+	}
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/thuanlegit/git-identitree/internal/config"
+	"github.com/thuanlegit/git-identitree/internal/mapping"
+	"github.com/thuanlegit/git-identitree/internal/profile"
+	"github.com/thuanlegit/git-identitree/internal/utils"
+
+	"github.com/spf13/cobra"
+)
+
+// completeMappedDirectories completes the directories that are currently
+// mapped, with the home directory shown as ~ as in status. Directories are
+// offered from the file system when the mappings cannot be read.
+func completeMappedDirectories(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) != 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	mappings, err := mapping.ParseMappings()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveFilterDirs
+	}
+
+	home, _ := utils.Root()
+	var dirs []string
+	for _, m := range mappings {
+		if !m.HasDirectory() {
+			continue
+		}
+		dir := m.Directory
+		// A path typed out in full completes in full
+		if home != "" && strings.HasPrefix(dir, home) && !strings.HasPrefix(toComplete, home) {
+			dir = "~" + strings.TrimPrefix(dir, home)
+		}
+		if strings.HasPrefix(dir, toComplete) {
+			dirs = append(dirs, dir)
+		}
+	}
+	return dirs, cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeProfileNames completes the first argument with profile names. Like
+// profile lookups it ignores surrounding whitespace and case, although most
+// shells still filter the names by what was typed, case-sensitively.
+func completeProfileNames(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) != 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	manager, err := profile.NewDefaultManager()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	prefix := strings.ToLower(strings.TrimSpace(toComplete))
+	var names []string
+	for _, p := range manager.ListProfiles() {
+		if strings.HasPrefix(strings.ToLower(p.Name), prefix) {
+			names = append(names, p.Name)
+		}
+	}
+	return names, cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeLoadableProfiles completes the profiles whose SSH key file exists,
+// the only ones ssh load and unload can act on.
+func completeLoadableProfiles(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) != 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	manager, err := profile.NewDefaultManager()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	var names []string
+	for _, p := range manager.ListProfiles() {
+		if p.SSHKeyPath == "" || !strings.HasPrefix(p.Name, toComplete) {
+			continue
+		}
+		keyPath, err := utils.ExpandPath(p.SSHKeyPath)
+		if err != nil {
+			continue
+		}
+		if _, err := os.Stat(keyPath); err == nil {
+			names = append(names, p.Name)
+		}
+	}
+	return names, cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeProfileValues completes the values of one profile field that other
+// profiles already use, deduplicated in profile order.
+func completeProfileValues(field func(p profile.Profile) string, toComplete string) []string {
+	manager, err := profile.NewDefaultManager()
+	if err != nil {
+		return nil
+	}
+	var values []string
+	seen := make(map[string]bool)
+	for _, p := range manager.ListProfiles() {
+		v := field(p)
+		if v == "" || seen[v] || !strings.HasPrefix(v, toComplete) {
+			continue
+		}
+		seen[v] = true
+		values = append(values, v)
+	}
+	return values
+}
+
+// completeProfileEmails completes the emails of existing profiles and, for
+// the part before the @ typed so far, the domains they use, since profiles
+// often share a domain.
+func completeProfileEmails(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	emails := completeProfileValues(func(p profile.Profile) string { return p.Email }, "")
+	local, typedDomain, _ := strings.Cut(toComplete, "@")
+
+	var values []string
+	seen := make(map[string]bool)
+	add := func(v string) {
+		if !seen[v] && strings.HasPrefix(v, toComplete) {
+			seen[v] = true
+			values = append(values, v)
+		}
+	}
+	for _, email := range emails {
+		add(email)
+	}
+	if local != "" {
+		for _, email := range emails {
+			if _, domain, ok := strings.Cut(email, "@"); ok && strings.HasPrefix(domain, typedDomain) {
+				add(local + "@" + domain)
+			}
+		}
+	}
+	return values, cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeProfileSSHKeys completes the SSH key paths of existing profiles,
+// falling back to file completion for a key no profile uses yet.
+func completeProfileSSHKeys(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	keys := completeProfileValues(func(p profile.Profile) string { return p.SSHKeyPath }, toComplete)
+	if len(keys) == 0 {
+		return nil, cobra.ShellCompDirectiveDefault
+	}
+	return keys, cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeProfileGPGKeys completes the GPG key IDs of existing profiles.
+func completeProfileGPGKeys(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	return completeProfileValues(func(p profile.Profile) string { return p.GPGKeyID }, toComplete), cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeProjectDirectories completes the subdirectories of projects_dir,
+// written the way projects_dir is configured. Anything else, including a
+// path below one of those subdirectories, falls back to directory completion.
+func completeProjectDirectories(toComplete string) ([]string, cobra.ShellCompDirective) {
+	cfg, err := config.Load()
+	if err != nil || cfg.ProjectsDir == "" {
+		return nil, cobra.ShellCompDirectiveFilterDirs
+	}
+	root, err := utils.ExpandPath(cfg.ProjectsDir)
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveFilterDirs
+	}
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveFilterDirs
+	}
+
+	prefix := utils.EnsureTrailingSlash(cfg.ProjectsDir)
+	var dirs []string
+	for _, e := range entries {
+		if !e.IsDir() || strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+		dir := prefix + e.Name() + string(filepath.Separator)
+		if strings.HasPrefix(dir, toComplete) && dir != toComplete {
+			dirs = append(dirs, dir)
+		}
+	}
+	if len(dirs) == 0 {
+		return nil, cobra.ShellCompDirectiveFilterDirs
+	}
+	return dirs, cobra.ShellCompDirectiveNoFileComp | cobra.ShellCompDirectiveNoSpace
+}
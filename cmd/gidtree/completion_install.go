@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/thuanlegit/git-identitree/internal/utils"
+
+	"github.com/spf13/cobra"
+)
+
+// installShells are the shells completion install knows where to install for.
+var installShells = []string{"bash", "zsh", "fish"}
+
+var completionInstallCmd = &cobra.Command{
+	Use:   "install [shell]",
+	Short: "Install the completion script where the shell looks for it",
+	Long: `Write the completion script for bash, zsh or fish (default: the shell in
+$SHELL) to the directory that shell loads completions from, creating it if
+needed:
+
+  bash  ~/.local/share/bash-completion/completions/gidtree
+  zsh   ~/.zsh/completions/_gidtree
+  fish  ~/.config/fish/completions/gidtree.fish
+
+$XDG_DATA_HOME and $XDG_CONFIG_HOME replace ~/.local/share and ~/.config when
+set. bash needs the bash-completion package. zsh only looks in directories on
+its fpath; when ~/.zshrc does not add ~/.zsh/completions yet, the line to add
+is printed. With --dry-run the path is printed and nothing is written.`,
+	Args:      cobra.MatchAll(cobra.MaximumNArgs(1), cobra.OnlyValidArgs),
+	ValidArgs: installShells,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		shell := ""
+		if len(args) == 1 {
+			shell = args[0]
+		}
+		return installCompletion(cmd.OutOrStdout(), cmd.Root(), shell, dryRun)
+	},
+}
+
+// completionTarget is where the completion script of a shell is installed.
+type completionTarget struct {
+	Path string
+	// RCFile and RCLine are a line the shell's startup file still needs
+	// before the shell finds Path; both are empty when nothing is needed.
+	RCFile string
+	RCLine string
+}
+
+// installCompletion writes root's completion script for shell, or the shell
+// in $SHELL when empty, to its conventional location, or only describes
+// that when dry is set.
+func installCompletion(w io.Writer, root *cobra.Command, shell string, dry bool) error {
+	if shell == "" {
+		detected, err := detectShell(os.Getenv("SHELL"))
+		if err != nil {
+			return err
+		}
+		shell = detected
+	}
+	home, err := utils.Root()
+	if err != nil {
+		return err
+	}
+	target, err := findCompletionTarget(shell, home, os.Getenv)
+	if err != nil {
+		return err
+	}
+
+	if dry {
+		_, _ = fmt.Fprintf(w, "Would write the output of 'gidtree completion %s' to %s\n", shell, utils.AbbreviateHome(target.Path))
+	} else {
+		var script bytes.Buffer
+		if err := generateCompletion(root, shell, &script); err != nil {
+			return fmt.Errorf("failed to generate %s completion: %w", shell, err)
+		}
+		if err := os.MkdirAll(filepath.Dir(target.Path), 0755); err != nil {
+			return fmt.Errorf("failed to create completion directory: %w", err)
+		}
+		if err := os.WriteFile(target.Path, script.Bytes(), 0644); err != nil {
+			return fmt.Errorf("failed to write completion script: %w", err)
+		}
+		_, _ = fmt.Fprintf(w, "✓ Installed %s completion to %s\n", shell, utils.AbbreviateHome(target.Path))
+	}
+
+	if target.RCLine != "" {
+		_, _ = fmt.Fprintf(w, "\nAdd this line to %s, before compinit runs:\n\n  %s\n\n", utils.AbbreviateHome(target.RCFile), target.RCLine)
+	}
+	if !dry {
+		_, _ = fmt.Fprintln(w, "Start a new shell to use it.")
+	}
+	return nil
+}
+
+// detectShell returns the name of the shell at path, the value of $SHELL.
+func detectShell(path string) (string, error) {
+	shell := filepath.Base(path)
+	for _, s := range installShells {
+		if shell == s {
+			return shell, nil
+		}
+	}
+	if path == "" {
+		return "", fmt.Errorf("cannot tell the shell: $SHELL is not set; name it: gidtree completion install <%s>", strings.Join(installShells, "|"))
+	}
+	return "", fmt.Errorf("cannot install completion for %s; name one of: %s", shell, strings.Join(installShells, ", "))
+}
+
+// findCompletionTarget returns where the completion script of shell goes
+// for the home directory home, reading XDG_DATA_HOME, XDG_CONFIG_HOME and
+// ZDOTDIR through getenv.
+func findCompletionTarget(shell, home string, getenv func(string) string) (completionTarget, error) {
+	xdg := func(name, fallback string) string {
+		if dir := getenv(name); filepath.IsAbs(dir) {
+			return dir
+		}
+		return filepath.Join(home, fallback)
+	}
+
+	var target completionTarget
+	switch shell {
+	case "bash":
+		target.Path = filepath.Join(xdg("XDG_DATA_HOME", filepath.Join(".local", "share")), "bash-completion", "completions", "gidtree")
+	case "fish":
+		target.Path = filepath.Join(xdg("XDG_CONFIG_HOME", ".config"), "fish", "completions", "gidtree.fish")
+	case "zsh":
+		dir := filepath.Join(home, ".zsh", "completions")
+		target.Path = filepath.Join(dir, "_gidtree")
+		rcDir := home
+		if zdotdir := getenv("ZDOTDIR"); zdotdir != "" {
+			rcDir = zdotdir
+		}
+		target.RCFile = filepath.Join(rcDir, ".zshrc")
+		rc, _ := os.ReadFile(target.RCFile)
+		if !strings.Contains(string(rc), ".zsh/completions") && !strings.Contains(string(rc), dir) {
+			target.RCLine = "fpath=(~/.zsh/completions $fpath)"
+		} else {
+			target.RCFile = ""
+		}
+	default:
+		return completionTarget{}, fmt.Errorf("cannot install completion for %s; name one of: %s", shell, strings.Join(installShells, ", "))
+	}
+	return target, nil
+}
+
+// generateCompletion writes root's completion script for shell to w, as
+// 'gidtree completion <shell>' prints it.
+func generateCompletion(root *cobra.Command, shell string, w io.Writer) error {
+	switch shell {
+	case "bash":
+		return root.GenBashCompletionV2(w, true)
+	case "zsh":
+		return root.GenZshCompletion(w)
+	case "fish":
+		return root.GenFishCompletion(w, true)
+	}
+	return fmt.Errorf("unsupported shell %s", shell)
+}
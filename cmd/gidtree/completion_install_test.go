@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/thuanlegit/git-identitree/pkg/gidtree/gidtreetest"
+)
+
+func TestFindCompletionTarget(t *testing.T) {
+	t.Parallel()
+	home := t.TempDir()
+	noEnv := func(string) string { return "" }
+
+	tests := []struct {
+		name   string
+		shell  string
+		env    map[string]string
+		want   string
+		rcFile string
+	}{
+		{name: "bash", shell: "bash", want: ".local/share/bash-completion/completions/gidtree"},
+		{name: "bash XDG_DATA_HOME", shell: "bash", env: map[string]string{"XDG_DATA_HOME": filepath.Join(home, "data")}, want: "data/bash-completion/completions/gidtree"},
+		{name: "fish", shell: "fish", want: ".config/fish/completions/gidtree.fish"},
+		{name: "fish XDG_CONFIG_HOME", shell: "fish", env: map[string]string{"XDG_CONFIG_HOME": filepath.Join(home, "cfg")}, want: "cfg/fish/completions/gidtree.fish"},
+		{name: "fish relative XDG_CONFIG_HOME", shell: "fish", env: map[string]string{"XDG_CONFIG_HOME": "cfg"}, want: ".config/fish/completions/gidtree.fish"},
+		{name: "zsh", shell: "zsh", want: ".zsh/completions/_gidtree", rcFile: ".zshrc"},
+		{name: "zsh ZDOTDIR", shell: "zsh", env: map[string]string{"ZDOTDIR": filepath.Join(home, "zdot")}, want: ".zsh/completions/_gidtree", rcFile: "zdot/.zshrc"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			getenv := noEnv
+			if tt.env != nil {
+				getenv = func(name string) string { return tt.env[name] }
+			}
+			got, err := findCompletionTarget(tt.shell, home, getenv)
+			if err != nil {
+				t.Fatalf("findCompletionTarget() error = %v", err)
+			}
+			if want := filepath.Join(home, filepath.FromSlash(tt.want)); got.Path != want {
+				t.Errorf("Path = %q, want %q", got.Path, want)
+			}
+			wantRC := ""
+			if tt.rcFile != "" {
+				wantRC = filepath.Join(home, filepath.FromSlash(tt.rcFile))
+			}
+			if got.RCFile != wantRC || (wantRC != "") != (got.RCLine != "") {
+				t.Errorf("RCFile, RCLine = %q, %q; want %q", got.RCFile, got.RCLine, wantRC)
+			}
+		})
+	}
+
+	if _, err := findCompletionTarget("powershell", home, noEnv); err == nil {
+		t.Error("findCompletionTarget(powershell) should fail")
+	}
+}
+
+func TestFindCompletionTarget_ZshrcHasFpath(t *testing.T) {
+	t.Parallel()
+	home := t.TempDir()
+	rc := "fpath=(~/.zsh/completions $fpath)\nautoload -Uz compinit && compinit\n"
+	if err := os.WriteFile(filepath.Join(home, ".zshrc"), []byte(rc), 0644); err != nil {
+		t.Fatalf("Failed to write .zshrc: %v", err)
+	}
+
+	got, err := findCompletionTarget("zsh", home, func(string) string { return "" })
+	if err != nil {
+		t.Fatalf("findCompletionTarget() error = %v", err)
+	}
+	if got.RCLine != "" || got.RCFile != "" {
+		t.Errorf("findCompletionTarget() asks for %q in %q, which .zshrc already has", got.RCLine, got.RCFile)
+	}
+}
+
+func TestDetectShell(t *testing.T) {
+	t.Parallel()
+	for path, want := range map[string]string{
+		"/bin/bash":              "bash",
+		"/usr/local/bin/zsh":     "zsh",
+		"/opt/homebrew/bin/fish": "fish",
+		"/usr/bin/nu":            "",
+		"":                       "",
+	} {
+		got, err := detectShell(path)
+		if got != want || (want == "") != (err != nil) {
+			t.Errorf("detectShell(%q) = %q, %v; want %q", path, got, err, want)
+		}
+	}
+}
+
+func TestInstallCompletion(t *testing.T) {
+	env := gidtreetest.NewEnv(t).Build()
+	t.Setenv("SHELL", "/bin/zsh")
+	t.Setenv("ZDOTDIR", "")
+
+	// --dry-run names the target and writes nothing
+	var out bytes.Buffer
+	if err := installCompletion(&out, rootCmd, "", true); err != nil {
+		t.Fatalf("installCompletion(dry) error = %v", err)
+	}
+	if !strings.Contains(out.String(), "'gidtree completion zsh' to ~/.zsh/completions/_gidtree") || !strings.Contains(out.String(), "fpath=(~/.zsh/completions $fpath)") {
+		t.Errorf("dry run output = %q", out.String())
+	}
+	if _, err := os.Stat(env.Path(".zsh")); !os.IsNotExist(err) {
+		t.Errorf("dry run created ~/.zsh: %v", err)
+	}
+
+	out.Reset()
+	if err := installCompletion(&out, rootCmd, "fish", false); err != nil {
+		t.Fatalf("installCompletion(fish) error = %v", err)
+	}
+	script, err := os.ReadFile(env.Path(".config/fish/completions/gidtree.fish"))
+	if err != nil || !strings.Contains(string(script), "complete -c gidtree") {
+		t.Errorf("fish completion script = %.80q, %v", script, err)
+	}
+	if strings.Contains(out.String(), "Add this line") {
+		t.Errorf("fish needs no rc line, output = %q", out.String())
+	}
+}
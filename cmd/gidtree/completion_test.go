@@ -0,0 +1,236 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/thuanlegit/git-identitree/internal/profile"
+	"github.com/thuanlegit/git-identitree/pkg/gidtree/gidtreetest"
+
+	"github.com/spf13/cobra"
+)
+
+func TestCompleteMappedDirectories(t *testing.T) {
+	env := gidtreetest.NewEnv(t).
+		WithProfile(profile.Profile{Name: "work", Email: "work@example.com"}).
+		WithProfile(profile.Profile{Name: "personal", Email: "me@example.com"}).
+		WithMapping("work", "code/work").
+		WithMapping("personal", "code/personal").
+		WithMapping("work", "projects/acme").
+		Build()
+
+	tests := []struct {
+		name       string
+		toComplete string
+		want       []string
+	}{
+		{name: "all", toComplete: "", want: []string{"~/code/work/", "~/code/personal/", "~/projects/acme/"}},
+		{name: "partial", toComplete: "~/code/p", want: []string{"~/code/personal/"}},
+		{name: "absolute", toComplete: env.Path("proj"), want: []string{env.Path("projects/acme") + "/"}},
+		{name: "no match", toComplete: "~/other", want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, directive := completeMappedDirectories(unmapCmd, nil, tt.toComplete)
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("completeMappedDirectories(%q) = %v, want %v", tt.toComplete, got, tt.want)
+			}
+			if directive != cobra.ShellCompDirectiveNoFileComp {
+				t.Errorf("directive = %v, want NoFileComp", directive)
+			}
+		})
+	}
+
+	if got, _ := completeMappedDirectories(unmapCmd, []string{"~/code/work/"}, ""); got != nil {
+		t.Errorf("completion after the directory = %v, want none", got)
+	}
+}
+
+func TestCompleteProfileNames(t *testing.T) {
+	gidtreetest.NewEnv(t).
+		WithProfile(profile.Profile{Name: "work", Email: "work@example.com"}).
+		WithProfile(profile.Profile{Name: "Workshop", Email: "shop@example.com"}).
+		WithProfile(profile.Profile{Name: "personal", Email: "me@example.com"}).
+		Build()
+
+	for toComplete, want := range map[string]string{
+		"":      "personal,work,Workshop",
+		"wo":    "work,Workshop",
+		" WORK": "work,Workshop",
+		"p":     "personal",
+		"x":     "",
+	} {
+		got, directive := completeProfileNames(profileDeleteCmd, nil, toComplete)
+		if strings.Join(got, ",") != want {
+			t.Errorf("completeProfileNames(%q) = %v, want %s", toComplete, got, want)
+		}
+		if directive != cobra.ShellCompDirectiveNoFileComp {
+			t.Errorf("directive = %v, want NoFileComp", directive)
+		}
+	}
+	if got, _ := completeProfileNames(mapCmd, []string{"work"}, ""); got != nil {
+		t.Errorf("completion after the profile = %v, want none", got)
+	}
+}
+
+func TestCompleteMappedDirectories_UnreadableConfig(t *testing.T) {
+	env := gidtreetest.NewEnv(t).Build()
+	// A directory where the file should be cannot be parsed
+	if err := os.Mkdir(env.GitConfigPath(), 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+
+	got, directive := completeMappedDirectories(unmapCmd, nil, "")
+	if got != nil || directive != cobra.ShellCompDirectiveFilterDirs {
+		t.Errorf("completeMappedDirectories() = %v, %v; want directory completion", got, directive)
+	}
+}
+
+func TestCompleteLoadableProfiles(t *testing.T) {
+	env := gidtreetest.NewEnv(t).Build()
+	keyPath := filepath.Join(env.Home(), ".ssh", "id_work")
+	if err := os.MkdirAll(filepath.Dir(keyPath), 0700); err != nil {
+		t.Fatalf("Failed to create .ssh: %v", err)
+	}
+	if err := os.WriteFile(keyPath, []byte("key"), 0600); err != nil {
+		t.Fatalf("Failed to write key: %v", err)
+	}
+	err := profile.SaveProfiles([]profile.Profile{
+		{Name: "work", Email: "work@example.com", SSHKeyPath: "~/.ssh/id_work"},
+		{Name: "wiki", Email: "wiki@example.com", SSHKeyPath: "~/.ssh/id_deleted"},
+		{Name: "personal", Email: "me@example.com"},
+	})
+	if err != nil {
+		t.Fatalf("SaveProfiles() error = %v", err)
+	}
+
+	if got, _ := completeLoadableProfiles(sshLoadCmd, nil, ""); strings.Join(got, ",") != "work" {
+		t.Errorf("completeLoadableProfiles() = %v, want only the profile whose key exists", got)
+	}
+	if got, _ := completeLoadableProfiles(sshUnloadCmd, nil, "p"); got != nil {
+		t.Errorf("completeLoadableProfiles(%q) = %v, want none", "p", got)
+	}
+}
+
+func TestCompleteProfileFields(t *testing.T) {
+	env := gidtreetest.NewEnv(t).Build()
+	err := profile.SaveProfiles([]profile.Profile{
+		{Name: "work", Email: "jane@acme.com", SSHKeyPath: "~/.ssh/id_acme", GPGKeyID: "ABCD1234"},
+		{Name: "acme-ci", Email: "ci@acme.com", SSHKeyPath: "~/.ssh/id_acme"},
+		{Name: "personal", Email: "jane@example.com"},
+	})
+	if err != nil {
+		t.Fatalf("SaveProfiles() error = %v", err)
+	}
+
+	emails := []struct {
+		toComplete string
+		want       []string
+	}{
+		{"", []string{"ci@acme.com", "jane@example.com", "jane@acme.com"}},
+		{"jane@", []string{"jane@example.com", "jane@acme.com"}},
+		{"bob", []string{"bob@acme.com", "bob@example.com"}},
+		{"bob@ex", []string{"bob@example.com"}},
+		{"ci@a", []string{"ci@acme.com"}},
+	}
+	for _, tt := range emails {
+		got, directive := completeProfileEmails(profileCloneCmd, nil, tt.toComplete)
+		if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("completeProfileEmails(%q) = %v, want %v", tt.toComplete, got, tt.want)
+		}
+		if directive != cobra.ShellCompDirectiveNoFileComp {
+			t.Errorf("completeProfileEmails(%q) directive = %v, want NoFileComp", tt.toComplete, directive)
+		}
+	}
+
+	if got, _ := completeProfileSSHKeys(profileFindCmd, nil, "~/.ssh/"); strings.Join(got, ",") != "~/.ssh/id_acme" {
+		t.Errorf("completeProfileSSHKeys() = %v, want the shared key once", got)
+	}
+	if got, directive := completeProfileSSHKeys(profileFindCmd, nil, env.Path("keys/")); got != nil || directive != cobra.ShellCompDirectiveDefault {
+		t.Errorf("completeProfileSSHKeys() for a new key = %v, %v; want file completion", got, directive)
+	}
+	if got, _ := completeProfileGPGKeys(profileFindCmd, nil, "AB"); strings.Join(got, ",") != "ABCD1234" {
+		t.Errorf("completeProfileGPGKeys() = %v, want ABCD1234", got)
+	}
+}
+
+func TestCompleteProfileFields_UnreadableStore(t *testing.T) {
+	gidtreetest.NewEnv(t).Build()
+	profilesPath, err := profile.GetProfilesPath()
+	if err != nil {
+		t.Fatalf("GetProfilesPath() error = %v", err)
+	}
+	if err := os.WriteFile(profilesPath, []byte("profiles: [not yaml"), 0600); err != nil {
+		t.Fatalf("Failed to write profiles: %v", err)
+	}
+
+	if got, directive := completeProfileEmails(profileCloneCmd, nil, "bob"); got != nil || directive != cobra.ShellCompDirectiveNoFileComp {
+		t.Errorf("completeProfileEmails() = %v, %v; want no completion", got, directive)
+	}
+	if got, _ := completeProfileGPGKeys(profileFindCmd, nil, ""); got != nil {
+		t.Errorf("completeProfileGPGKeys() = %v, want none", got)
+	}
+}
+
+func TestCompleteProjectDirectories(t *testing.T) {
+	env := gidtreetest.NewEnv(t).Build()
+	for _, dir := range []string{"code/acme", "code/blog", "code/.cache"} {
+		if err := os.MkdirAll(env.Path(dir), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+	}
+	if err := os.WriteFile(env.Path("code/notes.txt"), []byte("notes"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	// Without projects_dir the shell completes directories
+	if got, directive := completeProjectDirectories(""); got != nil || directive != cobra.ShellCompDirectiveFilterDirs {
+		t.Errorf("completeProjectDirectories() without projects_dir = %v, %v", got, directive)
+	}
+
+	if err := configSetCmd.RunE(configSetCmd, []string{"projects_dir", "~/code"}); err != nil {
+		t.Fatalf("config set error = %v", err)
+	}
+	tests := []struct {
+		toComplete string
+		want       []string
+	}{
+		{"", []string{"~/code/acme/", "~/code/blog/"}},
+		{"~/code/b", []string{"~/code/blog/"}},
+		{"~/code/acme/", nil},
+		{"/elsewhere", nil},
+	}
+	for _, tt := range tests {
+		got, directive := completeProjectDirectories(tt.toComplete)
+		if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("completeProjectDirectories(%q) = %v, want %v", tt.toComplete, got, tt.want)
+		}
+		if tt.want == nil && directive != cobra.ShellCompDirectiveFilterDirs {
+			t.Errorf("completeProjectDirectories(%q) directive = %v, want FilterDirs", tt.toComplete, directive)
+		}
+	}
+
+	got, _ := mapCmd.ValidArgsFunction(mapCmd, []string{"work"}, "~/code/a")
+	if strings.Join(got, ",") != "~/code/acme/" {
+		t.Errorf("map directory completion = %v, want ~/code/acme/", got)
+	}
+}
+
+func TestCompletion_NeverPrompts(t *testing.T) {
+	gidtreetest.NewEnv(t).Build()
+	t.Setenv(profile.PassphraseEnv, "")
+	original := profile.PassphraseSource
+	t.Cleanup(func() { profile.PassphraseSource = original })
+	profile.PassphraseSource = func() (string, error) {
+		t.Error("completion asked for a passphrase")
+		return "", profile.ErrPassphraseRequired
+	}
+
+	runCLI(t, cobra.ShellCompRequestCmd, "profile", "show", "")
+	if _, err := profile.PassphraseSource(); !errors.Is(err, profile.ErrPassphraseRequired) {
+		t.Errorf("passphrase source during completion error = %v, want ErrPassphraseRequired", err)
+	}
+}
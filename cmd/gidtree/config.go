@@ -0,0 +1,185 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/thuanlegit/git-identitree/internal/config"
+	"github.com/thuanlegit/git-identitree/internal/profile"
+	"github.com/thuanlegit/git-identitree/internal/ssh"
+
+	"github.com/spf13/cobra"
+)
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Get and set gidtree settings",
+	Long: `Get and set gidtree's own settings, stored in ~/.gidtree/config.yaml
+($XDG_CONFIG_HOME/gidtree/config.yaml when XDG_CONFIG_HOME is set).
+
+Settings provide defaults; the matching command-line flags still override them.`,
+}
+
+var configGetCmd = &cobra.Command{
+	Use:               "get <key>",
+	Short:             "Print the value of a setting",
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeConfigKeys,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := config.Load()
+		if err != nil {
+			return fmt.Errorf("failed to load config: %w", err)
+		}
+		value, err := cfg.Get(args[0])
+		if err != nil {
+			return err
+		}
+		fmt.Println(value)
+		return nil
+	},
+}
+
+var configSetCmd = &cobra.Command{
+	Use:               "set <key> <value>",
+	Short:             "Change a setting",
+	Args:              cobra.ExactArgs(2),
+	ValidArgsFunction: completeConfigKeys,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := config.Load()
+		if err != nil {
+			return fmt.Errorf("failed to load config: %w", err)
+		}
+		if err := cfg.Set(args[0], args[1]); err != nil {
+			return fmt.Errorf("invalid value for %s: %w", args[0], err)
+		}
+		if err := config.Save(cfg); err != nil {
+			return fmt.Errorf("failed to save config: %w", err)
+		}
+		fmt.Printf("✓ %s set to '%s'\n", args[0], args[1])
+		return nil
+	},
+}
+
+var configListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "Print every setting with its value",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := config.Load()
+		if err != nil {
+			return fmt.Errorf("failed to load config: %w", err)
+		}
+		writeConfigList(os.Stdout, cfg)
+		return nil
+	},
+}
+
+// writeConfigList prints each known setting as "key = value".
+func writeConfigList(w io.Writer, cfg *config.Config) {
+	for _, k := range config.Keys() {
+		value, _ := cfg.Get(k.Name)
+		_, _ = fmt.Fprintf(w, "%s = %s\n", k.Name, value)
+	}
+}
+
+// completeConfigKeys completes setting names with a hint of the value they
+// take, then the accepted values of the chosen setting.
+func completeConfigKeys(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) == 0 {
+		var keys []string
+		for _, k := range config.Keys() {
+			keys = append(keys, fmt.Sprintf("%s\t(%s) %s", k.Name, k.Type, k.Description))
+		}
+		return keys, cobra.ShellCompDirectiveNoFileComp
+	}
+	if len(args) != 1 || cmd.Name() != "set" {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	if args[0] == "default_profile" {
+		manager, err := profile.NewDefaultManager()
+		if err != nil {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		var names []string
+		for _, p := range manager.ListProfiles() {
+			names = append(names, p.Name)
+		}
+		return names, cobra.ShellCompDirectiveNoFileComp
+	}
+	k, err := config.LookupKey(args[0])
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	if k.Type == "directory" {
+		return nil, cobra.ShellCompDirectiveFilterDirs
+	}
+	if k.Type == "file" {
+		return nil, cobra.ShellCompDirectiveDefault
+	}
+	return k.Values, cobra.ShellCompDirectiveNoFileComp
+}
+
+// configuredBool returns the named flag's value when it was given on the
+// command line, and the configured default otherwise.
+func configuredBool(cmd *cobra.Command, name string, flagValue, configured bool) bool {
+	if cmd.Flags().Changed(name) {
+		return flagValue
+	}
+	return configured
+}
+
+// jsonOutput reports whether a command with a --json flag should print JSON,
+// falling back to output_format when the flag was not given.
+func jsonOutput(cmd *cobra.Command, flagValue bool) (bool, error) {
+	if cmd.Flags().Changed("json") {
+		return flagValue, nil
+	}
+	cfg, err := config.Load()
+	if err != nil {
+		return false, fmt.Errorf("failed to load config: %w", err)
+	}
+	return cfg.OutputFormat == config.OutputJSON, nil
+}
+
+// loadProfileKey loads prof's SSH key, honoring the --exclusive and --keychain
+// flags of cmd or, when they were not given, exclusive_keys and use_keychain.
+func loadProfileKey(cmd *cobra.Command, prof *profile.Profile, exclusive, keychain bool) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	if configuredBool(cmd, "exclusive", exclusive, cfg.ExclusiveKeys) {
+		manager, err := profile.NewDefaultManager()
+		if err != nil {
+			return fmt.Errorf("failed to initialize profile manager: %w", err)
+		}
+		err = ssh.UnloadOtherKeys(prof.SSHKeyPath, manager.ListProfiles())
+		if errors.Is(err, ssh.ErrAgentReadOnly) {
+			fmt.Fprintf(os.Stderr, "⚠ Other profiles' keys stay loaded: %v\n", err)
+		} else if err != nil {
+			return err
+		}
+	}
+
+	opts := ssh.LoadOptions{
+		UseKeychain:       configuredBool(cmd, "keychain", keychain, cfg.UseKeychain),
+		StrictPermissions: cfg.KeyPermissions == config.KeyPermissionsError,
+		IgnorePermissions: cfg.KeyPermissions == config.KeyPermissionsIgnore,
+		Warnings:          os.Stderr,
+	}
+	if err := ssh.LoadKeyWithOptions(prof.SSHKeyPath, opts); err != nil {
+		return err
+	}
+	markUsed(prof)
+	return nil
+}
+
+func init() {
+	configCmd.AddCommand(configGetCmd)
+	configCmd.AddCommand(configSetCmd)
+	configCmd.AddCommand(configListCmd)
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/thuanlegit/git-identitree/internal/config"
+	"github.com/thuanlegit/git-identitree/internal/profile"
+	"github.com/thuanlegit/git-identitree/pkg/gidtree/gidtreetest"
+
+	"github.com/spf13/cobra"
+)
+
+// setFlag sets a flag on cmd for one test, as if given on the command line.
+func setFlag(t *testing.T, cmd *cobra.Command, name, value string) {
+	t.Helper()
+	flag := cmd.Flags().Lookup(name)
+	original := flag.Value.String()
+	if err := cmd.Flags().Set(name, value); err != nil {
+		t.Fatalf("Set(%s) error = %v", name, err)
+	}
+	t.Cleanup(func() {
+		_ = flag.Value.Set(original)
+		flag.Changed = false
+	})
+}
+
+func TestConfigCommands(t *testing.T) {
+	gidtreetest.NewEnv(t).
+		WithProfile(profile.Profile{Name: "work", Email: "work@example.com"}).
+		Build()
+
+	for _, kv := range [][2]string{{"output_format", "json"}, {"backup_retention", "4"}, {"default_profile", "work"}} {
+		if err := configSetCmd.RunE(configSetCmd, kv[:]); err != nil {
+			t.Fatalf("config set %s error = %v", kv[0], err)
+		}
+	}
+	if err := configSetCmd.RunE(configSetCmd, []string{"colour", "auto"}); !errors.Is(err, config.ErrUnknownKey) {
+		t.Errorf("config set of an unknown key error = %v, want ErrUnknownKey", err)
+	}
+	if err := configGetCmd.RunE(configGetCmd, []string{"colour"}); !errors.Is(err, config.ErrUnknownKey) {
+		t.Errorf("config get of an unknown key error = %v, want ErrUnknownKey", err)
+	}
+	if err := configSetCmd.RunE(configSetCmd, []string{"backup_retention", "0"}); err == nil {
+		t.Error("config set with an invalid value should fail")
+	}
+	if err := configSetCmd.RunE(configSetCmd, []string{"default_profile", "missing"}); !errors.Is(err, profile.ErrProfileNotFound) {
+		t.Errorf("config set default_profile to a missing profile error = %v, want ErrProfileNotFound", err)
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	var out bytes.Buffer
+	writeConfigList(&out, cfg)
+	for _, want := range []string{"output_format = json", "backup_retention = 4", "default_profile = work", "exclusive_keys = false"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("config list = %q, want it to contain %q", out.String(), want)
+		}
+	}
+}
+
+func TestCompleteConfigKeys(t *testing.T) {
+	gidtreetest.NewEnv(t).
+		WithProfile(profile.Profile{Name: "work", Email: "work@example.com"}).
+		Build()
+
+	keys, _ := completeConfigKeys(configGetCmd, nil, "")
+	if len(keys) != len(config.Keys()) || !strings.HasPrefix(keys[0], "default_profile\t") {
+		t.Errorf("key completion = %v, want every key with its description", keys)
+	}
+
+	if values, _ := completeConfigKeys(configSetCmd, []string{"output_format"}, ""); strings.Join(values, ",") != "text,json" {
+		t.Errorf("output_format completion = %v, want text,json", values)
+	}
+	if values, _ := completeConfigKeys(configSetCmd, []string{"default_profile"}, ""); strings.Join(values, ",") != "work" {
+		t.Errorf("default_profile completion = %v, want work", values)
+	}
+	if values, _ := completeConfigKeys(configGetCmd, []string{"output_format"}, ""); values != nil {
+		t.Errorf("get takes one key, completion = %v", values)
+	}
+}
+
+func TestMapCommand_CaseSensitiveFromConfig(t *testing.T) {
+	env := gidtreetest.NewEnv(t).
+		WithProfile(profile.Profile{Name: "work", Email: "work@example.com"}).
+		Build()
+	if err := configSetCmd.RunE(configSetCmd, []string{"case_sensitive_gitdir", "true"}); err != nil {
+		t.Fatalf("config set error = %v", err)
+	}
+
+	readGitConfig := func() string {
+		data, err := os.ReadFile(env.Path(".gitconfig"))
+		if err != nil {
+			t.Fatalf("Failed to read git config: %v", err)
+		}
+		return string(data)
+	}
+
+	setFlag(t, mapCmd, "create", "true")
+	setFlag(t, mapCmd, "create-gitconfig", "true")
+	if err := mapCmd.RunE(mapCmd, []string{"work", env.Path("cs")}); err != nil {
+		t.Fatalf("map error = %v", err)
+	}
+	if got := readGitConfig(); !strings.Contains(got, `"gitdir:`+env.Path("cs")) {
+		t.Errorf("git config = %s, want a gitdir: condition from the config", got)
+	}
+
+	// The flag overrides the configured default
+	setFlag(t, mapCmd, "case-sensitive", "false")
+	if err := mapCmd.RunE(mapCmd, []string{"work", env.Path("ci")}); err != nil {
+		t.Fatalf("map --case-sensitive=false error = %v", err)
+	}
+	if got := readGitConfig(); !strings.Contains(got, `"gitdir/i:`+env.Path("ci")) {
+		t.Errorf("git config = %s, want a gitdir/i: condition from the flag", got)
+	}
+}
+
+func TestJSONOutput(t *testing.T) {
+	gidtreetest.NewEnv(t).Build()
+
+	if asJSON, err := jsonOutput(resolveCmd, false); err != nil || asJSON {
+		t.Errorf("jsonOutput() with defaults = %v, %v; want false", asJSON, err)
+	}
+
+	if err := configSetCmd.RunE(configSetCmd, []string{"output_format", "json"}); err != nil {
+		t.Fatalf("config set error = %v", err)
+	}
+	if asJSON, err := jsonOutput(resolveCmd, false); err != nil || !asJSON {
+		t.Errorf("jsonOutput() with output_format json = %v, %v; want true", asJSON, err)
+	}
+
+	setFlag(t, resolveCmd, "json", "false")
+	if asJSON, err := jsonOutput(resolveCmd, false); err != nil || asJSON {
+		t.Errorf("jsonOutput() with --json=false = %v, %v; want false", asJSON, err)
+	}
+}
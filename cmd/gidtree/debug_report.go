@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/thuanlegit/git-identitree/internal/debugreport"
+	"github.com/thuanlegit/git-identitree/internal/utils"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	debugReportOut          string
+	debugReportIncludeNames bool
+)
+
+var debugReportCmd = &cobra.Command{
+	Use:   "debug-report",
+	Short: "Write a snapshot of the setup to attach to a bug report",
+	Long: `Write a Markdown snapshot of the local setup to attach to a bug report: the
+gidtree, OS and git versions, whether ssh-agent is reachable, the profiles and
+the shape of the includeIf blocks in the git config.
+
+Nothing is sent anywhere. Emails, key paths and directory names are replaced
+by hashes salted anew for every report, so the report shows which values are
+the same without revealing them. Profile names are masked too unless
+--include-names is given. With the global --verbose flag, the errors logged
+while collecting the report are included, masked the same way.`,
+	Example: `  gidtree debug-report
+  gidtree debug-report --verbose -o report.md`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		out := cmd.OutOrStdout()
+		if debugReportOut != "" && debugReportOut != "-" {
+			f, err := os.OpenFile(debugReportOut, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, utils.PrivateFileMode)
+			if err != nil {
+				return fmt.Errorf("failed to create report file: %w", err)
+			}
+			defer f.Close()
+			out = f
+		}
+		return writeDebugReport(out, debugreport.Options{IncludeNames: debugReportIncludeNames})
+	},
+}
+
+// writeDebugReport collects a debug report and writes it to w as Markdown.
+func writeDebugReport(w io.Writer, opts debugreport.Options) error {
+	info := currentVersion()
+	build := fmt.Sprintf("%s (commit %s, built %s, %s)", info.Version, info.Commit, info.Date, info.GoVersion)
+	report, err := debugreport.Collect(build, opts)
+	if err != nil {
+		return fmt.Errorf("failed to collect debug report: %w", err)
+	}
+	if _, err := io.WriteString(w, report.Markdown()); err != nil {
+		return fmt.Errorf("failed to write debug report: %w", err)
+	}
+	return nil
+}
+
+func init() {
+	debugReportCmd.Flags().StringVarP(&debugReportOut, "out", "o", "", "Write the report to a file instead of stdout")
+	debugReportCmd.Flags().BoolVar(&debugReportIncludeNames, "include-names", false, "Show profile names instead of masking them")
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/thuanlegit/git-identitree/internal/debugreport"
+	"github.com/thuanlegit/git-identitree/internal/profile"
+	"github.com/thuanlegit/git-identitree/pkg/gidtree/gidtreetest"
+)
+
+func TestWriteDebugReport(t *testing.T) {
+	env := gidtreetest.NewEnv(t).
+		WithProfile(profile.Profile{Name: "acmeclient", Email: "jane@acmecorp.example"}).
+		WithMapping("acmeclient", "clients/acmecorp").
+		Build()
+
+	var out bytes.Buffer
+	if err := writeDebugReport(&out, debugreport.Options{}); err != nil {
+		t.Fatalf("writeDebugReport() error = %v", err)
+	}
+	if !strings.HasPrefix(out.String(), "# gidtree debug report\n") || !strings.Contains(out.String(), "| gidtree | "+version+" (commit ") {
+		t.Errorf("report = %s, want the header and build", out.String())
+	}
+	for _, raw := range []string{env.Home(), "acmeclient", "jane@", "acmecorp", "clients"} {
+		if strings.Contains(out.String(), raw) {
+			t.Errorf("report leaks %q:\n%s", raw, out.String())
+		}
+	}
+
+	out.Reset()
+	if err := writeDebugReport(&out, debugreport.Options{IncludeNames: true}); err != nil {
+		t.Fatalf("writeDebugReport() error = %v", err)
+	}
+	if !strings.Contains(out.String(), "- acmeclient: ") {
+		t.Errorf("report = %s, want the profile name with --include-names", out.String())
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/thuanlegit/git-identitree/internal/cli"
+	"github.com/thuanlegit/git-identitree/internal/config"
+	"github.com/thuanlegit/git-identitree/internal/mapping"
+	"github.com/thuanlegit/git-identitree/internal/profile"
+)
+
+// deleteProfile deletes a profile, first unmapping every directory mapped to
+// it once confirm agrees. It reports progress to w.
+func deleteProfile(w io.Writer, manager *profile.Manager, profileName string, confirm cli.Confirmer) error {
+	// Check if profile exists
+	prof, err := manager.GetProfile(profileName)
+	if err != nil {
+		return fmt.Errorf("profile not found: %w", err)
+	}
+	profileName = prof.Name
+
+	// Get all directories mapped to this profile
+	directories, err := mapping.GetDirectoriesForProfile(profileName)
+	if err != nil {
+		return fmt.Errorf("failed to check profile mappings: %w", err)
+	}
+
+	// If profile is mapped, ask user if they want to unmap
+	if len(directories) > 0 {
+		var list strings.Builder
+		for _, dir := range directories {
+			list.WriteString(fmt.Sprintf("  - %s\n", dir))
+		}
+
+		ok, err := confirm(
+			"Unmap all directories and delete the profile?",
+			fmt.Sprintf("Profile '%s' is mapped to the following directories:\n%s", profileName, list.String()),
+		)
+		if err != nil {
+			return fmt.Errorf("failed to confirm delete: %w", err)
+		}
+		if !ok {
+			_, _ = fmt.Fprintln(w, "Delete cancelled.")
+			return nil
+		}
+
+		// Unmap all directories
+		_, _ = fmt.Fprintln(w, "Unmapping directories...")
+		if err := mapping.UnmapDirectories(directories); err != nil {
+			return fmt.Errorf("failed to unmap directories: %w", err)
+		}
+		for _, dir := range directories {
+			_, _ = fmt.Fprintf(w, "  ✓ Unmapped: %s\n", dir)
+		}
+	}
+
+	// Delete the profile (no need to check mappings again)
+	isMapped := func(name string) (bool, error) {
+		return false, nil // Already handled above
+	}
+
+	if err := manager.DeleteProfile(profileName, isMapped); err != nil {
+		return fmt.Errorf("failed to delete profile: %w", err)
+	}
+
+	_, _ = fmt.Fprintf(w, "\n✓ Profile '%s' deleted successfully\n", profileName)
+
+	// A default pointing at a deleted profile would silently stop applying
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	if cfg.DefaultProfile == profileName {
+		if err := config.SetDefaultProfile(""); err != nil {
+			return fmt.Errorf("failed to clear default profile: %w", err)
+		}
+		_, _ = fmt.Fprintf(w, "Warning: '%s' was the default profile; no default is set now\n", profileName)
+	}
+	return nil
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/thuanlegit/git-identitree/internal/cli"
+	"github.com/thuanlegit/git-identitree/internal/config"
+	"github.com/thuanlegit/git-identitree/internal/mapping"
+	"github.com/thuanlegit/git-identitree/internal/profile"
+	"github.com/thuanlegit/git-identitree/pkg/gidtree/gidtreetest"
+)
+
+// buildMappedProfile sets up a "work" profile mapped to two directories.
+func buildMappedProfile(t *testing.T) *gidtreetest.Built {
+	t.Helper()
+	return gidtreetest.NewEnv(t).
+		WithProfile(profile.Profile{Name: "work", Email: "work@example.com"}).
+		WithMapping("work", "code/a").
+		WithMapping("work", "code/b").
+		Build()
+}
+
+func TestDeleteProfile(t *testing.T) {
+	tests := []struct {
+		name        string
+		answer      bool
+		answerErr   error
+		wantErr     bool
+		wantDeleted bool
+		wantOutput  string
+	}{
+		{name: "confirmed", answer: true, wantDeleted: true, wantOutput: "Unmapped"},
+		{name: "declined", answer: false, wantOutput: "Delete cancelled."},
+		{name: "no terminal", answerErr: cli.ErrNoConfirmation, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buildMappedProfile(t)
+			manager, err := profile.NewDefaultManager()
+			if err != nil {
+				t.Fatalf("NewDefaultManager() error = %v", err)
+			}
+
+			var asked string
+			confirm := func(title, description string) (bool, error) {
+				asked = description
+				return tt.answer, tt.answerErr
+			}
+
+			var out bytes.Buffer
+			err = deleteProfile(&out, manager, "work", confirm)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("deleteProfile() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.answerErr != nil && !errors.Is(err, tt.answerErr) {
+				t.Errorf("deleteProfile() error = %v, want it to wrap %v", err, tt.answerErr)
+			}
+			if !strings.Contains(asked, "code/a") || !strings.Contains(asked, "code/b") {
+				t.Errorf("confirmation should list the mapped directories, got %q", asked)
+			}
+			if !strings.Contains(out.String(), tt.wantOutput) {
+				t.Errorf("deleteProfile() output = %q, want it to contain %q", out.String(), tt.wantOutput)
+			}
+
+			_, getErr := manager.GetProfile("work")
+			if deleted := getErr != nil; deleted != tt.wantDeleted {
+				t.Errorf("profile deleted = %v, want %v", deleted, tt.wantDeleted)
+			}
+			dirs, err := mapping.GetDirectoriesForProfile("work")
+			if err != nil {
+				t.Fatalf("GetDirectoriesForProfile() error = %v", err)
+			}
+			if wantDirs := map[bool]int{true: 0, false: 2}[tt.wantDeleted]; len(dirs) != wantDirs {
+				t.Errorf("mapped directories = %v, want %d", dirs, wantDirs)
+			}
+		})
+	}
+}
+
+func TestDeleteProfile_OtherCase(t *testing.T) {
+	buildMappedProfile(t)
+	manager, err := profile.NewDefaultManager()
+	if err != nil {
+		t.Fatalf("NewDefaultManager() error = %v", err)
+	}
+	confirm := func(string, string) (bool, error) { return true, nil }
+
+	var out bytes.Buffer
+	if err := deleteProfile(&out, manager, " Work ", confirm); err != nil {
+		t.Fatalf("deleteProfile() error = %v", err)
+	}
+	if !strings.Contains(out.String(), "Profile 'work' deleted") {
+		t.Errorf("deleteProfile() output = %q, want the profile's own name", out.String())
+	}
+	dirs, err := mapping.GetDirectoriesForProfile("work")
+	if err != nil || len(dirs) != 0 {
+		t.Errorf("GetDirectoriesForProfile() = %v, %v, want the directories unmapped", dirs, err)
+	}
+}
+
+func TestDeleteProfile_UnmappedSkipsConfirmation(t *testing.T) {
+	gidtreetest.NewEnv(t).
+		WithProfile(profile.Profile{Name: "work", Email: "work@example.com"}).
+		Build()
+	manager, err := profile.NewDefaultManager()
+	if err != nil {
+		t.Fatalf("NewDefaultManager() error = %v", err)
+	}
+
+	confirm := func(string, string) (bool, error) {
+		t.Error("confirmation should not be asked for an unmapped profile")
+		return false, nil
+	}
+
+	var out bytes.Buffer
+	if err := deleteProfile(&out, manager, "work", confirm); err != nil {
+		t.Fatalf("deleteProfile() error = %v", err)
+	}
+	if _, err := manager.GetProfile("work"); err == nil {
+		t.Error("profile should have been deleted")
+	}
+	if err := deleteProfile(&out, manager, "work", confirm); err == nil {
+		t.Error("deleteProfile() should fail for a missing profile")
+	}
+}
+
+func TestProfileDeleteCommand_Yes(t *testing.T) {
+	buildMappedProfile(t)
+
+	// Test stdin is not a terminal, so without --yes the command fails fast
+	if err := profileDeleteCmd.RunE(profileDeleteCmd, []string{"work"}); !errors.Is(err, cli.ErrNoConfirmation) {
+		t.Fatalf("delete without --yes error = %v, want cli.ErrNoConfirmation", err)
+	}
+
+	assumeYes = true
+	defer func() { assumeYes = false }()
+
+	if err := profileDeleteCmd.RunE(profileDeleteCmd, []string{"work"}); err != nil {
+		t.Fatalf("delete --yes error = %v", err)
+	}
+	if mapped, err := mapping.IsProfileMapped("work"); err != nil || mapped {
+		t.Errorf("IsProfileMapped() = %v, %v; want false after delete --yes", mapped, err)
+	}
+}
+
+func TestDeleteProfile_ClearsDefault(t *testing.T) {
+	gidtreetest.NewEnv(t).
+		WithProfile(profile.Profile{Name: "work", Email: "work@example.com"}).
+		WithProfile(profile.Profile{Name: "personal", Email: "me@example.com"}).
+		Build()
+	manager, err := profile.NewDefaultManager()
+	if err != nil {
+		t.Fatalf("NewDefaultManager() error = %v", err)
+	}
+	if err := config.SetDefaultProfile("work"); err != nil {
+		t.Fatalf("SetDefaultProfile() error = %v", err)
+	}
+
+	var out bytes.Buffer
+	if err := deleteProfile(&out, manager, "personal", nil); err != nil {
+		t.Fatalf("deleteProfile(personal) error = %v", err)
+	}
+	if strings.Contains(out.String(), "Warning") {
+		t.Errorf("deleting a non-default profile should not warn, got %q", out.String())
+	}
+
+	if err := deleteProfile(&out, manager, "work", nil); err != nil {
+		t.Fatalf("deleteProfile(work) error = %v", err)
+	}
+	if !strings.Contains(out.String(), "'work' was the default profile") {
+		t.Errorf("deleteProfile() output = %q, want a default-profile warning", out.String())
+	}
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.DefaultProfile != "" {
+		t.Errorf("DefaultProfile = %q, want it cleared", cfg.DefaultProfile)
+	}
+}
+
+func TestDeleteProfile_ChangedOnDisk(t *testing.T) {
+	gidtreetest.NewEnv(t).
+		WithProfile(profile.Profile{Name: "work", Email: "work@example.com"}).
+		Build()
+	manager, err := profile.NewDefaultManager()
+	if err != nil {
+		t.Fatalf("NewDefaultManager() error = %v", err)
+	}
+	// Another terminal adds a profile after this one loaded them
+	other, err := profile.NewDefaultManager()
+	if err != nil {
+		t.Fatalf("NewDefaultManager() error = %v", err)
+	}
+	if err := other.AddProfile(profile.Profile{Name: "personal", Email: "me@example.com"}); err != nil {
+		t.Fatalf("AddProfile() error = %v", err)
+	}
+
+	var out bytes.Buffer
+	err = deleteProfile(&out, manager, "work", cli.NewConfirmer(true, nil))
+	if !errors.Is(err, profile.ErrProfilesChanged) {
+		t.Fatalf("deleteProfile() error = %v, want ErrProfilesChanged", err)
+	}
+
+	manager.Force = true
+	if err := deleteProfile(&out, manager, "work", cli.NewConfirmer(true, nil)); err != nil {
+		t.Fatalf("deleteProfile() with Force error = %v", err)
+	}
+}
@@ -0,0 +1,288 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/thuanlegit/git-identitree/internal/cli"
+	"github.com/thuanlegit/git-identitree/internal/doctor"
+	"github.com/thuanlegit/git-identitree/internal/identity"
+	"github.com/thuanlegit/git-identitree/internal/mapping"
+	"github.com/thuanlegit/git-identitree/internal/profile"
+
+	"github.com/spf13/cobra"
+)
+
+var doctorFix bool
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Check the local setup for problems",
+	Long: `Check the local setup for problems and offer to fix them.
+
+It reports files readable by other users: everything in ~/.gidtree, the
+generated ~/.gitconfig-<profile> files and the SSH private keys profiles
+reference. With --fix they are restricted to their owner without asking.
+Permission checks are skipped on Windows.
+
+It also reports profile names unsafe in file names or git config (fixed by
+'gidtree profile rename'), mappings whose directory no longer exists,
+generated config that has drifted from the profiles (fixed by 'gidtree sync'),
+a missing default identity in ~/.gitconfig, mappings that change nothing
+because their profile has the default identity's email and, inside a
+repository, whether the identity git resolves matches the mapped profile. It names the SSH agent SSH_AUTH_SOCK points at, and warns
+when it does not answer or when exclusive_keys is on but the agent, such as
+1Password or gpg-agent, cannot remove keys.
+
+A directory mapped by more than one includeIf block gets the identity of the
+last one, as git applies them in order. Fixing it keeps that block and
+removes the others, after confirmation unless --fix is given.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		confirm := confirmer()
+		if doctorFix {
+			confirm = func(string, string) (bool, error) { return true, nil }
+		}
+		remaining, err := checkPermissions(cmd.OutOrStdout(), confirm)
+		if err != nil {
+			return err
+		}
+		invalid, err := checkProfileNames(cmd.OutOrStdout())
+		if err != nil {
+			return err
+		}
+		missing, err := checkMappedDirectories(cmd.OutOrStdout())
+		if err != nil {
+			return err
+		}
+		duplicated, err := checkDuplicateMappings(cmd.OutOrStdout(), confirm)
+		if err != nil {
+			return err
+		}
+		drifted, err := checkDrift(cmd.OutOrStdout())
+		if err != nil {
+			return err
+		}
+		identityWarnings, err := checkDefaultIdentity(cmd.OutOrStdout())
+		if err != nil {
+			return err
+		}
+		cwd, err := os.Getwd()
+		if err != nil {
+			return fmt.Errorf("failed to get current directory: %w", err)
+		}
+		mismatched, err := checkEffectiveIdentity(cmd.OutOrStdout(), cwd)
+		if err != nil {
+			return err
+		}
+		agentProblems, err := checkSSHAgent(cmd.OutOrStdout())
+		if err != nil {
+			return err
+		}
+		if remaining += invalid + missing + duplicated + drifted + identityWarnings + mismatched + agentProblems; remaining > 0 {
+			return fmt.Errorf("%d problem(s) remain", remaining)
+		}
+		return nil
+	},
+}
+
+// checkPermissions reports files with loose permissions to w and fixes them
+// once confirm agrees. It returns how many problems are left unfixed.
+func checkPermissions(w io.Writer, confirm cli.Confirmer) (int, error) {
+	issues, err := doctor.CheckPermissions()
+	if err != nil {
+		return 0, fmt.Errorf("failed to check permissions: %w", err)
+	}
+	if len(issues) == 0 {
+		_, _ = fmt.Fprintln(w, "✓ File permissions are private")
+		return 0, nil
+	}
+
+	var list strings.Builder
+	for _, issue := range issues {
+		_, _ = fmt.Fprintf(w, "⚠ %s\n", issue)
+		list.WriteString(fmt.Sprintf("  - %s\n", issue.Path))
+	}
+
+	ok, err := confirm(
+		"Restrict these files to your user?",
+		fmt.Sprintf("These files can be read by other users:\n%s", list.String()),
+	)
+	if errors.Is(err, cli.ErrNoConfirmation) {
+		_, _ = fmt.Fprintln(w, "Run 'gidtree doctor --fix' to restrict them to your user.")
+		return len(issues), nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to confirm fix: %w", err)
+	}
+	if !ok {
+		return len(issues), nil
+	}
+
+	remaining := 0
+	for _, issue := range issues {
+		if err := issue.Fix(); err != nil {
+			_, _ = fmt.Fprintf(w, "  ✗ %v\n", err)
+			remaining++
+			continue
+		}
+		_, _ = fmt.Fprintf(w, "  ✓ Fixed: %s\n", issue.Path)
+	}
+	return remaining, nil
+}
+
+// checkProfileNames reports profiles whose names cannot be used safely in
+// file names and git config, and returns how many there are. Renaming is left
+// to the user, who has to pick the new name.
+func checkProfileNames(w io.Writer) (int, error) {
+	invalid, err := doctor.InvalidNames()
+	if err != nil {
+		return 0, fmt.Errorf("failed to check profile names: %w", err)
+	}
+	if len(invalid) == 0 {
+		_, _ = fmt.Fprintln(w, "✓ Profile names are valid")
+		return 0, nil
+	}
+	for _, n := range invalid {
+		_, _ = fmt.Fprintf(w, "⚠ %s\n", n)
+	}
+	return len(invalid), nil
+}
+
+// checkMappedDirectories reports mappings whose directory has been deleted
+// and returns how many there are.
+func checkMappedDirectories(w io.Writer) (int, error) {
+	missing, err := doctor.MissingDirectories()
+	if err != nil {
+		return 0, fmt.Errorf("failed to check mapped directories: %w", err)
+	}
+	if len(missing) == 0 {
+		_, _ = fmt.Fprintln(w, "✓ Mapped directories exist")
+		return 0, nil
+	}
+	for _, m := range missing {
+		_, _ = fmt.Fprintf(w, "⚠ %s (profile '%s') no longer exists; remove it with: gidtree unmap %s\n", m.Directory, m.Profile, m.Directory)
+	}
+	return len(missing), nil
+}
+
+// checkDuplicateMappings reports directories with more than one includeIf
+// block and, once confirm agrees, removes all but the last. It returns how many
+// directories are left duplicated.
+func checkDuplicateMappings(w io.Writer, confirm cli.Confirmer) (int, error) {
+	duplicates, err := doctor.DuplicateMappings()
+	if err != nil {
+		return 0, fmt.Errorf("failed to check for duplicate mappings: %w", err)
+	}
+	if len(duplicates) == 0 {
+		_, _ = fmt.Fprintln(w, "✓ Each directory is mapped once")
+		return 0, nil
+	}
+
+	var list strings.Builder
+	for _, d := range duplicates {
+		_, _ = fmt.Fprintf(w, "⚠ %s\n", d)
+		list.WriteString(fmt.Sprintf("  - %s → %s\n", d.Directory, d.Kept.Profile))
+	}
+
+	ok, err := confirm(
+		"Remove the overridden blocks?",
+		fmt.Sprintf("Only the last block for each directory is kept, the one git uses:\n%s", list.String()),
+	)
+	if errors.Is(err, cli.ErrNoConfirmation) {
+		_, _ = fmt.Fprintln(w, "Run 'gidtree doctor --fix' to keep the last block for each directory.")
+		return len(duplicates), nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to confirm fix: %w", err)
+	}
+	if !ok {
+		return len(duplicates), nil
+	}
+
+	remaining := 0
+	for _, d := range duplicates {
+		if err := d.Fix(); err != nil {
+			_, _ = fmt.Fprintf(w, "  ✗ %s: %v\n", d.Directory, err)
+			remaining++
+			continue
+		}
+		_, _ = fmt.Fprintf(w, "  ✓ Fixed: %s → %s\n", d.Directory, d.Kept.Profile)
+	}
+	return remaining, nil
+}
+
+// checkDrift reports where the generated git config disagrees with the
+// profiles and returns how many differences there are.
+func checkDrift(w io.Writer) (int, error) {
+	drifts, err := doctor.CheckDrift()
+	if err != nil {
+		return 0, fmt.Errorf("failed to check for drift: %w", err)
+	}
+	if len(drifts) == 0 {
+		_, _ = fmt.Fprintln(w, "✓ Generated git config matches the profiles")
+		return 0, nil
+	}
+	for _, d := range drifts {
+		_, _ = fmt.Fprintf(w, "⚠ %s\n", d)
+	}
+	_, _ = fmt.Fprintln(w, "Run 'gidtree sync' to fix them.")
+	return len(drifts), nil
+}
+
+// checkDefaultIdentity reports a missing default identity in ~/.gitconfig and
+// mappings that change nothing because their profile has the default email.
+// It returns how many warnings there are.
+func checkDefaultIdentity(w io.Writer) (int, error) {
+	global, err := mapping.ParseGlobalUser()
+	if err != nil {
+		return 0, fmt.Errorf("failed to read default identity: %w", err)
+	}
+	mappings, err := mapping.ParseMappings()
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse mappings: %w", err)
+	}
+	manager, err := profile.NewDefaultManager()
+	if err != nil {
+		return 0, fmt.Errorf("failed to load profiles: %w", err)
+	}
+
+	warnings := mapping.IdentityWarnings(global, mappings, manager.ListProfiles())
+	if len(warnings) == 0 {
+		_, _ = fmt.Fprintf(w, "✓ Default identity is %s <%s>\n", global.Name, global.Email)
+		return 0, nil
+	}
+	for _, warning := range warnings {
+		_, _ = fmt.Fprintf(w, "⚠ %s\n", warning)
+	}
+	return len(warnings), nil
+}
+
+// checkEffectiveIdentity asks git which identity it uses in dir and reports
+// whether it matches the mapped profile. It returns 1 on a mismatch. Outside
+// a repository there is nothing to check.
+func checkEffectiveIdentity(w io.Writer, dir string) (int, error) {
+	s, err := identity.Summarize(dir)
+	if err != nil {
+		return 0, fmt.Errorf("failed to resolve identity: %w", err)
+	}
+	if s.Effective == nil {
+		return 0, nil
+	}
+	if s.Effective.Matches {
+		_, _ = fmt.Fprintf(w, "✓ Git uses %s <%s> here\n", s.Effective.Name, s.Effective.Email)
+		return 0, nil
+	}
+	_, _ = fmt.Fprintf(w, "⚠ Git uses %s <%s> here, not profile '%s' <%s>\n", s.Effective.Name, s.Effective.Email, s.Profile.Name, s.Profile.Email)
+	for _, cause := range s.Effective.Causes {
+		_, _ = fmt.Fprintf(w, "  - %s\n", cause)
+	}
+	return 1, nil
+}
+
+func init() {
+	doctorCmd.Flags().BoolVar(&doctorFix, "fix", false, "Fix problems without asking")
+}
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/thuanlegit/git-identitree/internal/cli"
+	"github.com/thuanlegit/git-identitree/internal/mapping"
+	"github.com/thuanlegit/git-identitree/internal/profile"
+	"github.com/thuanlegit/git-identitree/pkg/gidtree/gidtreetest"
+)
+
+func TestCheckPermissions(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("permission bits are not meaningful on Windows")
+	}
+
+	tests := []struct {
+		name          string
+		answer        bool
+		answerErr     error
+		wantRemaining int
+		wantOutput    string
+	}{
+		{name: "confirmed", answer: true, wantRemaining: 0, wantOutput: "Fixed:"},
+		{name: "declined", answer: false, wantRemaining: 1},
+		{name: "no terminal", answerErr: cli.ErrNoConfirmation, wantRemaining: 1, wantOutput: "gidtree doctor --fix"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := gidtreetest.NewEnv(t).
+				WithProfile(profile.Profile{Name: "work", Email: "work@example.com"}).
+				Build()
+			profilesPath := env.Path(".gidtree/profiles.yaml")
+			if err := os.Chmod(profilesPath, 0644); err != nil {
+				t.Fatalf("Chmod() error = %v", err)
+			}
+
+			var out bytes.Buffer
+			confirm := func(string, string) (bool, error) { return tt.answer, tt.answerErr }
+			remaining, err := checkPermissions(&out, confirm)
+			if err != nil {
+				t.Fatalf("checkPermissions() error = %v", err)
+			}
+			if remaining != tt.wantRemaining {
+				t.Errorf("checkPermissions() remaining = %d, want %d", remaining, tt.wantRemaining)
+			}
+			if !strings.Contains(out.String(), profilesPath) || !strings.Contains(out.String(), tt.wantOutput) {
+				t.Errorf("checkPermissions() output = %q, want the file and %q", out.String(), tt.wantOutput)
+			}
+
+			info, err := os.Stat(profilesPath)
+			if err != nil {
+				t.Fatalf("Stat() error = %v", err)
+			}
+			if fixed := info.Mode().Perm() == 0600; fixed != (tt.wantRemaining == 0) {
+				t.Errorf("profiles.yaml mode = %04o after %s", info.Mode().Perm(), tt.name)
+			}
+		})
+	}
+}
+
+func TestInitCommand_CreatesPrivateFiles(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("permission bits are not meaningful on Windows")
+	}
+	env := gidtreetest.NewEnv(t).Build()
+
+	if err := initCmd.RunE(initCmd, nil); err != nil {
+		t.Fatalf("init error = %v", err)
+	}
+	for path, want := range map[string]os.FileMode{
+		env.Path(".gidtree"):               0700,
+		env.Path(".gidtree/profiles.yaml"): 0600,
+	} {
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatalf("Stat(%s) error = %v", path, err)
+		}
+		if info.Mode().Perm() != want {
+			t.Errorf("%s mode = %04o, want %04o", path, info.Mode().Perm(), want)
+		}
+	}
+}
+
+func TestCheckMappedDirectories(t *testing.T) {
+	env := gidtreetest.NewEnv(t).
+		WithProfile(profile.Profile{Name: "work", Email: "work@example.com"}).
+		WithMapping("work", "code/work").
+		Build()
+
+	var out bytes.Buffer
+	if missing, err := checkMappedDirectories(&out); err != nil || missing != 0 {
+		t.Fatalf("checkMappedDirectories() = %d, %v; want 0", missing, err)
+	}
+
+	if err := os.RemoveAll(env.Path("code/work")); err != nil {
+		t.Fatalf("Failed to remove mapped directory: %v", err)
+	}
+	out.Reset()
+	missing, err := checkMappedDirectories(&out)
+	if err != nil || missing != 1 {
+		t.Fatalf("checkMappedDirectories() = %d, %v; want 1", missing, err)
+	}
+	if !strings.Contains(out.String(), "gidtree unmap "+env.Path("code/work")) {
+		t.Errorf("checkMappedDirectories() output = %q, want an unmap hint", out.String())
+	}
+}
+
+func TestCheckDuplicateMappings(t *testing.T) {
+	tests := []struct {
+		name          string
+		answer        bool
+		answerErr     error
+		wantRemaining int
+		wantOutput    string
+	}{
+		{name: "confirmed", answer: true, wantRemaining: 0, wantOutput: "Fixed:"},
+		{name: "declined", answer: false, wantRemaining: 1},
+		{name: "no terminal", answerErr: cli.ErrNoConfirmation, wantRemaining: 1, wantOutput: "gidtree doctor --fix"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := gidtreetest.NewEnv(t).
+				WithProfile(profile.Profile{Name: "work", Email: "work@example.com"}).
+				WithProfile(profile.Profile{Name: "personal", Email: "me@example.com"}).
+				WithMapping("work", "code/work").
+				Build()
+
+			var out bytes.Buffer
+			never := func(string, string) (bool, error) { return false, nil }
+			if duplicated, err := checkDuplicateMappings(&out, never); err != nil || duplicated != 0 {
+				t.Fatalf("checkDuplicateMappings() = %d, %v; want 0", duplicated, err)
+			}
+
+			// A hand edit maps the directory again; git uses the later block
+			mappings, err := mapping.ParseMappings()
+			if err != nil || len(mappings) != 1 {
+				t.Fatalf("ParseMappings() = %+v, %v", mappings, err)
+			}
+			block := fmt.Sprintf("\n[includeIf \"%s\"]\n    path = ~/.gitconfig-personal\n", mappings[0].RawCondition)
+			f, err := os.OpenFile(env.GitConfigPath(), os.O_APPEND|os.O_WRONLY, 0644)
+			if err != nil {
+				t.Fatalf("Failed to open git config: %v", err)
+			}
+			_, err = f.WriteString(block)
+			if closeErr := f.Close(); err != nil || closeErr != nil {
+				t.Fatalf("Failed to append to git config: %v, %v", err, closeErr)
+			}
+
+			out.Reset()
+			confirm := func(string, string) (bool, error) { return tt.answer, tt.answerErr }
+			remaining, err := checkDuplicateMappings(&out, confirm)
+			if err != nil {
+				t.Fatalf("checkDuplicateMappings() error = %v", err)
+			}
+			if remaining != tt.wantRemaining {
+				t.Errorf("checkDuplicateMappings() remaining = %d, want %d", remaining, tt.wantRemaining)
+			}
+			if !strings.Contains(out.String(), "git uses profile 'personal', overriding 'work'") || !strings.Contains(out.String(), tt.wantOutput) {
+				t.Errorf("checkDuplicateMappings() output = %q, want the duplicate and %q", out.String(), tt.wantOutput)
+			}
+
+			mappings, err = mapping.ParseMappings()
+			if err != nil {
+				t.Fatalf("ParseMappings() error = %v", err)
+			}
+			if want := tt.wantRemaining + 1; len(mappings) != want || mappings[len(mappings)-1].Profile != "personal" {
+				t.Errorf("mappings after %s = %+v, want %d ending with personal", tt.name, mappings, want)
+			}
+		})
+	}
+}
+
+func TestCheckDrift(t *testing.T) {
+	env := gidtreetest.NewEnv(t).
+		WithProfile(profile.Profile{Name: "work", Email: "work@example.com"}).
+		WithMapping("work", "code/work").
+		Build()
+
+	var out bytes.Buffer
+	if drifted, err := checkDrift(&out); err != nil || drifted != 0 {
+		t.Fatalf("checkDrift() = %d, %v; want 0", drifted, err)
+	}
+
+	if err := os.Remove(env.FragmentPath("work")); err != nil {
+		t.Fatalf("Failed to remove profile config: %v", err)
+	}
+	out.Reset()
+	drifted, err := checkDrift(&out)
+	if err != nil || drifted != 1 {
+		t.Fatalf("checkDrift() = %d, %v; want 1", drifted, err)
+	}
+	if !strings.Contains(out.String(), "is missing (profile 'work')") || !strings.Contains(out.String(), "gidtree sync") {
+		t.Errorf("checkDrift() output = %q, want the missing config and a sync hint", out.String())
+	}
+}
+
+func TestCheckDefaultIdentity(t *testing.T) {
+	gidtreetest.NewEnv(t).
+		WithProfile(profile.Profile{Name: "work", Email: "work@example.com"}).
+		WithMapping("work", "code/work").
+		Build()
+
+	var out bytes.Buffer
+	warnings, err := checkDefaultIdentity(&out)
+	if err != nil || warnings != 1 {
+		t.Fatalf("checkDefaultIdentity() = %d, %v; want 1", warnings, err)
+	}
+	if !strings.Contains(out.String(), "No default identity") {
+		t.Errorf("checkDefaultIdentity() output = %q, want a missing default warning", out.String())
+	}
+
+	if err := mapping.SetGlobalUser("Jane Doe", "jane@example.com"); err != nil {
+		t.Fatalf("SetGlobalUser() error = %v", err)
+	}
+	out.Reset()
+	if warnings, err := checkDefaultIdentity(&out); err != nil || warnings != 0 {
+		t.Fatalf("checkDefaultIdentity() = %d, %v; want 0 (output %q)", warnings, err, out.String())
+	}
+	if !strings.Contains(out.String(), "Jane Doe <jane@example.com>") {
+		t.Errorf("checkDefaultIdentity() output = %q, want the default identity", out.String())
+	}
+}
+
+func TestCheckEffectiveIdentity(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	env := gidtreetest.NewEnv(t).
+		WithProfile(profile.Profile{Name: "work", Email: "work@example.com", AuthorName: "Jane Doe"}).
+		WithMapping("work", "code/work").
+		WithGitRepo("code/work/repo").
+		Build()
+	repo := env.Path("code/work/repo")
+
+	var out bytes.Buffer
+	if n, err := checkEffectiveIdentity(&out, repo); err != nil || n != 0 {
+		t.Fatalf("checkEffectiveIdentity() = %d, %v; want 0 (output %q)", n, err, out.String())
+	}
+	if !strings.Contains(out.String(), "Jane Doe <work@example.com>") {
+		t.Errorf("checkEffectiveIdentity() output = %q, want the resolved identity", out.String())
+	}
+
+	if err := os.Remove(env.Path(".gitconfig-work")); err != nil {
+		t.Fatalf("Failed to remove profile config: %v", err)
+	}
+	out.Reset()
+	n, err := checkEffectiveIdentity(&out, repo)
+	if err != nil || n != 1 {
+		t.Fatalf("checkEffectiveIdentity() = %d, %v; want 1", n, err)
+	}
+	if !strings.Contains(out.String(), ".gitconfig-work is missing") {
+		t.Errorf("checkEffectiveIdentity() output = %q, want the missing profile config as cause", out.String())
+	}
+
+	out.Reset()
+	if n, err := checkEffectiveIdentity(&out, env.Path("code/work")); err != nil || n != 0 || out.Len() != 0 {
+		t.Errorf("checkEffectiveIdentity() outside a repository = %d, %v, %q; want nothing", n, err, out.String())
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/thuanlegit/git-identitree/internal/profile"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	envShell string
+	envUnset bool
+)
+
+var envCmd = &cobra.Command{
+	Use:   "env [profile]",
+	Short: "Print shell statements exporting a profile's identity",
+	Long:  "Print statements that export GIT_AUTHOR_*, GIT_COMMITTER_* and GIT_SSH_COMMAND for a profile, for use with eval \"$(gidtree env work)\" in scripts and CI. Supported shells are bash (also sh and zsh), fish and powershell. With --unset, statements clearing the variables are printed instead and no profile is needed.",
+	Args:  cobra.MaximumNArgs(1),
+	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) > 0 {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		manager, err := profile.NewDefaultManager()
+		if err != nil {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		profiles := manager.ListProfiles()
+		var names []string
+		for _, p := range profiles {
+			names = append(names, p.Name)
+		}
+		return names, cobra.ShellCompDirectiveNoFileComp
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if envUnset {
+			return writeEnvUnset(os.Stdout, envShell, profile.EnvNames())
+		}
+		if len(args) == 0 {
+			return fmt.Errorf("profile name is required unless --unset is given")
+		}
+
+		manager, err := profile.NewDefaultManager()
+		if err != nil {
+			return fmt.Errorf("failed to initialize profile manager: %w", err)
+		}
+
+		prof, err := manager.GetProfile(args[0])
+		if err != nil {
+			return fmt.Errorf("profile not found: %w", err)
+		}
+
+		return writeEnvExports(os.Stdout, envShell, prof.Env())
+	},
+}
+
+// writeEnvExports prints one export statement per variable in the given shell's syntax.
+func writeEnvExports(w io.Writer, shell string, vars []profile.EnvVar) error {
+	for _, v := range vars {
+		var line string
+		switch shell {
+		case "bash", "sh", "zsh":
+			line = fmt.Sprintf("export %s=%s", v.Name, quotePOSIX(v.Value))
+		case "fish":
+			line = fmt.Sprintf("set -gx %s %s", v.Name, quoteFish(v.Value))
+		case "powershell", "pwsh":
+			line = fmt.Sprintf("$env:%s = %s", v.Name, quotePowerShell(v.Value))
+		default:
+			return fmt.Errorf("unsupported shell '%s' (use bash, fish or powershell)", shell)
+		}
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeEnvUnset prints one statement per variable name clearing it in the given shell's syntax.
+func writeEnvUnset(w io.Writer, shell string, names []string) error {
+	for _, name := range names {
+		var line string
+		switch shell {
+		case "bash", "sh", "zsh":
+			line = fmt.Sprintf("unset %s", name)
+		case "fish":
+			line = fmt.Sprintf("set -e %s", name)
+		case "powershell", "pwsh":
+			line = fmt.Sprintf("Remove-Item Env:%s -ErrorAction SilentlyContinue", name)
+		default:
+			return fmt.Errorf("unsupported shell '%s' (use bash, fish or powershell)", shell)
+		}
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// quotePOSIX wraps s in single quotes, closing and reopening them around embedded quotes.
+func quotePOSIX(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// quoteFish wraps s in single quotes; fish only treats \\ and \' as escapes inside them.
+func quoteFish(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	return "'" + strings.ReplaceAll(s, "'", `\'`) + "'"
+}
+
+// quotePowerShell wraps s in single quotes, doubling embedded quotes.
+func quotePowerShell(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+func init() {
+	envCmd.Flags().StringVar(&envShell, "shell", "bash", "Shell syntax to print: bash, fish or powershell")
+	envCmd.Flags().BoolVar(&envUnset, "unset", false, "Print statements that unset the identity variables")
+}
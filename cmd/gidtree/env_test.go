@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bytes"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/thuanlegit/git-identitree/internal/profile"
+	"github.com/thuanlegit/git-identitree/pkg/gidtree/gidtreetest"
+)
+
+func TestWriteEnvExports(t *testing.T) {
+	vars := []profile.EnvVar{
+		{Name: "GIT_AUTHOR_NAME", Value: "Jane O'Neil"},
+		{Name: "GIT_SSH_COMMAND", Value: `ssh -i C:\keys\id -F /dev/null`},
+	}
+
+	tests := []struct {
+		shell string
+		want  string
+	}{
+		{"bash", "export GIT_AUTHOR_NAME='Jane O'\\''Neil'\nexport GIT_SSH_COMMAND='ssh -i C:\\keys\\id -F /dev/null'\n"},
+		{"zsh", "export GIT_AUTHOR_NAME='Jane O'\\''Neil'\nexport GIT_SSH_COMMAND='ssh -i C:\\keys\\id -F /dev/null'\n"},
+		{"fish", "set -gx GIT_AUTHOR_NAME 'Jane O\\'Neil'\nset -gx GIT_SSH_COMMAND 'ssh -i C:\\\\keys\\\\id -F /dev/null'\n"},
+		{"powershell", "$env:GIT_AUTHOR_NAME = 'Jane O''Neil'\n$env:GIT_SSH_COMMAND = 'ssh -i C:\\keys\\id -F /dev/null'\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.shell, func(t *testing.T) {
+			var out bytes.Buffer
+			if err := writeEnvExports(&out, tt.shell, vars); err != nil {
+				t.Fatalf("writeEnvExports() error = %v", err)
+			}
+			if out.String() != tt.want {
+				t.Errorf("writeEnvExports() =\n%s\nwant\n%s", out.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteEnvUnset(t *testing.T) {
+	names := []string{"GIT_AUTHOR_NAME"}
+
+	tests := map[string]string{
+		"bash":       "unset GIT_AUTHOR_NAME\n",
+		"fish":       "set -e GIT_AUTHOR_NAME\n",
+		"powershell": "Remove-Item Env:GIT_AUTHOR_NAME -ErrorAction SilentlyContinue\n",
+	}
+	for shell, want := range tests {
+		var out bytes.Buffer
+		if err := writeEnvUnset(&out, shell, names); err != nil {
+			t.Fatalf("writeEnvUnset(%s) error = %v", shell, err)
+		}
+		if out.String() != want {
+			t.Errorf("writeEnvUnset(%s) = %q, want %q", shell, out.String(), want)
+		}
+	}
+}
+
+func TestWriteEnv_UnsupportedShell(t *testing.T) {
+	var out bytes.Buffer
+	if err := writeEnvExports(&out, "tcsh", []profile.EnvVar{{Name: "A", Value: "b"}}); err == nil {
+		t.Error("writeEnvExports() should reject unsupported shells")
+	}
+	if err := writeEnvUnset(&out, "tcsh", []string{"A"}); err == nil {
+		t.Error("writeEnvUnset() should reject unsupported shells")
+	}
+}
+
+func TestWriteEnvExports_BashEval(t *testing.T) {
+	if _, err := exec.LookPath("bash"); err != nil {
+		t.Skip("bash not available")
+	}
+
+	value := `Jane "JD" O'Neil $HOME \n`
+	var out bytes.Buffer
+	if err := writeEnvExports(&out, "bash", []profile.EnvVar{{Name: "GIT_AUTHOR_NAME", Value: value}}); err != nil {
+		t.Fatalf("writeEnvExports() error = %v", err)
+	}
+
+	script := out.String() + `printf '%s' "$GIT_AUTHOR_NAME"`
+	got, err := exec.Command("bash", "-c", script).Output()
+	if err != nil {
+		t.Fatalf("bash failed: %v", err)
+	}
+	if string(got) != value {
+		t.Errorf("eval round trip = %q, want %q", got, value)
+	}
+}
+
+func TestEnvCommand(t *testing.T) {
+	gidtreetest.NewEnv(t).
+		WithProfile(profile.Profile{Name: "work", Email: "work@example.com"}).
+		Build()
+
+	if err := envCmd.RunE(envCmd, []string{}); err == nil {
+		t.Error("env without a profile should fail unless --unset is given")
+	}
+	if err := envCmd.RunE(envCmd, []string{"missing"}); err == nil {
+		t.Error("env with an unknown profile should fail")
+	}
+
+	envUnset = true
+	defer func() { envUnset = false }()
+	if err := envCmd.RunE(envCmd, []string{}); err != nil {
+		t.Errorf("env --unset error = %v", err)
+	}
+}
+
+func TestEnvCommandRegistered(t *testing.T) {
+	for _, c := range rootCmd.Commands() {
+		if strings.HasPrefix(c.Use, "env ") {
+			return
+		}
+	}
+	t.Error("env command should be registered")
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"github.com/thuanlegit/git-identitree/internal/profile"
+
+	"github.com/spf13/cobra"
+)
+
+var execShell bool
+
+var execCmd = &cobra.Command{
+	Use:   "exec [profile] -- [command...]",
+	Short: "Run a command with a profile's identity",
+	Long:  "Run a command with GIT_AUTHOR_*, GIT_COMMITTER_* and GIT_SSH_COMMAND set from a profile, without changing any mapping. Standard streams are passed through and gidtree exits with the command's exit status. With --shell, an interactive subshell is started instead.",
+	Args:  cobra.MinimumNArgs(1),
+	// The child's own output and exit status are what matters here
+	SilenceErrors: true,
+	SilenceUsage:  true,
+	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) > 0 {
+			return nil, cobra.ShellCompDirectiveDefault
+		}
+		manager, err := profile.NewDefaultManager()
+		if err != nil {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		profiles := manager.ListProfiles()
+		var names []string
+		for _, p := range profiles {
+			names = append(names, p.Name)
+		}
+		return names, cobra.ShellCompDirectiveNoFileComp
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		profileName := args[0]
+		command := args[1:]
+		if len(command) == 0 && !execShell {
+			return fmt.Errorf("no command given: use 'gidtree exec %s -- <command...>' or --shell", profileName)
+		}
+
+		manager, err := profile.NewDefaultManager()
+		if err != nil {
+			return fmt.Errorf("failed to initialize profile manager: %w", err)
+		}
+
+		prof, err := manager.GetProfile(profileName)
+		if err != nil {
+			return fmt.Errorf("profile not found: %w", err)
+		}
+
+		env := mergeEnv(os.Environ(), prof.Env())
+		if execShell {
+			return runChild(userShell(), nil, env)
+		}
+		return runChild(command[0], command[1:], env)
+	},
+}
+
+// exitCodeError carries a child process's exit status up to main,
+// so gidtree exits with the same code as the command it ran.
+type exitCodeError struct {
+	code int
+}
+
+func (e *exitCodeError) Error() string {
+	return fmt.Sprintf("command exited with status %d", e.code)
+}
+
+// mergeEnv returns base with vars set, replacing any existing values.
+func mergeEnv(base []string, vars []profile.EnvVar) []string {
+	override := make(map[string]bool, len(vars))
+	for _, v := range vars {
+		override[v.Name] = true
+	}
+
+	env := make([]string, 0, len(base)+len(vars))
+	for _, entry := range base {
+		name, _, _ := strings.Cut(entry, "=")
+		if !override[name] {
+			env = append(env, entry)
+		}
+	}
+	for _, v := range vars {
+		env = append(env, v.String())
+	}
+	return env
+}
+
+// lookupEnv returns the value of name in an environment list.
+func lookupEnv(env []string, name string) string {
+	for _, entry := range env {
+		if key, value, ok := strings.Cut(entry, "="); ok && key == name {
+			return value
+		}
+	}
+	return ""
+}
+
+// runChild runs a command with the given environment, streaming stdin, stdout and stderr.
+// A non-zero exit status is returned as an *exitCodeError.
+func runChild(name string, args []string, env []string) error {
+	cmd := exec.Command(name, args...)
+	cmd.Env = env
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return &exitCodeError{code: exitErr.ExitCode()}
+		}
+		return fmt.Errorf("failed to run %s: %w", name, err)
+	}
+	return nil
+}
+
+// userShell returns the user's interactive shell.
+func userShell() string {
+	if shell := os.Getenv("SHELL"); shell != "" {
+		return shell
+	}
+	if runtime.GOOS == "windows" {
+		if comspec := os.Getenv("COMSPEC"); comspec != "" {
+			return comspec
+		}
+		return "cmd.exe"
+	}
+	return "/bin/sh"
+}
+
+func init() {
+	execCmd.Flags().BoolVar(&execShell, "shell", false, "Start an interactive subshell with the profile's identity")
+	execCmd.Flags().SetInterspersed(false)
+}
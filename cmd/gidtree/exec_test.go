@@ -0,0 +1,130 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+
+	"github.com/thuanlegit/git-identitree/internal/profile"
+)
+
+func TestMergeEnv(t *testing.T) {
+	base := []string{"PATH=/bin", "GIT_AUTHOR_NAME=Old", "HOME=/home/me"}
+	vars := []profile.EnvVar{
+		{Name: "GIT_AUTHOR_NAME", Value: "Jane Doe"},
+		{Name: "GIT_AUTHOR_EMAIL", Value: "jane@example.com"},
+	}
+
+	got := mergeEnv(base, vars)
+	want := []string{"PATH=/bin", "HOME=/home/me", "GIT_AUTHOR_NAME=Jane Doe", "GIT_AUTHOR_EMAIL=jane@example.com"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("mergeEnv() = %v, want %v", got, want)
+	}
+}
+
+func TestLookupEnv(t *testing.T) {
+	env := []string{"A=1", "B=two=2", "C"}
+	if got := lookupEnv(env, "B"); got != "two=2" {
+		t.Errorf("lookupEnv(B) = %q, want two=2", got)
+	}
+	if got := lookupEnv(env, "C"); got != "" {
+		t.Errorf("lookupEnv(C) = %q, want empty", got)
+	}
+	if got := lookupEnv(env, "D"); got != "" {
+		t.Errorf("lookupEnv(D) = %q, want empty", got)
+	}
+}
+
+func TestRunChild_ExitCode(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not available")
+	}
+
+	if err := runChild("sh", []string{"-c", "exit 0"}, nil); err != nil {
+		t.Errorf("runChild() error = %v, want nil", err)
+	}
+
+	err := runChild("sh", []string{"-c", "exit 3"}, nil)
+	var exitErr *exitCodeError
+	if !errors.As(err, &exitErr) || exitErr.code != 3 {
+		t.Errorf("runChild() error = %v, want exit code 3", err)
+	}
+
+	err = runChild("gidtree-definitely-missing-binary", nil, nil)
+	if err == nil || errors.As(err, &exitErr) {
+		t.Errorf("runChild() error = %v, want start failure", err)
+	}
+}
+
+func TestExecCommand(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	tmpDir, cleanup := setupCLITestEnv(t)
+	defer cleanup()
+
+	manager, err := profile.NewDefaultManager()
+	if err != nil {
+		t.Fatalf("NewDefaultManager() error = %v", err)
+	}
+	prof := profile.Profile{Name: "work", Email: "work@example.com", AuthorName: "Jane Doe", SSHKeyPath: filepath.Join(tmpDir, "id_work")}
+	if err := os.WriteFile(prof.SSHKeyPath, []byte("key"), 0600); err != nil {
+		t.Fatalf("Failed to write key: %v", err)
+	}
+	if err := manager.AddProfile(prof); err != nil {
+		t.Fatalf("AddProfile() error = %v", err)
+	}
+
+	out := filepath.Join(tmpDir, "env.txt")
+	script := `printf '%s|%s|%s|%s|%s' "$GIT_AUTHOR_NAME" "$GIT_AUTHOR_EMAIL" "$GIT_COMMITTER_NAME" "$GIT_COMMITTER_EMAIL" "$GIT_SSH_COMMAND" > "$0"`
+	if err := execCmd.RunE(execCmd, []string{"work", "sh", "-c", script, out}); err != nil {
+		t.Fatalf("execCmd.RunE() error = %v", err)
+	}
+
+	content, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatalf("Failed to read output: %v", err)
+	}
+	want := "Jane Doe|work@example.com|Jane Doe|work@example.com|ssh -i '" + prof.SSHKeyPath + "' -o IdentitiesOnly=yes"
+	if string(content) != want {
+		t.Errorf("child environment = %q, want %q", content, want)
+	}
+
+	err = execCmd.RunE(execCmd, []string{"work", "sh", "-c", "exit 7"})
+	var exitErr *exitCodeError
+	if !errors.As(err, &exitErr) || exitErr.code != 7 {
+		t.Errorf("execCmd.RunE() error = %v, want exit code 7", err)
+	}
+
+	// A separate committer only changes GIT_COMMITTER_*
+	prof.CommitterName = "Release Bot"
+	prof.CommitterEmail = "bot@example.com"
+	if err := manager.UpdateProfile("work", prof); err != nil {
+		t.Fatalf("UpdateProfile() error = %v", err)
+	}
+	if err := execCmd.RunE(execCmd, []string{"work", "sh", "-c", script, out}); err != nil {
+		t.Fatalf("execCmd.RunE() error = %v", err)
+	}
+	content, err = os.ReadFile(out)
+	if err != nil {
+		t.Fatalf("Failed to read output: %v", err)
+	}
+	want = "Jane Doe|work@example.com|Release Bot|bot@example.com|ssh -i '" + prof.SSHKeyPath + "' -o IdentitiesOnly=yes"
+	if string(content) != want {
+		t.Errorf("child environment with a committer = %q, want %q", content, want)
+	}
+
+	if err := execCmd.RunE(execCmd, []string{"missing", "true"}); err == nil {
+		t.Error("execCmd.RunE() should fail for non-existent profile")
+	}
+	if err := execCmd.RunE(execCmd, []string{"work"}); err == nil {
+		t.Error("execCmd.RunE() should fail without a command or --shell")
+	}
+}
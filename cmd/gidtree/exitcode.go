@@ -0,0 +1,51 @@
+package main
+
+import (
+	"errors"
+
+	"github.com/thuanlegit/git-identitree/internal/mapping"
+	"github.com/thuanlegit/git-identitree/internal/profile"
+)
+
+// Exit codes for failures scripts may want to tell apart. Anything else exits 1.
+// Keep the table in the README's "Exit Codes" section in sync.
+const (
+	exitFailure                = 1
+	exitProfileNotFound        = 2
+	exitProfileExists          = 3
+	exitDirectoryAlreadyMapped = 4
+	exitMappingNotFound        = 5
+	exitSSHKeyMissing          = 6
+)
+
+// exitCodes maps the errors internal packages return to their exit codes.
+var exitCodes = []struct {
+	err  error
+	code int
+}{
+	{profile.ErrProfileNotFound, exitProfileNotFound},
+	{profile.ErrProfileExists, exitProfileExists},
+	{mapping.ErrDirectoryAlreadyMapped, exitDirectoryAlreadyMapped},
+	{mapping.ErrMappingNotFound, exitMappingNotFound},
+	{profile.ErrSSHKeyMissing, exitSSHKeyMissing},
+}
+
+// exitStatus returns the process exit status for an error returned by a command.
+func exitStatus(err error) int {
+	if err == nil {
+		return 0
+	}
+
+	// Statuses chosen by commands (exec/with children, hooks, resolve)
+	var exitErr *exitCodeError
+	if errors.As(err, &exitErr) {
+		return exitErr.code
+	}
+
+	for _, e := range exitCodes {
+		if errors.Is(err, e.err) {
+			return e.code
+		}
+	}
+	return exitFailure
+}
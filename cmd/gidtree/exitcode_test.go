@@ -0,0 +1,234 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/thuanlegit/git-identitree/internal/profile"
+	"github.com/thuanlegit/git-identitree/pkg/gidtree/gidtreetest"
+
+	"github.com/spf13/pflag"
+)
+
+// runCLI runs gidtree with args against the current test environment and
+// returns the exit status main would use.
+func runCLI(t *testing.T, args ...string) int {
+	t.Helper()
+	rootCmd.SetArgs(args)
+	rootCmd.SetOut(io.Discard)
+	rootCmd.SetErr(io.Discard)
+	defer func() {
+		rootCmd.SetArgs(nil)
+		rootCmd.SetOut(nil)
+		rootCmd.SetErr(nil)
+	}()
+	return exitStatus(rootCmd.Execute())
+}
+
+// runCLIOutput runs gidtree with args like main does and returns what it
+// printed on stdout and stderr and its exit status. Flags set by args are
+// reset when the test ends.
+func runCLIOutput(t *testing.T, args ...string) (stdout, stderr string, code int) {
+	t.Helper()
+	var out, errOut bytes.Buffer
+	rootCmd.SetArgs(args)
+	rootCmd.SetOut(&out)
+	rootCmd.SetErr(&errOut)
+	defer func() {
+		rootCmd.SetArgs(nil)
+		rootCmd.SetOut(nil)
+		rootCmd.SetErr(nil)
+	}()
+	code = execute(&errOut)
+
+	if cmd, _, err := rootCmd.Find(args); err == nil {
+		t.Cleanup(func() {
+			cmd.Flags().VisitAll(func(f *pflag.Flag) {
+				_ = f.Value.Set(f.DefValue)
+				f.Changed = false
+			})
+		})
+	}
+	return out.String(), errOut.String(), code
+}
+
+func TestExitStatus(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"success", nil, 0},
+		{"generic", errors.New("gitconfig unwritable"), exitFailure},
+		{"profile not found", fmt.Errorf("wrapped: %w", profile.ErrProfileNotFound), exitProfileNotFound},
+		{"chosen by command", &exitCodeError{code: 42}, 42},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := exitStatus(tt.err); got != tt.want {
+				t.Errorf("exitStatus(%v) = %d, want %d", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExitStatus_ErrorClasses(t *testing.T) {
+	env := gidtreetest.NewEnv(t).
+		WithProfile(profile.Profile{Name: "work", Email: "work@example.com"}).
+		WithMapping("work", "code/work").
+		Build()
+
+	unmapped := env.Path("code/other")
+	if err := os.MkdirAll(unmapped, 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+
+	if got := runCLI(t, "profile", "delete", "missing"); got != exitProfileNotFound {
+		t.Errorf("profile delete of a missing profile exit status = %d, want %d", got, exitProfileNotFound)
+	}
+	if got := runCLI(t, "map", "work", env.Path("code/work")); got != exitDirectoryAlreadyMapped {
+		t.Errorf("map of a mapped directory exit status = %d, want %d", got, exitDirectoryAlreadyMapped)
+	}
+	if got := runCLI(t, "unmap", unmapped); got != exitMappingNotFound {
+		t.Errorf("unmap of an unmapped directory exit status = %d, want %d", got, exitMappingNotFound)
+	}
+	if got := runCLI(t, "resolve", unmapped); got != exitMappingNotFound {
+		t.Errorf("resolve of an unmapped directory exit status = %d, want %d", got, exitMappingNotFound)
+	}
+
+	t.Chdir(unmapped)
+	if got := runCLI(t, "activate"); got != exitMappingNotFound {
+		t.Errorf("activate in an unmapped directory exit status = %d, want %d", got, exitMappingNotFound)
+	}
+
+	manager, err := profile.NewDefaultManager()
+	if err != nil {
+		t.Fatalf("NewDefaultManager() error = %v", err)
+	}
+	err = manager.AddProfile(profile.Profile{Name: "work", Email: "other@example.com"})
+	if got := exitStatus(err); got != exitProfileExists {
+		t.Errorf("adding a duplicate profile exit status = %d, want %d", got, exitProfileExists)
+	}
+}
+
+func TestExitStatus_SSHKeyMissing(t *testing.T) {
+	home := t.TempDir()
+	keyPath := home + "/id_work"
+	if err := os.WriteFile(keyPath, []byte("key"), 0600); err != nil {
+		t.Fatalf("Failed to write key: %v", err)
+	}
+	gidtreetest.NewEnv(t).
+		WithProfile(profile.Profile{Name: "work", Email: "work@example.com", SSHKeyPath: keyPath}).
+		Build()
+
+	// The key disappears after the profile was created
+	if err := os.Remove(keyPath); err != nil {
+		t.Fatalf("Failed to remove key: %v", err)
+	}
+
+	if got := runCLI(t, "ssh", "load", "work"); got != exitSSHKeyMissing {
+		t.Errorf("ssh load with a missing key exit status = %d, want %d", got, exitSSHKeyMissing)
+	}
+}
+
+func TestActivate_OutputMatrix(t *testing.T) {
+	keyPath := filepath.Join(t.TempDir(), "id_broken")
+	if err := os.WriteFile(keyPath, []byte("key"), 0600); err != nil {
+		t.Fatalf("Failed to write key: %v", err)
+	}
+	env := gidtreetest.NewEnv(t).
+		WithProfile(profile.Profile{Name: "work", Email: "work@example.com"}).
+		WithProfile(profile.Profile{Name: "broken", Email: "broken@example.com", SSHKeyPath: keyPath}).
+		WithMapping("work", "work").
+		WithMapping("broken", "broken").
+		Build()
+	if err := os.MkdirAll(env.Path("elsewhere"), 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	// The key disappears after the profile was created, so loading it fails
+	if err := os.Remove(keyPath); err != nil {
+		t.Fatalf("Failed to remove key: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		dir        string
+		args       []string
+		wantStdout string // substring; "" means no output at all
+		wantStderr string
+		wantCode   int
+	}{
+		{name: "mapped", dir: "work", wantStdout: "Active profile: work"},
+		{name: "mapped quiet", dir: "work", args: []string{"--quiet"}},
+		{name: "mapped require mapping", dir: "work", args: []string{"--quiet", "--require-mapping"}},
+		{name: "unmapped", dir: "elsewhere", wantStdout: "No profile mapped", wantCode: exitMappingNotFound},
+		{name: "unmapped quiet", dir: "elsewhere", args: []string{"--quiet"}},
+		{name: "unmapped quiet require mapping", dir: "elsewhere", args: []string{"--quiet", "--require-mapping"}, wantCode: exitMappingNotFound},
+		{name: "unmapped porcelain require mapping", dir: "elsewhere", args: []string{"--porcelain", "--require-mapping"}, wantCode: exitMappingNotFound},
+		{name: "key failure", dir: "broken", wantStdout: "Active profile: broken", wantStderr: "failed to load SSH key", wantCode: exitSSHKeyMissing},
+		{name: "key failure quiet", dir: "broken", args: []string{"--quiet"}, wantStderr: "failed to load SSH key", wantCode: exitSSHKeyMissing},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Chdir(env.Path(tt.dir))
+			stdout, stderr, code := runCLIOutput(t, append([]string{"activate"}, tt.args...)...)
+			if code != tt.wantCode {
+				t.Errorf("exit status = %d, want %d (stderr %q)", code, tt.wantCode, stderr)
+			}
+			if tt.wantStdout == "" && stdout != "" || !strings.Contains(stdout, tt.wantStdout) {
+				t.Errorf("stdout = %q, want %q", stdout, tt.wantStdout)
+			}
+			if tt.wantStderr == "" && stderr != "" || !strings.Contains(stderr, tt.wantStderr) {
+				t.Errorf("stderr = %q, want %q", stderr, tt.wantStderr)
+			}
+		})
+	}
+}
+
+func TestActivate_Path(t *testing.T) {
+	env := gidtreetest.NewEnv(t).
+		WithProfile(profile.Profile{Name: "work", Email: "work@example.com"}).
+		WithGitRepo("work/api").
+		WithMapping("work", "work").
+		Build()
+	if err := os.WriteFile(env.Path("work/api/main.go"), []byte("package main\n"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	if err := os.MkdirAll(env.Path("elsewhere"), 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	t.Chdir(env.Path("elsewhere"))
+
+	tests := []struct {
+		name     string
+		args     []string
+		want     string
+		wantCode int
+	}{
+		{name: "no path uses the current directory", want: "", wantCode: exitMappingNotFound},
+		{name: "directory", args: []string{env.Path("work/api")}, want: "profile=work"},
+		{name: "file", args: []string{env.Path("work/api/main.go")}, want: "profile=work"},
+		{name: "relative", args: []string{"../work"}, want: "profile=work"},
+		{name: "home", args: []string{"~/work/api"}, want: "profile=work"},
+		{name: "missing", args: []string{env.Path("work/missing")}, wantCode: exitFailure},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := append([]string{"activate", "--porcelain", "--require-mapping"}, tt.args...)
+			stdout, stderr, code := runCLIOutput(t, args...)
+			if code != tt.wantCode {
+				t.Errorf("exit status = %d, want %d (stderr %q)", code, tt.wantCode, stderr)
+			}
+			if !strings.HasPrefix(stdout, tt.want) || (tt.want == "" && stdout != "") {
+				t.Errorf("stdout = %q, want %q", stdout, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+
+	"github.com/thuanlegit/git-identitree/internal/identity"
+	"github.com/thuanlegit/git-identitree/internal/utils"
+
+	"github.com/spf13/cobra"
+)
+
+// explainIdentityKeys are the keys explain-identity traces.
+var explainIdentityKeys = []string{"user.email", "user.name"}
+
+var explainIdentityCmd = &cobra.Command{
+	Use:   "explain-identity [path]",
+	Short: "Show which git config files set the identity of a directory",
+	Long: `Trace user.email and user.name through every config file git reads in a
+directory (default: the current directory): system, global, files included
+through includeIf, the repository's own config and the command line, in the
+order git reads them. The last value of each key is the one git uses and is
+marked with ✓. Values gidtree wrote are annotated: its ~/.gitconfig-<profile>
+files, the managed include file and the entries of a pinned profile.`,
+	Args: cobra.MaximumNArgs(1),
+	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return nil, cobra.ShellCompDirectiveFilterDirs
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dir := "."
+		if len(args) == 1 {
+			dir = args[0]
+		}
+		dir, err := filepath.Abs(dir)
+		if err != nil {
+			return fmt.Errorf("failed to resolve path: %w", err)
+		}
+		origins, err := identity.ExplainConfig(dir, explainIdentityKeys...)
+		if err != nil {
+			return err
+		}
+		writeConfigOrigins(cmd.OutOrStdout(), dir, origins)
+		return nil
+	},
+}
+
+// writeConfigOrigins prints origins grouped by key, marking the value git uses.
+func writeConfigOrigins(w io.Writer, dir string, origins []identity.ConfigOrigin) {
+	_, _ = fmt.Fprintf(w, "Identity in %s\n", utils.AbbreviateHome(dir))
+	for _, key := range explainIdentityKeys {
+		_, _ = fmt.Fprintf(w, "\n%s\n", key)
+		var values []identity.ConfigOrigin
+		for _, o := range origins {
+			if o.Key == key {
+				values = append(values, o)
+			}
+		}
+		if len(values) == 0 {
+			_, _ = fmt.Fprintln(w, "  not set")
+			continue
+		}
+		for i, o := range values {
+			mark := " "
+			if i == len(values)-1 {
+				mark = "✓"
+			}
+			source := o.Origin
+			if o.Path != "" {
+				source = utils.AbbreviateHome(o.Path)
+			}
+			line := fmt.Sprintf("  %s %-8s %s: %s", mark, o.Scope, source, o.Value)
+			if o.Managed != "" {
+				line += fmt.Sprintf(" (gidtree: %s)", o.Managed)
+			}
+			_, _ = fmt.Fprintln(w, line)
+		}
+	}
+}
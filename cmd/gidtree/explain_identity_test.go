@@ -0,0 +1,54 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/thuanlegit/git-identitree/internal/identity"
+	"github.com/thuanlegit/git-identitree/internal/profile"
+	"github.com/thuanlegit/git-identitree/pkg/gidtree/gidtreetest"
+)
+
+func TestWriteConfigOrigins(t *testing.T) {
+	var out bytes.Buffer
+	writeConfigOrigins(&out, "/srv/api", []identity.ConfigOrigin{
+		{Key: "user.email", Scope: "global", Origin: "file", Path: "/etc/home/.gitconfig", Value: "me@example.com"},
+		{Key: "user.email", Scope: "local", Origin: "file", Path: "/srv/api/.git/config", Value: "me@client.example", Managed: "pinned profile 'client'"},
+	})
+	want := `Identity in /srv/api
+
+user.email
+    global   /etc/home/.gitconfig: me@example.com
+  ✓ local    /srv/api/.git/config: me@client.example (gidtree: pinned profile 'client')
+
+user.name
+  not set
+`
+	if out.String() != want {
+		t.Errorf("output =\n%s\nwant\n%s", out.String(), want)
+	}
+}
+
+func TestExplainIdentityCommand(t *testing.T) {
+	env := gidtreetest.NewEnv(t).
+		WithProfile(profile.Profile{Name: "work", Email: "work@example.com", AuthorName: "Jane Doe"}).
+		WithGitRepo("work/api").
+		WithMapping("work", "work").
+		Build()
+
+	var out bytes.Buffer
+	explainIdentityCmd.SetOut(&out)
+	t.Cleanup(func() { explainIdentityCmd.SetOut(nil) })
+	if err := explainIdentityCmd.RunE(explainIdentityCmd, []string{env.Path("work/api")}); err != nil {
+		t.Fatalf("explain-identity error = %v", err)
+	}
+	for _, want := range []string{
+		"✓ global   ~/.gitconfig-work: work@example.com (gidtree: profile 'work')",
+		"✓ global   ~/.gitconfig-work: Jane Doe (gidtree: profile 'work')",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output missing %q:\n%s", want, out.String())
+		}
+	}
+}
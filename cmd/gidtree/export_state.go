@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/thuanlegit/git-identitree/internal/manifest"
+
+	"github.com/spf13/cobra"
+)
+
+var exportStateOut string
+
+var exportStateCmd = &cobra.Command{
+	Use:   "export-state",
+	Short: "Write the profiles and mappings of this machine as a manifest",
+	Long: `Write every profile and its directory mappings in the manifest format that
+'gidtree apply' reads. Paths under the home directory are written with ~, so
+the manifest can be applied on a machine with another user name.
+
+A profile that is mapped but missing from profiles.yaml is written as a stub
+with only its name and a warning comment; add its email before applying.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		out := cmd.OutOrStdout()
+		if exportStateOut != "" && exportStateOut != "-" {
+			f, err := os.Create(exportStateOut)
+			if err != nil {
+				return fmt.Errorf("failed to create manifest file: %w", err)
+			}
+			defer f.Close()
+			out = f
+		}
+		return exportState(out, cmd.ErrOrStderr())
+	},
+}
+
+// exportState writes the manifest of this machine to w and warns on errW
+// about stub profiles.
+func exportState(w, errW io.Writer) error {
+	m, stubs, err := manifest.Export()
+	if err != nil {
+		return err
+	}
+	data, err := manifest.Marshal(m, stubs)
+	if err != nil {
+		return fmt.Errorf("failed to encode manifest: %w", err)
+	}
+	if _, err := w.Write(data); err != nil {
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+	for _, name := range stubs {
+		_, _ = fmt.Fprintf(errW, "⚠ Profile '%s' is mapped but missing from profiles.yaml; exported as a stub without an email\n", name)
+	}
+	return nil
+}
+
+func init() {
+	exportStateCmd.Flags().StringVarP(&exportStateOut, "out", "o", "", "Write the manifest to a file instead of stdout")
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/thuanlegit/git-identitree/internal/profile"
+	"github.com/thuanlegit/git-identitree/pkg/gidtree/gidtreetest"
+)
+
+func TestExportStateCommand(t *testing.T) {
+	gidtreetest.NewEnv(t).
+		WithProfile(profile.Profile{Name: "work", Email: "me@work.example"}).
+		WithProfile(profile.Profile{Name: "gone", Email: "gone@example.com"}).
+		WithMapping("work", "code/work").
+		WithMapping("gone", "code/old").
+		Build()
+	if err := profile.SaveProfiles([]profile.Profile{{Name: "work", Email: "me@work.example"}}); err != nil {
+		t.Fatalf("SaveProfiles() error = %v", err)
+	}
+
+	out := filepath.Join(t.TempDir(), "state.yaml")
+	setFlag(t, exportStateCmd, "out", out)
+	var errOut bytes.Buffer
+	exportStateCmd.SetErr(&errOut)
+	t.Cleanup(func() { exportStateCmd.SetErr(nil) })
+
+	if err := exportStateCmd.RunE(exportStateCmd, nil); err != nil {
+		t.Fatalf("export-state error = %v", err)
+	}
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatalf("Failed to read manifest: %v", err)
+	}
+	if !strings.Contains(string(data), "- ~/code/work") || !strings.Contains(string(data), "name: gone") {
+		t.Errorf("manifest = %s", data)
+	}
+	if !strings.Contains(errOut.String(), "Profile 'gone' is mapped but missing") {
+		t.Errorf("stderr = %q, want a stub warning", errOut.String())
+	}
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"text/template"
+
+	"github.com/thuanlegit/git-identitree/internal/utils"
+)
+
+// formatHelpValue is the --format value that lists the template fields.
+const formatHelpValue = "help"
+
+// formatFuncs are the helpers --format templates can call besides the
+// text/template built-ins.
+var formatFuncs = template.FuncMap{
+	// tilde abbreviates the home directory in a path to ~
+	"tilde": utils.AbbreviateHome,
+	// join concatenates a list: {{join .RemotePatterns ", "}}
+	"join": strings.Join,
+}
+
+// parseFormat parses a --format template for data of the same type as
+// sample. Commands call it before reading or changing anything, so a typo
+// fails before any side effect: besides syntax errors, fields that sample's
+// type does not have are reported here rather than halfway through the output.
+func parseFormat(format string, sample any) (*template.Template, error) {
+	tmpl, err := template.New("format").Funcs(formatFuncs).Parse(format)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --format template: %w", err)
+	}
+	// Other execution errors may depend on the data, such as an index out of
+	// range, so only unknown fields are caught up front
+	probe := populated(reflect.TypeOf(sample), 0)
+	if err := tmpl.Execute(io.Discard, probe.Interface()); err != nil && strings.Contains(err.Error(), "can't evaluate field") {
+		return nil, fmt.Errorf("invalid --format template: %w (see --format help)", err)
+	}
+	return tmpl, nil
+}
+
+// populated returns a zero value of t whose struct pointers, at any depth, are
+// set, so a template can walk through them.
+func populated(t reflect.Type, depth int) reflect.Value {
+	v := reflect.New(t).Elem()
+	if depth > 3 {
+		return v
+	}
+	switch t.Kind() {
+	case reflect.Pointer:
+		if derefKind(t) == reflect.Struct {
+			p := reflect.New(t.Elem())
+			p.Elem().Set(populated(t.Elem(), depth+1))
+			v.Set(p)
+		}
+	case reflect.Struct:
+		for i := range t.NumField() {
+			if t.Field(i).IsExported() {
+				v.Field(i).Set(populated(t.Field(i).Type, depth+1))
+			}
+		}
+	}
+	return v
+}
+
+// writeFormatted executes tmpl against data and ends the output with a newline.
+func writeFormatted(w io.Writer, tmpl *template.Template, data any) error {
+	var b strings.Builder
+	if err := tmpl.Execute(&b, data); err != nil {
+		return fmt.Errorf("failed to execute --format template: %w", err)
+	}
+	_, _ = fmt.Fprintln(w, b.String())
+	return nil
+}
+
+// writeFormatHelp lists the fields a --format template can use on data, with
+// their types, followed by the helper functions.
+func writeFormatHelp(w io.Writer, data any) {
+	t := reflect.TypeOf(data)
+	_, _ = fmt.Fprintf(w, "Fields of %s:\n", typeName(t))
+	writeFields(w, t, "", 0)
+	_, _ = fmt.Fprintln(w, "\nFunctions:")
+	_, _ = fmt.Fprintln(w, "  tilde PATH        abbreviate the home directory to ~")
+	_, _ = fmt.Fprintln(w, "  join LIST SEP     join a list of strings with SEP")
+	_, _ = fmt.Fprintln(w, "  and the text/template built-ins, e.g. {{if .Field}}…{{end}}, {{range .List}}…{{end}}, printf")
+}
+
+// writeFields prints the exported fields of t, descending into nested structs
+// and the elements of struct slices.
+func writeFields(w io.Writer, t reflect.Type, prefix string, depth int) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct || depth > 3 {
+		return
+	}
+	for i := range t.NumField() {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		path := prefix + "." + field.Name
+		_, _ = fmt.Fprintf(w, "  %-36s %s\n", path, typeName(field.Type))
+
+		elem := field.Type
+		for elem.Kind() == reflect.Pointer {
+			elem = elem.Elem()
+		}
+		switch {
+		case elem.Kind() == reflect.Struct:
+			writeFields(w, elem, path, depth+1)
+		case elem.Kind() == reflect.Slice && derefKind(elem.Elem()) == reflect.Struct:
+			// Inside {{range}} the element fields are used directly
+			writeFields(w, elem.Elem(), path+"[]", depth+1)
+		}
+	}
+}
+
+// typeName returns t without package paths, e.g. "*Profile" or "[]string".
+func typeName(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Pointer:
+		return "*" + typeName(t.Elem())
+	case reflect.Slice:
+		return "[]" + typeName(t.Elem())
+	case reflect.Map:
+		return "map[" + typeName(t.Key()) + "]" + typeName(t.Elem())
+	}
+	if t.Name() != "" {
+		return t.Name()
+	}
+	return t.String()
+}
+
+// derefKind returns the kind of t after following pointers.
+func derefKind(t reflect.Type) reflect.Kind {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t.Kind()
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/thuanlegit/git-identitree/internal/identity"
+	"github.com/thuanlegit/git-identitree/internal/profile"
+	"github.com/thuanlegit/git-identitree/internal/ui"
+	"github.com/thuanlegit/git-identitree/pkg/gidtree/gidtreetest"
+)
+
+func TestParseFormat_Templates(t *testing.T) {
+	env := gidtreetest.NewEnv(t).Build()
+	prof := profile.Profile{
+		Name:           "work",
+		Email:          "work@example.com",
+		SSHKeyPath:     filepath.Join(env.Home(), ".ssh", "id_work"),
+		RemotePatterns: []string{"github.com/acme", "*.corp.com"},
+	}
+
+	tests := []struct {
+		name   string
+		format string
+		data   any
+		want   string
+	}{
+		{name: "field", format: "{{.Name}}", data: prof, want: "work\n"},
+		{name: "tilde", format: "{{tilde .SSHKeyPath}}", data: prof, want: "~/.ssh/id_work\n"},
+		{name: "join", format: `{{.Name}}: {{join .RemotePatterns ", "}}`, data: prof, want: "work: github.com/acme, *.corp.com\n"},
+		{name: "conditional", format: `{{if .GPGKeyID}}signed{{else}}unsigned{{end}}`, data: prof, want: "unsigned\n"},
+		{
+			name:   "nested",
+			format: `{{.Identity.Profile.Name}}{{range .Mappings}} {{.Profile}}{{end}}`,
+			data: ui.StatusReport{
+				Identity: identity.Summary{Profile: &prof},
+				Mappings: []ui.StatusMapping{{Directory: "/a/", Profile: "work"}, {Directory: "/b/", Profile: "oss"}},
+			},
+			want: "work work oss\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl, err := parseFormat(tt.format, tt.data)
+			if err != nil {
+				t.Fatalf("parseFormat() error = %v", err)
+			}
+			var out bytes.Buffer
+			if err := writeFormatted(&out, tmpl, tt.data); err != nil {
+				t.Fatalf("writeFormatted() error = %v", err)
+			}
+			if out.String() != tt.want {
+				t.Errorf("output = %q, want %q", out.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestParseFormat_Errors(t *testing.T) {
+	for _, format := range []string{
+		"{{.Name",
+		"{{.Nmae}}",
+		"{{.Identity.Profile.Emial}}",
+		"{{nosuchfunc .Name}}",
+	} {
+		if _, err := parseFormat(format, ui.StatusReport{}); err == nil || !strings.Contains(err.Error(), "invalid --format template") {
+			t.Errorf("parseFormat(%q) error = %v, want an invalid template error", format, err)
+		}
+	}
+
+	// Errors that depend on the data surface when the template runs
+	if _, err := parseFormat("{{index .RemotePatterns 0}}", profile.Profile{}); err != nil {
+		t.Errorf("parseFormat() error = %v, want the index checked at execution", err)
+	}
+}
+
+func TestWriteFormatHelp(t *testing.T) {
+	var out bytes.Buffer
+	writeFormatHelp(&out, ui.StatusReport{})
+
+	for _, want := range []string{
+		".CurrentDirectory",
+		".Identity.Profile.Email",
+		".Mappings[].Directory",
+		"[]StatusMapping",
+		"tilde",
+		"join",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("help is missing %q:\n%s", want, out.String())
+		}
+	}
+	if strings.Contains(out.String(), "rawCondition") {
+		t.Errorf("help should not list unexported fields:\n%s", out.String())
+	}
+}
+
+func TestProfileList_Format(t *testing.T) {
+	gidtreetest.NewEnv(t).
+		WithProfile(profile.Profile{Name: "work", Email: "work@example.com"}).
+		WithProfile(profile.Profile{Name: "oss", Email: "oss@example.com"}).
+		Build()
+
+	var out bytes.Buffer
+	profileListCmd.SetOut(&out)
+	t.Cleanup(func() { profileListCmd.SetOut(nil) })
+	profileListView.format = "{{.Name}}={{.Email}}"
+	t.Cleanup(func() { profileListView.format = "" })
+
+	if err := profileListCmd.RunE(profileListCmd, nil); err != nil {
+		t.Fatalf("profile list --format error = %v", err)
+	}
+	got := out.String()
+	if !strings.Contains(got, "work=work@example.com\n") || !strings.Contains(got, "oss=oss@example.com\n") {
+		t.Errorf("output = %q, want a line per profile", got)
+	}
+
+	profileListView.format = "{{.Nmae}}"
+	if err := profileListCmd.RunE(profileListCmd, nil); err == nil {
+		t.Error("profile list with an unknown field should fail")
+	}
+}
+
+func TestResolve_Format(t *testing.T) {
+	env := gidtreetest.NewEnv(t).
+		WithProfile(profile.Profile{Name: "work", Email: "work@example.com"}).
+		WithMapping("work", "work").
+		Build()
+
+	var out bytes.Buffer
+	resolveCmd.SetOut(&out)
+	t.Cleanup(func() { resolveCmd.SetOut(nil) })
+	resolveFormat = "{{.Profile.Email}} ({{.Source}})"
+	t.Cleanup(func() { resolveFormat = "" })
+
+	if err := resolveCmd.RunE(resolveCmd, []string{env.Path("work")}); err != nil {
+		t.Fatalf("resolve --format error = %v", err)
+	}
+	if out.String() != "work@example.com (mapping)\n" {
+		t.Errorf("output = %q", out.String())
+	}
+
+	out.Reset()
+	resolveFormat = formatHelpValue
+	if err := resolveCmd.RunE(resolveCmd, nil); err != nil {
+		t.Fatalf("resolve --format help error = %v", err)
+	}
+	if !strings.Contains(out.String(), ".Profile.Email") {
+		t.Errorf("help = %q, want the profile fields", out.String())
+	}
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/thuanlegit/git-identitree/internal/config"
+	"github.com/thuanlegit/git-identitree/internal/mapping"
+	"github.com/thuanlegit/git-identitree/internal/profile"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	repoCloneDepth   int
+	repoCloneLoadKey bool
+)
+
+var repoCloneCmd = &cobra.Command{
+	Use:   "clone <profile> <url> [directory] [-- git-args...]",
+	Short: "Clone a repository with a profile's SSH key and map it",
+	Long: `Run git clone with GIT_SSH_COMMAND set from the profile's SSH key, then map
+the new checkout to the profile. The directory defaults to the one git would
+pick. Arguments after -- are passed to git clone unchanged.
+
+Nothing is mapped when the clone fails. A checkout that already falls under a
+mapping of the same profile is left as it is. With --load-key the profile's
+key is loaded into the SSH agent before cloning. Without ~/.gitconfig, it asks
+before creating one, as map does, and --create-gitconfig skips the question.`,
+	Args: cobra.MinimumNArgs(2),
+	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) != 0 {
+			return nil, cobra.ShellCompDirectiveDefault
+		}
+		manager, err := profile.NewDefaultManager()
+		if err != nil {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		var names []string
+		for _, p := range manager.ListProfiles() {
+			names = append(names, p.Name)
+		}
+		return names, cobra.ShellCompDirectiveNoFileComp
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		positional, gitArgs := args, []string(nil)
+		if dash := cmd.ArgsLenAtDash(); dash >= 0 {
+			positional, gitArgs = args[:dash], args[dash:]
+		}
+		if len(positional) < 2 || len(positional) > 3 {
+			return fmt.Errorf("expected <profile> <url> [directory], got %d arguments", len(positional))
+		}
+		profileName, url := positional[0], positional[1]
+
+		manager, err := profile.NewDefaultManager()
+		if err != nil {
+			return fmt.Errorf("failed to initialize profile manager: %w", err)
+		}
+		prof, err := manager.GetProfile(profileName)
+		if err != nil {
+			return fmt.Errorf("profile not found: %w", err)
+		}
+
+		dest := cloneDirName(url)
+		if len(positional) == 3 {
+			dest = positional[2]
+		}
+		if dest == "" {
+			return fmt.Errorf("cannot guess a directory name from '%s'; pass one", url)
+		}
+		dest, err = filepath.Abs(dest)
+		if err != nil {
+			return fmt.Errorf("failed to resolve path: %w", err)
+		}
+
+		// Refuse before cloning rather than leave an unmapped checkout behind
+		if err := checkGitConfig(confirmer()); err != nil {
+			return fmt.Errorf("failed to map the clone: %w", err)
+		}
+
+		if repoCloneLoadKey {
+			if prof.SSHKeyPath == "" {
+				return fmt.Errorf("profile '%s' does not have an SSH key configured", profileName)
+			}
+			if err := loadProfileKey(cmd, prof, false, false); err != nil {
+				return fmt.Errorf("failed to load SSH key: %w", err)
+			}
+		}
+
+		cloneArgs := []string{"clone"}
+		if repoCloneDepth > 0 {
+			cloneArgs = append(cloneArgs, "--depth", strconv.Itoa(repoCloneDepth))
+		}
+		cloneArgs = append(cloneArgs, gitArgs...)
+		cloneArgs = append(cloneArgs, "--", url, dest)
+
+		var env []profile.EnvVar
+		if prof.SSHKeyPath != "" {
+			env = append(env, profile.EnvVar{Name: "GIT_SSH_COMMAND", Value: prof.SSHCommand()})
+		}
+		if err := runChild("git", cloneArgs, mergeEnv(os.Environ(), env)); err != nil {
+			var exitErr *exitCodeError
+			if errors.As(err, &exitErr) {
+				return fmt.Errorf("git clone failed with status %d; nothing was mapped", exitErr.code)
+			}
+			return err
+		}
+
+		return mapClone(prof, dest)
+	},
+}
+
+// mapClone maps a fresh checkout to prof unless a mapping of prof already
+// covers it.
+func mapClone(prof *profile.Profile, dest string) error {
+	existing, err := mapping.GetMappingForDirectory(dest)
+	if err != nil {
+		return fmt.Errorf("failed to get mapping: %w", err)
+	}
+	if existing != nil && existing.Profile == prof.Name {
+		fmt.Printf("✓ Cloned into '%s', already mapped to profile '%s' through %s\n", dest, prof.Name, existing.Directory)
+		return nil
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	opts := mapping.MapOptions{CaseSensitive: cfg.CaseSensitiveGitdir}
+	if err := mapping.MapProfileToDirectoryWithOptions(prof, dest, opts); err != nil {
+		return fmt.Errorf("cloned into '%s' but failed to map it: %w", dest, err)
+	}
+	fmt.Printf("✓ Cloned into '%s' and mapped it to profile '%s'\n", dest, prof.Name)
+	return warnOverlappingMappings(os.Stderr, dest, prof.Name)
+}
+
+// cloneDirName returns the directory git clone creates for url when none is
+// given, following git's own rules: drop the scheme, credentials, a trailing
+// "/.git", slashes and a port, take the last path component and strip ".git"
+// (or ".bundle") from it.
+func cloneDirName(url string) string {
+	start := url
+	if _, rest, ok := strings.Cut(start, "://"); ok {
+		start = rest
+	}
+	// Skip credentials in the host part
+	if host, _, _ := strings.Cut(start, "/"); strings.Contains(host, "@") {
+		start = start[strings.LastIndex(host, "@")+1:]
+	}
+
+	end := strings.TrimRightFunc(start, func(r rune) bool { return r == '/' || r == '\\' || unicode.IsSpace(r) })
+	if len(end) > 5 && (end[len(end)-5] == '/' || end[len(end)-5] == '\\') && strings.HasSuffix(end, ".git") {
+		end = end[:len(end)-5]
+	}
+	end = strings.TrimRightFunc(end, func(r rune) bool { return r == '/' || r == '\\' || unicode.IsSpace(r) })
+
+	// A bare host keeps no port
+	if !strings.ContainsAny(end, "/\\") {
+		if i := strings.LastIndex(end, ":"); i >= 0 && i < len(end)-1 && strings.Trim(end[i+1:], "0123456789") == "" {
+			end = end[:i]
+		}
+	}
+
+	name := end
+	if i := strings.LastIndexAny(name, "/\\:"); i >= 0 {
+		name = name[i+1:]
+	}
+	if strings.HasSuffix(name, ".bundle") {
+		name = strings.TrimSuffix(name, ".bundle")
+	} else {
+		name = strings.TrimSuffix(name, ".git")
+	}
+
+	// git collapses control characters and whitespace runs into single spaces
+	name = strings.Join(strings.FieldsFunc(name, func(r rune) bool { return unicode.IsSpace(r) || unicode.IsControl(r) }), " ")
+	return name
+}
+
+func init() {
+	repoCloneCmd.Flags().IntVar(&repoCloneDepth, "depth", 0, "Create a shallow clone with this many commits")
+	repoCloneCmd.Flags().BoolVar(&repoCloneLoadKey, "load-key", false, "Load the profile's SSH key into the agent before cloning")
+}
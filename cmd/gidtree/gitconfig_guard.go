@@ -0,0 +1,58 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/thuanlegit/git-identitree/internal/cli"
+	"github.com/thuanlegit/git-identitree/internal/mapping"
+	"github.com/thuanlegit/git-identitree/internal/utils"
+
+	"github.com/spf13/cobra"
+)
+
+// createGitConfig lets the commands that write mappings create a missing
+// ~/.gitconfig without asking (--create-gitconfig).
+var createGitConfig bool
+
+// requireGitConfig reports whether mapping must leave a missing ~/.gitconfig
+// alone: unless --create-gitconfig is set, confirm is asked whether to create
+// it. Without a terminal to ask in, mapping then fails with a description of
+// the alternatives, so a script never creates the file by surprise.
+func requireGitConfig(confirm cli.Confirmer) (bool, error) {
+	path, missing := mapping.MissingGitConfig()
+	if !missing || createGitConfig {
+		return false, nil
+	}
+	ok, err := confirm(
+		fmt.Sprintf("Create %s?", utils.AbbreviateHome(path)),
+		"It does not exist yet; gidtree adds the includeIf blocks of its mappings there. If git reads its global config elsewhere, such as the file in GIT_CONFIG_GLOBAL, answer no and pass --gitconfig <file> instead.",
+	)
+	if errors.Is(err, cli.ErrNoConfirmation) {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return !ok, nil
+}
+
+// checkGitConfig returns ErrGitConfigMissing, before anything is written,
+// when ~/.gitconfig does not exist and the user did not agree to create it,
+// for commands that map directories without MapOptions.RequireGitConfig.
+func checkGitConfig(confirm cli.Confirmer) error {
+	require, err := requireGitConfig(confirm)
+	if err != nil {
+		return err
+	}
+	if require {
+		return mapping.CheckGitConfig()
+	}
+	return nil
+}
+
+func init() {
+	for _, cmd := range []*cobra.Command{mapCmd, applyCmd, repoCloneCmd, mappingsImportCmd} {
+		cmd.Flags().BoolVar(&createGitConfig, "create-gitconfig", false, "Create ~/.gitconfig without asking when it does not exist")
+	}
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/thuanlegit/git-identitree/internal/cli"
+	"github.com/thuanlegit/git-identitree/internal/mapping"
+	"github.com/thuanlegit/git-identitree/internal/profile"
+	"github.com/thuanlegit/git-identitree/pkg/gidtree/gidtreetest"
+)
+
+func TestRequireGitConfig(t *testing.T) {
+	env := gidtreetest.NewEnv(t).Build()
+
+	var asked string
+	answer := func(ok bool, err error) cli.Confirmer {
+		return func(title, _ string) (bool, error) {
+			asked = title
+			return ok, err
+		}
+	}
+
+	tests := []struct {
+		name    string
+		confirm cli.Confirmer
+		want    bool
+	}{
+		{"confirmed", answer(true, nil), false},
+		{"declined", answer(false, nil), true},
+		{"no terminal", answer(false, cli.ErrNoConfirmation), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			asked = ""
+			got, err := requireGitConfig(tt.confirm)
+			if err != nil {
+				t.Fatalf("requireGitConfig() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("requireGitConfig() = %v, want %v", got, tt.want)
+			}
+			if asked != "Create ~/.gitconfig?" {
+				t.Errorf("asked %q, want whether to create ~/.gitconfig", asked)
+			}
+		})
+	}
+
+	t.Run("flag", func(t *testing.T) {
+		setFlag(t, mapCmd, "create-gitconfig", "true")
+		if got, err := requireGitConfig(answer(false, cli.ErrNoConfirmation)); err != nil || got {
+			t.Errorf("requireGitConfig() with --create-gitconfig = %v, %v; want false", got, err)
+		}
+	})
+
+	t.Run("exists", func(t *testing.T) {
+		if err := os.WriteFile(env.Path(".gitconfig"), nil, 0644); err != nil {
+			t.Fatalf("Failed to write git config: %v", err)
+		}
+		t.Cleanup(func() { _ = os.Remove(env.Path(".gitconfig")) })
+		asked = ""
+		if got, err := requireGitConfig(answer(false, nil)); err != nil || got || asked != "" {
+			t.Errorf("requireGitConfig() with ~/.gitconfig = %v, %v, asked %q; want false without asking", got, err, asked)
+		}
+	})
+}
+
+func TestMapCommand_NoGitConfig(t *testing.T) {
+	env := gidtreetest.NewEnv(t).
+		WithProfile(profile.Profile{Name: "work", Email: "work@example.com"}).
+		Build()
+	setFlag(t, mapCmd, "create", "true")
+
+	// stdin is not a terminal in tests, so map cannot ask
+	err := mapCmd.RunE(mapCmd, []string{"work", env.Path("code/work")})
+	if !errors.Is(err, mapping.ErrGitConfigMissing) || !strings.Contains(err.Error(), "--create-gitconfig") {
+		t.Fatalf("map without ~/.gitconfig error = %v, want ErrGitConfigMissing naming --create-gitconfig", err)
+	}
+	if _, err := os.Stat(env.Path(".gitconfig")); err == nil {
+		t.Fatal("a refused map should not create ~/.gitconfig")
+	}
+	if _, err := os.Stat(env.Path(".gitconfig-work")); err == nil {
+		t.Error("a refused map should not write the profile's git config")
+	}
+	if _, err := os.Stat(env.Path("code/work")); err == nil {
+		t.Error("a refused map should not create the directory")
+	}
+
+	err = mapCmd.RunE(mapCmd, []string{"work", env.Path("code/a"), env.Path("code/b")})
+	if !errors.Is(err, mapping.ErrGitConfigMissing) {
+		t.Errorf("map of several directories error = %v, want ErrGitConfigMissing", err)
+	}
+
+	setFlag(t, mapCmd, "create-gitconfig", "true")
+	if err := mapCmd.RunE(mapCmd, []string{"work", env.Path("code/work")}); err != nil {
+		t.Fatalf("map --create-gitconfig error = %v", err)
+	}
+	if m, _ := mapping.GetMappingForDirectory(env.Path("code/work")); m == nil || m.Profile != "work" {
+		t.Errorf("mapping = %+v, want work", m)
+	}
+}
+
+func TestRepoCloneCommand_NoGitConfig(t *testing.T) {
+	env := gidtreetest.NewEnv(t).
+		WithProfile(profile.Profile{Name: "work", Email: "work@example.com"}).
+		Build()
+	bare := bareRepo(t, env)
+
+	setFlag(t, repoCloneCmd, "depth", "0")
+	err := repoCloneCmd.RunE(repoCloneCmd, []string{"work", bare, env.Path("api")})
+	if !errors.Is(err, mapping.ErrGitConfigMissing) {
+		t.Fatalf("clone without ~/.gitconfig error = %v, want ErrGitConfigMissing", err)
+	}
+	if _, err := os.Stat(env.Path("api")); err == nil {
+		t.Error("a refused clone should not clone the repository")
+	}
+	if _, err := os.Stat(env.Path(".gitconfig")); err == nil {
+		t.Error("a refused clone should not create ~/.gitconfig")
+	}
+}
+
+func TestMappingsImport_NoGitConfig(t *testing.T) {
+	env := gidtreetest.NewEnv(t).
+		WithProfile(profile.Profile{Name: "work", Email: "work@example.com"}).
+		Build()
+	exportPath := env.Path("mappings.yaml")
+	export := "mappings:\n  - profile: work\n    directory: " + env.Path("code/work") + "\n"
+	if err := os.WriteFile(exportPath, []byte(export), 0644); err != nil {
+		t.Fatalf("Failed to write export: %v", err)
+	}
+
+	err := mappingsImportCmd.RunE(mappingsImportCmd, []string{exportPath})
+	if !errors.Is(err, mapping.ErrGitConfigMissing) {
+		t.Fatalf("mappings import without ~/.gitconfig error = %v, want ErrGitConfigMissing", err)
+	}
+	if _, err := os.Stat(env.Path(".gitconfig")); err == nil {
+		t.Error("a refused import should not create ~/.gitconfig")
+	}
+}
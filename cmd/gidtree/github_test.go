@@ -0,0 +1,119 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/thuanlegit/git-identitree/internal/github"
+	"github.com/thuanlegit/git-identitree/internal/profile"
+	"github.com/thuanlegit/git-identitree/internal/ui"
+	"github.com/thuanlegit/git-identitree/pkg/gidtree/gidtreetest"
+)
+
+// useGitHubAPI points githubClient at a fake API that knows the octocat
+// account.
+func useGitHubAPI(t *testing.T) {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/users/octocat" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(`{"login":"octocat","id":583231}`))
+	}))
+	t.Cleanup(server.Close)
+	original := githubClient
+	githubClient = &github.Client{BaseURL: server.URL, HTTP: server.Client()}
+	t.Cleanup(func() { githubClient = original })
+}
+
+// useStdinTerminal makes profile create see stdin as a terminal or not.
+func useStdinTerminal(t *testing.T, terminal bool) {
+	t.Helper()
+	original := stdinIsTerminal
+	stdinIsTerminal = func() bool { return terminal }
+	t.Cleanup(func() { stdinIsTerminal = original })
+}
+
+func TestGitHubNoreplyCommand(t *testing.T) {
+	gidtreetest.NewEnv(t).Build()
+	useGitHubAPI(t)
+
+	stdout, stderr, code := runCLIOutput(t, "github", "noreply", "octocat")
+	if code != 0 || stdout != "583231+octocat@users.noreply.github.com\n" {
+		t.Errorf("github noreply = %q, exit %d, stderr %q", stdout, code, stderr)
+	}
+
+	_, stderr, code = runCLIOutput(t, "github", "noreply", "nobody")
+	if code == 0 || !strings.Contains(stderr, "GitHub user 'nobody' not found") {
+		t.Errorf("github noreply for a missing user = exit %d, stderr %q", code, stderr)
+	}
+}
+
+func TestProfileCreateCommand_GitHub(t *testing.T) {
+	env := gidtreetest.NewEnv(t).Build()
+	useGitHubAPI(t)
+	const noreply = "583231+octocat@users.noreply.github.com"
+
+	// Without a terminal the profile is created directly
+	useStdinTerminal(t, false)
+	setFlag(t, profileCreateCmd, "github", "octocat")
+	if err := profileCreateCmd.RunE(profileCreateCmd, nil); err == nil || !strings.Contains(err.Error(), "--name is required") {
+		t.Errorf("profile create --github without --name error = %v", err)
+	}
+	setFlag(t, profileCreateCmd, "name", "oss")
+	if err := profileCreateCmd.RunE(profileCreateCmd, nil); err != nil {
+		t.Fatalf("profile create --github --name error = %v", err)
+	}
+	manager, err := profile.NewDefaultManager()
+	if err != nil {
+		t.Fatalf("NewDefaultManager() error = %v", err)
+	}
+	if prof, err := manager.GetProfile("oss"); err != nil || prof.Email != noreply {
+		t.Errorf("GetProfile(oss) = %+v, %v; want the noreply address", prof, err)
+	}
+
+	// In a terminal the form opens with the address filled in
+	useStdinTerminal(t, true)
+	setFlag(t, profileCreateCmd, "name", "")
+	setFlag(t, profileCreateCmd, "github-noreply", "octocat")
+	var seen profile.Profile
+	orig := createProfileForm
+	t.Cleanup(func() { createProfileForm = orig })
+	createProfileForm = func(initial profile.Profile) (*profile.Profile, error) {
+		seen = initial
+		prof := initial
+		prof.Name = "oss2"
+		return &prof, nil
+	}
+	if err := profileCreateCmd.RunE(profileCreateCmd, nil); err != nil {
+		t.Fatalf("profile create --github-noreply error = %v", err)
+	}
+	if seen.Email != noreply {
+		t.Errorf("form opened with %+v, want the noreply address", seen)
+	}
+
+	// A template that pins the email conflicts
+	path := env.Path("corp.yaml")
+	if err := os.WriteFile(path, []byte("email: \"{{ .Username }}@corp.com\"\n"), 0644); err != nil {
+		t.Fatalf("Failed to write template: %v", err)
+	}
+	setFlag(t, profileCreateCmd, "template", path)
+	if err := profileCreateCmd.RunE(profileCreateCmd, nil); err == nil || !strings.Contains(err.Error(), "sets the email") {
+		t.Errorf("profile create --github --template error = %v, want a conflict", err)
+	}
+
+	// A missing account stops before anything is asked
+	setFlag(t, profileCreateCmd, "template", "")
+	setFlag(t, profileCreateCmd, "github", "nobody")
+	createProfileForm = func(profile.Profile) (*profile.Profile, error) {
+		t.Error("the form opened for a missing GitHub account")
+		return nil, ui.ErrCancelled
+	}
+	if err := profileCreateCmd.RunE(profileCreateCmd, nil); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("profile create --github nobody error = %v", err)
+	}
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/thuanlegit/git-identitree/internal/audit"
+	"github.com/thuanlegit/git-identitree/internal/guard"
+	"github.com/thuanlegit/git-identitree/internal/mapping"
+	"github.com/thuanlegit/git-identitree/internal/utils"
+
+	"github.com/spf13/cobra"
+)
+
+var guardStrict bool
+
+// guardExecutable returns the binary the hook scripts call back into.
+var guardExecutable = os.Executable
+
+var guardInstallCmd = &cobra.Command{
+	Use:   "install",
+	Short: "Stop git from guessing an identity",
+	Long:  "Set user.useConfigOnly in ~/.gitconfig so git refuses to commit without a configured identity. With --strict, a global pre-commit hook is also installed through core.hooksPath that rejects commits in repositories no profile is mapped to. Repository hooks keep running: every hook chains to .git/hooks and to any previously configured core.hooksPath. Set " + guard.BypassEnvVar + "=<reason> to commit anyway; the bypass is recorded in the audit log.",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		binary, err := guardExecutable()
+		if err != nil {
+			return fmt.Errorf("failed to locate gidtree binary: %w", err)
+		}
+
+		if err := guard.Install(binary, guardStrict); err != nil {
+			return fmt.Errorf("failed to install guard: %w", err)
+		}
+
+		if guardStrict {
+			fmt.Println("✓ Strict guard installed: commits outside mapped directories are rejected")
+		} else {
+			fmt.Println("✓ Guard installed: git will not guess an identity")
+		}
+		return nil
+	},
+}
+
+var guardUninstallCmd = &cobra.Command{
+	Use:   "uninstall",
+	Short: "Remove the identity guard",
+	Long:  "Revert the settings changed by guard install and remove the global hook scripts. A core.hooksPath configured before strict mode is restored.",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := guard.Uninstall(); err != nil {
+			return fmt.Errorf("failed to uninstall guard: %w", err)
+		}
+		fmt.Println("✓ Guard uninstalled")
+		return nil
+	},
+}
+
+var guardRunHookCmd = &cobra.Command{
+	Use:    "run-hook [name] [args...]",
+	Short:  "Run a git hook on behalf of the strict guard",
+	Hidden: true,
+	Args:   cobra.MinimumNArgs(1),
+	// Hooks speak to git through their exit status
+	SilenceErrors:      true,
+	SilenceUsage:       true,
+	DisableFlagParsing: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name := args[0]
+
+		state, err := guard.LoadState()
+		if err != nil {
+			return err
+		}
+
+		gitDir, err := gitPath("--git-dir")
+		if err != nil {
+			return err
+		}
+		commonDir, err := gitPath("--git-common-dir")
+		if err != nil {
+			return err
+		}
+
+		if name == "pre-commit" && state != nil && state.Strict {
+			if err := strictPreCommit(gitDir); err != nil {
+				return err
+			}
+		}
+
+		for _, target := range guard.ChainTargets(name, commonDir, state) {
+			if err := runChild(target, args[1:], os.Environ()); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+}
+
+var guardCmd = &cobra.Command{
+	Use:   "guard",
+	Short: "Guard against commits with the wrong identity",
+	Long:  "Commands for installing and removing the global identity guard",
+}
+
+// strictPreCommit rejects a commit in a repository that no profile is mapped to.
+// Replayed commits and explicit bypasses are let through.
+func strictPreCommit(gitDir string) error {
+	top, err := gitPath("--show-toplevel")
+	if err != nil {
+		return err
+	}
+
+	if reason := os.Getenv(guard.BypassEnvVar); reason != "" {
+		if err := audit.Record(audit.Event{Kind: audit.EventGuardBypass, Directory: top, Detail: reason}); err != nil {
+			return fmt.Errorf("failed to record guard bypass: %w", err)
+		}
+		fmt.Fprintf(os.Stderr, "⚠ gidtree guard bypassed (%s); recorded in the audit log\n", reason)
+		return nil
+	}
+
+	if guard.InheritedIdentity(gitDir, os.Getenv, time.Now()) {
+		return nil
+	}
+
+	m, err := mapping.GetMappingForDirectory(top)
+	if err != nil {
+		return fmt.Errorf("failed to get mapping: %w", err)
+	}
+	if m != nil {
+		return nil
+	}
+
+	fmt.Fprintf(os.Stderr, "✗ gidtree strict guard: '%s' is not mapped to any profile.\n", top)
+	fmt.Fprintf(os.Stderr, "  Map it with 'gidtree map <profile> %s', or set %s=<reason> to commit anyway.\n", top, guard.BypassEnvVar)
+	return &exitCodeError{code: 1}
+}
+
+// gitPath runs git rev-parse with a path-returning flag and makes the result absolute.
+func gitPath(flag string) (string, error) {
+	out, err := utils.Command("git", "rev-parse", flag).Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to run git rev-parse %s: %w", flag, err)
+	}
+	path, err := filepath.Abs(strings.TrimSpace(string(out)))
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve %s: %w", flag, err)
+	}
+	return path, nil
+}
+
+func init() {
+	guardInstallCmd.Flags().BoolVar(&guardStrict, "strict", false, "Also reject commits in repositories that are not mapped to a profile")
+
+	guardCmd.AddCommand(guardInstallCmd)
+	guardCmd.AddCommand(guardUninstallCmd)
+	guardCmd.AddCommand(guardRunHookCmd)
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/thuanlegit/git-identitree/internal/audit"
+	"github.com/thuanlegit/git-identitree/internal/guard"
+	"github.com/thuanlegit/git-identitree/internal/profile"
+	"github.com/thuanlegit/git-identitree/internal/utils"
+	"github.com/thuanlegit/git-identitree/pkg/gidtree/gidtreetest"
+)
+
+// cliEnvVar makes the test binary behave as gidtree, so hook scripts
+// installed by the guard tests can call back into it.
+const cliEnvVar = "GIDTREE_TEST_RUN_CLI"
+
+func TestMain(m *testing.M) {
+	if os.Getenv(cliEnvVar) == "1" {
+		main()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// guardFixture builds a temp HOME with a mapped and an unmapped repository and
+// installs the strict guard pointing at the test binary.
+func guardFixture(t *testing.T) *gidtreetest.Built {
+	t.Helper()
+
+	env := gidtreetest.NewEnv(t).
+		WithProfile(profile.Profile{Name: "work", Email: "work@example.com"}).
+		WithGitRepo("work/repo").
+		WithGitRepo("scratch/repo").
+		WithMapping("work", "work").
+		Build()
+
+	t.Setenv(cliEnvVar, "1")
+	guardStrict = true
+	defer func() { guardStrict = false }()
+	if err := guardInstallCmd.RunE(guardInstallCmd, nil); err != nil {
+		t.Fatalf("guard install error = %v", err)
+	}
+	return env
+}
+
+// commit stages a new file and commits it, returning git's combined output.
+func commit(t *testing.T, repo string, env ...string) (string, error) {
+	t.Helper()
+
+	name := filepath.Join(repo, "file.txt")
+	data, _ := os.ReadFile(name)
+	if err := os.WriteFile(name, append(data, 'x'), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	if out, err := utils.Command("git", "-C", repo, "add", "file.txt").CombinedOutput(); err != nil {
+		t.Fatalf("git add failed: %v\n%s", err, out)
+	}
+
+	cmd := utils.Command("git", "-C", repo, "commit", "-q", "-m", "change")
+	// useConfigOnly is on and the scratch repo has no identity, so supply one
+	cmd.Env = append(cmd.Environ(), "GIT_AUTHOR_NAME=T", "GIT_AUTHOR_EMAIL=t@example.com",
+		"GIT_COMMITTER_NAME=T", "GIT_COMMITTER_EMAIL=t@example.com")
+	cmd.Env = append(cmd.Env, env...)
+	out, err := cmd.CombinedOutput()
+	return string(out), err
+}
+
+func writeRepoHook(t *testing.T, repo, name, body string) {
+	t.Helper()
+	path := filepath.Join(repo, ".git", "hooks", name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("Failed to create hooks directory: %v", err)
+	}
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+body+"\n"), 0755); err != nil {
+		t.Fatalf("Failed to write hook: %v", err)
+	}
+}
+
+func TestStrictGuard_RejectsUnmappedRepo(t *testing.T) {
+	env := guardFixture(t)
+
+	out, err := commit(t, env.Path("scratch/repo"))
+	if err == nil {
+		t.Fatal("commit in an unmapped repository should be rejected")
+	}
+	if !strings.Contains(out, "not mapped to any profile") {
+		t.Errorf("rejection message missing, got:\n%s", out)
+	}
+
+	if out, err := commit(t, env.Path("work/repo")); err != nil {
+		t.Errorf("commit in a mapped repository failed: %v\n%s", err, out)
+	}
+}
+
+func TestStrictGuard_ChainsRepositoryHooks(t *testing.T) {
+	env := guardFixture(t)
+	repo := env.Path("work/repo")
+	marker := filepath.Join(env.Home(), "hook-ran")
+
+	writeRepoHook(t, repo, "pre-commit", "touch '"+marker+"'")
+	if out, err := commit(t, repo); err != nil {
+		t.Fatalf("commit failed: %v\n%s", err, out)
+	}
+	if _, err := os.Stat(marker); err != nil {
+		t.Error("repository pre-commit hook should run after the guard")
+	}
+
+	// A failing repository hook still blocks the commit, and other hook types chain too
+	writeRepoHook(t, repo, "commit-msg", "echo 'bad message' >&2; exit 1")
+	out, err := commit(t, repo)
+	if err == nil {
+		t.Fatal("commit-msg hook failure should block the commit")
+	}
+	if !strings.Contains(out, "bad message") {
+		t.Errorf("repository hook output missing, got:\n%s", out)
+	}
+}
+
+func TestStrictGuard_AllowsInheritedIdentity(t *testing.T) {
+	env := guardFixture(t)
+	repo := env.Path("scratch/repo")
+
+	// Rebases and cherry-picks replay commits with their author date set
+	if out, err := commit(t, repo, "GIT_AUTHOR_DATE=@1700000000 +0000"); err != nil {
+		t.Errorf("replayed commit should be allowed: %v\n%s", err, out)
+	}
+}
+
+func TestStrictGuard_BypassIsAudited(t *testing.T) {
+	env := guardFixture(t)
+	repo := env.Path("scratch/repo")
+
+	if out, err := commit(t, repo, guard.BypassEnvVar+"=hotfix for incident 42"); err != nil {
+		t.Fatalf("bypassed commit failed: %v\n%s", err, out)
+	}
+
+	events, err := audit.ReadEvents()
+	if err != nil {
+		t.Fatalf("ReadEvents() error = %v", err)
+	}
+	if len(events) != 1 || events[0].Kind != audit.EventGuardBypass || events[0].Detail != "hotfix for incident 42" {
+		t.Errorf("audit events = %+v, want one guard bypass", events)
+	}
+	if events[0].Directory != repo {
+		t.Errorf("bypass directory = %q, want %q", events[0].Directory, repo)
+	}
+}
+
+func TestStrictGuard_Uninstall(t *testing.T) {
+	env := guardFixture(t)
+
+	if err := guardUninstallCmd.RunE(guardUninstallCmd, nil); err != nil {
+		t.Fatalf("guard uninstall error = %v", err)
+	}
+
+	out, err := utils.Command("git", "config", "--global", "--get", "core.hooksPath").Output()
+	if err == nil {
+		t.Errorf("core.hooksPath still set to %q", out)
+	}
+	if out, err := commit(t, env.Path("scratch/repo")); err != nil {
+		t.Errorf("commit after uninstall failed: %v\n%s", err, out)
+	}
+}
+
+func TestResolveCommand(t *testing.T) {
+	env := gidtreetest.NewEnv(t).
+		WithProfile(profile.Profile{Name: "work", Email: "work@example.com"}).
+		WithMapping("work", "work").
+		Build()
+
+	if err := resolveCmd.RunE(resolveCmd, []string{env.Path("work")}); err != nil {
+		t.Errorf("resolve of a mapped directory error = %v", err)
+	}
+
+	err := resolveCmd.RunE(resolveCmd, []string{env.Home()})
+	exitErr, ok := err.(*exitCodeError)
+	if !ok || exitErr.code != exitMappingNotFound {
+		t.Errorf("resolve of an unmapped directory = %v, want exit status %d", err, exitMappingNotFound)
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/thuanlegit/git-identitree/internal/profile"
+	"github.com/thuanlegit/git-identitree/internal/utils"
+
+	"github.com/spf13/cobra"
+)
+
+// homeFlag is --home, the home directory to use instead of $HOME.
+var homeFlag string
+
+// setupHome applies --home and checks that the home directory can be used
+// before a command runs, so a missing HOME, common in containers and systemd
+// services, gives one clear message instead of a wrapped error from deep
+// inside. Commands that write ~/.gidtree also need it to be writable.
+func setupHome(cmd *cobra.Command) error {
+	utils.SetHomeDir("")
+	if homeFlag != "" {
+		dir, err := filepath.Abs(homeFlag)
+		if err != nil {
+			return fmt.Errorf("invalid --home: %w", err)
+		}
+		utils.SetHomeDir(dir)
+		// git and ssh read $HOME themselves
+		if err := os.Setenv("HOME", dir); err != nil {
+			return fmt.Errorf("failed to set HOME: %w", err)
+		}
+	}
+	if !needsHome(cmd) {
+		return nil
+	}
+
+	home, err := utils.Root()
+	if err != nil {
+		return err
+	}
+	info, err := os.Stat(home)
+	if err != nil || !info.IsDir() {
+		return fmt.Errorf("home directory %s does not exist; set HOME or run with --home <dir>", home)
+	}
+	if cmd == initCmd || cmd == mapCmd {
+		return checkWritable(home)
+	}
+	return nil
+}
+
+// needsHome reports whether cmd reads or writes anything under the home
+// directory. Help and shell completion do not.
+func needsHome(cmd *cobra.Command) bool {
+	switch cmd.Name() {
+	case "help", "completion", "bash", "zsh", "fish", "powershell", cobra.ShellCompRequestCmd, cobra.ShellCompNoDescRequestCmd:
+		return false
+	}
+	return cmd.Runnable()
+}
+
+// checkWritable reports an error unless gidtree can create files in
+// ~/.gidtree, or create that directory in home when it does not exist yet.
+func checkWritable(home string) error {
+	dir, err := profile.GetProfilesDir()
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		dir = home
+	}
+	f, err := os.CreateTemp(dir, ".gidtree-write-check-*")
+	if err != nil {
+		return fmt.Errorf("%s is not writable; fix its permissions or run with --home <dir>", dir)
+	}
+	_ = f.Close()
+	_ = os.Remove(f.Name())
+	return nil
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/thuanlegit/git-identitree/internal/utils"
+)
+
+// unsetHome clears every variable the home directory is looked up from.
+func unsetHome(t *testing.T) {
+	t.Helper()
+	for _, name := range []string{"HOME", "USERPROFILE", "HOMEDRIVE", "HOMEPATH", "home"} {
+		t.Setenv(name, "")
+	}
+	t.Cleanup(func() {
+		homeFlag = ""
+		utils.SetHomeDir("")
+	})
+}
+
+func TestHome_Unset(t *testing.T) {
+	unsetHome(t)
+
+	for _, args := range [][]string{{"init"}, {"map", "work", t.TempDir()}, {"profile", "list"}} {
+		_, stderr, code := runCLIOutput(t, args...)
+		if code == 0 {
+			t.Errorf("%v without HOME exit = 0, want failure", args)
+		}
+		if !strings.Contains(stderr, "HOME is not set; set HOME or run with --home <dir>") {
+			t.Errorf("%v without HOME stderr = %q", args, stderr)
+		}
+	}
+
+	// Help and completion scripts do not touch the home directory
+	if _, stderr, code := runCLIOutput(t, "completion", "bash"); code != 0 {
+		t.Errorf("completion without HOME exit = %d, stderr = %q", code, stderr)
+	}
+}
+
+func TestHome_Flag(t *testing.T) {
+	unsetHome(t)
+	home := t.TempDir()
+
+	if _, stderr, code := runCLIOutput(t, "--home", home, "init"); code != 0 {
+		t.Fatalf("init --home exit = %d, stderr = %q", code, stderr)
+	}
+	if _, err := os.Stat(filepath.Join(home, ".gidtree", "profiles.yaml")); err != nil {
+		t.Errorf("init --home should create ~/.gidtree under %s: %v", home, err)
+	}
+	if got := os.Getenv("HOME"); got != home {
+		t.Errorf("HOME = %q, want %q so git and ssh use it too", got, home)
+	}
+}
+
+func TestHome_Missing(t *testing.T) {
+	unsetHome(t)
+	missing := filepath.Join(t.TempDir(), "nope")
+
+	_, stderr, code := runCLIOutput(t, "--home", missing, "profile", "list")
+	if code == 0 || !strings.Contains(stderr, "does not exist") {
+		t.Errorf("--home with a missing directory = %d, %q", code, stderr)
+	}
+}
+
+func TestHome_NotWritable(t *testing.T) {
+	if os.Getuid() == 0 {
+		t.Skip("root can write anywhere")
+	}
+	unsetHome(t)
+	home := t.TempDir()
+	if err := os.Chmod(home, 0555); err != nil {
+		t.Fatalf("Chmod() error = %v", err)
+	}
+	t.Cleanup(func() { _ = os.Chmod(home, 0755) })
+
+	_, stderr, code := runCLIOutput(t, "--home", home, "init")
+	if code == 0 || !strings.Contains(stderr, "is not writable") {
+		t.Errorf("init with a read-only home = %d, %q", code, stderr)
+	}
+}
@@ -0,0 +1,291 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+
+	"github.com/thuanlegit/git-identitree/internal/guard"
+	"github.com/thuanlegit/git-identitree/internal/identity"
+	"github.com/thuanlegit/git-identitree/internal/mapping"
+	"github.com/thuanlegit/git-identitree/internal/profile"
+	"github.com/thuanlegit/git-identitree/internal/utils"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	hooksAllMapped             bool
+	checkIdentityQuiet         bool
+	checkIdentityExpectProfile string
+	checkIdentityExpectEmail   string
+)
+
+var hooksInstallCmd = &cobra.Command{
+	Use:   "install [path]",
+	Short: "Install a pre-commit hook that checks the commit identity",
+	Long:  "Add a section to the repository's .git/hooks/pre-commit that runs 'gidtree check-identity --quiet', so a commit with an email other than the mapped profile's is rejected. An existing shell hook is kept and runs after the check; with core.hooksPath set, the hook in that directory is used instead. When the mapped profile has co-authors (see 'gidtree pair'), a section is also added to .git/hooks/prepare-commit-msg that appends them as Co-authored-by trailers. With --all-mapped, the hook is installed in every repository found in a mapped directory (the directory itself or its immediate subdirectories).",
+	Args:  cobra.MaximumNArgs(1),
+	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return nil, cobra.ShellCompDirectiveFilterDirs
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		binary, err := guardExecutable()
+		if err != nil {
+			return fmt.Errorf("failed to locate gidtree binary: %w", err)
+		}
+		return forEachHookRepo(cmd.OutOrStdout(), args, func(repo string) error {
+			path, err := guard.InstallRepoHook(repo, binary)
+			if err != nil {
+				return err
+			}
+			_, _ = fmt.Fprintf(cmd.OutOrStdout(), "✓ Installed identity check in %s\n", path)
+			if !wantsCoAuthorsHook(repo) {
+				return nil
+			}
+			path, err = guard.InstallCoAuthorsHook(repo, binary)
+			if err != nil {
+				return err
+			}
+			_, _ = fmt.Fprintf(cmd.OutOrStdout(), "✓ Installed co-author trailers in %s\n", path)
+			return nil
+		})
+	},
+}
+
+var hooksUninstallCmd = &cobra.Command{
+	Use:   "uninstall [path]",
+	Short: "Remove the identity check from a pre-commit hook",
+	Long:  "Remove the sections added by 'gidtree hooks install' from the repository's pre-commit and prepare-commit-msg hooks. The rest of each hook is left untouched; a hook that only contained gidtree's section is deleted.",
+	Args:  cobra.MaximumNArgs(1),
+	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return nil, cobra.ShellCompDirectiveFilterDirs
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return forEachHookRepo(cmd.OutOrStdout(), args, func(repo string) error {
+			removed, err := guard.UninstallRepoHook(repo)
+			if err != nil {
+				return err
+			}
+			if removed {
+				_, _ = fmt.Fprintf(cmd.OutOrStdout(), "✓ Removed identity check from %s\n", repo)
+			} else {
+				_, _ = fmt.Fprintf(cmd.OutOrStdout(), "No identity check installed in %s\n", repo)
+			}
+			removed, err = guard.UninstallCoAuthorsHook(repo)
+			if err != nil {
+				return err
+			}
+			if removed {
+				_, _ = fmt.Fprintf(cmd.OutOrStdout(), "✓ Removed co-author trailers from %s\n", repo)
+			}
+			return nil
+		})
+	},
+}
+
+var hooksCmd = &cobra.Command{
+	Use:   "hooks",
+	Short: "Manage per-repository identity hooks",
+	Long:  "Commands for installing and removing the pre-commit hook that rejects commits made with the wrong email",
+}
+
+var checkIdentityCmd = &cobra.Command{
+	Use:   "check-identity [path]",
+	Short: "Fail when git would commit with the wrong email",
+	Long: `Compare the user.email git resolves in a repository (default: the current
+directory) with the email of the profile mapped to it, and exit with status 1
+and an explanation when they differ. Directories no profile is mapped to pass.
+With --quiet, nothing is printed unless the check fails. This is what the hook
+installed by 'gidtree hooks install' runs.
+
+--expect-profile and --expect-email check the mapping instead, for CI: the path
+must be mapped to that profile, or to a profile with that email, or the command
+exits 1 with a one-line explanation. A path with no mapping fails too; the
+default profile does not count, as it does not set git's identity. This check
+only reads the profiles and the git config, so it needs no terminal, no SSH
+agent, no git repository and no write access to the home directory.`,
+	Example: `  gidtree check-identity
+  gidtree check-identity --expect-profile work ./checkout
+  gidtree check-identity --expect-email jane@work.example`,
+	Args: cobra.MaximumNArgs(1),
+	// Hooks speak to git through their exit status
+	SilenceErrors: true,
+	SilenceUsage:  true,
+	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return nil, cobra.ShellCompDirectiveFilterDirs
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dir := "."
+		if len(args) == 1 {
+			dir = args[0]
+		}
+		dir, err := filepath.Abs(dir)
+		if err != nil {
+			return fmt.Errorf("failed to resolve path: %w", err)
+		}
+		if checkIdentityExpectProfile != "" || checkIdentityExpectEmail != "" {
+			return checkExpectedIdentity(cmd.OutOrStdout(), cmd.ErrOrStderr(), dir, checkIdentityExpectProfile, checkIdentityExpectEmail, checkIdentityQuiet)
+		}
+		return checkIdentity(cmd.OutOrStdout(), cmd.ErrOrStderr(), dir, checkIdentityQuiet)
+	},
+}
+
+// checkExpectedIdentity reports whether dir is mapped to the profile named
+// wantProfile and with email wantEmail, each when not empty, in one line.
+// wantEmail may be one of the profile's aliases.
+// Anything else, including an error, yields exit status 1.
+func checkExpectedIdentity(w, errW io.Writer, dir, wantProfile, wantEmail string, quiet bool) error {
+	expected := describeExpected(wantProfile, wantEmail)
+	s, err := identity.Lookup(dir)
+	if err != nil {
+		_, _ = fmt.Fprintf(errW, "✗ Could not resolve the profile for %s: %v\n", dir, err)
+		return &exitCodeError{code: exitFailure}
+	}
+	if s.Source != identity.SourceMapping {
+		_, _ = fmt.Fprintf(errW, "✗ No profile is mapped to %s, expected %s\n", dir, expected)
+		return &exitCodeError{code: exitFailure}
+	}
+
+	got := fmt.Sprintf("profile '%s' <%s>", s.Profile.Name, s.Profile.Email)
+	if (wantProfile != "" && s.Profile.Name != wantProfile) || (wantEmail != "" && !s.Profile.HasEmail(wantEmail)) {
+		_, _ = fmt.Fprintf(errW, "✗ %s is mapped to %s, expected %s\n", dir, got, expected)
+		return &exitCodeError{code: exitFailure}
+	}
+	if !quiet {
+		_, _ = fmt.Fprintf(w, "✓ %s is mapped to %s\n", dir, got)
+	}
+	return nil
+}
+
+// describeExpected names what --expect-profile and --expect-email ask for.
+func describeExpected(wantProfile, wantEmail string) string {
+	switch {
+	case wantProfile != "" && wantEmail != "":
+		return fmt.Sprintf("profile '%s' <%s>", wantProfile, wantEmail)
+	case wantProfile != "":
+		return fmt.Sprintf("profile '%s'", wantProfile)
+	}
+	return fmt.Sprintf("<%s>", wantEmail)
+}
+
+// checkIdentity reports whether git commits in dir with the mapped profile's
+// email or one of its aliases. Failures go to errW and yield exit status 1.
+func checkIdentity(w, errW io.Writer, dir string, quiet bool) error {
+	s, err := identity.Summarize(dir)
+	if err != nil {
+		_, _ = fmt.Fprintf(errW, "Error: %v\n", err)
+		return &exitCodeError{code: exitStatus(err)}
+	}
+	if s.Source != identity.SourceMapping {
+		if !quiet {
+			_, _ = fmt.Fprintf(w, "No profile is mapped to %s; nothing to check\n", dir)
+		}
+		return nil
+	}
+	if _, ok := utils.FindRepoRoot(dir); !ok {
+		_, _ = fmt.Fprintf(errW, "✗ '%s' is not inside a git repository\n", dir)
+		return &exitCodeError{code: exitFailure}
+	}
+	if s.Effective == nil {
+		_, _ = fmt.Fprintf(errW, "✗ Could not ask git which email it uses in '%s'\n", dir)
+		return &exitCodeError{code: exitFailure}
+	}
+
+	if s.Profile.HasEmail(s.Effective.Email) {
+		if !quiet {
+			_, _ = fmt.Fprintf(w, "✓ Committing as %s (profile '%s')\n", s.Effective.Email, s.Profile.Name)
+		}
+		return nil
+	}
+
+	email := s.Effective.Email
+	if email == "" {
+		email = "no email"
+	}
+	_, _ = fmt.Fprintf(errW, "✗ git would commit as %s, but %s is mapped to profile '%s' <%s>\n", email, s.MappedDirectory, s.Profile.Name, s.Profile.Email)
+	for _, cause := range s.Effective.Causes {
+		_, _ = fmt.Fprintf(errW, "  - %s\n", cause)
+	}
+	_, _ = fmt.Fprintln(errW, "  Run 'gidtree status' for details, or commit with --no-verify to skip this check.")
+	return &exitCodeError{code: exitFailure}
+}
+
+// wantsCoAuthorsHook reports whether the co-authors hook belongs in repo: the
+// profile mapped to it has co-authors, or the hook is already there and is
+// refreshed along with the identity check.
+func wantsCoAuthorsHook(repo string) bool {
+	if guard.HasCoAuthorsHook(repo) {
+		return true
+	}
+	s, err := identity.Lookup(repo)
+	return err == nil && s.Source == identity.SourceMapping && len(s.Profile.CoAuthors) > 0
+}
+
+// forEachHookRepo runs fn on the repository named by args (default: the current
+// directory) or, with --all-mapped, on every repository in a mapped directory.
+// Failures are reported and counted so one broken repository does not stop the rest.
+func forEachHookRepo(w io.Writer, args []string, fn func(repo string) error) error {
+	if !hooksAllMapped {
+		dir := "."
+		if len(args) == 1 {
+			dir = args[0]
+		}
+		dir, err := filepath.Abs(dir)
+		if err != nil {
+			return fmt.Errorf("failed to resolve path: %w", err)
+		}
+		return fn(dir)
+	}
+	if len(args) > 0 {
+		return fmt.Errorf("--all-mapped does not take a path")
+	}
+
+	mappings, err := mapping.ParseMappings()
+	if err != nil {
+		return fmt.Errorf("failed to read mappings: %w", err)
+	}
+	failed, found := 0, 0
+	for _, m := range mappings {
+		if !m.HasDirectory() {
+			continue
+		}
+		for _, repo := range utils.FindRepos(m.Directory) {
+			found++
+			if err := fn(repo); err != nil {
+				_, _ = fmt.Fprintf(w, "✗ %s: %v\n", repo, err)
+				failed++
+			}
+		}
+	}
+	if found == 0 {
+		_, _ = fmt.Fprintln(w, "No repositories found in mapped directories")
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d repositories failed", failed, found)
+	}
+	return nil
+}
+
+func init() {
+	hooksInstallCmd.Flags().BoolVar(&hooksAllMapped, "all-mapped", false, "Install into every repository in a mapped directory")
+	hooksUninstallCmd.Flags().BoolVar(&hooksAllMapped, "all-mapped", false, "Remove from every repository in a mapped directory")
+	checkIdentityCmd.Flags().BoolVarP(&checkIdentityQuiet, "quiet", "q", false, "Print nothing when the check passes")
+	checkIdentityCmd.Flags().StringVar(&checkIdentityExpectProfile, "expect-profile", "", "Fail unless the path is mapped to this profile")
+	checkIdentityCmd.Flags().StringVar(&checkIdentityExpectEmail, "expect-email", "", "Fail unless the path is mapped to a profile with this email")
+	_ = checkIdentityCmd.RegisterFlagCompletionFunc("expect-profile", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		manager, err := profile.NewDefaultManager()
+		if err != nil {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		var names []string
+		for _, p := range manager.ListProfiles() {
+			names = append(names, p.Name)
+		}
+		return names, cobra.ShellCompDirectiveNoFileComp
+	})
+	_ = checkIdentityCmd.RegisterFlagCompletionFunc("expect-email", completeProfileEmails)
+
+	hooksCmd.AddCommand(hooksInstallCmd)
+	hooksCmd.AddCommand(hooksUninstallCmd)
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"bytes"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/thuanlegit/git-identitree/internal/profile"
+	"github.com/thuanlegit/git-identitree/internal/utils"
+	"github.com/thuanlegit/git-identitree/pkg/gidtree/gidtreetest"
+)
+
+// hooksFixture builds a temp HOME with a mapped repository and installs the
+// identity hook into it, pointing at the test binary.
+func hooksFixture(t *testing.T) (*gidtreetest.Built, string) {
+	t.Helper()
+
+	env := gidtreetest.NewEnv(t).
+		WithProfile(profile.Profile{Name: "work", Email: "work@example.com"}).
+		WithGitRepo("work/repo").
+		WithMapping("work", "work").
+		Build()
+	repo := env.Path("work/repo")
+
+	t.Setenv(cliEnvVar, "1")
+	setFlag(t, hooksInstallCmd, "all-mapped", "false")
+	if err := hooksInstallCmd.RunE(hooksInstallCmd, []string{repo}); err != nil {
+		t.Fatalf("hooks install error = %v", err)
+	}
+	return env, repo
+}
+
+// commitWithConfig commits a change using the identity from git config only.
+func commitWithConfig(t *testing.T, repo string) (string, error) {
+	t.Helper()
+
+	name := filepath.Join(repo, "file.txt")
+	data, _ := os.ReadFile(name)
+	if err := os.WriteFile(name, append(data, 'x'), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	if out, err := utils.Command("git", "-C", repo, "add", "file.txt").CombinedOutput(); err != nil {
+		t.Fatalf("git add failed: %v\n%s", err, out)
+	}
+	out, err := utils.Command("git", "-C", repo, "commit", "-q", "-m", "change").CombinedOutput()
+	return string(out), err
+}
+
+func TestHooks_RejectsWrongEmail(t *testing.T) {
+	_, repo := hooksFixture(t)
+
+	if out, err := commitWithConfig(t, repo); err != nil {
+		t.Fatalf("commit with the mapped identity failed: %v\n%s", err, out)
+	}
+
+	if out, err := utils.Command("git", "-C", repo, "config", "user.email", "me@personal.example").CombinedOutput(); err != nil {
+		t.Fatalf("git config failed: %v\n%s", err, out)
+	}
+	out, err := commitWithConfig(t, repo)
+	if err == nil {
+		t.Fatal("commit with a repository-local email should be rejected")
+	}
+	if !strings.Contains(out, "me@personal.example") || !strings.Contains(out, "profile 'work'") || !strings.Contains(out, "repository's own config") {
+		t.Errorf("rejection message = %q, want the emails and the local override as cause", out)
+	}
+}
+
+func TestHooks_ChainsAndUninstalls(t *testing.T) {
+	env, repo := hooksFixture(t)
+	hookPath := filepath.Join(repo, ".git", "hooks", "pre-commit")
+	marker := filepath.Join(env.Home(), "hook-ran")
+
+	// A hook installed afterwards by another tool keeps working alongside ours
+	data, _ := os.ReadFile(hookPath)
+	if err := os.WriteFile(hookPath, append(data, []byte("touch '"+marker+"'\n")...), 0755); err != nil {
+		t.Fatalf("Failed to write hook: %v", err)
+	}
+	if out, err := commitWithConfig(t, repo); err != nil {
+		t.Fatalf("commit failed: %v\n%s", err, out)
+	}
+	if _, err := os.Stat(marker); err != nil {
+		t.Error("the rest of the hook should run after the identity check")
+	}
+
+	if err := hooksUninstallCmd.RunE(hooksUninstallCmd, []string{repo}); err != nil {
+		t.Fatalf("hooks uninstall error = %v", err)
+	}
+	data, _ = os.ReadFile(hookPath)
+	if strings.Contains(string(data), "check-identity") || !strings.Contains(string(data), "touch") {
+		t.Errorf("hook after uninstall = %q, want only the foreign part", data)
+	}
+}
+
+func TestHooks_AllMapped(t *testing.T) {
+	env := gidtreetest.NewEnv(t).
+		WithProfile(profile.Profile{Name: "work", Email: "work@example.com"}).
+		WithGitRepo("work/api").
+		WithGitRepo("work/web").
+		WithGitRepo("scratch/repo").
+		WithMapping("work", "work").
+		Build()
+
+	setFlag(t, hooksInstallCmd, "all-mapped", "true")
+	if err := hooksInstallCmd.RunE(hooksInstallCmd, nil); err != nil {
+		t.Fatalf("hooks install --all-mapped error = %v", err)
+	}
+	for _, rel := range []string{"work/api", "work/web"} {
+		if _, err := os.Stat(env.Path(rel + "/.git/hooks/pre-commit")); err != nil {
+			t.Errorf("hook missing in %s", rel)
+		}
+	}
+	if _, err := os.Stat(env.Path("scratch/repo/.git/hooks/pre-commit")); err == nil {
+		t.Error("hook installed in an unmapped repository")
+	}
+}
+
+func TestCheckIdentity(t *testing.T) {
+	env := gidtreetest.NewEnv(t).
+		WithProfile(profile.Profile{Name: "work", Email: "work@example.com", EmailAliases: []string{"work@old.example.com"}}).
+		WithGitRepo("work/repo").
+		WithGitRepo("scratch/repo").
+		WithMapping("work", "work").
+		Build()
+
+	var out, errOut bytes.Buffer
+	if err := checkIdentity(&out, &errOut, env.Path("work/repo"), false); err != nil {
+		t.Fatalf("checkIdentity() error = %v (%s)", err, errOut.String())
+	}
+	if !strings.Contains(out.String(), "work@example.com") {
+		t.Errorf("checkIdentity() output = %q, want the email in use", out.String())
+	}
+
+	out.Reset()
+	if err := checkIdentity(&out, &errOut, env.Path("scratch/repo"), true); err != nil || out.Len() != 0 {
+		t.Errorf("checkIdentity() in an unmapped repository = %v, %q; want a silent pass", err, out.String())
+	}
+
+	// A repository still committing with an alias is fine
+	if out, err := utils.Command("git", "-C", env.Path("work/repo"), "config", "user.email", "work@old.example.com").CombinedOutput(); err != nil {
+		t.Fatalf("git config failed: %v\n%s", err, out)
+	}
+	if err := checkIdentity(&out, &errOut, env.Path("work/repo"), true); err != nil {
+		t.Errorf("checkIdentity() with an alias = %v (%s), want a pass", err, errOut.String())
+	}
+	if out, err := utils.Command("git", "-C", env.Path("work/repo"), "config", "--unset", "user.email").CombinedOutput(); err != nil {
+		t.Fatalf("git config failed: %v\n%s", err, out)
+	}
+
+	if err := os.Remove(env.Path(".gitconfig-work")); err != nil {
+		t.Fatalf("Failed to remove profile config: %v", err)
+	}
+	err := checkIdentity(&out, &errOut, env.Path("work/repo"), true)
+	if exitErr, ok := err.(*exitCodeError); !ok || exitErr.code != exitFailure {
+		t.Errorf("checkIdentity() with the profile config missing = %v, want exit status 1", err)
+	}
+	if !strings.Contains(errOut.String(), "no email") {
+		t.Errorf("checkIdentity() error output = %q, want an explanation", errOut.String())
+	}
+}
+
+func TestCheckExpectedIdentity(t *testing.T) {
+	env := gidtreetest.NewEnv(t).
+		WithProfile(profile.Profile{Name: "work", Email: "work@example.com", EmailAliases: []string{"work@old.example.com"}}).
+		WithProfile(profile.Profile{Name: "personal", Email: "me@example.com"}).
+		WithMapping("work", "work").
+		Build()
+	if err := os.MkdirAll(env.Path("scratch"), 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	if err := profileDefaultCmd.RunE(profileDefaultCmd, []string{"personal"}); err != nil {
+		t.Fatalf("profile default error = %v", err)
+	}
+	// CI runners have no agent, and nothing here may write to the home directory
+	t.Setenv("SSH_AUTH_SOCK", "")
+	before := listTree(t, env.Home())
+	for _, dir := range []string{env.Home(), env.Path(".gidtree")} {
+		if err := os.Chmod(dir, 0555); err != nil {
+			t.Fatalf("Failed to make %s read-only: %v", dir, err)
+		}
+		t.Cleanup(func() { _ = os.Chmod(dir, 0755) })
+	}
+
+	tests := []struct {
+		name        string
+		dir         string
+		wantProfile string
+		wantEmail   string
+		wantErr     bool
+		wantOutput  string
+	}{
+		{"profile matches", "work/api", "work", "", false, "✓ " + env.Path("work/api") + " is mapped to profile 'work' <work@example.com>\n"},
+		{"email matches", "work", "", "Work@Example.com", false, "✓ " + env.Path("work") + " is mapped to profile 'work' <work@example.com>\n"},
+		{"email alias matches", "work", "", "work@old.example.com", false, "✓ " + env.Path("work") + " is mapped to profile 'work' <work@example.com>\n"},
+		{"profile differs", "work", "personal", "", true, "✗ " + env.Path("work") + " is mapped to profile 'work' <work@example.com>, expected profile 'personal'\n"},
+		{"email differs", "work", "work", "me@example.com", true, "✗ " + env.Path("work") + " is mapped to profile 'work' <work@example.com>, expected profile 'work' <me@example.com>\n"},
+		{"no mapping", "scratch", "personal", "", true, "✗ No profile is mapped to " + env.Path("scratch") + ", expected profile 'personal'\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out, errOut bytes.Buffer
+			err := checkExpectedIdentity(&out, &errOut, env.Path(tt.dir), tt.wantProfile, tt.wantEmail, false)
+			if tt.wantErr {
+				if exitErr, ok := err.(*exitCodeError); !ok || exitErr.code != exitFailure {
+					t.Errorf("checkExpectedIdentity() = %v, want exit status 1", err)
+				}
+				if errOut.String() != tt.wantOutput || out.Len() != 0 {
+					t.Errorf("checkExpectedIdentity() output = %q, %q; want %q on stderr", out.String(), errOut.String(), tt.wantOutput)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("checkExpectedIdentity() error = %v (%s)", err, errOut.String())
+			}
+			if out.String() != tt.wantOutput {
+				t.Errorf("checkExpectedIdentity() output = %q, want %q", out.String(), tt.wantOutput)
+			}
+		})
+	}
+
+	var out, errOut bytes.Buffer
+	if err := checkExpectedIdentity(&out, &errOut, env.Path("work"), "work", "", true); err != nil || out.Len() != 0 {
+		t.Errorf("checkExpectedIdentity() with quiet = %v, %q; want a silent pass", err, out.String())
+	}
+	if after := listTree(t, env.Home()); !slices.Equal(before, after) {
+		t.Errorf("home directory changed during the check:\nbefore %v\nafter  %v", before, after)
+	}
+}
+
+// listTree returns every path under root with its modification time.
+func listTree(t *testing.T, root string) []string {
+	t.Helper()
+	var entries []string
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		entries = append(entries, path+" "+info.ModTime().String())
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to list %s: %v", root, err)
+	}
+	return entries
+}
@@ -1,40 +1,131 @@
 package main
 
 import (
-	"bufio"
+	"errors"
 	"fmt"
+	"io"
 	"os"
-	"strings"
-
+	"path/filepath"
+	"slices"
+	"text/template"
+	"time"
+
+	"github.com/thuanlegit/git-identitree/internal/cli"
+	"github.com/thuanlegit/git-identitree/internal/cloudsync"
+	"github.com/thuanlegit/git-identitree/internal/config"
+	"github.com/thuanlegit/git-identitree/internal/identity"
+	"github.com/thuanlegit/git-identitree/internal/logging"
 	"github.com/thuanlegit/git-identitree/internal/mapping"
+	"github.com/thuanlegit/git-identitree/internal/notice"
 	"github.com/thuanlegit/git-identitree/internal/profile"
 	"github.com/thuanlegit/git-identitree/internal/ssh"
 	"github.com/thuanlegit/git-identitree/internal/ui"
+	"github.com/thuanlegit/git-identitree/internal/utils"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/spf13/cobra"
 )
 
-// version can be set at build time using -ldflags "-X main.version=x.y.z"
-var version = "1.2.3"
+var (
+	// assumeYes answers every confirmation prompt with yes (--yes).
+	assumeYes bool
+	// verbose enables debug logging to stderr (--verbose).
+	verbose bool
+	// noColor renders every view without colors (--no-color, or NO_COLOR).
+	noColor bool
+	// dryRun makes commands that support it report what they would change
+	// without changing anything (--dry-run).
+	dryRun bool
+	// gitConfigFlag redirects gidtree's includeIf blocks from ~/.gitconfig
+	// to another file (--gitconfig).
+	gitConfigFlag string
+
+	mapCaseSensitive  bool
+	mapForce          bool
+	mapQuiet          bool
+	mapCreate         bool
+	mapAllowMissing   bool
+	mapNote           string
+	mapAllowBroad     bool
+	sshLoadExclusive  bool
+	sshLoadKeychain   bool
+	activateExclusive bool
+	activateKeychain  bool
+	initEncrypt       bool
+
+	profileCreateTemplate string
+	profileCreateName     string
+	profileCreateGitHub   string
+	profileUpdateForce    bool
+	profileDeleteForce    bool
+	profileListSort       string
+	// profileListUnusedSince is --unused-since, such as "180d"; empty lists
+	// every profile.
+	profileListUnusedSince string
+)
+
+// confirmer returns how commands should ask for confirmation, honoring --yes
+// and whether stdin is a terminal.
+func confirmer() cli.Confirmer {
+	return cli.NewConfirmer(assumeYes, os.Stdin)
+}
+
+// absPath expands ~ in path and makes it absolute, leaving it as given when
+// either fails.
+func absPath(path string) string {
+	expanded, err := utils.ExpandPath(path)
+	if err != nil {
+		return path
+	}
+	abs, err := filepath.Abs(expanded)
+	if err != nil {
+		return expanded
+	}
+	return abs
+}
 
 var rootCmd = &cobra.Command{
 	Use:   "gidtree",
 	Short: "Git Identitree - Manage Git profiles with directory-based context switching",
 	Long:  "A CLI tool to manage multiple Git identities and automatically switch between them based on directory context.",
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if verbose {
+			logging.Enable(os.Stderr)
+		}
+		if !ui.ColorEnabled(noColor, os.Getenv) {
+			ui.SetColor(false)
+		}
+		if gitConfigFlag != "" {
+			mapping.SetGitConfigPath(absPath(gitConfigFlag))
+		}
+		// The shell reads completions from stdout, so completing must never
+		// prompt; an encrypted store without GIDTREE_PASSPHRASE completes nothing
+		if cmd.Name() == cobra.ShellCompRequestCmd {
+			profile.PassphraseSource = cli.NewPassphraseSource(nil, "", false)
+		}
+		if err := setupHome(cmd); err != nil {
+			return err
+		}
+		configureSSH()
+		return nil
+	},
 }
 
 var initCmd = &cobra.Command{
 	Use:   "init",
 	Short: "Initialize Git Identitree",
-	Long:  "Create the necessary working directory (~/.gidtree/) and ensure permissions are correct",
+	Long: `Create the necessary working directory (~/.gidtree/) and ensure permissions are correct.
+
+--encrypt encrypts profiles.yaml with a passphrase. Commands that read profiles
+then prompt for it, or take it from GIDTREE_PASSPHRASE when not run in a
+terminal. 'gidtree profile decrypt-store' turns encryption off again.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		profilesDir, err := profile.GetProfilesDir()
 		if err != nil {
 			return fmt.Errorf("failed to get profiles directory: %w", err)
 		}
 
-		if err := os.MkdirAll(profilesDir, 0755); err != nil {
+		if err := os.MkdirAll(profilesDir, utils.PrivateDirMode); err != nil {
 			return fmt.Errorf("failed to create profiles directory: %w", err)
 		}
 
@@ -51,170 +142,307 @@ var initCmd = &cobra.Command{
 		}
 
 		fmt.Printf("✓ Initialized Git Identitree at %s\n", profilesDir)
+
+		if initEncrypt {
+			if err := encryptProfiles(os.Stdout); err != nil {
+				return err
+			}
+		}
+
+		// Earlier versions created these files world-readable
+		if _, err := checkPermissions(os.Stdout, confirmer()); err != nil {
+			return err
+		}
 		return nil
 	},
 }
 
+// encryptProfiles encrypts profiles.yaml with a newly chosen passphrase.
+func encryptProfiles(w io.Writer) error {
+	encrypted, err := profile.IsEncrypted()
+	if err != nil {
+		return fmt.Errorf("failed to read profiles file: %w", err)
+	}
+	if encrypted {
+		_, _ = fmt.Fprintln(w, "profiles.yaml is already encrypted")
+		return nil
+	}
+
+	passphrase, err := cli.NewPassphraseSource(os.Stdin, "New passphrase for profiles.yaml", true)()
+	if err != nil {
+		return err
+	}
+	if err := profile.EncryptStore(passphrase); err != nil {
+		return err
+	}
+	_, _ = fmt.Fprintln(w, "✓ Encrypted profiles.yaml; keep the passphrase safe, it cannot be recovered")
+	return nil
+}
+
 var profileCreateCmd = &cobra.Command{
 	Use:   "create",
 	Short: "Create a new profile",
-	Long:  "Interactively create a new Git profile",
+	Long: `Interactively create a new Git profile.
+
+--template takes a YAML file or http(s) URL with your team's conventions.
+Fields it sets are not asked for and may use placeholders, which are asked
+for instead:
+
+  email: "{{ .Username }}@corp.com"
+  ssh_key_path: "~/.ssh/id_ed25519_{{ .Username }}"
+  signing_required: true
+  directory_prefix: "~/work/"
+
+signing_required makes the GPG key ID mandatory. With directory_prefix set,
+gidtree offers to map the new profile to a directory starting with it.
+
+--github <username> looks up the account's ID with GitHub's public API and
+fills in its ID+username@users.noreply.github.com address as the email.
+Without a terminal, --github and --name create the profile directly with
+that name and address; add an SSH key later with 'gidtree profile update'.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		prof, err := ui.CreateProfileForm()
+		manager, err := profile.NewDefaultManager()
 		if err != nil {
-			return fmt.Errorf("failed to create profile: %w", err)
+			return fmt.Errorf("failed to initialize profile manager: %w", err)
 		}
-
-		manager, err := profile.NewManager()
+		initial, err := githubProfile(profileCreateName, profileCreateGitHub)
 		if err != nil {
-			return fmt.Errorf("failed to initialize profile manager: %w", err)
+			return err
 		}
 
-		if err := manager.AddProfile(*prof); err != nil {
-			return fmt.Errorf("failed to save profile: %w", err)
+		var (
+			prof   *profile.Profile
+			tmpl   *profile.Template
+			values map[string]string
+		)
+		if profileCreateTemplate != "" {
+			tmpl, err = profile.LoadTemplate(profileCreateTemplate)
+			if err != nil {
+				return err
+			}
+			if err := applyInitialToTemplate(tmpl, initial); err != nil {
+				return err
+			}
+			if prof, values, err = ui.TemplateProfileForm(tmpl); err != nil {
+				return fmt.Errorf("failed to create profile: %w", err)
+			}
+			if err := manager.AddProfile(*prof); err != nil {
+				return fmt.Errorf("failed to save profile: %w", err)
+			}
+		} else if initial.Email != "" && !stdinIsTerminal() {
+			if initial.Name == "" {
+				return errors.New("--name is required to create a profile without a terminal")
+			}
+			if err := manager.AddProfile(initial); err != nil {
+				return fmt.Errorf("failed to save profile: %w", err)
+			}
+			prof = &initial
+		} else if prof, err = createProfile(cmd.ErrOrStderr(), manager, initial); errors.Is(err, ui.ErrCancelled) {
+			fmt.Println("Cancelled; no profile was created")
+			return nil
+		} else if err != nil {
+			return err
 		}
 
 		fmt.Printf("✓ Profile '%s' created successfully\n", prof.Name)
+
+		if tmpl != nil && tmpl.DirectoryPrefix != "" {
+			return offerTemplateMapping(prof.Name, tmpl, values)
+		}
 		return nil
 	},
 }
 
-var profileListCmd = &cobra.Command{
-	Use:   "list",
-	Short: "List all profiles",
-	Long:  "Display all stored profiles with their core settings",
-	RunE: func(cmd *cobra.Command, args []string) error {
-		manager, err := profile.NewManager()
+// createProfileForm asks for a new profile; replaced in tests.
+var createProfileForm = ui.CreateProfileForm
+
+// createProfile asks for a new profile, starting from initial, and adds it.
+// When the manager rejects it for a reason the form can fix, such as a taken
+// name, the error is printed to w and the form opens again with the answers
+// kept.
+func createProfile(w io.Writer, manager *profile.Manager, initial profile.Profile) (*profile.Profile, error) {
+	answers := initial
+	for {
+		prof, err := createProfileForm(answers)
 		if err != nil {
-			return fmt.Errorf("failed to initialize profile manager: %w", err)
+			return nil, fmt.Errorf("failed to create profile: %w", err)
 		}
-
-		profiles := manager.ListProfiles()
-		model := ui.NewListModel(profiles)
-
-		p := tea.NewProgram(model, tea.WithAltScreen())
-		if _, err := p.Run(); err != nil {
-			return fmt.Errorf("failed to run UI: %w", err)
+		err = manager.AddProfile(*prof)
+		if err == nil {
+			return prof, nil
+		}
+		if !errors.Is(err, profile.ErrProfileExists) && !errors.Is(err, profile.ErrInvalidName) && !errors.Is(err, profile.ErrSSHKeyMissing) && !errors.Is(err, profile.ErrPuTTYKey) {
+			return nil, fmt.Errorf("failed to save profile: %w", err)
 		}
+		_, _ = fmt.Fprintf(w, "✗ %v\n", err)
+		answers = *prof
+	}
+}
 
+// offerTemplateMapping offers to map a profile created from a template to a
+// directory starting with the template's prefix. Without a terminal it only
+// prints the command to run.
+func offerTemplateMapping(name string, tmpl *profile.Template, values map[string]string) error {
+	prefix, err := tmpl.RenderDirectoryPrefix(values)
+	if err != nil {
+		return err
+	}
+	if !cli.IsTerminal(os.Stdin) {
+		fmt.Printf("Map it with: gidtree map %s %s<directory>\n", name, prefix)
 		return nil
-	},
+	}
+
+	ok, err := ui.ConfirmForm("Map a directory now?", fmt.Sprintf("The template suggests directories under %s", prefix))
+	if err != nil || !ok {
+		return err
+	}
+	dir, err := ui.MapDirectoryForm(name, prefix)
+	if err != nil {
+		return fmt.Errorf("failed to read directory: %w", err)
+	}
+	return mapCmd.RunE(mapCmd, []string{name, dir})
 }
 
-var profileDeleteCmd = &cobra.Command{
-	Use:   "delete [name]",
-	Short: "Delete a profile",
-	Long:  "Delete a profile. If mapped to directories, will prompt to unmap them first.",
-	Args:  cobra.ExactArgs(1),
-	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
-		manager, err := profile.NewManager()
+var profileListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List all profiles",
+	Long:  "Display all stored profiles with their core settings. Use the arrow keys or j/k to select a profile, / to filter by name, email or author, enter to show its details, e to edit, d to delete and m to map it to a directory. Profiles are listed by name; --sort created or --sort email orders them by creation time or email instead. When stdout is not a terminal, a plain table is printed instead (JSON with output_format set to json); --plain, --json and --interactive choose explicitly. --format prints each profile with a Go template such as '{{.Name}}\t{{.Email}}' ('--format help' lists the fields). The Last Used column shows the day a profile was last activated, resolved or had its key loaded; --unused-since 180d lists only the profiles not used for that long (also d, w or y), to find ones to delete.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		mode, err := selectView(cmd, profileListView, stdoutIsTerminal, os.Stderr)
 		if err != nil {
-			return nil, cobra.ShellCompDirectiveNoFileComp
+			return err
 		}
-		profiles := manager.ListProfiles()
-		var names []string
-		for _, p := range profiles {
-			names = append(names, p.Name)
+		if profileListView.format == formatHelpValue {
+			writeFormatHelp(cmd.OutOrStdout(), profile.Profile{})
+			return nil
 		}
-		return names, cobra.ShellCompDirectiveNoFileComp
-	},
-	RunE: func(cmd *cobra.Command, args []string) error {
-		profileName := args[0]
-
-		manager, err := profile.NewManager()
-		if err != nil {
-			return fmt.Errorf("failed to initialize profile manager: %w", err)
+		var tmpl *template.Template
+		if profileListView.format != "" {
+			if tmpl, err = parseFormat(profileListView.format, profile.Profile{}); err != nil {
+				return err
+			}
 		}
 
-		// Check if profile exists
-		_, err = manager.GetProfile(profileName)
+		sortKey, err := profile.ParseSortKey(profileListSort)
 		if err != nil {
-			return fmt.Errorf("profile not found: %w", err)
+			return err
+		}
+		var unusedSince time.Duration
+		if profileListUnusedSince != "" {
+			if unusedSince, err = utils.ParseAge(profileListUnusedSince); err != nil {
+				return fmt.Errorf("invalid --unused-since: %w", err)
+			}
 		}
 
-		// Get all directories mapped to this profile
-		directories, err := mapping.GetDirectoriesForProfile(profileName)
+		manager, err := profile.NewDefaultManager()
 		if err != nil {
-			return fmt.Errorf("failed to check profile mappings: %w", err)
+			return fmt.Errorf("failed to initialize profile manager: %w", err)
 		}
 
-		// If profile is mapped, ask user if they want to unmap
-		if len(directories) > 0 {
-			fmt.Printf("Profile '%s' is mapped to the following directories:\n", profileName)
-			for _, dir := range directories {
-				fmt.Printf("  - %s\n", dir)
-			}
-			fmt.Print("\nDo you want to unmap all directories and delete the profile? (y/N): ")
-
-			reader := bufio.NewReader(os.Stdin)
-			response, err := reader.ReadString('\n')
-			if err != nil {
-				return fmt.Errorf("failed to read input: %w", err)
-			}
-
-			response = strings.TrimSpace(strings.ToLower(response))
-			if response != "y" && response != "yes" {
-				fmt.Println("Delete cancelled.")
+		profiles := slices.Clone(manager.ListProfiles())
+		if profileListUnusedSince != "" {
+			cutoff := time.Now().Add(-unusedSince)
+			profiles = slices.DeleteFunc(profiles, func(p profile.Profile) bool { return !p.UnusedSince(cutoff) })
+		}
+		profile.Sort(profiles, sortKey)
+		switch mode {
+		case viewJSON:
+			return writeJSON(cmd.OutOrStdout(), profiles)
+		case viewPlain:
+			if tmpl == nil {
+				_, _ = fmt.Fprint(cmd.OutOrStdout(), ui.RenderProfilesPlain(profiles))
 				return nil
 			}
-
-			// Unmap all directories
-			fmt.Println("\nUnmapping directories...")
-			for _, dir := range directories {
-				if err := mapping.UnmapDirectory(dir); err != nil {
-					return fmt.Errorf("failed to unmap directory '%s': %w", dir, err)
+			// One line per profile
+			for _, prof := range profiles {
+				if err := writeFormatted(cmd.OutOrStdout(), tmpl, prof); err != nil {
+					return err
 				}
-				fmt.Printf("  ✓ Unmapped: %s\n", dir)
 			}
+			return nil
 		}
+		model := ui.NewListModel(profiles)
 
-		// Delete the profile (no need to check mappings again)
-		isMapped := func(name string) (bool, error) {
-			return false, nil // Already handled above
-		}
-
-		if err := manager.DeleteProfile(profileName, isMapped); err != nil {
-			return fmt.Errorf("failed to delete profile: %w", err)
+		// Hold debug logs back while the UI owns the screen
+		resume := logging.Pause()
+		p := tea.NewProgram(model, tea.WithAltScreen())
+		_, err = p.Run()
+		resume()
+		if err != nil {
+			return fmt.Errorf("failed to run UI: %w", err)
 		}
 
-		fmt.Printf("\n✓ Profile '%s' deleted successfully\n", profileName)
-		return nil
+		return runListAction(model.Selected())
 	},
 }
 
-var profileUpdateCmd = &cobra.Command{
-	Use:   "update [name]",
-	Short: "Update an existing profile",
-	Long:  "Interactively update an existing Git profile with pre-populated values",
-	Args:  cobra.ExactArgs(1),
-	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
-		manager, err := profile.NewManager()
+// runListAction carries out the action chosen in the interactive profile list
+// by delegating to the matching command, so the same checks apply.
+func runListAction(action ui.ListAction, prof *profile.Profile) error {
+	switch action {
+	case ui.ListActionEdit:
+		return profileUpdateCmd.RunE(profileUpdateCmd, []string{prof.Name})
+	case ui.ListActionDelete:
+		return profileDeleteCmd.RunE(profileDeleteCmd, []string{prof.Name})
+	case ui.ListActionMap:
+		dir, err := ui.MapDirectoryForm(prof.Name, "")
 		if err != nil {
-			return nil, cobra.ShellCompDirectiveNoFileComp
+			return fmt.Errorf("failed to read directory: %w", err)
 		}
-		profiles := manager.ListProfiles()
-		var names []string
-		for _, p := range profiles {
-			names = append(names, p.Name)
+		return mapCmd.RunE(mapCmd, []string{prof.Name, dir})
+	}
+	return nil
+}
+
+var profileDeleteCmd = &cobra.Command{
+	Use:   "delete [name]",
+	Short: "Delete a profile",
+	Long: `Delete a profile. If mapped to directories, will prompt to unmap them first.
+
+The prompt needs a terminal; use the global --yes flag to unmap without asking.
+If profiles.yaml changes while the prompt is open, the delete is refused;
+--force deletes anyway, overwriting the other change.`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeProfileNames,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		manager, err := profile.NewDefaultManager()
+		if err != nil {
+			return fmt.Errorf("failed to initialize profile manager: %w", err)
 		}
-		return names, cobra.ShellCompDirectiveNoFileComp
+		manager.Force = profileDeleteForce
+
+		return deleteProfile(os.Stdout, manager, args[0], confirmer())
 	},
+}
+
+var profileUpdateCmd = &cobra.Command{
+	Use:               "update [name]",
+	Short:             "Update an existing profile",
+	Long:              "Interactively update an existing Git profile with pre-populated values. If profiles.yaml changes while the form is open, for example from another terminal, the update is refused instead of overwriting that change; run it again, or pass --force to overwrite.",
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeProfileNames,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		profileName := args[0]
 
-		manager, err := profile.NewManager()
+		manager, err := profile.NewDefaultManager()
 		if err != nil {
 			return fmt.Errorf("failed to initialize profile manager: %w", err)
 		}
+		manager.Force = profileUpdateForce
 
 		// Get the current profile
 		currentProfile, err := manager.GetProfile(profileName)
 		if err != nil {
 			return fmt.Errorf("profile not found: %w", err)
 		}
+		profileName = currentProfile.Name
 
 		// Show update form with pre-populated values
 		updatedProfile, err := ui.UpdateProfileForm(currentProfile)
+		if errors.Is(err, ui.ErrCancelled) {
+			fmt.Println("Cancelled; the profile was not changed")
+			return nil
+		}
 		if err != nil {
 			return fmt.Errorf("failed to update profile: %w", err)
 		}
@@ -225,6 +453,15 @@ var profileUpdateCmd = &cobra.Command{
 		}
 
 		fmt.Printf("✓ Profile '%s' updated successfully\n", profileName)
+
+		// Mapped directories read the profile's generated config, not the profile file
+		synced, err := mapping.SyncProfileConfig(updatedProfile)
+		if err != nil {
+			return fmt.Errorf("profile saved but its git config was not refreshed (run 'gidtree sync'): %w", err)
+		}
+		if synced {
+			fmt.Printf("✓ Refreshed the git config of its mapped directories\n")
+		}
 		return nil
 	},
 }
@@ -236,34 +473,44 @@ var profileCmd = &cobra.Command{
 }
 
 var mapCmd = &cobra.Command{
-	Use:   "map [profile] [directory]",
-	Short: "Map a profile to a directory",
-	Long:  "Associate a profile with a target directory path. Git will automatically use this profile when working in that directory.",
-	Args:  cobra.ExactArgs(2),
+	Use:   "map [profile] [directory...]",
+	Short: "Map a profile to one or more directories",
+	Long: `Associate a profile with a target directory path. Git will automatically use this profile when working in that directory.
+
+Several directories can be mapped at once. Each is reported as it is done,
+followed by a summary such as "7 mapped, 1 skipped (already mapped), 1
+failed"; a failure does not stop the others. With --quiet only the failures
+and the summary are printed.
+
+The directory has to exist, so a typo is caught before commits use the wrong
+identity. --create creates it; --allow-missing maps it anyway, for setting up a
+machine before its checkouts are in place.
+
+Mapping the home directory or the filesystem root gives every repository on the
+machine the profile's identity, which is rarely intended; it needs --allow-broad.
+
+On a machine without ~/.gitconfig, map asks before creating it, since git may be
+configured elsewhere on purpose, such as through GIT_CONFIG_GLOBAL; --gitconfig
+writes the mapping to another file instead. --create-gitconfig creates it without
+asking; without a terminal and without the flag, map fails.
+
+Each includeIf block gidtree writes sits below a comment recording the profile
+and the date it was mapped, plus the --note if given:
+
+  # gidtree: profile=work mapped=2024-05-01 note="acme contract"`,
+	Args: cobra.MinimumNArgs(2),
 	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
 		if len(args) == 0 {
-			// First argument: profile name - get list of profiles
-			manager, err := profile.NewManager()
-			if err != nil {
-				return nil, cobra.ShellCompDirectiveNoFileComp
-			}
-			profiles := manager.ListProfiles()
-			var names []string
-			for _, p := range profiles {
-				names = append(names, p.Name)
-			}
-			return names, cobra.ShellCompDirectiveNoFileComp
-		} else if len(args) == 1 {
-			// Second argument: directory path - enable directory completion
-			return nil, cobra.ShellCompDirectiveFilterDirs
+			return completeProfileNames(cmd, args, toComplete)
 		}
-		return nil, cobra.ShellCompDirectiveNoFileComp
+		// Directory paths, from projects_dir when set
+		return completeProjectDirectories(toComplete)
 	},
 	RunE: func(cmd *cobra.Command, args []string) error {
 		profileName := args[0]
 		dir := args[1]
 
-		manager, err := profile.NewManager()
+		manager, err := profile.NewDefaultManager()
 		if err != nil {
 			return fmt.Errorf("failed to initialize profile manager: %w", err)
 		}
@@ -272,26 +519,175 @@ var mapCmd = &cobra.Command{
 		if err != nil {
 			return fmt.Errorf("profile not found: %w", err)
 		}
+		profileName = prof.Name
 
-		if err := mapping.MapProfileToDirectory(prof, dir); err != nil {
+		cfg, err := config.Load()
+		if err != nil {
+			return fmt.Errorf("failed to load config: %w", err)
+		}
+		opts := mapping.MapOptions{
+			CaseSensitive: configuredBool(cmd, "case-sensitive", mapCaseSensitive, cfg.CaseSensitiveGitdir),
+			Force:         mapForce,
+			Create:        mapCreate,
+			AllowMissing:  mapAllowMissing,
+			Note:          mapNote,
+			AllowBroad:    mapAllowBroad,
+		}
+		if opts.RequireGitConfig, err = requireGitConfig(confirmer()); err != nil {
+			return err
+		}
+		if len(args) > 2 {
+			return mapDirectories(os.Stdout, prof, args[1:], opts)
+		}
+		if err := mapping.MapProfileToDirectoryWithOptions(prof, dir, opts); err != nil {
 			return fmt.Errorf("failed to map profile: %w", err)
 		}
 
 		fmt.Printf("✓ Profile '%s' mapped to directory '%s'\n", profileName, dir)
+		if err := warnOverlappingMappings(os.Stderr, dir, profileName); err != nil {
+			return err
+		}
+		if !mapQuiet {
+			warnNoRepositories(os.Stderr, profileName, dir)
+		}
+		warnCloudSynced(os.Stderr, dir)
 		return nil
 	},
 }
 
+// mapDirectories maps prof to each of dirs, reporting each directory to w as
+// it is done. A directory already mapped to prof is skipped, and a failure
+// does not stop the others.
+func mapDirectories(w io.Writer, prof *profile.Profile, dirs []string, opts mapping.MapOptions) error {
+	// Every directory would fail the same way
+	if opts.RequireGitConfig {
+		if err := mapping.CheckGitConfig(); err != nil {
+			return fmt.Errorf("failed to map profile: %w", err)
+		}
+	}
+	report := newReporter(w, "mapped", mapQuiet)
+	for _, dir := range dirs {
+		err := mapping.MapProfileToDirectoryWithOptions(prof, dir, opts)
+		switch {
+		case errors.Is(err, mapping.ErrDirectoryAlreadyMapped) && mappedExactly(dir, prof.Name):
+			report.Skip(dir, "already mapped")
+			continue
+		case err != nil:
+			report.Fail(dir, err)
+			continue
+		}
+		report.Done(dir)
+		if err := warnOverlappingMappings(os.Stderr, dir, prof.Name); err != nil {
+			return err
+		}
+		if !mapQuiet {
+			warnNoRepositories(os.Stderr, prof.Name, dir)
+		}
+		warnCloudSynced(os.Stderr, dir)
+	}
+	report.Summary()
+	if err := report.Err(); err != nil {
+		return fmt.Errorf("failed to map profile: %w", err)
+	}
+	return nil
+}
+
+// mappedExactly reports whether dir itself, rather than a parent, is mapped
+// to profileName.
+func mappedExactly(dir, profileName string) bool {
+	_, candidates, err := mapping.ExplainMappingForDirectory(dir)
+	if err != nil {
+		return false
+	}
+	for _, c := range candidates {
+		if c.Match == mapping.MatchExact && c.Mapping.Profile == profileName {
+			return true
+		}
+	}
+	return false
+}
+
+// newReporter returns a cli.Reporter for a bulk command writing to w, colored
+// when w is a terminal and colors are enabled.
+func newReporter(w io.Writer, verb string, quiet bool) *cli.Reporter {
+	f, isFile := w.(*os.File)
+	color := isFile && cli.IsTerminal(f) && ui.ColorEnabled(noColor, os.Getenv)
+	return cli.NewReporter(w, verb, quiet, color)
+}
+
+// warnOverlappingMappings warns about mappings of other profiles nested
+// inside dir or containing it, where either identity may end up applying.
+func warnOverlappingMappings(w io.Writer, dir, profileName string) error {
+	overlapping, err := mapping.OverlappingMappings(dir, profileName)
+	if err != nil {
+		return fmt.Errorf("failed to check overlapping mappings: %w", err)
+	}
+	for _, m := range overlapping {
+		_, _ = fmt.Fprintf(w, "⚠ '%s' overlaps '%s', which is mapped to profile '%s'.\n", dir, m.Directory, m.Profile)
+	}
+	if len(overlapping) > 0 {
+		_, _ = fmt.Fprintln(w, "  Repositories covered by both get the identity whose includeIf block comes last in ~/.gitconfig.")
+	}
+	return nil
+}
+
+// warnNoRepositories warns when dir is neither a git repository nor directly
+// holds one, which usually means the wrong directory was mapped. When the
+// working directory is inside a repository its root is suggested instead.
+func warnNoRepositories(w io.Writer, profileName, dir string) {
+	expanded, err := utils.ExpandPath(dir)
+	if err != nil {
+		return
+	}
+	if info, err := os.Stat(expanded); err != nil || !info.IsDir() || utils.ContainsRepos(expanded) {
+		return
+	}
+
+	_, _ = fmt.Fprintf(w, "⚠ '%s' is not a git repository and has none directly inside it.\n", dir)
+	_, _ = fmt.Fprintln(w, "  The mapping applies to repositories anywhere under this path.")
+	if cwd, err := os.Getwd(); err == nil {
+		if root, ok := utils.FindRepoRoot(cwd); ok {
+			_, _ = fmt.Fprintf(w, "  Did you mean: gidtree map %s %s\n", profileName, root)
+		}
+	}
+}
+
+// warnCloudSynced prints a one-time warning when dir lies inside a cloud-synced folder.
+func warnCloudSynced(w io.Writer, dir string) {
+	provider, ok := cloudsync.Detect(dir)
+	if !ok || !notice.ShowOnce(notice.CloudSync) {
+		return
+	}
+	_, _ = fmt.Fprintf(w, "⚠ '%s' is inside a %s folder.\n%s\n", dir, provider, cloudsync.Warning)
+	_, _ = fmt.Fprintln(w, "  See: https://github.com/thuanlegit/git-identitree#cloud-synced-folders (this warning is shown only once)")
+}
+
+var (
+	unmapProfileName string
+	unmapQuiet       bool
+)
+
 var unmapCmd = &cobra.Command{
 	Use:   "unmap [directory]",
 	Short: "Remove a directory mapping",
-	Long:  "Remove the association between a directory and its profile",
-	Args:  cobra.ExactArgs(1),
-	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
-		// Enable directory completion
-		return nil, cobra.ShellCompDirectiveFilterDirs
+	Long: `Remove the association between a directory and its profile.
+
+With --profile, every directory mapped to the profile is unmapped after
+confirmation. Use the global --yes flag to skip the prompt. Each directory is
+reported as it is unmapped, followed by a summary; with --quiet only the
+failures and the summary are printed.`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if unmapProfileName != "" {
+			return cobra.NoArgs(cmd, args)
+		}
+		return cobra.ExactArgs(1)(cmd, args)
 	},
+	ValidArgsFunction: completeMappedDirectories,
 	RunE: func(cmd *cobra.Command, args []string) error {
+		if unmapProfileName != "" {
+			return unmapProfile(os.Stdout, unmapProfileName, unmapQuiet, confirmer())
+		}
+
 		dir := args[0]
 
 		if err := mapping.UnmapDirectory(dir); err != nil {
@@ -303,18 +699,121 @@ var unmapCmd = &cobra.Command{
 	},
 }
 
+// unmapProfile removes every mapping of a profile once confirm agrees,
+// reporting each directory to w; quiet reports only failures and the summary.
+func unmapProfile(w io.Writer, profileName string, quiet bool, confirm cli.Confirmer) error {
+	directories, err := mapping.GetDirectoriesForProfile(profileName)
+	if err != nil {
+		return fmt.Errorf("failed to check profile mappings: %w", err)
+	}
+	if len(directories) == 0 {
+		return fmt.Errorf("profile '%s' is not mapped to any directory", profileName)
+	}
+
+	description := fmt.Sprintf("Profile '%s' is mapped to:\n", profileName)
+	for _, dir := range directories {
+		description += fmt.Sprintf("  - %s\n", dir)
+	}
+	ok, err := confirm(fmt.Sprintf("Unmap all %d directories?", len(directories)), description)
+	if err != nil {
+		return fmt.Errorf("failed to confirm unmap: %w", err)
+	}
+	if !ok {
+		_, _ = fmt.Fprintln(w, "Unmap cancelled.")
+		return nil
+	}
+
+	// One rewrite of ~/.gitconfig for all of them, so either every
+	// directory is unmapped or none is
+	err = mapping.UnmapDirectories(directories)
+	report := newReporter(w, "unmapped", quiet)
+	for _, dir := range directories {
+		if err != nil {
+			report.Fail(dir, err)
+			continue
+		}
+		report.Done(dir)
+	}
+	report.Summary()
+	if err := report.Err(); err != nil {
+		return fmt.Errorf("failed to unmap directories: %w", err)
+	}
+	return nil
+}
+
+var defaultCmd = &cobra.Command{
+	Use:               "default [profile]",
+	Short:             "Set the default identity",
+	Long:              "Write a profile's name and email into the [user] section of ~/.gitconfig, making it the fallback identity outside mapped directories. The previous ~/.gitconfig is backed up to ~/.gidtree/backups.",
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeProfileNames,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		profileName := args[0]
+
+		manager, err := profile.NewDefaultManager()
+		if err != nil {
+			return fmt.Errorf("failed to initialize profile manager: %w", err)
+		}
+
+		prof, err := manager.GetProfile(profileName)
+		if err != nil {
+			return fmt.Errorf("profile not found: %w", err)
+		}
+
+		if err := mapping.SetGlobalUser(prof.GetAuthorName(), prof.Email); err != nil {
+			return fmt.Errorf("failed to set default identity: %w", err)
+		}
+
+		fmt.Printf("✓ Default identity set to profile '%s' (%s <%s>)\n", prof.Name, prof.GetAuthorName(), prof.Email)
+		return nil
+	},
+}
+
 var statusCmd = &cobra.Command{
 	Use:   "status",
 	Short: "Show status and mappings",
-	Long:  "Display which directories are mapped to which profiles and verify the ~/.gitconfig file",
+	Long:  "Display which directories are mapped to which profiles and verify the ~/.gitconfig file. When stdout is not a terminal, plain text is printed instead (JSON with output_format set to json); --plain, --json and --interactive choose explicitly. --format prints the status with a Go template such as '{{tilde .CurrentDirectory}}: {{.Identity.Source}}' ('--format help' lists the fields).",
 	RunE: func(cmd *cobra.Command, args []string) error {
+		mode, err := selectView(cmd, statusView, stdoutIsTerminal, os.Stderr)
+		if err != nil {
+			return err
+		}
+		if statusView.format == formatHelpValue {
+			writeFormatHelp(cmd.OutOrStdout(), ui.StatusReport{})
+			return nil
+		}
+		var tmpl *template.Template
+		if statusView.format != "" {
+			if tmpl, err = parseFormat(statusView.format, ui.StatusReport{}); err != nil {
+				return err
+			}
+		}
+
 		model, err := ui.NewStatusModel()
 		if err != nil {
 			return fmt.Errorf("failed to create status model: %w", err)
 		}
+		if statusTouch {
+			touchActiveProfile()
+		}
+
+		switch mode {
+		case viewJSON:
+			return writeJSON(cmd.OutOrStdout(), model.Report())
+		case viewPlain:
+			if tmpl != nil {
+				return writeFormatted(cmd.OutOrStdout(), tmpl, model.Report())
+			}
+			_, _ = fmt.Fprint(cmd.OutOrStdout(), ui.RenderStatusPlain(model.Report()))
+			return nil
+		}
 
+		// Hold debug logs back while the UI owns the screen
+		resume := logging.Pause()
 		p := tea.NewProgram(model, tea.WithAltScreen())
-		if _, err := p.Run(); err != nil {
+		_, err = p.Run()
+		resume()
+		if err != nil {
 			return fmt.Errorf("failed to run UI: %w", err)
 		}
 
@@ -323,28 +822,15 @@ var statusCmd = &cobra.Command{
 }
 
 var sshLoadCmd = &cobra.Command{
-	Use:   "load [profile]",
-	Short: "Load SSH key for a profile",
-	Long:  "Manually load the SSH key associated with a profile into the SSH agent",
-	Args:  cobra.ExactArgs(1),
-	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
-		manager, err := profile.NewManager()
-		if err != nil {
-			return nil, cobra.ShellCompDirectiveNoFileComp
-		}
-		profiles := manager.ListProfiles()
-		var names []string
-		for _, p := range profiles {
-			if p.SSHKeyPath != "" {
-				names = append(names, p.Name)
-			}
-		}
-		return names, cobra.ShellCompDirectiveNoFileComp
-	},
+	Use:               "load [profile]",
+	Short:             "Load SSH key for a profile",
+	Long:              "Manually load the SSH key associated with a profile into the SSH agent. When the key file no longer matches the fingerprint recorded for the profile, as after rotating the key in place, a warning is printed; 'gidtree profile refresh-fingerprints' records the new key.\n\nssh-add cannot read keys saved by PuTTY (.ppk files). With --convert, an unencrypted PuTTY key is converted to an OpenSSH key next to it, without the .ppk extension, and the profile is pointed at that before loading it.",
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeLoadableProfiles,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		profileName := args[0]
 
-		manager, err := profile.NewManager()
+		manager, err := profile.NewDefaultManager()
 		if err != nil {
 			return fmt.Errorf("failed to initialize profile manager: %w", err)
 		}
@@ -357,39 +843,36 @@ var sshLoadCmd = &cobra.Command{
 		if prof.SSHKeyPath == "" {
 			return fmt.Errorf("profile '%s' does not have an SSH key configured", profileName)
 		}
+		if sshLoadConvert {
+			if prof, err = convertProfileKey(os.Stdout, manager, prof); err != nil {
+				return fmt.Errorf("failed to convert SSH key: %w", err)
+			}
+		}
+		warnKeyRotated(os.Stderr, prof)
 
-		if err := ssh.LoadKeyForProfile(prof); err != nil {
+		if err := loadProfileKey(cmd, prof, sshLoadExclusive, sshLoadKeychain); errors.Is(err, profile.ErrPuTTYKey) {
+			return fmt.Errorf("failed to load SSH key: %w; or run: gidtree ssh load %s --convert", err, profileName)
+		} else if err != nil {
 			return fmt.Errorf("failed to load SSH key: %w", err)
 		}
 
-		fmt.Printf("✓ SSH key loaded for profile '%s'\n", profileName)
+		if !ssh.Offline {
+			fmt.Printf("✓ SSH key loaded for profile '%s'\n", profileName)
+		}
 		return nil
 	},
 }
 
 var sshUnloadCmd = &cobra.Command{
-	Use:   "unload [profile]",
-	Short: "Unload SSH key for a profile",
-	Long:  "Manually unload the SSH key associated with a profile from the SSH agent",
-	Args:  cobra.ExactArgs(1),
-	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
-		manager, err := profile.NewManager()
-		if err != nil {
-			return nil, cobra.ShellCompDirectiveNoFileComp
-		}
-		profiles := manager.ListProfiles()
-		var names []string
-		for _, p := range profiles {
-			if p.SSHKeyPath != "" {
-				names = append(names, p.Name)
-			}
-		}
-		return names, cobra.ShellCompDirectiveNoFileComp
-	},
+	Use:               "unload [profile]",
+	Short:             "Unload SSH key for a profile",
+	Long:              "Manually unload the SSH key associated with a profile from the SSH agent",
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeLoadableProfiles,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		profileName := args[0]
 
-		manager, err := profile.NewManager()
+		manager, err := profile.NewDefaultManager()
 		if err != nil {
 			return fmt.Errorf("failed to initialize profile manager: %w", err)
 		}
@@ -403,11 +886,18 @@ var sshUnloadCmd = &cobra.Command{
 			return fmt.Errorf("profile '%s' does not have an SSH key configured", profileName)
 		}
 
-		if err := ssh.UnloadKeyForProfile(prof); err != nil {
+		err = ssh.UnloadKeyForProfile(prof)
+		if errors.Is(err, ssh.ErrAgentReadOnly) {
+			fmt.Printf("- SSH key of profile '%s' left loaded: %v\n", profileName, err)
+			return nil
+		}
+		if err != nil {
 			return fmt.Errorf("failed to unload SSH key: %w", err)
 		}
 
-		fmt.Printf("✓ SSH key unloaded for profile '%s'\n", profileName)
+		if !ssh.Offline {
+			fmt.Printf("✓ SSH key unloaded for profile '%s'\n", profileName)
+		}
 		return nil
 	},
 }
@@ -419,86 +909,248 @@ var sshCmd = &cobra.Command{
 }
 
 var activateCmd = &cobra.Command{
-	Use:   "activate",
+	Use:   "activate [path]",
 	Short: "Auto-detect and activate profile for current directory",
-	Long:  "Automatically detect the current directory, find its mapped profile, and load the associated SSH key if needed",
+	Long: `Automatically detect the current directory, find its mapped profile, and load
+the associated SSH key if needed. Outside mapped directories the default profile
+applies, if one is set. Exits with status 5 when no profile applies.
+
+Given a path, activate works on it instead of the current directory, so editors
+and scripts need not change directory first. The path is normalized like the
+directory of 'gidtree map'; for a file, its directory is used.
+
+For shell prompts, --porcelain prints a single stable line
+
+  profile=<name> email=<email> key_loaded=<bool>
+
+and --format prints a Go template over the fields Name, Email, AuthorName,
+Source, SSHKeyPath and KeyLoaded, e.g. --format '{{.Name}}'. Both skip git and
+the SSH agent unless --load is given (key_loaded is false without it), print
+nothing else, and exit 0 with empty output when no profile applies.
+
+--quiet prints nothing on success: it loads the key, if the profile has one,
+and exits 0, also when no profile applies. Failures, such as a key that cannot
+be loaded, exit non-zero with the error on stderr only. --require-mapping
+exits with status 5 unless a mapping applies, in every mode; the default
+profile does not count.`,
+	Args: cobra.MaximumNArgs(1),
+	// The exit status tells shell hooks whether a profile applies
+	SilenceErrors: true,
+	SilenceUsage:  true,
+	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return nil, cobra.ShellCompDirectiveFilterDirs
+	},
 	RunE: func(cmd *cobra.Command, args []string) error {
-		currentDir, err := os.Getwd()
+		currentDir, err := activateDirectory(args)
 		if err != nil {
-			return fmt.Errorf("failed to get current directory: %w", err)
+			return err
 		}
-
-		m, err := mapping.GetMappingForDirectory(currentDir)
-		if err != nil {
-			return fmt.Errorf("failed to get mapping: %w", err)
+		if activateLoad && !activatePorcelain && activateFormat == "" {
+			return fmt.Errorf("--load only applies to --porcelain and --format")
 		}
-
-		if m == nil {
-			fmt.Println("No profile mapped for current directory")
-			return nil
+		if activatePorcelain || activateFormat != "" {
+			return activateMachineReadable(cmd, cmd.OutOrStdout(), currentDir, activateFormat, activateLoad, activateRequireMapping)
+		}
+		if activateQuiet {
+			return activateQuietly(cmd, currentDir, activateRequireMapping)
 		}
 
-		manager, err := profile.NewManager()
+		summary, err := identity.Summarize(currentDir)
 		if err != nil {
-			return fmt.Errorf("failed to initialize profile manager: %w", err)
+			return err
 		}
 
-		prof, err := manager.GetProfile(m.Profile)
-		if err != nil {
-			return fmt.Errorf("profile not found: %w", err)
+		w := cmd.OutOrStdout()
+		markUsed(summary.Profile)
+		if summary.Profile == nil || (activateRequireMapping && summary.Source != identity.SourceMapping) {
+			// Shell hooks can branch on the exit status
+			_, _ = fmt.Fprintln(w, "No profile mapped for current directory")
+			return &exitCodeError{code: exitMappingNotFound}
 		}
 
-		fmt.Printf("Active profile: %s\n", prof.Name)
-		fmt.Printf("Email: %s\n", prof.Email)
+		writeSummary(w, summary)
 
-		if prof.SSHKeyPath != "" {
-			if err := ssh.LoadKeyForProfile(prof); err != nil {
+		if summary.Profile.SSHKeyPath != "" {
+			if err := loadProfileKey(cmd, summary.Profile, activateExclusive, activateKeychain); err != nil {
 				return fmt.Errorf("failed to load SSH key: %w", err)
 			}
-			fmt.Printf("✓ SSH key loaded\n")
+			if !ssh.Offline {
+				_, _ = fmt.Fprintln(w, "✓ SSH key loaded")
+			}
 		}
 
 		return nil
 	},
 }
 
-var versionCmd = &cobra.Command{
-	Use:   "version",
-	Short: "Display the version of gidtree",
-	Long:  "Display the current version of the Git Identitree CLI",
-	Run: func(cmd *cobra.Command, args []string) {
-		fmt.Printf("gidtree version %s\n", version)
-	},
+// activateDirectory returns the directory activate works on: the path in
+// args, normalized as map does and with a file replaced by its directory, or
+// the current directory.
+func activateDirectory(args []string) (string, error) {
+	if len(args) == 0 {
+		dir, err := os.Getwd()
+		if err != nil {
+			return "", fmt.Errorf("failed to get current directory: %w", err)
+		}
+		return dir, nil
+	}
+	dir, err := utils.NormalizePath(args[0])
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve path: %w", err)
+	}
+	info, err := os.Stat(dir)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve path: %w", err)
+	}
+	if !info.IsDir() {
+		dir = filepath.Dir(dir)
+	}
+	return dir, nil
+}
+
+// writeSummary prints the facts of an identity summary as plain text.
+// The status view renders the same facts via ui.RenderSummary.
+func writeSummary(w io.Writer, s identity.Summary) {
+	_, _ = fmt.Fprintf(w, "Active profile: %s\n", s.Profile.Name)
+	for _, fact := range s.Facts() {
+		_, _ = fmt.Fprintf(w, "%s: %s\n", fact.Label, fact.Value)
+	}
 }
 
 func init() {
+	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "Log what gidtree reads, writes and runs to stderr")
+	profile.PassphraseSource = cli.NewPassphraseSource(os.Stdin, "Passphrase for profiles.yaml", false)
+
+	rootCmd.PersistentFlags().BoolVarP(&assumeYes, "yes", "y", false, "Answer yes to confirmation prompts, for non-interactive use")
+	rootCmd.PersistentFlags().BoolVar(&noColor, "no-color", false, "Disable colors; also set by a non-empty NO_COLOR environment variable")
+	rootCmd.PersistentFlags().StringVar(&homeFlag, "home", "", "Use this directory as the home directory instead of $HOME, e.g. in containers and services")
+	rootCmd.PersistentFlags().StringVar(&gitConfigFlag, "gitconfig", "", "Manage includeIf blocks in this file instead of ~/.gitconfig; include it from your git config yourself")
+	rootCmd.PersistentFlags().BoolVar(&abbreviateHomeJSON, "abbreviate-home", false, "Write paths under the home directory as ~ in JSON output")
+	rootCmd.PersistentFlags().BoolVar(&dryRun, "dry-run", false, "Show what would change without changing anything (apply, migrate-includes, completion install)")
+	initCmd.Flags().BoolVar(&initEncrypt, "encrypt", false, "Encrypt profiles.yaml with a passphrase")
+	profileCreateCmd.Flags().StringVar(&profileCreateTemplate, "template", "", "Create the profile from a template file or URL")
+	profileCreateCmd.Flags().StringVar(&profileCreateName, "name", "", "Name of the new profile, filled into the form")
+	profileCreateCmd.Flags().StringVar(&profileCreateGitHub, "github", "", "Use the noreply email address of this GitHub account")
+	profileCreateCmd.Flags().StringVar(&profileCreateGitHub, "github-noreply", "", "Same as --github")
+	profileUpdateCmd.Flags().BoolVar(&profileUpdateForce, "force", false, "Save even if profiles.yaml changed since it was read, overwriting that change")
+	profileListCmd.Flags().StringVar(&profileListSort, "sort", string(profile.SortByName), "Order profiles by name, created or email")
+	_ = profileListCmd.RegisterFlagCompletionFunc("sort", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		var keys []string
+		for _, key := range profile.SortKeys {
+			keys = append(keys, string(key))
+		}
+		return keys, cobra.ShellCompDirectiveNoFileComp
+	})
+	profileListCmd.Flags().StringVar(&profileListUnusedSince, "unused-since", "", "List only profiles not used for this long, such as 180d, 26w or 1y")
+	profileDeleteCmd.Flags().BoolVar(&profileDeleteForce, "force", false, "Delete even if profiles.yaml changed since it was read, overwriting that change")
+	mapCmd.Flags().BoolVarP(&mapQuiet, "quiet", "q", false, "Print only failures and the summary when mapping several directories; don't warn when a directory holds no git repositories")
+	mapCmd.Flags().BoolVar(&mapCreate, "create", false, "Create the directory if it does not exist")
+	mapCmd.Flags().BoolVar(&mapAllowMissing, "allow-missing", false, "Map the directory even if it does not exist")
+	mapCmd.MarkFlagsMutuallyExclusive("create", "allow-missing")
+	mapCmd.Flags().BoolVar(&mapForce, "force", false, "Replace an existing mapping of the same directory")
+	mapCmd.Flags().BoolVar(&mapAllowBroad, "allow-broad", false, "Map the home directory or the filesystem root, which every repository below inherits")
+	mapCmd.Flags().StringVar(&mapNote, "note", "", "Remember why the directory is mapped; shown by 'gidtree status'")
+	mapCmd.Flags().BoolVar(&mapCaseSensitive, "case-sensitive", false, "Match the directory case-sensitively (gitdir: instead of gitdir/i:); default from case_sensitive_gitdir")
+	unmapCmd.Flags().StringVar(&unmapProfileName, "profile", "", "Unmap every directory mapped to this profile")
+	unmapCmd.Flags().BoolVarP(&unmapQuiet, "quiet", "q", false, "With --profile, print only failures and the summary")
+	sshLoadCmd.Flags().BoolVar(&sshLoadExclusive, "exclusive", false, "Unload other profiles' keys first; default from exclusive_keys")
+	sshLoadCmd.Flags().BoolVar(&sshLoadKeychain, "keychain", false, "Store the passphrase in the macOS keychain; default from use_keychain")
+	activateCmd.Flags().BoolVar(&activateExclusive, "exclusive", false, "Unload other profiles' keys first; default from exclusive_keys")
+	activateCmd.Flags().BoolVar(&activateKeychain, "keychain", false, "Store the passphrase in the macOS keychain; default from use_keychain")
+	_ = unmapCmd.RegisterFlagCompletionFunc("profile", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		manager, err := profile.NewDefaultManager()
+		if err != nil {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		var names []string
+		for _, p := range manager.ListProfiles() {
+			names = append(names, p.Name)
+		}
+		return names, cobra.ShellCompDirectiveNoFileComp
+	})
+
 	// Profile subcommands
 	profileCmd.AddCommand(profileCreateCmd)
 	profileCmd.AddCommand(profileListCmd)
+	profileCmd.AddCommand(profileShowCmd)
 	profileCmd.AddCommand(profileUpdateCmd)
 	profileCmd.AddCommand(profileDeleteCmd)
+	profileCmd.AddCommand(profileRenameCmd)
+	profileCmd.AddCommand(profileFindCmd)
+	profileCmd.AddCommand(profileCloneCmd)
+	profileCmd.AddCommand(profileDefaultCmd)
+	profileCmd.AddCommand(profileDecryptStoreCmd)
 
 	// SSH subcommands
 	sshCmd.AddCommand(sshLoadCmd)
 	sshCmd.AddCommand(sshUnloadCmd)
+	sshCmd.AddCommand(sshStatusCmd)
 
 	// Root commands
 	rootCmd.AddCommand(initCmd)
 	rootCmd.AddCommand(profileCmd)
 	rootCmd.AddCommand(mapCmd)
 	rootCmd.AddCommand(unmapCmd)
+	rootCmd.AddCommand(mvCmd)
+	rootCmd.AddCommand(pinCmd)
+	rootCmd.AddCommand(unpinCmd)
+	rootCmd.AddCommand(defaultCmd)
+	rootCmd.AddCommand(mappingsCmd)
 	rootCmd.AddCommand(statusCmd)
 	rootCmd.AddCommand(sshCmd)
 	rootCmd.AddCommand(activateCmd)
+	rootCmd.AddCommand(auditCmd)
+	rootCmd.AddCommand(guardCmd)
+	rootCmd.AddCommand(hooksCmd)
+	rootCmd.AddCommand(checkIdentityCmd)
+	rootCmd.AddCommand(pairCmd)
+	rootCmd.AddCommand(signersCmd)
+	rootCmd.AddCommand(githubCmd)
+	rootCmd.AddCommand(internalCmd)
+	rootCmd.AddCommand(resolveCmd)
+	rootCmd.AddCommand(explainIdentityCmd)
+	rootCmd.AddCommand(suggestCmd)
+	rootCmd.AddCommand(repoCloneCmd)
+	rootCmd.AddCommand(execCmd)
+	rootCmd.AddCommand(envCmd)
+	rootCmd.AddCommand(withCmd)
+	rootCmd.AddCommand(configCmd)
+	rootCmd.AddCommand(syncCmd)
+	rootCmd.AddCommand(applyCmd)
+	rootCmd.AddCommand(exportStateCmd)
+	rootCmd.AddCommand(migrateIncludesCmd)
+	rootCmd.AddCommand(doctorCmd)
+	rootCmd.AddCommand(debugReportCmd)
 	rootCmd.AddCommand(versionCmd)
 
-	// Enable shell completion
+	// Enable shell completion. The completion command is created now rather
+	// than when the root command runs, so install can be added below it.
 	rootCmd.CompletionOptions.DisableDefaultCmd = false
+	rootCmd.InitDefaultCompletionCmd()
+	for _, c := range rootCmd.Commands() {
+		if c.Name() == "completion" {
+			c.AddCommand(completionInstallCmd)
+		}
+	}
 }
 
 func main() {
-	if err := rootCmd.Execute(); err != nil {
-		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		os.Exit(1)
+	if code := execute(os.Stderr); code != 0 {
+		os.Exit(code)
+	}
+}
+
+// execute runs the root command, reports its error on stderr and returns the
+// exit status.
+func execute(stderr io.Writer) int {
+	err := rootCmd.Execute()
+	recordUsage()
+	if err != nil {
+		// Commands that choose their exit status have already reported why
+		var exitErr *exitCodeError
+		if !errors.As(err, &exitErr) {
+			_, _ = fmt.Fprintf(stderr, "Error: %v\n", err)
+		}
 	}
+	return exitStatus(err)
 }
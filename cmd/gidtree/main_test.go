@@ -2,14 +2,23 @@ package main
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"io"
+	"log/slog"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
 
+	"github.com/thuanlegit/git-identitree/internal/cli"
+	"github.com/thuanlegit/git-identitree/internal/identity"
+	"github.com/thuanlegit/git-identitree/internal/logging"
 	"github.com/thuanlegit/git-identitree/internal/mapping"
 	"github.com/thuanlegit/git-identitree/internal/profile"
+	"github.com/thuanlegit/git-identitree/internal/ui"
+	"github.com/thuanlegit/git-identitree/internal/utils"
+	"github.com/thuanlegit/git-identitree/pkg/gidtree/gidtreetest"
 )
 
 func setupCLITestEnv(t *testing.T) (string, func()) {
@@ -27,41 +36,12 @@ func setupCLITestEnv(t *testing.T) (string, func()) {
 		t.Logf("Warning: Failed to resolve tmpDir symlinks: %v", err)
 	}
 
-	// Override home directory for testing
-	// On Windows, os.UserHomeDir() uses USERPROFILE, HOMEDRIVE+HOMEPATH, or HOME
-	// We need to override all of them to ensure tests work correctly
-	originalHome := os.Getenv("HOME")
-	originalUserProfile := os.Getenv("USERPROFILE")
-	originalHomeDrive := os.Getenv("HOMEDRIVE")
-	originalHomePath := os.Getenv("HOMEPATH")
-
-	if err := os.Setenv("HOME", tmpDir); err != nil {
-		t.Fatalf("Failed to set HOME: %v", err)
-	}
-	if err := os.Setenv("USERPROFILE", tmpDir); err != nil {
-		t.Fatalf("Failed to set USERPROFILE: %v", err)
-	}
-	// Clear HOMEDRIVE and HOMEPATH to prevent them from interfering
-	if err := os.Setenv("HOMEDRIVE", ""); err != nil {
-		t.Fatalf("Failed to clear HOMEDRIVE: %v", err)
-	}
-	if err := os.Setenv("HOMEPATH", ""); err != nil {
-		t.Fatalf("Failed to clear HOMEPATH: %v", err)
-	}
+	// Point gidtree at the temporary directory instead of the home directory
+	// and keep the host's gidtree config out of the way
+	t.Setenv(utils.RootEnv, tmpDir)
+	t.Setenv("XDG_CONFIG_HOME", "")
 
 	cleanup := func() {
-		if err := os.Setenv("HOME", originalHome); err != nil {
-			t.Logf("Failed to restore HOME: %v", err)
-		}
-		if err := os.Setenv("USERPROFILE", originalUserProfile); err != nil {
-			t.Logf("Failed to restore USERPROFILE: %v", err)
-		}
-		if err := os.Setenv("HOMEDRIVE", originalHomeDrive); err != nil {
-			t.Logf("Failed to restore HOMEDRIVE: %v", err)
-		}
-		if err := os.Setenv("HOMEPATH", originalHomePath); err != nil {
-			t.Logf("Failed to restore HOMEPATH: %v", err)
-		}
 		if err := os.RemoveAll(tmpDir); err != nil {
 			t.Logf("Failed to remove temp directory: %v", err)
 		}
@@ -102,11 +82,94 @@ func TestInitCommand(t *testing.T) {
 	}
 }
 
-func TestProfileCreateCommand(t *testing.T) {
-	// Note: This test would require mocking the Huh form, which is complex
-	// For now, we'll test the profile management logic separately
-	// Integration test would require user interaction simulation
-	t.Skip("Skipping interactive profile create test - requires form mocking")
+func TestCreateProfile_ReopensForm(t *testing.T) {
+	gidtreetest.NewEnv(t).
+		WithProfile(profile.Profile{Name: "work", Email: "work@example.com"}).
+		Build()
+	manager, err := profile.NewDefaultManager()
+	if err != nil {
+		t.Fatalf("NewDefaultManager() error = %v", err)
+	}
+
+	// The first answer takes a name in use; the second fixes only the name
+	var seen []profile.Profile
+	answers := []profile.Profile{
+		{Name: "Work", Email: "jane@example.com", AuthorName: "Jane"},
+		{Name: "personal", Email: "jane@example.com", AuthorName: "Jane"},
+	}
+	orig := createProfileForm
+	defer func() { createProfileForm = orig }()
+	createProfileForm = func(initial profile.Profile) (*profile.Profile, error) {
+		seen = append(seen, initial)
+		prof := answers[len(seen)-1]
+		return &prof, nil
+	}
+
+	var out bytes.Buffer
+	prof, err := createProfile(&out, manager, profile.Profile{})
+	if err != nil {
+		t.Fatalf("createProfile() error = %v", err)
+	}
+	if prof.Name != "personal" {
+		t.Errorf("createProfile() = %+v, want personal", prof)
+	}
+	if len(seen) != 2 || seen[0].Name != "" || seen[1].Name != "Work" || seen[1].AuthorName != "Jane" {
+		t.Errorf("form opened with %+v, want it empty and then with the rejected answers", seen)
+	}
+	if !strings.Contains(out.String(), "✗") || !strings.Contains(out.String(), "already exists") {
+		t.Errorf("output = %q, want the rejection", out.String())
+	}
+
+	// Cancelling on the summary creates nothing
+	createProfileForm = func(profile.Profile) (*profile.Profile, error) {
+		return nil, ui.ErrCancelled
+	}
+	if err := profileCreateCmd.RunE(profileCreateCmd, nil); err != nil {
+		t.Errorf("profile create cancelled error = %v, want none", err)
+	}
+	if got := len(manager.ListProfiles()); got != 2 {
+		t.Errorf("ListProfiles() = %d profiles, want 2", got)
+	}
+
+	// Leaving the form gives up
+	createProfileForm = func(profile.Profile) (*profile.Profile, error) {
+		return nil, errors.New("user aborted")
+	}
+	if _, err := createProfile(&out, manager, profile.Profile{}); err == nil {
+		t.Error("createProfile() should fail when the form is left")
+	}
+}
+
+func TestProfileCreateCommand_Template(t *testing.T) {
+	env := gidtreetest.NewEnv(t).Build()
+
+	setFlag(t, profileCreateCmd, "template", env.Path("missing.yaml"))
+	if err := profileCreateCmd.RunE(profileCreateCmd, nil); err == nil || !strings.Contains(err.Error(), "failed to read template") {
+		t.Errorf("profile create with a missing template error = %v", err)
+	}
+
+	path := env.Path("ci.yaml")
+	tmpl := "name: ci\nemail: ci@corp.com\nauthor_name: CI Bot\nssh_key_path: ~/.ssh/id_ci\ngpg_key_id: ABC123\n"
+	if err := os.WriteFile(path, []byte(tmpl), 0644); err != nil {
+		t.Fatalf("Failed to write template: %v", err)
+	}
+	if err := os.MkdirAll(env.Path(".ssh"), 0700); err != nil {
+		t.Fatalf("Failed to create .ssh: %v", err)
+	}
+	if err := os.WriteFile(env.Path(".ssh/id_ci"), []byte("key"), 0600); err != nil {
+		t.Fatalf("Failed to write key: %v", err)
+	}
+	setFlag(t, profileCreateCmd, "template", path)
+	if err := profileCreateCmd.RunE(profileCreateCmd, nil); err != nil {
+		t.Fatalf("profile create from a fully pinned template error = %v", err)
+	}
+	manager, err := profile.NewDefaultManager()
+	if err != nil {
+		t.Fatalf("NewDefaultManager() error = %v", err)
+	}
+	if prof, err := manager.GetProfile("ci"); err != nil || prof.Email != "ci@corp.com" {
+		t.Errorf("GetProfile(ci) = %+v, %v", prof, err)
+	}
 }
 
 func TestProfileListCommand(t *testing.T) {
@@ -120,9 +183,9 @@ func TestProfileListCommand(t *testing.T) {
 	}
 
 	// Create a profile directly
-	manager, err := profile.NewManager()
+	manager, err := profile.NewDefaultManager()
 	if err != nil {
-		t.Fatalf("NewManager() error = %v", err)
+		t.Fatalf("NewDefaultManager() error = %v", err)
 	}
 
 	testProfile := profile.Profile{
@@ -153,9 +216,9 @@ func TestProfileDeleteCommand(t *testing.T) {
 	}
 
 	// Create a profile
-	manager, err := profile.NewManager()
+	manager, err := profile.NewDefaultManager()
 	if err != nil {
-		t.Fatalf("NewManager() error = %v", err)
+		t.Fatalf("NewDefaultManager() error = %v", err)
 	}
 
 	testProfile := profile.Profile{
@@ -194,9 +257,9 @@ func TestMapCommand(t *testing.T) {
 	}
 
 	// Create a profile
-	manager, err := profile.NewManager()
+	manager, err := profile.NewDefaultManager()
 	if err != nil {
-		t.Fatalf("NewManager() error = %v", err)
+		t.Fatalf("NewDefaultManager() error = %v", err)
 	}
 
 	testProfile := profile.Profile{
@@ -250,9 +313,9 @@ func TestUnmapCommand(t *testing.T) {
 	}
 
 	// Create a profile and map it
-	manager, err := profile.NewManager()
+	manager, err := profile.NewDefaultManager()
 	if err != nil {
-		t.Fatalf("NewManager() error = %v", err)
+		t.Fatalf("NewDefaultManager() error = %v", err)
 	}
 
 	testProfile := profile.Profile{
@@ -305,9 +368,9 @@ func TestStatusCommand(t *testing.T) {
 	}
 
 	// Create a profile and map it
-	manager, err := profile.NewManager()
+	manager, err := profile.NewDefaultManager()
 	if err != nil {
-		t.Fatalf("NewManager() error = %v", err)
+		t.Fatalf("NewDefaultManager() error = %v", err)
 	}
 
 	testProfile := profile.Profile{
@@ -355,9 +418,9 @@ func TestActivateCommand(t *testing.T) {
 	}
 
 	// Create a profile and map it
-	manager, err := profile.NewManager()
+	manager, err := profile.NewDefaultManager()
 	if err != nil {
-		t.Fatalf("NewManager() error = %v", err)
+		t.Fatalf("NewDefaultManager() error = %v", err)
 	}
 
 	testProfile := profile.Profile{
@@ -423,9 +486,9 @@ func TestProfileDeleteWithMapping(t *testing.T) {
 	}
 
 	// Create a profile and map it
-	manager, err := profile.NewManager()
+	manager, err := profile.NewDefaultManager()
 	if err != nil {
-		t.Fatalf("NewManager() error = %v", err)
+		t.Fatalf("NewDefaultManager() error = %v", err)
 	}
 
 	testProfile := profile.Profile{
@@ -495,8 +558,7 @@ func TestGenerateProfileConfig_Content(t *testing.T) {
 	}
 
 	// Verify profile config was created
-	home := os.Getenv("HOME")
-	configPath := filepath.Join(home, ".gitconfig-test")
+	configPath := filepath.Join(tmpDir, ".gitconfig-test")
 	content, err := os.ReadFile(configPath)
 	if err != nil {
 		t.Fatalf("Failed to read profile config: %v", err)
@@ -507,7 +569,7 @@ func TestGenerateProfileConfig_Content(t *testing.T) {
 		"name = test",
 		"email = test@example.com",
 		"signingkey = ABC123",
-		"sshCommand = ssh -i /path/to/key",
+		"sshCommand = ssh -i '/path/to/key' -o IdentitiesOnly=yes\n",
 	}
 
 	for _, check := range checks {
@@ -556,7 +618,9 @@ func TestVersionCommand(t *testing.T) {
 			os.Stdout = w
 
 			// Execute version command
-			versionCmd.Run(versionCmd, []string{})
+			if err := versionCmd.RunE(versionCmd, []string{}); err != nil {
+				t.Fatalf("version error = %v", err)
+			}
 
 			// Restore stdout and read captured output
 			if err := w.Close(); err != nil {
@@ -570,7 +634,8 @@ func TestVersionCommand(t *testing.T) {
 				t.Fatalf("Failed to read output: %v", err)
 			}
 
-			output := strings.TrimSpace(buf.String())
+			// The first line stays stable for scripts; build metadata follows
+			output, _, _ := strings.Cut(buf.String(), "\n")
 
 			// Verify output
 			if output != tt.expectedOutput {
@@ -621,9 +686,9 @@ func TestProfileUpdateCommand(t *testing.T) {
 	}
 
 	// Create a profile first
-	manager, err := profile.NewManager()
+	manager, err := profile.NewDefaultManager()
 	if err != nil {
-		t.Fatalf("NewManager() error = %v", err)
+		t.Fatalf("NewDefaultManager() error = %v", err)
 	}
 
 	// Create a test SSH key file
@@ -699,9 +764,9 @@ func TestProfileUpdateCommand_NonExistent(t *testing.T) {
 		t.Fatalf("initCmd.Execute() error = %v", err)
 	}
 
-	manager, err := profile.NewManager()
+	manager, err := profile.NewDefaultManager()
 	if err != nil {
-		t.Fatalf("NewManager() error = %v", err)
+		t.Fatalf("NewDefaultManager() error = %v", err)
 	}
 
 	// Try to update non-existent profile
@@ -736,9 +801,9 @@ func TestProfileUpdateCommand_SSHKeyValidation(t *testing.T) {
 		t.Fatalf("initCmd.Execute() error = %v", err)
 	}
 
-	manager, err := profile.NewManager()
+	manager, err := profile.NewDefaultManager()
 	if err != nil {
-		t.Fatalf("NewManager() error = %v", err)
+		t.Fatalf("NewDefaultManager() error = %v", err)
 	}
 
 	// Create a profile without SSH key
@@ -764,3 +829,477 @@ func TestProfileUpdateCommand_SSHKeyValidation(t *testing.T) {
 	}
 }
 
+
+func TestActivateAndStatusRenderSameFacts(t *testing.T) {
+	withAll := &profile.Profile{
+		Name:       "work",
+		Email:      "work@example.com",
+		AuthorName: "Jane Doe",
+		SSHKeyPath: "~/.ssh/id_work",
+		GPGKeyID:   "ABC123",
+	}
+	minimal := &profile.Profile{
+		Name:  "personal",
+		Email: "me@example.com",
+	}
+
+	tests := []struct {
+		name    string
+		summary identity.Summary
+	}{
+		{
+			name: "all fields",
+			summary: identity.Summary{
+				Profile:         withAll,
+				Source:          identity.SourceMapping,
+				MappedDirectory: "/src/work/",
+				KeyState:        identity.KeyLoaded,
+				Signing:         "gpg",
+			},
+		},
+		{
+			name: "minimal profile",
+			summary: identity.Summary{
+				Profile:         minimal,
+				Source:          identity.SourceMapping,
+				MappedDirectory: "/src/personal/",
+				KeyState:        identity.KeyNone,
+				Signing:         "none",
+			},
+		},
+		{
+			name: "local override",
+			summary: identity.Summary{
+				Profile:         withAll,
+				Source:          identity.SourceMapping,
+				MappedDirectory: "/src/work/",
+				KeyState:        identity.KeyNotLoaded,
+				Signing:         "gpg",
+				LocalEmail:      "other@example.com",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			writeSummary(&buf, tt.summary)
+			activateOutput := buf.String()
+			statusOutput := ui.RenderSummary(tt.summary)
+
+			for _, surface := range []string{activateOutput, statusOutput} {
+				if !strings.Contains(surface, tt.summary.Profile.Name) {
+					t.Errorf("output missing profile name %q:\n%s", tt.summary.Profile.Name, surface)
+				}
+			}
+
+			for _, fact := range tt.summary.Facts() {
+				if !strings.Contains(activateOutput, fact.Label+": "+fact.Value) {
+					t.Errorf("activate output missing fact %s=%q:\n%s", fact.Label, fact.Value, activateOutput)
+				}
+				if !strings.Contains(statusOutput, fact.Label+": "+fact.Value) {
+					t.Errorf("status output missing fact %s=%q:\n%s", fact.Label, fact.Value, statusOutput)
+				}
+			}
+		})
+	}
+}
+
+func TestDefaultCommand(t *testing.T) {
+	tmpDir, cleanup := setupCLITestEnv(t)
+	defer cleanup()
+
+	manager, err := profile.NewDefaultManager()
+	if err != nil {
+		t.Fatalf("NewDefaultManager() error = %v", err)
+	}
+	if err := manager.AddProfile(profile.Profile{Name: "personal", Email: "me@example.com", AuthorName: "Jane Doe"}); err != nil {
+		t.Fatalf("AddProfile() error = %v", err)
+	}
+
+	if err := defaultCmd.RunE(defaultCmd, []string{"personal"}); err != nil {
+		t.Fatalf("defaultCmd.RunE() error = %v", err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(tmpDir, ".gitconfig"))
+	if err != nil {
+		t.Fatalf("Failed to read git config: %v", err)
+	}
+	if !strings.Contains(string(content), "name = Jane Doe") || !strings.Contains(string(content), "email = me@example.com") {
+		t.Errorf("git config missing default identity:\n%s", content)
+	}
+
+	if err := defaultCmd.RunE(defaultCmd, []string{"missing"}); err == nil {
+		t.Error("defaultCmd.RunE() should fail for non-existent profile")
+	}
+}
+
+func TestWarnCloudSynced(t *testing.T) {
+	tmpDir, cleanup := setupCLITestEnv(t)
+	defer cleanup()
+
+	plain := filepath.Join(tmpDir, "code", "project")
+	synced := filepath.Join(tmpDir, "Sync", "project")
+	for _, dir := range []string{plain, synced} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+	}
+	// Dropbox marks its root with a .dropbox file
+	if err := os.WriteFile(filepath.Join(tmpDir, "Sync", ".dropbox"), []byte("{}"), 0644); err != nil {
+		t.Fatalf("Failed to write marker: %v", err)
+	}
+
+	var out bytes.Buffer
+	warnCloudSynced(&out, plain)
+	if out.Len() != 0 {
+		t.Errorf("warnCloudSynced() should stay quiet outside synced folders, got %q", out.String())
+	}
+
+	warnCloudSynced(&out, synced)
+	if !strings.Contains(out.String(), "Dropbox") {
+		t.Errorf("warnCloudSynced() = %q, want Dropbox warning", out.String())
+	}
+
+	// The warning is shown only once
+	out.Reset()
+	warnCloudSynced(&out, synced)
+	if out.Len() != 0 {
+		t.Errorf("warnCloudSynced() second call = %q, want no output", out.String())
+	}
+}
+
+func TestRunListAction(t *testing.T) {
+	env := gidtreetest.NewEnv(t).
+		WithProfile(profile.Profile{Name: "work", Email: "work@example.com"}).
+		Build()
+	prof, _ := env.Client().Profile("work")
+
+	if err := runListAction(ui.ListActionNone, nil); err != nil {
+		t.Errorf("runListAction(none) error = %v", err)
+	}
+
+	// Delete goes through the delete command; the profile has no mappings so no prompt
+	if err := runListAction(ui.ListActionDelete, prof); err != nil {
+		t.Fatalf("runListAction(delete) error = %v", err)
+	}
+	manager, _ := profile.NewDefaultManager()
+	if _, err := manager.GetProfile("work"); err == nil {
+		t.Error("runListAction(delete) should delete the profile")
+	}
+}
+
+func TestUnmapCommand_Profile(t *testing.T) {
+	env := gidtreetest.NewEnv(t).
+		WithProfile(profile.Profile{Name: "work", Email: "work@example.com"}).
+		WithProfile(profile.Profile{Name: "personal", Email: "me@example.com"}).
+		WithMapping("work", "code/a").
+		WithMapping("work", "code/b").
+		WithMapping("personal", "code/home").
+		Build()
+
+	unmapProfileName = "work"
+	defer func() { unmapProfileName = "" }()
+
+	if err := unmapCmd.Args(unmapCmd, []string{env.Path("code/a")}); err == nil {
+		t.Error("unmap --profile should not accept a directory argument")
+	}
+
+	// Test stdin is not a terminal, so without --yes the command fails fast
+	if err := unmapCmd.RunE(unmapCmd, nil); !errors.Is(err, cli.ErrNoConfirmation) {
+		t.Fatalf("unmap --profile without --yes error = %v, want ErrNoConfirmation", err)
+	}
+	if dirs, _ := mapping.GetDirectoriesForProfile("work"); len(dirs) != 2 {
+		t.Fatalf("mappings changed without confirmation: %v", dirs)
+	}
+
+	assumeYes = true
+	defer func() { assumeYes = false }()
+
+	var out bytes.Buffer
+	if err := unmapProfile(&out, "work", false, confirmer()); err != nil {
+		t.Fatalf("unmap --profile --yes error = %v", err)
+	}
+	if !strings.Contains(out.String(), "✓ "+env.Path("code/a")) || !strings.HasSuffix(out.String(), "\n2 unmapped\n") {
+		t.Errorf("unmap --profile output = %q", out.String())
+	}
+	if dirs, _ := mapping.GetDirectoriesForProfile("work"); len(dirs) != 0 {
+		t.Errorf("work should have no mappings left, got %v", dirs)
+	}
+	if dirs, _ := mapping.GetDirectoriesForProfile("personal"); len(dirs) != 1 {
+		t.Errorf("other profiles' mappings should be kept, got %v", dirs)
+	}
+
+	if err := unmapCmd.RunE(unmapCmd, nil); err == nil {
+		t.Error("unmap --profile should fail when the profile has no mappings")
+	}
+}
+
+func TestUnmapCommand_EmptyPath(t *testing.T) {
+	env := gidtreetest.NewEnv(t).
+		WithProfile(profile.Profile{Name: "work", Email: "work@example.com"}).
+		WithMapping("work", "code/a").
+		Build()
+	t.Chdir(env.Path("code/a"))
+
+	// An empty argument must not stand for the working directory
+	err := unmapCmd.RunE(unmapCmd, []string{""})
+	if !errors.Is(err, utils.ErrEmptyPath) || !strings.Contains(err.Error(), "path must not be empty") {
+		t.Errorf("unmap \"\" error = %v, want ErrEmptyPath", err)
+	}
+	if dirs, _ := mapping.GetDirectoriesForProfile("work"); len(dirs) != 1 {
+		t.Errorf("unmap \"\" changed the mappings: %v", dirs)
+	}
+}
+
+func TestUnmapProfile_Declined(t *testing.T) {
+	gidtreetest.NewEnv(t).
+		WithProfile(profile.Profile{Name: "work", Email: "work@example.com"}).
+		WithMapping("work", "code/a").
+		Build()
+
+	var out bytes.Buffer
+	decline := func(string, string) (bool, error) { return false, nil }
+	if err := unmapProfile(&out, "work", false, decline); err != nil {
+		t.Fatalf("unmapProfile() error = %v", err)
+	}
+	if !strings.Contains(out.String(), "cancelled") {
+		t.Errorf("unmapProfile() output = %q, want a cancellation notice", out.String())
+	}
+	if dirs, _ := mapping.GetDirectoriesForProfile("work"); len(dirs) != 1 {
+		t.Errorf("declined unmap should keep mappings, got %v", dirs)
+	}
+}
+
+func TestRootYesFlag(t *testing.T) {
+	flag := rootCmd.PersistentFlags().Lookup("yes")
+	if flag == nil || flag.Shorthand != "y" {
+		t.Fatal("rootCmd should have a persistent --yes/-y flag")
+	}
+	if profileDeleteCmd.InheritedFlags().Lookup("yes") == nil {
+		t.Error("--yes should be inherited by profile delete")
+	}
+}
+
+func TestRootVerboseFlag(t *testing.T) {
+	flag := rootCmd.PersistentFlags().Lookup("verbose")
+	if flag == nil || flag.Shorthand != "v" {
+		t.Fatal("rootCmd should have a persistent --verbose/-v flag")
+	}
+
+	_ = rootCmd.PersistentPreRunE(rootCmd, nil)
+	if logging.Logger().Enabled(context.Background(), slog.LevelDebug) {
+		t.Error("debug logging should stay off without --verbose")
+	}
+
+	verbose = true
+	defer func() {
+		verbose = false
+		logging.Disable()
+	}()
+	_ = rootCmd.PersistentPreRunE(rootCmd, nil)
+	if !logging.Logger().Enabled(context.Background(), slog.LevelDebug) {
+		t.Error("--verbose should enable debug logging")
+	}
+}
+
+func TestRootNoColorFlag(t *testing.T) {
+	if rootCmd.PersistentFlags().Lookup("no-color") == nil {
+		t.Fatal("rootCmd should have a persistent --no-color flag")
+	}
+	if statusCmd.InheritedFlags().Lookup("no-color") == nil {
+		t.Error("--no-color should be inherited by status")
+	}
+}
+
+func TestRootGitConfigFlag(t *testing.T) {
+	env := gidtreetest.NewEnv(t).
+		WithProfile(profile.Profile{Name: "work", Email: "work@example.com"}).
+		Build()
+
+	gitConfigFlag = env.Path("dotfiles/gidtree.gitconfig")
+	defer func() {
+		gitConfigFlag = ""
+		mapping.SetGitConfigPath("")
+	}()
+	_ = rootCmd.PersistentPreRunE(rootCmd, nil)
+
+	setFlag(t, mapCmd, "create", "true")
+	if err := mapCmd.RunE(mapCmd, []string{"work", env.Path("src")}); err != nil {
+		t.Fatalf("map error = %v", err)
+	}
+	data, err := os.ReadFile(gitConfigFlag)
+	if err != nil || !strings.Contains(string(data), "[includeIf") {
+		t.Errorf("--gitconfig file = %q, %v; want the includeIf block", data, err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(env.Home(), ".gitconfig")); strings.Contains(string(data), "[includeIf") {
+		t.Errorf("~/.gitconfig should be left alone with --gitconfig, got:\n%s", data)
+	}
+}
+
+func TestMapCommand_ForceAndOverlap(t *testing.T) {
+	env := gidtreetest.NewEnv(t).
+		WithProfile(profile.Profile{Name: "work", Email: "work@example.com"}).
+		WithProfile(profile.Profile{Name: "personal", Email: "me@example.com"}).
+		Build()
+
+	setFlag(t, mapCmd, "create", "true")
+	setFlag(t, mapCmd, "create-gitconfig", "true")
+	if err := mapCmd.RunE(mapCmd, []string{"work", env.Path("src")}); err != nil {
+		t.Fatalf("map work error = %v", err)
+	}
+	if err := mapCmd.RunE(mapCmd, []string{"personal", env.Path("src")}); !errors.Is(err, mapping.ErrDirectoryAlreadyMapped) {
+		t.Fatalf("map of a mapped directory error = %v, want ErrDirectoryAlreadyMapped", err)
+	}
+
+	setFlag(t, mapCmd, "force", "true")
+	if err := mapCmd.RunE(mapCmd, []string{"personal", env.Path("src")}); err != nil {
+		t.Fatalf("map --force error = %v", err)
+	}
+	m, err := mapping.GetMappingForDirectory(env.Path("src"))
+	if err != nil || m == nil || m.Profile != "personal" {
+		t.Fatalf("mapping after --force = %+v, %v; want personal", m, err)
+	}
+
+	var out bytes.Buffer
+	if err := warnOverlappingMappings(&out, env.Path("src/oss"), "work"); err != nil {
+		t.Fatalf("warnOverlappingMappings() error = %v", err)
+	}
+	if !strings.Contains(out.String(), "mapped to profile 'personal'") {
+		t.Errorf("warnOverlappingMappings() = %q, want a warning about personal", out.String())
+	}
+	out.Reset()
+	if err := warnOverlappingMappings(&out, env.Path("elsewhere"), "work"); err != nil || out.Len() != 0 {
+		t.Errorf("warnOverlappingMappings() for an unrelated directory = %q, %v", out.String(), err)
+	}
+}
+
+func TestWarnNoRepositories(t *testing.T) {
+	root := t.TempDir()
+	repo := filepath.Join(root, "src", "app")
+	empty := filepath.Join(root, "notes", "deep")
+	for _, dir := range []string{filepath.Join(repo, ".git"), filepath.Join(repo, "internal"), empty} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatalf("Failed to create %s: %v", dir, err)
+		}
+	}
+	t.Chdir(root)
+
+	var out bytes.Buffer
+	warnNoRepositories(&out, "work", filepath.Join(root, "src"))
+	warnNoRepositories(&out, "work", repo)
+	if out.Len() != 0 {
+		t.Errorf("warnNoRepositories() for directories holding repositories = %q", out.String())
+	}
+
+	warnNoRepositories(&out, "work", empty)
+	if got := out.String(); !strings.Contains(got, "not a git repository") || strings.Contains(got, "Did you mean") {
+		t.Errorf("warnNoRepositories() outside any repository = %q", got)
+	}
+
+	out.Reset()
+	t.Chdir(filepath.Join(repo, "internal"))
+	warnNoRepositories(&out, "work", filepath.Join(repo, "internal"))
+	if got := out.String(); !strings.Contains(got, "Did you mean: gidtree map work "+repo) {
+		t.Errorf("warnNoRepositories() inside a repository = %q, want the repository root suggested", got)
+	}
+}
+
+func TestMapDirectories(t *testing.T) {
+	env := gidtreetest.NewEnv(t).
+		WithProfile(profile.Profile{Name: "work", Email: "work@example.com"}).
+		WithMapping("work", "code/b").
+		WithGitRepo("code/a").
+		WithGitRepo("code/c").
+		Build()
+	manager, _ := profile.NewDefaultManager()
+	work, _ := manager.GetProfile("work")
+	dirs := []string{env.Path("code/a"), env.Path("code/b"), env.Path("code/missing"), env.Path("code/c")}
+
+	var out bytes.Buffer
+	err := mapDirectories(&out, work, dirs, mapping.MapOptions{})
+	if err == nil || !strings.Contains(err.Error(), "1 of 4 failed") {
+		t.Errorf("mapDirectories() error = %v, want one failure", err)
+	}
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	want := []string{
+		"✓ " + dirs[0],
+		"- " + dirs[1] + " (already mapped)",
+		"✗ " + dirs[2] + ": ",
+		"✓ " + dirs[3],
+		"2 mapped, 1 skipped (already mapped), 1 failed",
+	}
+	if len(lines) != len(want) {
+		t.Fatalf("mapDirectories() output =\n%s", out.String())
+	}
+	for i := range want {
+		if !strings.HasPrefix(lines[i], want[i]) {
+			t.Errorf("line %d = %q, want %q", i, lines[i], want[i])
+		}
+	}
+	// The failure does not stop the directories after it
+	if m, _ := mapping.GetMappingForDirectory(dirs[3]); m == nil || m.Profile != "work" {
+		t.Errorf("code/c mapping = %+v, want work", m)
+	}
+
+	t.Run("quiet", func(t *testing.T) {
+		mapQuiet = true
+		defer func() { mapQuiet = false }()
+		out.Reset()
+		if err := mapDirectories(&out, work, dirs[:2], mapping.MapOptions{}); err != nil {
+			t.Fatalf("mapDirectories() error = %v", err)
+		}
+		if out.String() != "0 mapped, 2 skipped (already mapped)\n" {
+			t.Errorf("quiet output = %q, want only the summary", out.String())
+		}
+	})
+}
+
+func TestMapCommand_MissingDirectory(t *testing.T) {
+	env := gidtreetest.NewEnv(t).
+		WithProfile(profile.Profile{Name: "work", Email: "work@example.com"}).
+		Build()
+
+	if err := mapCmd.RunE(mapCmd, []string{"work", env.Path("wrok")}); !errors.Is(err, mapping.ErrDirectoryNotFound) {
+		t.Fatalf("map of a missing directory error = %v, want ErrDirectoryNotFound", err)
+	}
+	if mappings, _ := mapping.ParseMappings(); len(mappings) != 0 {
+		t.Errorf("mappings after a rejected map = %+v", mappings)
+	}
+	setFlag(t, mapCmd, "create-gitconfig", "true")
+
+	t.Run("create", func(t *testing.T) {
+		setFlag(t, mapCmd, "create", "true")
+		if err := mapCmd.RunE(mapCmd, []string{"work", env.Path("new/work")}); err != nil {
+			t.Fatalf("map --create error = %v", err)
+		}
+		if info, err := os.Stat(env.Path("new/work")); err != nil || !info.IsDir() {
+			t.Errorf("map --create did not create the directory: %v", err)
+		}
+	})
+
+	t.Run("allow missing", func(t *testing.T) {
+		setFlag(t, mapCmd, "allow-missing", "true")
+		if err := mapCmd.RunE(mapCmd, []string{"work", env.Path("later")}); err != nil {
+			t.Fatalf("map --allow-missing error = %v", err)
+		}
+		if _, err := os.Stat(env.Path("later")); !os.IsNotExist(err) {
+			t.Errorf("map --allow-missing created the directory: %v", err)
+		}
+		if m, err := mapping.GetMappingForDirectory(env.Path("later")); err != nil || m == nil {
+			t.Errorf("GetMappingForDirectory() = %v, %v; want the mapping", m, err)
+		}
+	})
+
+	t.Run("both", func(t *testing.T) {
+		// Executing through the root command leaves the flags set
+		setFlag(t, mapCmd, "create", "false")
+		setFlag(t, mapCmd, "allow-missing", "false")
+		if code := runCLI(t, "map", "--create", "--allow-missing", "work", env.Path("both")); code == 0 {
+			t.Error("map --create --allow-missing should be rejected")
+		}
+		if _, err := os.Stat(env.Path("both")); !os.IsNotExist(err) {
+			t.Errorf("rejected map created the directory: %v", err)
+		}
+	})
+}
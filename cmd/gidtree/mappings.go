@@ -0,0 +1,145 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/thuanlegit/git-identitree/internal/mapping"
+	"github.com/thuanlegit/git-identitree/internal/profile"
+	"github.com/thuanlegit/git-identitree/internal/utils"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	exportOut         string
+	exportAnonymize   bool
+	exportRoot        string
+	exportSeed        int64
+	exportTranslation string
+	importTranslate   string
+)
+
+var mappingsExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export directory mappings",
+	Long:  "Write all directory mappings as YAML. With --anonymize, every path segment below --root is replaced by a stable pseudonym and the private translation is written to a separate file.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if exportAnonymize && exportTranslation == "" {
+			return fmt.Errorf("--anonymize requires --translation-out <file> to keep the private translation")
+		}
+
+		export, err := mapping.ExportMappings()
+		if err != nil {
+			return fmt.Errorf("failed to export mappings: %w", err)
+		}
+
+		if exportAnonymize {
+			seed := exportSeed
+			if !cmd.Flags().Changed("seed") {
+				seed = time.Now().UnixNano()
+			}
+			// Exported paths are ~-relative, so the root must be too
+			root := filepath.ToSlash(utils.AbbreviateHome(exportRoot))
+			var translation *mapping.Translation
+			export, translation = mapping.Anonymize(export, root, seed)
+			if err := writeYAML(exportTranslation, translation, 0600); err != nil {
+				return fmt.Errorf("failed to write translation file: %w", err)
+			}
+			_, _ = fmt.Fprintf(cmd.ErrOrStderr(), "Translation written to %s (keep it private)\n", exportTranslation)
+		}
+
+		if err := writeYAML(exportOut, export, 0644); err != nil {
+			return fmt.Errorf("failed to write mappings: %w", err)
+		}
+		return nil
+	},
+}
+
+var mappingsImportCmd = &cobra.Command{
+	Use:   "import [file]",
+	Short: "Import directory mappings",
+	Long:  "Create the mappings listed in an exported file. Directories that are already mapped are skipped. Use --translate with the private translation file to restore anonymized paths. Without ~/.gitconfig, it asks before creating one, as map does.",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var export mapping.ExportFile
+		if err := readYAML(args[0], &export); err != nil {
+			return fmt.Errorf("failed to read mappings file: %w", err)
+		}
+
+		if importTranslate != "" {
+			var translation mapping.Translation
+			if err := readYAML(importTranslate, &translation); err != nil {
+				return fmt.Errorf("failed to read translation file: %w", err)
+			}
+			translated, err := mapping.Translate(&export, &translation)
+			if err != nil {
+				return fmt.Errorf("failed to translate mappings: %w", err)
+			}
+			export = *translated
+		}
+
+		if len(export.Mappings) > 0 {
+			if err := checkGitConfig(confirmer()); err != nil {
+				return fmt.Errorf("failed to import mappings: %w", err)
+			}
+		}
+
+		manager, err := profile.NewDefaultManager()
+		if err != nil {
+			return fmt.Errorf("failed to initialize profile manager: %w", err)
+		}
+
+		created, err := mapping.ImportMappings(&export, manager.GetProfile)
+		if err != nil {
+			return fmt.Errorf("failed to import mappings: %w", err)
+		}
+
+		fmt.Printf("✓ Imported %d mappings (%d already present)\n", created, len(export.Mappings)-created)
+		return nil
+	},
+}
+
+var mappingsCmd = &cobra.Command{
+	Use:   "mappings",
+	Short: "Export and import directory mappings",
+	Long:  "Commands for sharing directory mappings between machines",
+}
+
+// writeYAML marshals v to path, or to stdout when path is empty or "-".
+func writeYAML(path string, v interface{}, perm os.FileMode) error {
+	data, err := yaml.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if path == "" || path == "-" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	return os.WriteFile(path, data, perm)
+}
+
+// readYAML unmarshals the YAML file at path into v.
+func readYAML(path string, v interface{}) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return yaml.Unmarshal(data, v)
+}
+
+func init() {
+	mappingsExportCmd.Flags().StringVarP(&exportOut, "out", "o", "", "Write the export to a file instead of stdout")
+	mappingsExportCmd.Flags().BoolVar(&exportAnonymize, "anonymize", false, "Replace path segments below --root with pseudonyms")
+	mappingsExportCmd.Flags().StringVar(&exportRoot, "root", "~", "Code root whose own path is kept when anonymizing")
+	mappingsExportCmd.Flags().Int64Var(&exportSeed, "seed", 0, "Seed for pseudonym assignment (default: random per export)")
+	mappingsExportCmd.Flags().StringVar(&exportTranslation, "translation-out", "", "Private file that receives the pseudonym translation")
+
+	mappingsImportCmd.Flags().StringVar(&importTranslate, "translate", "", "Translation file produced by an anonymized export")
+
+	mappingsCmd.AddCommand(mappingsExportCmd)
+	mappingsCmd.AddCommand(mappingsImportCmd)
+}
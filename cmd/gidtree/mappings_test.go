@@ -0,0 +1,83 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/thuanlegit/git-identitree/internal/mapping"
+	"github.com/thuanlegit/git-identitree/internal/profile"
+)
+
+func TestMappingsExportImportAnonymized(t *testing.T) {
+	tmpDir, cleanup := setupCLITestEnv(t)
+	defer cleanup()
+
+	manager, err := profile.NewDefaultManager()
+	if err != nil {
+		t.Fatalf("NewDefaultManager() error = %v", err)
+	}
+	prof := profile.Profile{Name: "work", Email: "work@example.com"}
+	if err := manager.AddProfile(prof); err != nil {
+		t.Fatalf("AddProfile() error = %v", err)
+	}
+	projectDir := filepath.Join(tmpDir, "code", "acme")
+	if err := os.MkdirAll(projectDir, 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	if err := mapping.MapProfileToDirectory(&prof, projectDir); err != nil {
+		t.Fatalf("MapProfileToDirectory() error = %v", err)
+	}
+	original, _ := mapping.ParseMappings()
+
+	exportPath := filepath.Join(tmpDir, "mappings.yaml")
+	translationPath := filepath.Join(tmpDir, "translation.yaml")
+	exportOut, exportAnonymize, exportRoot, exportTranslation = exportPath, true, filepath.Join(tmpDir, "code"), translationPath
+	defer func() {
+		exportOut, exportAnonymize, exportRoot, exportTranslation = "", false, "~", ""
+	}()
+	if err := mappingsExportCmd.RunE(mappingsExportCmd, nil); err != nil {
+		t.Fatalf("mappings export error = %v", err)
+	}
+
+	content, err := os.ReadFile(exportPath)
+	if err != nil {
+		t.Fatalf("Failed to read export: %v", err)
+	}
+	if strings.Contains(string(content), "acme") {
+		t.Errorf("anonymized export leaks directory name:\n%s", content)
+	}
+	if info, err := os.Stat(translationPath); err != nil {
+		t.Fatalf("translation file not written: %v", err)
+	} else if info.Mode().Perm()&0077 != 0 && os.PathSeparator == '/' {
+		t.Errorf("translation file mode = %v, want private", info.Mode().Perm())
+	}
+
+	if err := os.Remove(filepath.Join(tmpDir, ".gitconfig")); err != nil {
+		t.Fatalf("Failed to remove git config: %v", err)
+	}
+
+	importTranslate = translationPath
+	defer func() { importTranslate = "" }()
+	setFlag(t, mappingsImportCmd, "create-gitconfig", "true")
+	if err := mappingsImportCmd.RunE(mappingsImportCmd, []string{exportPath}); err != nil {
+		t.Fatalf("mappings import error = %v", err)
+	}
+
+	restored, _ := mapping.ParseMappings()
+	if len(restored) != 1 || restored[0].Directory != original[0].Directory || restored[0].Profile != "work" {
+		t.Errorf("restored mappings = %+v, want %+v", restored, original)
+	}
+}
+
+func TestMappingsExport_AnonymizeRequiresTranslation(t *testing.T) {
+	_, cleanup := setupCLITestEnv(t)
+	defer cleanup()
+
+	exportAnonymize = true
+	defer func() { exportAnonymize = false }()
+	if err := mappingsExportCmd.RunE(mappingsExportCmd, nil); err == nil {
+		t.Error("mappings export --anonymize should require --translation-out")
+	}
+}
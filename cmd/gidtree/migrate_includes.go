@@ -0,0 +1,69 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/thuanlegit/git-identitree/internal/config"
+	"github.com/thuanlegit/git-identitree/internal/mapping"
+	"github.com/thuanlegit/git-identitree/internal/utils"
+
+	"github.com/spf13/cobra"
+)
+
+var migrateIncludesCmd = &cobra.Command{
+	Use:   "migrate-includes",
+	Short: "Move gidtree's includeIf blocks out of ~/.gitconfig into ~/.gidtree/gitconfig",
+	Long: `Move the includeIf blocks gidtree wrote into ~/.gitconfig, with their
+metadata comments, to ~/.gidtree/gitconfig, add a single include of that file
+to ~/.gitconfig and turn managed_include on. From then on gidtree only edits
+~/.gidtree/gitconfig, except for the [user] section set by 'gidtree default'.
+
+Blocks gidtree did not write, such as onbranch or hasconfig conditions, stay in
+~/.gitconfig. ~/.gitconfig is backed up first. With --dry-run the blocks that
+would move are listed and nothing is changed.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if gitConfigFlag != "" {
+			return errors.New("--gitconfig already redirects the includeIf blocks; migrate-includes cannot be combined with it")
+		}
+		return migrateIncludes(cmd.OutOrStdout(), dryRun)
+	},
+}
+
+// migrateIncludes moves the blocks and turns managed_include on, reporting
+// each moved mapping to w.
+func migrateIncludes(w io.Writer, dry bool) error {
+	moved, err := mapping.MigrateIncludes(dry)
+	if err != nil {
+		return fmt.Errorf("failed to migrate includeIf blocks: %w", err)
+	}
+	managedPath, err := mapping.ManagedIncludePath()
+	if err != nil {
+		return err
+	}
+
+	verb := "Moved"
+	if dry {
+		verb = "Would move"
+	}
+	for _, m := range moved {
+		_, _ = fmt.Fprintf(w, "%s %s → %s\n", verb, m.Directory, m.Profile)
+	}
+	if dry {
+		_, _ = fmt.Fprintf(w, "%d block(s) would move to %s; run without --dry-run to migrate\n", len(moved), utils.AbbreviateHome(managedPath))
+		return nil
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	cfg.ManagedInclude = true
+	if err := config.Save(cfg); err != nil {
+		return fmt.Errorf("failed to save config: %w", err)
+	}
+	_, _ = fmt.Fprintf(w, "✓ Moved %d block(s) to %s; ~/.gitconfig includes it and managed_include is on\n", len(moved), utils.AbbreviateHome(managedPath))
+	return nil
+}
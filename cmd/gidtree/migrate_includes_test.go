@@ -0,0 +1,61 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/thuanlegit/git-identitree/internal/config"
+	"github.com/thuanlegit/git-identitree/internal/mapping"
+	"github.com/thuanlegit/git-identitree/internal/profile"
+	"github.com/thuanlegit/git-identitree/pkg/gidtree/gidtreetest"
+)
+
+func TestMigrateIncludesCommand(t *testing.T) {
+	env := gidtreetest.NewEnv(t).
+		WithProfile(profile.Profile{Name: "work", Email: "me@work.example"}).
+		WithMapping("work", "code/work").
+		Build()
+
+	var out bytes.Buffer
+	if err := migrateIncludes(&out, true); err != nil {
+		t.Fatalf("migrate-includes --dry-run error = %v", err)
+	}
+	if !strings.Contains(out.String(), "Would move") || !strings.Contains(out.String(), "1 block(s) would move") {
+		t.Errorf("dry run output = %q", out.String())
+	}
+	if cfg, _ := config.Load(); cfg.ManagedInclude {
+		t.Error("dry run should not turn managed_include on")
+	}
+
+	out.Reset()
+	if err := migrateIncludes(&out, false); err != nil {
+		t.Fatalf("migrate-includes error = %v", err)
+	}
+	if !strings.Contains(out.String(), "✓ Moved 1 block(s) to ~/.gidtree/gitconfig") {
+		t.Errorf("output = %q", out.String())
+	}
+	if cfg, _ := config.Load(); !cfg.ManagedInclude {
+		t.Error("migrate-includes should turn managed_include on")
+	}
+
+	data, _ := os.ReadFile(env.GitConfigPath())
+	if strings.Contains(string(data), "[includeIf") || !strings.Contains(string(data), "[include]") {
+		t.Errorf("~/.gitconfig after migration = %q", data)
+	}
+	m, err := mapping.GetMappingForDirectory(env.Path("code/work/repo"))
+	if err != nil || m == nil || m.Profile != "work" {
+		t.Errorf("mapping after migration = %+v, %v", m, err)
+	}
+}
+
+func TestMigrateIncludesCommand_RejectsGitConfigFlag(t *testing.T) {
+	gidtreetest.NewEnv(t).Build()
+	gitConfigFlag = "/tmp/elsewhere"
+	defer func() { gitConfigFlag = "" }()
+
+	if err := migrateIncludesCmd.RunE(migrateIncludesCmd, nil); err == nil || !strings.Contains(err.Error(), "--gitconfig") {
+		t.Errorf("migrate-includes with --gitconfig error = %v", err)
+	}
+}
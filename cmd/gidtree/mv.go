@@ -0,0 +1,110 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/thuanlegit/git-identitree/internal/cli"
+	"github.com/thuanlegit/git-identitree/internal/doctor"
+	"github.com/thuanlegit/git-identitree/internal/mapping"
+	"github.com/thuanlegit/git-identitree/internal/ui"
+	"github.com/thuanlegit/git-identitree/internal/utils"
+
+	"github.com/spf13/cobra"
+)
+
+var mvDetect bool
+
+var mvCmd = &cobra.Command{
+	Use:   "mv <old-dir> <new-dir>",
+	Short: "Point a mapping at a directory's new location",
+	Long: `Rewrite the includeIf condition of a mapped directory that was moved on disk,
+so the mapping follows it. The block keeps its profile, condition kind and
+note; the new directory must exist and must not be mapped already. The
+rewritten block is printed.
+
+With --detect, the mapped directories that no longer exist are listed and you
+are asked for the new location of each; an empty answer leaves it as it is.`,
+	Example: `  gidtree mv ~/src/acme ~/clients/acme
+  gidtree mv --detect`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if mvDetect {
+			return cobra.NoArgs(cmd, args)
+		}
+		return cobra.ExactArgs(2)(cmd, args)
+	},
+	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) == 0 && !mvDetect {
+			return completeMappedDirectories(cmd, args, toComplete)
+		}
+		return nil, cobra.ShellCompDirectiveFilterDirs
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if !mvDetect {
+			return moveMapping(cmd.OutOrStdout(), args[0], args[1])
+		}
+		if !cli.IsTerminal(os.Stdin) {
+			return errors.New("--detect asks for new locations and needs a terminal; use 'gidtree mv <old-dir> <new-dir>'")
+		}
+		return detectMoves(cmd.OutOrStdout(), func(m mapping.Mapping) (string, error) {
+			return ui.MoveDirectoryForm(utils.AbbreviateHome(m.Directory), m.Profile)
+		})
+	},
+}
+
+// moveMapping points the mapping of oldDir at newDir and prints the rewritten
+// block.
+func moveMapping(w io.Writer, oldDir, newDir string) error {
+	m, block, err := mapping.MoveMapping(oldDir, newDir)
+	if err != nil {
+		return fmt.Errorf("failed to move mapping: %w", err)
+	}
+	_, _ = fmt.Fprintf(w, "✓ Moved mapping of profile '%s' from %s to %s\n", m.Profile, oldDir, utils.AbbreviateHome(m.Directory))
+	_, _ = fmt.Fprint(w, block)
+	return nil
+}
+
+// locationPrompt asks where the directory of m has moved to. An empty answer
+// skips it.
+type locationPrompt func(m mapping.Mapping) (string, error)
+
+// detectMoves asks for the new location of every mapped directory that no
+// longer exists and moves the mappings that get one. A mapping that cannot be
+// moved is reported and skipped.
+func detectMoves(w io.Writer, ask locationPrompt) error {
+	missing, err := doctor.MissingDirectories()
+	if err != nil {
+		return fmt.Errorf("failed to check mapped directories: %w", err)
+	}
+	if len(missing) == 0 {
+		_, _ = fmt.Fprintln(w, "✓ Mapped directories exist")
+		return nil
+	}
+
+	moved, skipped := 0, 0
+	for _, m := range missing {
+		_, _ = fmt.Fprintf(w, "⚠ %s (profile '%s') no longer exists\n", utils.AbbreviateHome(m.Directory), m.Profile)
+		dir, err := ask(m)
+		if err != nil {
+			return fmt.Errorf("failed to read new location: %w", err)
+		}
+		if dir == "" {
+			skipped++
+			continue
+		}
+		if err := moveMapping(w, m.Directory, dir); err != nil {
+			_, _ = fmt.Fprintf(w, "✗ %v\n", err)
+			skipped++
+			continue
+		}
+		moved++
+	}
+	_, _ = fmt.Fprintf(w, "%d moved, %d left as they were\n", moved, skipped)
+	return nil
+}
+
+func init() {
+	mvCmd.Flags().BoolVar(&mvDetect, "detect", false, "Find mapped directories that no longer exist and ask where they went")
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/thuanlegit/git-identitree/internal/mapping"
+	"github.com/thuanlegit/git-identitree/internal/profile"
+	"github.com/thuanlegit/git-identitree/pkg/gidtree/gidtreetest"
+)
+
+// moveDir moves a directory of the test home, creating the new parent.
+func moveDir(t *testing.T, env *gidtreetest.Built, from, to string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(env.Path(to)), 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	if err := os.Rename(env.Path(from), env.Path(to)); err != nil {
+		t.Fatalf("Failed to move directory: %v", err)
+	}
+}
+
+// mappedProfile returns the profile mapped to dir, or "" when none is.
+func mappedProfile(t *testing.T, dir string) string {
+	t.Helper()
+	m, err := mapping.GetMappingForDirectory(dir)
+	if err != nil {
+		t.Fatalf("GetMappingForDirectory() error = %v", err)
+	}
+	if m == nil {
+		return ""
+	}
+	return m.Profile
+}
+
+func TestMoveMapping(t *testing.T) {
+	env := gidtreetest.NewEnv(t).
+		WithProfile(profile.Profile{Name: "work", Email: "work@example.com"}).
+		WithMapping("work", "src/acme").
+		Build()
+	moveDir(t, env, "src/acme", "clients/acme")
+
+	var out bytes.Buffer
+	if err := moveMapping(&out, env.Path("src/acme"), env.Path("clients/acme")); err != nil {
+		t.Fatalf("moveMapping() error = %v", err)
+	}
+	for _, want := range []string{
+		"✓ Moved mapping of profile 'work'",
+		`[includeIf "gitdir/i:` + env.Path("clients/acme") + `/"]`,
+		"path = ~/.gitconfig-work",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("moveMapping() output = %q, want %q", out.String(), want)
+		}
+	}
+	if got := mappedProfile(t, env.Path("clients/acme/repo")); got != "work" {
+		t.Errorf("profile of the moved directory = %q, want work", got)
+	}
+}
+
+func TestDetectMoves(t *testing.T) {
+	env := gidtreetest.NewEnv(t).
+		WithProfile(profile.Profile{Name: "work", Email: "work@example.com"}).
+		WithProfile(profile.Profile{Name: "personal", Email: "me@example.com"}).
+		WithMapping("work", "src/acme").
+		WithMapping("personal", "src/blog").
+		WithMapping("personal", "src/notes").
+		Build()
+	moveDir(t, env, "src/acme", "clients/acme")
+	moveDir(t, env, "src/blog", "sites/blog")
+	if err := os.RemoveAll(env.Path("src/notes")); err != nil {
+		t.Fatalf("Failed to remove directory: %v", err)
+	}
+
+	answers := map[string]string{
+		env.Path("src/acme") + "/": env.Path("clients/acme"),
+		env.Path("src/blog") + "/": "~/sites/blog",
+	}
+	var asked []string
+	ask := func(m mapping.Mapping) (string, error) {
+		asked = append(asked, m.Directory)
+		return answers[m.Directory], nil
+	}
+
+	var out bytes.Buffer
+	if err := detectMoves(&out, ask); err != nil {
+		t.Fatalf("detectMoves() error = %v", err)
+	}
+	if len(asked) != 3 {
+		t.Errorf("detectMoves() asked about %v, want the three missing directories", asked)
+	}
+	if !strings.Contains(out.String(), "2 moved, 1 left as they were") {
+		t.Errorf("detectMoves() output = %q, want a summary", out.String())
+	}
+	if got := mappedProfile(t, env.Path("clients/acme")); got != "work" {
+		t.Errorf("profile of clients/acme = %q, want work", got)
+	}
+	if got := mappedProfile(t, env.Path("sites/blog")); got != "personal" {
+		t.Errorf("profile of sites/blog = %q, want personal", got)
+	}
+
+	// Only the skipped directory is left to ask about
+	out.Reset()
+	asked = nil
+	if err := detectMoves(&out, ask); err != nil {
+		t.Fatalf("detectMoves() again error = %v", err)
+	}
+	if len(asked) != 1 || asked[0] != env.Path("src/notes")+"/" {
+		t.Errorf("detectMoves() again asked about %v, want src/notes", asked)
+	}
+}
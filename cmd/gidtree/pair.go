@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/thuanlegit/git-identitree/internal/profile"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	pairWith  []string
+	pairClear bool
+)
+
+var pairCmd = &cobra.Command{
+	Use:   "pair <profile>",
+	Short: "Credit co-authors on a profile's commits",
+	Long: `Toggle the co-authors of a profile: each --with adds a "Name <email>" entry,
+or removes the co-author with that email when it is already there. --clear
+removes them all. Without flags the current co-authors are printed.
+
+Commits in repositories mapped to the profile get a Co-authored-by trailer for
+each co-author once 'gidtree hooks install' has added the prepare-commit-msg
+hook. Trailers already in the message are not repeated, and merge and squash
+messages are left alone.`,
+	Example: `  gidtree pair work --with "Ada Lovelace <ada@example.com>"
+  gidtree pair work --with "Ada Lovelace <ada@example.com>" --with "Alan Turing <alan@example.com>"
+  gidtree pair work --clear`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeProfileNames,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runPair(cmd.OutOrStdout(), args[0], pairWith, pairClear)
+	},
+}
+
+// runPair toggles each entry of with on the named profile, after removing
+// every co-author when clear is set, and prints the resulting co-authors.
+func runPair(w io.Writer, name string, with []string, clear bool) error {
+	manager, err := profile.NewDefaultManager()
+	if err != nil {
+		return fmt.Errorf("failed to initialize profile manager: %w", err)
+	}
+	prof, err := manager.GetProfile(name)
+	if err != nil {
+		return fmt.Errorf("profile not found: %w", err)
+	}
+
+	if clear {
+		for _, entry := range prof.CoAuthors {
+			_, _ = fmt.Fprintf(w, "- %s\n", entry)
+		}
+		prof.CoAuthors = nil
+	}
+	added := false
+	for _, entry := range with {
+		ok, err := prof.ToggleCoAuthor(entry)
+		if err != nil {
+			return err
+		}
+		coAuthor, email, _ := profile.ParseCoAuthor(entry)
+		if ok {
+			added = true
+			_, _ = fmt.Fprintf(w, "+ %s\n", profile.FormatCoAuthor(coAuthor, email))
+		} else {
+			_, _ = fmt.Fprintf(w, "- %s\n", profile.FormatCoAuthor(coAuthor, email))
+		}
+	}
+	if clear || len(with) > 0 {
+		if err := manager.UpdateProfile(prof.Name, *prof); err != nil {
+			return fmt.Errorf("failed to update profile: %w", err)
+		}
+	}
+
+	if len(prof.CoAuthors) == 0 {
+		_, _ = fmt.Fprintf(w, "Profile '%s' has no co-authors\n", prof.Name)
+		return nil
+	}
+	_, _ = fmt.Fprintf(w, "Co-authors of profile '%s':\n", prof.Name)
+	for _, entry := range prof.CoAuthors {
+		_, _ = fmt.Fprintf(w, "  %s\n", entry)
+	}
+	if added {
+		_, _ = fmt.Fprintln(w, "Run 'gidtree hooks install' in a repository mapped to the profile to add the trailers to its commits.")
+	}
+	return nil
+}
+
+func init() {
+	pairCmd.Flags().StringArrayVar(&pairWith, "with", nil, `Add or remove a co-author, as "Name <email>" (repeatable)`)
+	pairCmd.Flags().BoolVar(&pairClear, "clear", false, "Remove every co-author")
+}
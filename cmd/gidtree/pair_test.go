@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/thuanlegit/git-identitree/internal/profile"
+	"github.com/thuanlegit/git-identitree/pkg/gidtree/gidtreetest"
+)
+
+func TestRunPair(t *testing.T) {
+	gidtreetest.NewEnv(t).
+		WithProfile(profile.Profile{Name: "work", Email: "work@example.com"}).
+		Build()
+	ada := "Ada Lovelace <ada@example.com>"
+	alan := "Alan Turing <alan@example.com>"
+	coAuthors := func() []string {
+		t.Helper()
+		manager, err := profile.NewDefaultManager()
+		if err != nil {
+			t.Fatalf("NewDefaultManager() error = %v", err)
+		}
+		p, err := manager.GetProfile("work")
+		if err != nil {
+			t.Fatalf("GetProfile() error = %v", err)
+		}
+		return p.CoAuthors
+	}
+
+	var out bytes.Buffer
+	if err := runPair(&out, "work", []string{ada, alan}, false); err != nil {
+		t.Fatalf("runPair() error = %v", err)
+	}
+	if got := coAuthors(); !reflect.DeepEqual(got, []string{ada, alan}) {
+		t.Errorf("co-authors = %v, want Ada and Alan", got)
+	}
+	if !strings.Contains(out.String(), "+ "+ada) || !strings.Contains(out.String(), "gidtree hooks install") {
+		t.Errorf("runPair() output = %q, want the additions and a hint", out.String())
+	}
+
+	// Toggling an existing email removes it
+	out.Reset()
+	if err := runPair(&out, "work", []string{"Ada <ADA@example.com>"}, false); err != nil {
+		t.Fatalf("runPair() error = %v", err)
+	}
+	if got := coAuthors(); !reflect.DeepEqual(got, []string{alan}) {
+		t.Errorf("co-authors = %v, want only Alan", got)
+	}
+	if !strings.Contains(out.String(), "- Ada <ADA@example.com>") {
+		t.Errorf("runPair() output = %q, want the removal", out.String())
+	}
+
+	out.Reset()
+	if err := runPair(&out, "work", nil, false); err != nil {
+		t.Fatalf("runPair() error = %v", err)
+	}
+	if out.String() != "Co-authors of profile 'work':\n  "+alan+"\n" {
+		t.Errorf("runPair() without flags = %q, want the list", out.String())
+	}
+
+	out.Reset()
+	if err := runPair(&out, "work", nil, true); err != nil {
+		t.Fatalf("runPair() error = %v", err)
+	}
+	if got := coAuthors(); len(got) != 0 {
+		t.Errorf("co-authors after --clear = %v, want none", got)
+	}
+	if !strings.Contains(out.String(), "has no co-authors") {
+		t.Errorf("runPair() --clear output = %q", out.String())
+	}
+
+	if err := runPair(&out, "work", []string{"ada@example.com"}, false); err == nil {
+		t.Error("runPair() should reject an entry without a name")
+	}
+	if err := runPair(&out, "missing", nil, false); !errors.Is(err, profile.ErrProfileNotFound) {
+		t.Errorf("runPair() for a missing profile = %v, want ErrProfileNotFound", err)
+	}
+}
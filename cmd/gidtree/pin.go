@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/thuanlegit/git-identitree/internal/mapping"
+	"github.com/thuanlegit/git-identitree/internal/profile"
+	"github.com/thuanlegit/git-identitree/internal/utils"
+
+	"github.com/spf13/cobra"
+)
+
+var pinCmd = &cobra.Command{
+	Use:   "pin <profile> [repo-path]",
+	Short: "Write a profile's identity into one repository's own config",
+	Long: `Write the profile's user.name, user.email and, if it has an SSH key,
+core.sshCommand into the .git/config of the repository holding repo-path
+(default: the current directory), for repositories that cannot be mapped, such
+as checkouts on a shared path. The entries sit below a "# gidtree: pinned"
+comment, override any mapping and leave the repository's other settings alone;
+'gidtree unpin' removes exactly them. 'gidtree status' shows the source as
+"pinned (local)".`,
+	Example: `  gidtree pin work
+  gidtree pin work /srv/shared/api`,
+	Args: cobra.RangeArgs(1, 2),
+	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) == 0 {
+			return completeProfileNames(cmd, args, toComplete)
+		}
+		return nil, cobra.ShellCompDirectiveFilterDirs
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dir := "."
+		if len(args) == 2 {
+			dir = args[1]
+		}
+		prof, err := lookupProfile(args[0])
+		if err != nil {
+			return err
+		}
+		return runPin(cmd.OutOrStdout(), prof, dir)
+	},
+}
+
+var unpinCmd = &cobra.Command{
+	Use:   "unpin [repo-path]",
+	Short: "Remove the identity 'gidtree pin' wrote into a repository",
+	Args:  cobra.MaximumNArgs(1),
+	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return nil, cobra.ShellCompDirectiveFilterDirs
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dir := "."
+		if len(args) == 1 {
+			dir = args[0]
+		}
+		name, err := mapping.Unpin(dir)
+		if err != nil {
+			return err
+		}
+		_, _ = fmt.Fprintf(cmd.OutOrStdout(), "✓ Unpinned profile '%s'\n", name)
+		return nil
+	},
+}
+
+// runPin pins prof in the repository holding dir and reports what was written.
+func runPin(w io.Writer, prof *profile.Profile, dir string) error {
+	path, err := mapping.Pin(prof, dir)
+	if err != nil {
+		return err
+	}
+	_, _ = fmt.Fprintf(w, "✓ Pinned profile '%s' in %s\n", prof.Name, utils.AbbreviateHome(path))
+	_, _ = fmt.Fprintf(w, "  user.name = %s\n", prof.GetAuthorName())
+	_, _ = fmt.Fprintf(w, "  user.email = %s\n", prof.Email)
+	if prof.SSHKeyPath != "" {
+		_, _ = fmt.Fprintf(w, "  core.sshCommand = %s\n", prof.SSHCommand())
+	}
+	return nil
+}
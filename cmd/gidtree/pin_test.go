@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/thuanlegit/git-identitree/internal/identity"
+	"github.com/thuanlegit/git-identitree/internal/mapping"
+	"github.com/thuanlegit/git-identitree/internal/profile"
+	"github.com/thuanlegit/git-identitree/internal/utils"
+	"github.com/thuanlegit/git-identitree/pkg/gidtree/gidtreetest"
+)
+
+func TestPinAndUnpinCommands(t *testing.T) {
+	env := gidtreetest.NewEnv(t).
+		WithProfile(profile.Profile{Name: "work", Email: "work@example.com", AuthorName: "Jane Doe"}).
+		WithProfile(profile.Profile{Name: "client", Email: "jane@client.example"}).
+		WithGitRepo("shared/api").
+		WithMapping("work", "shared").
+		Build()
+	repo := env.Path("shared/api")
+	gitGet := func(key string) string {
+		out, _ := utils.Command("git", "-C", repo, "config", "--get", key).Output()
+		return strings.TrimSpace(string(out))
+	}
+	if err := utils.Command("git", "-C", repo, "config", "pull.rebase", "true").Run(); err != nil {
+		t.Fatalf("git config error = %v", err)
+	}
+
+	prof, err := lookupProfile("client")
+	if err != nil {
+		t.Fatalf("lookupProfile() error = %v", err)
+	}
+	var out bytes.Buffer
+	if err := runPin(&out, prof, repo); err != nil {
+		t.Fatalf("runPin() error = %v", err)
+	}
+	if !strings.Contains(out.String(), "Pinned profile 'client'") {
+		t.Errorf("output = %q", out.String())
+	}
+	if got := gitGet("user.email"); got != "jane@client.example" {
+		t.Errorf("git user.email = %q, want the pinned profile's", got)
+	}
+
+	summary, err := identity.Summarize(repo)
+	if err != nil {
+		t.Fatalf("Summarize() error = %v", err)
+	}
+	if summary.Source != identity.SourcePinned || summary.Profile.Name != "client" {
+		t.Errorf("Summarize() = %v/%v, want client pinned", summary.Profile.Name, summary.Source)
+	}
+	if summary.Effective == nil || !summary.Effective.Matches {
+		t.Errorf("Effective = %+v, want git to use the pinned identity", summary.Effective)
+	}
+
+	t.Chdir(repo)
+	out.Reset()
+	unpinCmd.SetOut(&out)
+	t.Cleanup(func() { unpinCmd.SetOut(nil) })
+	if err := unpinCmd.RunE(unpinCmd, nil); err != nil {
+		t.Fatalf("unpin error = %v", err)
+	}
+	if got := gitGet("user.email"); got != "work@example.com" {
+		t.Errorf("git user.email after unpin = %q, want the mapped profile's", got)
+	}
+	if got := gitGet("pull.rebase"); got != "true" {
+		t.Errorf("git pull.rebase = %q, want other local settings kept", got)
+	}
+	if err := unpinCmd.RunE(unpinCmd, nil); !errors.Is(err, mapping.ErrNotPinned) {
+		t.Errorf("unpin without a pin error = %v, want ErrNotPinned", err)
+	}
+}
+
+func TestPinCommand_OutsideRepository(t *testing.T) {
+	env := gidtreetest.NewEnv(t).
+		WithProfile(profile.Profile{Name: "work", Email: "work@example.com"}).
+		Build()
+	if err := os.MkdirAll(env.Path("plain"), 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+
+	err := pinCmd.RunE(pinCmd, []string{"work", env.Path("plain")})
+	if !errors.Is(err, mapping.ErrNotRepository) {
+		t.Errorf("pin outside a repository error = %v, want ErrNotRepository", err)
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"text/template"
+
+	"github.com/thuanlegit/git-identitree/internal/identity"
+	"github.com/thuanlegit/git-identitree/internal/ssh"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	activatePorcelain bool
+	activateFormat    string
+	activateLoad      bool
+
+	activateQuiet          bool
+	activateRequireMapping bool
+)
+
+// promptInfo is what activate --porcelain and --format report about the
+// identity of a directory.
+type promptInfo struct {
+	Name       string
+	Email      string
+	AuthorName string
+	// Source is "mapping", "pinned" or "default".
+	Source     string
+	SSHKeyPath string
+	// KeyLoaded is only known with --load; the agent is not asked otherwise.
+	KeyLoaded bool
+	// AgentUnreachable is set when --load found no SSH agent answering at
+	// SSH_AUTH_SOCK, which is then not an error.
+	AgentUnreachable bool
+}
+
+// activateMachineReadable prints the identity of dir for shell prompts: one
+// "profile=<name> email=<email> key_loaded=<bool>" line, followed by
+// " agent=unreachable" when loading found the agent gone, or format executed
+// against a promptInfo. Nothing is printed and no error is returned when no
+// profile applies, so prompts can test for empty output, unless
+// requireMapping asks for exitMappingNotFound. It never runs git and only
+// touches the SSH agent when load is set.
+func activateMachineReadable(cmd *cobra.Command, w io.Writer, dir, format string, load, requireMapping bool) error {
+	if format == formatHelpValue {
+		writeFormatHelp(w, promptInfo{})
+		return nil
+	}
+	var tmpl *template.Template
+	if format != "" {
+		var err error
+		if tmpl, err = parseFormat(format, promptInfo{}); err != nil {
+			return err
+		}
+	}
+
+	summary, err := identity.Lookup(dir)
+	if err != nil {
+		return err
+	}
+	if requireMapping && summary.Source != identity.SourceMapping {
+		return &exitCodeError{code: exitMappingNotFound}
+	}
+	if summary.Profile == nil {
+		return nil
+	}
+	prof := summary.Profile
+	markUsed(prof)
+
+	info := promptInfo{
+		Name:       prof.Name,
+		Email:      prof.Email,
+		AuthorName: prof.GetAuthorName(),
+		Source:     string(summary.Source),
+		SSHKeyPath: prof.SSHKeyPath,
+	}
+	if load && prof.SSHKeyPath != "" {
+		err := loadProfileKey(cmd, prof, activateExclusive, activateKeychain)
+		switch {
+		case errors.Is(err, ssh.ErrAgentUnreachable):
+			// Shown in the prompt rather than failing it on every command
+			info.AgentUnreachable = true
+		case err != nil:
+			return fmt.Errorf("failed to load SSH key: %w", err)
+		default:
+			info.KeyLoaded = true
+		}
+	}
+
+	if tmpl != nil {
+		return writeFormatted(w, tmpl, info)
+	}
+	line := fmt.Sprintf("profile=%s email=%s key_loaded=%t", info.Name, info.Email, info.KeyLoaded)
+	if info.AgentUnreachable {
+		line += " agent=unreachable"
+	}
+	_, _ = fmt.Fprintln(w, line)
+	return nil
+}
+
+// activateQuietly loads the SSH key of the profile that applies to dir and
+// prints nothing. Where no profile applies it succeeds, or returns
+// exitMappingNotFound with requireMapping. Failures are left to main to
+// report on stderr.
+func activateQuietly(cmd *cobra.Command, dir string, requireMapping bool) error {
+	summary, err := identity.Lookup(dir)
+	if err != nil {
+		return err
+	}
+	if requireMapping && summary.Source != identity.SourceMapping {
+		return &exitCodeError{code: exitMappingNotFound}
+	}
+	markUsed(summary.Profile)
+	if summary.Profile == nil || summary.Profile.SSHKeyPath == "" {
+		return nil
+	}
+	if err := loadProfileKey(cmd, summary.Profile, activateExclusive, activateKeychain); err != nil {
+		return fmt.Errorf("failed to load SSH key: %w", err)
+	}
+	return nil
+}
+
+func init() {
+	activateCmd.Flags().BoolVar(&activatePorcelain, "porcelain", false, "Print one stable profile=<name> email=<email> key_loaded=<bool> line for shell prompts")
+	activateCmd.Flags().StringVar(&activateFormat, "format", "", "Print the identity with a Go template, e.g. '{{.Name}}'; 'help' lists the fields")
+	activateCmd.Flags().BoolVar(&activateLoad, "load", false, "With --porcelain or --format, also load the profile's SSH key")
+	activateCmd.Flags().BoolVarP(&activateQuiet, "quiet", "q", false, "Print nothing on success; only load the profile's SSH key")
+	activateCmd.Flags().BoolVar(&activateRequireMapping, "require-mapping", false, "Exit with status 5 unless a mapping applies, even where the default profile does")
+	activateCmd.MarkFlagsMutuallyExclusive("porcelain", "format", "quiet")
+}
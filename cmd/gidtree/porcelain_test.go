@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/thuanlegit/git-identitree/internal/profile"
+	"github.com/thuanlegit/git-identitree/pkg/gidtree/gidtreetest"
+)
+
+func TestActivateMachineReadable(t *testing.T) {
+	env := gidtreetest.NewEnv(t).
+		WithProfile(profile.Profile{Name: "work", Email: "work@example.com", AuthorName: "Jane Doe"}).
+		WithMapping("work", "work").
+		Build()
+	if err := os.MkdirAll(env.Path("elsewhere"), 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		dir    string
+		format string
+		want   string
+	}{
+		{name: "porcelain", dir: "work", want: "profile=work email=work@example.com key_loaded=false\n"},
+		{name: "format", dir: "work", format: "{{.Name}}", want: "work\n"},
+		{name: "format fields", dir: "work", format: "{{.AuthorName}} <{{.Email}}> via {{.Source}}", want: "Jane Doe <work@example.com> via mapping\n"},
+		{name: "unmapped", dir: "elsewhere", want: ""},
+		{name: "unmapped format", dir: "elsewhere", format: "{{.Name}}", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			if err := activateMachineReadable(activateCmd, &out, env.Path(tt.dir), tt.format, false, false); err != nil {
+				t.Fatalf("activateMachineReadable() error = %v", err)
+			}
+			if out.String() != tt.want {
+				t.Errorf("output = %q, want %q", out.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestActivateMachineReadable_AgentUnreachable(t *testing.T) {
+	keyPath := filepath.Join(t.TempDir(), "id_work")
+	writeKey(t, keyPath)
+	env := gidtreetest.NewEnv(t).
+		WithProfile(profile.Profile{Name: "work", Email: "work@example.com", SSHKeyPath: keyPath}).
+		WithMapping("work", "work").
+		Build()
+	// A forwarded agent whose session has ended leaves its socket path behind
+	t.Setenv("SSH_AUTH_SOCK", filepath.Join(t.TempDir(), "agent.sock"))
+
+	var out bytes.Buffer
+	if err := activateMachineReadable(activateCmd, &out, env.Path("work"), "", true, false); err != nil {
+		t.Fatalf("activateMachineReadable() error = %v, want the prompt shown anyway", err)
+	}
+	if want := "profile=work email=work@example.com key_loaded=false agent=unreachable\n"; out.String() != want {
+		t.Errorf("output = %q, want %q", out.String(), want)
+	}
+
+	out.Reset()
+	if err := activateMachineReadable(activateCmd, &out, env.Path("work"), "{{if .AgentUnreachable}}!{{end}}{{.Name}}", true, false); err != nil {
+		t.Fatalf("activateMachineReadable() error = %v", err)
+	}
+	if out.String() != "!work\n" {
+		t.Errorf("format output = %q, want !work", out.String())
+	}
+}
+
+func TestActivateMachineReadable_InvalidFormat(t *testing.T) {
+	env := gidtreetest.NewEnv(t).Build()
+
+	var out bytes.Buffer
+	err := activateMachineReadable(activateCmd, &out, env.Home(), "{{.Name", false, false)
+	if err == nil || !strings.Contains(err.Error(), "invalid --format template") {
+		t.Errorf("error = %v, want a template parse error", err)
+	}
+	if out.Len() != 0 {
+		t.Errorf("output = %q, want nothing", out.String())
+	}
+}
+
+func TestActivateMachineReadable_FormatHelp(t *testing.T) {
+	var out bytes.Buffer
+	if err := activateMachineReadable(activateCmd, &out, "", formatHelpValue, false, false); err != nil {
+		t.Fatalf("activateMachineReadable() error = %v", err)
+	}
+	if !strings.Contains(out.String(), ".KeyLoaded") {
+		t.Errorf("help = %q, want the prompt fields", out.String())
+	}
+}
+
+func TestActivateCommand_Porcelain(t *testing.T) {
+	env := gidtreetest.NewEnv(t).
+		WithProfile(profile.Profile{Name: "work", Email: "work@example.com"}).
+		Build()
+	t.Chdir(env.Home())
+
+	var out bytes.Buffer
+	activateCmd.SetOut(&out)
+	t.Cleanup(func() { activateCmd.SetOut(nil) })
+	setFlag(t, activateCmd, "porcelain", "true")
+
+	// Nothing applies: exit 0 and no output, unlike plain activate
+	if err := activateCmd.RunE(activateCmd, nil); err != nil {
+		t.Fatalf("activate --porcelain error = %v", err)
+	}
+	if out.Len() != 0 {
+		t.Errorf("output = %q, want nothing", out.String())
+	}
+}
+
+func TestActivateCommand_LoadNeedsPorcelain(t *testing.T) {
+	env := gidtreetest.NewEnv(t).Build()
+	t.Chdir(env.Home())
+	setFlag(t, activateCmd, "load", "true")
+
+	if err := activateCmd.RunE(activateCmd, nil); err == nil {
+		t.Error("activate --load without --porcelain or --format should fail")
+	}
+}
+
+// BenchmarkActivatePorcelain guards the path shell prompts run on every
+// command: it must stay well under 50ms and never shell out.
+func BenchmarkActivatePorcelain(b *testing.B) {
+	keyPath := filepath.Join(b.TempDir(), "id_work")
+	if err := os.WriteFile(keyPath, []byte("key"), 0600); err != nil {
+		b.Fatalf("Failed to write key: %v", err)
+	}
+	env := gidtreetest.NewEnv(b).
+		WithProfile(profile.Profile{Name: "work", Email: "work@example.com", SSHKeyPath: keyPath}).
+		WithMapping("work", "work").
+		Build()
+	dir := env.Path("work")
+
+	start := time.Now()
+	b.ResetTimer()
+	for range b.N {
+		if err := activateMachineReadable(activateCmd, io.Discard, dir, "", false, false); err != nil {
+			b.Fatalf("activateMachineReadable() error = %v", err)
+		}
+	}
+	b.StopTimer()
+	if perOp := time.Since(start) / time.Duration(b.N); perOp > 50*time.Millisecond {
+		b.Errorf("activate --porcelain took %v per run, want well under 50ms", perOp)
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/thuanlegit/git-identitree/internal/guard"
+	"github.com/thuanlegit/git-identitree/internal/identity"
+
+	"github.com/spf13/cobra"
+)
+
+var internalCmd = &cobra.Command{
+	Use:    "internal",
+	Short:  "Commands run by gidtree's hooks",
+	Hidden: true,
+}
+
+var prepareCommitMsgCmd = &cobra.Command{
+	Use:   "prepare-commit-msg <message-file> [source] [commit]",
+	Short: "Append the mapped profile's co-authors to a commit message",
+	Long:  "Run by the prepare-commit-msg hook that 'gidtree hooks install' adds, with git's arguments. Appends a Co-authored-by trailer for each co-author of the profile mapped to the repository, skipping merge and squash messages.",
+	Args:  cobra.RangeArgs(1, 3),
+	// Hooks speak to git through their exit status
+	SilenceErrors: true,
+	SilenceUsage:  true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		source := ""
+		if len(args) > 1 {
+			source = args[1]
+		}
+		// git runs hooks from the top of the working tree
+		return prepareCommitMsg(cmd.ErrOrStderr(), ".", args[0], source)
+	},
+}
+
+// prepareCommitMsg adds the co-authors of the profile mapped to dir to the
+// commit message in path. A profile that cannot be resolved only warns, so a
+// broken setup never blocks a commit; an unreadable message fails it.
+func prepareCommitMsg(errW io.Writer, dir, path, source string) error {
+	if guard.SkipCoAuthors(source) {
+		return nil
+	}
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return fmt.Errorf("failed to resolve path: %w", err)
+	}
+	s, err := identity.Lookup(dir)
+	if err != nil {
+		_, _ = fmt.Fprintf(errW, "⚠ gidtree: co-authors not added: %v\n", err)
+		return nil
+	}
+	if s.Source != identity.SourceMapping || len(s.Profile.CoAuthors) == 0 {
+		return nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read commit message: %w", err)
+	}
+	message := guard.AddCoAuthors(string(data), s.Profile.CoAuthors)
+	if message == string(data) {
+		return nil
+	}
+	if err := os.WriteFile(path, []byte(message), 0644); err != nil {
+		return fmt.Errorf("failed to write commit message: %w", err)
+	}
+	return nil
+}
+
+func init() {
+	internalCmd.AddCommand(prepareCommitMsgCmd)
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/thuanlegit/git-identitree/internal/profile"
+	"github.com/thuanlegit/git-identitree/internal/utils"
+	"github.com/thuanlegit/git-identitree/pkg/gidtree/gidtreetest"
+)
+
+// coAuthorsFixture builds a temp HOME with a repository mapped to a profile
+// with a co-author and installs the hooks into it, pointing at the test binary.
+func coAuthorsFixture(t *testing.T) string {
+	t.Helper()
+
+	env := gidtreetest.NewEnv(t).
+		WithProfile(profile.Profile{Name: "work", Email: "work@example.com", CoAuthors: []string{"Ada Lovelace <ada@example.com>"}}).
+		WithGitRepo("work/repo").
+		WithMapping("work", "work").
+		Build()
+	repo := env.Path("work/repo")
+
+	t.Setenv(cliEnvVar, "1")
+	setFlag(t, hooksInstallCmd, "all-mapped", "false")
+	if err := hooksInstallCmd.RunE(hooksInstallCmd, []string{repo}); err != nil {
+		t.Fatalf("hooks install error = %v", err)
+	}
+	return repo
+}
+
+// git runs git in repo and returns its trimmed output.
+func gitIn(t *testing.T, repo string, args ...string) string {
+	t.Helper()
+	out, err := utils.Command("git", append([]string{"-C", repo}, args...)...).CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %s failed: %v\n%s", strings.Join(args, " "), err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// lastMessage returns the message of the newest commit in repo.
+func lastMessage(t *testing.T, repo string) string {
+	t.Helper()
+	return gitIn(t, repo, "log", "-1", "--format=%B")
+}
+
+func TestCoAuthorsHook_AddsTrailers(t *testing.T) {
+	repo := coAuthorsFixture(t)
+
+	if out, err := commitWithConfig(t, repo); err != nil {
+		t.Fatalf("commit failed: %v\n%s", err, out)
+	}
+	if got, want := lastMessage(t, repo), "change\n\nCo-authored-by: Ada Lovelace <ada@example.com>"; got != want {
+		t.Errorf("commit message = %q, want %q", got, want)
+	}
+
+	// A trailer already in the message is not repeated
+	if err := os.WriteFile(filepath.Join(repo, "file.txt"), []byte("y"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	gitIn(t, repo, "commit", "-q", "-a", "-m", "second", "-m", "Co-authored-by: Ada <ADA@example.com>")
+	if got, want := lastMessage(t, repo), "second\n\nCo-authored-by: Ada <ADA@example.com>"; got != want {
+		t.Errorf("commit message = %q, want %q", got, want)
+	}
+
+	// Amending keeps a single trailer
+	gitIn(t, repo, "commit", "-q", "--amend", "--no-edit")
+	if got := lastMessage(t, repo); strings.Count(strings.ToLower(got), "co-authored-by") != 1 {
+		t.Errorf("amended commit message = %q, want one trailer", got)
+	}
+}
+
+func TestCoAuthorsHook_SkipsMergeAndSquash(t *testing.T) {
+	repo := coAuthorsFixture(t)
+	if out, err := commitWithConfig(t, repo); err != nil {
+		t.Fatalf("commit failed: %v\n%s", err, out)
+	}
+	main := gitIn(t, repo, "rev-parse", "--abbrev-ref", "HEAD")
+
+	gitIn(t, repo, "checkout", "-q", "-b", "topic")
+	if err := os.WriteFile(filepath.Join(repo, "topic.txt"), []byte("topic"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	gitIn(t, repo, "add", "topic.txt")
+	gitIn(t, repo, "commit", "-q", "-m", "topic")
+	gitIn(t, repo, "checkout", "-q", main)
+
+	gitIn(t, repo, "merge", "-q", "--no-ff", "--no-edit", "topic")
+	if got := lastMessage(t, repo); strings.Contains(got, "Co-authored-by") {
+		t.Errorf("merge commit message = %q, want no trailers", got)
+	}
+
+	gitIn(t, repo, "reset", "-q", "--hard", "HEAD~1")
+	gitIn(t, repo, "merge", "-q", "--squash", "topic")
+	gitIn(t, repo, "commit", "-q", "--no-edit")
+	// The squashed commit's own trailer is quoted, indented, in the message
+	if got := lastMessage(t, repo); strings.Contains(got, "\nCo-authored-by") {
+		t.Errorf("squash commit message = %q, want no trailers", got)
+	}
+}
+
+func TestHooksInstall_WithoutCoAuthors(t *testing.T) {
+	_, repo := hooksFixture(t)
+	hook := filepath.Join(repo, ".git", "hooks", "prepare-commit-msg")
+	if _, err := os.Stat(hook); !os.IsNotExist(err) {
+		t.Errorf("prepare-commit-msg hook installed for a profile without co-authors: %v", err)
+	}
+}
+
+func TestHooksUninstall_RemovesCoAuthorsHook(t *testing.T) {
+	repo := coAuthorsFixture(t)
+	hook := filepath.Join(repo, ".git", "hooks", "prepare-commit-msg")
+	if _, err := os.Stat(hook); err != nil {
+		t.Fatalf("prepare-commit-msg hook missing after install: %v", err)
+	}
+
+	setFlag(t, hooksUninstallCmd, "all-mapped", "false")
+	var out bytes.Buffer
+	hooksUninstallCmd.SetOut(&out)
+	t.Cleanup(func() { hooksUninstallCmd.SetOut(nil) })
+	if err := hooksUninstallCmd.RunE(hooksUninstallCmd, []string{repo}); err != nil {
+		t.Fatalf("hooks uninstall error = %v", err)
+	}
+	if _, err := os.Stat(hook); !os.IsNotExist(err) {
+		t.Error("hooks uninstall should remove the prepare-commit-msg hook")
+	}
+	if !strings.Contains(out.String(), "Removed co-author trailers") {
+		t.Errorf("hooks uninstall output = %q", out.String())
+	}
+}
+
+func TestPrepareCommitMsg_Unmapped(t *testing.T) {
+	env := gidtreetest.NewEnv(t).
+		WithProfile(profile.Profile{Name: "work", Email: "work@example.com", CoAuthors: []string{"Ada Lovelace <ada@example.com>"}}).
+		Build()
+	msg := filepath.Join(env.Home(), "COMMIT_EDITMSG")
+	if err := os.WriteFile(msg, []byte("change\n"), 0644); err != nil {
+		t.Fatalf("Failed to write message: %v", err)
+	}
+
+	var errOut bytes.Buffer
+	if err := prepareCommitMsg(&errOut, env.Home(), msg, "message"); err != nil {
+		t.Fatalf("prepareCommitMsg() error = %v", err)
+	}
+	if data, _ := os.ReadFile(msg); string(data) != "change\n" {
+		t.Errorf("message in an unmapped directory = %q, want it unchanged", data)
+	}
+	if err := prepareCommitMsg(&errOut, env.Home(), filepath.Join(env.Home(), "missing"), ""); err != nil {
+		t.Errorf("prepareCommitMsg() in an unmapped directory should not read the message: %v", err)
+	}
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/thuanlegit/git-identitree/internal/profile"
+
+	"github.com/spf13/cobra"
+)
+
+var profileDecryptStoreCmd = &cobra.Command{
+	Use:   "decrypt-store",
+	Short: "Store profiles.yaml as plain YAML again",
+	Long: `Decrypt profiles.yaml, undoing 'gidtree init --encrypt'. The passphrase is
+prompted for, or read from GIDTREE_PASSPHRASE when not run in a terminal.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		encrypted, err := profile.IsEncrypted()
+		if err != nil {
+			return fmt.Errorf("failed to read profiles file: %w", err)
+		}
+		if !encrypted {
+			fmt.Println("profiles.yaml is not encrypted")
+			return nil
+		}
+
+		if err := profile.DecryptStore(); err != nil {
+			return err
+		}
+		fmt.Println("✓ profiles.yaml is stored as plain YAML")
+		return nil
+	},
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/thuanlegit/git-identitree/internal/profile"
+	"github.com/thuanlegit/git-identitree/pkg/gidtree/gidtreetest"
+)
+
+func TestInitEncryptAndDecryptStore(t *testing.T) {
+	env := gidtreetest.NewEnv(t).
+		WithProfile(profile.Profile{Name: "work", Email: "jane@company.com"}).
+		Build()
+	profilesPath := env.Path(".gidtree/profiles.yaml")
+
+	// Without a terminal the passphrase has to come from the environment
+	t.Setenv(profile.PassphraseEnv, "")
+	if err := encryptProfiles(&bytes.Buffer{}); !errors.Is(err, profile.ErrPassphraseRequired) {
+		t.Fatalf("encryptProfiles() without a passphrase error = %v, want ErrPassphraseRequired", err)
+	}
+
+	t.Setenv(profile.PassphraseEnv, "s3cret")
+	setFlag(t, initCmd, "encrypt", "true")
+	if err := initCmd.RunE(initCmd, nil); err != nil {
+		t.Fatalf("init --encrypt error = %v", err)
+	}
+	data, err := os.ReadFile(profilesPath)
+	if err != nil {
+		t.Fatalf("Failed to read profiles file: %v", err)
+	}
+	if strings.Contains(string(data), "jane@company.com") {
+		t.Fatalf("profiles.yaml is readable after init --encrypt:\n%s", data)
+	}
+
+	var out bytes.Buffer
+	if err := encryptProfiles(&out); err != nil || !strings.Contains(out.String(), "already encrypted") {
+		t.Errorf("encryptProfiles() again = %q, %v", out.String(), err)
+	}
+
+	manager, err := profile.NewDefaultManager()
+	if err != nil {
+		t.Fatalf("NewDefaultManager() on an encrypted store error = %v", err)
+	}
+	if _, err := manager.GetProfile("work"); err != nil {
+		t.Errorf("GetProfile(work) error = %v", err)
+	}
+
+	if err := profileDecryptStoreCmd.RunE(profileDecryptStoreCmd, nil); err != nil {
+		t.Fatalf("decrypt-store error = %v", err)
+	}
+	data, err = os.ReadFile(profilesPath)
+	if err != nil {
+		t.Fatalf("Failed to read profiles file: %v", err)
+	}
+	if !strings.Contains(string(data), "jane@company.com") {
+		t.Errorf("profiles.yaml after decrypt-store:\n%s", data)
+	}
+	if err := profileDecryptStoreCmd.RunE(profileDecryptStoreCmd, nil); err != nil {
+		t.Errorf("decrypt-store on a plain store error = %v", err)
+	}
+}
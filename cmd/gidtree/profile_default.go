@@ -0,0 +1,84 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/thuanlegit/git-identitree/internal/config"
+	"github.com/thuanlegit/git-identitree/internal/profile"
+
+	"github.com/spf13/cobra"
+)
+
+var profileDefaultUnset bool
+
+var profileDefaultCmd = &cobra.Command{
+	Use:   "default [name]",
+	Short: "Set the profile used outside mapped directories",
+	Long: `Set the default profile, which activate, resolve and status fall back to
+in directories that no mapping covers. Without a name the current default is
+printed. --unset clears it.
+
+The default is stored in ~/.gidtree/config.yaml and does not touch git's
+own config; see 'gidtree default' for writing the global [user] section.`,
+	Args: cobra.MaximumNArgs(1),
+	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) != 0 || profileDefaultUnset {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		manager, err := profile.NewDefaultManager()
+		if err != nil {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		profiles := manager.ListProfiles()
+		var names []string
+		for _, p := range profiles {
+			names = append(names, p.Name)
+		}
+		return names, cobra.ShellCompDirectiveNoFileComp
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if profileDefaultUnset {
+			if len(args) != 0 {
+				return errors.New("--unset does not take a profile name")
+			}
+			if err := config.SetDefaultProfile(""); err != nil {
+				return fmt.Errorf("failed to clear default profile: %w", err)
+			}
+			fmt.Println("✓ Default profile cleared")
+			return nil
+		}
+
+		if len(args) == 0 {
+			cfg, err := config.Load()
+			if err != nil {
+				return fmt.Errorf("failed to load config: %w", err)
+			}
+			if cfg.DefaultProfile == "" {
+				fmt.Println("No default profile set")
+			} else {
+				fmt.Println(cfg.DefaultProfile)
+			}
+			return nil
+		}
+
+		manager, err := profile.NewDefaultManager()
+		if err != nil {
+			return fmt.Errorf("failed to initialize profile manager: %w", err)
+		}
+		prof, err := manager.GetProfile(args[0])
+		if err != nil {
+			return fmt.Errorf("profile not found: %w", err)
+		}
+
+		if err := config.SetDefaultProfile(prof.Name); err != nil {
+			return fmt.Errorf("failed to set default profile: %w", err)
+		}
+		fmt.Printf("✓ Default profile set to '%s'\n", prof.Name)
+		return nil
+	},
+}
+
+func init() {
+	profileDefaultCmd.Flags().BoolVar(&profileDefaultUnset, "unset", false, "Clear the default profile")
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/thuanlegit/git-identitree/internal/config"
+	"github.com/thuanlegit/git-identitree/internal/profile"
+	"github.com/thuanlegit/git-identitree/pkg/gidtree/gidtreetest"
+)
+
+func loadDefaultProfile(t *testing.T) string {
+	t.Helper()
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	return cfg.DefaultProfile
+}
+
+func TestProfileDefaultCommand(t *testing.T) {
+	gidtreetest.NewEnv(t).
+		WithProfile(profile.Profile{Name: "personal", Email: "me@example.com"}).
+		Build()
+
+	if err := profileDefaultCmd.RunE(profileDefaultCmd, []string{"missing"}); !errors.Is(err, profile.ErrProfileNotFound) {
+		t.Errorf("default of a missing profile error = %v, want ErrProfileNotFound", err)
+	}
+	if got := loadDefaultProfile(t); got != "" {
+		t.Errorf("DefaultProfile = %q after a failed set, want empty", got)
+	}
+
+	if err := profileDefaultCmd.RunE(profileDefaultCmd, []string{"personal"}); err != nil {
+		t.Fatalf("default personal error = %v", err)
+	}
+	if got := loadDefaultProfile(t); got != "personal" {
+		t.Errorf("DefaultProfile = %q, want personal", got)
+	}
+	if err := profileDefaultCmd.RunE(profileDefaultCmd, nil); err != nil {
+		t.Errorf("default without arguments error = %v", err)
+	}
+
+	if err := profileDefaultCmd.Flags().Set("unset", "true"); err != nil {
+		t.Fatalf("Set(unset) error = %v", err)
+	}
+	defer func() {
+		profileDefaultUnset = false
+		profileDefaultCmd.Flags().Lookup("unset").Changed = false
+	}()
+
+	if err := profileDefaultCmd.RunE(profileDefaultCmd, []string{"personal"}); err == nil {
+		t.Error("--unset with a name should fail")
+	}
+	if err := profileDefaultCmd.RunE(profileDefaultCmd, nil); err != nil {
+		t.Fatalf("default --unset error = %v", err)
+	}
+	if got := loadDefaultProfile(t); got != "" {
+		t.Errorf("DefaultProfile = %q after --unset, want empty", got)
+	}
+}
+
+func TestActivateAndResolve_FallBackToDefault(t *testing.T) {
+	env := gidtreetest.NewEnv(t).
+		WithProfile(profile.Profile{Name: "personal", Email: "me@example.com"}).
+		Build()
+
+	unmapped := env.Home()
+	if got := runCLI(t, "resolve", unmapped); got != exitMappingNotFound {
+		t.Errorf("resolve without default exit status = %d, want %d", got, exitMappingNotFound)
+	}
+
+	if err := config.SetDefaultProfile("personal"); err != nil {
+		t.Fatalf("SetDefaultProfile() error = %v", err)
+	}
+	if got := runCLI(t, "resolve", unmapped); got != 0 {
+		t.Errorf("resolve with default exit status = %d, want 0", got)
+	}
+
+	originalDir, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Getwd() error = %v", err)
+	}
+	defer func() { _ = os.Chdir(originalDir) }()
+	if err := os.Chdir(unmapped); err != nil {
+		t.Fatalf("Chdir() error = %v", err)
+	}
+	if got := runCLI(t, "activate"); got != 0 {
+		t.Errorf("activate with default exit status = %d, want 0", got)
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/thuanlegit/git-identitree/internal/mapping"
+	"github.com/thuanlegit/git-identitree/internal/profile"
+	"github.com/thuanlegit/git-identitree/internal/utils"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	profileFindQuery    profile.Query
+	profileFindMappings bool
+	profileFindJSON     bool
+)
+
+var profileFindCmd = &cobra.Command{
+	Use:   "find --email <addr> | --ssh-key <path> | --gpg-key <id>",
+	Short: "Find the profiles with an email, SSH key or GPG key",
+	Long: `Print the profiles whose email, SSH key or GPG key matches, for working out
+which profile a commit was made with. Given several fields, a profile must match
+them all.
+
+Values match exactly by default: emails and GPG key IDs ignoring case (and a
+leading 0x), SSH key paths with ~ expanded. --contains matches part of the value
+instead. --mappings also lists the directories each profile is mapped to. Exits
+with status 2 when no profile matches.`,
+	Example: `  gidtree profile find --email jane@acme.com
+  gidtree profile find --email acme.com --contains --mappings
+  git log -1 --format=%ae | xargs gidtree profile find --email`,
+	Args: cobra.NoArgs,
+	// The exit status tells scripts whether anything matched
+	SilenceErrors: true,
+	SilenceUsage:  true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if profileFindQuery.IsEmpty() {
+			return errors.New("give at least one of --email, --ssh-key or --gpg-key")
+		}
+		asJSON, err := jsonOutput(cmd, profileFindJSON)
+		if err != nil {
+			return err
+		}
+		manager, err := profile.NewDefaultManager()
+		if err != nil {
+			return fmt.Errorf("failed to initialize profile manager: %w", err)
+		}
+		err = findProfiles(cmd.OutOrStdout(), manager, profileFindQuery, profileFindMappings, asJSON)
+		if exitStatus(err) == exitProfileNotFound && !asJSON {
+			fmt.Fprintln(os.Stderr, "No profile matches")
+		}
+		return err
+	},
+}
+
+// profileMatch is a profile found by profile find, with its mapped directories
+// when they were asked for.
+type profileMatch struct {
+	profile.Profile
+	Directories []string `json:",omitempty"`
+}
+
+// findProfiles writes the profiles of manager matching q to w, one per line as
+// "name <email>" or as a JSON array.
+func findProfiles(w io.Writer, manager *profile.Manager, q profile.Query, withMappings, asJSON bool) error {
+	found := manager.FindProfiles(q)
+	matches := make([]profileMatch, 0, len(found))
+	for _, p := range found {
+		m := profileMatch{Profile: p}
+		if withMappings {
+			dirs, err := mapping.GetDirectoriesForProfile(p.Name)
+			if err != nil {
+				return fmt.Errorf("failed to get mappings: %w", err)
+			}
+			m.Directories = dirs
+		}
+		matches = append(matches, m)
+	}
+
+	if asJSON {
+		if err := writeJSON(w, matches); err != nil {
+			return err
+		}
+	} else {
+		for _, m := range matches {
+			_, _ = fmt.Fprintf(w, "%s <%s>\n", m.Name, m.Email)
+			for _, dir := range m.Directories {
+				_, _ = fmt.Fprintf(w, "  %s\n", utils.AbbreviateHome(dir))
+			}
+			if withMappings && len(m.Directories) == 0 {
+				_, _ = fmt.Fprintln(w, "  (not mapped)")
+			}
+		}
+	}
+
+	if len(matches) == 0 {
+		return &exitCodeError{code: exitProfileNotFound}
+	}
+	return nil
+}
+
+func init() {
+	profileFindCmd.Flags().StringVar(&profileFindQuery.Email, "email", "", "Match the profile email")
+	profileFindCmd.Flags().StringVar(&profileFindQuery.SSHKey, "ssh-key", "", "Match the SSH key path")
+	profileFindCmd.Flags().StringVar(&profileFindQuery.GPGKey, "gpg-key", "", "Match the GPG key ID")
+	profileFindCmd.Flags().BoolVar(&profileFindQuery.Contains, "contains", false, "Match part of a value instead of all of it")
+	profileFindCmd.Flags().BoolVar(&profileFindMappings, "mappings", false, "Also list the directories each profile is mapped to")
+	profileFindCmd.Flags().BoolVar(&profileFindJSON, "json", false, "Print the matches as JSON")
+	_ = profileFindCmd.RegisterFlagCompletionFunc("email", completeProfileEmails)
+	_ = profileFindCmd.RegisterFlagCompletionFunc("ssh-key", completeProfileSSHKeys)
+	_ = profileFindCmd.RegisterFlagCompletionFunc("gpg-key", completeProfileGPGKeys)
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/thuanlegit/git-identitree/internal/profile"
+	"github.com/thuanlegit/git-identitree/internal/utils"
+	"github.com/thuanlegit/git-identitree/pkg/gidtree/gidtreetest"
+)
+
+func TestFindProfiles(t *testing.T) {
+	env := gidtreetest.NewEnv(t).
+		WithProfile(profile.Profile{Name: "work", Email: "jane@acme.com", GPGKeyID: "ABCD1234"}).
+		WithProfile(profile.Profile{Name: "acme-ci", Email: "ci@acme.com"}).
+		WithProfile(profile.Profile{Name: "personal", Email: "jane@example.com"}).
+		WithMapping("work", "code/acme").
+		Build()
+	manager, err := profile.NewDefaultManager()
+	if err != nil {
+		t.Fatalf("NewDefaultManager() error = %v", err)
+	}
+
+	var out bytes.Buffer
+	if err := findProfiles(&out, manager, profile.Query{Email: "JANE@acme.com"}, false, false); err != nil {
+		t.Fatalf("findProfiles() error = %v", err)
+	}
+	if want := "work <jane@acme.com>\n"; out.String() != want {
+		t.Errorf("findProfiles() output = %q, want %q", out.String(), want)
+	}
+
+	out.Reset()
+	if err := findProfiles(&out, manager, profile.Query{Email: "acme.com", Contains: true}, true, false); err != nil {
+		t.Fatalf("findProfiles() error = %v", err)
+	}
+	want := "acme-ci <ci@acme.com>\n  (not mapped)\n" +
+		"work <jane@acme.com>\n  " + utils.AbbreviateHome(env.Path("code/acme")) + "/\n"
+	if out.String() != want {
+		t.Errorf("findProfiles() with mappings output = %q, want %q", out.String(), want)
+	}
+
+	out.Reset()
+	if err := findProfiles(&out, manager, profile.Query{GPGKey: "0xabcd1234"}, true, true); err != nil {
+		t.Fatalf("findProfiles() error = %v", err)
+	}
+	var matches []struct {
+		Name        string
+		Directories []string
+	}
+	if err := json.Unmarshal(out.Bytes(), &matches); err != nil {
+		t.Fatalf("findProfiles() JSON = %q: %v", out.String(), err)
+	}
+	if len(matches) != 1 || matches[0].Name != "work" || len(matches[0].Directories) != 1 {
+		t.Errorf("findProfiles() JSON = %+v", matches)
+	}
+
+	out.Reset()
+	err = findProfiles(&out, manager, profile.Query{Email: "nobody@example.com"}, false, true)
+	if exitStatus(err) != exitProfileNotFound {
+		t.Errorf("findProfiles() without matches error = %v, want exit status %d", err, exitProfileNotFound)
+	}
+	if strings.TrimSpace(out.String()) != "[]" {
+		t.Errorf("findProfiles() JSON without matches = %q, want []", out.String())
+	}
+}
+
+func TestProfileFindCommand_RequiresAField(t *testing.T) {
+	gidtreetest.NewEnv(t).Build()
+
+	err := profileFindCmd.RunE(profileFindCmd, nil)
+	if err == nil || !strings.Contains(err.Error(), "--email") {
+		t.Errorf("profile find without a field error = %v, want a hint", err)
+	}
+}
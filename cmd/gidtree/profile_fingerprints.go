@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/thuanlegit/git-identitree/internal/profile"
+	"github.com/thuanlegit/git-identitree/internal/utils"
+
+	"github.com/spf13/cobra"
+)
+
+var profileRefreshFingerprintsCmd = &cobra.Command{
+	Use:   "refresh-fingerprints [profile...]",
+	Short: "Record the fingerprints of the profiles' SSH keys again",
+	Long: `Record the current SHA256 fingerprint of the SSH key of each named profile,
+or of every profile, as after rotating a key in place.
+
+gidtree records the fingerprint when a profile is created or its key path
+changes, and 'gidtree ssh load' warns when the key file no longer matches it.
+Each profile is reported with its new fingerprint; profiles without a key or
+whose fingerprint is unchanged are skipped.`,
+	ValidArgsFunction: completeLoadableProfiles,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		manager, err := profile.NewDefaultManager()
+		if err != nil {
+			return fmt.Errorf("failed to initialize profile manager: %w", err)
+		}
+		return refreshFingerprints(cmd.OutOrStdout(), manager, args)
+	},
+}
+
+// refreshFingerprints re-records the key fingerprints of the named profiles,
+// or of all of them, reporting each to w.
+func refreshFingerprints(w io.Writer, manager *profile.Manager, names []string) error {
+	updates, err := manager.RefreshFingerprints(names...)
+	if err != nil {
+		return fmt.Errorf("failed to refresh fingerprints: %w", err)
+	}
+	report := newReporter(w, "refreshed", false)
+	for _, u := range updates {
+		switch {
+		case u.Err != nil:
+			report.Fail(u.Profile, u.Err)
+		case u.New == "":
+			report.Skip(u.Profile, "no SSH key")
+		case !u.Changed():
+			report.Skip(u.Profile, "unchanged")
+		default:
+			report.Done(fmt.Sprintf("%s: %s", u.Profile, u.New))
+		}
+	}
+	report.Summary()
+	return report.Err()
+}
+
+// warnKeyRotated warns on w when the SSH key file of prof holds another key
+// than the one recorded for the profile, as after rotating it in place: the
+// agent may then still hold the old key.
+func warnKeyRotated(w io.Writer, prof *profile.Profile) {
+	current, rotated := prof.KeyRotated()
+	if !rotated {
+		return
+	}
+	_, _ = fmt.Fprintf(w, "⚠ key at %s changed since profile was created (rotated?)\n", utils.AbbreviateHome(prof.SSHKeyPath))
+	_, _ = fmt.Fprintf(w, "  recorded %s, now %s\n", prof.SSHKeyFingerprint, current)
+	_, _ = fmt.Fprintf(w, "  If the change is intended, record it: gidtree profile refresh-fingerprints %s\n", prof.Name)
+}
+
+func init() {
+	profileCmd.AddCommand(profileRefreshFingerprintsCmd)
+}
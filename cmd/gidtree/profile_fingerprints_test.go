@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/thuanlegit/git-identitree/internal/profile"
+	"github.com/thuanlegit/git-identitree/pkg/gidtree/gidtreetest"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// writeKey generates a new ed25519 key at path, replacing any key there.
+func writeKey(t *testing.T, path string) {
+	t.Helper()
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+	block, err := ssh.MarshalPrivateKey(priv, "test")
+	if err != nil {
+		t.Fatalf("MarshalPrivateKey() error = %v", err)
+	}
+	if err := os.WriteFile(path, pem.EncodeToMemory(block), 0600); err != nil {
+		t.Fatalf("Failed to write key: %v", err)
+	}
+}
+
+func TestRefreshFingerprints(t *testing.T) {
+	keyPath := filepath.Join(t.TempDir(), "id_work")
+	writeKey(t, keyPath)
+	gidtreetest.NewEnv(t).
+		WithProfile(profile.Profile{Name: "work", Email: "work@example.com", SSHKeyPath: keyPath}).
+		WithProfile(profile.Profile{Name: "personal", Email: "me@example.com"}).
+		Build()
+	manager, err := profile.NewDefaultManager()
+	if err != nil {
+		t.Fatalf("NewDefaultManager() error = %v", err)
+	}
+	prof, _ := manager.GetProfile("work")
+	if prof.SSHKeyFingerprint == "" {
+		t.Fatal("creating the profile should record the key's fingerprint")
+	}
+
+	var warning bytes.Buffer
+	warnKeyRotated(&warning, prof)
+	if warning.Len() != 0 {
+		t.Errorf("warning before rotation = %q, want none", warning.String())
+	}
+
+	// Rotate the key in place
+	writeKey(t, keyPath)
+	warnKeyRotated(&warning, prof)
+	if !strings.Contains(warning.String(), "changed since profile was created (rotated?)") || !strings.Contains(warning.String(), "refresh-fingerprints work") {
+		t.Errorf("warning after rotation = %q", warning.String())
+	}
+
+	var out bytes.Buffer
+	if err := refreshFingerprints(&out, manager, nil); err != nil {
+		t.Fatalf("refreshFingerprints() error = %v", err)
+	}
+	if !strings.Contains(out.String(), "✓ work: SHA256:") || !strings.Contains(out.String(), "- personal (no SSH key)") || !strings.HasSuffix(out.String(), "1 refreshed, 1 skipped (no SSH key)\n") {
+		t.Errorf("output = %q", out.String())
+	}
+
+	prof, _ = manager.GetProfile("work")
+	warning.Reset()
+	warnKeyRotated(&warning, prof)
+	if warning.Len() != 0 {
+		t.Errorf("warning after refresh = %q, want none", warning.String())
+	}
+
+	out.Reset()
+	if err := refreshFingerprints(&out, manager, []string{"work"}); err != nil {
+		t.Fatalf("refreshFingerprints(work) error = %v", err)
+	}
+	if out.String() != "- work (unchanged)\n0 refreshed, 1 skipped (unchanged)\n" {
+		t.Errorf("output = %q, want work unchanged", out.String())
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/thuanlegit/git-identitree/internal/config"
+	"github.com/thuanlegit/git-identitree/internal/mapping"
+	"github.com/thuanlegit/git-identitree/internal/profile"
+	"github.com/thuanlegit/git-identitree/internal/signers"
+
+	"github.com/spf13/cobra"
+)
+
+var profileRenameCmd = &cobra.Command{
+	Use:   "rename <old> <new>",
+	Short: "Rename a profile",
+	Long: `Rename a profile and everything named after it: its ~/.gitconfig-<name>,
+the includeIf blocks of the directories mapped to it, its allowed signers file
+and the default profile setting.
+
+Profile names may use letters, digits, '-', '_' and '.', up to 64 characters.
+Profiles created before names were checked keep working; 'gidtree doctor'
+lists them, and renaming gives them a valid name.`,
+	Example:           `  gidtree profile rename "work client" work-client`,
+	Args:              cobra.ExactArgs(2),
+	ValidArgsFunction: completeProfileNames,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		manager, err := profile.NewDefaultManager()
+		if err != nil {
+			return fmt.Errorf("failed to initialize profile manager: %w", err)
+		}
+		return renameProfile(cmd.OutOrStdout(), manager, args[0], args[1])
+	},
+}
+
+// renameProfile renames the profile oldName to newName, then moves its
+// allowed signers, mappings and the default profile setting along.
+func renameProfile(w io.Writer, manager *profile.Manager, oldName, newName string) error {
+	old, err := manager.GetProfile(oldName)
+	if err != nil {
+		return err
+	}
+	oldName = old.Name
+
+	if err := manager.RenameProfile(oldName, newName); err != nil {
+		return err
+	}
+	_, _ = fmt.Fprintf(w, "✓ Renamed profile '%s' to '%s'\n", oldName, newName)
+
+	prof, err := manager.GetProfile(newName)
+	if err != nil {
+		return err
+	}
+	if err := signers.Rename(oldName, newName); err != nil {
+		return err
+	}
+	dirs, err := mapping.RenameProfileConfig(oldName, prof)
+	for _, dir := range dirs {
+		_, _ = fmt.Fprintf(w, "  ✓ %s\n", dir)
+	}
+	if err != nil {
+		return err
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	if cfg.DefaultProfile == oldName {
+		if err := config.SetDefaultProfile(newName); err != nil {
+			return fmt.Errorf("failed to set default profile: %w", err)
+		}
+		_, _ = fmt.Fprintf(w, "  ✓ Default profile is now '%s'\n", newName)
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/thuanlegit/git-identitree/internal/config"
+	"github.com/thuanlegit/git-identitree/internal/mapping"
+	"github.com/thuanlegit/git-identitree/internal/profile"
+	"github.com/thuanlegit/git-identitree/internal/signers"
+	"github.com/thuanlegit/git-identitree/pkg/gidtree/gidtreetest"
+)
+
+func TestRenameProfile(t *testing.T) {
+	env := gidtreetest.NewEnv(t).
+		WithProfile(profile.Profile{Name: "work", Email: "work@example.com"}).
+		WithMapping("work", "work").
+		Build()
+	// A name from before names were checked, written directly
+	profiles, err := profile.LoadProfiles()
+	if err != nil {
+		t.Fatalf("LoadProfiles() error = %v", err)
+	}
+	profiles[0].Name = "work client"
+	if err := profile.SaveProfiles(profiles); err != nil {
+		t.Fatalf("SaveProfiles() error = %v", err)
+	}
+	legacy := &profile.Profile{Name: "work client", Email: "work@example.com"}
+	if _, err := mapping.RenameProfileConfig("work", legacy); err != nil {
+		t.Fatalf("RenameProfileConfig() error = %v", err)
+	}
+	if err := config.SetDefaultProfile("work client"); err != nil {
+		t.Fatalf("SetDefaultProfile() error = %v", err)
+	}
+	if _, err := signers.Add("work client", "work@example.com ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIK2tra2tra2tra2tra2tra2tra2tra2tra2tra2tra2t"); err != nil {
+		t.Fatalf("signers.Add() error = %v", err)
+	}
+
+	var doctorOut bytes.Buffer
+	if n, err := checkProfileNames(&doctorOut); err != nil || n != 1 {
+		t.Errorf("checkProfileNames() = %d, %v, want 1", n, err)
+	}
+	if !strings.Contains(doctorOut.String(), `gidtree profile rename "work client"`) {
+		t.Errorf("checkProfileNames() output = %q, want the rename hint", doctorOut.String())
+	}
+
+	manager, err := profile.NewDefaultManager()
+	if err != nil {
+		t.Fatalf("NewDefaultManager() error = %v", err)
+	}
+	var out bytes.Buffer
+	if err := renameProfile(&out, manager, "work client", "work/client"); !errors.Is(err, profile.ErrInvalidName) {
+		t.Errorf("renameProfile() error = %v, want ErrInvalidName", err)
+	}
+	if err := renameProfile(&out, manager, "work client", "work-client"); err != nil {
+		t.Fatalf("renameProfile() error = %v", err)
+	}
+	if !strings.Contains(out.String(), env.Path("work")) || !strings.Contains(out.String(), "Default profile is now 'work-client'") {
+		t.Errorf("renameProfile() output = %q, want the directory and the default", out.String())
+	}
+
+	m, err := mapping.GetMappingForDirectory(env.Path("work"))
+	if err != nil || m == nil || m.Profile != "work-client" {
+		t.Errorf("GetMappingForDirectory() = %+v, %v, want profile 'work-client'", m, err)
+	}
+	if _, err := os.Stat(env.FragmentPath("work client")); !os.IsNotExist(err) {
+		t.Errorf("old fragment still exists: %v", err)
+	}
+	if _, err := os.Stat(env.FragmentPath("work-client")); err != nil {
+		t.Errorf("new fragment missing: %v", err)
+	}
+	cfg, err := config.Load()
+	if err != nil || cfg.DefaultProfile != "work-client" {
+		t.Errorf("default profile = %q, %v, want 'work-client'", cfg.DefaultProfile, err)
+	}
+	if entries, err := signers.List("work-client"); err != nil || len(entries) != 1 {
+		t.Errorf("signers.List() = %v, %v, want the entry moved", entries, err)
+	}
+
+	doctorOut.Reset()
+	if n, err := checkProfileNames(&doctorOut); err != nil || n != 0 {
+		t.Errorf("checkProfileNames() after rename = %d, %v, want 0", n, err)
+	}
+}
+
+func TestRenameProfile_DifferentCase(t *testing.T) {
+	env := gidtreetest.NewEnv(t).
+		WithProfile(profile.Profile{Name: "work", Email: "work@example.com"}).
+		WithMapping("work", "work").
+		Build()
+	if err := config.SetDefaultProfile("work"); err != nil {
+		t.Fatalf("SetDefaultProfile() error = %v", err)
+	}
+
+	manager, err := profile.NewDefaultManager()
+	if err != nil {
+		t.Fatalf("NewDefaultManager() error = %v", err)
+	}
+	var out bytes.Buffer
+	if err := renameProfile(&out, manager, "WORK", "client"); err != nil {
+		t.Fatalf("renameProfile() error = %v", err)
+	}
+	if !strings.Contains(out.String(), "Renamed profile 'work' to 'client'") {
+		t.Errorf("renameProfile() output = %q, want the stored name", out.String())
+	}
+
+	m, err := mapping.GetMappingForDirectory(env.Path("work"))
+	if err != nil || m == nil || m.Profile != "client" {
+		t.Errorf("GetMappingForDirectory() = %+v, %v, want profile 'client'", m, err)
+	}
+	if _, err := os.Stat(env.FragmentPath("work")); !os.IsNotExist(err) {
+		t.Errorf("old fragment still exists: %v", err)
+	}
+	cfg, err := config.Load()
+	if err != nil || cfg.DefaultProfile != "client" {
+		t.Errorf("default profile = %q, %v, want 'client'", cfg.DefaultProfile, err)
+	}
+}
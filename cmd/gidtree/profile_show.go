@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/thuanlegit/git-identitree/internal/identity"
+	"github.com/thuanlegit/git-identitree/internal/profile"
+
+	"github.com/spf13/cobra"
+)
+
+var profileShowJSON bool
+
+var profileShowCmd = &cobra.Command{
+	Use:   "show <name>",
+	Short: "Show a profile and everything derived from it",
+	Long: `Print every field of a profile together with what gidtree derives from it:
+the generated git config file and whether it exists, the directories mapped to
+the profile, whether the SSH key exists and is loaded in the agent, and whether
+the GPG key is in the keyring.`,
+	Example: `  gidtree profile show work
+  gidtree profile show work --json`,
+	Args: cobra.ExactArgs(1),
+	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) != 0 {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		manager, err := profile.NewDefaultManager()
+		if err != nil {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		profiles := manager.ListProfiles()
+		var names []string
+		for _, p := range profiles {
+			names = append(names, p.Name)
+		}
+		return names, cobra.ShellCompDirectiveNoFileComp
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		asJSON, err := jsonOutput(cmd, profileShowJSON)
+		if err != nil {
+			return err
+		}
+		manager, err := profile.NewDefaultManager()
+		if err != nil {
+			return fmt.Errorf("failed to initialize profile manager: %w", err)
+		}
+		prof, err := manager.GetProfile(args[0])
+		if err != nil {
+			return err
+		}
+		detail, err := identity.DescribeProfile(prof)
+		if err != nil {
+			return fmt.Errorf("failed to describe profile: %w", err)
+		}
+		return showProfile(cmd.OutOrStdout(), detail, asJSON)
+	},
+}
+
+// showProfile writes the detail of a profile to w as "Label: value" lines or
+// as JSON.
+func showProfile(w io.Writer, detail identity.ProfileDetail, asJSON bool) error {
+	if asJSON {
+		return writeJSON(w, detail)
+	}
+	for _, fact := range detail.Facts() {
+		_, _ = fmt.Fprintf(w, "%s: %s\n", fact.Label, fact.Value)
+	}
+	return nil
+}
+
+func init() {
+	profileShowCmd.Flags().BoolVar(&profileShowJSON, "json", false, "Print the profile detail as JSON")
+}
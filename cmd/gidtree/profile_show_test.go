@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/thuanlegit/git-identitree/internal/identity"
+	"github.com/thuanlegit/git-identitree/internal/profile"
+	"github.com/thuanlegit/git-identitree/internal/utils"
+	"github.com/thuanlegit/git-identitree/pkg/gidtree/gidtreetest"
+)
+
+func TestShowProfile(t *testing.T) {
+	env := gidtreetest.NewEnv(t).
+		WithProfile(profile.Profile{Name: "work", Email: "work@example.com"}).
+		WithMapping("work", "code/acme").
+		Build()
+	manager, err := profile.NewDefaultManager()
+	if err != nil {
+		t.Fatalf("NewDefaultManager() error = %v", err)
+	}
+	prof, err := manager.GetProfile("work")
+	if err != nil {
+		t.Fatalf("GetProfile() error = %v", err)
+	}
+	detail, err := identity.DescribeProfile(prof)
+	if err != nil {
+		t.Fatalf("DescribeProfile() error = %v", err)
+	}
+
+	var out bytes.Buffer
+	if err := showProfile(&out, detail, false); err != nil {
+		t.Fatalf("showProfile() error = %v", err)
+	}
+	for _, want := range []string{
+		"Name: work\n",
+		"Email: work@example.com\n",
+		"SSH Key: none\n",
+		"GPG Key: none\n",
+		"Git Config: ~/.gitconfig-work (exists)\n",
+		"Mapped: " + utils.AbbreviateHome(env.Path("code/acme")) + "/\n",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("showProfile() output = %q, want %q", out.String(), want)
+		}
+	}
+
+	out.Reset()
+	if err := showProfile(&out, detail, true); err != nil {
+		t.Fatalf("showProfile() JSON error = %v", err)
+	}
+	var got struct {
+		ConfigExists bool     `json:"config_exists"`
+		Directories  []string `json:"directories"`
+		KeyState     string   `json:"key_state"`
+	}
+	if err := json.Unmarshal(out.Bytes(), &got); err != nil {
+		t.Fatalf("showProfile() JSON = %q: %v", out.String(), err)
+	}
+	if !got.ConfigExists || len(got.Directories) != 1 || got.KeyState != string(identity.KeyNone) {
+		t.Errorf("showProfile() JSON = %+v", got)
+	}
+}
+
+func TestProfileShowCommand_MissingProfile(t *testing.T) {
+	gidtreetest.NewEnv(t).Build()
+
+	err := profileShowCmd.RunE(profileShowCmd, []string{"missing"})
+	if !errors.Is(err, profile.ErrProfileNotFound) {
+		t.Errorf("show of a missing profile error = %v, want ErrProfileNotFound", err)
+	}
+}
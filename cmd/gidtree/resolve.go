@@ -0,0 +1,153 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"text/template"
+
+	"github.com/thuanlegit/git-identitree/internal/config"
+	"github.com/thuanlegit/git-identitree/internal/identity"
+	"github.com/thuanlegit/git-identitree/internal/mapping"
+	"github.com/thuanlegit/git-identitree/internal/utils"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	resolveJSON    bool
+	resolveFormat  string
+	resolveExplain bool
+)
+
+var resolveCmd = &cobra.Command{
+	Use:     "resolve [path]",
+	Aliases: []string{"which"},
+	Short:   "Print the profile mapped to a directory",
+	Long:    "Print the name of the profile that applies to a directory (default: the current directory). Falls back to the default profile (see 'gidtree profile default') and exits with status 5 and no output when neither applies. With --json (or output_format set to json), the full identity summary is printed instead; --format prints it with a Go template such as '{{.Profile.Email}}' ('--format help' lists the fields). --explain prints how the profile was picked instead: every mapping considered, whether it matched the path exactly or as a prefix, which lost to a more specific mapping, and the winner; the path does not have to exist, so layouts can be planned before creating them.",
+	Args:    cobra.MaximumNArgs(1),
+	// Scripts branch on the exit status; there is nothing to explain
+	SilenceErrors: true,
+	SilenceUsage:  true,
+	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return nil, cobra.ShellCompDirectiveFilterDirs
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if resolveFormat == formatHelpValue {
+			writeFormatHelp(cmd.OutOrStdout(), identity.Summary{})
+			return nil
+		}
+		if resolveExplain {
+			if resolveJSON || resolveFormat != "" {
+				return fmt.Errorf("--explain cannot be combined with --json or --format")
+			}
+			dir := "."
+			if len(args) == 1 {
+				dir = args[0]
+			}
+			return explainResolution(cmd.OutOrStdout(), dir)
+		}
+
+		var tmpl *template.Template
+		if resolveFormat != "" {
+			if resolveJSON {
+				return fmt.Errorf("--json and --format cannot be combined")
+			}
+			var err error
+			if tmpl, err = parseFormat(resolveFormat, identity.Summary{}); err != nil {
+				return err
+			}
+		}
+
+		dir := "."
+		if len(args) == 1 {
+			dir = args[0]
+		}
+		dir, err := filepath.Abs(dir)
+		if err != nil {
+			return fmt.Errorf("failed to resolve path: %w", err)
+		}
+
+		summary, err := identity.Summarize(dir)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return &exitCodeError{code: exitStatus(err)}
+		}
+
+		asJSON, err := jsonOutput(cmd, resolveJSON)
+		if err != nil {
+			return err
+		}
+
+		markUsed(summary.Profile)
+		if tmpl != nil {
+			if summary.Profile != nil {
+				if err := writeFormatted(cmd.OutOrStdout(), tmpl, summary); err != nil {
+					return err
+				}
+			}
+		} else if asJSON {
+			if err := writeJSON(os.Stdout, summary); err != nil {
+				return err
+			}
+		} else if summary.Profile != nil {
+			fmt.Println(summary.Profile.Name)
+		}
+
+		if summary.Profile == nil {
+			return &exitCodeError{code: exitMappingNotFound}
+		}
+		return nil
+	},
+}
+
+// explainResolution writes the mappings considered for dir, from the winner
+// to the mappings that do not match, and the profile that applies. It exits
+// with status 5 when no mapping or default profile applies.
+func explainResolution(w io.Writer, dir string) error {
+	normalized, candidates, err := mapping.ExplainMappingForDirectory(dir)
+	if err != nil {
+		return fmt.Errorf("failed to resolve mappings: %w", err)
+	}
+
+	_, _ = fmt.Fprintf(w, "Resolving %s\n", utils.AbbreviateHome(normalized))
+	if len(candidates) == 0 {
+		_, _ = fmt.Fprintln(w, "  no directory mappings")
+	}
+	var winner *mapping.Mapping
+	for _, c := range candidates {
+		mark, outcome := "✗", "no match"
+		switch {
+		case c.Winner:
+			mark, outcome = "✓", string(c.Match)+" match, wins"
+			winner = &c.Mapping
+		case c.Match != mapping.MatchNone && c.Mapping.Duplicate:
+			outcome = string(c.Match) + " match, overridden by a later block for the same directory"
+		case c.Match != mapping.MatchNone:
+			outcome = string(c.Match) + " match, lost to the longer " + utils.AbbreviateHome(winner.Directory)
+		}
+		_, _ = fmt.Fprintf(w, "  %s %s → %s (%s)\n", mark, utils.AbbreviateHome(c.Mapping.Directory), c.Mapping.Profile, outcome)
+	}
+
+	if winner != nil {
+		_, _ = fmt.Fprintf(w, "Result: %s\n", winner.Profile)
+		return nil
+	}
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	if cfg.DefaultProfile != "" {
+		_, _ = fmt.Fprintf(w, "Result: %s (default profile, no mapping matches)\n", cfg.DefaultProfile)
+		return nil
+	}
+	_, _ = fmt.Fprintln(w, "Result: no profile applies")
+	return &exitCodeError{code: exitMappingNotFound}
+}
+
+func init() {
+	resolveCmd.Flags().BoolVar(&resolveExplain, "explain", false, "Print every mapping considered for the path and which one wins")
+	resolveCmd.Flags().BoolVar(&resolveJSON, "json", false, "Print the identity summary as JSON")
+	resolveCmd.Flags().StringVar(&resolveFormat, "format", "", "Print the identity summary with a Go template; 'help' lists the fields")
+}
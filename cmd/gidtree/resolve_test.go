@@ -0,0 +1,44 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/thuanlegit/git-identitree/internal/profile"
+	"github.com/thuanlegit/git-identitree/internal/utils"
+	"github.com/thuanlegit/git-identitree/pkg/gidtree/gidtreetest"
+)
+
+func TestExplainResolution(t *testing.T) {
+	env := gidtreetest.NewEnv(t).
+		WithProfile(profile.Profile{Name: "work", Email: "work@example.com"}).
+		WithProfile(profile.Profile{Name: "acme", Email: "jane@acme.com"}).
+		WithProfile(profile.Profile{Name: "personal", Email: "me@example.com"}).
+		WithMapping("work", "code").
+		WithMapping("acme", "code/acme").
+		WithMapping("personal", "oss").
+		Build()
+
+	var out bytes.Buffer
+	if err := explainResolution(&out, env.Path("code/acme/new-service")); err != nil {
+		t.Fatalf("explainResolution() error = %v", err)
+	}
+	want := "Resolving " + utils.AbbreviateHome(env.Path("code/acme/new-service")) + "/\n" +
+		"  ✓ " + utils.AbbreviateHome(env.Path("code/acme")) + "/ → acme (prefix match, wins)\n" +
+		"  ✗ " + utils.AbbreviateHome(env.Path("code")) + "/ → work (prefix match, lost to the longer " + utils.AbbreviateHome(env.Path("code/acme")) + "/)\n" +
+		"  ✗ " + utils.AbbreviateHome(env.Path("oss")) + "/ → personal (no match)\n" +
+		"Result: acme\n"
+	if out.String() != want {
+		t.Errorf("explainResolution() output =\n%s\nwant\n%s", out.String(), want)
+	}
+
+	out.Reset()
+	err := explainResolution(&out, env.Path("elsewhere/planned"))
+	if exitStatus(err) != exitMappingNotFound {
+		t.Errorf("explainResolution() outside mappings error = %v, want exit status %d", err, exitMappingNotFound)
+	}
+	if !strings.HasSuffix(out.String(), "Result: no profile applies\n") {
+		t.Errorf("explainResolution() outside mappings output = %q", out.String())
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/thuanlegit/git-identitree/internal/profile"
+	"github.com/thuanlegit/git-identitree/internal/signers"
+	"github.com/thuanlegit/git-identitree/internal/utils"
+
+	"github.com/spf13/cobra"
+)
+
+var signersCmd = &cobra.Command{
+	Use:   "signers",
+	Short: "Manage the keys trusted to verify SSH-signed commits",
+	Long: `Manage a profile's allowed signers file, ~/.gidtree/allowed_signers-<profile>,
+which git reads through gpg.ssh.allowedSignersFile to verify SSH-signed commits.
+For a profile with ssh_signing, gidtree adds the profile's own key when it
+writes the profile's git config; add your collaborators' keys here to verify
+their commits too.`,
+}
+
+var signersAddCmd = &cobra.Command{
+	Use:   "add <profile> <entry>",
+	Short: "Trust a key to sign as an email",
+	Long:  `Add an entry to the profile's allowed signers file, in ssh-keygen's format: the emails the key may sign as (comma-separated), optional options such as namespaces="git", and the public key as in a .pub file.`,
+	Example: `  gidtree signers add work "ada@example.com ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAI..."
+  gidtree signers add work 'ada@example.com namespaces="git" ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAI...'`,
+	Args:              cobra.ExactArgs(2),
+	ValidArgsFunction: completeProfileNames,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runSignersAdd(cmd.OutOrStdout(), args[0], args[1])
+	},
+}
+
+var signersRemoveCmd = &cobra.Command{
+	Use:               "remove <profile> <email|key>",
+	Short:             "Stop trusting a key",
+	Long:              `Remove every entry of the profile's allowed signers file that lists the email as a principal, or that holds the key, given as "keytype base64".`,
+	Args:              cobra.ExactArgs(2),
+	ValidArgsFunction: completeProfileNames,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if _, err := lookupProfile(args[0]); err != nil {
+			return err
+		}
+		removed, err := signers.Remove(args[0], args[1])
+		if err != nil {
+			return err
+		}
+		if removed == 0 {
+			return fmt.Errorf("no entry for '%s' in the allowed signers of profile '%s'", args[1], args[0])
+		}
+		_, _ = fmt.Fprintf(cmd.OutOrStdout(), "✓ Removed %d entries\n", removed)
+		return nil
+	},
+}
+
+var signersListCmd = &cobra.Command{
+	Use:               "list <profile>",
+	Short:             "Print the keys trusted for a profile",
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeProfileNames,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if _, err := lookupProfile(args[0]); err != nil {
+			return err
+		}
+		entries, err := signers.List(args[0])
+		if err != nil {
+			return err
+		}
+		if len(entries) == 0 {
+			_, _ = fmt.Fprintf(cmd.OutOrStdout(), "No allowed signers for profile '%s'\n", args[0])
+			return nil
+		}
+		for _, e := range entries {
+			_, _ = fmt.Fprintln(cmd.OutOrStdout(), e.String())
+		}
+		return nil
+	},
+}
+
+// runSignersAdd adds entry to the allowed signers of the named profile.
+func runSignersAdd(w io.Writer, name, entry string) error {
+	prof, err := lookupProfile(name)
+	if err != nil {
+		return err
+	}
+	added, err := signers.Add(name, entry)
+	if err != nil {
+		return err
+	}
+	path, err := signers.Path(name)
+	if err != nil {
+		return err
+	}
+	if !added {
+		_, _ = fmt.Fprintf(w, "Already in %s\n", utils.AbbreviateHome(path))
+		return nil
+	}
+	_, _ = fmt.Fprintf(w, "✓ Added to %s\n", utils.AbbreviateHome(path))
+	if !prof.SSHSigning {
+		_, _ = fmt.Fprintf(w, "⚠ Profile '%s' does not sign with SSH; git uses this file once ssh_signing is set\n", name)
+	}
+	return nil
+}
+
+// lookupProfile returns the named profile.
+func lookupProfile(name string) (*profile.Profile, error) {
+	manager, err := profile.NewDefaultManager()
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize profile manager: %w", err)
+	}
+	prof, err := manager.GetProfile(name)
+	if err != nil {
+		return nil, fmt.Errorf("profile not found: %w", err)
+	}
+	return prof, nil
+}
+
+func init() {
+	signersCmd.AddCommand(signersAddCmd)
+	signersCmd.AddCommand(signersRemoveCmd)
+	signersCmd.AddCommand(signersListCmd)
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/thuanlegit/git-identitree/internal/profile"
+	"github.com/thuanlegit/git-identitree/internal/signers"
+	"github.com/thuanlegit/git-identitree/internal/utils"
+	"github.com/thuanlegit/git-identitree/pkg/gidtree/gidtreetest"
+)
+
+// requireSSHSigning skips the test unless git can sign with SSH keys, which
+// needs git 2.34, and ssh-keygen is installed.
+func requireSSHSigning(t *testing.T) {
+	t.Helper()
+	if _, err := exec.LookPath("ssh-keygen"); err != nil {
+		t.Skip("ssh-keygen not available")
+	}
+	out, err := utils.Command("git", "--version").Output()
+	if err != nil {
+		t.Skip("git not available")
+	}
+	var major, minor int
+	if _, err := fmt.Sscanf(strings.TrimPrefix(strings.TrimSpace(string(out)), "git version "), "%d.%d", &major, &minor); err != nil {
+		t.Skipf("unrecognized git version %q", out)
+	}
+	if major < 2 || major == 2 && minor < 34 {
+		t.Skipf("git %d.%d cannot sign with SSH keys", major, minor)
+	}
+}
+
+// newSSHKey generates an unencrypted ed25519 key at path.
+func newSSHKey(t *testing.T, path string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		t.Fatalf("Failed to create key directory: %v", err)
+	}
+	if out, err := exec.Command("ssh-keygen", "-q", "-t", "ed25519", "-N", "", "-C", "test", "-f", path).CombinedOutput(); err != nil {
+		t.Fatalf("ssh-keygen failed: %v\n%s", err, out)
+	}
+}
+
+func TestRunSignersAdd(t *testing.T) {
+	gidtreetest.NewEnv(t).
+		WithProfile(profile.Profile{Name: "work", Email: "work@example.com"}).
+		Build()
+	entry := "ada@example.com ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIK2tra2tra2tra2tra2tra2tra2tra2tra2tra2tra2t"
+
+	var out bytes.Buffer
+	if err := runSignersAdd(&out, "work", entry); err != nil {
+		t.Fatalf("runSignersAdd() error = %v", err)
+	}
+	if !strings.Contains(out.String(), "✓ Added to ~/.gidtree/allowed_signers-work") {
+		t.Errorf("runSignersAdd() output = %q, want the file", out.String())
+	}
+	if !strings.Contains(out.String(), "does not sign with SSH") {
+		t.Errorf("runSignersAdd() output = %q, want a warning for a profile without ssh_signing", out.String())
+	}
+
+	out.Reset()
+	if err := runSignersAdd(&out, "work", entry); err != nil {
+		t.Fatalf("runSignersAdd() error = %v", err)
+	}
+	if !strings.HasPrefix(out.String(), "Already in") {
+		t.Errorf("runSignersAdd() output = %q, want it already there", out.String())
+	}
+
+	if err := runSignersAdd(&out, "work", "ada@example.com"); err == nil {
+		t.Error("runSignersAdd() should reject an invalid entry")
+	}
+	if err := runSignersAdd(&out, "missing", entry); err == nil {
+		t.Error("runSignersAdd() should fail for an unknown profile")
+	}
+
+	out.Reset()
+	signersListCmd.SetOut(&out)
+	defer signersListCmd.SetOut(nil)
+	if err := signersListCmd.RunE(signersListCmd, []string{"work"}); err != nil {
+		t.Fatalf("signers list error = %v", err)
+	}
+	if out.String() != entry+"\n" {
+		t.Errorf("signers list = %q, want the entry", out.String())
+	}
+
+	signersRemoveCmd.SetOut(&out)
+	defer signersRemoveCmd.SetOut(nil)
+	if err := signersRemoveCmd.RunE(signersRemoveCmd, []string{"work", "ada@example.com"}); err != nil {
+		t.Fatalf("signers remove error = %v", err)
+	}
+	if err := signersRemoveCmd.RunE(signersRemoveCmd, []string{"work", "ada@example.com"}); err == nil {
+		t.Error("signers remove should fail when nothing matches")
+	}
+	if entries, err := signers.List("work"); err != nil || len(entries) != 0 {
+		t.Errorf("List() = %v, %v, want none", entries, err)
+	}
+}
+
+func TestSSHSigning_VerifiesCommits(t *testing.T) {
+	requireSSHSigning(t)
+	env := gidtreetest.NewEnv(t).
+		WithGitRepo("work/repo").
+		Build()
+	keyPath := env.Path(".ssh/id_work")
+	newSSHKey(t, keyPath)
+
+	client := env.Client()
+	if err := client.AddProfile(profile.Profile{Name: "work", Email: "work@example.com", SSHKeyPath: keyPath, SSHSigning: true}); err != nil {
+		t.Fatalf("AddProfile() error = %v", err)
+	}
+	if err := client.Map("work", env.Path("work")); err != nil {
+		t.Fatalf("Map() error = %v", err)
+	}
+	repo := env.Path("work/repo")
+
+	if err := os.WriteFile(filepath.Join(repo, "file.txt"), []byte("x"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	gitIn(t, repo, "add", "file.txt")
+	gitIn(t, repo, "commit", "-q", "-S", "-m", "signed")
+	if out, err := utils.Command("git", "-C", repo, "verify-commit", "HEAD").CombinedOutput(); err != nil {
+		t.Fatalf("git verify-commit failed: %v\n%s", err, out)
+	}
+
+	// A commit signed by a key nobody trusts does not verify until it is added
+	otherKey := env.Path(".ssh/id_other")
+	newSSHKey(t, otherKey)
+	if err := os.WriteFile(filepath.Join(repo, "file.txt"), []byte("y"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	gitIn(t, repo, "-c", "user.signingkey="+otherKey+".pub", "commit", "-q", "-a", "-S", "-m", "other")
+	if out, err := utils.Command("git", "-C", repo, "verify-commit", "HEAD").CombinedOutput(); err == nil {
+		t.Fatalf("git verify-commit accepted an untrusted key:\n%s", out)
+	}
+
+	pub, err := os.ReadFile(otherKey + ".pub")
+	if err != nil {
+		t.Fatalf("Failed to read public key: %v", err)
+	}
+	if err := runSignersAdd(&bytes.Buffer{}, "work", "work@example.com "+string(pub)); err != nil {
+		t.Fatalf("runSignersAdd() error = %v", err)
+	}
+	if out, err := utils.Command("git", "-C", repo, "verify-commit", "HEAD").CombinedOutput(); err != nil {
+		t.Errorf("git verify-commit failed after adding the key: %v\n%s", err, out)
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/thuanlegit/git-identitree/internal/profile"
+	"github.com/thuanlegit/git-identitree/internal/ssh"
+	"github.com/thuanlegit/git-identitree/internal/utils"
+)
+
+// sshLoadConvert lets ssh load convert a PuTTY key first (--convert).
+var sshLoadConvert bool
+
+// convertProfileKey converts prof's SSH key when it is a PuTTY key: an
+// OpenSSH copy is written next to it and the profile pointed at the copy,
+// which is returned. Profiles with other keys are returned unchanged.
+func convertProfileKey(w io.Writer, manager *profile.Manager, prof *profile.Profile) (*profile.Profile, error) {
+	path, err := utils.ExpandPath(prof.SSHKeyPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to expand SSH key path: %w", err)
+	}
+	if format, err := ssh.SniffKeyFile(path); err != nil || format != ssh.FormatPuTTY {
+		return prof, nil
+	}
+
+	converted, err := ssh.ConvertPPKFile(path)
+	if err != nil {
+		return nil, err
+	}
+	updated := *prof
+	updated.SSHKeyPath = profile.ConvertedKeyPath(prof.SSHKeyPath)
+	if err := manager.UpdateProfile(prof.Name, updated); err != nil {
+		return nil, fmt.Errorf("converted the key to %s, but failed to update profile '%s': %w", utils.AbbreviateHome(converted), prof.Name, err)
+	}
+	_, _ = fmt.Fprintf(w, "✓ Converted PuTTY key %s to %s; profile '%s' now uses it\n", prof.SSHKeyPath, updated.SSHKeyPath, prof.Name)
+	return manager.GetProfile(prof.Name)
+}
+
+func init() {
+	sshLoadCmd.Flags().BoolVar(&sshLoadConvert, "convert", false, "Convert a PuTTY key to OpenSSH format next to it first, and point the profile at the copy")
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/thuanlegit/git-identitree/internal/profile"
+	"github.com/thuanlegit/git-identitree/internal/ssh"
+	"github.com/thuanlegit/git-identitree/pkg/gidtree/gidtreetest"
+)
+
+func TestConvertProfileKey(t *testing.T) {
+	env := gidtreetest.NewEnv(t).Build()
+	data, err := os.ReadFile(filepath.Join("..", "..", "internal", "ssh", "testdata", "keys", "id_ed25519.ppk"))
+	if err != nil {
+		t.Fatalf("Failed to read fixture: %v", err)
+	}
+	sshDir := filepath.Join(env.Home(), ".ssh")
+	if err := os.MkdirAll(sshDir, 0700); err != nil {
+		t.Fatalf("Failed to create .ssh: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(sshDir, "work.ppk"), data, 0600); err != nil {
+		t.Fatalf("Failed to write key: %v", err)
+	}
+	// Written directly, as the manager no longer accepts PuTTY keys
+	if err := profile.SaveProfiles([]profile.Profile{{Name: "work", Email: "work@example.com", SSHKeyPath: "~/.ssh/work.ppk"}}); err != nil {
+		t.Fatalf("SaveProfiles() error = %v", err)
+	}
+	manager, err := profile.NewDefaultManager()
+	if err != nil {
+		t.Fatalf("NewDefaultManager() error = %v", err)
+	}
+	prof, _ := manager.GetProfile("work")
+
+	var out bytes.Buffer
+	converted, err := convertProfileKey(&out, manager, prof)
+	if err != nil {
+		t.Fatalf("convertProfileKey() error = %v", err)
+	}
+	if converted.SSHKeyPath != "~/.ssh/work" || converted.SSHKeyFingerprint == "" {
+		t.Errorf("converted profile = %+v, want it to use ~/.ssh/work with a fingerprint", converted)
+	}
+	if format, err := ssh.SniffKeyFile(filepath.Join(sshDir, "work")); err != nil || format != ssh.FormatPrivate {
+		t.Errorf("converted key format = %v, %v; want a private key", format, err)
+	}
+	if !strings.Contains(out.String(), "Converted PuTTY key ~/.ssh/work.ppk to ~/.ssh/work") {
+		t.Errorf("output = %q", out.String())
+	}
+
+	// An OpenSSH key is left alone
+	out.Reset()
+	again, err := convertProfileKey(&out, manager, converted)
+	if err != nil || again != converted || out.Len() != 0 {
+		t.Errorf("convertProfileKey(OpenSSH key) = %+v, %v, printed %q; want the profile unchanged", again, err, out.String())
+	}
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"github.com/thuanlegit/git-identitree/internal/config"
+	"github.com/thuanlegit/git-identitree/internal/ssh"
+	"github.com/thuanlegit/git-identitree/internal/utils"
+)
+
+// configureSSH applies the SSH agent and tool settings of config.yaml to the ssh
+// package. A config file that cannot be read leaves the defaults, so that
+// 'gidtree config set' can still fix it; commands that need the config
+// report the error themselves.
+func configureSSH() {
+	cfg, err := config.Load()
+	if err != nil {
+		return
+	}
+	ssh.AgentTimeout = cfg.AgentTimeout
+	ssh.SSHAddPath = toolPath(cfg.SSHAddPath, "ssh-add")
+	ssh.SSHKeygenPath = toolPath(cfg.SSHKeygenPath, "ssh-keygen")
+	ssh.Offline = cfg.Offline
+}
+
+// toolPath returns the program configured for a tool, with ~ and variables
+// expanded, or the tool's name to look up in PATH when none is.
+func toolPath(configured, name string) string {
+	if configured == "" {
+		return name
+	}
+	if expanded, err := utils.ExpandPath(configured); err == nil {
+		return expanded
+	}
+	return configured
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/thuanlegit/git-identitree/internal/config"
+	"github.com/thuanlegit/git-identitree/internal/ssh"
+	"github.com/thuanlegit/git-identitree/pkg/gidtree/gidtreetest"
+)
+
+func TestConfigureSSH(t *testing.T) {
+	gidtreetest.NewEnv(t).Build()
+	previous := ssh.AgentTimeout
+	t.Cleanup(func() { ssh.AgentTimeout = previous })
+
+	cfg := config.Default()
+	cfg.AgentTimeout = 3 * time.Second
+	if err := config.Save(&cfg); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	configureSSH()
+	if ssh.AgentTimeout != 3*time.Second {
+		t.Errorf("AgentTimeout = %s, want agent_timeout from config.yaml", ssh.AgentTimeout)
+	}
+}
+
+func TestConfigureSSH_Tools(t *testing.T) {
+	env := gidtreetest.NewEnv(t).Build()
+	prevAdd, prevKeygen, prevOffline := ssh.SSHAddPath, ssh.SSHKeygenPath, ssh.Offline
+	t.Cleanup(func() { ssh.SSHAddPath, ssh.SSHKeygenPath, ssh.Offline = prevAdd, prevKeygen, prevOffline })
+
+	configureSSH()
+	if ssh.SSHAddPath != "ssh-add" || ssh.SSHKeygenPath != "ssh-keygen" || ssh.Offline {
+		t.Errorf("defaults = %q, %q, offline %v; want the tools in PATH", ssh.SSHAddPath, ssh.SSHKeygenPath, ssh.Offline)
+	}
+
+	cfg := config.Default()
+	cfg.SSHAddPath = "~/bin/ssh-add"
+	cfg.SSHKeygenPath = "/opt/openssh/bin/ssh-keygen"
+	cfg.Offline = true
+	if err := config.Save(&cfg); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	configureSSH()
+	if want := filepath.Join(env.Home(), "bin", "ssh-add"); ssh.SSHAddPath != want {
+		t.Errorf("SSHAddPath = %q, want %q", ssh.SSHAddPath, want)
+	}
+	if ssh.SSHKeygenPath != "/opt/openssh/bin/ssh-keygen" {
+		t.Errorf("SSHKeygenPath = %q", ssh.SSHKeygenPath)
+	}
+	if !ssh.Offline {
+		t.Error("Offline = false, want offline from config.yaml")
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/thuanlegit/git-identitree/internal/config"
+	"github.com/thuanlegit/git-identitree/internal/identity"
+	"github.com/thuanlegit/git-identitree/internal/profile"
+	"github.com/thuanlegit/git-identitree/internal/ssh"
+	"github.com/thuanlegit/git-identitree/internal/utils"
+
+	"github.com/spf13/cobra"
+)
+
+var sshStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show the SSH agent and which profile keys it holds",
+	Long: `Show which SSH agent SSH_AUTH_SOCK points at, whether it answers and
+whether keys can be removed from it, then whether each profile's key is loaded.
+
+The agent is recognized from its socket path or, failing that, from the
+protocol extensions it lists. 1Password, Secretive, Bitwarden and gpg-agent
+serve keys they manage themselves and do not let ssh-add remove them, so ssh
+unload and exclusive_keys leave keys loaded there. Git still offers only each
+profile's key, as the generated sshCommand sets IdentitiesOnly.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		w := cmd.OutOrStdout()
+		agent := ssh.DetectAgent()
+		if ssh.Offline {
+			_, _ = fmt.Fprintln(w, "SSH agent: not checked, as offline is set in config.yaml")
+		} else if agent.Socket == "" {
+			_, _ = fmt.Fprintln(w, "SSH agent: SSH_AUTH_SOCK is not set; ssh-add looks for the system agent")
+		} else {
+			_, _ = fmt.Fprintf(w, "SSH agent: %s at %s\n", agent.Name(), utils.AbbreviateHome(agent.Socket))
+			if err := ssh.CheckAgent(); err != nil {
+				return err
+			}
+			if !agent.CanRemoveKeys() {
+				_, _ = fmt.Fprintf(w, "Keys cannot be removed from %s; git relies on IdentitiesOnly to use each profile's key\n", agent.Name())
+			}
+		}
+
+		manager, err := profile.NewDefaultManager()
+		if err != nil {
+			return fmt.Errorf("failed to initialize profile manager: %w", err)
+		}
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		for _, p := range manager.ListProfiles() {
+			if p.SSHKeyPath == "" {
+				continue
+			}
+			_, _ = fmt.Fprintf(tw, "  %s\t%s\t%s\n", p.Name, p.SSHKeyPath, keyStateText(identity.CheckKeyState(p.SSHKeyPath)))
+		}
+		return tw.Flush()
+	},
+}
+
+// keyStateText describes a key state in ssh status.
+func keyStateText(state identity.KeyState) string {
+	switch state {
+	case identity.KeyLoaded:
+		return "loaded"
+	case identity.KeyNotLoaded:
+		return "not loaded"
+	case identity.KeyMissing:
+		return "key file missing"
+	case identity.KeyAgentUnreachable:
+		return "agent unreachable"
+	case identity.KeyOffline:
+		return "not checked"
+	}
+	return string(state)
+}
+
+// checkSSHAgent reports which SSH agent SSH_AUTH_SOCK points at and returns 1
+// when it does not answer, or when exclusive_keys is on but the agent cannot
+// remove keys. Without SSH_AUTH_SOCK, or in offline mode, there is nothing to
+// check.
+func checkSSHAgent(w io.Writer) (int, error) {
+	if ssh.Offline {
+		_, _ = fmt.Fprintln(w, "- SSH agent not checked: offline is set in config.yaml")
+		return 0, nil
+	}
+	agent := ssh.DetectAgent()
+	if agent.Socket == "" {
+		return 0, nil
+	}
+	if err := ssh.CheckAgent(); err != nil {
+		_, _ = fmt.Fprintf(w, "⚠ %v\n", err)
+		return 1, nil
+	}
+	_, _ = fmt.Fprintf(w, "✓ SSH agent: %s at %s\n", agent.Name(), utils.AbbreviateHome(agent.Socket))
+	if agent.CanRemoveKeys() {
+		return 0, nil
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return 0, fmt.Errorf("failed to load config: %w", err)
+	}
+	if !cfg.ExclusiveKeys {
+		_, _ = fmt.Fprintf(w, "  - %s cannot remove keys; git relies on IdentitiesOnly to use each profile's key\n", agent.Name())
+		return 0, nil
+	}
+	_, _ = fmt.Fprintf(w, "⚠ exclusive_keys is on, but %s cannot remove keys, so other profiles' keys stay loaded; git relies on IdentitiesOnly to use each profile's key\n", agent.Name())
+	_, _ = fmt.Fprintln(w, "  Turn it off with: gidtree config set exclusive_keys false")
+	return 1, nil
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bytes"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/thuanlegit/git-identitree/internal/config"
+	"github.com/thuanlegit/git-identitree/internal/profile"
+	"github.com/thuanlegit/git-identitree/pkg/gidtree/gidtreetest"
+
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// serveTestAgent serves an empty in-memory SSH agent on a Unix socket at
+// name, under a new temporary directory, and points SSH_AUTH_SOCK at it.
+func serveTestAgent(t *testing.T, name string) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("the SSH agent is not probed on Windows")
+	}
+	// Socket paths are limited to about 100 bytes, which t.TempDir can exceed
+	dir, err := os.MkdirTemp("", "agent")
+	if err != nil {
+		t.Fatalf("MkdirTemp() error = %v", err)
+	}
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+	socket := filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Dir(socket), 0700); err != nil {
+		t.Fatalf("MkdirAll() error = %v", err)
+	}
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	t.Cleanup(func() { _ = listener.Close() })
+	keyring := agent.NewKeyring()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer func() { _ = conn.Close() }()
+				_ = agent.ServeAgent(keyring, conn)
+			}(conn)
+		}
+	}()
+	t.Setenv("SSH_AUTH_SOCK", socket)
+}
+
+func TestSSHStatus(t *testing.T) {
+	if _, err := exec.LookPath("ssh-add"); err != nil {
+		t.Skip("ssh-add not available")
+	}
+	key := filepath.Join(t.TempDir(), "id_work")
+	writeKey(t, key)
+	gidtreetest.NewEnv(t).
+		WithProfile(profile.Profile{Name: "work", Email: "work@example.com", SSHKeyPath: key}).
+		Build()
+	serveTestAgent(t, filepath.Join(".1password", "agent.sock"))
+
+	stdout, stderr, code := runCLIOutput(t, "ssh", "status")
+	if code != 0 {
+		t.Fatalf("ssh status exit code = %d, stderr = %q", code, stderr)
+	}
+	for _, want := range []string{"SSH agent: 1Password at ", "Keys cannot be removed from 1Password", "work", "not loaded"} {
+		if !strings.Contains(stdout, want) {
+			t.Errorf("ssh status output = %q, want %q", stdout, want)
+		}
+	}
+
+	// A read-only agent makes unload a no-op rather than a failure
+	if code := runCLI(t, "ssh", "unload", "work"); code != 0 {
+		t.Errorf("ssh unload with a read-only agent exit code = %d, want 0", code)
+	}
+
+	t.Setenv("SSH_AUTH_SOCK", filepath.Join(t.TempDir(), "gone.sock"))
+	if _, _, code := runCLIOutput(t, "ssh", "status"); code == 0 {
+		t.Error("ssh status with an unreachable agent exit code = 0, want a failure")
+	}
+}
+
+func TestCheckSSHAgent(t *testing.T) {
+	gidtreetest.NewEnv(t).Build()
+	var out bytes.Buffer
+
+	t.Setenv("SSH_AUTH_SOCK", "")
+	if problems, err := checkSSHAgent(&out); err != nil || problems != 0 || out.Len() != 0 {
+		t.Errorf("checkSSHAgent() without SSH_AUTH_SOCK = %d, %v, printed %q; want nothing", problems, err, out.String())
+	}
+
+	serveTestAgent(t, filepath.Join("gnupg", "S.gpg-agent.ssh"))
+	out.Reset()
+	if problems, err := checkSSHAgent(&out); err != nil || problems != 0 {
+		t.Errorf("checkSSHAgent() = %d, %v; want no problems", problems, err)
+	}
+	if !strings.Contains(out.String(), "✓ SSH agent: gpg-agent") || !strings.Contains(out.String(), "cannot remove keys") {
+		t.Errorf("checkSSHAgent() output = %q", out.String())
+	}
+
+	cfg := config.Default()
+	cfg.ExclusiveKeys = true
+	if err := config.Save(&cfg); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	out.Reset()
+	if problems, err := checkSSHAgent(&out); err != nil || problems != 1 {
+		t.Errorf("checkSSHAgent() with exclusive_keys = %d, %v; want 1 problem", problems, err)
+	}
+	if !strings.Contains(out.String(), "gidtree config set exclusive_keys false") {
+		t.Errorf("checkSSHAgent() output = %q, want how to turn exclusive_keys off", out.String())
+	}
+
+	t.Setenv("SSH_AUTH_SOCK", filepath.Join(t.TempDir(), "gone.sock"))
+	out.Reset()
+	if problems, err := checkSSHAgent(&out); err != nil || problems != 1 {
+		t.Errorf("checkSSHAgent() with an unreachable agent = %d, %v; want 1 problem", problems, err)
+	}
+}
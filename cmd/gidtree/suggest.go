@@ -0,0 +1,135 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/thuanlegit/git-identitree/internal/cli"
+	"github.com/thuanlegit/git-identitree/internal/logging"
+	"github.com/thuanlegit/git-identitree/internal/mapping"
+	"github.com/thuanlegit/git-identitree/internal/profile"
+	"github.com/thuanlegit/git-identitree/internal/utils"
+
+	"github.com/spf13/cobra"
+)
+
+var suggestQuiet bool
+
+var suggestCmd = &cobra.Command{
+	Use:   "suggest [path]",
+	Short: "Suggest a profile for a repository from its origin URL",
+	Long: `Guess the profile for an unmapped repository (default: the current directory)
+by matching the URL of its origin remote against each profile's remote_patterns,
+and offer to map the repository root to the best match.
+
+Patterns are globs matched segment by segment against <host>/<org>/<repo>;
+"github.com/acme" matches every acme repository and "*.corp.com" every
+repository on a corp.com host. The most specific match ranks first.
+
+With --quiet, only the name of the best match is printed and nothing is mapped;
+the exit status is 5 when no profile matches.`,
+	Args: cobra.MaximumNArgs(1),
+	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return nil, cobra.ShellCompDirectiveFilterDirs
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dir := "."
+		if len(args) == 1 {
+			dir = args[0]
+		}
+		dir, err := filepath.Abs(dir)
+		if err != nil {
+			return fmt.Errorf("failed to resolve path: %w", err)
+		}
+		return suggestProfile(cmd.OutOrStdout(), dir, suggestQuiet, confirmer())
+	},
+}
+
+// originURL returns the URL of the origin remote of the repository at root.
+var originURL = func(root string) (string, error) {
+	cmd := utils.Command("git", "-C", root, "remote", "get-url", "origin")
+	out, err := cmd.Output()
+	logging.Command(cmd, err)
+	if err != nil {
+		return "", fmt.Errorf("repository '%s' has no origin remote", root)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// suggestProfile ranks profiles for the repository containing dir and, unless
+// quiet, offers to map its root to the best one.
+func suggestProfile(w io.Writer, dir string, quiet bool, confirm cli.Confirmer) error {
+	root, ok := utils.FindRepoRoot(dir)
+	if !ok {
+		return fmt.Errorf("'%s' is not inside a git repository", dir)
+	}
+
+	if !quiet {
+		m, err := mapping.GetMappingForDirectory(root)
+		if err != nil {
+			return fmt.Errorf("failed to get mapping: %w", err)
+		}
+		if m != nil {
+			_, _ = fmt.Fprintf(w, "%s is already mapped to profile '%s'\n", root, m.Profile)
+			return nil
+		}
+	}
+
+	raw, err := originURL(root)
+	if err != nil {
+		return err
+	}
+	remote, err := mapping.ParseRemoteURL(raw)
+	if err != nil {
+		return err
+	}
+
+	manager, err := profile.NewDefaultManager()
+	if err != nil {
+		return fmt.Errorf("failed to initialize profile manager: %w", err)
+	}
+	suggestions := mapping.SuggestProfiles(remote, manager.ListProfiles())
+
+	if quiet {
+		if len(suggestions) == 0 {
+			return &exitCodeError{code: exitMappingNotFound}
+		}
+		_, _ = fmt.Fprintln(w, suggestions[0].Profile)
+		return nil
+	}
+
+	if len(suggestions) == 0 {
+		_, _ = fmt.Fprintf(w, "No profile's remote_patterns match %s\n", remote)
+		_, _ = fmt.Fprintln(w, "Add a pattern with 'gidtree profile update <name>', or map it yourself with 'gidtree map <profile> "+root+"'")
+		return nil
+	}
+
+	_, _ = fmt.Fprintf(w, "Suggestions for %s:\n", remote)
+	for _, s := range suggestions {
+		_, _ = fmt.Fprintf(w, "  %s (matches %s)\n", s.Profile, s.Pattern)
+	}
+
+	best := suggestions[0].Profile
+	ok, err = confirm(
+		fmt.Sprintf("Map %s to profile '%s'?", root, best),
+		fmt.Sprintf("Its origin %s matches '%s'.", remote, suggestions[0].Pattern),
+	)
+	if errors.Is(err, cli.ErrNoConfirmation) {
+		_, _ = fmt.Fprintf(w, "Map it with: gidtree map %s %s\n", best, root)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to confirm mapping: %w", err)
+	}
+	if !ok {
+		return nil
+	}
+	return mapCmd.RunE(mapCmd, []string{best, root})
+}
+
+func init() {
+	suggestCmd.Flags().BoolVarP(&suggestQuiet, "quiet", "q", false, "Print only the best matching profile and do not map")
+}
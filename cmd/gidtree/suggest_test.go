@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/thuanlegit/git-identitree/internal/cli"
+	"github.com/thuanlegit/git-identitree/internal/mapping"
+	"github.com/thuanlegit/git-identitree/internal/profile"
+	"github.com/thuanlegit/git-identitree/internal/utils"
+	"github.com/thuanlegit/git-identitree/pkg/gidtree/gidtreetest"
+)
+
+func suggestFixture(t *testing.T, origin string) (*gidtreetest.Built, string) {
+	t.Helper()
+
+	env := gidtreetest.NewEnv(t).
+		WithProfile(profile.Profile{Name: "personal", Email: "me@example.com", RemotePatterns: []string{"github.com"}}).
+		WithProfile(profile.Profile{Name: "work", Email: "work@example.com", RemotePatterns: []string{"github.com/acme"}}).
+		WithGitRepo("src/api").
+		Build()
+	repo := env.Path("src/api")
+	if origin != "" {
+		if out, err := utils.Command("git", "-C", repo, "remote", "add", "origin", origin).CombinedOutput(); err != nil {
+			t.Fatalf("git remote add failed: %v\n%s", err, out)
+		}
+	}
+	return env, repo
+}
+
+func TestSuggestProfile_Quiet(t *testing.T) {
+	_, repo := suggestFixture(t, "git@github.com:acme/api.git")
+
+	var out bytes.Buffer
+	if err := suggestProfile(&out, repo+"/sub", true, nil); err != nil {
+		t.Fatalf("suggestProfile() error = %v", err)
+	}
+	if out.String() != "work\n" {
+		t.Errorf("suggestProfile() output = %q, want the most specific match", out.String())
+	}
+}
+
+func TestSuggestProfile_NoMatch(t *testing.T) {
+	_, repo := suggestFixture(t, "https://gitlab.com/someone/api.git")
+
+	var out bytes.Buffer
+	err := suggestProfile(&out, repo, true, nil)
+	if exitErr, ok := err.(*exitCodeError); !ok || exitErr.code != exitMappingNotFound {
+		t.Errorf("suggestProfile() without a match = %v, want exit status %d", err, exitMappingNotFound)
+	}
+
+	out.Reset()
+	if err := suggestProfile(&out, repo, false, nil); err != nil {
+		t.Fatalf("suggestProfile() error = %v", err)
+	}
+	if !strings.Contains(out.String(), "No profile's remote_patterns match gitlab.com/someone/api") {
+		t.Errorf("suggestProfile() output = %q", out.String())
+	}
+}
+
+func TestSuggestProfile_NoOrigin(t *testing.T) {
+	env, repo := suggestFixture(t, "")
+
+	if err := suggestProfile(&bytes.Buffer{}, repo, true, nil); err == nil || !strings.Contains(err.Error(), "no origin remote") {
+		t.Errorf("suggestProfile() without origin error = %v", err)
+	}
+	if err := suggestProfile(&bytes.Buffer{}, env.Home(), true, nil); err == nil || !strings.Contains(err.Error(), "not inside a git repository") {
+		t.Errorf("suggestProfile() outside a repository error = %v", err)
+	}
+}
+
+func TestSuggestProfile_Maps(t *testing.T) {
+	_, repo := suggestFixture(t, "https://github.com/acme/api")
+	setFlag(t, mapCmd, "create-gitconfig", "true")
+
+	var out bytes.Buffer
+	noTTY := func(string, string) (bool, error) { return false, cli.ErrNoConfirmation }
+	if err := suggestProfile(&out, repo, false, noTTY); err != nil {
+		t.Fatalf("suggestProfile() error = %v", err)
+	}
+	if !strings.Contains(out.String(), "work (matches github.com/acme)") || !strings.Contains(out.String(), "gidtree map work "+repo) {
+		t.Errorf("suggestProfile() output = %q, want the ranking and a map hint", out.String())
+	}
+
+	yes := func(string, string) (bool, error) { return true, nil }
+	if err := suggestProfile(&out, repo, false, yes); err != nil {
+		t.Fatalf("suggestProfile() error = %v", err)
+	}
+	m, err := mapping.GetMappingForDirectory(repo)
+	if err != nil || m == nil || m.Profile != "work" {
+		t.Fatalf("mapping after suggest = %+v, %v; want work", m, err)
+	}
+
+	out.Reset()
+	if err := suggestProfile(&out, repo, false, yes); err != nil {
+		t.Fatalf("suggestProfile() error = %v", err)
+	}
+	if !strings.Contains(out.String(), "already mapped to profile 'work'") {
+		t.Errorf("suggestProfile() in a mapped repository = %q", out.String())
+	}
+}
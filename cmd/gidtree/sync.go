@@ -0,0 +1,115 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/thuanlegit/git-identitree/internal/cli"
+	"github.com/thuanlegit/git-identitree/internal/doctor"
+
+	"github.com/spf13/cobra"
+)
+
+var syncCheck bool
+
+var syncCmd = &cobra.Command{
+	Use:   "sync",
+	Short: "Bring the generated git config back in line with the profiles",
+	Long: `Reconcile ~/.gitconfig and the generated ~/.gitconfig-<profile> files with
+profiles.yaml after hand edits, deleted files or renamed directories:
+
+  - regenerate the config of every mapped profile that is missing or out of date
+  - point includeIf blocks whose path no longer exists at the profile's config
+  - remove mappings of profiles that no longer exist, after confirmation
+
+'gidtree profile update' refreshes the profile it changes on its own.
+
+With --check, nothing is changed: the differences are listed and the exit
+status is 1 when there are any, which suits checking dotfiles in CI.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return syncProfileConfigs(cmd.OutOrStdout(), syncCheck, confirmer())
+	},
+}
+
+// syncProfileConfigs reports drift between the profiles and the files git
+// reads and, unless check is set, fixes it. Orphaned mappings are only removed
+// once confirm agrees.
+func syncProfileConfigs(w io.Writer, check bool, confirm cli.Confirmer) error {
+	drifts, err := doctor.CheckDrift()
+	if err != nil {
+		return fmt.Errorf("failed to check for drift: %w", err)
+	}
+	if len(drifts) == 0 {
+		_, _ = fmt.Fprintln(w, "✓ Git config is in sync with the profiles")
+		return nil
+	}
+
+	if check {
+		for _, d := range drifts {
+			_, _ = fmt.Fprintf(w, "⚠ %s\n", d)
+		}
+		_, _ = fmt.Fprintf(w, "%d difference(s); run 'gidtree sync' to fix them\n", len(drifts))
+		return &exitCodeError{code: exitFailure}
+	}
+
+	var orphans []doctor.Drift
+	fixed, failed := 0, 0
+	for _, d := range drifts {
+		if d.Kind == doctor.OrphanedMapping {
+			orphans = append(orphans, d)
+			continue
+		}
+		if err := d.Fix(); err != nil {
+			_, _ = fmt.Fprintf(w, "✗ %s: %v\n", d, err)
+			failed++
+			continue
+		}
+		_, _ = fmt.Fprintf(w, "✓ Fixed: %s\n", d)
+		fixed++
+	}
+
+	removed, kept := 0, 0
+	if len(orphans) > 0 {
+		var list strings.Builder
+		for _, d := range orphans {
+			_, _ = fmt.Fprintf(w, "⚠ %s\n", d)
+			list.WriteString(fmt.Sprintf("  - %s\n", d))
+		}
+		ok, err := confirm(
+			"Remove these mappings?",
+			fmt.Sprintf("Their profiles no longer exist:\n%s", list.String()),
+		)
+		if errors.Is(err, cli.ErrNoConfirmation) {
+			_, _ = fmt.Fprintln(w, "Run 'gidtree sync --yes' to remove them.")
+			ok = false
+		} else if err != nil {
+			return fmt.Errorf("failed to confirm removal: %w", err)
+		}
+		for _, d := range orphans {
+			if !ok {
+				kept++
+				continue
+			}
+			if err := d.Fix(); err != nil {
+				_, _ = fmt.Fprintf(w, "✗ %s: %v\n", d, err)
+				failed++
+				continue
+			}
+			_, _ = fmt.Fprintf(w, "✓ Removed: %s\n", d)
+			removed++
+		}
+	}
+
+	_, _ = fmt.Fprintf(w, "Synced: %d fixed, %d orphaned mapping(s) removed, %d kept\n", fixed, removed, kept)
+	if failed > 0 {
+		return fmt.Errorf("%d change(s) failed", failed)
+	}
+	return nil
+}
+
+func init() {
+	syncCmd.Flags().BoolVar(&syncCheck, "check", false, "Only report differences; exit 1 if there are any")
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/thuanlegit/git-identitree/internal/cli"
+	"github.com/thuanlegit/git-identitree/internal/mapping"
+	"github.com/thuanlegit/git-identitree/internal/profile"
+	"github.com/thuanlegit/git-identitree/pkg/gidtree/gidtreetest"
+)
+
+// syncFixture maps work and old, then edits work's profile and deletes old
+// behind gidtree's back, as hand edits of profiles.yaml would.
+func syncFixture(t *testing.T) *gidtreetest.Built {
+	t.Helper()
+
+	env := gidtreetest.NewEnv(t).
+		WithProfile(profile.Profile{Name: "work", Email: "jane@acme.com"}).
+		WithProfile(profile.Profile{Name: "old", Email: "old@example.com"}).
+		WithProfile(profile.Profile{Name: "personal", Email: "me@example.com"}).
+		WithMapping("work", "work").
+		WithMapping("old", "old").
+		Build()
+
+	err := profile.SaveProfiles([]profile.Profile{
+		{Name: "work", Email: "jane@acme.com", AuthorName: "Jane Doe"},
+		{Name: "personal", Email: "me@example.com"},
+	})
+	if err != nil {
+		t.Fatalf("SaveProfiles() error = %v", err)
+	}
+	return env
+}
+
+func TestSyncProfileConfigs_Check(t *testing.T) {
+	env := syncFixture(t)
+	before, err := os.ReadFile(env.FragmentPath("work"))
+	if err != nil {
+		t.Fatalf("Failed to read profile config: %v", err)
+	}
+
+	var out bytes.Buffer
+	err = syncProfileConfigs(&out, true, nil)
+	if exitErr, ok := err.(*exitCodeError); !ok || exitErr.code != exitFailure {
+		t.Errorf("syncProfileConfigs() with drift = %v, want exit status %d", err, exitFailure)
+	}
+	output := out.String()
+	if !strings.Contains(output, "out of date with profile 'work'") || !strings.Contains(output, "profile 'old', which does not exist") {
+		t.Errorf("output = %q, want both differences", output)
+	}
+
+	after, err := os.ReadFile(env.FragmentPath("work"))
+	if err != nil {
+		t.Fatalf("Failed to read profile config: %v", err)
+	}
+	if string(after) != string(before) {
+		t.Error("syncProfileConfigs() with check changed the profile config")
+	}
+}
+
+func TestSyncProfileConfigs(t *testing.T) {
+	env := syncFixture(t)
+
+	var out bytes.Buffer
+	yes := func(string, string) (bool, error) { return true, nil }
+	if err := syncProfileConfigs(&out, false, yes); err != nil {
+		t.Fatalf("syncProfileConfigs() error = %v", err)
+	}
+
+	content, err := os.ReadFile(env.FragmentPath("work"))
+	if err != nil {
+		t.Fatalf("Failed to read profile config: %v", err)
+	}
+	if !strings.Contains(string(content), "name = Jane Doe\n") {
+		t.Errorf("profile config = %q, want the author name", content)
+	}
+	if _, err := os.Stat(env.FragmentPath("personal")); !os.IsNotExist(err) {
+		t.Errorf("syncProfileConfigs() wrote a config for an unmapped profile: %v", err)
+	}
+	if m, _ := mapping.GetMappingForDirectory(env.Path("old")); m != nil {
+		t.Errorf("orphaned mapping was not removed: %+v", m)
+	}
+	if !strings.Contains(out.String(), "Synced: 1 fixed, 1 orphaned mapping(s) removed, 0 kept") {
+		t.Errorf("output = %q, want a summary", out.String())
+	}
+
+	out.Reset()
+	if err := syncProfileConfigs(&out, true, nil); err != nil {
+		t.Errorf("syncProfileConfigs() with check after syncing = %v", err)
+	}
+}
+
+func TestSyncProfileConfigs_KeepsOrphansWithoutConfirmation(t *testing.T) {
+	env := syncFixture(t)
+
+	var out bytes.Buffer
+	noTTY := func(string, string) (bool, error) { return false, cli.ErrNoConfirmation }
+	if err := syncProfileConfigs(&out, false, noTTY); err != nil {
+		t.Fatalf("syncProfileConfigs() error = %v", err)
+	}
+	if m, _ := mapping.GetMappingForDirectory(env.Path("old")); m == nil {
+		t.Error("orphaned mapping was removed without confirmation")
+	}
+	if !strings.Contains(out.String(), "gidtree sync --yes") {
+		t.Errorf("output = %q, want a --yes hint", out.String())
+	}
+}
+
+func TestSyncProfileConfigs_InSync(t *testing.T) {
+	gidtreetest.NewEnv(t).
+		WithProfile(profile.Profile{Name: "work", Email: "jane@acme.com"}).
+		WithMapping("work", "work").
+		Build()
+
+	var out bytes.Buffer
+	if err := syncProfileConfigs(&out, true, nil); err != nil {
+		t.Fatalf("syncProfileConfigs() error = %v", err)
+	}
+	if out.String() != "✓ Git config is in sync with the profiles\n" {
+		t.Errorf("output = %q", out.String())
+	}
+}
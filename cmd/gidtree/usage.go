@@ -0,0 +1,64 @@
+package main
+
+import (
+	"os"
+	"slices"
+	"time"
+
+	"github.com/thuanlegit/git-identitree/internal/identity"
+	"github.com/thuanlegit/git-identitree/internal/logging"
+	"github.com/thuanlegit/git-identitree/internal/profile"
+)
+
+// statusTouch makes status record the active profile as used (--touch).
+var statusTouch bool
+
+// usedProfiles are the profiles this run used and has not recorded as used
+// yet. recordUsage saves them when the command ends, in one write.
+var usedProfiles []string
+
+// markUsed notes that the command used prof, unless its last use was already
+// today, so frequent commands such as activate in a shell hook rewrite
+// profiles.yaml at most once a day.
+func markUsed(prof *profile.Profile) {
+	if prof == nil || prof.UsedOn(time.Now()) || slices.Contains(usedProfiles, prof.Name) {
+		return
+	}
+	usedProfiles = append(usedProfiles, prof.Name)
+}
+
+// recordUsage saves the last use of the profiles markUsed noted. Recording
+// is best effort: failing to, for example because another process changed
+// profiles.yaml at the same moment, must not fail the command, so errors are
+// only logged.
+func recordUsage() {
+	if len(usedProfiles) == 0 {
+		return
+	}
+	names := usedProfiles
+	usedProfiles = nil
+
+	manager, err := profile.NewDefaultManager()
+	if err == nil {
+		err = manager.RecordUse(time.Now(), names...)
+	}
+	if err != nil {
+		logging.Logger().Debug("failed to record profile use", "profiles", names, "error", err)
+	}
+}
+
+// touchActiveProfile marks the profile that applies to the current directory
+// as used, for status --touch. Like status itself it never fails.
+func touchActiveProfile() {
+	dir, err := os.Getwd()
+	if err != nil {
+		return
+	}
+	if summary, err := identity.Lookup(dir); err == nil {
+		markUsed(summary.Profile)
+	}
+}
+
+func init() {
+	statusCmd.Flags().BoolVar(&statusTouch, "touch", false, "Record the profile of the current directory as used, as activate does")
+}
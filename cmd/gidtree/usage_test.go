@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/thuanlegit/git-identitree/internal/profile"
+	"github.com/thuanlegit/git-identitree/pkg/gidtree/gidtreetest"
+)
+
+// lastUsed returns the recorded last use of the profile name.
+func lastUsed(t *testing.T, name string) time.Time {
+	t.Helper()
+	manager, err := profile.NewDefaultManager()
+	if err != nil {
+		t.Fatalf("NewDefaultManager() error = %v", err)
+	}
+	p, err := manager.GetProfile(name)
+	if err != nil {
+		t.Fatalf("GetProfile(%s) error = %v", name, err)
+	}
+	return p.LastUsed
+}
+
+func TestRecordUsage(t *testing.T) {
+	gidtreetest.NewEnv(t).
+		WithProfile(profile.Profile{Name: "work", Email: "work@example.com"}).
+		WithProfile(profile.Profile{Name: "personal", Email: "me@example.com"}).
+		Build()
+	// commands run directly by earlier tests leave their marks behind
+	usedProfiles = nil
+	t.Cleanup(func() { usedProfiles = nil })
+
+	markUsed(&profile.Profile{Name: "work"})
+	markUsed(&profile.Profile{Name: "work"})
+	markUsed(nil)
+	if len(usedProfiles) != 1 {
+		t.Fatalf("usedProfiles = %v, want work once", usedProfiles)
+	}
+	recordUsage()
+	if usedProfiles != nil {
+		t.Errorf("usedProfiles = %v, want nil after recording", usedProfiles)
+	}
+
+	used := lastUsed(t, "work")
+	if !(&profile.Profile{LastUsed: used}).UsedOn(time.Now()) {
+		t.Errorf("LastUsed = %v, want today", used)
+	}
+	if !lastUsed(t, "personal").IsZero() {
+		t.Error("personal should not be recorded as used")
+	}
+
+	markUsed(&profile.Profile{Name: "work", LastUsed: used})
+	if len(usedProfiles) != 0 {
+		t.Errorf("usedProfiles = %v, a profile used today should not be noted again", usedProfiles)
+	}
+}
+
+func TestProfileList_UnusedSince(t *testing.T) {
+	gidtreetest.NewEnv(t).
+		WithProfile(profile.Profile{Name: "recent", Email: "a@example.com", LastUsed: time.Now().AddDate(0, 0, -3)}).
+		WithProfile(profile.Profile{Name: "stale", Email: "b@example.com", LastUsed: time.Now().AddDate(-1, 0, 0)}).
+		Build()
+	original := stdoutIsTerminal
+	stdoutIsTerminal = func() bool { return false }
+	t.Cleanup(func() { stdoutIsTerminal = original })
+	profileListView.format = "{{.Name}}"
+	t.Cleanup(func() { profileListView.format = "" })
+
+	var out bytes.Buffer
+	profileListCmd.SetOut(&out)
+	t.Cleanup(func() { profileListCmd.SetOut(nil) })
+
+	setFlag(t, profileListCmd, "unused-since", "30d")
+	if err := profileListCmd.RunE(profileListCmd, nil); err != nil {
+		t.Fatalf("profile list --unused-since error = %v", err)
+	}
+	if out.String() != "stale\n" {
+		t.Errorf("output = %q, want only the stale profile", out.String())
+	}
+
+	setFlag(t, profileListCmd, "unused-since", "soon")
+	if err := profileListCmd.RunE(profileListCmd, nil); err == nil || !strings.Contains(err.Error(), "--unused-since") {
+		t.Errorf("error = %v, want an invalid --unused-since error", err)
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"runtime"
+
+	"github.com/thuanlegit/git-identitree/internal/release"
+
+	"github.com/spf13/cobra"
+)
+
+// Build metadata, set at build time with
+// -ldflags "-X main.version=x.y.z -X main.buildCommit=<hash> -X main.buildDate=<date>".
+var (
+	version     = "dev"
+	buildCommit = "unknown"
+	buildDate   = "unknown"
+)
+
+var (
+	versionJSON        bool
+	versionCheckLatest bool
+)
+
+// versionInfo is the build of gidtree, as `gidtree version` prints it.
+type versionInfo struct {
+	Version   string         `json:"version"`
+	Commit    string         `json:"commit"`
+	Date      string         `json:"date"`
+	GoVersion string         `json:"go_version"`
+	Platform  string         `json:"platform"`
+	Latest    *latestVersion `json:"latest,omitempty"`
+}
+
+// latestVersion is the newest release, filled by --check-latest.
+type latestVersion struct {
+	Version string `json:"version"`
+	URL     string `json:"url"`
+	// Newer is true when the release is newer than this build. It is false
+	// when that cannot be told, as for a dev build.
+	Newer bool `json:"newer"`
+}
+
+var versionCmd = &cobra.Command{
+	Use:   "version",
+	Short: "Display the version of gidtree",
+	Long: `Display the version of the Git Identitree CLI with the commit and date it was
+built from. The first line is always "gidtree version X.Y.Z", so scripts can
+read it; more lines may follow.
+
+--check-latest asks GitHub for the newest release and tells whether it is newer.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		asJSON, err := jsonOutput(cmd, versionJSON)
+		if err != nil {
+			return err
+		}
+		info := currentVersion()
+		if versionCheckLatest {
+			r, err := release.Latest()
+			if err != nil {
+				return err
+			}
+			newer, _ := release.Newer(info.Version, r.Version)
+			info.Latest = &latestVersion{Version: r.Version, URL: r.URL, Newer: newer}
+		}
+		if asJSON {
+			return writeJSON(cmd.OutOrStdout(), info)
+		}
+		writeVersion(cmd.OutOrStdout(), info)
+		return nil
+	},
+}
+
+// currentVersion returns the build metadata of this binary.
+func currentVersion() versionInfo {
+	return versionInfo{
+		Version:   version,
+		Commit:    buildCommit,
+		Date:      buildDate,
+		GoVersion: runtime.Version(),
+		Platform:  runtime.GOOS + "/" + runtime.GOARCH,
+	}
+}
+
+// writeVersion prints info as plain text.
+func writeVersion(w io.Writer, info versionInfo) {
+	_, _ = fmt.Fprintf(w, "gidtree version %s\n", info.Version)
+	_, _ = fmt.Fprintf(w, "commit: %s\n", info.Commit)
+	_, _ = fmt.Fprintf(w, "built: %s\n", info.Date)
+	_, _ = fmt.Fprintf(w, "go: %s %s\n", info.GoVersion, info.Platform)
+	if info.Latest == nil {
+		return
+	}
+	_, ok := release.Newer(info.Version, info.Latest.Version)
+	switch {
+	case info.Latest.Newer:
+		_, _ = fmt.Fprintf(w, "A newer version is available: %s (%s)\n", info.Latest.Version, info.Latest.URL)
+	case !ok:
+		_, _ = fmt.Fprintf(w, "Latest release: %s (%s)\n", info.Latest.Version, info.Latest.URL)
+	default:
+		_, _ = fmt.Fprintf(w, "Up to date: %s is the latest release\n", info.Latest.Version)
+	}
+}
+
+func init() {
+	versionCmd.Flags().BoolVar(&versionJSON, "json", false, "Print the build metadata as JSON")
+	versionCmd.Flags().BoolVar(&versionCheckLatest, "check-latest", false, "Check GitHub for a newer release")
+}
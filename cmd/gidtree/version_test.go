@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/thuanlegit/git-identitree/internal/release"
+	"github.com/thuanlegit/git-identitree/pkg/gidtree/gidtreetest"
+)
+
+// serveLatestRelease answers the releases API with tag.
+func serveLatestRelease(t *testing.T, tag string) {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"tag_name": "` + tag + `", "html_url": "https://example.com/` + tag + `"}`))
+	}))
+	t.Cleanup(server.Close)
+	original := release.LatestURL
+	release.LatestURL = server.URL
+	t.Cleanup(func() { release.LatestURL = original })
+}
+
+// runVersion runs the version command with flags set and returns its output.
+func runVersion(t *testing.T, flags ...string) string {
+	t.Helper()
+	for _, name := range flags {
+		setFlag(t, versionCmd, name, "true")
+	}
+	var out bytes.Buffer
+	versionCmd.SetOut(&out)
+	defer versionCmd.SetOut(nil)
+	if err := versionCmd.RunE(versionCmd, nil); err != nil {
+		t.Fatalf("version %v error = %v", flags, err)
+	}
+	return out.String()
+}
+
+func TestVersion_Defaults(t *testing.T) {
+	gidtreetest.NewEnv(t).Build()
+
+	out := runVersion(t)
+	want := "gidtree version dev\n" +
+		"commit: unknown\n" +
+		"built: unknown\n" +
+		"go: " + runtime.Version() + " " + runtime.GOOS + "/" + runtime.GOARCH + "\n"
+	if out != want {
+		t.Errorf("version output = %q, want %q", out, want)
+	}
+}
+
+func TestVersion_JSON(t *testing.T) {
+	gidtreetest.NewEnv(t).Build()
+	defer func(v, c, d string) { version, buildCommit, buildDate = v, c, d }(version, buildCommit, buildDate)
+	version, buildCommit, buildDate = "1.4.0", "abc1234", "2026-10-01T12:00:00Z"
+
+	out := runVersion(t, "json")
+	var got map[string]any
+	if err := json.Unmarshal([]byte(out), &got); err != nil {
+		t.Fatalf("output is not JSON: %v\n%s", err, out)
+	}
+	want := map[string]any{
+		"version":    "1.4.0",
+		"commit":     "abc1234",
+		"date":       "2026-10-01T12:00:00Z",
+		"go_version": runtime.Version(),
+		"platform":   runtime.GOOS + "/" + runtime.GOARCH,
+	}
+	if len(got) != len(want) {
+		t.Errorf("JSON keys = %v, want %v", got, want)
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("JSON %s = %v, want %v", k, got[k], v)
+		}
+	}
+}
+
+func TestVersion_CheckLatest(t *testing.T) {
+	tests := []struct {
+		name    string
+		version string
+		latest  string
+		want    string
+	}{
+		{"newer", "1.2.3", "v1.3.0", "A newer version is available: v1.3.0 (https://example.com/v1.3.0)\n"},
+		{"up to date", "v1.3.0", "v1.3.0", "Up to date: v1.3.0 is the latest release\n"},
+		{"dev build", "dev", "v1.3.0", "Latest release: v1.3.0 (https://example.com/v1.3.0)\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gidtreetest.NewEnv(t).Build()
+			serveLatestRelease(t, tt.latest)
+			defer func(v string) { version = v }(version)
+			version = tt.version
+
+			out := runVersion(t, "check-latest")
+			if !strings.HasPrefix(out, "gidtree version "+tt.version+"\n") {
+				t.Errorf("output = %q, want the version line first", out)
+			}
+			if !strings.HasSuffix(out, tt.want) {
+				t.Errorf("output = %q, want it to end with %q", out, tt.want)
+			}
+		})
+	}
+}
+
+func TestVersion_CheckLatestJSON(t *testing.T) {
+	gidtreetest.NewEnv(t).Build()
+	serveLatestRelease(t, "v2.0.0")
+	defer func(v string) { version = v }(version)
+	version = "1.2.3"
+
+	var out bytes.Buffer
+	info := currentVersion()
+	info.Latest = &latestVersion{Version: "v2.0.0", URL: "https://example.com/v2.0.0", Newer: true}
+	if err := writeJSON(&out, info); err != nil {
+		t.Fatalf("writeJSON() error = %v", err)
+	}
+	if cliOut := runVersion(t, "check-latest", "json"); cliOut != out.String() {
+		t.Errorf("output = %s, want %s", cliOut, out.String())
+	}
+}
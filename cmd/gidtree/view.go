@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/thuanlegit/git-identitree/internal/cli"
+	"github.com/thuanlegit/git-identitree/internal/utils"
+
+	"github.com/spf13/cobra"
+)
+
+// viewMode is how a command with an interactive view shows its data.
+type viewMode int
+
+const (
+	viewInteractive viewMode = iota
+	viewPlain
+	viewJSON
+)
+
+// viewFlags are the flags of commands that have an interactive view.
+type viewFlags struct {
+	interactive bool
+	plain       bool
+	json        bool
+	// format is a --format template, which implies plain output.
+	format string
+}
+
+var (
+	statusView      viewFlags
+	profileListView viewFlags
+)
+
+// stdoutIsTerminal reports whether stdout is a terminal. Replaced in tests.
+var stdoutIsTerminal = func() bool { return cli.IsTerminal(os.Stdout) }
+
+// stdinIsTerminal reports whether stdin is a terminal, where forms can ask
+// questions. Replaced in tests.
+var stdinIsTerminal = func() bool { return cli.IsTerminal(os.Stdin) }
+
+// addViewFlags registers --interactive, --plain, --json and --format on cmd.
+func addViewFlags(cmd *cobra.Command, flags *viewFlags) {
+	cmd.Flags().BoolVar(&flags.interactive, "interactive", false, "Show the interactive view even when stdout is not a terminal")
+	cmd.Flags().BoolVar(&flags.plain, "plain", false, "Print plain text instead of the interactive view")
+	cmd.Flags().BoolVar(&flags.json, "json", false, "Print JSON instead of the interactive view")
+	cmd.Flags().StringVar(&flags.format, "format", "", "Print with a Go template instead of the interactive view; 'help' lists the fields")
+}
+
+// selectView picks the view for cmd. Explicit flags win; otherwise the
+// interactive view is shown on a terminal. Without one, the view falls back to
+// JSON when output_format is json and to plain text otherwise, with a note on
+// errW so a pipeline does not hang or fill with escape codes unexplained.
+func selectView(cmd *cobra.Command, flags viewFlags, isTTY func() bool, errW io.Writer) (viewMode, error) {
+	if flags.interactive && (flags.plain || flags.json || flags.format != "") {
+		return 0, fmt.Errorf("--interactive cannot be combined with --plain, --json or --format")
+	}
+	if flags.json && (flags.plain || flags.format != "") {
+		return 0, fmt.Errorf("--json cannot be combined with --plain or --format")
+	}
+
+	switch {
+	case flags.interactive:
+		return viewInteractive, nil
+	case flags.json:
+		return viewJSON, nil
+	case flags.plain, flags.format != "":
+		return viewPlain, nil
+	case isTTY():
+		return viewInteractive, nil
+	}
+
+	asJSON, err := jsonOutput(cmd, false)
+	if err != nil {
+		return 0, err
+	}
+	_, _ = fmt.Fprintln(errW, "Note: stdout is not a terminal, skipping the interactive view (use --interactive to force it)")
+	if asJSON {
+		return viewJSON, nil
+	}
+	return viewPlain, nil
+}
+
+// abbreviateHomeJSON is the --abbreviate-home flag: JSON output writes paths
+// under the home directory with ~, so it can be shared or compared across
+// machines.
+var abbreviateHomeJSON bool
+
+// writeJSON prints v as indented JSON, with paths under the home directory
+// abbreviated to ~ when --abbreviate-home is given.
+func writeJSON(w io.Writer, v any) error {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(v); err != nil {
+		return err
+	}
+	data := buf.Bytes()
+	if abbreviateHomeJSON {
+		data = abbreviateJSONPaths(data)
+	}
+	_, err := w.Write(data)
+	return err
+}
+
+// abbreviateJSONPaths rewrites the JSON strings that are the home directory
+// or a path under it, bare or as a gitdir includeIf condition, to start with ~
+// instead. git expands ~/ in gitdir conditions, so they stay valid.
+func abbreviateJSONPaths(data []byte) []byte {
+	home, err := utils.Root()
+	if err != nil {
+		return data
+	}
+	home = strings.TrimRight(home, `/\`)
+	if home == "" {
+		return data
+	}
+	quoted, err := json.Marshal(home)
+	if err != nil {
+		return data
+	}
+	// The home directory without its quotes
+	escaped := string(quoted[1 : len(quoted)-1])
+	for _, lead := range []string{`"`, `"gitdir:`, `"gitdir/i:`} {
+		// A string that ends at the home directory or goes on with a
+		// separator, which is / or an escaped \
+		for _, next := range []string{`"`, `/`, `\\`} {
+			data = bytes.ReplaceAll(data, []byte(lead+escaped+next), []byte(lead+"~"+next))
+		}
+	}
+	return data
+}
+
+func init() {
+	addViewFlags(statusCmd, &statusView)
+	addViewFlags(profileListCmd, &profileListView)
+}
@@ -0,0 +1,283 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/thuanlegit/git-identitree/internal/config"
+	"github.com/thuanlegit/git-identitree/internal/identity"
+	"github.com/thuanlegit/git-identitree/internal/profile"
+	"github.com/thuanlegit/git-identitree/internal/utils"
+	"github.com/thuanlegit/git-identitree/pkg/gidtree/gidtreetest"
+)
+
+func TestSelectView(t *testing.T) {
+	gidtreetest.NewEnv(t).Build()
+
+	tests := []struct {
+		name     string
+		flags    viewFlags
+		tty      bool
+		want     viewMode
+		wantNote bool
+	}{
+		{name: "terminal", tty: true, want: viewInteractive},
+		{name: "pipe", want: viewPlain, wantNote: true},
+		{name: "forced interactive", flags: viewFlags{interactive: true}, want: viewInteractive},
+		{name: "plain on terminal", flags: viewFlags{plain: true}, tty: true, want: viewPlain},
+		{name: "json on terminal", flags: viewFlags{json: true}, tty: true, want: viewJSON},
+		{name: "format on terminal", flags: viewFlags{format: "{{.Name}}"}, tty: true, want: viewPlain},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var errW bytes.Buffer
+			got, err := selectView(statusCmd, tt.flags, func() bool { return tt.tty }, &errW)
+			if err != nil {
+				t.Fatalf("selectView() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("selectView() = %v, want %v", got, tt.want)
+			}
+			if hasNote := strings.Contains(errW.String(), "not a terminal"); hasNote != tt.wantNote {
+				t.Errorf("note = %q, want note %v", errW.String(), tt.wantNote)
+			}
+		})
+	}
+}
+
+func TestSelectView_OutputFormatJSON(t *testing.T) {
+	gidtreetest.NewEnv(t).Build()
+	cfg := config.Default()
+	cfg.OutputFormat = config.OutputJSON
+	if err := config.Save(&cfg); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	got, err := selectView(statusCmd, viewFlags{}, func() bool { return false }, &bytes.Buffer{})
+	if err != nil {
+		t.Fatalf("selectView() error = %v", err)
+	}
+	if got != viewJSON {
+		t.Errorf("selectView() = %v, want viewJSON", got)
+	}
+
+	// A terminal still gets the interactive view
+	got, _ = selectView(statusCmd, viewFlags{}, func() bool { return true }, &bytes.Buffer{})
+	if got != viewInteractive {
+		t.Errorf("selectView() on a terminal = %v, want viewInteractive", got)
+	}
+}
+
+func TestSelectView_ConflictingFlags(t *testing.T) {
+	for _, flags := range []viewFlags{
+		{interactive: true, plain: true},
+		{interactive: true, json: true},
+		{plain: true, json: true},
+		{interactive: true, format: "{{.Name}}"},
+		{json: true, format: "{{.Name}}"},
+	} {
+		if _, err := selectView(statusCmd, flags, func() bool { return true }, &bytes.Buffer{}); err == nil {
+			t.Errorf("selectView(%+v) error = nil, want a conflict", flags)
+		}
+	}
+}
+
+func TestProfileList_NotATerminal(t *testing.T) {
+	gidtreetest.NewEnv(t).
+		WithProfile(profile.Profile{Name: "work", Email: "work@example.com"}).
+		Build()
+	original := stdoutIsTerminal
+	stdoutIsTerminal = func() bool { return false }
+	t.Cleanup(func() { stdoutIsTerminal = original })
+
+	var out bytes.Buffer
+	profileListCmd.SetOut(&out)
+	t.Cleanup(func() { profileListCmd.SetOut(nil) })
+
+	if err := profileListCmd.RunE(profileListCmd, nil); err != nil {
+		t.Fatalf("profile list error = %v", err)
+	}
+	if !strings.Contains(out.String(), "NAME") || !strings.Contains(out.String(), "work@example.com") {
+		t.Errorf("output = %q, want a plain table", out.String())
+	}
+
+	out.Reset()
+	profileListView.json = true
+	t.Cleanup(func() { profileListView.json = false })
+	if err := profileListCmd.RunE(profileListCmd, nil); err != nil {
+		t.Fatalf("profile list --json error = %v", err)
+	}
+	var profiles []profile.Profile
+	if err := json.Unmarshal(out.Bytes(), &profiles); err != nil {
+		t.Fatalf("output is not JSON: %v\n%s", err, out.String())
+	}
+	if len(profiles) != 1 || profiles[0].Name != "work" {
+		t.Errorf("profiles = %+v, want work", profiles)
+	}
+}
+
+func TestProfileList_Sort(t *testing.T) {
+	gidtreetest.NewEnv(t).
+		WithProfile(profile.Profile{Name: "work", Email: "a@example.com"}).
+		WithProfile(profile.Profile{Name: "personal", Email: "b@example.com"}).
+		Build()
+	original := stdoutIsTerminal
+	stdoutIsTerminal = func() bool { return false }
+	t.Cleanup(func() { stdoutIsTerminal = original })
+	profileListView.format = "{{.Name}}"
+	t.Cleanup(func() { profileListView.format = "" })
+
+	var out bytes.Buffer
+	profileListCmd.SetOut(&out)
+	t.Cleanup(func() { profileListCmd.SetOut(nil) })
+
+	if err := profileListCmd.RunE(profileListCmd, nil); err != nil {
+		t.Fatalf("profile list error = %v", err)
+	}
+	if out.String() != "personal\nwork\n" {
+		t.Errorf("output = %q, want the profiles by name", out.String())
+	}
+
+	out.Reset()
+	setFlag(t, profileListCmd, "sort", "email")
+	if err := profileListCmd.RunE(profileListCmd, nil); err != nil {
+		t.Fatalf("profile list --sort email error = %v", err)
+	}
+	if out.String() != "work\npersonal\n" {
+		t.Errorf("output = %q, want the profiles by email", out.String())
+	}
+
+	setFlag(t, profileListCmd, "sort", "size")
+	if err := profileListCmd.RunE(profileListCmd, nil); err == nil {
+		t.Error("profile list should reject an unknown sort key")
+	}
+}
+
+func TestStatus_NotATerminal(t *testing.T) {
+	env := gidtreetest.NewEnv(t).
+		WithProfile(profile.Profile{Name: "work", Email: "work@example.com"}).
+		WithMapping("work", "work").
+		Build()
+	t.Chdir(env.Path("work"))
+	original := stdoutIsTerminal
+	stdoutIsTerminal = func() bool { return false }
+	t.Cleanup(func() { stdoutIsTerminal = original })
+
+	var out bytes.Buffer
+	statusCmd.SetOut(&out)
+	t.Cleanup(func() { statusCmd.SetOut(nil) })
+
+	if err := statusCmd.RunE(statusCmd, nil); err != nil {
+		t.Fatalf("status error = %v", err)
+	}
+	if !strings.Contains(out.String(), "Active profile: work") {
+		t.Errorf("output = %q, want the active profile", out.String())
+	}
+}
+
+// TestViews_AbbreviateHome renders one profile through every view and checks
+// that none of them shows the home directory.
+func TestViews_AbbreviateHome(t *testing.T) {
+	env := gidtreetest.NewEnv(t).
+		WithProfile(profile.Profile{Name: "work", Email: "work@example.com"}).
+		WithMapping("work", "code/acme").
+		Build()
+	keyPath := env.Path(".ssh/id_work")
+	if err := os.MkdirAll(env.Path(".ssh"), utils.PrivateDirMode); err != nil {
+		t.Fatalf("failed to create .ssh: %v", err)
+	}
+	if err := os.WriteFile(keyPath, []byte("key"), 0600); err != nil {
+		t.Fatalf("failed to write key: %v", err)
+	}
+	manager, err := profile.NewDefaultManager()
+	if err != nil {
+		t.Fatalf("NewDefaultManager() error = %v", err)
+	}
+	if err := manager.UpdateProfile("work", profile.Profile{Name: "work", Email: "work@example.com", SSHKeyPath: keyPath}); err != nil {
+		t.Fatalf("UpdateProfile() error = %v", err)
+	}
+	t.Chdir(env.Path("code/acme"))
+	original := stdoutIsTerminal
+	stdoutIsTerminal = func() bool { return false }
+	t.Cleanup(func() { stdoutIsTerminal = original })
+	abbreviateHomeJSON = true
+	t.Cleanup(func() { abbreviateHomeJSON = false })
+
+	var out bytes.Buffer
+	profileListCmd.SetOut(&out)
+	statusCmd.SetOut(&out)
+	t.Cleanup(func() {
+		profileListCmd.SetOut(nil)
+		statusCmd.SetOut(nil)
+	})
+	t.Cleanup(func() {
+		profileListView.json = false
+		statusView.json = false
+	})
+
+	for _, asJSON := range []bool{false, true} {
+		profileListView.json = asJSON
+		statusView.json = asJSON
+		if err := profileListCmd.RunE(profileListCmd, nil); err != nil {
+			t.Fatalf("profile list error = %v", err)
+		}
+		if err := statusCmd.RunE(statusCmd, nil); err != nil {
+			t.Fatalf("status error = %v", err)
+		}
+		prof, err := manager.GetProfile("work")
+		if err != nil {
+			t.Fatalf("GetProfile() error = %v", err)
+		}
+		detail, err := identity.DescribeProfile(prof)
+		if err != nil {
+			t.Fatalf("DescribeProfile() error = %v", err)
+		}
+		if err := showProfile(&out, detail, asJSON); err != nil {
+			t.Fatalf("showProfile() error = %v", err)
+		}
+	}
+	if err := exportState(&out, &bytes.Buffer{}); err != nil {
+		t.Fatalf("exportState() error = %v", err)
+	}
+
+	if !strings.Contains(out.String(), "~/.ssh/id_work") {
+		t.Errorf("output = %q, want the key path as ~/.ssh/id_work", out.String())
+	}
+	if strings.Contains(out.String(), env.Home()+"/") {
+		t.Errorf("output shows the home directory %s:\n%s", env.Home(), out.String())
+	}
+}
+
+func TestAbbreviateJSONPaths(t *testing.T) {
+	home := gidtreetest.NewEnv(t).Build().Home()
+
+	data, err := json.Marshal(map[string]string{
+		"home":    home,
+		"key":     home + "/.ssh/id_work",
+		"sibling": home + "t/code",
+		"note":    "see " + home + "/code",
+		"cond":    "gitdir/i:" + home + "/code/",
+	})
+	if err != nil {
+		t.Fatalf("json.Marshal() error = %v", err)
+	}
+	var got map[string]string
+	if err := json.Unmarshal(abbreviateJSONPaths(data), &got); err != nil {
+		t.Fatalf("abbreviateJSONPaths() returned invalid JSON: %v", err)
+	}
+	want := map[string]string{
+		"home":    "~",
+		"key":     "~/.ssh/id_work",
+		"sibling": home + "t/code",
+		"note":    "see " + home + "/code",
+		"cond":    "gitdir/i:~/code/",
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("abbreviateJSONPaths()[%q] = %q, want %q", k, got[k], v)
+		}
+	}
+}
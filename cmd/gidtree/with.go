@@ -0,0 +1,153 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/thuanlegit/git-identitree/internal/audit"
+	"github.com/thuanlegit/git-identitree/internal/mapping"
+	"github.com/thuanlegit/git-identitree/internal/profile"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	// overrideEnvVar names the profile of the innermost active override.
+	// Prompt integrations can render it as a marker.
+	overrideEnvVar = "GIDTREE_OVERRIDE"
+	// overrideStackEnvVar lists nested overrides from outermost to innermost.
+	overrideStackEnvVar = "GIDTREE_OVERRIDE_STACK"
+)
+
+var withShell bool
+
+var withCmd = &cobra.Command{
+	Use:   "with [profile] -- [command...]",
+	Short: "Run one command under a different profile than the mapped one",
+	Long:  "Run a command with a profile's git identity exported, overriding the profile mapped to the current directory for this invocation only. The override is announced on stderr and recorded in the audit log. With --shell, an interactive shell is started instead and GIDTREE_OVERRIDE is set so prompts can show the override.",
+	Args:  cobra.MinimumNArgs(1),
+	// The child's own output and exit status are what matters here
+	SilenceErrors: true,
+	SilenceUsage:  true,
+	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) > 0 {
+			return nil, cobra.ShellCompDirectiveDefault
+		}
+		manager, err := profile.NewDefaultManager()
+		if err != nil {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		profiles := manager.ListProfiles()
+		var names []string
+		for _, p := range profiles {
+			names = append(names, p.Name)
+		}
+		return names, cobra.ShellCompDirectiveNoFileComp
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		profileName := args[0]
+		command := args[1:]
+		if len(command) == 0 && !withShell {
+			return fmt.Errorf("no command given: use 'gidtree with %s -- <command...>' or --shell", profileName)
+		}
+
+		manager, err := profile.NewDefaultManager()
+		if err != nil {
+			return fmt.Errorf("failed to initialize profile manager: %w", err)
+		}
+
+		prof, err := manager.GetProfile(profileName)
+		if err != nil {
+			return fmt.Errorf("profile not found: %w", err)
+		}
+
+		currentDir, err := os.Getwd()
+		if err != nil {
+			return fmt.Errorf("failed to get current directory: %w", err)
+		}
+
+		m, err := mapping.GetMappingForDirectory(currentDir)
+		if err != nil {
+			return fmt.Errorf("failed to get mapping: %w", err)
+		}
+
+		env, outer := overrideEnv(os.Environ(), prof)
+		fmt.Fprintln(os.Stderr, overrideNotice(prof.Name, m, outer))
+
+		if m == nil || m.Profile != prof.Name || outer != "" {
+			event := audit.Event{
+				Kind:      audit.EventOverride,
+				Profile:   prof.Name,
+				Directory: currentDir,
+				Detail:    overrideDetail(m, outer, withShell),
+			}
+			if err := audit.Record(event); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: failed to record override in audit log: %v\n", err)
+			}
+		}
+
+		if withShell {
+			return runChild(userShell(), nil, env)
+		}
+		return runChild(command[0], command[1:], env)
+	},
+}
+
+// overrideEnv builds the child environment for an override. It returns the
+// profile of any override already active in base, so nesting can be reported.
+func overrideEnv(base []string, prof *profile.Profile) ([]string, string) {
+	outer := lookupEnv(base, overrideEnvVar)
+
+	stack := lookupEnv(base, overrideStackEnvVar)
+	if stack == "" {
+		stack = outer
+	}
+	if stack != "" {
+		stack += ","
+	}
+	stack += prof.Name
+
+	vars := append(prof.Env(),
+		profile.EnvVar{Name: overrideEnvVar, Value: prof.Name},
+		profile.EnvVar{Name: overrideStackEnvVar, Value: stack},
+	)
+	return mergeEnv(base, vars), outer
+}
+
+// overrideNotice is the one-line announcement printed before running the command.
+func overrideNotice(profileName string, m *mapping.Mapping, outer string) string {
+	var b strings.Builder
+	switch {
+	case m == nil:
+		fmt.Fprintf(&b, "⚠ Using profile '%s' (no profile is mapped to this directory)", profileName)
+	case m.Profile == profileName:
+		fmt.Fprintf(&b, "Using profile '%s' (same as the mapped profile)", profileName)
+	default:
+		fmt.Fprintf(&b, "⚠ OVERRIDE: using profile '%s' instead of mapped profile '%s' for this invocation", profileName, m.Profile)
+	}
+	if outer != "" {
+		fmt.Fprintf(&b, " [nested inside override '%s']", outer)
+	}
+	return b.String()
+}
+
+// overrideDetail describes an override for the audit log.
+func overrideDetail(m *mapping.Mapping, outer string, shell bool) string {
+	parts := []string{"unmapped directory"}
+	if m != nil {
+		parts[0] = fmt.Sprintf("mapped profile '%s'", m.Profile)
+	}
+	if outer != "" {
+		parts = append(parts, fmt.Sprintf("nested inside '%s'", outer))
+	}
+	if shell {
+		parts = append(parts, "shell")
+	}
+	return strings.Join(parts, "; ")
+}
+
+func init() {
+	withCmd.Flags().BoolVar(&withShell, "shell", false, "Start an interactive shell with the override instead of running a command")
+	withCmd.Flags().SetInterspersed(false)
+}
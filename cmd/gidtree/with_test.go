@@ -0,0 +1,151 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/thuanlegit/git-identitree/internal/audit"
+	"github.com/thuanlegit/git-identitree/internal/mapping"
+	"github.com/thuanlegit/git-identitree/internal/profile"
+)
+
+func TestOverrideEnv(t *testing.T) {
+	personal := &profile.Profile{Name: "personal", Email: "me@example.com"}
+	oss := &profile.Profile{Name: "oss", Email: "oss@example.com"}
+
+	base := []string{"PATH=/bin", "GIT_AUTHOR_EMAIL=work@example.com"}
+	env, outer := overrideEnv(base, personal)
+	if outer != "" {
+		t.Errorf("overrideEnv() outer = %q, want none", outer)
+	}
+	if got := lookupEnv(env, "GIT_AUTHOR_EMAIL"); got != "me@example.com" {
+		t.Errorf("GIT_AUTHOR_EMAIL = %q, want me@example.com", got)
+	}
+	if got := lookupEnv(env, overrideEnvVar); got != "personal" {
+		t.Errorf("%s = %q, want personal", overrideEnvVar, got)
+	}
+	if got := lookupEnv(env, overrideStackEnvVar); got != "personal" {
+		t.Errorf("%s = %q, want personal", overrideStackEnvVar, got)
+	}
+
+	// Running `with` inside an overridden shell stacks
+	nested, outer := overrideEnv(env, oss)
+	if outer != "personal" {
+		t.Errorf("nested overrideEnv() outer = %q, want personal", outer)
+	}
+	if got := lookupEnv(nested, overrideEnvVar); got != "oss" {
+		t.Errorf("nested %s = %q, want oss", overrideEnvVar, got)
+	}
+	if got := lookupEnv(nested, overrideStackEnvVar); got != "personal,oss" {
+		t.Errorf("nested %s = %q, want personal,oss", overrideStackEnvVar, got)
+	}
+	if got := lookupEnv(nested, "GIT_AUTHOR_EMAIL"); got != "oss@example.com" {
+		t.Errorf("nested GIT_AUTHOR_EMAIL = %q, want oss@example.com", got)
+	}
+	if strings.Count(strings.Join(nested, "\n"), overrideEnvVar+"=") != 1 {
+		t.Errorf("nested env should contain a single %s entry: %v", overrideEnvVar, nested)
+	}
+
+	// A legacy environment with only GIDTREE_OVERRIDE set still stacks
+	legacy, _ := overrideEnv([]string{overrideEnvVar + "=work"}, oss)
+	if got := lookupEnv(legacy, overrideStackEnvVar); got != "work,oss" {
+		t.Errorf("legacy %s = %q, want work,oss", overrideStackEnvVar, got)
+	}
+}
+
+func TestOverrideNotice(t *testing.T) {
+	work := &mapping.Mapping{Directory: "/work/", Profile: "work"}
+
+	tests := []struct {
+		name    string
+		m       *mapping.Mapping
+		outer   string
+		profile string
+		want    []string
+	}{
+		{name: "override", m: work, profile: "personal", want: []string{"OVERRIDE", "'personal'", "'work'"}},
+		{name: "unmapped", m: nil, profile: "personal", want: []string{"no profile is mapped"}},
+		{name: "same", m: work, profile: "work", want: []string{"same as the mapped profile"}},
+		{name: "nested", m: work, profile: "oss", outer: "personal", want: []string{"OVERRIDE", "nested inside override 'personal'"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			notice := overrideNotice(tt.profile, tt.m, tt.outer)
+			if strings.Contains(notice, "\n") {
+				t.Errorf("overrideNotice() should be a single line: %q", notice)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(notice, want) {
+					t.Errorf("overrideNotice() = %q, want it to contain %q", notice, want)
+				}
+			}
+		})
+	}
+}
+
+func TestWithCommand_RecordsOverride(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	tmpDir, cleanup := setupCLITestEnv(t)
+	defer cleanup()
+
+	manager, err := profile.NewDefaultManager()
+	if err != nil {
+		t.Fatalf("NewDefaultManager() error = %v", err)
+	}
+	work := profile.Profile{Name: "work", Email: "work@example.com"}
+	personal := profile.Profile{Name: "personal", Email: "me@example.com"}
+	for _, p := range []profile.Profile{work, personal} {
+		if err := manager.AddProfile(p); err != nil {
+			t.Fatalf("AddProfile() error = %v", err)
+		}
+	}
+	workDir := filepath.Join(tmpDir, "work")
+	if err := os.MkdirAll(workDir, 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	if err := mapping.MapProfileToDirectory(&work, workDir); err != nil {
+		t.Fatalf("MapProfileToDirectory() error = %v", err)
+	}
+
+	originalDir, _ := os.Getwd()
+	if err := os.Chdir(workDir); err != nil {
+		t.Fatalf("Failed to change directory: %v", err)
+	}
+	defer func() {
+		_ = os.Chdir(originalDir)
+	}()
+
+	out := filepath.Join(tmpDir, "email.txt")
+	if err := withCmd.RunE(withCmd, []string{"personal", "sh", "-c", `printf %s "$GIT_AUTHOR_EMAIL" > "$0"`, out}); err != nil {
+		t.Fatalf("withCmd.RunE() error = %v", err)
+	}
+
+	content, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatalf("Failed to read output: %v", err)
+	}
+	if string(content) != "me@example.com" {
+		t.Errorf("child GIT_AUTHOR_EMAIL = %q, want me@example.com", content)
+	}
+
+	events, err := audit.ReadEvents()
+	if err != nil {
+		t.Fatalf("ReadEvents() error = %v", err)
+	}
+	if len(events) != 1 || events[0].Kind != audit.EventOverride || events[0].Profile != "personal" {
+		t.Fatalf("audit events = %+v, want one override for personal", events)
+	}
+	if !strings.Contains(events[0].Detail, "mapped profile 'work'") {
+		t.Errorf("audit detail = %q, want mapped profile", events[0].Detail)
+	}
+
+	if err := withCmd.RunE(withCmd, []string{"personal"}); err == nil {
+		t.Error("withCmd.RunE() should fail without a command or --shell")
+	}
+}
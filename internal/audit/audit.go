@@ -0,0 +1,195 @@
+package audit
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/thuanlegit/git-identitree/internal/logging"
+	"github.com/thuanlegit/git-identitree/internal/mapping"
+	"github.com/thuanlegit/git-identitree/internal/profile"
+	"github.com/thuanlegit/git-identitree/internal/utils"
+)
+
+// Commit is a single commit's author identity.
+type Commit struct {
+	Hash  string `json:"hash"`
+	Email string `json:"email"`
+	Name  string `json:"name"`
+}
+
+// LogOptions limits which commits are inspected.
+type LogOptions struct {
+	Since    string
+	MaxCount int
+}
+
+// GitLogger lists commit authors for a repository.
+type GitLogger interface {
+	Authors(repo string, opts LogOptions) ([]Commit, error)
+}
+
+// ExecGit implements GitLogger by running the git binary.
+type ExecGit struct{}
+
+// Authors runs git log in repo and returns the author of each commit.
+func (ExecGit) Authors(repo string, opts LogOptions) ([]Commit, error) {
+	args := []string{"-C", repo, "log", "--format=%H%x09%ae%x09%an"}
+	if opts.Since != "" {
+		args = append(args, "--since="+opts.Since)
+	}
+	if opts.MaxCount > 0 {
+		args = append(args, "--max-count="+strconv.Itoa(opts.MaxCount))
+	}
+
+	cmd := utils.Command("git", args...)
+	output, err := cmd.Output()
+	logging.Command(cmd, err)
+	if err != nil {
+		// A repository without commits has nothing to audit
+		if exitErr, ok := err.(*exec.ExitError); ok && strings.Contains(string(exitErr.Stderr), "does not have any commits") {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to run git log in %s: %w", repo, err)
+	}
+
+	return parseLog(string(output)), nil
+}
+
+// parseLog parses tab-separated "hash email name" lines.
+func parseLog(output string) []Commit {
+	var commits []Commit
+	for _, line := range strings.Split(output, "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		fields := strings.SplitN(line, "\t", 3)
+		if len(fields) < 3 {
+			continue
+		}
+		commits = append(commits, Commit{Hash: fields[0], Email: fields[1], Name: fields[2]})
+	}
+	return commits
+}
+
+// RepoReport lists the commits in a repository whose author does not match the mapped profile.
+type RepoReport struct {
+	Repo          string `json:"repo"`
+	Profile       string `json:"profile"`
+	ExpectedEmail string `json:"expected_email"`
+	// EmailAliases are the other emails of the profile, also accepted.
+	EmailAliases []string `json:"email_aliases,omitempty"`
+	Checked      int      `json:"checked"`
+	Mismatches   []Commit `json:"mismatches"`
+}
+
+// Auditor compares commit authors against mapped profiles.
+type Auditor struct {
+	Git     GitLogger
+	Options LogOptions
+}
+
+// NewAuditor creates an auditor that uses the git binary.
+func NewAuditor(opts LogOptions) *Auditor {
+	return &Auditor{Git: ExecGit{}, Options: opts}
+}
+
+// Run audits every git repository found under roots.
+// Repositories that are not covered by a mapping are skipped.
+func (a *Auditor) Run(roots []string) ([]RepoReport, error) {
+	manager, err := profile.NewDefaultManager()
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize profile manager: %w", err)
+	}
+
+	var reports []RepoReport
+	seen := make(map[string]bool)
+	for _, root := range roots {
+		repos, err := FindRepositories(root)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, repo := range repos {
+			if seen[repo] {
+				continue
+			}
+			seen[repo] = true
+
+			m, err := mapping.GetMappingForDirectory(repo)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get mapping for %s: %w", repo, err)
+			}
+			if m == nil {
+				continue
+			}
+			prof, err := manager.GetProfile(m.Profile)
+			if err != nil {
+				return nil, fmt.Errorf("profile not found: %w", err)
+			}
+
+			commits, err := a.Git.Authors(repo, a.Options)
+			if err != nil {
+				return nil, err
+			}
+
+			report := RepoReport{
+				Repo:          repo,
+				Profile:       prof.Name,
+				ExpectedEmail: prof.Email,
+				EmailAliases:  prof.EmailAliases,
+				Checked:       len(commits),
+				Mismatches:    []Commit{},
+			}
+			for _, c := range commits {
+				if !prof.HasEmail(c.Email) {
+					report.Mismatches = append(report.Mismatches, c)
+				}
+			}
+			reports = append(reports, report)
+		}
+	}
+
+	return reports, nil
+}
+
+// FindRepositories walks root and returns every directory that contains a .git entry.
+func FindRepositories(root string) ([]string, error) {
+	info, err := os.Stat(root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", root, err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", root)
+	}
+
+	var repos []string
+	err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// Skip unreadable directories rather than aborting the walk
+			if d != nil && d.IsDir() && path != root {
+				return fs.SkipDir
+			}
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if d.Name() == ".git" {
+			return fs.SkipDir
+		}
+		if _, err := os.Stat(filepath.Join(path, ".git")); err == nil {
+			repos = append(repos, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan %s: %w", root, err)
+	}
+
+	return repos, nil
+}
@@ -0,0 +1,212 @@
+package audit
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/thuanlegit/git-identitree/internal/mapping"
+	"github.com/thuanlegit/git-identitree/internal/profile"
+	"github.com/thuanlegit/git-identitree/internal/utils"
+)
+
+type fakeGit struct {
+	commits map[string][]Commit
+	calls   []LogOptions
+}
+
+func (f *fakeGit) Authors(repo string, opts LogOptions) ([]Commit, error) {
+	f.calls = append(f.calls, opts)
+	return f.commits[repo], nil
+}
+
+func setupAuditTestEnv(t *testing.T) string {
+	t.Helper()
+
+	tmpDir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to resolve temp directory: %v", err)
+	}
+
+	// Override home directory for testing on all platforms
+	t.Setenv(utils.RootEnv, tmpDir)
+	t.Setenv("XDG_CONFIG_HOME", "")
+
+	return tmpDir
+}
+
+func mapProfile(t *testing.T, prof profile.Profile, dir string) {
+	t.Helper()
+
+	manager, err := profile.NewDefaultManager()
+	if err != nil {
+		t.Fatalf("NewDefaultManager() error = %v", err)
+	}
+	if err := manager.AddProfile(prof); err != nil {
+		t.Fatalf("AddProfile() error = %v", err)
+	}
+	if err := mapping.MapProfileToDirectory(&prof, dir); err != nil {
+		t.Fatalf("MapProfileToDirectory() error = %v", err)
+	}
+}
+
+func makeRepo(t *testing.T, dir string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Join(dir, ".git"), 0755); err != nil {
+		t.Fatalf("Failed to create repo: %v", err)
+	}
+}
+
+func TestFindRepositories(t *testing.T) {
+	tmpDir := setupAuditTestEnv(t)
+
+	makeRepo(t, filepath.Join(tmpDir, "work", "a"))
+	makeRepo(t, filepath.Join(tmpDir, "work", "nested", "b"))
+	if err := os.MkdirAll(filepath.Join(tmpDir, "work", "plain"), 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	// Worktrees and submodules use a .git file
+	if err := os.MkdirAll(filepath.Join(tmpDir, "work", "c"), 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, "work", "c", ".git"), []byte("gitdir: ../a/.git"), 0644); err != nil {
+		t.Fatalf("Failed to write .git file: %v", err)
+	}
+
+	repos, err := FindRepositories(filepath.Join(tmpDir, "work"))
+	if err != nil {
+		t.Fatalf("FindRepositories() error = %v", err)
+	}
+	if len(repos) != 3 {
+		t.Errorf("FindRepositories() = %v, want 3 repositories", repos)
+	}
+}
+
+func TestFindRepositories_NotADirectory(t *testing.T) {
+	tmpDir := setupAuditTestEnv(t)
+
+	file := filepath.Join(tmpDir, "file.txt")
+	if err := os.WriteFile(file, []byte("x"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	if _, err := FindRepositories(file); err == nil {
+		t.Error("FindRepositories() should fail for a file")
+	}
+	if _, err := FindRepositories(filepath.Join(tmpDir, "missing")); err == nil {
+		t.Error("FindRepositories() should fail for a missing path")
+	}
+}
+
+func TestAuditor_Run(t *testing.T) {
+	tmpDir := setupAuditTestEnv(t)
+
+	workDir := filepath.Join(tmpDir, "work")
+	repo := filepath.Join(workDir, "repo")
+	unmapped := filepath.Join(tmpDir, "other", "repo")
+	makeRepo(t, repo)
+	makeRepo(t, unmapped)
+	mapProfile(t, profile.Profile{Name: "work", Email: "work@example.com", EmailAliases: []string{"work@old.example.com"}}, workDir)
+
+	git := &fakeGit{commits: map[string][]Commit{
+		repo: {
+			{Hash: "a1", Email: "work@example.com", Name: "Jane"},
+			{Hash: "b2", Email: "me@personal.com", Name: "Jane"},
+			{Hash: "c3", Email: "WORK@example.com", Name: "Jane"},
+			{Hash: "d4", Email: "work@old.example.com", Name: "Jane"},
+		},
+	}}
+	auditor := &Auditor{Git: git, Options: LogOptions{Since: "1 year ago", MaxCount: 50}}
+
+	reports, err := auditor.Run([]string{workDir, filepath.Join(tmpDir, "other")})
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if len(reports) != 1 {
+		t.Fatalf("Run() returned %d reports, want 1 (unmapped repo skipped)", len(reports))
+	}
+
+	r := reports[0]
+	if r.Profile != "work" || r.Checked != 4 {
+		t.Errorf("report = %+v, want profile work with 4 commits checked", r)
+	}
+	if len(r.Mismatches) != 1 || r.Mismatches[0].Hash != "b2" {
+		t.Errorf("Mismatches = %+v, want only b2 (aliases accepted)", r.Mismatches)
+	}
+	if len(r.EmailAliases) != 1 || r.EmailAliases[0] != "work@old.example.com" {
+		t.Errorf("EmailAliases = %v, want the profile's aliases", r.EmailAliases)
+	}
+	if len(git.calls) != 1 || git.calls[0].Since != "1 year ago" || git.calls[0].MaxCount != 50 {
+		t.Errorf("git called with %+v, want options passed through", git.calls)
+	}
+}
+
+func TestExecGit_Authors(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+	tmpDir := setupAuditTestEnv(t)
+
+	repo := filepath.Join(tmpDir, "repo")
+	run := func(env []string, args ...string) {
+		t.Helper()
+		cmd := utils.Command("git", append([]string{"-C", repo}, args...)...)
+		cmd.Env = append(os.Environ(), env...)
+		if output, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v failed: %v\n%s", args, err, output)
+		}
+	}
+
+	if err := os.MkdirAll(repo, 0755); err != nil {
+		t.Fatalf("Failed to create repo directory: %v", err)
+	}
+	run(nil, "init", "-q")
+
+	// A repository without commits audits cleanly
+	commits, err := ExecGit{}.Authors(repo, LogOptions{})
+	if err != nil {
+		t.Fatalf("Authors() on empty repo error = %v", err)
+	}
+	if len(commits) != 0 {
+		t.Errorf("Authors() on empty repo = %v, want none", commits)
+	}
+
+	identity := func(name, email string) []string {
+		return []string{
+			"GIT_AUTHOR_NAME=" + name, "GIT_AUTHOR_EMAIL=" + email,
+			"GIT_COMMITTER_NAME=" + name, "GIT_COMMITTER_EMAIL=" + email,
+		}
+	}
+	run(identity("Jane Doe", "work@example.com"), "commit", "-q", "--allow-empty", "-m", "first")
+	run(identity("Jane Doe", "me@personal.com"), "commit", "-q", "--allow-empty", "-m", "second")
+
+	commits, err = ExecGit{}.Authors(repo, LogOptions{})
+	if err != nil {
+		t.Fatalf("Authors() error = %v", err)
+	}
+	if len(commits) != 2 {
+		t.Fatalf("Authors() = %v, want 2 commits", commits)
+	}
+	if commits[0].Email != "me@personal.com" || commits[0].Name != "Jane Doe" {
+		t.Errorf("Authors()[0] = %+v, want newest commit first", commits[0])
+	}
+
+	commits, err = ExecGit{}.Authors(repo, LogOptions{MaxCount: 1})
+	if err != nil {
+		t.Fatalf("Authors() error = %v", err)
+	}
+	if len(commits) != 1 {
+		t.Errorf("Authors() with MaxCount=1 returned %d commits", len(commits))
+	}
+}
+
+func TestParseLog(t *testing.T) {
+	output := "abc\tjane@example.com\tJane Doe\n\nbad line\ndef\tbob@example.com\tBob\tWith Tab\n"
+	commits := parseLog(output)
+	if len(commits) != 2 {
+		t.Fatalf("parseLog() = %v, want 2 commits", commits)
+	}
+	if commits[1].Name != "Bob\tWith Tab" {
+		t.Errorf("parseLog() name = %q, want remainder of line", commits[1].Name)
+	}
+}
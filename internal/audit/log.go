@@ -0,0 +1,108 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/thuanlegit/git-identitree/internal/profile"
+	"github.com/thuanlegit/git-identitree/internal/utils"
+)
+
+const logFile = "audit.log"
+
+// Event kinds recorded in the audit log.
+const (
+	// EventOverride records a command run under a profile other than the mapped one.
+	EventOverride = "override"
+	// EventGuardBypass records a commit allowed by the strict guard bypass variable.
+	EventGuardBypass = "guard_bypass"
+)
+
+// Event is a single entry in the audit log.
+type Event struct {
+	Time      time.Time `json:"time"`
+	Kind      string    `json:"kind"`
+	Profile   string    `json:"profile,omitempty"`
+	Directory string    `json:"directory,omitempty"`
+	Detail    string    `json:"detail,omitempty"`
+}
+
+// GetLogPath returns the path to the audit log.
+func GetLogPath() (string, error) {
+	profilesDir, err := profile.GetProfilesDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(profilesDir, logFile), nil
+}
+
+// Record appends an event to the audit log as a JSON line.
+func Record(event Event) error {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+
+	logPath, err := GetLogPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(logPath), utils.PrivateDirMode); err != nil {
+		return fmt.Errorf("failed to create audit log directory: %w", err)
+	}
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal audit event: %w", err)
+	}
+
+	file, err := os.OpenFile(logPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, utils.PrivateFileMode)
+	if err != nil {
+		return fmt.Errorf("failed to open audit log: %w", err)
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write audit log: %w", err)
+	}
+	return nil
+}
+
+// ReadEvents returns all events in the audit log, oldest first.
+func ReadEvents() ([]Event, error) {
+	logPath, err := GetLogPath()
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(logPath)
+	if os.IsNotExist(err) {
+		return []Event{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	events := []Event{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var event Event
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			// Skip corrupt lines instead of failing the whole read
+			continue
+		}
+		events = append(events, event)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read audit log: %w", err)
+	}
+	return events, nil
+}
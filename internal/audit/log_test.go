@@ -0,0 +1,67 @@
+package audit
+
+import (
+	"os"
+	"testing"
+)
+
+func TestRecordAndReadEvents(t *testing.T) {
+	setupAuditTestEnv(t)
+
+	events, err := ReadEvents()
+	if err != nil {
+		t.Fatalf("ReadEvents() error = %v", err)
+	}
+	if len(events) != 0 {
+		t.Errorf("ReadEvents() = %v, want none before any record", events)
+	}
+
+	if err := Record(Event{Kind: EventOverride, Profile: "personal", Directory: "/work/repo", Detail: "mapped profile 'work'"}); err != nil {
+		t.Fatalf("Record() error = %v", err)
+	}
+	if err := Record(Event{Kind: EventOverride, Profile: "oss"}); err != nil {
+		t.Fatalf("Record() error = %v", err)
+	}
+
+	events, err = ReadEvents()
+	if err != nil {
+		t.Fatalf("ReadEvents() error = %v", err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("ReadEvents() returned %d events, want 2", len(events))
+	}
+	if events[0].Profile != "personal" || events[0].Directory != "/work/repo" || events[0].Time.IsZero() {
+		t.Errorf("events[0] = %+v", events[0])
+	}
+	if events[1].Profile != "oss" {
+		t.Errorf("events[1] = %+v", events[1])
+	}
+
+	logPath, _ := GetLogPath()
+	if info, err := os.Stat(logPath); err == nil && os.PathSeparator == '/' && info.Mode().Perm() != 0600 {
+		t.Errorf("audit log mode = %v, want 0600", info.Mode().Perm())
+	}
+}
+
+func TestReadEvents_SkipsCorruptLines(t *testing.T) {
+	setupAuditTestEnv(t)
+
+	if err := Record(Event{Kind: EventOverride, Profile: "a"}); err != nil {
+		t.Fatalf("Record() error = %v", err)
+	}
+	logPath, _ := GetLogPath()
+	f, err := os.OpenFile(logPath, os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		t.Fatalf("Failed to open audit log: %v", err)
+	}
+	_, _ = f.WriteString("not json\n")
+	_ = f.Close()
+
+	events, err := ReadEvents()
+	if err != nil {
+		t.Fatalf("ReadEvents() error = %v", err)
+	}
+	if len(events) != 1 {
+		t.Errorf("ReadEvents() returned %d events, want 1", len(events))
+	}
+}
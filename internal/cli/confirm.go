@@ -0,0 +1,42 @@
+// Package cli holds helpers shared by the gidtree commands.
+package cli
+
+import (
+	"errors"
+	"os"
+
+	"github.com/thuanlegit/git-identitree/internal/ui"
+
+	"github.com/charmbracelet/huh"
+	"github.com/mattn/go-isatty"
+)
+
+// ErrNoConfirmation is returned when a confirmation is needed but stdin is not a terminal.
+var ErrNoConfirmation = errors.New("confirmation required but stdin is not a terminal; pass --yes to confirm")
+
+// Confirmer asks the user to confirm an action described by title and description.
+type Confirmer func(title, description string) (bool, error)
+
+// NewConfirmer picks how confirmations are answered: --yes accepts them,
+// a terminal gets an interactive prompt and anything else fails fast.
+func NewConfirmer(yes bool, stdin *os.File) Confirmer {
+	switch {
+	case yes:
+		return func(string, string) (bool, error) { return true, nil }
+	case IsTerminal(stdin):
+		return func(title, description string) (bool, error) {
+			ok, err := ui.ConfirmForm(title, description)
+			if errors.Is(err, huh.ErrUserAborted) {
+				return false, nil
+			}
+			return ok, err
+		}
+	default:
+		return func(string, string) (bool, error) { return false, ErrNoConfirmation }
+	}
+}
+
+// IsTerminal reports whether f is attached to a terminal.
+func IsTerminal(f *os.File) bool {
+	return isatty.IsTerminal(f.Fd()) || isatty.IsCygwinTerminal(f.Fd())
+}
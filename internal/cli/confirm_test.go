@@ -0,0 +1,30 @@
+package cli
+
+import (
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestNewConfirmer(t *testing.T) {
+	// A regular file stands in for stdin redirected from a pipe or file
+	notTTY, err := os.CreateTemp(t.TempDir(), "stdin")
+	if err != nil {
+		t.Fatalf("CreateTemp() error = %v", err)
+	}
+	defer func() { _ = notTTY.Close() }()
+
+	if IsTerminal(notTTY) {
+		t.Fatal("IsTerminal() = true for a regular file")
+	}
+
+	ok, err := NewConfirmer(true, notTTY)("title", "")
+	if err != nil || !ok {
+		t.Errorf("--yes confirmer = %v, %v; want true, nil", ok, err)
+	}
+
+	ok, err = NewConfirmer(false, notTTY)("title", "")
+	if ok || !errors.Is(err, ErrNoConfirmation) {
+		t.Errorf("non-terminal confirmer = %v, %v; want false, ErrNoConfirmation", ok, err)
+	}
+}
@@ -0,0 +1,31 @@
+package cli
+
+import (
+	"errors"
+	"os"
+
+	"github.com/thuanlegit/git-identitree/internal/profile"
+	"github.com/thuanlegit/git-identitree/internal/ui"
+
+	"github.com/charmbracelet/huh"
+)
+
+// NewPassphraseSource returns how the passphrase of an encrypted profiles
+// store is obtained: GIDTREE_PASSPHRASE when set, a prompt when stdin is a
+// terminal and profile.ErrPassphraseRequired otherwise. With confirm set the
+// prompt asks twice, for choosing a new passphrase.
+func NewPassphraseSource(stdin *os.File, title string, confirm bool) func() (string, error) {
+	return func() (string, error) {
+		if p := os.Getenv(profile.PassphraseEnv); p != "" {
+			return p, nil
+		}
+		if !IsTerminal(stdin) {
+			return "", profile.ErrPassphraseRequired
+		}
+		p, err := ui.PassphraseForm(title, confirm)
+		if errors.Is(err, huh.ErrUserAborted) {
+			return "", profile.ErrPassphraseRequired
+		}
+		return p, err
+	}
+}
@@ -0,0 +1,27 @@
+package cli
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/thuanlegit/git-identitree/internal/profile"
+)
+
+func TestNewPassphraseSource(t *testing.T) {
+	notTTY, err := os.CreateTemp(t.TempDir(), "stdin")
+	if err != nil {
+		t.Fatalf("CreateTemp() error = %v", err)
+	}
+	defer func() { _ = notTTY.Close() }()
+
+	t.Setenv(profile.PassphraseEnv, "from-env")
+	if p, err := NewPassphraseSource(notTTY, "Passphrase", false)(); err != nil || p != "from-env" {
+		t.Errorf("source with %s = %q, %v; want from-env", profile.PassphraseEnv, p, err)
+	}
+
+	t.Setenv(profile.PassphraseEnv, "")
+	if _, err := NewPassphraseSource(notTTY, "Passphrase", false)(); !errors.Is(err, profile.ErrPassphraseRequired) {
+		t.Errorf("non-terminal source error = %v, want ErrPassphraseRequired", err)
+	}
+}
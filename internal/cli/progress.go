@@ -0,0 +1,108 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Reporter prints the outcome of each item of a bulk operation, such as
+// mapping several directories, as it completes, followed by a summary:
+//
+//	✓ ~/code/api
+//	- ~/code/web (already mapped)
+//	✗ ~/code/old: directory '~/code/old' does not exist
+//	1 mapped, 1 skipped (already mapped), 1 failed
+//
+// Quiet reporters print only the failures and the summary.
+type Reporter struct {
+	w     io.Writer
+	verb  string
+	quiet bool
+
+	done, failed int
+	skipped      int
+	// reasons are the distinct reasons given for skipped items, in order.
+	reasons []string
+
+	doneStyle, skipStyle, failStyle lipgloss.Style
+}
+
+// NewReporter returns a Reporter writing to w. verb describes a successful
+// item in the summary, such as "mapped". With color the marks are colored,
+// which suits a terminal; otherwise the output is plain text.
+func NewReporter(w io.Writer, verb string, quiet, color bool) *Reporter {
+	r := &Reporter{
+		w:         w,
+		verb:      verb,
+		quiet:     quiet,
+		doneStyle: lipgloss.NewStyle(),
+		skipStyle: lipgloss.NewStyle(),
+		failStyle: lipgloss.NewStyle(),
+	}
+	if color {
+		r.doneStyle = r.doneStyle.Foreground(lipgloss.Color("42"))
+		r.skipStyle = r.skipStyle.Foreground(lipgloss.Color("240"))
+		r.failStyle = r.failStyle.Foreground(lipgloss.Color("196")).Bold(true)
+	}
+	return r
+}
+
+// Done records that item succeeded.
+func (r *Reporter) Done(item string) {
+	r.done++
+	if !r.quiet {
+		_, _ = fmt.Fprintf(r.w, "%s %s\n", r.doneStyle.Render("✓"), item)
+	}
+}
+
+// Skip records that item was left alone, for example because there was
+// nothing to do.
+func (r *Reporter) Skip(item, reason string) {
+	r.skipped++
+	found := false
+	for _, known := range r.reasons {
+		found = found || known == reason
+	}
+	if !found {
+		r.reasons = append(r.reasons, reason)
+	}
+	if !r.quiet {
+		_, _ = fmt.Fprintf(r.w, "%s %s (%s)\n", r.skipStyle.Render("-"), item, reason)
+	}
+}
+
+// Fail records that item failed with err. Failures are printed even when
+// quiet.
+func (r *Reporter) Fail(item string, err error) {
+	r.failed++
+	_, _ = fmt.Fprintf(r.w, "%s %s: %v\n", r.failStyle.Render("✗"), item, err)
+}
+
+// Summary prints the counts, such as "7 mapped, 1 skipped (already
+// mapped), 1 failed". The reason of skipped items is given when they share
+// one. Counts of zero are left out, except that of successful items.
+func (r *Reporter) Summary() {
+	parts := []string{fmt.Sprintf("%d %s", r.done, r.verb)}
+	if r.skipped > 0 {
+		skipped := fmt.Sprintf("%d skipped", r.skipped)
+		if len(r.reasons) == 1 {
+			skipped += fmt.Sprintf(" (%s)", r.reasons[0])
+		}
+		parts = append(parts, skipped)
+	}
+	if r.failed > 0 {
+		parts = append(parts, r.failStyle.Render(fmt.Sprintf("%d failed", r.failed)))
+	}
+	_, _ = fmt.Fprintln(r.w, strings.Join(parts, ", "))
+}
+
+// Err returns an error counting the failed items, or nil when none failed.
+func (r *Reporter) Err() error {
+	if r.failed == 0 {
+		return nil
+	}
+	return fmt.Errorf("%d of %d failed", r.failed, r.done+r.skipped+r.failed)
+}
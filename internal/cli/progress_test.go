@@ -0,0 +1,60 @@
+package cli
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestReporter(t *testing.T) {
+	var out bytes.Buffer
+	r := NewReporter(&out, "mapped", false, false)
+	r.Done("~/code/api")
+	r.Skip("~/code/web", "already mapped")
+	r.Fail("~/code/old", errors.New("directory does not exist"))
+	r.Done("~/code/cli")
+	r.Summary()
+
+	want := `✓ ~/code/api
+- ~/code/web (already mapped)
+✗ ~/code/old: directory does not exist
+✓ ~/code/cli
+2 mapped, 1 skipped (already mapped), 1 failed
+`
+	if out.String() != want {
+		t.Errorf("output =\n%s\nwant\n%s", out.String(), want)
+	}
+	if err := r.Err(); err == nil || err.Error() != "1 of 4 failed" {
+		t.Errorf("Err() = %v, want 1 of 4 failed", err)
+	}
+}
+
+func TestReporter_Quiet(t *testing.T) {
+	var out bytes.Buffer
+	r := NewReporter(&out, "unmapped", true, false)
+	r.Done("~/code/api")
+	r.Skip("~/code/web", "not mapped")
+	r.Skip("~/code/cli", "mapped to another profile")
+	r.Fail("~/code/old", errors.New("permission denied"))
+	r.Summary()
+
+	want := "✗ ~/code/old: permission denied\n1 unmapped, 2 skipped, 1 failed\n"
+	if out.String() != want {
+		t.Errorf("quiet output =\n%s\nwant\n%s", out.String(), want)
+	}
+}
+
+func TestReporter_NoFailures(t *testing.T) {
+	var out bytes.Buffer
+	r := NewReporter(&out, "mapped", false, true)
+	r.Done("~/code/api")
+	r.Summary()
+
+	if !strings.Contains(out.String(), "~/code/api") || !strings.HasSuffix(out.String(), "1 mapped\n") {
+		t.Errorf("output = %q", out.String())
+	}
+	if err := r.Err(); err != nil {
+		t.Errorf("Err() = %v, want nil", err)
+	}
+}
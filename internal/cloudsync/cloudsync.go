@@ -0,0 +1,123 @@
+package cloudsync
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Provider identifies a cloud sync service.
+type Provider string
+
+const (
+	Dropbox     Provider = "Dropbox"
+	OneDrive    Provider = "OneDrive"
+	ICloud      Provider = "iCloud Drive"
+	GoogleDrive Provider = "Google Drive"
+	Box         Provider = "Box"
+)
+
+// Warning explains why mapping inside a synced folder is risky.
+const Warning = `Repositories inside cloud-synced folders break in subtle ways:
+  - the sync client rewrites files, changing mtimes and confusing git's index and gidtree's caches
+  - iCloud "Optimize Mac Storage" can evict .git contents, corrupting the repository
+  - concurrent edits from two machines produce conflicted copies inside .git
+Consider keeping repositories outside synced folders and pushing to a remote instead.`
+
+// Detect reports whether path lies inside a folder managed by a known cloud sync service.
+// It checks well-known path components and marker files in existing ancestors.
+func Detect(path string) (Provider, bool) {
+	clean := filepath.Clean(path)
+
+	if provider, ok := detectByName(clean); ok {
+		return provider, true
+	}
+	return detectByMarker(clean)
+}
+
+// IsSynced reports whether path is inside a cloud-synced folder.
+// Caches keyed on file mtimes must treat such files as always stale.
+func IsSynced(path string) bool {
+	_, ok := Detect(path)
+	return ok
+}
+
+// detectByName matches the well-known folder names used by sync clients.
+func detectByName(path string) (Provider, bool) {
+	components := strings.FieldsFunc(filepath.ToSlash(path), func(r rune) bool { return r == '/' })
+
+	for i, c := range components {
+		switch {
+		case strings.Contains(c, "com~apple~CloudDocs"), c == "iCloud Drive", c == "iCloudDrive":
+			return ICloud, true
+		case c == "Mobile Documents" && i > 0 && components[i-1] == "Library":
+			return ICloud, true
+		case c == "CloudStorage" && i > 0 && components[i-1] == "Library" && i+1 < len(components):
+			// macOS File Provider locations: ~/Library/CloudStorage/<Provider>-<account>
+			return cloudStorageProvider(components[i+1])
+		case c == "Dropbox", strings.HasPrefix(c, "Dropbox ("):
+			return Dropbox, true
+		case c == "OneDrive", strings.HasPrefix(c, "OneDrive - "):
+			return OneDrive, true
+		case c == "Google Drive", c == "My Drive":
+			return GoogleDrive, true
+		case c == "Box Sync":
+			return Box, true
+		}
+	}
+	return "", false
+}
+
+// cloudStorageProvider maps a ~/Library/CloudStorage entry to its provider.
+func cloudStorageProvider(name string) (Provider, bool) {
+	switch {
+	case strings.HasPrefix(name, "Dropbox"):
+		return Dropbox, true
+	case strings.HasPrefix(name, "OneDrive"):
+		return OneDrive, true
+	case strings.HasPrefix(name, "GoogleDrive"):
+		return GoogleDrive, true
+	case strings.HasPrefix(name, "Box"):
+		return Box, true
+	case strings.HasPrefix(name, "iCloud"):
+		return ICloud, true
+	}
+	return "", false
+}
+
+// detectByMarker walks existing ancestors looking for files sync clients leave in their root.
+func detectByMarker(path string) (Provider, bool) {
+	dir := path
+	for {
+		if info, err := os.Stat(dir); err == nil && info.IsDir() {
+			if exists(filepath.Join(dir, ".dropbox")) || exists(filepath.Join(dir, ".dropbox.cache")) {
+				return Dropbox, true
+			}
+			if desktopIniMentions(filepath.Join(dir, "desktop.ini"), "OneDrive") {
+				return OneDrive, true
+			}
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", false
+		}
+		dir = parent
+	}
+}
+
+// desktopIniMentions reports whether a Windows desktop.ini file references a sync client.
+func desktopIniMentions(path, needle string) bool {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false
+	}
+	// desktop.ini is frequently UTF-16; dropping NUL bytes is enough for an ASCII match
+	text := strings.ReplaceAll(string(data), "\x00", "")
+	return strings.Contains(strings.ToLower(text), strings.ToLower(needle))
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
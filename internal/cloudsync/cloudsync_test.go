@@ -0,0 +1,93 @@
+package cloudsync
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDetect_ByName(t *testing.T) {
+	tests := []struct {
+		path     string
+		want     Provider
+		wantSync bool
+	}{
+		{"/Users/me/Dropbox/code/repo", Dropbox, true},
+		{"/Users/me/Dropbox (Acme)/repo", Dropbox, true},
+		{"/Users/me/OneDrive - Contoso/src", OneDrive, true},
+		{"/home/me/OneDrive/src", OneDrive, true},
+		{"/Users/me/Library/Mobile Documents/com~apple~CloudDocs/src", ICloud, true},
+		{"/Users/me/Library/CloudStorage/GoogleDrive-me@example.com/My Drive/src", GoogleDrive, true},
+		{"/Users/me/Library/CloudStorage/OneDrive-Contoso/src", OneDrive, true},
+		{"/Users/me/Google Drive/src", GoogleDrive, true},
+		{"/home/me/src/dropbox-clone", "", false},
+		{"/home/me/src/Box", "", false},
+		{"/home/me/Library/Mobile/src", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			got, ok := detectByName(filepath.FromSlash(tt.path))
+			if ok != tt.wantSync || got != tt.want {
+				t.Errorf("detectByName(%q) = %q, %v; want %q, %v", tt.path, got, ok, tt.want, tt.wantSync)
+			}
+		})
+	}
+}
+
+func TestDetect_DropboxMarker(t *testing.T) {
+	root := t.TempDir()
+	synced := filepath.Join(root, "Sync Folder")
+	repo := filepath.Join(synced, "code", "repo")
+	if err := os.MkdirAll(repo, 0755); err != nil {
+		t.Fatalf("Failed to create fixture: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(synced, ".dropbox"), []byte("{}"), 0644); err != nil {
+		t.Fatalf("Failed to write marker: %v", err)
+	}
+
+	if got, ok := Detect(repo); !ok || got != Dropbox {
+		t.Errorf("Detect() = %q, %v; want Dropbox", got, ok)
+	}
+	// Paths that do not exist yet are checked against their existing ancestors
+	if got, ok := Detect(filepath.Join(repo, "not", "created")); !ok || got != Dropbox {
+		t.Errorf("Detect() for missing child = %q, %v; want Dropbox", got, ok)
+	}
+	if _, ok := Detect(filepath.Join(root, "elsewhere")); ok {
+		t.Error("Detect() should not flag siblings of the synced folder")
+	}
+}
+
+func TestDetect_OneDriveDesktopIni(t *testing.T) {
+	root := t.TempDir()
+	synced := filepath.Join(root, "Documents")
+	if err := os.MkdirAll(filepath.Join(synced, "repo"), 0755); err != nil {
+		t.Fatalf("Failed to create fixture: %v", err)
+	}
+
+	// UTF-16LE encoded, as Windows writes it
+	ini := "[.ShellClassInfo]\r\nIconResource=C:\\Program Files\\Microsoft OneDrive\\OneDrive.exe,0\r\n"
+	var utf16 []byte
+	for _, b := range []byte(ini) {
+		utf16 = append(utf16, b, 0)
+	}
+	if err := os.WriteFile(filepath.Join(synced, "desktop.ini"), utf16, 0644); err != nil {
+		t.Fatalf("Failed to write desktop.ini: %v", err)
+	}
+
+	if got, ok := Detect(filepath.Join(synced, "repo")); !ok || got != OneDrive {
+		t.Errorf("Detect() = %q, %v; want OneDrive", got, ok)
+	}
+
+	// An unrelated desktop.ini does not count
+	other := filepath.Join(root, "Other")
+	if err := os.MkdirAll(other, 0755); err != nil {
+		t.Fatalf("Failed to create fixture: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(other, "desktop.ini"), []byte("[.ShellClassInfo]\r\n"), 0644); err != nil {
+		t.Fatalf("Failed to write desktop.ini: %v", err)
+	}
+	if IsSynced(other) {
+		t.Error("IsSynced() should ignore desktop.ini files without a sync client reference")
+	}
+}
@@ -0,0 +1,187 @@
+// Package config reads and writes gidtree's own settings in ~/.gidtree/config.yaml.
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/thuanlegit/git-identitree/internal/logging"
+	"github.com/thuanlegit/git-identitree/internal/profile"
+	"github.com/thuanlegit/git-identitree/internal/utils"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	configFile = "config.yaml"
+	// xdgDir is the directory below $XDG_CONFIG_HOME that holds the config file.
+	xdgDir = "gidtree"
+)
+
+const (
+	// DefaultBackupRetention is how many git config backups are kept when unset.
+	DefaultBackupRetention = 10
+	// DefaultAgentTimeout is how long gidtree waits for the SSH agent when
+	// agent_timeout is unset.
+	DefaultAgentTimeout = time.Second
+	// OutputText is the human-readable output format.
+	OutputText = "text"
+	// OutputJSON is the machine-readable output format.
+	OutputJSON = "json"
+	// KeyPermissionsWarn warns when loading an SSH key others can access.
+	KeyPermissionsWarn = "warn"
+	// KeyPermissionsError refuses to load an SSH key others can access.
+	KeyPermissionsError = "error"
+	// KeyPermissionsIgnore loads SSH keys without checking their permissions.
+	KeyPermissionsIgnore = "ignore"
+)
+
+// keyPermissionsValues are the accepted values of key_permissions.
+var keyPermissionsValues = []string{KeyPermissionsWarn, KeyPermissionsError, KeyPermissionsIgnore}
+
+// Config holds gidtree's settings.
+type Config struct {
+	// DefaultProfile applies to directories no mapping matches.
+	DefaultProfile string `yaml:"default_profile,omitempty"`
+	// ExclusiveKeys unloads other profiles' SSH keys when one is loaded.
+	ExclusiveKeys bool `yaml:"exclusive_keys,omitempty"`
+	// UseKeychain stores SSH key passphrases in the macOS keychain.
+	UseKeychain bool `yaml:"use_keychain,omitempty"`
+	// CaseSensitiveGitdir writes gitdir: instead of gitdir/i: conditions.
+	CaseSensitiveGitdir bool `yaml:"case_sensitive_gitdir,omitempty"`
+	// BackupRetention is how many backups of each git config file are kept.
+	BackupRetention int `yaml:"backup_retention,omitempty"`
+	// OutputFormat is the default format of commands that support --json.
+	OutputFormat string `yaml:"output_format,omitempty"`
+	// ManagedInclude keeps the includeIf blocks in ~/.gidtree/gitconfig, which
+	// ~/.gitconfig includes, instead of in ~/.gitconfig itself.
+	ManagedInclude bool `yaml:"managed_include,omitempty"`
+	// ProjectsDir is where checkouts live; map completes its subdirectories.
+	ProjectsDir string `yaml:"projects_dir,omitempty"`
+	// KeyPermissions says what loading an SSH key that group or others can
+	// access does: warn, error or ignore.
+	KeyPermissions string `yaml:"key_permissions,omitempty"`
+	// AgentTimeout is how long gidtree waits for the SSH agent to answer
+	// before reporting it unreachable, as a dead forwarded agent would
+	// otherwise hang shell hooks.
+	AgentTimeout time.Duration `yaml:"agent_timeout,omitempty"`
+	// SSHAddPath and SSHKeygenPath are the ssh-add and ssh-keygen programs
+	// to run, when they are not the ones in PATH.
+	SSHAddPath    string `yaml:"ssh_add_path,omitempty"`
+	SSHKeygenPath string `yaml:"ssh_keygen_path,omitempty"`
+	// Offline leaves the SSH agent alone: loading and unloading keys only
+	// warn, for setups that only want the generated git config.
+	Offline bool `yaml:"offline,omitempty"`
+}
+
+// Default returns the settings used when the config file does not set them.
+func Default() Config {
+	return Config{
+		BackupRetention: DefaultBackupRetention,
+		OutputFormat:    OutputText,
+		KeyPermissions:  KeyPermissionsWarn,
+		AgentTimeout:    DefaultAgentTimeout,
+	}
+}
+
+// Validate reports the first setting with an invalid value.
+func (c *Config) Validate() error {
+	if c.BackupRetention < 1 {
+		return fmt.Errorf("backup_retention must be at least 1, got %d", c.BackupRetention)
+	}
+	if c.OutputFormat != OutputText && c.OutputFormat != OutputJSON {
+		return fmt.Errorf("output_format must be %q or %q, got %q", OutputText, OutputJSON, c.OutputFormat)
+	}
+	if !slices.Contains(keyPermissionsValues, c.KeyPermissions) {
+		return fmt.Errorf("key_permissions must be one of %s, got %q", strings.Join(keyPermissionsValues, ", "), c.KeyPermissions)
+	}
+	if c.AgentTimeout <= 0 {
+		return fmt.Errorf("agent_timeout must be a positive duration such as 2s, got %s", c.AgentTimeout)
+	}
+	return nil
+}
+
+// GetConfigPath returns the path to the config file: $XDG_CONFIG_HOME/gidtree/config.yaml
+// when XDG_CONFIG_HOME is set, otherwise ~/.gidtree/config.yaml.
+func GetConfigPath() (string, error) {
+	if xdg := os.Getenv("XDG_CONFIG_HOME"); xdg != "" {
+		return filepath.Join(xdg, xdgDir, configFile), nil
+	}
+	dir, err := profile.GetProfilesDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, configFile), nil
+}
+
+// Load reads the config file and fills in defaults for unset settings.
+// A missing file yields the defaults. Unknown keys are rejected so that typos
+// do not silently fall back to a default.
+func Load() (*Config, error) {
+	cfg := Default()
+
+	path, err := GetConfigPath()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return &cfg, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+	logging.Logger().Debug("read config", "path", path, "bytes", len(data))
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&cfg); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("failed to parse config file: %w", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+	return &cfg, nil
+}
+
+// Save validates and writes the config file.
+func Save(cfg *Config) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+
+	path, err := GetConfigPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), utils.PrivateDirMode); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+
+	data, err := yaml.Marshal(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to marshal config: %w", err)
+	}
+	if err := os.WriteFile(path, data, utils.PrivateFileMode); err != nil {
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+	logging.Logger().Debug("wrote config", "path", path, "bytes", len(data))
+	return nil
+}
+
+// SetDefaultProfile makes name the default profile, or clears it when name is empty.
+func SetDefaultProfile(name string) error {
+	cfg, err := Load()
+	if err != nil {
+		return err
+	}
+	cfg.DefaultProfile = name
+	return Save(cfg)
+}
@@ -0,0 +1,207 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/thuanlegit/git-identitree/internal/utils"
+)
+
+func setupConfigTestEnv(t *testing.T) string {
+	t.Helper()
+	home, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to resolve temp directory: %v", err)
+	}
+	t.Setenv(utils.RootEnv, home)
+	t.Setenv("XDG_CONFIG_HOME", "")
+	return home
+}
+
+func TestLoad_Missing(t *testing.T) {
+	setupConfigTestEnv(t)
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if *cfg != Default() {
+		t.Errorf("Load() without a file = %+v, want the defaults %+v", *cfg, Default())
+	}
+}
+
+func TestSetDefaultProfile(t *testing.T) {
+	home := setupConfigTestEnv(t)
+
+	if err := SetDefaultProfile("work"); err != nil {
+		t.Fatalf("SetDefaultProfile() error = %v", err)
+	}
+	path, err := GetConfigPath()
+	if err != nil {
+		t.Fatalf("GetConfigPath() error = %v", err)
+	}
+	if path != filepath.Join(home, ".gidtree", "config.yaml") {
+		t.Errorf("GetConfigPath() = %s, want ~/.gidtree/config.yaml", path)
+	}
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.DefaultProfile != "work" {
+		t.Errorf("DefaultProfile = %q, want work", cfg.DefaultProfile)
+	}
+
+	if err := SetDefaultProfile(""); err != nil {
+		t.Fatalf("SetDefaultProfile(\"\") error = %v", err)
+	}
+	if cfg, _ := Load(); cfg.DefaultProfile != "" {
+		t.Errorf("DefaultProfile = %q after clearing, want empty", cfg.DefaultProfile)
+	}
+}
+
+func TestLoad_Invalid(t *testing.T) {
+	setupConfigTestEnv(t)
+
+	path, _ := GetConfigPath()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("Failed to create config directory: %v", err)
+	}
+	if err := os.WriteFile(path, []byte("default_profile: [unclosed"), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	if _, err := Load(); err == nil {
+		t.Error("Load() should fail on invalid YAML")
+	}
+}
+
+// writeConfig writes raw YAML to the config file.
+func writeConfig(t *testing.T, content string) {
+	t.Helper()
+	path, err := GetConfigPath()
+	if err != nil {
+		t.Fatalf("GetConfigPath() error = %v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("Failed to create config directory: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+}
+
+func TestSaveLoad_RoundTrip(t *testing.T) {
+	setupConfigTestEnv(t)
+
+	want := Config{
+		DefaultProfile:      "work",
+		ExclusiveKeys:       true,
+		UseKeychain:         true,
+		CaseSensitiveGitdir: true,
+		BackupRetention:     3,
+		OutputFormat:        OutputJSON,
+		KeyPermissions:      KeyPermissionsError,
+		AgentTimeout:        1500 * time.Millisecond,
+		SSHAddPath:          "/opt/openssh/bin/ssh-add",
+		SSHKeygenPath:       "/opt/openssh/bin/ssh-keygen",
+		Offline:             true,
+	}
+	if err := Save(&want); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	got, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if *got != want {
+		t.Errorf("Load() = %+v, want %+v", *got, want)
+	}
+}
+
+func TestLoad_PartialFileKeepsDefaults(t *testing.T) {
+	setupConfigTestEnv(t)
+	writeConfig(t, "exclusive_keys: true\n")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	want := Default()
+	want.ExclusiveKeys = true
+	if *cfg != want {
+		t.Errorf("Load() = %+v, want %+v", *cfg, want)
+	}
+}
+
+func TestLoad_UnknownKey(t *testing.T) {
+	setupConfigTestEnv(t)
+	writeConfig(t, "exclusive_key: true\n")
+
+	_, err := Load()
+	if err == nil || !strings.Contains(err.Error(), "exclusive_key") {
+		t.Errorf("Load() error = %v, want it to name the unknown key", err)
+	}
+}
+
+func TestLoad_InvalidValues(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{name: "zero retention", content: "backup_retention: 0\n"},
+		{name: "negative retention", content: "backup_retention: -2\n"},
+		{name: "unknown format", content: "output_format: xml\n"},
+		{name: "unknown key permissions", content: "key_permissions: strict\n"},
+		{name: "agent timeout without unit", content: "agent_timeout: 2\n"},
+		{name: "negative agent timeout", content: "agent_timeout: -1s\n"},
+		{name: "wrong type", content: "use_keychain: maybe\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupConfigTestEnv(t)
+			writeConfig(t, tt.content)
+			if _, err := Load(); err == nil {
+				t.Errorf("Load() of %q should fail", tt.content)
+			}
+		})
+	}
+}
+
+func TestSave_RejectsInvalid(t *testing.T) {
+	setupConfigTestEnv(t)
+
+	cfg := Default()
+	cfg.OutputFormat = "yaml"
+	if err := Save(&cfg); err == nil {
+		t.Error("Save() should reject an invalid output format")
+	}
+	path, _ := GetConfigPath()
+	if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Save() of an invalid config should not write %s", path)
+	}
+}
+
+func TestGetConfigPath_XDG(t *testing.T) {
+	setupConfigTestEnv(t)
+	xdg := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", xdg)
+
+	path, err := GetConfigPath()
+	if err != nil {
+		t.Fatalf("GetConfigPath() error = %v", err)
+	}
+	if want := filepath.Join(xdg, "gidtree", "config.yaml"); path != want {
+		t.Errorf("GetConfigPath() = %s, want %s", path, want)
+	}
+
+	if err := SetDefaultProfile("work"); err != nil {
+		t.Fatalf("SetDefaultProfile() error = %v", err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("config should be written below XDG_CONFIG_HOME: %v", err)
+	}
+}
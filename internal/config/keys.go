@@ -0,0 +1,222 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/thuanlegit/git-identitree/internal/profile"
+	"github.com/thuanlegit/git-identitree/internal/utils"
+)
+
+// ErrUnknownKey is returned when getting or setting a key that does not exist.
+var ErrUnknownKey = errors.New("unknown config key")
+
+// Key describes a setting that `gidtree config` can get and set.
+type Key struct {
+	Name        string
+	Description string
+	// Type hints at the kind of value the setting takes, for completion.
+	Type string
+	// Values lists the accepted values, when there is a fixed set.
+	Values []string
+
+	get func(c *Config) string
+	set func(c *Config, value string) error
+}
+
+var boolValues = []string{"true", "false"}
+
+var keys = []Key{
+	{
+		Name:        "default_profile",
+		Description: "Profile used in directories no mapping covers (empty for none)",
+		Type:        "profile name",
+		get:         func(c *Config) string { return c.DefaultProfile },
+		set: func(c *Config, value string) error {
+			if value != "" {
+				manager, err := profile.NewDefaultManager()
+				if err != nil {
+					return fmt.Errorf("failed to initialize profile manager: %w", err)
+				}
+				if _, err := manager.GetProfile(value); err != nil {
+					return err
+				}
+			}
+			c.DefaultProfile = value
+			return nil
+		},
+	},
+	{
+		Name:        "exclusive_keys",
+		Description: "Unload other profiles' SSH keys when loading one",
+		Type:        "bool",
+		Values:      boolValues,
+		get:         func(c *Config) string { return strconv.FormatBool(c.ExclusiveKeys) },
+		set:         func(c *Config, value string) error { return parseBool(value, &c.ExclusiveKeys) },
+	},
+	{
+		Name:        "use_keychain",
+		Description: "Store SSH key passphrases in the macOS keychain",
+		Type:        "bool",
+		Values:      boolValues,
+		get:         func(c *Config) string { return strconv.FormatBool(c.UseKeychain) },
+		set:         func(c *Config, value string) error { return parseBool(value, &c.UseKeychain) },
+	},
+	{
+		Name:        "case_sensitive_gitdir",
+		Description: "Write case-sensitive gitdir: conditions instead of gitdir/i:",
+		Type:        "bool",
+		Values:      boolValues,
+		get:         func(c *Config) string { return strconv.FormatBool(c.CaseSensitiveGitdir) },
+		set:         func(c *Config, value string) error { return parseBool(value, &c.CaseSensitiveGitdir) },
+	},
+	{
+		Name:        "managed_include",
+		Description: "Keep includeIf blocks in ~/.gidtree/gitconfig, included from ~/.gitconfig",
+		Type:        "bool",
+		Values:      boolValues,
+		get:         func(c *Config) string { return strconv.FormatBool(c.ManagedInclude) },
+		set:         func(c *Config, value string) error { return parseBool(value, &c.ManagedInclude) },
+	},
+	{
+		Name:        "backup_retention",
+		Description: "Number of backups kept per git config file",
+		Type:        "number",
+		get:         func(c *Config) string { return strconv.Itoa(c.BackupRetention) },
+		set: func(c *Config, value string) error {
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 {
+				return fmt.Errorf("backup_retention must be a whole number of at least 1, got %q", value)
+			}
+			c.BackupRetention = n
+			return nil
+		},
+	},
+	{
+		Name:        "output_format",
+		Description: "Default output format of commands that support --json",
+		Type:        "text or json",
+		Values:      []string{OutputText, OutputJSON},
+		get:         func(c *Config) string { return c.OutputFormat },
+		set: func(c *Config, value string) error {
+			if value != OutputText && value != OutputJSON {
+				return fmt.Errorf("output_format must be %q or %q, got %q", OutputText, OutputJSON, value)
+			}
+			c.OutputFormat = value
+			return nil
+		},
+	},
+	{
+		Name:        "key_permissions",
+		Description: "What loading an SSH key others can read does: warn, error or ignore",
+		Type:        "warn, error or ignore",
+		Values:      keyPermissionsValues,
+		get:         func(c *Config) string { return c.KeyPermissions },
+		set: func(c *Config, value string) error {
+			if !slices.Contains(keyPermissionsValues, value) {
+				return fmt.Errorf("key_permissions must be one of %s, got %q", strings.Join(keyPermissionsValues, ", "), value)
+			}
+			c.KeyPermissions = value
+			return nil
+		},
+	},
+	{
+		Name:        "agent_timeout",
+		Description: "How long to wait for the SSH agent before reporting it unreachable",
+		Type:        "duration",
+		get:         func(c *Config) string { return c.AgentTimeout.String() },
+		set: func(c *Config, value string) error {
+			d, err := time.ParseDuration(value)
+			if err != nil || d <= 0 {
+				return fmt.Errorf("agent_timeout must be a positive duration such as 2s, got %q", value)
+			}
+			c.AgentTimeout = d
+			return nil
+		},
+	},
+	{
+		Name:        "ssh_add_path",
+		Description: "ssh-add program to run, when not the one in PATH",
+		Type:        "file",
+		get:         func(c *Config) string { return c.SSHAddPath },
+		set: func(c *Config, value string) error {
+			c.SSHAddPath = value
+			return nil
+		},
+	},
+	{
+		Name:        "ssh_keygen_path",
+		Description: "ssh-keygen program to run, when not the one in PATH",
+		Type:        "file",
+		get:         func(c *Config) string { return c.SSHKeygenPath },
+		set: func(c *Config, value string) error {
+			c.SSHKeygenPath = value
+			return nil
+		},
+	},
+	{
+		Name:        "offline",
+		Description: "Never talk to the SSH agent; loading and unloading keys only warn",
+		Type:        "bool",
+		Values:      boolValues,
+		get:         func(c *Config) string { return strconv.FormatBool(c.Offline) },
+		set:         func(c *Config, value string) error { return parseBool(value, &c.Offline) },
+	},
+	{
+		Name:        "projects_dir",
+		Description: "Directory holding your checkouts; map completes its subdirectories",
+		Type:        "directory",
+		get:         func(c *Config) string { return c.ProjectsDir },
+		set: func(c *Config, value string) error {
+			c.ProjectsDir = value
+			return nil
+		},
+	},
+}
+
+// Keys returns every known setting in display order.
+func Keys() []Key {
+	return keys
+}
+
+// LookupKey returns the setting with the given name.
+func LookupKey(name string) (Key, error) {
+	for _, k := range keys {
+		if k.Name == name {
+			return k, nil
+		}
+	}
+	return Key{}, utils.WithDetail(ErrUnknownKey, "unknown config key '%s'", name)
+}
+
+// Get returns the value of a setting as text.
+func (c *Config) Get(name string) (string, error) {
+	k, err := LookupKey(name)
+	if err != nil {
+		return "", err
+	}
+	return k.get(c), nil
+}
+
+// Set parses and validates value and stores it in the named setting.
+func (c *Config) Set(name, value string) error {
+	k, err := LookupKey(name)
+	if err != nil {
+		return err
+	}
+	return k.set(c, value)
+}
+
+// parseBool parses a boolean setting into dst.
+func parseBool(value string, dst *bool) error {
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return fmt.Errorf("expected true or false, got %q", value)
+	}
+	*dst = b
+	return nil
+}
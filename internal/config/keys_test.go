@@ -0,0 +1,106 @@
+package config
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/thuanlegit/git-identitree/internal/profile"
+)
+
+func TestConfigSetGet(t *testing.T) {
+	setupConfigTestEnv(t)
+
+	manager, err := profile.NewDefaultManager()
+	if err != nil {
+		t.Fatalf("NewDefaultManager() error = %v", err)
+	}
+	if err := manager.AddProfile(profile.Profile{Name: "work", Email: "work@example.com"}); err != nil {
+		t.Fatalf("AddProfile() error = %v", err)
+	}
+
+	tests := []struct {
+		key     string
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{key: "default_profile", value: "work", want: "work"},
+		{key: "default_profile", value: "", want: ""},
+		{key: "default_profile", value: "missing", wantErr: true},
+		{key: "exclusive_keys", value: "true", want: "true"},
+		{key: "use_keychain", value: "1", want: "true"},
+		{key: "case_sensitive_gitdir", value: "yes", wantErr: true},
+		{key: "managed_include", value: "true", want: "true"},
+		{key: "backup_retention", value: "5", want: "5"},
+		{key: "backup_retention", value: "0", wantErr: true},
+		{key: "backup_retention", value: "ten", wantErr: true},
+		{key: "output_format", value: "json", want: "json"},
+		{key: "output_format", value: "xml", wantErr: true},
+		{key: "key_permissions", value: "ignore", want: "ignore"},
+		{key: "key_permissions", value: "off", wantErr: true},
+		{key: "agent_timeout", value: "500ms", want: "500ms"},
+		{key: "agent_timeout", value: "0s", wantErr: true},
+		{key: "ssh_add_path", value: "/opt/openssh/bin/ssh-add", want: "/opt/openssh/bin/ssh-add"},
+		{key: "ssh_keygen_path", value: "~/bin/ssh-keygen", want: "~/bin/ssh-keygen"},
+		{key: "offline", value: "true", want: "true"},
+		{key: "offline", value: "sometimes", wantErr: true},
+		{key: "projects_dir", value: "~/code", want: "~/code"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key+"="+tt.value, func(t *testing.T) {
+			cfg := Default()
+			before, _ := cfg.Get(tt.key)
+
+			err := cfg.Set(tt.key, tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Set(%s, %q) error = %v, wantErr %v", tt.key, tt.value, err, tt.wantErr)
+			}
+			got, err := cfg.Get(tt.key)
+			if err != nil {
+				t.Fatalf("Get(%s) error = %v", tt.key, err)
+			}
+			if tt.wantErr {
+				tt.want = before
+			}
+			if got != tt.want {
+				t.Errorf("Get(%s) = %q, want %q", tt.key, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfigUnknownKey(t *testing.T) {
+	cfg := Default()
+	if _, err := cfg.Get("colour"); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("Get(colour) error = %v, want ErrUnknownKey", err)
+	}
+	if err := cfg.Set("colour", "auto"); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("Set(colour) error = %v, want ErrUnknownKey", err)
+	}
+}
+
+func TestKeys_CoverEverySetting(t *testing.T) {
+	// Every key must read back what it stores, so a missing or miswired key shows up here
+	seen := make(map[string]bool)
+	for _, k := range Keys() {
+		if seen[k.Name] {
+			t.Errorf("duplicate key %s", k.Name)
+		}
+		seen[k.Name] = true
+		if k.Description == "" {
+			t.Errorf("key %s has no description", k.Name)
+		}
+		if k.Type == "" {
+			t.Errorf("key %s has no type hint", k.Name)
+		}
+		for _, v := range k.Values {
+			cfg := Default()
+			if err := cfg.Set(k.Name, v); err != nil {
+				t.Errorf("Set(%s, %q) error = %v for a listed value", k.Name, v, err)
+			}
+			if got, _ := cfg.Get(k.Name); got != v {
+				t.Errorf("Get(%s) = %q after Set(%q)", k.Name, got, v)
+			}
+		}
+	}
+}
@@ -0,0 +1,233 @@
+// Package debugreport collects a snapshot of the local setup to attach to bug
+// reports, with everything that could identify the user masked.
+package debugreport
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+
+	"github.com/thuanlegit/git-identitree/internal/logging"
+	"github.com/thuanlegit/git-identitree/internal/mapping"
+	"github.com/thuanlegit/git-identitree/internal/profile"
+	"github.com/thuanlegit/git-identitree/internal/ssh"
+	"github.com/thuanlegit/git-identitree/internal/utils"
+)
+
+var (
+	// agentReachable and gitVersion ask the system. Replaced in tests.
+	agentReachable = ssh.AgentReachable
+	gitVersion     = runGitVersion
+)
+
+// Report is a snapshot of the local setup. Every value that could identify
+// the user is already masked, so it can be shared as it is.
+type Report struct {
+	Version        string
+	Platform       string
+	GitVersion     string
+	AgentReachable bool
+	// NamesIncluded is set when profile names are shown as they are.
+	NamesIncluded bool
+	Profiles      []ProfileInfo
+	Includes      []IncludeInfo
+	// Mappings counts the includeIf blocks that map a directory.
+	Mappings int
+	// LogErrors holds the errors and warnings logged while the report was
+	// collected; nil when logging was not enabled.
+	LogErrors []string
+}
+
+// ProfileInfo is the shape of a profile, with its values masked.
+type ProfileInfo struct {
+	Name   string
+	Email  string
+	SSHKey string
+	HasGPG bool
+}
+
+// IncludeInfo is the shape of one includeIf block in the git config.
+type IncludeInfo struct {
+	Condition    string
+	Profile      string
+	ConfigExists bool
+	Duplicate    bool
+}
+
+// Options controls what a report shows.
+type Options struct {
+	// IncludeNames shows profile names instead of masking them.
+	IncludeNames bool
+}
+
+// Collect gathers a report. version describes the running gidtree build.
+func Collect(version string, opts Options) (*Report, error) {
+	r, err := NewRedactor(opts.IncludeNames)
+	if err != nil {
+		return nil, err
+	}
+	return collect(version, opts, r)
+}
+
+func collect(version string, opts Options, r *Redactor) (*Report, error) {
+	report := &Report{
+		Version:        version,
+		Platform:       runtime.GOOS + "/" + runtime.GOARCH,
+		GitVersion:     gitVersion(),
+		AgentReachable: agentReachable(),
+		NamesIncluded:  opts.IncludeNames,
+		Profiles:       []ProfileInfo{},
+		Includes:       []IncludeInfo{},
+	}
+
+	manager, err := profile.NewDefaultManager()
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize profile manager: %w", err)
+	}
+	for _, p := range manager.ListProfiles() {
+		report.Profiles = append(report.Profiles, ProfileInfo{
+			Name:   r.Name(p.Name),
+			Email:  r.Email(p.Email),
+			SSHKey: r.Path(p.SSHKeyPath),
+			HasGPG: p.GPGKeyID != "",
+		})
+	}
+
+	mappings, err := mapping.ParseMappings()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse mappings: %w", err)
+	}
+	for _, m := range mappings {
+		if m.HasDirectory() {
+			report.Mappings++
+		}
+		info := IncludeInfo{
+			Condition: maskCondition(r, m),
+			Profile:   r.Name(m.Profile),
+			Duplicate: m.Duplicate,
+		}
+		if configPath, err := utils.ExpandPath(m.ConfigPath); err == nil && configPath != "" {
+			_, err := os.Stat(configPath)
+			info.ConfigExists = err == nil
+		}
+		report.Includes = append(report.Includes, info)
+	}
+
+	// Masked last, so every profile value and directory is known by now
+	if logging.Enabled() {
+		report.LogErrors = []string{}
+		for _, line := range logging.Recent() {
+			if strings.Contains(line, "level=ERROR") || strings.Contains(line, "level=WARN") || strings.Contains(line, " error=") {
+				report.LogErrors = append(report.LogErrors, r.Text(line))
+			}
+		}
+	}
+	return report, nil
+}
+
+// maskCondition masks the argument of an includeIf condition, keeping its
+// keyword and, for hasconfig, the config key.
+func maskCondition(r *Redactor, m mapping.Mapping) string {
+	kind, arg, _ := strings.Cut(m.RawCondition, ":")
+	switch m.ConditionKind {
+	case mapping.ConditionGitDir, mapping.ConditionGitDirI:
+		return kind + ":" + r.Path(arg)
+	case mapping.ConditionOnBranch:
+		return kind + ":" + r.token("branch", arg)
+	case mapping.ConditionHasConfig:
+		// hasconfig:remote.*.url:<pattern>
+		key, pattern, ok := strings.Cut(arg, ":")
+		if ok {
+			return kind + ":" + key + ":" + r.token("url", pattern)
+		}
+	}
+	return string(mapping.ConditionUnknown) + ":" + r.token("condition", m.RawCondition)
+}
+
+// runGitVersion returns the output of git --version.
+func runGitVersion() string {
+	cmd := utils.Command("git", "--version")
+	out, err := cmd.Output()
+	logging.Command(cmd, err)
+	if err != nil {
+		return "not found"
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// Markdown renders the report for pasting into an issue.
+func (rep *Report) Markdown() string {
+	var b strings.Builder
+	b.WriteString("# gidtree debug report\n\n")
+	if rep.NamesIncluded {
+		b.WriteString("Emails, paths and directory names are replaced by salted hashes.\n\n")
+	} else {
+		b.WriteString("Profile names, emails, paths and directory names are replaced by salted hashes.\n\n")
+	}
+
+	b.WriteString("| | |\n|---|---|\n")
+	fmt.Fprintf(&b, "| gidtree | %s |\n", rep.Version)
+	fmt.Fprintf(&b, "| OS/arch | %s |\n", rep.Platform)
+	fmt.Fprintf(&b, "| git | %s |\n", rep.GitVersion)
+	if rep.AgentReachable {
+		b.WriteString("| ssh-agent | reachable |\n")
+	} else {
+		b.WriteString("| ssh-agent | not reachable |\n")
+	}
+	fmt.Fprintf(&b, "| Profiles | %d |\n", len(rep.Profiles))
+	fmt.Fprintf(&b, "| Mappings | %d |\n", rep.Mappings)
+
+	b.WriteString("\n## Profiles\n\n")
+	if len(rep.Profiles) == 0 {
+		b.WriteString("None.\n")
+	}
+	for _, p := range rep.Profiles {
+		fmt.Fprintf(&b, "- %s: email %s, SSH key %s, GPG key %s\n", p.Name, orNone(p.Email), orNone(p.SSHKey), setOrNone(p.HasGPG))
+	}
+
+	b.WriteString("\n## includeIf blocks\n\n")
+	if len(rep.Includes) == 0 {
+		b.WriteString("None.\n")
+	}
+	for i, inc := range rep.Includes {
+		config := "config exists"
+		if !inc.ConfigExists {
+			config = "config missing"
+		}
+		fmt.Fprintf(&b, "%d. `%s` → %s (%s", i+1, inc.Condition, orNone(inc.Profile), config)
+		if inc.Duplicate {
+			b.WriteString(", overridden by a later block")
+		}
+		b.WriteString(")\n")
+	}
+
+	b.WriteString("\n## Logged errors\n\n")
+	switch {
+	case rep.LogErrors == nil:
+		b.WriteString("Run with --verbose to include the errors logged while collecting this report.\n")
+	case len(rep.LogErrors) == 0:
+		b.WriteString("None.\n")
+	default:
+		b.WriteString("```\n")
+		for _, line := range rep.LogErrors {
+			b.WriteString(line + "\n")
+		}
+		b.WriteString("```\n")
+	}
+	return b.String()
+}
+
+func orNone(s string) string {
+	if s == "" {
+		return "none"
+	}
+	return s
+}
+
+func setOrNone(ok bool) string {
+	if ok {
+		return "set"
+	}
+	return "none"
+}
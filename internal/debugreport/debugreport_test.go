@@ -0,0 +1,143 @@
+package debugreport
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/thuanlegit/git-identitree/internal/logging"
+	"github.com/thuanlegit/git-identitree/internal/profile"
+	"github.com/thuanlegit/git-identitree/internal/utils"
+	"github.com/thuanlegit/git-identitree/pkg/gidtree/gidtreetest"
+)
+
+// stubSystem answers the system questions without running anything.
+func stubSystem(t *testing.T) {
+	t.Helper()
+	originalAgent, originalGit := agentReachable, gitVersion
+	t.Cleanup(func() { agentReachable, gitVersion = originalAgent, originalGit })
+	agentReachable = func() bool { return true }
+	gitVersion = func() string { return "git version 2.45.0" }
+}
+
+// buildIdentifiableEnv sets up profiles whose every value is easy to spot in
+// a report.
+func buildIdentifiableEnv(t *testing.T) *gidtreetest.Built {
+	t.Helper()
+	env := gidtreetest.NewEnv(t).
+		WithProfile(profile.Profile{Name: "acmeclient", Email: "jane.doe@acmecorp.example", GPGKeyID: "ABCDEF0123456789"}).
+		WithProfile(profile.Profile{Name: "personalstuff", Email: "janedoe@mailhost.example"}).
+		WithMapping("acmeclient", "clients/acmecorp").
+		WithMapping("personalstuff", "hobby/secretproject").
+		Build()
+
+	keyPath := env.Path(".ssh/id_acmeclient")
+	if err := os.MkdirAll(filepath.Dir(keyPath), utils.PrivateDirMode); err != nil {
+		t.Fatalf("failed to create .ssh: %v", err)
+	}
+	if err := os.WriteFile(keyPath, []byte("key"), 0600); err != nil {
+		t.Fatalf("failed to write key: %v", err)
+	}
+	manager, err := profile.NewDefaultManager()
+	if err != nil {
+		t.Fatalf("NewDefaultManager() error = %v", err)
+	}
+	prof, err := manager.GetProfile("acmeclient")
+	if err != nil {
+		t.Fatalf("GetProfile() error = %v", err)
+	}
+	updated := *prof
+	updated.SSHKeyPath = keyPath
+	if err := manager.UpdateProfile("acmeclient", updated); err != nil {
+		t.Fatalf("UpdateProfile() error = %v", err)
+	}
+	return env
+}
+
+func TestCollect_NoRawValues(t *testing.T) {
+	env := buildIdentifiableEnv(t)
+	stubSystem(t)
+
+	var logs bytes.Buffer
+	logging.Enable(&logs)
+	defer logging.Disable()
+	logging.Logger().Warn("failed to load key", "path", env.Path(".ssh/id_acmeclient"), "error", "bad passphrase for jane.doe@acmecorp.example")
+
+	report, err := Collect("1.2.3", Options{})
+	if err != nil {
+		t.Fatalf("Collect() error = %v", err)
+	}
+	out := report.Markdown()
+
+	for _, raw := range []string{
+		env.Home(), filepath.Base(env.Home()),
+		"acmeclient", "personalstuff",
+		"jane.doe", "acmecorp", "janedoe", "mailhost",
+		"id_acmeclient", "clients", "hobby", "secretproject",
+		"ABCDEF0123456789",
+	} {
+		if strings.Contains(out, raw) {
+			t.Errorf("report leaks %q:\n%s", raw, out)
+		}
+	}
+	for _, want := range []string{
+		"| gidtree | 1.2.3 |",
+		"| git | git version 2.45.0 |",
+		"| ssh-agent | reachable |",
+		"| Profiles | 2 |",
+		"| Mappings | 2 |",
+		"GPG key set",
+		"`gitdir/i:~/path-",
+		"failed to load key",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("report = %s\nwant %q", out, want)
+		}
+	}
+	if len(report.LogErrors) != 1 {
+		t.Errorf("LogErrors = %q, want the logged warning", report.LogErrors)
+	}
+}
+
+func TestCollect_IncludeNames(t *testing.T) {
+	buildIdentifiableEnv(t)
+	stubSystem(t)
+
+	report, err := Collect("1.2.3", Options{IncludeNames: true})
+	if err != nil {
+		t.Fatalf("Collect() error = %v", err)
+	}
+	out := report.Markdown()
+	for _, want := range []string{"- acmeclient: email email-", "→ personalstuff (config exists)"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("report = %s\nwant %q", out, want)
+		}
+	}
+	for _, raw := range []string{"jane.doe", "acmecorp", "secretproject"} {
+		if strings.Contains(out, raw) {
+			t.Errorf("report leaks %q with names included:\n%s", raw, out)
+		}
+	}
+	if report.LogErrors != nil || !strings.Contains(out, "Run with --verbose") {
+		t.Errorf("report without logging = %s\nwant the --verbose hint", out)
+	}
+}
+
+func TestMarkdown_Empty(t *testing.T) {
+	gidtreetest.NewEnv(t).Build()
+	stubSystem(t)
+	agentReachable = func() bool { return false }
+
+	report, err := Collect("dev", Options{})
+	if err != nil {
+		t.Fatalf("Collect() error = %v", err)
+	}
+	out := report.Markdown()
+	for _, want := range []string{"| ssh-agent | not reachable |", "## Profiles\n\nNone.", "## includeIf blocks\n\nNone."} {
+		if !strings.Contains(out, want) {
+			t.Errorf("report = %s\nwant %q", out, want)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package debugreport
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/thuanlegit/git-identitree/internal/utils"
+)
+
+// emailPattern finds email addresses in free text such as log lines.
+var emailPattern = regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`)
+
+// Redactor masks values that could identify the user. Each value becomes a
+// short hash keyed with a random salt, so one value is masked the same way
+// throughout a report but cannot be looked up by hashing guesses, and two
+// reports cannot be linked.
+type Redactor struct {
+	salt      []byte
+	keepNames bool
+	home      string
+	// masked maps every name and email masked so far to its mask, so Text
+	// can find them in free text.
+	masked map[string]string
+	// paths holds every path masked so far, for Text.
+	paths map[string]bool
+}
+
+// pathRest matches the rest of a path in free text, up to the end of a log
+// value.
+const pathRest = `[^\s"'=,;\])]*`
+
+// NewRedactor creates a Redactor with a random salt. With keepNames, profile
+// names are left as they are.
+func NewRedactor(keepNames bool) (*Redactor, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("failed to generate salt: %w", err)
+	}
+	home, _ := utils.Root()
+	return newRedactor(salt, keepNames, home), nil
+}
+
+func newRedactor(salt []byte, keepNames bool, home string) *Redactor {
+	return &Redactor{
+		salt:      salt,
+		keepNames: keepNames,
+		home:      strings.TrimRight(filepath.ToSlash(home), "/"),
+		masked:    make(map[string]string),
+		paths:     make(map[string]bool),
+	}
+}
+
+// Name masks a profile name, unless names are kept.
+func (r *Redactor) Name(name string) string {
+	if r.keepNames || name == "" {
+		return name
+	}
+	return r.mask("profile", name)
+}
+
+// Email masks an email address. Case is ignored, as mail servers do.
+func (r *Redactor) Email(email string) string {
+	if email == "" {
+		return ""
+	}
+	token := r.token("email", strings.ToLower(email))
+	r.masked[email] = token
+	return token
+}
+
+// Path masks every segment of a path. A leading home directory becomes ~ and
+// a trailing separator is kept, so nesting stays visible:
+// /home/jane/code/acme/ becomes ~/path-1a2b3c4d/path-5e6f7a8b/.
+func (r *Redactor) Path(path string) string {
+	if path == "" {
+		return ""
+	}
+	slashed := filepath.ToSlash(path)
+	prefix := ""
+	rest := slashed
+	switch {
+	case r.home != "" && (slashed == r.home || strings.HasPrefix(slashed, r.home+"/")):
+		prefix, rest = "~", strings.TrimPrefix(slashed, r.home)
+	case strings.HasPrefix(slashed, "~"):
+		prefix, rest = "~", strings.TrimPrefix(slashed, "~")
+	}
+
+	segments := strings.Split(rest, "/")
+	for i, seg := range segments {
+		if seg != "" {
+			segments[i] = r.token("path", seg)
+		}
+	}
+	r.paths[strings.TrimRight(slashed, "/")] = true
+	return prefix + strings.Join(segments, "/")
+}
+
+// Text masks free text such as a log line: every path under the home
+// directory or one masked so far, with whatever follows it, every name and
+// email masked so far, and any other email address.
+func (r *Redactor) Text(s string) string {
+	// Longest first, so a path is matched before a directory inside it
+	prefixes := []string{"~"}
+	if r.home != "" {
+		prefixes = append(prefixes, r.home)
+	}
+	for p := range r.paths {
+		if p != "" {
+			prefixes = append(prefixes, p)
+		}
+	}
+	sort.Slice(prefixes, func(i, j int) bool { return len(prefixes[i]) > len(prefixes[j]) })
+	for i, p := range prefixes {
+		// Either separator
+		prefixes[i] = strings.ReplaceAll(regexp.QuoteMeta(p), "/", `[/\\]`)
+	}
+	paths := regexp.MustCompile("(?:" + strings.Join(prefixes, "|") + ")" + pathRest)
+	s = paths.ReplaceAllStringFunc(s, r.Path)
+
+	values := make([]string, 0, len(r.masked))
+	for v := range r.masked {
+		values = append(values, v)
+	}
+	sort.Slice(values, func(i, j int) bool { return len(values[i]) > len(values[j]) })
+	for _, v := range values {
+		s = strings.ReplaceAll(s, v, r.masked[v])
+	}
+
+	return emailPattern.ReplaceAllStringFunc(s, r.Email)
+}
+
+// mask returns the token for value and remembers it for Text.
+func (r *Redactor) mask(kind, value string) string {
+	token := r.token(kind, value)
+	r.masked[value] = token
+	return token
+}
+
+// token returns kind and the first 4 bytes of the salted hash of value.
+func (r *Redactor) token(kind, value string) string {
+	mac := hmac.New(sha256.New, r.salt)
+	mac.Write([]byte(value))
+	return kind + "-" + hex.EncodeToString(mac.Sum(nil)[:4])
+}
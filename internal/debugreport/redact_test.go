@@ -0,0 +1,68 @@
+package debugreport
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRedactor(t *testing.T) {
+	r := newRedactor([]byte("salt"), false, "/home/jane")
+
+	name := r.Name("acme-client")
+	if name == "acme-client" || !strings.HasPrefix(name, "profile-") {
+		t.Errorf("Name() = %q, want a masked name", name)
+	}
+	if again := r.Name("acme-client"); again != name {
+		t.Errorf("Name() = %q, then %q for the same name", name, again)
+	}
+	if email := r.Email("Jane.Doe@acme.example"); email != r.Email("jane.doe@acme.example") || !strings.HasPrefix(email, "email-") {
+		t.Errorf("Email() = %q, want one mask regardless of case", email)
+	}
+
+	path := r.Path("/home/jane/clients/acme/")
+	parts := strings.Split(path, "/")
+	if len(parts) != 4 || parts[0] != "~" || parts[3] != "" {
+		t.Errorf("Path() = %q, want ~ and two masked segments with the trailing slash", path)
+	}
+	if nested := r.Path("/home/jane/clients/acme/api"); !strings.HasPrefix(nested, path) {
+		t.Errorf("Path() of a subdirectory = %q, want it under %q", nested, path)
+	}
+	if tilde := r.Path("~/clients/acme/"); tilde != path {
+		t.Errorf("Path(~/...) = %q, want %q", tilde, path)
+	}
+	if outside := r.Path("/srv/acme"); strings.Contains(outside, "srv") || strings.Contains(outside, "acme") {
+		t.Errorf("Path() outside home = %q, want every segment masked", outside)
+	}
+
+	other := newRedactor([]byte("other salt"), false, "/home/jane")
+	if other.Name("acme-client") == name {
+		t.Error("Name() is the same with another salt, so reports could be linked")
+	}
+	kept := newRedactor([]byte("salt"), true, "/home/jane")
+	if got := kept.Name("acme-client"); got != "acme-client" {
+		t.Errorf("Name() with keepNames = %q, want it unchanged", got)
+	}
+}
+
+func TestRedactor_Text(t *testing.T) {
+	r := newRedactor([]byte("salt"), false, "/home/jane")
+	r.Name("acme-client")
+	r.Path("/srv/checkouts/acme")
+
+	line := `level=DEBUG msg="ran command" cmd=/usr/bin/ssh-add args="[/home/jane/.ssh/id_acme]" error="exit status 1" ` +
+		`note="acme-client key for bob@acme.example in ~/clients/acme and /srv/checkouts/acme/api and /home/jane"`
+	got := r.Text(line)
+	for _, raw := range []string{"jane", "id_acme", "acme-client", "bob@acme.example", "clients", "checkouts", "/api"} {
+		if strings.Contains(got, raw) {
+			t.Errorf("Text() = %q, leaks %q", got, raw)
+		}
+	}
+	for _, kept := range []string{"level=DEBUG", "/usr/bin/ssh-add", "exit status 1"} {
+		if !strings.Contains(got, kept) {
+			t.Errorf("Text() = %q, want %q kept", got, kept)
+		}
+	}
+	if !strings.Contains(got, r.Path("/home/jane/.ssh/id_acme")) {
+		t.Errorf("Text() = %q, want the key path masked as Path masks it", got)
+	}
+}
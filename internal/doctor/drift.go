@@ -0,0 +1,151 @@
+package doctor
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/thuanlegit/git-identitree/internal/mapping"
+	"github.com/thuanlegit/git-identitree/internal/profile"
+)
+
+// DriftKind is a way the files git reads disagree with profiles.yaml.
+type DriftKind int
+
+const (
+	// StaleConfig is a mapped profile's ~/.gitconfig-<name> that is missing or
+	// differs from what the profile generates.
+	StaleConfig DriftKind = iota
+	// DanglingInclude is a mapping that includes a file which does not exist,
+	// usually a config moved or renamed by hand.
+	DanglingInclude
+	// OrphanedMapping is a mapping of a profile that no longer exists.
+	OrphanedMapping
+)
+
+// Drift is one difference between the profiles and the files generated from them.
+type Drift struct {
+	Kind    DriftKind
+	Profile string
+	// Path is the profile config for StaleConfig and the missing include target
+	// for DanglingInclude.
+	Path string
+	// Mapping is the includeIf block concerned, for DanglingInclude and
+	// OrphanedMapping.
+	Mapping mapping.Mapping
+
+	profile *profile.Profile
+}
+
+// String describes the drift for display.
+func (d Drift) String() string {
+	switch d.Kind {
+	case StaleConfig:
+		if _, err := os.Stat(d.Path); os.IsNotExist(err) {
+			return fmt.Sprintf("%s is missing (profile '%s')", d.Path, d.Profile)
+		}
+		return fmt.Sprintf("%s is out of date with profile '%s'", d.Path, d.Profile)
+	case DanglingInclude:
+		return fmt.Sprintf("%s includes %s, which does not exist (profile '%s')", d.Mapping.Directory, d.Path, d.Profile)
+	default:
+		if d.Mapping.HasDirectory() {
+			return fmt.Sprintf("%s is mapped to profile '%s', which does not exist", d.Mapping.Directory, d.Profile)
+		}
+		return fmt.Sprintf("includeIf \"%s\" uses profile '%s', which does not exist", d.Mapping.RawCondition, d.Profile)
+	}
+}
+
+// Fix brings the files in line with the profiles: it rewrites a stale config,
+// points a dangling include at the profile's config, or removes an orphaned
+// mapping.
+func (d Drift) Fix() error {
+	switch d.Kind {
+	case StaleConfig:
+		if _, err := mapping.SyncProfileConfig(d.profile); err != nil {
+			return err
+		}
+	case DanglingInclude:
+		configPath, err := mapping.GetProfileConfigPath(d.Profile)
+		if err != nil {
+			return err
+		}
+		if err := mapping.RepointMapping(d.Mapping, configPath); err != nil {
+			return fmt.Errorf("failed to repoint %s: %w", d.Mapping.Directory, err)
+		}
+	default:
+		if !d.Mapping.HasDirectory() {
+			return fmt.Errorf("remove includeIf \"%s\" from ~/.gitconfig by hand", d.Mapping.RawCondition)
+		}
+		if err := mapping.UnmapDirectory(d.Mapping.Directory); err != nil {
+			return fmt.Errorf("failed to unmap %s: %w", d.Mapping.Directory, err)
+		}
+	}
+	return nil
+}
+
+// CheckDrift compares ~/.gitconfig and the generated ~/.gitconfig-<name> files
+// with the profiles. Stale configs come first, so fixing in order regenerates
+// a config before includes are pointed at it. Blocks gidtree did not write are
+// not its business and are skipped, as are duplicates, which
+// DuplicateMappings reports.
+func CheckDrift() ([]Drift, error) {
+	profiles, err := profile.LoadProfiles()
+	if err != nil {
+		return nil, err
+	}
+	mappings, err := mapping.ParseMappings()
+	if err != nil {
+		return nil, err
+	}
+
+	byName := make(map[string]*profile.Profile, len(profiles))
+	for i := range profiles {
+		byName[profiles[i].Name] = &profiles[i]
+	}
+	mapped := make(map[string]bool)
+	for _, m := range mappings {
+		mapped[m.Profile] = true
+	}
+
+	var drifts []Drift
+	for i := range profiles {
+		p := &profiles[i]
+		if !mapped[p.Name] {
+			continue
+		}
+		configPath, err := mapping.GetProfileConfigPath(p.Name)
+		if err != nil {
+			return nil, err
+		}
+		data, err := os.ReadFile(configPath)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to read %s: %w", configPath, err)
+		}
+		if err != nil || string(data) != mapping.RenderProfileConfig(p) {
+			drifts = append(drifts, Drift{Kind: StaleConfig, Profile: p.Name, Path: configPath, profile: p})
+		}
+	}
+
+	for _, m := range mappings {
+		// Overridden blocks are reported as duplicates; unmapping one would
+		// take the block git uses with it
+		if m.Profile == "" || m.Duplicate {
+			continue
+		}
+		if byName[m.Profile] == nil {
+			drifts = append(drifts, Drift{Kind: OrphanedMapping, Profile: m.Profile, Mapping: m})
+			continue
+		}
+		configPath, err := mapping.GetProfileConfigPath(m.Profile)
+		if err != nil {
+			return nil, err
+		}
+		if filepath.Clean(m.ConfigPath) == configPath || !m.HasDirectory() {
+			continue
+		}
+		if _, err := os.Stat(m.ConfigPath); os.IsNotExist(err) {
+			drifts = append(drifts, Drift{Kind: DanglingInclude, Profile: m.Profile, Path: m.ConfigPath, Mapping: m})
+		}
+	}
+	return drifts, nil
+}
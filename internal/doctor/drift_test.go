@@ -0,0 +1,107 @@
+package doctor
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/thuanlegit/git-identitree/internal/mapping"
+	"github.com/thuanlegit/git-identitree/internal/profile"
+)
+
+// writeDriftFixture maps work and personal, then lets the files drift: work's
+// profile changes, personal's mapping includes a moved file, and old is
+// mapped without a profile.
+func writeDriftFixture(t *testing.T, home string) {
+	t.Helper()
+
+	work := profile.Profile{Name: "work", Email: "jane@acme.com"}
+	personal := profile.Profile{Name: "personal", Email: "me@example.com"}
+	old := profile.Profile{Name: "old", Email: "old@example.com"}
+	for _, p := range []profile.Profile{work, personal, old} {
+		if err := mapping.MapProfileToDirectoryWithOptions(&p, filepath.Join(home, p.Name), mapping.MapOptions{Create: true}); err != nil {
+			t.Fatalf("MapProfileToDirectoryWithOptions() error = %v", err)
+		}
+	}
+
+	work.AuthorName = "Jane Doe"
+	if err := profile.SaveProfiles([]profile.Profile{work, personal}); err != nil {
+		t.Fatalf("SaveProfiles() error = %v", err)
+	}
+
+	gitConfig := filepath.Join(home, ".gitconfig")
+	data, err := os.ReadFile(gitConfig)
+	if err != nil {
+		t.Fatalf("Failed to read git config: %v", err)
+	}
+	moved := strings.Replace(string(data), "~/.gitconfig-personal", "~/dotfiles/.gitconfig-personal", 1)
+	if err := os.WriteFile(gitConfig, []byte(moved), 0644); err != nil {
+		t.Fatalf("Failed to write git config: %v", err)
+	}
+	mapping.Invalidate()
+}
+
+func TestCheckDrift(t *testing.T) {
+	home := setupDoctorTestEnv(t)
+	writeDriftFixture(t, home)
+
+	drifts, err := CheckDrift()
+	if err != nil {
+		t.Fatalf("CheckDrift() error = %v", err)
+	}
+
+	var got []string
+	for _, d := range drifts {
+		got = append(got, d.String())
+	}
+	want := []string{
+		filepath.Join(home, ".gitconfig-work") + " is out of date with profile 'work'",
+		filepath.Join(home, "personal") + "/ includes " + filepath.Join(home, "dotfiles", ".gitconfig-personal") + ", which does not exist (profile 'personal')",
+		filepath.Join(home, "old") + "/ is mapped to profile 'old', which does not exist",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("CheckDrift() =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestDrift_Fix(t *testing.T) {
+	home := setupDoctorTestEnv(t)
+	writeDriftFixture(t, home)
+
+	drifts, err := CheckDrift()
+	if err != nil {
+		t.Fatalf("CheckDrift() error = %v", err)
+	}
+	for _, d := range drifts {
+		if err := d.Fix(); err != nil {
+			t.Fatalf("Fix(%s) error = %v", d, err)
+		}
+	}
+
+	drifts, err = CheckDrift()
+	if err != nil {
+		t.Fatalf("CheckDrift() error = %v", err)
+	}
+	if len(drifts) != 0 {
+		t.Errorf("CheckDrift() after fixing = %v, want none", drifts)
+	}
+
+	content, err := os.ReadFile(filepath.Join(home, ".gitconfig-work"))
+	if err != nil {
+		t.Fatalf("Failed to read profile config: %v", err)
+	}
+	if !strings.Contains(string(content), "name = Jane Doe\n") {
+		t.Errorf("profile config = %q, want the new author name", content)
+	}
+	m, err := mapping.GetMappingForDirectory(filepath.Join(home, "personal"))
+	if err != nil || m == nil {
+		t.Fatalf("GetMappingForDirectory() = %v, %v", m, err)
+	}
+	if m.ConfigPath != filepath.Join(home, ".gitconfig-personal") {
+		t.Errorf("repointed mapping includes %s", m.ConfigPath)
+	}
+	if m, _ := mapping.GetMappingForDirectory(filepath.Join(home, "old")); m != nil {
+		t.Errorf("orphaned mapping was not removed: %+v", m)
+	}
+}
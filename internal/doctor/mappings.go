@@ -0,0 +1,86 @@
+package doctor
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/thuanlegit/git-identitree/internal/mapping"
+)
+
+// MissingDirectories returns the mappings whose directory no longer exists.
+// They are usually left over from deleted checkouts, or typos made before map
+// checked that the directory exists.
+func MissingDirectories() ([]mapping.Mapping, error) {
+	mappings, err := mapping.ParseMappings()
+	if err != nil {
+		return nil, err
+	}
+
+	var missing []mapping.Mapping
+	for _, m := range mappings {
+		if !m.HasDirectory() {
+			continue
+		}
+		if _, err := os.Stat(m.Directory); os.IsNotExist(err) {
+			missing = append(missing, m)
+		}
+	}
+	return missing, nil
+}
+
+// DuplicateMapping is a directory with more than one includeIf block. git
+// applies them all in order, so the last one decides the identity.
+type DuplicateMapping struct {
+	Directory string
+	// Kept is the last block, the one git's identity comes from.
+	Kept mapping.Mapping
+	// Overridden are the earlier blocks, in file order.
+	Overridden []mapping.Mapping
+}
+
+// String describes the duplicate for a report.
+func (d DuplicateMapping) String() string {
+	profiles := make([]string, len(d.Overridden))
+	for i, m := range d.Overridden {
+		profiles[i] = fmt.Sprintf("'%s'", m.Profile)
+	}
+	return fmt.Sprintf("%s is mapped %d times; git uses profile '%s', overriding %s",
+		d.Directory, len(d.Overridden)+1, d.Kept.Profile, strings.Join(profiles, ", "))
+}
+
+// Fix removes the overridden blocks, keeping the one git uses.
+func (d DuplicateMapping) Fix() error {
+	_, err := mapping.MergeDuplicateMappings(d.Directory)
+	return err
+}
+
+// DuplicateMappings returns the directories mapped by more than one includeIf
+// block, usually left behind by hand edits or by older versions of gidtree.
+func DuplicateMappings() ([]DuplicateMapping, error) {
+	mappings, err := mapping.ParseMappings()
+	if err != nil {
+		return nil, err
+	}
+
+	var duplicates []DuplicateMapping
+	index := make(map[string]int)
+	for _, m := range mappings {
+		if !m.Duplicate {
+			continue
+		}
+		i, ok := index[m.Directory]
+		if !ok {
+			i = len(duplicates)
+			index[m.Directory] = i
+			duplicates = append(duplicates, DuplicateMapping{Directory: m.Directory})
+		}
+		duplicates[i].Overridden = append(duplicates[i].Overridden, m)
+	}
+	for _, m := range mappings {
+		if i, ok := index[m.Directory]; ok && m.HasDirectory() && !m.Duplicate {
+			duplicates[i].Kept = m
+		}
+	}
+	return duplicates, nil
+}
@@ -0,0 +1,73 @@
+package doctor
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/thuanlegit/git-identitree/internal/mapping"
+	"github.com/thuanlegit/git-identitree/internal/profile"
+)
+
+func TestMissingDirectories(t *testing.T) {
+	home := setupDoctorTestEnv(t)
+
+	kept := filepath.Join(home, "work")
+	deleted := filepath.Join(home, "old")
+	prof := &profile.Profile{Name: "work", Email: "work@example.com"}
+	for _, dir := range []string{kept, deleted} {
+		if err := mapping.MapProfileToDirectoryWithOptions(prof, dir, mapping.MapOptions{Create: true}); err != nil {
+			t.Fatalf("MapProfileToDirectoryWithOptions() error = %v", err)
+		}
+	}
+	if err := os.Remove(deleted); err != nil {
+		t.Fatalf("Failed to remove directory: %v", err)
+	}
+
+	missing, err := MissingDirectories()
+	if err != nil {
+		t.Fatalf("MissingDirectories() error = %v", err)
+	}
+	if len(missing) != 1 || missing[0].Directory != deleted+string(filepath.Separator) {
+		t.Errorf("MissingDirectories() = %+v, want only %s", missing, deleted)
+	}
+}
+
+func TestDuplicateMappings(t *testing.T) {
+	home := setupDoctorTestEnv(t)
+
+	dir := filepath.Join(home, "work") + string(filepath.Separator)
+	content := "[includeIf \"gitdir/i:" + dir + "\"]\n    path = ~/.gitconfig-old\n\n" +
+		"[includeIf \"gitdir/i:/elsewhere/\"]\n    path = ~/.gitconfig-other\n\n" +
+		"[includeIf \"gitdir/i:" + dir + "\"]\n    path = ~/.gitconfig-work\n"
+	if err := os.WriteFile(filepath.Join(home, ".gitconfig"), []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write git config: %v", err)
+	}
+
+	duplicates, err := DuplicateMappings()
+	if err != nil {
+		t.Fatalf("DuplicateMappings() error = %v", err)
+	}
+	if len(duplicates) != 1 {
+		t.Fatalf("DuplicateMappings() = %+v, want one", duplicates)
+	}
+	d := duplicates[0]
+	if d.Directory != dir || d.Kept.Profile != "work" || len(d.Overridden) != 1 || d.Overridden[0].Profile != "old" {
+		t.Errorf("DuplicateMappings() = %+v", d)
+	}
+	if want := "mapped 2 times; git uses profile 'work', overriding 'old'"; !strings.Contains(d.String(), want) {
+		t.Errorf("String() = %q, want it to contain %q", d.String(), want)
+	}
+
+	if err := d.Fix(); err != nil {
+		t.Fatalf("Fix() error = %v", err)
+	}
+	if duplicates, err := DuplicateMappings(); err != nil || len(duplicates) != 0 {
+		t.Errorf("DuplicateMappings() after Fix = %+v, %v; want none", duplicates, err)
+	}
+	m, err := mapping.GetMappingForDirectory(dir)
+	if err != nil || m == nil || m.Profile != "work" {
+		t.Errorf("GetMappingForDirectory() after Fix = %+v, %v; want profile work", m, err)
+	}
+}
@@ -0,0 +1,34 @@
+package doctor
+
+import (
+	"fmt"
+
+	"github.com/thuanlegit/git-identitree/internal/profile"
+)
+
+// InvalidName is a profile whose name was accepted before names were checked
+// but cannot be used safely in file names and git config.
+type InvalidName struct {
+	Profile string
+	Err     error
+}
+
+// String describes the name for a report, with the command that fixes it.
+func (n InvalidName) String() string {
+	return fmt.Sprintf("%v; rename it with: gidtree profile rename %q <new-name>", n.Err, n.Profile)
+}
+
+// InvalidNames returns the profiles whose names profile.ValidateName rejects.
+func InvalidNames() ([]InvalidName, error) {
+	profiles, err := profile.LoadProfiles()
+	if err != nil {
+		return nil, err
+	}
+	var invalid []InvalidName
+	for _, p := range profiles {
+		if err := profile.ValidateName(p.Name); err != nil {
+			invalid = append(invalid, InvalidName{Profile: p.Name, Err: err})
+		}
+	}
+	return invalid, nil
+}
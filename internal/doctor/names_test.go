@@ -0,0 +1,32 @@
+package doctor
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/thuanlegit/git-identitree/internal/profile"
+)
+
+func TestInvalidNames(t *testing.T) {
+	setupDoctorTestEnv(t)
+
+	// Written directly, as versions that did not check names did
+	if err := profile.SaveProfiles([]profile.Profile{
+		{Name: "work", Email: "work@example.com"},
+		{Name: "acme client", Email: "acme@example.com"},
+	}); err != nil {
+		t.Fatalf("SaveProfiles() error = %v", err)
+	}
+
+	invalid, err := InvalidNames()
+	if err != nil {
+		t.Fatalf("InvalidNames() error = %v", err)
+	}
+	if len(invalid) != 1 || invalid[0].Profile != "acme client" || !errors.Is(invalid[0].Err, profile.ErrInvalidName) {
+		t.Fatalf("InvalidNames() = %+v, want only 'acme client'", invalid)
+	}
+	if got := invalid[0].String(); !strings.Contains(got, `gidtree profile rename "acme client" <new-name>`) {
+		t.Errorf("String() = %q, want the rename command", got)
+	}
+}
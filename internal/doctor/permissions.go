@@ -0,0 +1,124 @@
+// Package doctor checks the local setup for problems gidtree can report or fix.
+package doctor
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"github.com/thuanlegit/git-identitree/internal/mapping"
+	"github.com/thuanlegit/git-identitree/internal/profile"
+	"github.com/thuanlegit/git-identitree/internal/utils"
+)
+
+// permissionsSupported is false on Windows, where Unix permission bits do not
+// describe who can read a file.
+var permissionsSupported = runtime.GOOS != "windows"
+
+// PermissionIssue is a file or directory readable by more than its owner.
+type PermissionIssue struct {
+	Path string
+	// Kind describes what the file is, e.g. "SSH private key of profile 'work'".
+	Kind string
+	Mode os.FileMode
+	// Want is Mode without any group or world access.
+	Want os.FileMode
+}
+
+// String describes the issue for display.
+func (i PermissionIssue) String() string {
+	return fmt.Sprintf("%s is %04o, should be %04o (%s)", i.Path, i.Mode, i.Want, i.Kind)
+}
+
+// Fix restricts the path to its owner.
+func (i PermissionIssue) Fix() error {
+	if err := os.Chmod(i.Path, i.Want); err != nil {
+		return fmt.Errorf("failed to change permissions of %s: %w", i.Path, err)
+	}
+	return nil
+}
+
+// CheckPermissions reports gidtree's own files, the generated ~/.gitconfig-<name>
+// files and the SSH private keys profiles reference when group or others can
+// access them. It reports nothing on Windows.
+func CheckPermissions() ([]PermissionIssue, error) {
+	if !permissionsSupported {
+		return nil, nil
+	}
+
+	var issues []PermissionIssue
+
+	profilesDir, err := profile.GetProfilesDir()
+	if err != nil {
+		return nil, err
+	}
+	err = filepath.WalkDir(profilesDir, func(path string, d fs.DirEntry, err error) error {
+		if os.IsNotExist(err) && path == profilesDir {
+			return filepath.SkipDir
+		}
+		if err != nil {
+			return err
+		}
+		// Symlinks are not ours to tighten; chmod would change their target
+		if d.Type()&fs.ModeSymlink != 0 {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		issues = appendIssue(issues, path, "gidtree data", info.Mode())
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to check %s: %w", profilesDir, err)
+	}
+
+	profiles, err := profile.LoadProfiles()
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range profiles {
+		configPath, err := mapping.GetProfileConfigPath(p.Name)
+		if err != nil {
+			return nil, err
+		}
+		issues = appendPathIssue(issues, configPath, fmt.Sprintf("git config of profile '%s'", p.Name))
+
+		if p.SSHKeyPath != "" {
+			keyPath, err := utils.NormalizePath(p.SSHKeyPath)
+			if err != nil {
+				continue
+			}
+			issues = appendPathIssue(issues, keyPath, fmt.Sprintf("SSH private key of profile '%s'", p.Name))
+		}
+	}
+
+	return issues, nil
+}
+
+// appendPathIssue stats path and records an issue when it is shared.
+// Missing files are skipped; other checks report those.
+func appendPathIssue(issues []PermissionIssue, path, kind string) []PermissionIssue {
+	info, err := os.Stat(path)
+	if err != nil {
+		return issues
+	}
+	for _, existing := range issues {
+		if existing.Path == path {
+			return issues
+		}
+	}
+	return appendIssue(issues, path, kind, info.Mode())
+}
+
+// appendIssue records an issue when mode grants group or world access.
+func appendIssue(issues []PermissionIssue, path, kind string, mode os.FileMode) []PermissionIssue {
+	if !utils.IsShared(mode) {
+		return issues
+	}
+	perm := mode.Perm()
+	return append(issues, PermissionIssue{Path: path, Kind: kind, Mode: perm, Want: perm &^ 0077})
+}
@@ -0,0 +1,123 @@
+package doctor
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/thuanlegit/git-identitree/internal/profile"
+	"github.com/thuanlegit/git-identitree/internal/utils"
+)
+
+func setupDoctorTestEnv(t *testing.T) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("permission bits are not meaningful on Windows")
+	}
+
+	home, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to resolve temp directory: %v", err)
+	}
+	t.Setenv(utils.RootEnv, home)
+	t.Setenv("XDG_CONFIG_HOME", "")
+	return home
+}
+
+func TestCheckPermissions(t *testing.T) {
+	home := setupDoctorTestEnv(t)
+
+	keyPath := filepath.Join(home, ".ssh", "id_work")
+	if err := os.MkdirAll(filepath.Dir(keyPath), 0700); err != nil {
+		t.Fatalf("Failed to create .ssh: %v", err)
+	}
+	if err := os.WriteFile(keyPath, []byte("key"), 0644); err != nil {
+		t.Fatalf("Failed to write key: %v", err)
+	}
+	if err := profile.SaveProfiles([]profile.Profile{{Name: "work", Email: "work@example.com", SSHKeyPath: "~/.ssh/id_work"}}); err != nil {
+		t.Fatalf("SaveProfiles() error = %v", err)
+	}
+	gidtreeDir := filepath.Join(home, ".gidtree")
+	profilesPath := filepath.Join(gidtreeDir, "profiles.yaml")
+	configPath := filepath.Join(home, ".gitconfig-work")
+	if err := os.WriteFile(configPath, []byte("[user]\n"), 0644); err != nil {
+		t.Fatalf("Failed to write profile config: %v", err)
+	}
+
+	// New files are private
+	issues, err := CheckPermissions()
+	if err != nil {
+		t.Fatalf("CheckPermissions() error = %v", err)
+	}
+	for _, issue := range issues {
+		if issue.Path == gidtreeDir || issue.Path == profilesPath {
+			t.Errorf("freshly written %s reported: %s", issue.Path, issue)
+		}
+	}
+
+	// Loosen everything the way older versions wrote it
+	for path, mode := range map[string]os.FileMode{gidtreeDir: 0755, profilesPath: 0644} {
+		if err := os.Chmod(path, mode); err != nil {
+			t.Fatalf("Chmod() error = %v", err)
+		}
+	}
+	// Symlinks are left alone
+	if err := os.Symlink(keyPath, filepath.Join(gidtreeDir, "link")); err != nil {
+		t.Fatalf("Symlink() error = %v", err)
+	}
+
+	issues, err = CheckPermissions()
+	if err != nil {
+		t.Fatalf("CheckPermissions() error = %v", err)
+	}
+	want := map[string]os.FileMode{gidtreeDir: 0700, profilesPath: 0600, configPath: 0600, keyPath: 0600}
+	if len(issues) != len(want) {
+		t.Fatalf("CheckPermissions() = %v, want %d issues", issues, len(want))
+	}
+	for _, issue := range issues {
+		if wantMode, ok := want[issue.Path]; !ok || issue.Want != wantMode {
+			t.Errorf("unexpected issue %s", issue)
+		}
+		if issue.Path == keyPath && !strings.Contains(issue.String(), "SSH private key of profile 'work'") {
+			t.Errorf("key issue = %q, want it to name the profile", issue)
+		}
+	}
+
+	for _, issue := range issues {
+		if err := issue.Fix(); err != nil {
+			t.Fatalf("Fix() error = %v", err)
+		}
+	}
+	if issues, err := CheckPermissions(); err != nil || len(issues) != 0 {
+		t.Errorf("CheckPermissions() after fixing = %v, %v; want none", issues, err)
+	}
+}
+
+func TestCheckPermissions_NoGidtreeDir(t *testing.T) {
+	setupDoctorTestEnv(t)
+
+	issues, err := CheckPermissions()
+	if err != nil || len(issues) != 0 {
+		t.Errorf("CheckPermissions() without ~/.gidtree = %v, %v; want none", issues, err)
+	}
+}
+
+func TestCheckPermissions_Unsupported(t *testing.T) {
+	home := setupDoctorTestEnv(t)
+	if err := os.MkdirAll(filepath.Join(home, ".gidtree"), 0777); err != nil {
+		t.Fatalf("Failed to create .gidtree: %v", err)
+	}
+	if err := os.Chmod(filepath.Join(home, ".gidtree"), 0777); err != nil {
+		t.Fatalf("Chmod() error = %v", err)
+	}
+
+	original := permissionsSupported
+	permissionsSupported = false
+	defer func() { permissionsSupported = original }()
+
+	if issues, err := CheckPermissions(); err != nil || issues != nil {
+		t.Errorf("CheckPermissions() on an unsupported platform = %v, %v; want nothing", issues, err)
+	}
+}
@@ -0,0 +1,359 @@
+// Package gitconfig parses git config files into a document that keeps every
+// byte of the original, so gidtree can edit a section and write the file back
+// without disturbing comments, spacing or anything else it did not touch.
+package gitconfig
+
+import (
+	"strings"
+)
+
+// LineKind classifies a line of a git config file.
+type LineKind int
+
+const (
+	// Blank is an empty or whitespace-only line.
+	Blank LineKind = iota
+	// Comment is a line starting with # or ;.
+	Comment
+	// Header is a section header such as [user] or [includeIf "gitdir:~/"].
+	Header
+	// Entry is a key, with or without a value.
+	Entry
+	// Other is anything git would reject; it is kept as it is.
+	Other
+)
+
+// Line is one line of a git config file. An entry whose value is continued
+// with a trailing backslash spans several physical lines.
+type Line struct {
+	Kind LineKind
+	// Raw is the exact text of the line, line endings included.
+	Raw string
+	// Key and Value are set for entries. Value is unquoted and unescaped;
+	// NoValue is set for a key without "=", which git reads as true.
+	Key     string
+	Value   string
+	NoValue bool
+}
+
+// Text returns the line without its final line ending.
+func (l *Line) Text() string {
+	return strings.TrimRight(l.Raw, "\r\n")
+}
+
+// SetComment replaces a comment line with text, keeping its line ending.
+func (l *Line) SetComment(text string) {
+	l.Raw = text + lineEnding(l.Raw)
+}
+
+// Section is a section header with the lines that follow it up to the next
+// header.
+type Section struct {
+	// Name is the section name as written; compare it with Is.
+	Name string
+	// Subsection is the unquoted subsection name, e.g. the condition of an
+	// includeIf section. HasSubsection tells an empty one from none.
+	Subsection    string
+	HasSubsection bool
+
+	// Leading are the comment lines directly above the header, with no blank
+	// line in between. They usually describe the section.
+	Leading []*Line
+	Header  *Line
+	// Body holds the entries, comments and blank lines after the header.
+	Body []*Line
+}
+
+// Is reports whether the section has the given name. Section names are
+// case-insensitive.
+func (s *Section) Is(name string) bool {
+	return strings.EqualFold(s.Name, name)
+}
+
+// Get returns the value of the first entry with key. Keys are case-insensitive.
+func (s *Section) Get(key string) (string, bool) {
+	for _, l := range s.Body {
+		if l.Kind == Entry && strings.EqualFold(l.Key, key) {
+			return l.Value, true
+		}
+	}
+	return "", false
+}
+
+// GetAll returns the values of every entry with key, in file order.
+func (s *Section) GetAll(key string) []string {
+	var values []string
+	for _, l := range s.Body {
+		if l.Kind == Entry && strings.EqualFold(l.Key, key) {
+			values = append(values, l.Value)
+		}
+	}
+	return values
+}
+
+// Add appends an entry after the last entry of the section.
+func (s *Section) Add(key, value string) {
+	line := entryLine("    ", key, value, s.lineEnding())
+	at := len(s.Body)
+	for at > 0 && s.Body[at-1].Kind == Blank {
+		at--
+	}
+	s.Body = append(s.Body[:at], append([]*Line{line}, s.Body[at:]...)...)
+}
+
+// Set gives key a single value: the first entry with key is rewritten, keeping
+// its indentation, and any others are removed. Without one the entry is added.
+func (s *Section) Set(key, value string) {
+	var body []*Line
+	found := false
+	for _, l := range s.Body {
+		if l.Kind != Entry || !strings.EqualFold(l.Key, key) {
+			body = append(body, l)
+			continue
+		}
+		if found {
+			continue
+		}
+		found = true
+		indent := l.Raw[:len(l.Raw)-len(strings.TrimLeft(l.Raw, " \t"))]
+		body = append(body, entryLine(indent, l.Key, value, lineEnding(l.Raw)))
+	}
+	s.Body = body
+	if !found {
+		s.Add(key, value)
+	}
+}
+
+// SetSubsection rewrites the header for a new subsection, keeping the header's
+// indentation and line ending. Anything after the closing bracket is dropped.
+func (s *Section) SetSubsection(sub string) {
+	raw := s.Header.Raw
+	indent := raw[:len(raw)-len(strings.TrimLeft(raw, " \t"))]
+	s.Header.Raw = indent + "[" + s.Name + " " + quoteSubsection(sub) + "]" + lineEnding(raw)
+	s.Subsection, s.HasSubsection = sub, true
+}
+
+// Serialize returns the section's lines, leading comments included.
+func (s *Section) Serialize() []byte {
+	var b strings.Builder
+	for _, l := range s.Leading {
+		b.WriteString(l.Raw)
+	}
+	b.WriteString(s.Header.Raw)
+	for _, l := range s.Body {
+		b.WriteString(l.Raw)
+	}
+	return []byte(b.String())
+}
+
+// AddComment adds a comment line directly above the header. text should start
+// with # or ;.
+func (s *Section) AddComment(text string) {
+	s.Leading = append(s.Leading, &Line{Kind: Comment, Raw: text + s.lineEnding()})
+}
+
+// lineEnding returns the line ending the header uses.
+func (s *Section) lineEnding() string {
+	if s.Header != nil {
+		if end := lineEnding(s.Header.Raw); end != "" {
+			return end
+		}
+	}
+	return "\n"
+}
+
+// NewSection returns a section with a header for name and, unless it is
+// empty, subsection. It is not part of a document until appended.
+func NewSection(name, subsection string) *Section {
+	header := "[" + name + "]"
+	if subsection != "" {
+		header = "[" + name + " " + quoteSubsection(subsection) + "]"
+	}
+	return &Section{
+		Name:          name,
+		Subsection:    subsection,
+		HasSubsection: subsection != "",
+		Header:        &Line{Kind: Header, Raw: header + "\n"},
+	}
+}
+
+// Document is a parsed git config file.
+type Document struct {
+	// Preamble holds the lines before the first section header.
+	Preamble []*Line
+	Sections []*Section
+}
+
+// Serialize returns the file content. A document nothing was changed in
+// serializes to exactly the bytes it was parsed from.
+func (d *Document) Serialize() []byte {
+	var b strings.Builder
+	for _, l := range d.Preamble {
+		b.WriteString(l.Raw)
+	}
+	for _, s := range d.Sections {
+		b.Write(s.Serialize())
+	}
+	return []byte(b.String())
+}
+
+// AppendSection adds s at the end of the document, separated from the content
+// before it by exactly one blank line. Whether the file ends with a newline
+// is left as it was; an empty document gets none.
+func (d *Document) AppendSection(s *Section) {
+	final := d.endsWithNewline()
+	d.setFinalNewline(true)
+
+	ending := d.lineEnding()
+	if container := d.lastContainer(); container != nil {
+		*container = trimBlank(*container)
+	}
+	if len(d.Preamble) > 0 || len(d.Sections) > 0 {
+		d.appendLine(&Line{Kind: Blank, Raw: ending})
+	}
+	for _, l := range append(append(append([]*Line(nil), s.Leading...), s.Header), s.Body...) {
+		l.Raw = l.Text() + ending
+	}
+	d.Sections = append(d.Sections, s)
+
+	d.setFinalNewline(final)
+}
+
+// RemoveSection takes s out of the document. Its leading comments stay
+// behind, as with `git config --remove-section`; callers that want them gone
+// remove them from s.Leading first. The blank line that separated the section
+// from what came before goes with it, so no gap is left.
+func (d *Document) RemoveSection(s *Section) {
+	at := -1
+	for i, section := range d.Sections {
+		if section == s {
+			at = i
+			break
+		}
+	}
+	if at < 0 {
+		return
+	}
+	final := d.endsWithNewline()
+	d.setFinalNewline(true)
+
+	// Trailing blank lines separate the section from the next one and stay
+	body := trimBlank(s.Body)
+	trailing := s.Body[len(body):]
+	s.Body = body
+
+	d.Sections = append(d.Sections[:at], d.Sections[at+1:]...)
+	before := &d.Preamble
+	if at > 0 {
+		before = &d.Sections[at-1].Body
+	}
+	*before = append(*before, s.Leading...)
+	s.Leading = nil
+	if n := len(*before); n > 0 && (*before)[n-1].Kind == Blank {
+		*before = (*before)[:n-1]
+	}
+	if d.isEmptyBefore(at) {
+		// A section removed from the top of the file leaves no blank lines behind
+		trailing = nil
+	}
+	*before = append(*before, trailing...)
+
+	d.setFinalNewline(final)
+}
+
+// isEmptyBefore reports whether nothing comes before the section at index at.
+func (d *Document) isEmptyBefore(at int) bool {
+	return at == 0 && len(d.Preamble) == 0
+}
+
+// lines returns every line in file order.
+func (d *Document) lines() []*Line {
+	lines := append([]*Line(nil), d.Preamble...)
+	for _, s := range d.Sections {
+		lines = append(lines, s.Leading...)
+		lines = append(lines, s.Header)
+		lines = append(lines, s.Body...)
+	}
+	return lines
+}
+
+// lastContainer returns the line slice the document ends with, or nil when
+// the document is empty.
+func (d *Document) lastContainer() *[]*Line {
+	if n := len(d.Sections); n > 0 {
+		return &d.Sections[n-1].Body
+	}
+	if len(d.Preamble) > 0 {
+		return &d.Preamble
+	}
+	return nil
+}
+
+// appendLine adds l at the end of the document.
+func (d *Document) appendLine(l *Line) {
+	if container := d.lastContainer(); container != nil {
+		*container = append(*container, l)
+		return
+	}
+	d.Preamble = append(d.Preamble, l)
+}
+
+// lineEnding returns the line ending of the document's first line, "\n" for
+// a document without one.
+func (d *Document) lineEnding() string {
+	for _, l := range d.lines() {
+		if end := lineEnding(l.Raw); end != "" {
+			return end
+		}
+	}
+	return "\n"
+}
+
+// endsWithNewline reports whether the last line has a line ending.
+func (d *Document) endsWithNewline() bool {
+	lines := d.lines()
+	return len(lines) > 0 && lineEnding(lines[len(lines)-1].Raw) != ""
+}
+
+// setFinalNewline adds or removes the line ending of the last line.
+func (d *Document) setFinalNewline(final bool) {
+	lines := d.lines()
+	if len(lines) == 0 {
+		return
+	}
+	last := lines[len(lines)-1]
+	if final && lineEnding(last.Raw) == "" {
+		last.Raw += d.lineEnding()
+	} else if !final {
+		last.Raw = last.Text()
+	}
+}
+
+// lineEnding returns the line ending raw ends with, if any.
+func lineEnding(raw string) string {
+	switch {
+	case strings.HasSuffix(raw, "\r\n"):
+		return "\r\n"
+	case strings.HasSuffix(raw, "\n"):
+		return "\n"
+	}
+	return ""
+}
+
+// trimBlank drops blank lines from the end of lines.
+func trimBlank(lines []*Line) []*Line {
+	for len(lines) > 0 && lines[len(lines)-1].Kind == Blank {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// entryLine formats an entry line.
+func entryLine(indent, key, value, ending string) *Line {
+	return &Line{
+		Kind:  Entry,
+		Raw:   indent + key + " = " + quoteValue(value) + ending,
+		Key:   key,
+		Value: value,
+	}
+}
@@ -0,0 +1,248 @@
+package gitconfig
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the .golden files in testdata")
+
+// corpus returns the sample git configs in testdata.
+func corpus(t *testing.T) []string {
+	t.Helper()
+	files, err := filepath.Glob(filepath.Join("testdata", "*.gitconfig"))
+	if err != nil || len(files) == 0 {
+		t.Fatalf("no sample git configs in testdata: %v", err)
+	}
+	return files
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, file := range corpus(t) {
+		t.Run(filepath.Base(file), func(t *testing.T) {
+			data, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatalf("Failed to read sample: %v", err)
+			}
+			if got := Parse(data).Serialize(); string(got) != string(data) {
+				t.Errorf("Serialize(Parse()) =\n%q\nwant\n%q", got, data)
+			}
+		})
+	}
+}
+
+func TestParseGolden(t *testing.T) {
+	for _, file := range corpus(t) {
+		t.Run(filepath.Base(file), func(t *testing.T) {
+			data, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatalf("Failed to read sample: %v", err)
+			}
+			got := dump(Parse(data))
+
+			golden := strings.TrimSuffix(file, ".gitconfig") + ".golden"
+			if *update {
+				if err := os.WriteFile(golden, []byte(got), 0644); err != nil {
+					t.Fatalf("Failed to write golden file: %v", err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("Failed to read golden file (run with -update to create it): %v", err)
+			}
+			if got != string(want) {
+				t.Errorf("parsed document =\n%s\nwant\n%s", got, want)
+			}
+		})
+	}
+}
+
+// dump describes a document line by line, for golden files.
+func dump(d *Document) string {
+	var b strings.Builder
+	writeLines := func(indent string, lines []*Line) {
+		for _, l := range lines {
+			switch l.Kind {
+			case Blank:
+				fmt.Fprintf(&b, "%sblank\n", indent)
+			case Comment:
+				fmt.Fprintf(&b, "%scomment %q\n", indent, strings.TrimSpace(l.Text()))
+			case Entry:
+				if l.NoValue {
+					fmt.Fprintf(&b, "%sentry %s (no value)\n", indent, l.Key)
+				} else {
+					fmt.Fprintf(&b, "%sentry %s = %q\n", indent, l.Key, l.Value)
+				}
+			default:
+				fmt.Fprintf(&b, "%sother %q\n", indent, l.Text())
+			}
+		}
+	}
+	b.WriteString("preamble\n")
+	writeLines("  ", d.Preamble)
+	for _, s := range d.Sections {
+		if s.HasSubsection {
+			fmt.Fprintf(&b, "section %s %q\n", s.Name, s.Subsection)
+		} else {
+			fmt.Fprintf(&b, "section %s\n", s.Name)
+		}
+		writeLines("  leading ", s.Leading)
+		writeLines("  ", s.Body)
+	}
+	return b.String()
+}
+
+func TestSectionGetAndSet(t *testing.T) {
+	d := Parse([]byte("[includeIf \"gitdir:/work/\"]\n\tpath = ~/.gitconfig-old # old\n\tpath = ~/.gitconfig-extra\n\n[core]\n"))
+	s := d.Sections[0]
+	if !s.Is("includeif") || s.Subsection != "gitdir:/work/" {
+		t.Fatalf("section = %q %q", s.Name, s.Subsection)
+	}
+	if got := s.GetAll("PATH"); len(got) != 2 || got[0] != "~/.gitconfig-old" {
+		t.Errorf("GetAll() = %q", got)
+	}
+
+	s.Set("path", "~/.gitconfig-work")
+	want := "[includeIf \"gitdir:/work/\"]\n\tpath = ~/.gitconfig-work\n\n[core]\n"
+	if got := string(d.Serialize()); got != want {
+		t.Errorf("after Set() =\n%q\nwant\n%q", got, want)
+	}
+
+	s.Add("path", "~/my files/#1")
+	if v, _ := s.Get("path"); v != "~/.gitconfig-work" {
+		t.Errorf("Get() = %q", v)
+	}
+	want = "[includeIf \"gitdir:/work/\"]\n\tpath = ~/.gitconfig-work\n    path = \"~/my files/#1\"\n\n[core]\n"
+	if got := string(d.Serialize()); got != want {
+		t.Errorf("after Add() =\n%q\nwant\n%q", got, want)
+	}
+	if got := Parse(d.Serialize()).Sections[0].GetAll("path"); len(got) != 2 || got[1] != "~/my files/#1" {
+		t.Errorf("added value reads back as %q", got)
+	}
+}
+
+func TestAppendSection(t *testing.T) {
+	tests := []struct {
+		name, in, want string
+	}{
+		{"empty", "", "# note\n[includeIf \"gitdir/i:/work/\"]\n    path = ~/.gitconfig-work"},
+		{"trailing blank lines", "[user]\n    name = Jane\n\n\n  \n", "[user]\n    name = Jane\n\n# note\n[includeIf \"gitdir/i:/work/\"]\n    path = ~/.gitconfig-work\n"},
+		{"no final newline", "[user]\n    name = Jane", "[user]\n    name = Jane\n\n# note\n[includeIf \"gitdir/i:/work/\"]\n    path = ~/.gitconfig-work"},
+		{"crlf", "[user]\r\n    name = Jane\r\n", "[user]\r\n    name = Jane\r\n\r\n# note\r\n[includeIf \"gitdir/i:/work/\"]\r\n    path = ~/.gitconfig-work\r\n"},
+		{"comments only", "# just a comment\n", "# just a comment\n\n# note\n[includeIf \"gitdir/i:/work/\"]\n    path = ~/.gitconfig-work\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := Parse([]byte(tt.in))
+			s := NewSection("includeIf", "gitdir/i:/work/")
+			s.AddComment("# note")
+			s.Add("path", "~/.gitconfig-work")
+			d.AppendSection(s)
+			if got := string(d.Serialize()); got != tt.want {
+				t.Errorf("AppendSection() =\n%q\nwant\n%q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRemoveSection(t *testing.T) {
+	tests := []struct {
+		name, in, want string
+	}{
+		{
+			"middle",
+			"[user]\n    name = Jane\n\n[includeIf \"gitdir:/a/\"]\n    path = a\n\n[core]\n    editor = vim\n",
+			"[user]\n    name = Jane\n\n[core]\n    editor = vim\n",
+		},
+		{
+			"top",
+			"[includeIf \"gitdir:/a/\"]\n    path = a\n\n\n[core]\n    editor = vim\n",
+			"[core]\n    editor = vim\n",
+		},
+		{
+			"last without final newline",
+			"[user]\n    name = Jane\n\n[includeIf \"gitdir:/a/\"]\n    path = a",
+			"[user]\n    name = Jane",
+		},
+		{
+			"leading comments stay",
+			"[user]\n    name = Jane\n\n# about a\n[includeIf \"gitdir:/a/\"]\n    path = a\n",
+			"[user]\n    name = Jane\n\n# about a\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := Parse([]byte(tt.in))
+			for _, s := range d.Sections {
+				if s.Is("includeIf") {
+					d.RemoveSection(s)
+					break
+				}
+			}
+			if got := string(d.Serialize()); got != tt.want {
+				t.Errorf("RemoveSection() =\n%q\nwant\n%q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseValue(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		noValue bool
+		valid   bool
+	}{
+		{" = plain", "plain", false, true},
+		{"=  spaced value  ", "spaced value", false, true},
+		{` = "  kept  "`, "  kept  ", false, true},
+		{` = a"b c"d`, "ab cd", false, true},
+		{" = a # comment", "a", false, true},
+		{` = "a # not a comment"`, "a # not a comment", false, true},
+		{` = esc\\aped\"\t\n`, "esc\\aped\"\t\n", false, true},
+		{"", "", true, true},
+		{" ; comment", "", true, true},
+		{"= ", "", false, true},
+		{` = "unterminated`, "", false, false},
+		{` = bad\q`, "", false, false},
+		{" novalue", "", false, false},
+	}
+	for _, tt := range tests {
+		value, noValue, more, valid := parseValue(tt.in)
+		if value != tt.want || noValue != tt.noValue || valid != tt.valid || more {
+			t.Errorf("parseValue(%q) = %q, %v, %v, %v; want %q, %v, false, %v", tt.in, value, noValue, more, valid, tt.want, tt.noValue, tt.valid)
+		}
+	}
+}
+
+func TestNewSectionQuotesSubsection(t *testing.T) {
+	s := NewSection("includeIf", `gitdir:C:\work "x"/`)
+	if got := s.Header.Text(); got != `[includeIf "gitdir:C:\\work \"x\"/"]` {
+		t.Errorf("header = %s", got)
+	}
+	d := Parse([]byte(s.Header.Raw))
+	if len(d.Sections) != 1 || d.Sections[0].Subsection != `gitdir:C:\work "x"/` {
+		t.Errorf("header reads back as %+v", d.Sections)
+	}
+}
+
+func TestSetSubsection(t *testing.T) {
+	d := Parse([]byte("# above\r\n  [includeIf \"gitdir:/old/\"] # trailing\r\n\tpath = x\r\n"))
+	s := d.Sections[0]
+	s.SetSubsection("gitdir:/new dir/")
+
+	want := "# above\r\n  [includeIf \"gitdir:/new dir/\"]\r\n\tpath = x\r\n"
+	if got := string(d.Serialize()); got != want {
+		t.Errorf("Serialize() = %q, want %q", got, want)
+	}
+	if got := string(s.Serialize()); got != want {
+		t.Errorf("Section.Serialize() = %q, want %q", got, want)
+	}
+	if again := Parse(d.Serialize()).Sections[0]; again.Subsection != "gitdir:/new dir/" {
+		t.Errorf("subsection reads back as %q", again.Subsection)
+	}
+}
@@ -0,0 +1,255 @@
+package gitconfig
+
+import (
+	"strings"
+)
+
+// Parse reads a git config file. It never fails: lines git would reject are
+// kept as Other, so the document still serializes to the original bytes.
+func Parse(data []byte) *Document {
+	d := &Document{}
+	body := &d.Preamble
+
+	physical := splitLines(string(data))
+	for i := 0; i < len(physical); i++ {
+		raw := physical[i]
+		trimmed := strings.TrimSpace(raw)
+		if i == 0 {
+			// git skips a UTF-8 byte order mark
+			trimmed = strings.TrimPrefix(trimmed, "\ufeff")
+		}
+
+		switch {
+		case trimmed == "":
+			*body = append(*body, &Line{Kind: Blank, Raw: raw})
+		case trimmed[0] == '#' || trimmed[0] == ';':
+			*body = append(*body, &Line{Kind: Comment, Raw: raw})
+		case trimmed[0] == '[':
+			name, sub, hasSub, ok := parseHeader(trimmed)
+			if !ok {
+				*body = append(*body, &Line{Kind: Other, Raw: raw})
+				continue
+			}
+			s := &Section{
+				Name:          name,
+				Subsection:    sub,
+				HasSubsection: hasSub,
+				Header:        &Line{Kind: Header, Raw: raw},
+			}
+			// Comments right above the header belong to the section
+			at := len(*body)
+			for at > 0 && (*body)[at-1].Kind == Comment {
+				at--
+			}
+			s.Leading = append([]*Line(nil), (*body)[at:]...)
+			*body = (*body)[:at]
+			d.Sections = append(d.Sections, s)
+			body = &s.Body
+		default:
+			line := &Line{Kind: Other, Raw: raw}
+			key, rest, ok := parseKey(trimmed)
+			if ok {
+				// A value ending in a backslash continues on the next line
+				value, noValue, more, valid := parseValue(rest)
+				for more && i+1 < len(physical) {
+					i++
+					raw += physical[i]
+					_, rest, _ = parseKey(strings.TrimSpace(raw))
+					value, noValue, more, valid = parseValue(rest)
+				}
+				line = &Line{Kind: Entry, Raw: raw, Key: key, Value: value, NoValue: noValue}
+				if !valid || more {
+					line.Kind = Other
+				}
+			}
+			*body = append(*body, line)
+		}
+	}
+	return d
+}
+
+// splitLines splits s after each "\n". The last line has no line ending when
+// s does not end with one.
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// parseHeader parses a trimmed section header: [name], [name "subsection"] or
+// the deprecated [name.subsection]. Text after the closing bracket is allowed,
+// as git reads an entry there, but is not interpreted.
+func parseHeader(s string) (name, sub string, hasSub, ok bool) {
+	s = s[1:]
+	i := 0
+	for i < len(s) && isNameChar(s[i]) {
+		i++
+	}
+	name, s = s[:i], s[i:]
+	if name == "" {
+		return "", "", false, false
+	}
+
+	if strings.HasPrefix(s, "]") {
+		if base, legacy, found := strings.Cut(name, "."); found {
+			return base, strings.ToLower(legacy), true, true
+		}
+		return name, "", false, true
+	}
+
+	s = strings.TrimLeft(s, " \t")
+	if !strings.HasPrefix(s, `"`) {
+		return "", "", false, false
+	}
+	var b strings.Builder
+	for i = 1; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '\\':
+			// git keeps the character after a backslash, whatever it is
+			if i+1 < len(s) {
+				i++
+				b.WriteByte(s[i])
+			}
+		case '"':
+			if strings.HasPrefix(s[i+1:], "]") {
+				return name, b.String(), true, true
+			}
+			return "", "", false, false
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", "", false, false
+}
+
+// isNameChar reports whether c may appear in a section name.
+func isNameChar(c byte) bool {
+	return c == '-' || c == '.' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// parseKey splits a trimmed entry line into its key and the text after it.
+func parseKey(s string) (key, rest string, ok bool) {
+	if s == "" || !isLetter(s[0]) {
+		return "", "", false
+	}
+	i := 1
+	for i < len(s) && (isLetter(s[i]) || s[i] >= '0' && s[i] <= '9' || s[i] == '-') {
+		i++
+	}
+	return s[:i], s[i:], true
+}
+
+// isLetter reports whether c is an ASCII letter.
+func isLetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// parseValue reads what follows a key the way git does: an optional "=" and a
+// value in which double quotes group, backslash escapes \n, \t, \b, \\ and \"
+// are decoded, # and ; outside quotes start a comment and whitespace outside
+// quotes is trimmed at the end. more is set when the value ends in a
+// backslash-newline and continues on the next line; valid is false for text
+// git would reject.
+func parseValue(rest string) (value string, noValue, more, valid bool) {
+	rest = strings.TrimLeft(rest, " \t")
+	if rest == "" || rest[0] == '#' || rest[0] == ';' || rest[0] == '\r' || rest[0] == '\n' {
+		return "", true, false, true
+	}
+	if rest[0] != '=' {
+		return "", false, false, false
+	}
+	rest = strings.TrimLeft(rest[1:], " \t")
+
+	var b strings.Builder
+	quoted := false
+	// keep is the length of the value without trailing unquoted whitespace
+	keep := 0
+	for i := 0; i < len(rest); i++ {
+		c := rest[i]
+		switch {
+		case c == '\n' || c == '\r' && strings.HasPrefix(rest[i:], "\r\n"):
+			if quoted {
+				return "", false, false, false
+			}
+			return b.String()[:keep], false, false, true
+		case c == '\\':
+			if i+1 >= len(rest) {
+				// Continued on a line the caller has not read yet
+				return "", false, true, true
+			}
+			i++
+			switch rest[i] {
+			case '\n':
+				continue
+			case '\r':
+				if strings.HasPrefix(rest[i:], "\r\n") {
+					i++
+					continue
+				}
+				return "", false, false, false
+			case 'n':
+				b.WriteByte('\n')
+			case 't':
+				b.WriteByte('\t')
+			case 'b':
+				b.WriteByte('\b')
+			case '\\', '"':
+				b.WriteByte(rest[i])
+			default:
+				return "", false, false, false
+			}
+			keep = b.Len()
+		case c == '"':
+			quoted = !quoted
+		case !quoted && (c == '#' || c == ';'):
+			return b.String()[:keep], false, false, true
+		case !quoted && (c == ' ' || c == '\t'):
+			b.WriteByte(c)
+		default:
+			b.WriteByte(c)
+			keep = b.Len()
+		}
+	}
+	if quoted {
+		return "", false, false, false
+	}
+	return b.String()[:keep], false, false, true
+}
+
+// quoteValue formats value so git reads it back unchanged.
+func quoteValue(value string) string {
+	needsQuotes := value != strings.TrimSpace(value) || strings.ContainsAny(value, "#;")
+	var b strings.Builder
+	for _, r := range value {
+		switch r {
+		case '\\':
+			b.WriteString(`\\`)
+		case '"':
+			b.WriteString(`\"`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '\b':
+			b.WriteString(`\b`)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	if needsQuotes {
+		return `"` + b.String() + `"`
+	}
+	return b.String()
+}
+
+// quoteSubsection formats a subsection name for a section header.
+func quoteSubsection(sub string) string {
+	sub = strings.ReplaceAll(sub, `\`, `\\`)
+	sub = strings.ReplaceAll(sub, `"`, `\"`)
+	return `"` + sub + `"`
+}
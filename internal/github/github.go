@@ -0,0 +1,157 @@
+// Package github looks up GitHub accounts through the public REST API, for
+// the noreply address GitHub gives every account to use as a commit email.
+package github
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/thuanlegit/git-identitree/internal/utils"
+)
+
+// DefaultBaseURL is the root of GitHub's REST API.
+const DefaultBaseURL = "https://api.github.com"
+
+// NoreplyDomain is the domain of GitHub's noreply addresses.
+const NoreplyDomain = "users.noreply.github.com"
+
+// fetchLimit caps how much of an API response is read.
+const fetchLimit = 1 << 20
+
+var (
+	// ErrInvalidUsername is returned for a name GitHub does not allow.
+	ErrInvalidUsername = errors.New("invalid GitHub username")
+	// ErrUserNotFound is returned when no account has the name.
+	ErrUserNotFound = errors.New("GitHub user not found")
+	// ErrRateLimited is returned when GitHub refuses the request because
+	// too many were made without a token.
+	ErrRateLimited = errors.New("GitHub API rate limit exceeded")
+)
+
+// usernamePattern matches GitHub usernames: up to 39 letters, digits and
+// single hyphens, not starting or ending with a hyphen.
+var usernamePattern = regexp.MustCompile(`^[A-Za-z0-9](?:[A-Za-z0-9]|-[A-Za-z0-9]){0,38}$`)
+
+// defaultHTTP sends requests for a Client without its own.
+var defaultHTTP = &http.Client{Timeout: 10 * time.Second}
+
+// Client looks up GitHub users. The zero value asks api.github.com without
+// a token, which GitHub allows for public profiles at 60 requests an hour.
+type Client struct {
+	// BaseURL is the API root; empty means DefaultBaseURL.
+	BaseURL string
+	// HTTP sends the requests; nil means a client with a 10 second timeout.
+	HTTP *http.Client
+	// Now tells the time, for when a rate limit resets; nil means time.Now.
+	Now func() time.Time
+}
+
+// User is the part of a GitHub account the noreply address is made of.
+type User struct {
+	ID    int64  `json:"id"`
+	Login string `json:"login"`
+	Name  string `json:"name"`
+}
+
+// NoreplyEmail returns the ID+login@users.noreply.github.com address of the
+// user, the form GitHub has used for accounts since July 2017 and accepts
+// for every account.
+func (u User) NoreplyEmail() string {
+	return fmt.Sprintf("%d+%s@%s", u.ID, u.Login, NoreplyDomain)
+}
+
+// User looks up the account called username.
+func (c *Client) User(username string) (*User, error) {
+	username = strings.TrimPrefix(strings.TrimSpace(username), "@")
+	if !usernamePattern.MatchString(username) {
+		return nil, utils.WithDetail(ErrInvalidUsername, "'%s' is not a GitHub username: use letters, digits and single hyphens, at most 39 characters", username)
+	}
+
+	base := c.BaseURL
+	if base == "" {
+		base = DefaultBaseURL
+	}
+	endpoint := strings.TrimSuffix(base, "/") + "/users/" + url.PathEscape(username)
+	req, err := http.NewRequest(http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up GitHub user: %w", err)
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("User-Agent", "gidtree")
+
+	client := c.HTTP
+	if client == nil {
+		client = defaultHTTP
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up GitHub user: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, utils.WithDetail(ErrUserNotFound, "GitHub user '%s' not found", username)
+	case isRateLimited(resp):
+		return nil, c.rateLimitError(resp)
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("failed to look up GitHub user: %s returned %s", endpoint, resp.Status)
+	}
+
+	var u User
+	if err := json.NewDecoder(io.LimitReader(resp.Body, fetchLimit)).Decode(&u); err != nil {
+		return nil, fmt.Errorf("failed to read GitHub user: %w", err)
+	}
+	if u.ID == 0 || u.Login == "" {
+		return nil, fmt.Errorf("failed to read GitHub user: no ID or login")
+	}
+	return &u, nil
+}
+
+// NoreplyEmail looks up the account called username and returns its noreply
+// address.
+func (c *Client) NoreplyEmail(username string) (string, error) {
+	u, err := c.User(username)
+	if err != nil {
+		return "", err
+	}
+	return u.NoreplyEmail(), nil
+}
+
+// isRateLimited reports whether GitHub refused a request for exceeding a
+// rate limit: 429, or 403 with no requests remaining or a Retry-After.
+func isRateLimited(resp *http.Response) bool {
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return true
+	}
+	return resp.StatusCode == http.StatusForbidden &&
+		(resp.Header.Get("X-RateLimit-Remaining") == "0" || resp.Header.Get("Retry-After") != "")
+}
+
+// rateLimitError returns ErrRateLimited saying when to try again, from the
+// Retry-After or X-RateLimit-Reset header.
+func (c *Client) rateLimitError(resp *http.Response) error {
+	now := time.Now
+	if c.Now != nil {
+		now = c.Now
+	}
+	var wait time.Duration
+	if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+		wait = time.Duration(seconds) * time.Second
+	} else if reset, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+		wait = time.Unix(reset, 0).Sub(now())
+	}
+	if wait <= 0 {
+		return utils.WithDetail(ErrRateLimited, "GitHub API rate limit exceeded; unauthenticated lookups are limited to 60 an hour, try again later")
+	}
+	return utils.WithDetail(ErrRateLimited, "GitHub API rate limit exceeded; unauthenticated lookups are limited to 60 an hour, try again in %d minute(s)", int(math.Ceil(wait.Minutes())))
+}
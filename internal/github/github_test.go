@@ -0,0 +1,119 @@
+package github
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeAPI serves handler as the GitHub API and returns a client for it.
+func fakeAPI(t *testing.T, handler http.HandlerFunc) *Client {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	return &Client{BaseURL: server.URL, HTTP: server.Client()}
+}
+
+func TestClient_NoreplyEmail(t *testing.T) {
+	var path, auth, agent string
+	client := fakeAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		path, auth, agent = r.URL.Path, r.Header.Get("Authorization"), r.Header.Get("User-Agent")
+		_, _ = w.Write([]byte(`{"login":"Octocat","id":583231,"name":"The Octocat","type":"User"}`))
+	})
+
+	email, err := client.NoreplyEmail("@octocat")
+	if err != nil {
+		t.Fatalf("NoreplyEmail() error = %v", err)
+	}
+	if email != "583231+Octocat@users.noreply.github.com" {
+		t.Errorf("NoreplyEmail() = %q, want the ID and the login as GitHub spells it", email)
+	}
+	if path != "/users/octocat" {
+		t.Errorf("requested %q, want /users/octocat", path)
+	}
+	if auth != "" || agent == "" {
+		t.Errorf("Authorization = %q, User-Agent = %q; want no token and a user agent", auth, agent)
+	}
+}
+
+func TestClient_User_Errors(t *testing.T) {
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		wantErr error
+		wantMsg string
+	}{
+		{
+			name:    "not found",
+			handler: func(w http.ResponseWriter, r *http.Request) { http.NotFound(w, r) },
+			wantErr: ErrUserNotFound,
+			wantMsg: "GitHub user 'octocat' not found",
+		},
+		{
+			name: "rate limited",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("X-RateLimit-Remaining", "0")
+				w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(now.Add(12*time.Minute+10*time.Second).Unix(), 10))
+				w.WriteHeader(http.StatusForbidden)
+			},
+			wantErr: ErrRateLimited,
+			wantMsg: "try again in 13 minute(s)",
+		},
+		{
+			name: "secondary rate limit",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Retry-After", "60")
+				w.WriteHeader(http.StatusTooManyRequests)
+			},
+			wantErr: ErrRateLimited,
+			wantMsg: "try again in 1 minute(s)",
+		},
+		{
+			name:    "server error",
+			handler: func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusBadGateway) },
+			wantMsg: "502 Bad Gateway",
+		},
+		{
+			name:    "forbidden without a rate limit",
+			handler: func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusForbidden) },
+			wantMsg: "403 Forbidden",
+		},
+		{
+			name:    "not a user",
+			handler: func(w http.ResponseWriter, r *http.Request) { _, _ = w.Write([]byte(`{"message":"hello"}`)) },
+			wantMsg: "no ID or login",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := fakeAPI(t, tt.handler)
+			client.Now = func() time.Time { return now }
+			_, err := client.User("octocat")
+			if err == nil {
+				t.Fatal("User() error = nil")
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("User() error = %v, want %v", err, tt.wantErr)
+			}
+			if !strings.Contains(err.Error(), tt.wantMsg) {
+				t.Errorf("User() error = %q, want it to mention %q", err, tt.wantMsg)
+			}
+		})
+	}
+}
+
+func TestClient_User_InvalidUsername(t *testing.T) {
+	client := fakeAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("requested %s for an invalid username", r.URL.Path)
+	})
+	for _, name := range []string{"", "-octocat", "octo--cat", "octo/cat", "../orgs/x", strings.Repeat("a", 40)} {
+		if _, err := client.User(name); !errors.Is(err, ErrInvalidUsername) {
+			t.Errorf("User(%q) error = %v, want ErrInvalidUsername", name, err)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package guard
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/thuanlegit/git-identitree/internal/profile"
+)
+
+// coAuthorTrailer is the trailer GitHub reads co-authors from.
+const coAuthorTrailer = "Co-authored-by"
+
+// scissorsLine is the line git puts above the diff in a verbose commit
+// message. It and everything below it are dropped from the commit.
+const scissorsLine = "# ------------------------ >8 ------------------------"
+
+var (
+	// trailerLine matches a "Token: value" trailer line.
+	trailerLine = regexp.MustCompile(`^[A-Za-z0-9-]+:\s`)
+	// coAuthorLine matches a Co-authored-by trailer, in any case.
+	coAuthorLine = regexp.MustCompile(`(?i)^co-authored-by:\s*(.*)$`)
+)
+
+// SkipCoAuthors reports whether the co-authors are left out of a message with
+// the given prepare-commit-msg source. Merge and squash messages describe
+// other commits, which carry their own trailers.
+func SkipCoAuthors(source string) bool {
+	return source == "merge" || source == "squash"
+}
+
+// AddCoAuthors appends a Co-authored-by trailer for every "Name <email>" entry
+// in coAuthors whose email the message does not already credit, ignoring
+// case. The trailers join an existing trailer block, or start one after a
+// blank line, and go above git's trailing comments and scissors line. The
+// message is returned as it is when nothing needs adding.
+func AddCoAuthors(message string, coAuthors []string) string {
+	lines := strings.Split(message, "\n")
+	end := len(lines)
+	for i, line := range lines {
+		if line == scissorsLine {
+			end = i
+			break
+		}
+	}
+	// Trailing comments and blank lines stay below the trailers
+	cut := end
+	for cut > 0 && (strings.TrimSpace(lines[cut-1]) == "" || strings.HasPrefix(lines[cut-1], "#")) {
+		cut--
+	}
+	body, rest := lines[:cut], lines[cut:]
+
+	credited := make(map[string]bool)
+	for _, line := range body {
+		if m := coAuthorLine.FindStringSubmatch(line); m != nil {
+			if _, email, err := profile.ParseCoAuthor(m[1]); err == nil {
+				credited[strings.ToLower(email)] = true
+			}
+		}
+	}
+	var trailers []string
+	for _, entry := range coAuthors {
+		name, email, err := profile.ParseCoAuthor(entry)
+		if err != nil || credited[strings.ToLower(email)] {
+			continue
+		}
+		credited[strings.ToLower(email)] = true
+		trailers = append(trailers, coAuthorTrailer+": "+profile.FormatCoAuthor(name, email))
+	}
+	if len(trailers) == 0 {
+		return message
+	}
+
+	var b strings.Builder
+	b.WriteString(strings.Join(body, "\n"))
+	if hasTrailerBlock(body) {
+		b.WriteString("\n")
+	} else {
+		// An empty message keeps its first line free for the subject
+		b.WriteString("\n\n")
+	}
+	for _, trailer := range trailers {
+		b.WriteString(trailer + "\n")
+	}
+	b.WriteString(strings.Join(rest, "\n"))
+	return b.String()
+}
+
+// hasTrailerBlock reports whether the last paragraph of body, other than the
+// subject, consists of trailers.
+func hasTrailerBlock(body []string) bool {
+	start := len(body)
+	for start > 0 && strings.TrimSpace(body[start-1]) != "" {
+		start--
+	}
+	if start == 0 || start == len(body) {
+		return false
+	}
+	for _, line := range body[start:] {
+		// Indented lines continue the trailer above
+		if !trailerLine.MatchString(line) && !strings.HasPrefix(line, " ") && !strings.HasPrefix(line, "\t") {
+			return false
+		}
+	}
+	return true
+}
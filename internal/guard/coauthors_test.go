@@ -0,0 +1,96 @@
+package guard
+
+import "testing"
+
+func TestAddCoAuthors(t *testing.T) {
+	ada := "Ada Lovelace <ada@example.com>"
+	alan := "Alan Turing <alan@example.com>"
+	tests := []struct {
+		name      string
+		message   string
+		coAuthors []string
+		want      string
+	}{
+		{
+			name:      "message from -m",
+			message:   "Fix parser\n",
+			coAuthors: []string{ada},
+			want:      "Fix parser\n\nCo-authored-by: Ada Lovelace <ada@example.com>\n",
+		},
+		{
+			name:      "no trailing newline",
+			message:   "Fix parser",
+			coAuthors: []string{ada, alan},
+			want:      "Fix parser\n\nCo-authored-by: Ada Lovelace <ada@example.com>\nCo-authored-by: Alan Turing <alan@example.com>\n",
+		},
+		{
+			name:      "above git's comments",
+			message:   "Fix parser\n\n# Please enter the commit message for your changes.\n#\n# On branch main\n",
+			coAuthors: []string{ada},
+			want:      "Fix parser\n\nCo-authored-by: Ada Lovelace <ada@example.com>\n\n# Please enter the commit message for your changes.\n#\n# On branch main\n",
+		},
+		{
+			name:      "empty message keeps the subject line free",
+			message:   "\n# Please enter the commit message for your changes.\n",
+			coAuthors: []string{ada},
+			want:      "\n\nCo-authored-by: Ada Lovelace <ada@example.com>\n\n# Please enter the commit message for your changes.\n",
+		},
+		{
+			name:      "joins an existing trailer block",
+			message:   "Fix parser\n\nThe body.\n\nSigned-off-by: Jane <jane@example.com>\n",
+			coAuthors: []string{ada},
+			want:      "Fix parser\n\nThe body.\n\nSigned-off-by: Jane <jane@example.com>\nCo-authored-by: Ada Lovelace <ada@example.com>\n",
+		},
+		{
+			name:      "a subject with a colon is not a trailer block",
+			message:   "docs: fix typo\n",
+			coAuthors: []string{ada},
+			want:      "docs: fix typo\n\nCo-authored-by: Ada Lovelace <ada@example.com>\n",
+		},
+		{
+			name:      "existing trailer in another case",
+			message:   "Fix parser\n\nco-authored-by: Ada L. <ADA@example.com>\n",
+			coAuthors: []string{ada, alan},
+			want:      "Fix parser\n\nco-authored-by: Ada L. <ADA@example.com>\nCo-authored-by: Alan Turing <alan@example.com>\n",
+		},
+		{
+			name:      "everything credited already",
+			message:   "Fix parser\n\nCo-authored-by: Ada Lovelace <ada@example.com>\n",
+			coAuthors: []string{ada},
+			want:      "Fix parser\n\nCo-authored-by: Ada Lovelace <ada@example.com>\n",
+		},
+		{
+			name:      "duplicate co-authors",
+			message:   "Fix parser\n",
+			coAuthors: []string{ada, "Ada <Ada@Example.com>"},
+			want:      "Fix parser\n\nCo-authored-by: Ada Lovelace <ada@example.com>\n",
+		},
+		{
+			name:      "above the scissors line",
+			message:   "Fix parser\n\n# ------------------------ >8 ------------------------\n# Do not modify or remove the line above.\ndiff --git a/x b/x\n",
+			coAuthors: []string{ada},
+			want:      "Fix parser\n\nCo-authored-by: Ada Lovelace <ada@example.com>\n\n# ------------------------ >8 ------------------------\n# Do not modify or remove the line above.\ndiff --git a/x b/x\n",
+		},
+		{
+			name:      "invalid entries are skipped",
+			message:   "Fix parser\n",
+			coAuthors: []string{"nobody"},
+			want:      "Fix parser\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := AddCoAuthors(tt.message, tt.coAuthors); got != tt.want {
+				t.Errorf("AddCoAuthors() =\n%q\nwant\n%q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSkipCoAuthors(t *testing.T) {
+	for source, want := range map[string]bool{"": false, "message": false, "template": false, "commit": false, "merge": true, "squash": true} {
+		if got := SkipCoAuthors(source); got != want {
+			t.Errorf("SkipCoAuthors(%q) = %v, want %v", source, got, want)
+		}
+	}
+}
@@ -0,0 +1,392 @@
+package guard
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/thuanlegit/git-identitree/internal/logging"
+	"github.com/thuanlegit/git-identitree/internal/mapping"
+	"github.com/thuanlegit/git-identitree/internal/profile"
+	"github.com/thuanlegit/git-identitree/internal/utils"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	stateFile = "guard.yaml"
+	hooksDir  = "hooks"
+
+	// BypassEnvVar lets a commit through the strict guard in an emergency.
+	// Its value is recorded in the audit log as the reason.
+	BypassEnvVar = "GIDTREE_GUARD_BYPASS"
+
+	// marker identifies hook scripts written by gidtree.
+	marker = "# Managed by gidtree guard."
+)
+
+// HookNames are the client-side hooks installed into the global hooks directory.
+// core.hooksPath replaces .git/hooks entirely, so every hook a repository might
+// use needs a chaining script, not just pre-commit.
+var HookNames = []string{
+	"applypatch-msg",
+	"pre-applypatch",
+	"post-applypatch",
+	"pre-commit",
+	"pre-merge-commit",
+	"prepare-commit-msg",
+	"commit-msg",
+	"post-commit",
+	"pre-rebase",
+	"post-checkout",
+	"post-merge",
+	"pre-push",
+	"pre-auto-gc",
+	"post-rewrite",
+}
+
+// State records what guard install changed so uninstall can restore it.
+type State struct {
+	Strict            bool   `yaml:"strict"`
+	HooksPath         string `yaml:"hooks_path,omitempty"`
+	PreviousHooksPath string `yaml:"previous_hooks_path,omitempty"`
+	SetUseConfigOnly  bool   `yaml:"set_use_config_only,omitempty"`
+}
+
+// GetStatePath returns the path to ~/.gidtree/guard.yaml.
+func GetStatePath() (string, error) {
+	profilesDir, err := profile.GetProfilesDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(profilesDir, stateFile), nil
+}
+
+// GetHooksDir returns the directory holding the global hook scripts.
+func GetHooksDir() (string, error) {
+	profilesDir, err := profile.GetProfilesDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(profilesDir, hooksDir), nil
+}
+
+// LoadState returns the installed guard state, or nil when the guard is not installed.
+func LoadState() (*State, error) {
+	statePath, err := GetStatePath()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(statePath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read guard state: %w", err)
+	}
+
+	var state State
+	if err := yaml.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("failed to parse guard state: %w", err)
+	}
+	return &state, nil
+}
+
+func saveState(state *State) error {
+	statePath, err := GetStatePath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(statePath), utils.PrivateDirMode); err != nil {
+		return fmt.Errorf("failed to create guard state directory: %w", err)
+	}
+	data, err := yaml.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("failed to marshal guard state: %w", err)
+	}
+	if err := os.WriteFile(statePath, data, utils.PrivateFileMode); err != nil {
+		return fmt.Errorf("failed to write guard state: %w", err)
+	}
+	return nil
+}
+
+// Install enables user.useConfigOnly so git never guesses an identity.
+// In strict mode it also points core.hooksPath at gidtree's hook scripts, which
+// run `<binary> guard run-hook <name>` and chain to the repository's own hooks.
+// Installing again switches between modes without losing the original settings.
+func Install(binary string, strict bool) error {
+	state, err := LoadState()
+	if err != nil {
+		return err
+	}
+	if state == nil {
+		state = &State{}
+		current, err := globalConfigGet("user.useConfigOnly")
+		if err != nil {
+			return err
+		}
+		if current != "true" {
+			if err := globalConfigSet("user.useConfigOnly", "true"); err != nil {
+				return err
+			}
+			state.SetUseConfigOnly = true
+		}
+	}
+
+	hooks, err := GetHooksDir()
+	if err != nil {
+		return err
+	}
+
+	if strict && !state.Strict {
+		previous, err := globalConfigGet("core.hooksPath")
+		if err != nil {
+			return err
+		}
+		if previous != filepath.ToSlash(hooks) {
+			state.PreviousHooksPath = previous
+		}
+	}
+	if strict {
+		if err := writeHooks(hooks, binary); err != nil {
+			return err
+		}
+		if err := globalConfigSet("core.hooksPath", filepath.ToSlash(hooks)); err != nil {
+			return err
+		}
+		state.HooksPath = filepath.ToSlash(hooks)
+	} else if state.Strict {
+		if err := restoreHooksPath(state); err != nil {
+			return err
+		}
+	}
+	state.Strict = strict
+
+	return saveState(state)
+}
+
+// Uninstall reverts everything Install changed and removes the hook scripts.
+func Uninstall() error {
+	state, err := LoadState()
+	if err != nil {
+		return err
+	}
+	if state == nil {
+		return errors.New("guard is not installed")
+	}
+
+	if state.Strict {
+		if err := restoreHooksPath(state); err != nil {
+			return err
+		}
+	}
+	if state.SetUseConfigOnly {
+		if err := globalConfigUnset("user.useConfigOnly"); err != nil {
+			return err
+		}
+	}
+
+	statePath, err := GetStatePath()
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(statePath); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove guard state: %w", err)
+	}
+	return nil
+}
+
+// restoreHooksPath puts back the core.hooksPath that was set before strict mode
+// and removes gidtree's hook scripts.
+func restoreHooksPath(state *State) error {
+	current, err := globalConfigGet("core.hooksPath")
+	if err != nil {
+		return err
+	}
+	// Leave a hooks path the user changed after installing alone
+	if current == state.HooksPath {
+		if state.PreviousHooksPath != "" {
+			err = globalConfigSet("core.hooksPath", state.PreviousHooksPath)
+		} else {
+			err = globalConfigUnset("core.hooksPath")
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	hooks, err := GetHooksDir()
+	if err != nil {
+		return err
+	}
+	if err := os.RemoveAll(hooks); err != nil {
+		return fmt.Errorf("failed to remove hook scripts: %w", err)
+	}
+	state.HooksPath = ""
+	state.PreviousHooksPath = ""
+	return nil
+}
+
+// writeHooks writes one forwarding script per hook name.
+func writeHooks(dir, binary string) error {
+	if err := os.MkdirAll(dir, utils.PrivateDirMode); err != nil {
+		return fmt.Errorf("failed to create hooks directory: %w", err)
+	}
+	for _, name := range HookNames {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(hookScript(binary, name)), utils.PrivateExecMode); err != nil {
+			return fmt.Errorf("failed to write %s hook: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// hookScript returns the shell script installed for a hook.
+func hookScript(binary, name string) string {
+	return fmt.Sprintf("#!/bin/sh\n%s Remove with: gidtree guard uninstall\nexec %s guard run-hook %s \"$@\"\n",
+		marker, utils.ShellQuote(filepath.ToSlash(binary)), name)
+}
+
+// IsManagedHook reports whether the file at path was written by gidtree.
+func IsManagedHook(path string) bool {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false
+	}
+	return strings.Contains(string(data), marker)
+}
+
+// ChainTargets returns the hooks to run after gidtree's own check: the hook from
+// the core.hooksPath that was configured before strict mode, then the
+// repository's .git/hooks entry. Missing, non-executable and gidtree-managed
+// scripts are skipped.
+func ChainTargets(name, gitCommonDir string, state *State) []string {
+	var candidates []string
+	if state != nil && state.PreviousHooksPath != "" {
+		previous := state.PreviousHooksPath
+		if !filepath.IsAbs(previous) {
+			// A relative core.hooksPath is relative to the repository root
+			previous = filepath.Join(filepath.Dir(gitCommonDir), previous)
+		}
+		candidates = append(candidates, filepath.Join(previous, name))
+	}
+	candidates = append(candidates, filepath.Join(gitCommonDir, "hooks", name))
+
+	var targets []string
+	seen := make(map[string]bool)
+	for _, c := range candidates {
+		if seen[c] || !isExecutable(c) || IsManagedHook(c) {
+			continue
+		}
+		seen[c] = true
+		targets = append(targets, c)
+	}
+	return targets
+}
+
+// InheritedIdentity reports whether a commit is replaying existing history, as
+// in a rebase, cherry-pick, revert or amend. Such commits inherit their identity
+// and must not be blocked.
+//
+// git exports GIT_AUTHOR_DATE to every pre-commit hook, so its presence alone
+// means nothing; a date noticeably older than now does, because fresh commits
+// are stamped with the current time.
+func InheritedIdentity(gitDir string, getenv func(string) string, now time.Time) bool {
+	for _, name := range []string{"rebase-merge", "rebase-apply", "CHERRY_PICK_HEAD", "REVERT_HEAD", "sequencer"} {
+		if _, err := os.Stat(filepath.Join(gitDir, name)); err == nil {
+			return true
+		}
+	}
+	if authored, ok := parseGitDate(getenv("GIT_AUTHOR_DATE")); ok {
+		return now.Sub(authored) > inheritedDateSlack
+	}
+	return false
+}
+
+// inheritedDateSlack absorbs the time between git stamping a fresh commit and
+// the hook running.
+const inheritedDateSlack = time.Minute
+
+// parseGitDate parses the date formats git accepts for GIT_AUTHOR_DATE:
+// its internal "@<unix> <tz>" form, plus RFC 2822 and ISO 8601.
+func parseGitDate(value string) (time.Time, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}, false
+	}
+
+	fields := strings.Fields(strings.TrimPrefix(value, "@"))
+	if len(fields) == 0 {
+		return time.Time{}, false
+	}
+	if secs, err := strconv.ParseInt(fields[0], 10, 64); err == nil {
+		return time.Unix(secs, 0), true
+	}
+
+	for _, layout := range []string{time.RFC1123Z, time.RFC3339, "2006-01-02 15:04:05 -0700", "2006-01-02T15:04:05"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+func isExecutable(path string) bool {
+	info, err := os.Stat(path)
+	if err != nil || info.IsDir() {
+		return false
+	}
+	return info.Mode()&0111 != 0
+}
+
+// globalConfigGet reads a key from the global git config gidtree manages.
+// A missing key yields an empty string.
+func globalConfigGet(key string) (string, error) {
+	out, err := gitGlobalConfig("--get", key)
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(out), nil
+}
+
+func globalConfigSet(key, value string) error {
+	_, err := gitGlobalConfig(key, value)
+	return err
+}
+
+func globalConfigUnset(key string) error {
+	_, err := gitGlobalConfig("--unset", key)
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 5 {
+		// Key was already absent
+		return nil
+	}
+	return err
+}
+
+// gitGlobalConfig runs git config against ~/.gitconfig.
+func gitGlobalConfig(args ...string) (string, error) {
+	configPath, err := mapping.GetGitConfigPath()
+	if err != nil {
+		return "", err
+	}
+	cmd := utils.Command("git", append([]string{"config", "--file", configPath}, args...)...)
+	out, err := cmd.Output()
+	logging.Command(cmd, err)
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return "", exitErr
+		}
+		return "", fmt.Errorf("failed to run git config: %w", err)
+	}
+	return string(out), nil
+}
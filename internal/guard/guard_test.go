@@ -0,0 +1,223 @@
+package guard
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/thuanlegit/git-identitree/internal/utils"
+)
+
+func setupGuardTestEnv(t *testing.T) string {
+	t.Helper()
+
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+
+	tmpDir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to resolve temp directory: %v", err)
+	}
+
+	// Override home directory for testing on all platforms
+	t.Setenv(utils.RootEnv, tmpDir)
+	t.Setenv("XDG_CONFIG_HOME", "")
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+
+	return tmpDir
+}
+
+func readConfig(t *testing.T, key string) string {
+	t.Helper()
+	value, err := globalConfigGet(key)
+	if err != nil {
+		t.Fatalf("globalConfigGet(%s) error = %v", key, err)
+	}
+	return value
+}
+
+func TestInstallUninstall(t *testing.T) {
+	tmpDir := setupGuardTestEnv(t)
+
+	if err := Install("/usr/local/bin/gidtree", false); err != nil {
+		t.Fatalf("Install() error = %v", err)
+	}
+	if got := readConfig(t, "user.useConfigOnly"); got != "true" {
+		t.Errorf("user.useConfigOnly = %q, want true", got)
+	}
+	if got := readConfig(t, "core.hooksPath"); got != "" {
+		t.Errorf("core.hooksPath = %q, want unset without --strict", got)
+	}
+
+	// Upgrading to strict installs the hooks
+	if err := Install("/usr/local/bin/gidtree", true); err != nil {
+		t.Fatalf("Install(strict) error = %v", err)
+	}
+	hooks, _ := GetHooksDir()
+	if got := readConfig(t, "core.hooksPath"); got != filepath.ToSlash(hooks) {
+		t.Errorf("core.hooksPath = %q, want %q", got, hooks)
+	}
+	for _, name := range HookNames {
+		path := filepath.Join(hooks, name)
+		if !IsManagedHook(path) {
+			t.Errorf("hook %s not installed", name)
+		}
+	}
+	script, _ := os.ReadFile(filepath.Join(hooks, "pre-commit"))
+	if !strings.Contains(string(script), "'/usr/local/bin/gidtree' guard run-hook pre-commit") {
+		t.Errorf("pre-commit script = %q", script)
+	}
+
+	if err := Uninstall(); err != nil {
+		t.Fatalf("Uninstall() error = %v", err)
+	}
+	if got := readConfig(t, "user.useConfigOnly"); got != "" {
+		t.Errorf("user.useConfigOnly = %q after uninstall, want unset", got)
+	}
+	if got := readConfig(t, "core.hooksPath"); got != "" {
+		t.Errorf("core.hooksPath = %q after uninstall, want unset", got)
+	}
+	if _, err := os.Stat(hooks); !os.IsNotExist(err) {
+		t.Error("hooks directory should be removed")
+	}
+	if state, _ := LoadState(); state != nil {
+		t.Error("guard state should be removed")
+	}
+	if err := Uninstall(); err == nil {
+		t.Error("Uninstall() should fail when not installed")
+	}
+
+	// The user's own settings were never touched beyond what we changed
+	data, _ := os.ReadFile(filepath.Join(tmpDir, ".gitconfig"))
+	if strings.Contains(string(data), "hooksPath") || strings.Contains(string(data), "useConfigOnly") {
+		t.Errorf("gitconfig still has guard settings:\n%s", data)
+	}
+}
+
+func TestInstallRestoresPreviousSettings(t *testing.T) {
+	setupGuardTestEnv(t)
+
+	if err := globalConfigSet("core.hooksPath", "~/my-hooks"); err != nil {
+		t.Fatalf("globalConfigSet() error = %v", err)
+	}
+	if err := globalConfigSet("user.useConfigOnly", "true"); err != nil {
+		t.Fatalf("globalConfigSet() error = %v", err)
+	}
+
+	if err := Install("gidtree", true); err != nil {
+		t.Fatalf("Install() error = %v", err)
+	}
+	state, _ := LoadState()
+	if state.PreviousHooksPath != "~/my-hooks" {
+		t.Errorf("PreviousHooksPath = %q, want ~/my-hooks", state.PreviousHooksPath)
+	}
+	if state.SetUseConfigOnly {
+		t.Error("SetUseConfigOnly should be false when it was already set")
+	}
+
+	// Reinstalling must not record our own hooks directory as the previous one
+	if err := Install("gidtree", true); err != nil {
+		t.Fatalf("Install() again error = %v", err)
+	}
+	if state, _ := LoadState(); state.PreviousHooksPath != "~/my-hooks" {
+		t.Errorf("PreviousHooksPath after reinstall = %q, want ~/my-hooks", state.PreviousHooksPath)
+	}
+
+	if err := Uninstall(); err != nil {
+		t.Fatalf("Uninstall() error = %v", err)
+	}
+	if got := readConfig(t, "core.hooksPath"); got != "~/my-hooks" {
+		t.Errorf("core.hooksPath = %q, want restored ~/my-hooks", got)
+	}
+	if got := readConfig(t, "user.useConfigOnly"); got != "true" {
+		t.Errorf("user.useConfigOnly = %q, want the user's own setting kept", got)
+	}
+}
+
+func TestChainTargets(t *testing.T) {
+	tmpDir := t.TempDir()
+	gitDir := filepath.Join(tmpDir, "repo", ".git")
+	previous := filepath.Join(tmpDir, "previous")
+	for _, dir := range []string{filepath.Join(gitDir, "hooks"), previous} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+	}
+
+	write := func(path, content string, mode os.FileMode) {
+		if err := os.WriteFile(path, []byte(content), mode); err != nil {
+			t.Fatalf("Failed to write %s: %v", path, err)
+		}
+	}
+	write(filepath.Join(gitDir, "hooks", "pre-commit"), "#!/bin/sh\n", 0755)
+	write(filepath.Join(gitDir, "hooks", "commit-msg"), "#!/bin/sh\n", 0644) // not executable
+	write(filepath.Join(gitDir, "hooks", "pre-push"), hookScript("gidtree", "pre-push"), 0755)
+	write(filepath.Join(previous, "pre-commit"), "#!/bin/sh\n", 0755)
+
+	state := &State{Strict: true, PreviousHooksPath: previous}
+
+	got := ChainTargets("pre-commit", gitDir, state)
+	want := []string{filepath.Join(previous, "pre-commit"), filepath.Join(gitDir, "hooks", "pre-commit")}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("ChainTargets(pre-commit) = %v, want %v", got, want)
+	}
+	if got := ChainTargets("commit-msg", gitDir, state); len(got) != 0 {
+		t.Errorf("ChainTargets(commit-msg) = %v, want non-executable hook skipped", got)
+	}
+	if got := ChainTargets("pre-push", gitDir, state); len(got) != 0 {
+		t.Errorf("ChainTargets(pre-push) = %v, want managed hook skipped", got)
+	}
+	if got := ChainTargets("pre-commit", gitDir, nil); len(got) != 1 {
+		t.Errorf("ChainTargets() without state = %v, want repository hook only", got)
+	}
+}
+
+func TestInheritedIdentity(t *testing.T) {
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	authorDate := func(value string) func(string) string {
+		return func(k string) string {
+			if k == "GIT_AUTHOR_DATE" {
+				return value
+			}
+			return ""
+		}
+	}
+	noEnv := authorDate("")
+
+	tests := []struct {
+		name   string
+		marker string
+		env    func(string) string
+		want   bool
+	}{
+		{name: "no author date", env: noEnv, want: false},
+		{name: "fresh commit stamped by git", env: authorDate(fmt.Sprintf("@%d +0000", now.Unix()-2)), want: false},
+		{name: "interactive rebase", marker: "rebase-merge", env: noEnv, want: true},
+		{name: "am-style rebase", marker: "rebase-apply", env: noEnv, want: true},
+		{name: "cherry-pick", marker: "CHERRY_PICK_HEAD", env: noEnv, want: true},
+		{name: "revert", marker: "REVERT_HEAD", env: noEnv, want: true},
+		{name: "replayed author date", env: authorDate("@1700000000 +0000"), want: true},
+		{name: "ISO author date", env: authorDate("2024-01-02T03:04:05Z"), want: true},
+		{name: "unparseable author date", env: authorDate("yesterday"), want: false},
+		{name: "bare @ author date", env: authorDate("@"), want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gitDir := t.TempDir()
+			if tt.marker != "" {
+				if err := os.WriteFile(filepath.Join(gitDir, tt.marker), nil, 0644); err != nil {
+					t.Fatalf("Failed to write marker: %v", err)
+				}
+			}
+			if got := InheritedIdentity(gitDir, tt.env, now); got != tt.want {
+				t.Errorf("InheritedIdentity() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,269 @@
+package guard
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/thuanlegit/git-identitree/internal/logging"
+	"github.com/thuanlegit/git-identitree/internal/utils"
+)
+
+// Markers delimiting the sections gidtree owns inside a repository's hooks.
+// Everything outside them belongs to the user or another tool.
+const (
+	repoHookBegin      = "# >>> gidtree check-identity >>>"
+	repoHookEnd        = "# <<< gidtree check-identity <<<"
+	coAuthorsHookBegin = "# >>> gidtree co-authors >>>"
+	coAuthorsHookEnd   = "# <<< gidtree co-authors <<<"
+)
+
+// ErrForeignHook is returned when an existing hook is not a shell script, so
+// gidtree's section cannot be chained into it.
+var ErrForeignHook = errors.New("existing hook is not a shell script")
+
+// RepoHookPath returns the pre-commit hook of the repository containing dir.
+// Linked worktrees share the hooks of their main repository.
+func RepoHookPath(dir string) (string, error) {
+	return repoHookPath(dir, "pre-commit")
+}
+
+// repoHookPath returns the hook called name of the repository containing dir,
+// where git runs it: under core.hooksPath when a hook manager sets one. Strict
+// guard points core.hooksPath at gidtree's own scripts, which run the
+// repository's .git/hooks after their check, so hooks go there instead.
+func repoHookPath(dir, name string) (string, error) {
+	path, err := gitPath(dir, "--git-path", "hooks/"+name)
+	if err != nil {
+		return "", err
+	}
+	if guardHooks, err := GetHooksDir(); err == nil && sameDir(filepath.Dir(path), guardHooks) {
+		commonDir, err := gitPath(dir, "--git-common-dir")
+		if err != nil {
+			return "", err
+		}
+		return filepath.Join(commonDir, "hooks", name), nil
+	}
+	return path, nil
+}
+
+// gitPath runs `git rev-parse <args>` in dir and returns the path it prints,
+// made absolute.
+func gitPath(dir string, args ...string) (string, error) {
+	cmd := utils.Command("git", append([]string{"-C", dir, "rev-parse"}, args...)...)
+	out, err := cmd.Output()
+	logging.Command(cmd, err)
+	if err != nil {
+		return "", fmt.Errorf("'%s' is not a git repository", dir)
+	}
+	path := strings.TrimSpace(string(out))
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
+	}
+	return path, nil
+}
+
+// sameDir reports whether a and b name the same directory.
+func sameDir(a, b string) bool {
+	if resolved, err := filepath.EvalSymlinks(a); err == nil {
+		a = resolved
+	}
+	if resolved, err := filepath.EvalSymlinks(b); err == nil {
+		b = resolved
+	}
+	return filepath.Clean(a) == filepath.Clean(b)
+}
+
+// InstallRepoHook adds a section running `<binary> check-identity --quiet` to
+// the pre-commit hook of the repository containing dir. A missing hook is
+// created; an existing shell hook keeps its content and runs after the check.
+// Installing again refreshes the section in place. It returns the hook path.
+func InstallRepoHook(dir, binary string) (string, error) {
+	command := utils.ShellQuote(filepath.ToSlash(binary)) + " check-identity --quiet"
+	return installHookSection(dir, "pre-commit", repoHookBegin, repoHookEnd, command)
+}
+
+// InstallCoAuthorsHook adds a section to the prepare-commit-msg hook of the
+// repository containing dir that appends the mapped profile's co-authors to
+// the commit message, as InstallRepoHook does for the pre-commit hook.
+func InstallCoAuthorsHook(dir, binary string) (string, error) {
+	command := utils.ShellQuote(filepath.ToSlash(binary)) + ` internal prepare-commit-msg "$@"`
+	return installHookSection(dir, "prepare-commit-msg", coAuthorsHookBegin, coAuthorsHookEnd, command)
+}
+
+// installHookSection adds a section running command, between begin and end,
+// to the hook called name of the repository containing dir.
+func installHookSection(dir, name, begin, end, command string) (string, error) {
+	path, err := repoHookPath(dir, name)
+	if err != nil {
+		return "", err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return "", fmt.Errorf("failed to read %s hook: %w", name, err)
+	}
+	content, _ := removeHookSection(string(data), begin, end)
+	if content != "" && !isShellScript(content) {
+		return "", utils.WithDetail(ErrForeignHook, "%s hook %s is not a shell script; add '%s' to it yourself", name, path, command)
+	}
+
+	section := fmt.Sprintf("%s\n# Managed by gidtree. Remove with: gidtree hooks uninstall\n%s || exit 1\n%s\n", begin, command, end)
+	content = insertRepoHookSection(content, section)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", fmt.Errorf("failed to create hooks directory: %w", err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0755); err != nil {
+		return "", fmt.Errorf("failed to write %s hook: %w", name, err)
+	}
+	// WriteFile keeps the mode of an existing file
+	if err := os.Chmod(path, 0755); err != nil {
+		return "", fmt.Errorf("failed to make %s hook executable: %w", name, err)
+	}
+	return path, nil
+}
+
+// UninstallRepoHook removes gidtree's section from the pre-commit hook of the
+// repository containing dir, leaving the rest of the hook untouched. A hook
+// left with nothing but its shebang is deleted. It reports whether a section
+// was found.
+func UninstallRepoHook(dir string) (bool, error) {
+	return uninstallHookSection(dir, "pre-commit", repoHookBegin, repoHookEnd)
+}
+
+// UninstallCoAuthorsHook removes gidtree's section from the prepare-commit-msg
+// hook of the repository containing dir, as UninstallRepoHook does.
+func UninstallCoAuthorsHook(dir string) (bool, error) {
+	return uninstallHookSection(dir, "prepare-commit-msg", coAuthorsHookBegin, coAuthorsHookEnd)
+}
+
+// uninstallHookSection removes the section between begin and end from the
+// hook called name of the repository containing dir.
+func uninstallHookSection(dir, name, begin, end string) (bool, error) {
+	path, err := repoHookPath(dir, name)
+	if err != nil {
+		return false, err
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to read %s hook: %w", name, err)
+	}
+	content, found := removeHookSection(string(data), begin, end)
+	if !found {
+		return false, nil
+	}
+
+	if isEmptyScript(content) {
+		if err := os.Remove(path); err != nil {
+			return false, fmt.Errorf("failed to remove %s hook: %w", name, err)
+		}
+		return true, nil
+	}
+	if err := os.WriteFile(path, []byte(content), 0755); err != nil {
+		return false, fmt.Errorf("failed to write %s hook: %w", name, err)
+	}
+	return true, nil
+}
+
+// HasRepoHook reports whether the pre-commit hook of the repository containing
+// dir has gidtree's section.
+func HasRepoHook(dir string) bool {
+	return hasHookSection(dir, "pre-commit", repoHookBegin, repoHookEnd)
+}
+
+// HasCoAuthorsHook reports whether the prepare-commit-msg hook of the
+// repository containing dir has gidtree's section.
+func HasCoAuthorsHook(dir string) bool {
+	return hasHookSection(dir, "prepare-commit-msg", coAuthorsHookBegin, coAuthorsHookEnd)
+}
+
+// hasHookSection reports whether the hook called name has the section
+// between begin and end.
+func hasHookSection(dir, name, begin, end string) bool {
+	path, err := repoHookPath(dir, name)
+	if err != nil {
+		return false
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false
+	}
+	_, found := removeHookSection(string(data), begin, end)
+	return found
+}
+
+// insertRepoHookSection places section right after the shebang, so the check
+// runs before anything in the hook that might exec or exit.
+func insertRepoHookSection(content, section string) string {
+	if content == "" {
+		return "#!/bin/sh\n" + section
+	}
+	if !strings.HasPrefix(content, "#!") {
+		return section + content
+	}
+	shebang, rest, _ := strings.Cut(content, "\n")
+	return shebang + "\n" + section + rest
+}
+
+// removeHookSection strips the section between begin and end from content
+// and reports whether there was one.
+func removeHookSection(content, begin, end string) (string, bool) {
+	lines := strings.SplitAfter(content, "\n")
+	var kept []string
+	found, inside := false, false
+	for _, line := range lines {
+		switch strings.TrimSpace(line) {
+		case begin:
+			found, inside = true, true
+			continue
+		case end:
+			if inside {
+				inside = false
+				continue
+			}
+		}
+		if !inside {
+			kept = append(kept, line)
+		}
+	}
+	return strings.Join(kept, ""), found
+}
+
+// isShellScript reports whether content is run by a POSIX-style shell. A hook
+// without a shebang is run by git through sh.
+func isShellScript(content string) bool {
+	if !strings.HasPrefix(content, "#!") {
+		return true
+	}
+	shebang, _, _ := strings.Cut(content, "\n")
+	fields := strings.Fields(strings.TrimPrefix(shebang, "#!"))
+	if len(fields) == 0 {
+		return false
+	}
+	interpreter := filepath.Base(fields[0])
+	if interpreter == "env" && len(fields) > 1 {
+		interpreter = fields[1]
+	}
+	switch interpreter {
+	case "sh", "bash", "dash", "ash", "ksh", "zsh":
+		return true
+	}
+	return false
+}
+
+// isEmptyScript reports whether content has nothing but a shebang and blank lines.
+func isEmptyScript(content string) bool {
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "#!") {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,238 @@
+package guard
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func initRepo(t *testing.T) string {
+	t.Helper()
+	repo := filepath.Join(setupGuardTestEnv(t), "repo")
+	if out, err := exec.Command("git", "init", "-q", repo).CombinedOutput(); err != nil {
+		t.Fatalf("git init failed: %v\n%s", err, out)
+	}
+	return repo
+}
+
+func TestRepoHook_CreateAndRemove(t *testing.T) {
+	repo := initRepo(t)
+	hookPath := filepath.Join(repo, ".git", "hooks", "pre-commit")
+
+	path, err := InstallRepoHook(filepath.Join(repo), "/opt/my tools/gidtree")
+	if err != nil {
+		t.Fatalf("InstallRepoHook() error = %v", err)
+	}
+	if path != hookPath {
+		t.Errorf("InstallRepoHook() path = %q, want %q", path, hookPath)
+	}
+	data, _ := os.ReadFile(hookPath)
+	if !strings.HasPrefix(string(data), "#!/bin/sh\n") || !strings.Contains(string(data), "'/opt/my tools/gidtree' check-identity --quiet || exit 1") {
+		t.Errorf("hook = %q, want a shell script calling check-identity", data)
+	}
+	if info, _ := os.Stat(hookPath); info.Mode()&0100 == 0 {
+		t.Errorf("hook mode = %v, want executable", info.Mode())
+	}
+	if !HasRepoHook(repo) {
+		t.Error("HasRepoHook() = false after install")
+	}
+
+	// Installing again does not duplicate the section
+	if _, err := InstallRepoHook(repo, "/usr/bin/gidtree"); err != nil {
+		t.Fatalf("InstallRepoHook() again error = %v", err)
+	}
+	data, _ = os.ReadFile(hookPath)
+	if strings.Count(string(data), repoHookBegin) != 1 || !strings.Contains(string(data), "'/usr/bin/gidtree'") {
+		t.Errorf("hook after reinstall = %q, want one refreshed section", data)
+	}
+
+	removed, err := UninstallRepoHook(repo)
+	if err != nil || !removed {
+		t.Fatalf("UninstallRepoHook() = %v, %v; want true", removed, err)
+	}
+	if _, err := os.Stat(hookPath); !os.IsNotExist(err) {
+		t.Error("a hook holding only gidtree's section should be deleted")
+	}
+	if removed, err := UninstallRepoHook(repo); err != nil || removed {
+		t.Errorf("UninstallRepoHook() without a hook = %v, %v; want false", removed, err)
+	}
+}
+
+func TestCoAuthorsHook_CreateAndRemove(t *testing.T) {
+	repo := initRepo(t)
+	hookPath := filepath.Join(repo, ".git", "hooks", "prepare-commit-msg")
+	if _, err := InstallRepoHook(repo, "/usr/bin/gidtree"); err != nil {
+		t.Fatalf("InstallRepoHook() error = %v", err)
+	}
+
+	path, err := InstallCoAuthorsHook(repo, "/usr/bin/gidtree")
+	if err != nil {
+		t.Fatalf("InstallCoAuthorsHook() error = %v", err)
+	}
+	if path != hookPath {
+		t.Errorf("InstallCoAuthorsHook() path = %q, want %q", path, hookPath)
+	}
+	data, _ := os.ReadFile(hookPath)
+	if !strings.Contains(string(data), `'/usr/bin/gidtree' internal prepare-commit-msg "$@" || exit 1`) {
+		t.Errorf("hook = %q, want a call passing git's arguments on", data)
+	}
+	if !HasCoAuthorsHook(repo) {
+		t.Error("HasCoAuthorsHook() = false after install")
+	}
+
+	removed, err := UninstallCoAuthorsHook(repo)
+	if err != nil || !removed {
+		t.Fatalf("UninstallCoAuthorsHook() = %v, %v; want true", removed, err)
+	}
+	if _, err := os.Stat(hookPath); !os.IsNotExist(err) {
+		t.Error("a hook holding only gidtree's section should be deleted")
+	}
+	// Each hook has its own section
+	if !HasRepoHook(repo) {
+		t.Error("removing the co-authors hook should keep the identity check")
+	}
+}
+
+func TestRepoHook_ChainsExistingHook(t *testing.T) {
+	repo := initRepo(t)
+	hookPath := filepath.Join(repo, ".git", "hooks", "pre-commit")
+	original := "#!/usr/bin/env bash\nset -e\nmake lint\n"
+	if err := os.MkdirAll(filepath.Dir(hookPath), 0755); err != nil {
+		t.Fatalf("Failed to create hooks directory: %v", err)
+	}
+	if err := os.WriteFile(hookPath, []byte(original), 0755); err != nil {
+		t.Fatalf("Failed to write hook: %v", err)
+	}
+
+	if _, err := InstallRepoHook(repo, "gidtree"); err != nil {
+		t.Fatalf("InstallRepoHook() error = %v", err)
+	}
+	data, _ := os.ReadFile(hookPath)
+	content := string(data)
+	if !strings.HasPrefix(content, "#!/usr/bin/env bash\n"+repoHookBegin) {
+		t.Errorf("hook = %q, want the section right after the shebang", content)
+	}
+	if !strings.HasSuffix(content, "set -e\nmake lint\n") {
+		t.Errorf("hook = %q, want the original body kept", content)
+	}
+
+	if _, err := UninstallRepoHook(repo); err != nil {
+		t.Fatalf("UninstallRepoHook() error = %v", err)
+	}
+	data, _ = os.ReadFile(hookPath)
+	if string(data) != original {
+		t.Errorf("hook after uninstall = %q, want %q", data, original)
+	}
+}
+
+// gitIn runs git in repo and fails the test if it does.
+func gitIn(t *testing.T, repo string, args ...string) {
+	t.Helper()
+	if out, err := exec.Command("git", append([]string{"-C", repo}, args...)...).CombinedOutput(); err != nil {
+		t.Fatalf("git %s failed: %v\n%s", strings.Join(args, " "), err, out)
+	}
+}
+
+func TestRepoHook_HooksPath(t *testing.T) {
+	repo := initRepo(t)
+	gitIn(t, repo, "config", "core.hooksPath", ".husky")
+	hookPath := filepath.Join(repo, ".husky", "pre-commit")
+
+	// A check that always fails shows whether git runs the hook
+	path, err := InstallRepoHook(repo, "false")
+	if err != nil {
+		t.Fatalf("InstallRepoHook() error = %v", err)
+	}
+	if path != hookPath {
+		t.Errorf("InstallRepoHook() path = %q, want %q", path, hookPath)
+	}
+	commit := exec.Command("git", "-C", repo, "-c", "user.name=Jane", "-c", "user.email=jane@example.com", "commit", "-q", "--allow-empty", "-m", "test")
+	if out, err := commit.CombinedOutput(); err == nil {
+		t.Errorf("commit succeeded, want the hook under core.hooksPath to block it:\n%s", out)
+	}
+
+	if removed, err := UninstallRepoHook(repo); err != nil || !removed {
+		t.Errorf("UninstallRepoHook() = %v, %v; want true", removed, err)
+	}
+}
+
+func TestCoAuthorsHook_HooksPath(t *testing.T) {
+	repo := initRepo(t)
+	gitIn(t, repo, "config", "core.hooksPath", ".husky")
+	hookPath := filepath.Join(repo, ".husky", "prepare-commit-msg")
+
+	// A command that always fails shows whether git runs the hook
+	path, err := InstallCoAuthorsHook(repo, "false")
+	if err != nil {
+		t.Fatalf("InstallCoAuthorsHook() error = %v", err)
+	}
+	if path != hookPath {
+		t.Errorf("InstallCoAuthorsHook() path = %q, want %q", path, hookPath)
+	}
+	if !HasCoAuthorsHook(repo) {
+		t.Error("HasCoAuthorsHook() = false after install")
+	}
+	commit := exec.Command("git", "-C", repo, "-c", "user.name=Jane", "-c", "user.email=jane@example.com", "commit", "-q", "--allow-empty", "-m", "test")
+	if out, err := commit.CombinedOutput(); err == nil {
+		t.Errorf("commit succeeded, want the hook under core.hooksPath to run:\n%s", out)
+	}
+}
+
+func TestRepoHook_StrictGuardHooksPath(t *testing.T) {
+	repo := initRepo(t)
+	guardHooks, err := GetHooksDir()
+	if err != nil {
+		t.Fatalf("GetHooksDir() error = %v", err)
+	}
+	gitIn(t, repo, "config", "core.hooksPath", filepath.ToSlash(guardHooks))
+
+	// gidtree's own scripts chain .git/hooks, so the hook goes there
+	path, err := InstallRepoHook(repo, "gidtree")
+	if err != nil {
+		t.Fatalf("InstallRepoHook() error = %v", err)
+	}
+	if want := filepath.Join(repo, ".git", "hooks", "pre-commit"); path != want {
+		t.Errorf("InstallRepoHook() path = %q, want %q", path, want)
+	}
+}
+
+func TestRepoHook_RejectsForeignHook(t *testing.T) {
+	repo := initRepo(t)
+	hookPath := filepath.Join(repo, ".git", "hooks", "pre-commit")
+	if err := os.MkdirAll(filepath.Dir(hookPath), 0755); err != nil {
+		t.Fatalf("Failed to create hooks directory: %v", err)
+	}
+	if err := os.WriteFile(hookPath, []byte("#!/usr/bin/env python3\nprint('hi')\n"), 0755); err != nil {
+		t.Fatalf("Failed to write hook: %v", err)
+	}
+
+	_, err := InstallRepoHook(repo, "gidtree")
+	if !errors.Is(err, ErrForeignHook) {
+		t.Errorf("InstallRepoHook() error = %v, want ErrForeignHook", err)
+	}
+	if _, err := InstallRepoHook(filepath.Dir(repo), "gidtree"); err == nil {
+		t.Error("InstallRepoHook() outside a repository should fail")
+	}
+}
+
+func TestIsShellScript(t *testing.T) {
+	tests := []struct {
+		content string
+		want    bool
+	}{
+		{"echo hi\n", true},
+		{"#!/bin/sh\n", true},
+		{"#!/usr/bin/env bash\n", true},
+		{"#!/bin/zsh -e\n", true},
+		{"#!/usr/bin/env python3\n", false},
+		{"#!/usr/bin/node\n", false},
+	}
+	for _, tt := range tests {
+		if got := isShellScript(tt.content); got != tt.want {
+			t.Errorf("isShellScript(%q) = %v, want %v", tt.content, got, tt.want)
+		}
+	}
+}
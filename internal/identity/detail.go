@@ -0,0 +1,182 @@
+package identity
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/thuanlegit/git-identitree/internal/logging"
+	"github.com/thuanlegit/git-identitree/internal/mapping"
+	"github.com/thuanlegit/git-identitree/internal/profile"
+	"github.com/thuanlegit/git-identitree/internal/signers"
+	"github.com/thuanlegit/git-identitree/internal/utils"
+)
+
+// GPGState describes whether a profile's signing key is in the GPG keyring.
+type GPGState string
+
+const (
+	// GPGNone means the profile has no GPG key configured.
+	GPGNone GPGState = "none"
+	// GPGPresent means gpg has a secret key for the key ID.
+	GPGPresent GPGState = "present"
+	// GPGMissing means gpg has no secret key for the key ID.
+	GPGMissing GPGState = "missing"
+	// GPGUnknown means gpg could not be run to check.
+	GPGUnknown GPGState = "unknown"
+)
+
+// ProfileDetail gathers a profile with everything derived from it: its
+// generated git config, the directories mapped to it and the state of its keys.
+// It backs `gidtree profile show` and can fill any other detail view.
+type ProfileDetail struct {
+	Profile      profile.Profile `json:"profile"`
+	ConfigPath   string          `json:"config_path"`
+	ConfigExists bool            `json:"config_exists"`
+	Directories  []string        `json:"directories"`
+	KeyState     KeyState        `json:"key_state"`
+	GPGState     GPGState        `json:"gpg_state"`
+}
+
+// gpgSecretKeyPresent reports whether gpg has a secret key for id. Replaced in tests.
+var gpgSecretKeyPresent = gpgListSecretKey
+
+// DescribeProfile gathers the detail of prof. It asks the SSH agent and gpg
+// about the profile's keys, so it may block as long as they take to answer.
+func DescribeProfile(prof *profile.Profile) (ProfileDetail, error) {
+	d := ProfileDetail{
+		Profile:     prof.Clone(),
+		Directories: []string{},
+		KeyState:    KeyNone,
+		GPGState:    GPGNone,
+	}
+
+	configPath, err := mapping.GetProfileConfigPath(prof.Name)
+	if err != nil {
+		return d, fmt.Errorf("failed to get profile config path: %w", err)
+	}
+	d.ConfigPath = configPath
+	_, err = os.Stat(configPath)
+	d.ConfigExists = err == nil
+
+	dirs, err := mapping.GetDirectoriesForProfile(prof.Name)
+	if err != nil {
+		return d, fmt.Errorf("failed to get mappings: %w", err)
+	}
+	if dirs != nil {
+		d.Directories = dirs
+	}
+
+	if prof.SSHKeyPath != "" {
+		d.KeyState = CheckKeyState(prof.SSHKeyPath)
+	}
+	if prof.GPGKeyID != "" {
+		present, err := gpgSecretKeyPresent(prof.GPGKeyID)
+		switch {
+		case err != nil:
+			d.GPGState = GPGUnknown
+		case present:
+			d.GPGState = GPGPresent
+		default:
+			d.GPGState = GPGMissing
+		}
+	}
+	return d, nil
+}
+
+// Facts returns every field of the profile and what is derived from it, in
+// display order.
+func (d ProfileDetail) Facts() []Fact {
+	p := d.Profile
+	facts := []Fact{
+		{Label: "Name", Value: p.Name},
+		{Label: "Email", Value: p.Email},
+	}
+	if len(p.EmailAliases) > 0 {
+		facts = append(facts, Fact{Label: "Aliases", Value: strings.Join(p.EmailAliases, ", ")})
+	}
+	facts = append(facts, Fact{Label: "Author", Value: p.GetAuthorName()})
+	if p.HasSeparateCommitter() {
+		facts = append(facts, Fact{Label: "Committer", Value: committerLabel(&p)})
+	}
+
+	if p.SSHKeyPath != "" {
+		facts = append(facts, Fact{Label: "SSH Key", Value: fmt.Sprintf("%s (%s)", utils.AbbreviateHome(p.SSHKeyPath), keyStateLabel(d.KeyState, p.Name))})
+		if p.SSHKeyFingerprint != "" {
+			facts = append(facts, Fact{Label: "Fingerprint", Value: p.SSHKeyFingerprint})
+		}
+		if p.IsolateSSHConfig {
+			facts = append(facts, Fact{Label: "SSH Config", Value: "ignored (isolate_ssh_config)"})
+		}
+	} else {
+		facts = append(facts, Fact{Label: "SSH Key", Value: "none"})
+	}
+
+	if p.GPGKeyID != "" {
+		facts = append(facts, Fact{Label: "GPG Key", Value: fmt.Sprintf("%s (%s)", p.GPGKeyID, gpgStateLabel(d.GPGState))})
+	} else {
+		facts = append(facts, Fact{Label: "GPG Key", Value: "none"})
+	}
+	if p.SSHSigning {
+		value := "SSH key"
+		if path, err := signers.Path(p.Name); err == nil {
+			value += ", verified with " + utils.AbbreviateHome(path)
+		}
+		facts = append(facts, Fact{Label: "Signing", Value: value})
+	}
+
+	if len(p.RemotePatterns) > 0 {
+		facts = append(facts, Fact{Label: "Remotes", Value: strings.Join(p.RemotePatterns, ", ")})
+	}
+
+	config := utils.AbbreviateHome(d.ConfigPath)
+	switch {
+	case d.ConfigExists:
+		config += " (exists)"
+	case len(d.Directories) > 0:
+		config += " (missing — run gidtree sync)"
+	default:
+		config += " (not written until the profile is mapped)"
+	}
+	facts = append(facts, Fact{Label: "Git Config", Value: config})
+
+	if len(d.Directories) == 0 {
+		facts = append(facts, Fact{Label: "Mapped", Value: "none"})
+	}
+	for _, dir := range d.Directories {
+		facts = append(facts, Fact{Label: "Mapped", Value: utils.AbbreviateHome(dir)})
+	}
+	facts = append(facts, Fact{Label: "Last Used", Value: p.LastUsedLabel()})
+	return facts
+}
+
+// gpgStateLabel returns a human-readable label for a GPG key state.
+func gpgStateLabel(state GPGState) string {
+	switch state {
+	case GPGPresent:
+		return "in keyring"
+	case GPGMissing:
+		return "not in keyring"
+	case GPGUnknown:
+		return "gpg not available"
+	}
+	return "none"
+}
+
+// gpgListSecretKey asks gpg whether it has a secret key for id. gpg exits 2
+// when it has none.
+func gpgListSecretKey(id string) (bool, error) {
+	cmd := utils.Command("gpg", "--batch", "--list-secret-keys", "--with-colons", id)
+	err := cmd.Run()
+	logging.Command(cmd, err)
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,116 @@
+package identity
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/thuanlegit/git-identitree/internal/profile"
+)
+
+func stubGPG(t *testing.T, present bool, err error) {
+	t.Helper()
+	original := gpgSecretKeyPresent
+	t.Cleanup(func() { gpgSecretKeyPresent = original })
+	gpgSecretKeyPresent = func(string) (bool, error) { return present, err }
+}
+
+func TestDescribeProfile_Mapped(t *testing.T) {
+	tmpDir := setupIdentityTestEnv(t)
+	stubGPG(t, true, nil)
+
+	keyPath := filepath.Join(tmpDir, ".ssh", "id_work")
+	if err := os.MkdirAll(filepath.Dir(keyPath), 0700); err != nil {
+		t.Fatalf("Failed to create .ssh: %v", err)
+	}
+	if err := os.WriteFile(keyPath, []byte("key"), 0600); err != nil {
+		t.Fatalf("Failed to write key: %v", err)
+	}
+	prof := profile.Profile{Name: "work", Email: "work@example.com", EmailAliases: []string{"work@old.example.com", "jane@old.example.com"}, SSHKeyPath: keyPath, GPGKeyID: "ABCD1234"}
+	mapTestProfile(t, prof, filepath.Join(tmpDir, "work"))
+
+	d, err := DescribeProfile(&prof)
+	if err != nil {
+		t.Fatalf("DescribeProfile() error = %v", err)
+	}
+	if d.ConfigPath != filepath.Join(tmpDir, ".gitconfig-work") || !d.ConfigExists {
+		t.Errorf("DescribeProfile() config = %q exists %v, want the written ~/.gitconfig-work", d.ConfigPath, d.ConfigExists)
+	}
+	if len(d.Directories) != 1 || !strings.HasPrefix(d.Directories[0], filepath.Join(tmpDir, "work")) {
+		t.Errorf("DescribeProfile() directories = %v, want ~/work", d.Directories)
+	}
+	if d.KeyState != KeyNotLoaded {
+		t.Errorf("DescribeProfile() key state = %v, want %v", d.KeyState, KeyNotLoaded)
+	}
+	if d.GPGState != GPGPresent {
+		t.Errorf("DescribeProfile() GPG state = %v, want %v", d.GPGState, GPGPresent)
+	}
+
+	facts := map[string]string{}
+	for _, f := range d.Facts() {
+		facts[f.Label] = f.Value
+	}
+	if facts["Git Config"] != "~/.gitconfig-work (exists)" {
+		t.Errorf("Facts() Git Config = %q", facts["Git Config"])
+	}
+	if _, ok := facts["Committer"]; ok {
+		t.Errorf("Facts() Committer = %q, want none when it is the author", facts["Committer"])
+	}
+	if facts["Aliases"] != "work@old.example.com, jane@old.example.com" {
+		t.Errorf("Facts() Aliases = %q", facts["Aliases"])
+	}
+	if facts["GPG Key"] != "ABCD1234 (in keyring)" {
+		t.Errorf("Facts() GPG Key = %q", facts["GPG Key"])
+	}
+	if !strings.HasPrefix(facts["SSH Key"], "~/.ssh/id_work (") {
+		t.Errorf("Facts() SSH Key = %q", facts["SSH Key"])
+	}
+	if facts["Last Used"] != "never" {
+		t.Errorf("Facts() Last Used = %q, want never", facts["Last Used"])
+	}
+
+	d.Profile.SSHKeyFingerprint = "SHA256:47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU"
+	for _, f := range d.Facts() {
+		facts[f.Label] = f.Value
+	}
+	if facts["Fingerprint"] != d.Profile.SSHKeyFingerprint {
+		t.Errorf("Facts() Fingerprint = %q, want the recorded one", facts["Fingerprint"])
+	}
+}
+
+func TestDescribeProfile_Unmapped(t *testing.T) {
+	setupIdentityTestEnv(t)
+	stubGPG(t, false, errors.New("gpg: not found"))
+
+	prof := profile.Profile{Name: "personal", Email: "me@example.com", GPGKeyID: "FFFF0000"}
+	d, err := DescribeProfile(&prof)
+	if err != nil {
+		t.Fatalf("DescribeProfile() error = %v", err)
+	}
+	if d.ConfigExists || len(d.Directories) != 0 || d.KeyState != KeyNone || d.GPGState != GPGUnknown {
+		t.Errorf("DescribeProfile() = %+v, want an unmapped profile without an SSH key", d)
+	}
+
+	data, err := json.Marshal(d)
+	if err != nil {
+		t.Fatalf("json.Marshal() error = %v", err)
+	}
+	for _, want := range []string{`"directories":[]`, `"key_state":"none"`, `"gpg_state":"unknown"`, `"config_exists":false`} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("JSON = %s, want %s", data, want)
+		}
+	}
+
+	var mapped []string
+	for _, f := range d.Facts() {
+		if f.Label == "Mapped" {
+			mapped = append(mapped, f.Value)
+		}
+	}
+	if len(mapped) != 1 || mapped[0] != "none" {
+		t.Errorf("Facts() Mapped = %v, want [none]", mapped)
+	}
+}
@@ -0,0 +1,154 @@
+package identity
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/thuanlegit/git-identitree/internal/logging"
+	"github.com/thuanlegit/git-identitree/internal/mapping"
+	"github.com/thuanlegit/git-identitree/internal/profile"
+	"github.com/thuanlegit/git-identitree/internal/utils"
+)
+
+// ConfigOrigin is one value of a git config key together with where git read
+// it, as `git config --show-scope --show-origin` reports it.
+type ConfigOrigin struct {
+	Key string `json:"key"`
+	// Scope is system, global, local, worktree or command.
+	Scope string `json:"scope"`
+	// Origin is the kind of source: file, command line, blob or standard input.
+	Origin string `json:"origin"`
+	// Path is the file or blob the value came from, empty for other origins.
+	Path  string `json:"path,omitempty"`
+	Value string `json:"value"`
+	// Managed describes the part of gidtree that wrote the value, such as
+	// "profile 'work'"; it is empty for values gidtree did not write.
+	Managed string `json:"managed,omitempty"`
+}
+
+// ParseConfigOrigins parses the output of
+// `git config --show-scope --show-origin --get-all <key>`: one tab-separated
+// scope, origin and value per line. The path of a file origin may contain
+// colons, as on Windows, and is C-quoted by git when it holds tabs, quotes or
+// non-ASCII bytes. Values may contain tabs.
+func ParseConfigOrigins(key, output string) ([]ConfigOrigin, error) {
+	var origins []ConfigOrigin
+	for _, line := range strings.Split(strings.TrimRight(output, "\r\n"), "\n") {
+		line = strings.TrimSuffix(line, "\r")
+		if line == "" {
+			continue
+		}
+		fields := strings.SplitN(line, "\t", 3)
+		if len(fields) < 3 {
+			return nil, fmt.Errorf("unexpected git config output: %q", line)
+		}
+		kind, path, ok := strings.Cut(fields[1], ":")
+		if !ok {
+			return nil, fmt.Errorf("unexpected git config origin: %q", fields[1])
+		}
+		if strings.HasPrefix(path, `"`) {
+			unquoted, err := strconv.Unquote(path)
+			if err != nil {
+				return nil, fmt.Errorf("unexpected git config origin: %q", fields[1])
+			}
+			path = unquoted
+		}
+		origins = append(origins, ConfigOrigin{Key: key, Scope: fields[0], Origin: kind, Path: path, Value: fields[2]})
+	}
+	return origins, nil
+}
+
+// ExplainConfig returns every value of each key git reads in dir, in the order
+// git reads them, so the last value of a key is the one git uses. Values
+// written by gidtree are annotated: the generated ~/.gitconfig-<profile> files,
+// the managed include file and the entries of a pinned profile.
+func ExplainConfig(dir string, keys ...string) ([]ConfigOrigin, error) {
+	var origins []ConfigOrigin
+	for _, key := range keys {
+		output, err := configOrigins(dir, key)
+		if err != nil {
+			return nil, err
+		}
+		parsed, err := ParseConfigOrigins(key, output)
+		if err != nil {
+			return nil, err
+		}
+		origins = append(origins, parsed...)
+	}
+
+	root, inRepo := utils.FindRepoRoot(dir)
+	for i := range origins {
+		o := &origins[i]
+		if o.Origin == "file" && inRepo && !filepath.IsAbs(o.Path) {
+			// git reports repository files relative to the top of the worktree
+			o.Path = filepath.Join(root, o.Path)
+		}
+	}
+	annotateManaged(dir, origins)
+	return origins, nil
+}
+
+// annotateManaged sets Managed on the origins gidtree wrote.
+func annotateManaged(dir string, origins []ConfigOrigin) {
+	managed := make(map[string]string)
+	if manager, err := profile.NewDefaultManager(); err == nil {
+		for _, p := range manager.ListProfiles() {
+			if path, err := mapping.GetProfileConfigPath(p.Name); err == nil {
+				managed[path] = fmt.Sprintf("profile '%s'", p.Name)
+			}
+		}
+	}
+	if path, err := mapping.ManagedIncludePath(); err == nil {
+		managed[path] = "managed include file"
+	}
+	pinned, pinnedEntries := mapping.PinnedConfig(dir)
+	localConfig, _ := mapping.LocalConfigPath(dir)
+
+	for i := range origins {
+		o := &origins[i]
+		if o.Origin != "file" {
+			continue
+		}
+		if label, ok := managed[o.Path]; ok {
+			o.Managed = label
+			continue
+		}
+		if pinned != "" && sameFile(o.Path, localConfig) && pinnedEntries[strings.ToLower(o.Key)] == o.Value {
+			o.Managed = fmt.Sprintf("pinned profile '%s'", pinned)
+		}
+	}
+}
+
+// sameFile reports whether two paths name the same file, following symlinks.
+func sameFile(a, b string) bool {
+	if a == "" || b == "" {
+		return false
+	}
+	if resolved, err := filepath.EvalSymlinks(a); err == nil {
+		a = resolved
+	}
+	if resolved, err := filepath.EvalSymlinks(b); err == nil {
+		b = resolved
+	}
+	return filepath.Clean(a) == filepath.Clean(b)
+}
+
+// configOrigins runs git config --show-scope --show-origin for key in dir.
+// A key that is not set gives no output.
+func configOrigins(dir, key string) (string, error) {
+	cmd := utils.Command("git", "-C", dir, "config", "--show-scope", "--show-origin", "--get-all", key)
+	output, err := cmd.Output()
+	logging.Command(cmd, err)
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to run git config: %w", err)
+	}
+	return string(output), nil
+}
@@ -0,0 +1,139 @@
+package identity
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/thuanlegit/git-identitree/internal/mapping"
+	"github.com/thuanlegit/git-identitree/internal/profile"
+)
+
+// The samples in testdata/show-origin were captured from git 2.39 with
+// --show-scope --show-origin --get-all; the format is the same since git 2.26
+// added --show-scope. windows.txt follows the output of Git for Windows, CRLF
+// line ending included.
+func TestParseConfigOrigins(t *testing.T) {
+	tests := []struct {
+		file string
+		want []ConfigOrigin
+	}{
+		{
+			file: "include-quoted.txt",
+			want: []ConfigOrigin{
+				{Scope: "global", Origin: "file", Path: "/tmp/orig/home/.gitconfig", Value: "g@x"},
+				{Scope: "global", Origin: "file", Path: "/tmp/orig/home/.gitconfig-w\tork", Value: "w@x"},
+				{Scope: "local", Origin: "file", Path: ".git/config", Value: "l@x"},
+				{Scope: "command", Origin: "command line", Value: "c@x"},
+			},
+		},
+		{
+			file: "worktree-colon.txt",
+			want: []ConfigOrigin{
+				{Scope: "global", Origin: "file", Path: "/tmp/orig/home/.gitconfig", Value: "g@x"},
+				{Scope: "global", Origin: "file", Path: "/tmp/orig/home/.gitconfig-w\tork", Value: "w@x"},
+				{Scope: "local", Origin: "file", Path: "/tmp/orig/a:b/repo/.git/config", Value: "l@x"},
+			},
+		},
+		{
+			file: "windows.txt",
+			want: []ConfigOrigin{
+				{Scope: "system", Origin: "file", Path: "C:/Program Files/Git/etc/gitconfig", Value: "jane@example.com"},
+				{Scope: "global", Origin: "file", Path: "C:/Users/jane/.gitconfig", Value: "jane@example.com"},
+				{Scope: "global", Origin: "file", Path: "C:/Users/jane/.gitconfig-work", Value: "jane@work.example"},
+			},
+		},
+		{
+			file: "nonascii-tab-value.txt",
+			want: []ConfigOrigin{
+				{Scope: "global", Origin: "file", Path: "/tmp/orig/home/.gitconfig", Value: "Global Name"},
+				{Scope: "command", Origin: "file", Path: "/tmp/orig/jé/extra", Value: "Jane\t\"The Dev\": Doe"},
+			},
+		},
+		{
+			file: "blob-stdin.txt",
+			want: []ConfigOrigin{
+				{Scope: "command", Origin: "blob", Path: "HEAD:conf", Value: "blob@x"},
+				{Scope: "command", Origin: "standard input", Value: "in@x"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			data, err := os.ReadFile(filepath.Join("testdata", "show-origin", tt.file))
+			if err != nil {
+				t.Fatalf("ReadFile() error = %v", err)
+			}
+			got, err := ParseConfigOrigins("user.email", string(data))
+			if err != nil {
+				t.Fatalf("ParseConfigOrigins() error = %v", err)
+			}
+			for i := range tt.want {
+				tt.want[i].Key = "user.email"
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseConfigOrigins() =\n%+v\nwant\n%+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseConfigOrigins_Malformed(t *testing.T) {
+	for _, output := range []string{
+		"file:/home/jane/.gitconfig\tjane@example.com\n", // git before 2.26, without --show-scope
+		"global\tnot-an-origin\tjane@example.com\n",
+		"global\tfile:\"/unterminated\tjane@example.com\n",
+	} {
+		if _, err := ParseConfigOrigins("user.email", output); err == nil {
+			t.Errorf("ParseConfigOrigins(%q) should fail", output)
+		}
+	}
+	if got, err := ParseConfigOrigins("user.email", ""); err != nil || len(got) != 0 {
+		t.Errorf("ParseConfigOrigins(\"\") = %v, %v, want nothing", got, err)
+	}
+}
+
+func TestExplainConfig(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+	tmpDir := setupIdentityTestEnv(t)
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+
+	work := profile.Profile{Name: "work", Email: "work@example.com"}
+	mapTestProfile(t, work, filepath.Join(tmpDir, "work"))
+	repo := filepath.Join(tmpDir, "work", "api")
+	if out, err := exec.Command("git", "init", "-q", repo).CombinedOutput(); err != nil {
+		t.Fatalf("git init error = %v: %s", err, out)
+	}
+	manager, err := profile.NewDefaultManager()
+	if err != nil {
+		t.Fatalf("NewDefaultManager() error = %v", err)
+	}
+	client := profile.Profile{Name: "client", Email: "me@client.example"}
+	if err := manager.AddProfile(client); err != nil {
+		t.Fatalf("AddProfile() error = %v", err)
+	}
+	if _, err := mapping.Pin(&client, repo); err != nil {
+		t.Fatalf("Pin() error = %v", err)
+	}
+
+	origins, err := ExplainConfig(repo, "user.email")
+	if err != nil {
+		t.Fatalf("ExplainConfig() error = %v", err)
+	}
+	var managed []string
+	for _, o := range origins {
+		managed = append(managed, o.Value+"="+o.Managed)
+	}
+	got := strings.Join(managed, ", ")
+	if want := "work@example.com=profile 'work', me@client.example=pinned profile 'client'"; got != want {
+		t.Errorf("ExplainConfig() = %s, want %s", got, want)
+	}
+	if last := origins[len(origins)-1]; last.Scope != "local" || !filepath.IsAbs(last.Path) {
+		t.Errorf("last origin = %+v, want the repository's config with an absolute path", last)
+	}
+}
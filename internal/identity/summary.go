@@ -0,0 +1,405 @@
+package identity
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"github.com/thuanlegit/git-identitree/internal/config"
+	"github.com/thuanlegit/git-identitree/internal/logging"
+	"github.com/thuanlegit/git-identitree/internal/mapping"
+	"github.com/thuanlegit/git-identitree/internal/profile"
+	"github.com/thuanlegit/git-identitree/internal/ssh"
+	"github.com/thuanlegit/git-identitree/internal/utils"
+)
+
+// Source describes how the identity for a directory was resolved.
+type Source string
+
+const (
+	// SourceNone means no profile applies to the directory.
+	SourceNone Source = "none"
+	// SourceMapping means the profile was resolved through an includeIf mapping.
+	SourceMapping Source = "mapping"
+	// SourceDefault means no mapping matched and the configured default profile applies.
+	SourceDefault Source = "default"
+	// SourcePinned means 'gidtree pin' wrote the profile into the repository's
+	// own config, which overrides any mapping.
+	SourcePinned Source = "pinned"
+)
+
+// KeyState describes the SSH agent state of the resolved profile's key.
+type KeyState string
+
+const (
+	// KeyNone means the profile has no SSH key configured.
+	KeyNone KeyState = "none"
+	// KeyLoaded means the key is present in the SSH agent.
+	KeyLoaded KeyState = "loaded"
+	// KeyNotLoaded means the key exists on disk but is not in the agent.
+	KeyNotLoaded KeyState = "not_loaded"
+	// KeyMissing means the configured key file does not exist.
+	KeyMissing KeyState = "missing"
+	// KeyUnknown means the key exists but the agent has not been asked yet;
+	// see SummarizeWithoutAgent.
+	KeyUnknown KeyState = "unknown"
+	// KeyAgentUnreachable means the key exists but the SSH agent could not be
+	// reached in time to ask, as when a forwarded agent has gone away.
+	KeyAgentUnreachable KeyState = "agent_unreachable"
+	// KeyOffline means the key exists but the agent was not asked, as offline
+	// is set in config.yaml.
+	KeyOffline KeyState = "offline"
+)
+
+// Summary gathers everything known about the identity that applies to a directory.
+// It is the single source of truth for activate, status and any other surface
+// that reports the active identity.
+type Summary struct {
+	Directory       string           `json:"directory"`
+	Profile         *profile.Profile `json:"profile,omitempty"`
+	Source          Source           `json:"source"`
+	MappedDirectory string           `json:"mapped_directory,omitempty"`
+	KeyState        KeyState         `json:"key_state"`
+	SSHKeyLoaded    bool             `json:"ssh_key_loaded"`
+	Signing         string           `json:"signing"`
+	LocalEmail      string           `json:"local_email,omitempty"`
+	// Effective is what git itself resolves, checked only inside a git repository.
+	Effective *Effective `json:"effective,omitempty"`
+
+	// rawCondition is the includeIf condition of the mapping, as written.
+	rawCondition string
+}
+
+// Effective is the identity git resolves for a repository, compared with the
+// profile gidtree expects to apply.
+type Effective struct {
+	Name    string `json:"name"`
+	Email   string `json:"email"`
+	Matches bool   `json:"matches"`
+	// Causes lists likely reasons for a mismatch.
+	Causes []string `json:"causes,omitempty"`
+}
+
+// GitConfig reads configuration the way git resolves it for a directory.
+type GitConfig interface {
+	// Get returns the effective value of key in dir, or "" when it is unset.
+	Get(dir, key string) (string, error)
+}
+
+// gitCLI is the GitConfig backed by the git command.
+type gitCLI struct{}
+
+// Get implements GitConfig.
+func (gitCLI) Get(dir, key string) (string, error) {
+	cmd := utils.Command("git", "-C", dir, "config", "--get", key)
+	output, err := cmd.Output()
+	logging.Command(cmd, err)
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+		// Key is not set
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to run git config: %w", err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// Fact is a single labeled piece of information from a Summary.
+type Fact struct {
+	Label string
+	Value string
+}
+
+var (
+	// checkKeyLoaded reports whether an SSH key is in the agent. Replaced in tests.
+	checkKeyLoaded = ssh.CheckKeyLoaded
+
+	// localConfigEmail returns the repository-local user.email for a directory,
+	// or an empty string when there is none. Replaced in tests.
+	localConfigEmail = gitLocalEmail
+
+	// effectiveConfig resolves config values as git sees them. Replaced in tests.
+	effectiveConfig GitConfig = gitCLI{}
+)
+
+// Summarize resolves the identity for a directory.
+func Summarize(dir string) (Summary, error) {
+	return summarize(dir, summarizeOptions{askAgent: true, askGit: true})
+}
+
+// SummarizeWithoutAgent is Summarize without asking the SSH agent, which can
+// be slow to answer. An existing key is left in KeyUnknown; pass the result of
+// CheckKeyState to SetKeyState once it is known.
+func SummarizeWithoutAgent(dir string) (Summary, error) {
+	return summarize(dir, summarizeOptions{askGit: true})
+}
+
+// Lookup resolves only which profile applies to dir, without running git or
+// asking the SSH agent, for callers such as shell prompts that run on every
+// command. LocalEmail and Effective stay empty and an existing key is left in
+// KeyUnknown.
+func Lookup(dir string) (Summary, error) {
+	return summarize(dir, summarizeOptions{})
+}
+
+// summarizeOptions choose the slow parts of summarize.
+type summarizeOptions struct {
+	// askAgent asks the SSH agent whether the profile's key is loaded.
+	askAgent bool
+	// askGit runs git for the repository-local and effective identity.
+	askGit bool
+}
+
+// summarize resolves the identity for dir.
+func summarize(dir string, opts summarizeOptions) (Summary, error) {
+	s := Summary{
+		Directory: dir,
+		Source:    SourceNone,
+		KeyState:  KeyNone,
+		Signing:   "none",
+	}
+
+	m, err := mapping.GetMappingForDirectory(dir)
+	if err != nil {
+		return s, fmt.Errorf("failed to get mapping: %w", err)
+	}
+
+	manager, err := profile.NewDefaultManager()
+	if err != nil {
+		return s, fmt.Errorf("failed to initialize profile manager: %w", err)
+	}
+
+	var prof *profile.Profile
+	if pinned := mapping.PinnedProfile(dir); pinned != "" {
+		// A profile deleted since it was pinned falls through to the mapping
+		prof, _ = manager.GetProfile(pinned)
+	}
+	if prof != nil {
+		s.Source = SourcePinned
+	} else if m != nil {
+		prof, err = manager.GetProfile(m.Profile)
+		if err != nil {
+			return s, fmt.Errorf("profile not found: %w", err)
+		}
+		s.Source = SourceMapping
+		s.MappedDirectory = m.Directory
+		s.rawCondition = m.RawCondition
+	} else {
+		// Fall back to the default profile, if one is set and still exists
+		cfg, err := config.Load()
+		if err != nil {
+			return s, fmt.Errorf("failed to load config: %w", err)
+		}
+		if cfg.DefaultProfile == "" {
+			return s, nil
+		}
+		if prof, err = manager.GetProfile(cfg.DefaultProfile); err != nil {
+			return s, nil
+		}
+		s.Source = SourceDefault
+	}
+
+	s.Profile = prof
+
+	if prof.SSHKeyPath != "" {
+		if opts.askAgent {
+			s.SetKeyState(CheckKeyState(prof.SSHKeyPath))
+		} else if keyExists(prof.SSHKeyPath) {
+			s.KeyState = KeyUnknown
+		} else {
+			s.KeyState = KeyMissing
+		}
+	}
+	if prof.GPGKeyID != "" {
+		s.Signing = "gpg"
+	}
+	if prof.SSHSigning {
+		s.Signing = "ssh"
+	}
+
+	if !opts.askGit {
+		return s, nil
+	}
+	if email := localConfigEmail(dir); email != "" && email != prof.Email {
+		s.LocalEmail = email
+	}
+
+	if _, ok := utils.FindRepoRoot(dir); ok {
+		s.Effective = checkEffective(dir, s)
+	}
+
+	return s, nil
+}
+
+// checkEffective asks git which identity it uses in dir and explains a
+// mismatch with the profile. It returns nil when git cannot be run.
+func checkEffective(dir string, s Summary) *Effective {
+	email, err := effectiveConfig.Get(dir, "user.email")
+	if err != nil {
+		return nil
+	}
+	name, err := effectiveConfig.Get(dir, "user.name")
+	if err != nil {
+		return nil
+	}
+
+	e := &Effective{Name: name, Email: email}
+	e.Matches = s.Profile.HasEmail(email) && name == s.Profile.GetAuthorName()
+	if !e.Matches {
+		e.Causes = mismatchCauses(s)
+	}
+	return e
+}
+
+// mismatchCauses returns likely reasons why git does not use the profile in s.
+func mismatchCauses(s Summary) []string {
+	if s.LocalEmail != "" {
+		return []string{fmt.Sprintf("the repository's own config sets user.email = %s, which overrides any mapping", s.LocalEmail)}
+	}
+	if s.Source == SourceDefault {
+		return []string{"no mapping covers this directory and git does not read gidtree's default profile; map the directory or run 'gidtree default " + s.Profile.Name + "'"}
+	}
+
+	var causes []string
+	if configPath, err := mapping.GetProfileConfigPath(s.Profile.Name); err == nil {
+		if _, err := os.Stat(configPath); os.IsNotExist(err) {
+			causes = append(causes, fmt.Sprintf("%s is missing; map the directory again to recreate it", utils.AbbreviateHome(configPath)))
+		}
+	}
+	if runtime.GOOS == "windows" && strings.Contains(s.rawCondition, `\`) {
+		causes = append(causes, "the includeIf condition uses backslashes, which git does not match; it needs forward slashes")
+	}
+	if len(causes) == 0 {
+		causes = append(causes,
+			"git did not apply the includeIf block: git before 2.13 ignores gitdir/i conditions",
+			"a [user] section read after the include, for example later in ~/.gitconfig, overrides it")
+	}
+	return causes
+}
+
+// committerLabel describes a profile's separate committer, which only the
+// environment-based commands can apply.
+func committerLabel(p *profile.Profile) string {
+	return fmt.Sprintf("%s <%s> (exec, with and env only)", p.GetCommitterName(), p.GetCommitterEmail())
+}
+
+// Facts returns the summary's details as ordered label/value pairs.
+// The profile name itself is not included; surfaces render it as a heading.
+func (s Summary) Facts() []Fact {
+	if s.Profile == nil {
+		return nil
+	}
+
+	facts := []Fact{
+		{Label: "Email", Value: s.Profile.Email},
+		{Label: "Author", Value: s.Profile.GetAuthorName()},
+	}
+	if s.Profile.HasSeparateCommitter() {
+		facts = append(facts, Fact{Label: "Committer", Value: committerLabel(s.Profile)})
+	}
+
+	switch s.Source {
+	case SourceMapping:
+		facts = append(facts, Fact{Label: "Source", Value: "mapped via " + utils.AbbreviateHome(s.MappedDirectory)})
+	case SourceDefault:
+		facts = append(facts, Fact{Label: "Source", Value: "default, not mapped"})
+	case SourcePinned:
+		facts = append(facts, Fact{Label: "Source", Value: "pinned (local)"})
+	}
+
+	if s.Profile.SSHKeyPath != "" {
+		facts = append(facts, Fact{Label: "SSH Key", Value: fmt.Sprintf("%s (%s)", utils.AbbreviateHome(s.Profile.SSHKeyPath), keyStateLabel(s.KeyState, s.Profile.Name))})
+	}
+
+	if s.Profile.GPGKeyID != "" {
+		facts = append(facts, Fact{Label: "GPG Key", Value: s.Profile.GPGKeyID})
+	}
+
+	if s.LocalEmail != "" {
+		facts = append(facts, Fact{Label: "Local Override", Value: fmt.Sprintf("repository config sets user.email = %s", s.LocalEmail)})
+	}
+
+	if e := s.Effective; e != nil {
+		verdict := "matches"
+		if !e.Matches {
+			verdict = "does not match"
+		}
+		facts = append(facts, Fact{Label: "Git Uses", Value: fmt.Sprintf("%s <%s> (%s)", e.Name, e.Email, verdict)})
+		for _, cause := range e.Causes {
+			facts = append(facts, Fact{Label: "Likely Cause", Value: cause})
+		}
+	}
+
+	return facts
+}
+
+// SetKeyState records the agent state of the profile's key.
+func (s *Summary) SetKeyState(state KeyState) {
+	s.KeyState = state
+	s.SSHKeyLoaded = state == KeyLoaded
+}
+
+// CheckKeyState determines the agent state of an SSH key. It runs ssh-add and
+// may block as long as the agent takes to answer, up to ssh.AgentTimeout.
+func CheckKeyState(keyPath string) KeyState {
+	if !keyExists(keyPath) {
+		return KeyMissing
+	}
+	expanded, _ := utils.ExpandPath(keyPath)
+	loaded, err := checkKeyLoaded(expanded)
+	if errors.Is(err, ssh.ErrAgentUnreachable) {
+		return KeyAgentUnreachable
+	}
+	if errors.Is(err, ssh.ErrOffline) {
+		return KeyOffline
+	}
+	if err != nil || !loaded {
+		return KeyNotLoaded
+	}
+	return KeyLoaded
+}
+
+// keyExists reports whether the key file at keyPath exists.
+func keyExists(keyPath string) bool {
+	expanded, err := utils.ExpandPath(keyPath)
+	if err != nil {
+		return false
+	}
+	_, err = os.Stat(expanded)
+	return err == nil
+}
+
+// keyStateLabel returns a human-readable label for a key state of the named
+// profile, with the command that fixes a key that is not loaded.
+func keyStateLabel(state KeyState, profileName string) string {
+	switch state {
+	case KeyLoaded:
+		return "loaded"
+	case KeyNotLoaded:
+		return "not loaded — run gidtree ssh load " + profileName
+	case KeyMissing:
+		return "missing"
+	case KeyUnknown:
+		return "checking agent…"
+	case KeyAgentUnreachable:
+		return "SSH agent unreachable — check SSH_AUTH_SOCK"
+	case KeyOffline:
+		return "not checked — offline mode"
+	}
+	return "none"
+}
+
+// gitLocalEmail reads user.email from the repository-local config of dir.
+func gitLocalEmail(dir string) string {
+	cmd := utils.Command("git", "-C", dir, "config", "--local", "--get", "user.email")
+	output, err := cmd.Output()
+	logging.Command(cmd, err)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(output))
+}
@@ -0,0 +1,490 @@
+package identity
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/thuanlegit/git-identitree/internal/config"
+	"github.com/thuanlegit/git-identitree/internal/mapping"
+	"github.com/thuanlegit/git-identitree/internal/profile"
+	"github.com/thuanlegit/git-identitree/internal/ssh"
+	"github.com/thuanlegit/git-identitree/internal/utils"
+)
+
+func setupIdentityTestEnv(t *testing.T) string {
+	t.Helper()
+
+	tmpDir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to resolve temp directory: %v", err)
+	}
+
+	// Override home directory for testing on all platforms
+	t.Setenv(utils.RootEnv, tmpDir)
+	t.Setenv("XDG_CONFIG_HOME", "")
+
+	// Stub out external commands
+	originalCheck := checkKeyLoaded
+	originalLocal := localConfigEmail
+	originalEffective := effectiveConfig
+	t.Cleanup(func() {
+		checkKeyLoaded = originalCheck
+		localConfigEmail = originalLocal
+		effectiveConfig = originalEffective
+	})
+	effectiveConfig = fakeGitConfig{}
+	checkKeyLoaded = func(string) (bool, error) { return false, nil }
+	localConfigEmail = func(string) string { return "" }
+
+	return tmpDir
+}
+
+// fakeGitConfig is a GitConfig answering from a map of keys.
+type fakeGitConfig map[string]string
+
+func (f fakeGitConfig) Get(dir, key string) (string, error) {
+	return f[key], nil
+}
+
+func mapTestProfile(t *testing.T, prof profile.Profile, dir string) {
+	t.Helper()
+
+	manager, err := profile.NewDefaultManager()
+	if err != nil {
+		t.Fatalf("NewDefaultManager() error = %v", err)
+	}
+	if err := manager.AddProfile(prof); err != nil {
+		t.Fatalf("AddProfile() error = %v", err)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	if err := mapping.MapProfileToDirectory(&prof, dir); err != nil {
+		t.Fatalf("MapProfileToDirectory() error = %v", err)
+	}
+}
+
+func TestSummarize_Unmapped(t *testing.T) {
+	tmpDir := setupIdentityTestEnv(t)
+
+	s, err := Summarize(tmpDir)
+	if err != nil {
+		t.Fatalf("Summarize() error = %v", err)
+	}
+	if s.Profile != nil {
+		t.Errorf("Summarize() profile = %v, want nil", s.Profile)
+	}
+	if s.Source != SourceNone {
+		t.Errorf("Summarize() source = %v, want %v", s.Source, SourceNone)
+	}
+	if len(s.Facts()) != 0 {
+		t.Errorf("Facts() = %v, want none for unmapped directory", s.Facts())
+	}
+}
+
+func TestSummarize_Mapped(t *testing.T) {
+	tmpDir := setupIdentityTestEnv(t)
+
+	keyPath := filepath.Join(tmpDir, "id_test")
+	if err := os.WriteFile(keyPath, []byte("key"), 0600); err != nil {
+		t.Fatalf("Failed to write key: %v", err)
+	}
+	checkKeyLoaded = func(string) (bool, error) { return true, nil }
+	localConfigEmail = func(string) string { return "other@example.com" }
+
+	projectDir := filepath.Join(tmpDir, "work")
+	mapTestProfile(t, profile.Profile{
+		Name:       "work",
+		Email:      "work@example.com",
+		AuthorName: "Jane Doe",
+		SSHKeyPath: keyPath,
+		GPGKeyID:   "ABC123",
+	}, projectDir)
+
+	s, err := Summarize(filepath.Join(projectDir, "repo"))
+	if err != nil {
+		t.Fatalf("Summarize() error = %v", err)
+	}
+	if s.Profile == nil || s.Profile.Name != "work" {
+		t.Fatalf("Summarize() profile = %v, want work", s.Profile)
+	}
+	if s.Source != SourceMapping {
+		t.Errorf("Source = %v, want %v", s.Source, SourceMapping)
+	}
+	if s.KeyState != KeyLoaded {
+		t.Errorf("KeyState = %v, want %v", s.KeyState, KeyLoaded)
+	}
+	if s.Signing != "gpg" {
+		t.Errorf("Signing = %v, want gpg", s.Signing)
+	}
+	if s.LocalEmail != "other@example.com" {
+		t.Errorf("LocalEmail = %v, want other@example.com", s.LocalEmail)
+	}
+
+	labels := make(map[string]string)
+	for _, f := range s.Facts() {
+		labels[f.Label] = f.Value
+	}
+	for _, label := range []string{"Email", "Author", "Source", "SSH Key", "GPG Key", "Local Override"} {
+		if _, ok := labels[label]; !ok {
+			t.Errorf("Facts() missing %q", label)
+		}
+	}
+	if !strings.Contains(labels["SSH Key"], "(loaded)") {
+		t.Errorf("SSH Key fact = %q, want loaded state", labels["SSH Key"])
+	}
+	if !strings.HasPrefix(labels["Source"], "mapped via ~") {
+		t.Errorf("Source fact = %q, want home-abbreviated directory", labels["Source"])
+	}
+}
+
+func TestSummarize_KeyStates(t *testing.T) {
+	tests := []struct {
+		name      string
+		createKey bool
+		loaded    bool
+		err       error
+		want      KeyState
+	}{
+		{name: "loaded", createKey: true, loaded: true, want: KeyLoaded},
+		{name: "not loaded", createKey: true, loaded: false, want: KeyNotLoaded},
+		{name: "missing", createKey: false, want: KeyMissing},
+		{name: "agent unreachable", createKey: true, err: ssh.ErrAgentUnreachable, want: KeyAgentUnreachable},
+		{name: "offline", createKey: true, err: ssh.ErrOffline, want: KeyOffline},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := setupIdentityTestEnv(t)
+
+			keyPath := filepath.Join(tmpDir, "id_test")
+			if tt.createKey {
+				if err := os.WriteFile(keyPath, []byte("key"), 0600); err != nil {
+					t.Fatalf("Failed to write key: %v", err)
+				}
+			}
+			loaded, err := tt.loaded, tt.err
+			checkKeyLoaded = func(string) (bool, error) { return loaded, err }
+
+			if got := CheckKeyState(keyPath); got != tt.want {
+				t.Errorf("CheckKeyState() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSummarizeWithoutAgent(t *testing.T) {
+	tmpDir := setupIdentityTestEnv(t)
+
+	keyPath := filepath.Join(tmpDir, "id_test")
+	if err := os.WriteFile(keyPath, []byte("key"), 0600); err != nil {
+		t.Fatalf("Failed to write key: %v", err)
+	}
+	asked := false
+	checkKeyLoaded = func(string) (bool, error) { asked = true; return true, nil }
+
+	projectDir := filepath.Join(tmpDir, "work")
+	mapTestProfile(t, profile.Profile{Name: "work", Email: "work@example.com", SSHKeyPath: keyPath}, projectDir)
+
+	s, err := SummarizeWithoutAgent(projectDir)
+	if err != nil {
+		t.Fatalf("SummarizeWithoutAgent() error = %v", err)
+	}
+	if asked {
+		t.Error("SummarizeWithoutAgent() asked the agent")
+	}
+	if s.KeyState != KeyUnknown || s.SSHKeyLoaded {
+		t.Errorf("KeyState = %v, SSHKeyLoaded = %v; want unknown and false", s.KeyState, s.SSHKeyLoaded)
+	}
+
+	s.SetKeyState(CheckKeyState(keyPath))
+	if s.KeyState != KeyLoaded || !s.SSHKeyLoaded {
+		t.Errorf("after SetKeyState: KeyState = %v, SSHKeyLoaded = %v; want loaded and true", s.KeyState, s.SSHKeyLoaded)
+	}
+
+	data, err := json.Marshal(s)
+	if err != nil {
+		t.Fatalf("json.Marshal() error = %v", err)
+	}
+	if !strings.Contains(string(data), `"ssh_key_loaded":true`) {
+		t.Errorf("JSON = %s, want ssh_key_loaded", data)
+	}
+}
+
+func TestLookup(t *testing.T) {
+	tmpDir := setupIdentityTestEnv(t)
+
+	keyPath := filepath.Join(tmpDir, "id_test")
+	if err := os.WriteFile(keyPath, []byte("key"), 0600); err != nil {
+		t.Fatalf("Failed to write key: %v", err)
+	}
+	// Lookup must not run anything
+	checkKeyLoaded = func(string) (bool, error) { t.Error("Lookup() asked the agent"); return true, nil }
+	localConfigEmail = func(string) string { t.Error("Lookup() ran git for the local email"); return "" }
+	effectiveConfig = nil
+
+	projectDir := filepath.Join(tmpDir, "work")
+	mapTestProfile(t, profile.Profile{Name: "work", Email: "work@example.com", SSHKeyPath: keyPath}, projectDir)
+	if err := os.MkdirAll(filepath.Join(projectDir, ".git"), 0755); err != nil {
+		t.Fatalf("Failed to create repository: %v", err)
+	}
+
+	s, err := Lookup(projectDir)
+	if err != nil {
+		t.Fatalf("Lookup() error = %v", err)
+	}
+	if s.Profile == nil || s.Profile.Name != "work" || s.Source != SourceMapping {
+		t.Fatalf("Lookup() = %+v, want profile work through a mapping", s)
+	}
+	if s.KeyState != KeyUnknown || s.Effective != nil {
+		t.Errorf("KeyState = %v, Effective = %+v; want unknown and nil", s.KeyState, s.Effective)
+	}
+}
+
+func TestSummary_Facts_KeyNotLoaded(t *testing.T) {
+	s := Summary{
+		Profile:  &profile.Profile{Name: "work", Email: "work@example.com", SSHKeyPath: "~/.ssh/id_ed25519_work"},
+		Source:   SourceMapping,
+		KeyState: KeyNotLoaded,
+	}
+	for _, f := range s.Facts() {
+		if f.Label == "SSH Key" {
+			if want := "~/.ssh/id_ed25519_work (not loaded — run gidtree ssh load work)"; f.Value != want {
+				t.Errorf("SSH Key fact = %q, want %q", f.Value, want)
+			}
+			return
+		}
+	}
+	t.Error("Facts() missing SSH Key")
+}
+
+func TestSummary_Facts_Committer(t *testing.T) {
+	s := Summary{Profile: &profile.Profile{Name: "work", Email: "work@example.com", AuthorName: "Jane"}, Source: SourceMapping}
+	if hasFact(s.Facts(), "Committer", "") {
+		t.Errorf("Facts() = %v, want no Committer when it is the author", s.Facts())
+	}
+
+	s.Profile.CommitterEmail = "bot@example.com"
+	if !hasFact(s.Facts(), "Committer", "Jane <bot@example.com> (exec, with and env only)") {
+		t.Errorf("Facts() = %v, want the committer", s.Facts())
+	}
+}
+
+func TestSummarize_LocalEmailMatchingProfile(t *testing.T) {
+	tmpDir := setupIdentityTestEnv(t)
+	localConfigEmail = func(string) string { return "work@example.com" }
+
+	projectDir := filepath.Join(tmpDir, "work")
+	mapTestProfile(t, profile.Profile{Name: "work", Email: "work@example.com"}, projectDir)
+
+	s, err := Summarize(projectDir)
+	if err != nil {
+		t.Fatalf("Summarize() error = %v", err)
+	}
+	if s.LocalEmail != "" {
+		t.Errorf("LocalEmail = %q, want empty when it matches the profile", s.LocalEmail)
+	}
+}
+
+func TestSummarize_DefaultProfile(t *testing.T) {
+	tmpDir := setupIdentityTestEnv(t)
+
+	mapTestProfile(t, profile.Profile{Name: "work", Email: "work@example.com"}, filepath.Join(tmpDir, "work"))
+	manager, err := profile.NewDefaultManager()
+	if err != nil {
+		t.Fatalf("NewDefaultManager() error = %v", err)
+	}
+	if err := manager.AddProfile(profile.Profile{Name: "personal", Email: "me@example.com"}); err != nil {
+		t.Fatalf("AddProfile() error = %v", err)
+	}
+	if err := config.SetDefaultProfile("personal"); err != nil {
+		t.Fatalf("SetDefaultProfile() error = %v", err)
+	}
+
+	s, err := Summarize(filepath.Join(tmpDir, "elsewhere"))
+	if err != nil {
+		t.Fatalf("Summarize() error = %v", err)
+	}
+	if s.Profile == nil || s.Profile.Name != "personal" {
+		t.Fatalf("Summarize() profile = %v, want personal", s.Profile)
+	}
+	if s.Source != SourceDefault {
+		t.Errorf("Source = %v, want %v", s.Source, SourceDefault)
+	}
+	found := false
+	for _, f := range s.Facts() {
+		if f.Label == "Source" && f.Value == "default, not mapped" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("Facts() = %v, want Source 'default, not mapped'", s.Facts())
+	}
+
+	// A mapping still wins over the default
+	s, err = Summarize(filepath.Join(tmpDir, "work"))
+	if err != nil {
+		t.Fatalf("Summarize() error = %v", err)
+	}
+	if s.Profile == nil || s.Profile.Name != "work" || s.Source != SourceMapping {
+		t.Errorf("Summarize(mapped) = %v/%v, want work via mapping", s.Profile, s.Source)
+	}
+
+	// A default that no longer exists is ignored
+	if err := config.SetDefaultProfile("gone"); err != nil {
+		t.Fatalf("SetDefaultProfile() error = %v", err)
+	}
+	s, err = Summarize(filepath.Join(tmpDir, "elsewhere"))
+	if err != nil {
+		t.Fatalf("Summarize() error = %v", err)
+	}
+	if s.Profile != nil || s.Source != SourceNone {
+		t.Errorf("Summarize() with missing default = %v/%v, want none", s.Profile, s.Source)
+	}
+}
+
+func TestSummarize_Pinned(t *testing.T) {
+	tmpDir := setupIdentityTestEnv(t)
+
+	repo := filepath.Join(tmpDir, "work", "api")
+	mapTestProfile(t, profile.Profile{Name: "work", Email: "work@example.com"}, filepath.Join(tmpDir, "work"))
+	if err := os.MkdirAll(filepath.Join(repo, ".git"), 0755); err != nil {
+		t.Fatalf("Failed to create repository: %v", err)
+	}
+	manager, err := profile.NewDefaultManager()
+	if err != nil {
+		t.Fatalf("NewDefaultManager() error = %v", err)
+	}
+	client := profile.Profile{Name: "client", Email: "me@client.example"}
+	if err := manager.AddProfile(client); err != nil {
+		t.Fatalf("AddProfile() error = %v", err)
+	}
+	if _, err := mapping.Pin(&client, repo); err != nil {
+		t.Fatalf("Pin() error = %v", err)
+	}
+
+	// The pin overrides the mapping of the parent directory
+	s, err := Lookup(repo)
+	if err != nil {
+		t.Fatalf("Lookup() error = %v", err)
+	}
+	if s.Profile == nil || s.Profile.Name != "client" || s.Source != SourcePinned {
+		t.Fatalf("Lookup() = %v/%v, want client pinned", s.Profile, s.Source)
+	}
+	found := false
+	for _, f := range s.Facts() {
+		if f.Label == "Source" && f.Value == "pinned (local)" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("Facts() = %v, want Source 'pinned (local)'", s.Facts())
+	}
+
+	// A pinned profile that was deleted falls back to the mapping
+	if err := manager.DeleteProfile("client", nil); err != nil {
+		t.Fatalf("DeleteProfile() error = %v", err)
+	}
+	s, err = Lookup(repo)
+	if err != nil {
+		t.Fatalf("Lookup() error = %v", err)
+	}
+	if s.Profile == nil || s.Profile.Name != "work" || s.Source != SourceMapping {
+		t.Errorf("Lookup() after delete = %v/%v, want work via mapping", s.Profile, s.Source)
+	}
+}
+
+func TestSummarize_Effective(t *testing.T) {
+	tmpDir := setupIdentityTestEnv(t)
+
+	repo := filepath.Join(tmpDir, "work", "repo")
+	mapTestProfile(t, profile.Profile{Name: "work", Email: "work@example.com", AuthorName: "Jane Doe"}, filepath.Join(tmpDir, "work"))
+	if err := os.MkdirAll(filepath.Join(repo, ".git"), 0755); err != nil {
+		t.Fatalf("Failed to create repository: %v", err)
+	}
+
+	effectiveConfig = fakeGitConfig{"user.email": "work@example.com", "user.name": "Jane Doe"}
+	s, err := Summarize(repo)
+	if err != nil {
+		t.Fatalf("Summarize() error = %v", err)
+	}
+	if s.Effective == nil || !s.Effective.Matches || len(s.Effective.Causes) != 0 {
+		t.Fatalf("Effective = %+v, want a match", s.Effective)
+	}
+	if !hasFact(s.Facts(), "Git Uses", "(matches)") {
+		t.Errorf("Facts() = %v, want a matching Git Uses fact", s.Facts())
+	}
+
+	// Outside a repository git is not asked
+	if s, _ := Summarize(filepath.Join(tmpDir, "work")); s.Effective != nil {
+		t.Errorf("Effective outside a repository = %+v, want nil", s.Effective)
+	}
+
+	// The profile config file disappeared, so git falls back to the global identity
+	effectiveConfig = fakeGitConfig{"user.email": "me@example.com", "user.name": "Jane Doe"}
+	configPath, err := mapping.GetProfileConfigPath("work")
+	if err != nil {
+		t.Fatalf("GetProfileConfigPath() error = %v", err)
+	}
+	if err := os.Remove(configPath); err != nil {
+		t.Fatalf("Failed to remove profile config: %v", err)
+	}
+	s, err = Summarize(repo)
+	if err != nil {
+		t.Fatalf("Summarize() error = %v", err)
+	}
+	if s.Effective == nil || s.Effective.Matches {
+		t.Fatalf("Effective = %+v, want a mismatch", s.Effective)
+	}
+	if len(s.Effective.Causes) != 1 || !strings.Contains(s.Effective.Causes[0], ".gitconfig-work is missing") {
+		t.Errorf("Causes = %v, want the missing profile config", s.Effective.Causes)
+	}
+	if !hasFact(s.Facts(), "Git Uses", "does not match") || !hasFact(s.Facts(), "Likely Cause", "missing") {
+		t.Errorf("Facts() = %v, want the mismatch and its cause", s.Facts())
+	}
+
+	// A repository-local email explains the mismatch
+	localConfigEmail = func(string) string { return "me@example.com" }
+	s, _ = Summarize(repo)
+	if s.Effective == nil || len(s.Effective.Causes) != 1 || !strings.Contains(s.Effective.Causes[0], "repository's own config") {
+		t.Errorf("Effective = %+v, want the local override as the cause", s.Effective)
+	}
+}
+
+func TestSummarize_EffectiveDefaultProfile(t *testing.T) {
+	tmpDir := setupIdentityTestEnv(t)
+
+	manager, err := profile.NewDefaultManager()
+	if err != nil {
+		t.Fatalf("NewDefaultManager() error = %v", err)
+	}
+	if err := manager.AddProfile(profile.Profile{Name: "personal", Email: "me@example.com"}); err != nil {
+		t.Fatalf("AddProfile() error = %v", err)
+	}
+	if err := config.SetDefaultProfile("personal"); err != nil {
+		t.Fatalf("SetDefaultProfile() error = %v", err)
+	}
+	if err := os.MkdirAll(filepath.Join(tmpDir, "repo", ".git"), 0755); err != nil {
+		t.Fatalf("Failed to create repository: %v", err)
+	}
+
+	s, err := Summarize(filepath.Join(tmpDir, "repo"))
+	if err != nil {
+		t.Fatalf("Summarize() error = %v", err)
+	}
+	if s.Effective == nil || s.Effective.Matches || !strings.Contains(strings.Join(s.Effective.Causes, " "), "gidtree default personal") {
+		t.Errorf("Effective = %+v, want a mismatch pointing at 'gidtree default'", s.Effective)
+	}
+}
+
+func hasFact(facts []Fact, label, contains string) bool {
+	for _, f := range facts {
+		if f.Label == label && strings.Contains(f.Value, contains) {
+			return true
+		}
+	}
+	return false
+}
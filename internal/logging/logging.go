@@ -0,0 +1,130 @@
+// Package logging provides the debug logger enabled by the --verbose flag.
+// It discards everything until Enable is called.
+package logging
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"os/exec"
+	"strings"
+	"sync"
+
+	"github.com/thuanlegit/git-identitree/internal/utils"
+)
+
+// recentLimit is how many log lines Recent keeps.
+const recentLimit = 100
+
+var (
+	logger  = slog.New(slog.DiscardHandler)
+	output  = &pausableWriter{}
+	enabled bool
+)
+
+// Logger returns the debug logger.
+func Logger() *slog.Logger {
+	return logger
+}
+
+// Enable sends debug logs to w as structured text.
+func Enable(w io.Writer) {
+	output.mu.Lock()
+	output.w = w
+	output.recent = nil
+	output.mu.Unlock()
+	logger = slog.New(slog.NewTextHandler(output, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	enabled = true
+}
+
+// Disable discards debug logs again.
+func Disable() {
+	logger = slog.New(slog.DiscardHandler)
+	enabled = false
+}
+
+// Enabled reports whether debug logs are being written.
+func Enabled() bool {
+	return enabled
+}
+
+// Recent returns the last log lines written since Enable, oldest first, so
+// they can be attached to a debug report.
+func Recent() []string {
+	output.mu.Lock()
+	defer output.mu.Unlock()
+	return append([]string(nil), output.recent...)
+}
+
+// Pause holds back log output until the returned resume function is called,
+// so logs never draw over a full-screen UI. Held logs are written on resume.
+func Pause() (resume func()) {
+	output.mu.Lock()
+	output.paused = true
+	output.mu.Unlock()
+
+	return func() {
+		output.mu.Lock()
+		defer output.mu.Unlock()
+		output.paused = false
+		if output.w != nil && output.held.Len() > 0 {
+			_, _ = output.w.Write(output.held.Bytes())
+		}
+		output.held.Reset()
+	}
+}
+
+// Command logs an external command after it ran, with home directory paths
+// in its arguments abbreviated and the exit code it finished with.
+func Command(cmd *exec.Cmd, err error) {
+	exitCode := -1
+	if cmd.ProcessState != nil {
+		exitCode = cmd.ProcessState.ExitCode()
+	}
+	attrs := []any{"cmd", cmd.Path, "args", SanitizeArgs(cmd.Args[1:]), "exit_code", exitCode}
+	if err != nil {
+		attrs = append(attrs, "error", err)
+	}
+	logger.Debug("ran command", attrs...)
+}
+
+// SanitizeArgs abbreviates the home directory in command arguments to ~ so
+// logs can be shared without revealing the local user name.
+func SanitizeArgs(args []string) []string {
+	home, err := utils.Root()
+	sanitized := make([]string, len(args))
+	for i, arg := range args {
+		if err == nil && home != "" {
+			arg = strings.ReplaceAll(arg, home, "~")
+		}
+		sanitized[i] = arg
+	}
+	return sanitized
+}
+
+// pausableWriter forwards writes to w, or buffers them while paused. It
+// remembers the last recentLimit lines either way.
+type pausableWriter struct {
+	mu     sync.Mutex
+	w      io.Writer
+	paused bool
+	held   bytes.Buffer
+	recent []string
+}
+
+func (p *pausableWriter) Write(b []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	// The handler writes one record per call
+	p.recent = append(p.recent, strings.TrimRight(string(b), "\n"))
+	if len(p.recent) > recentLimit {
+		p.recent = p.recent[len(p.recent)-recentLimit:]
+	}
+	if p.paused {
+		return p.held.Write(b)
+	}
+	if p.w == nil {
+		return len(b), nil
+	}
+	return p.w.Write(b)
+}
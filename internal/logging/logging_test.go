@@ -0,0 +1,97 @@
+package logging
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/thuanlegit/git-identitree/internal/utils"
+)
+
+func TestEnableDisable(t *testing.T) {
+	var buf bytes.Buffer
+	Enable(&buf)
+	defer Disable()
+
+	Logger().Debug("wrote file", "path", "/tmp/x", "bytes", 12)
+	if got := buf.String(); !strings.Contains(got, "level=DEBUG") || !strings.Contains(got, "bytes=12") {
+		t.Errorf("Enable() output = %q, want a structured debug record", got)
+	}
+
+	if !Enabled() {
+		t.Error("Enabled() = false after Enable")
+	}
+	Disable()
+	if Enabled() {
+		t.Error("Enabled() = true after Disable")
+	}
+	buf.Reset()
+	Logger().Debug("dropped")
+	if buf.Len() != 0 {
+		t.Errorf("Disable() should discard logs, got %q", buf.String())
+	}
+}
+
+func TestPause(t *testing.T) {
+	var buf bytes.Buffer
+	Enable(&buf)
+	defer Disable()
+
+	resume := Pause()
+	Logger().Debug("during ui")
+	if buf.Len() != 0 {
+		t.Fatalf("logs written while paused: %q", buf.String())
+	}
+
+	resume()
+	if !strings.Contains(buf.String(), "during ui") {
+		t.Errorf("held logs should be written on resume, got %q", buf.String())
+	}
+
+	Logger().Debug("after ui")
+	if !strings.Contains(buf.String(), "after ui") {
+		t.Errorf("logs after resume should be written directly, got %q", buf.String())
+	}
+}
+
+func TestRecent(t *testing.T) {
+	var buf bytes.Buffer
+	Enable(&buf)
+	defer Disable()
+
+	for i := range recentLimit + 5 {
+		Logger().Debug("step", "n", i)
+	}
+	recent := Recent()
+	if len(recent) != recentLimit {
+		t.Fatalf("Recent() has %d lines, want %d", len(recent), recentLimit)
+	}
+	if !strings.HasSuffix(recent[0], "n=5") || !strings.HasSuffix(recent[len(recent)-1], "n=104") {
+		t.Errorf("Recent() = %q ... %q, want the last %d lines", recent[0], recent[len(recent)-1], recentLimit)
+	}
+
+	Enable(&buf)
+	if got := Recent(); len(got) != 0 {
+		t.Errorf("Recent() after Enable = %q, want none", got)
+	}
+}
+
+func TestCommand(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv(utils.RootEnv, home)
+
+	var buf bytes.Buffer
+	Enable(&buf)
+	defer Disable()
+
+	cmd := utils.Command("git", "--version", home+"/.ssh/id_work")
+	Command(cmd, nil)
+
+	got := buf.String()
+	if strings.Contains(got, home) {
+		t.Errorf("Command() should abbreviate the home directory, got %q", got)
+	}
+	if !strings.Contains(got, "~/.ssh/id_work") || !strings.Contains(got, "exit_code=-1") {
+		t.Errorf("Command() output = %q, want sanitized args and exit code", got)
+	}
+}
@@ -0,0 +1,77 @@
+package manifest
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/thuanlegit/git-identitree/internal/mapping"
+	"github.com/thuanlegit/git-identitree/internal/profile"
+	"github.com/thuanlegit/git-identitree/internal/utils"
+	"gopkg.in/yaml.v3"
+)
+
+// Export gathers the profiles and directory mappings of this machine into a
+// manifest that Apply accepts. Paths under the home directory are written
+// with ~ so the manifest works for another user name. Profiles that are
+// mapped but missing from profiles.yaml are included as stubs with only a
+// name; their names are returned as well.
+func Export() (*Manifest, []string, error) {
+	manager, err := profile.NewDefaultManager()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to initialize profile manager: %w", err)
+	}
+	mappings, err := mapping.ParseMappings()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse mappings: %w", err)
+	}
+
+	m := &Manifest{Profiles: []Entry{}}
+	index := make(map[string]int)
+	for _, p := range manager.ListProfiles() {
+		e := Entry{Profile: p.Clone()}
+		e.SSHKeyPath = utils.AbbreviateHome(e.SSHKeyPath)
+		index[p.Name] = len(m.Profiles)
+		m.Profiles = append(m.Profiles, e)
+	}
+
+	var stubs []string
+	for _, mp := range mappings {
+		if !mp.HasDirectory() {
+			continue
+		}
+		i, ok := index[mp.Profile]
+		if !ok {
+			i = len(m.Profiles)
+			index[mp.Profile] = i
+			m.Profiles = append(m.Profiles, Entry{Profile: profile.Profile{Name: mp.Profile}})
+			stubs = append(stubs, mp.Profile)
+		}
+		dir := utils.AbbreviateHome(filepath.ToSlash(strings.TrimSuffix(mp.Directory, "/")))
+		m.Profiles[i].Mappings = append(m.Profiles[i].Mappings, dir)
+	}
+	return m, stubs, nil
+}
+
+// Marshal encodes m as YAML, with a warning comment above each stub profile
+// so it is filled in before the manifest is applied.
+func Marshal(m *Manifest, stubs []string) ([]byte, error) {
+	var doc yaml.Node
+	if err := doc.Encode(m); err != nil {
+		return nil, err
+	}
+
+	isStub := make(map[string]bool, len(stubs))
+	for _, name := range stubs {
+		isStub[name] = true
+	}
+	// doc is a mapping whose only value is the profiles sequence
+	if len(doc.Content) == 2 {
+		for i, item := range doc.Content[1].Content {
+			if name := m.Profiles[i].Name; isStub[name] {
+				item.HeadComment = fmt.Sprintf("WARNING: profile '%s' is mapped but missing from profiles.yaml; add its email before applying", name)
+			}
+		}
+	}
+	return yaml.Marshal(&doc)
+}
@@ -0,0 +1,113 @@
+package manifest
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/thuanlegit/git-identitree/internal/mapping"
+	"github.com/thuanlegit/git-identitree/internal/profile"
+	"github.com/thuanlegit/git-identitree/pkg/gidtree/gidtreetest"
+	"gopkg.in/yaml.v3"
+)
+
+func exportYAML(t *testing.T) string {
+	t.Helper()
+	m, stubs, err := Export()
+	if err != nil {
+		t.Fatalf("Export() error = %v", err)
+	}
+	data, err := Marshal(m, stubs)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	return string(data)
+}
+
+func TestExport_RoundTrip(t *testing.T) {
+	keyDir := t.TempDir()
+	keyPath := filepath.Join(keyDir, "id_work")
+	if err := os.WriteFile(keyPath, []byte("key"), 0600); err != nil {
+		t.Fatalf("Failed to write key: %v", err)
+	}
+	env := gidtreetest.NewEnv(t).
+		WithProfile(profile.Profile{Name: "work", Email: "me@work.example", AuthorName: "Jane Doe", SSHKeyPath: keyPath, RemotePatterns: []string{"github.com/acme"}}).
+		WithProfile(profile.Profile{Name: "personal", Email: "me@example.com"}).
+		WithMapping("work", "code/work").
+		WithMapping("work", "code/clients").
+		WithMapping("personal", "code/oss").
+		Build()
+
+	first := exportYAML(t)
+	if strings.Contains(first, env.Home()) {
+		t.Errorf("export should abbreviate the home directory:\n%s", first)
+	}
+	if !strings.Contains(first, "- ~/code/work") {
+		t.Errorf("export should list ~/code/work:\n%s", first)
+	}
+
+	// Wipe the machine and rebuild it from the manifest
+	for _, path := range []string{env.ProfilesPath(), env.GitConfigPath(), env.FragmentPath("work"), env.FragmentPath("personal")} {
+		if err := os.Remove(path); err != nil {
+			t.Fatalf("Failed to remove %s: %v", path, err)
+		}
+	}
+	mapping.Invalidate()
+
+	var m Manifest
+	if err := yaml.Unmarshal([]byte(first), &m); err != nil {
+		t.Fatalf("exported manifest does not parse: %v", err)
+	}
+	if err := m.Validate(); err != nil {
+		t.Fatalf("exported manifest is invalid: %v", err)
+	}
+	actions, err := Plan(&m, PlanOptions{})
+	if err != nil {
+		t.Fatalf("Plan() error = %v", err)
+	}
+	if _, err := Apply(actions); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+
+	if second := exportYAML(t); second != first {
+		t.Errorf("round trip changed the manifest:\nfirst:\n%s\nsecond:\n%s", first, second)
+	}
+	if actions, _ := Plan(&m, PlanOptions{Update: true}); len(actions) != 0 {
+		t.Errorf("Plan() after the round trip = %v, want nothing", actions)
+	}
+}
+
+func TestExport_StubProfile(t *testing.T) {
+	gidtreetest.NewEnv(t).
+		WithProfile(profile.Profile{Name: "work", Email: "me@work.example"}).
+		WithProfile(profile.Profile{Name: "gone", Email: "gone@example.com"}).
+		WithMapping("gone", "code/old").
+		Build()
+	if err := profile.SaveProfiles([]profile.Profile{{Name: "work", Email: "me@work.example"}}); err != nil {
+		t.Fatalf("SaveProfiles() error = %v", err)
+	}
+
+	m, stubs, err := Export()
+	if err != nil {
+		t.Fatalf("Export() error = %v", err)
+	}
+	if len(stubs) != 1 || stubs[0] != "gone" {
+		t.Fatalf("Export() stubs = %v, want [gone]", stubs)
+	}
+	last := m.Profiles[len(m.Profiles)-1]
+	if last.Name != "gone" || last.Email != "" || len(last.Mappings) != 1 {
+		t.Errorf("stub = %+v", last)
+	}
+
+	data, err := Marshal(m, stubs)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	if !strings.Contains(string(data), "# WARNING: profile 'gone' is mapped but missing from profiles.yaml") {
+		t.Errorf("Marshal() should warn above the stub:\n%s", data)
+	}
+	if strings.Count(string(data), "WARNING") != 1 {
+		t.Errorf("Marshal() should only warn about the stub:\n%s", data)
+	}
+}
@@ -0,0 +1,280 @@
+// Package manifest declares profiles and their directory mappings in one
+// YAML file, for setting up a machine with 'gidtree apply'.
+package manifest
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"time"
+
+	"github.com/thuanlegit/git-identitree/internal/config"
+	"github.com/thuanlegit/git-identitree/internal/mapping"
+	"github.com/thuanlegit/git-identitree/internal/profile"
+	"github.com/thuanlegit/git-identitree/internal/utils"
+	"gopkg.in/yaml.v3"
+)
+
+// Manifest lists profiles and the directories mapped to each.
+type Manifest struct {
+	Profiles []Entry `yaml:"profiles"`
+}
+
+// Entry is a profile with the directories mapped to it.
+type Entry struct {
+	profile.Profile `yaml:",inline"`
+	Mappings        []string `yaml:"mappings,omitempty"`
+}
+
+// Load reads a manifest from path and validates it.
+func Load(path string) (*Manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+	var m Manifest
+	if err := yaml.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("failed to parse manifest %s: %w", path, err)
+	}
+	if err := m.Validate(); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// Validate checks that every profile has a name and an email and appears
+// only once.
+func (m *Manifest) Validate() error {
+	seen := make(map[string]bool)
+	for i, e := range m.Profiles {
+		if e.Name == "" {
+			return fmt.Errorf("manifest profile #%d has no name", i+1)
+		}
+		if e.Email == "" {
+			return fmt.Errorf("manifest profile '%s' has no email", e.Name)
+		}
+		if seen[e.Name] {
+			return fmt.Errorf("manifest lists profile '%s' more than once", e.Name)
+		}
+		seen[e.Name] = true
+		if err := profile.ValidateRemotePatterns(e.RemotePatterns); err != nil {
+			return fmt.Errorf("manifest profile '%s': %w", e.Name, err)
+		}
+	}
+	return nil
+}
+
+// ActionKind is what an Action does.
+type ActionKind string
+
+const (
+	// CreateProfile adds a profile that does not exist yet.
+	CreateProfile ActionKind = "create profile"
+	// UpdateProfile replaces a profile whose settings differ from the manifest.
+	UpdateProfile ActionKind = "update profile"
+	// CreateMapping maps a directory that is not mapped yet.
+	CreateMapping ActionKind = "map"
+	// SkipProfile is a profile that differs but is left alone without --update.
+	SkipProfile ActionKind = "skip profile"
+	// SkipMapping is a directory already mapped to another profile.
+	SkipMapping ActionKind = "skip mapping"
+)
+
+// Action is one step of a plan.
+type Action struct {
+	Kind    ActionKind
+	Profile string
+	// Directory is the normalized directory of mapping actions.
+	Directory string
+	// Reason explains skipped actions.
+	Reason string
+
+	entry *Entry
+}
+
+// Changes reports whether the action changes anything when applied.
+func (a Action) Changes() bool {
+	return a.Kind == CreateProfile || a.Kind == UpdateProfile || a.Kind == CreateMapping
+}
+
+// String describes the action for the plan.
+func (a Action) String() string {
+	switch a.Kind {
+	case CreateMapping:
+		return fmt.Sprintf("map %s to profile '%s'", a.Directory, a.Profile)
+	case SkipMapping:
+		return fmt.Sprintf("skip %s: %s", a.Directory, a.Reason)
+	case SkipProfile:
+		return fmt.Sprintf("skip profile '%s': %s", a.Profile, a.Reason)
+	}
+	return fmt.Sprintf("%s '%s'", a.Kind, a.Profile)
+}
+
+// PlanOptions choose what Plan may change.
+type PlanOptions struct {
+	// Update replaces existing profiles whose settings differ from the manifest.
+	Update bool
+}
+
+// Plan compares the manifest with the profiles and mappings on this machine
+// and returns the actions that would bring them in line. Existing profiles and
+// mappings are never removed. A manifest that has been applied plans no
+// changes.
+func Plan(m *Manifest, opts PlanOptions) ([]Action, error) {
+	manager, err := profile.NewDefaultManager()
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize profile manager: %w", err)
+	}
+	existing, err := mapping.ParseMappings()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse existing mappings: %w", err)
+	}
+	mappedTo := make(map[string]string, len(existing))
+	for _, em := range existing {
+		if em.HasDirectory() {
+			mappedTo[em.Directory] = em.Profile
+		}
+	}
+
+	var actions []Action
+	for i := range m.Profiles {
+		e := &m.Profiles[i]
+		current, err := manager.GetProfile(e.Name)
+		switch {
+		case errors.Is(err, profile.ErrProfileNotFound):
+			actions = append(actions, Action{Kind: CreateProfile, Profile: e.Name, entry: e})
+		case err != nil:
+			return nil, err
+		case !sameProfile(*current, e.Profile):
+			if opts.Update {
+				actions = append(actions, Action{Kind: UpdateProfile, Profile: e.Name, entry: e})
+			} else {
+				actions = append(actions, Action{Kind: SkipProfile, Profile: e.Name, Reason: "differs from the manifest; use --update to replace it"})
+			}
+		}
+
+		for _, dir := range e.Mappings {
+			normalized, err := utils.NormalizePath(dir)
+			if err != nil {
+				return nil, fmt.Errorf("failed to normalize directory path '%s': %w", dir, err)
+			}
+			normalized = utils.EnsureTrailingSlash(normalized)
+			switch owner, ok := mappedTo[normalized]; {
+			case !ok:
+				actions = append(actions, Action{Kind: CreateMapping, Profile: e.Name, Directory: normalized, entry: e})
+				// A directory listed twice is mapped once
+				mappedTo[normalized] = e.Name
+			case owner != e.Name:
+				actions = append(actions, Action{Kind: SkipMapping, Profile: e.Name, Directory: normalized, Reason: fmt.Sprintf("already mapped to profile '%s'", owner)})
+			}
+		}
+	}
+	return actions, nil
+}
+
+// sameProfile reports whether two profiles have the same settings. A nil
+// and an empty pattern, alias or co-author list are the same, and so are SSH key paths that only
+// differ in how the home directory is written. Creation and last-use times
+// and the recorded key fingerprint are ignored.
+func sameProfile(a, b profile.Profile) bool {
+	a.CreatedAt, b.CreatedAt = time.Time{}, time.Time{}
+	a.LastUsed, b.LastUsed = time.Time{}, time.Time{}
+	a.SSHKeyFingerprint, b.SSHKeyFingerprint = "", ""
+	if len(a.RemotePatterns) == 0 && len(b.RemotePatterns) == 0 {
+		a.RemotePatterns, b.RemotePatterns = nil, nil
+	}
+	if len(a.EmailAliases) == 0 && len(b.EmailAliases) == 0 {
+		a.EmailAliases, b.EmailAliases = nil, nil
+	}
+	if len(a.CoAuthors) == 0 && len(b.CoAuthors) == 0 {
+		a.CoAuthors, b.CoAuthors = nil, nil
+	}
+	if expanded, err := utils.ExpandPath(a.SSHKeyPath); err == nil {
+		a.SSHKeyPath = expanded
+	}
+	if expanded, err := utils.ExpandPath(b.SSHKeyPath); err == nil {
+		b.SSHKeyPath = expanded
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// Apply carries out the actions of a plan in order. A failure does not stop
+// the actions after it; the first one is returned. It returns the number of
+// changes made.
+func Apply(actions []Action) (int, error) {
+	return ApplyEach(actions, nil)
+}
+
+// ApplyEach is Apply, calling done after each action with its error, if any,
+// so progress can be shown. Actions that change nothing are passed to done
+// too, with a nil error.
+func ApplyEach(actions []Action, done func(Action, error)) (int, error) {
+	manager, err := profile.NewDefaultManager()
+	if err != nil {
+		return 0, fmt.Errorf("failed to initialize profile manager: %w", err)
+	}
+	cfg, err := config.Load()
+	if err != nil {
+		return 0, fmt.Errorf("failed to load config: %w", err)
+	}
+
+	changed := 0
+	var first error
+	for _, a := range actions {
+		var err error
+		if a.Changes() {
+			err = applyAction(manager, cfg, a)
+			if err == nil {
+				changed++
+			} else if first == nil {
+				first = err
+			}
+		}
+		if done != nil {
+			done(a, err)
+		}
+	}
+	return changed, first
+}
+
+// applyAction carries out a, an action that changes something.
+func applyAction(manager *profile.Manager, cfg *config.Config, a Action) error {
+	switch a.Kind {
+	case CreateProfile:
+		if err := manager.AddProfile(a.entry.Profile.Clone()); err != nil {
+			return fmt.Errorf("failed to create profile '%s': %w", a.Profile, err)
+		}
+	case UpdateProfile:
+		if err := manager.UpdateProfile(a.Profile, a.entry.Profile.Clone()); err != nil {
+			return fmt.Errorf("failed to update profile '%s': %w", a.Profile, err)
+		}
+		// A mapped profile's generated config must follow
+		if _, err := mapping.SyncProfileConfig(&a.entry.Profile); err != nil {
+			return fmt.Errorf("failed to update git config of profile '%s': %w", a.Profile, err)
+		}
+	case CreateMapping:
+		prof, err := manager.GetProfile(a.Profile)
+		if err != nil {
+			return err
+		}
+		// The checkouts of a new machine may not exist yet, and a broad
+		// directory in a manifest was written there on purpose
+		opts := mapping.MapOptions{CaseSensitive: cfg.CaseSensitiveGitdir, AllowMissing: true, AllowBroad: true}
+		if err := mapping.MapProfileToDirectoryWithOptions(prof, a.Directory, opts); err != nil {
+			return fmt.Errorf("failed to map %s: %w", a.Directory, err)
+		}
+	}
+	return nil
+}
+
+// CountChanges returns how many actions change something.
+func CountChanges(actions []Action) int {
+	n := 0
+	for _, a := range actions {
+		if a.Changes() {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,159 @@
+package manifest
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/thuanlegit/git-identitree/internal/mapping"
+	"github.com/thuanlegit/git-identitree/internal/profile"
+	"github.com/thuanlegit/git-identitree/pkg/gidtree/gidtreetest"
+)
+
+const testManifest = `profiles:
+  - name: work
+    email: me@work.example
+    author_name: Jane Doe
+    remote_patterns: [github.com/acme]
+    mappings:
+      - ~/code/work
+      - ~/code/clients
+  - name: personal
+    email: me@example.com
+    mappings:
+      - ~/code/oss
+`
+
+func writeManifest(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "manifest.yaml")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write manifest: %v", err)
+	}
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	m, err := Load(writeManifest(t, testManifest))
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if len(m.Profiles) != 2 {
+		t.Fatalf("Load() = %d profiles, want 2", len(m.Profiles))
+	}
+	work := m.Profiles[0]
+	if work.Name != "work" || work.AuthorName != "Jane Doe" || len(work.RemotePatterns) != 1 || len(work.Mappings) != 2 {
+		t.Errorf("Load() work = %+v", work)
+	}
+}
+
+func TestLoad_Invalid(t *testing.T) {
+	for name, content := range map[string]string{
+		"no name":   "profiles:\n  - email: a@example.com\n",
+		"no email":  "profiles:\n  - name: work\n",
+		"duplicate": "profiles:\n  - name: work\n    email: a@example.com\n  - name: work\n    email: b@example.com\n",
+		"pattern":   "profiles:\n  - name: work\n    email: a@example.com\n    remote_patterns: ['[']\n",
+		"yaml":      "profiles: [",
+	} {
+		if _, err := Load(writeManifest(t, content)); err == nil {
+			t.Errorf("%s: Load() error = nil, want an error", name)
+		}
+	}
+}
+
+func TestPlanApply_Idempotent(t *testing.T) {
+	env := gidtreetest.NewEnv(t).Build()
+	m, err := Load(writeManifest(t, testManifest))
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	actions, err := Plan(m, PlanOptions{})
+	if err != nil {
+		t.Fatalf("Plan() error = %v", err)
+	}
+	if got := CountChanges(actions); got != 5 {
+		t.Fatalf("Plan() = %d changes, want 2 profiles and 3 mappings: %v", got, actions)
+	}
+	var done []Action
+	applied, err := ApplyEach(actions, func(a Action, err error) {
+		if err != nil {
+			t.Errorf("ApplyEach() %s error = %v", a, err)
+		}
+		done = append(done, a)
+	})
+	if err != nil {
+		t.Fatalf("ApplyEach() error = %v", err)
+	}
+	if applied != 5 || len(done) != len(actions) {
+		t.Errorf("ApplyEach() = %d, reported %d actions; want 5 and %d", applied, len(done), len(actions))
+	}
+
+	m2, err := mapping.GetMappingForDirectory(env.Path("code/clients/acme"))
+	if err != nil || m2 == nil || m2.Profile != "work" {
+		t.Errorf("GetMappingForDirectory() = %+v, %v; want work", m2, err)
+	}
+
+	// The second run has nothing to do, even with --update
+	for _, opts := range []PlanOptions{{}, {Update: true}} {
+		actions, err = Plan(m, opts)
+		if err != nil {
+			t.Fatalf("Plan() error = %v", err)
+		}
+		if len(actions) != 0 {
+			t.Errorf("Plan(%+v) after Apply() = %v, want no actions", opts, actions)
+		}
+	}
+}
+
+func TestPlan_UpdateAndConflicts(t *testing.T) {
+	env := gidtreetest.NewEnv(t).
+		WithProfile(profile.Profile{Name: "work", Email: "old@work.example"}).
+		WithProfile(profile.Profile{Name: "other", Email: "other@example.com"}).
+		WithMapping("other", "code/oss").
+		Build()
+	m, err := Load(writeManifest(t, testManifest))
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	actions, err := Plan(m, PlanOptions{})
+	if err != nil {
+		t.Fatalf("Plan() error = %v", err)
+	}
+	kinds := map[ActionKind]int{}
+	for _, a := range actions {
+		kinds[a.Kind]++
+	}
+	if kinds[SkipProfile] != 1 || kinds[UpdateProfile] != 0 || kinds[SkipMapping] != 1 {
+		t.Errorf("Plan() without --update = %v", actions)
+	}
+
+	actions, err = Plan(m, PlanOptions{Update: true})
+	if err != nil {
+		t.Fatalf("Plan() error = %v", err)
+	}
+	if _, err := Apply(actions); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+
+	manager, err := profile.NewDefaultManager()
+	if err != nil {
+		t.Fatalf("NewDefaultManager() error = %v", err)
+	}
+	work, err := manager.GetProfile("work")
+	if err != nil || work.Email != "me@work.example" {
+		t.Errorf("work = %+v, %v; want the manifest's email", work, err)
+	}
+	// The conflicting mapping keeps its profile
+	oss, err := mapping.GetMappingForDirectory(env.Path("code/oss"))
+	if err != nil || oss == nil || oss.Profile != "other" {
+		t.Errorf("code/oss mapping = %+v, %v; want other", oss, err)
+	}
+	// The updated profile's generated config follows
+	data, err := os.ReadFile(env.FragmentPath("work"))
+	if err != nil || !strings.Contains(string(data), "me@work.example") {
+		t.Errorf("work config = %q, %v; want the new email", data, err)
+	}
+}
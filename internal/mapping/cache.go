@@ -0,0 +1,94 @@
+package mapping
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/thuanlegit/git-identitree/internal/cloudsync"
+)
+
+// racyWindow is how recently a git config may have been modified and still be
+// served from the cache. File timestamps are coarse, so a rewrite within the
+// same tick can keep both mtime and size; like git's racy-clean check, files
+// modified this recently are always read again.
+const racyWindow = 2 * time.Second
+
+// mappingCache holds the mappings parsed from each git config file gidtree
+// reads, keyed by path and checked against the file's modification time and
+// size. Files inside cloud-synced folders are never cached: sync clients
+// rewrite them and can keep both.
+type mappingCache struct {
+	mu      sync.RWMutex
+	entries map[string]cacheEntry
+}
+
+// cacheEntry is the cached state of one file.
+type cacheEntry struct {
+	modTime  time.Time
+	size     int64
+	mappings []Mapping
+}
+
+var cache mappingCache
+
+// fileReads counts how often ParseMappings actually read a git config.
+var fileReads atomic.Int64
+
+// get returns a copy of the cached mappings if they were parsed from path
+// and the file described by info has not changed since.
+func (c *mappingCache) get(path string, info os.FileInfo) ([]Mapping, bool) {
+	if inSyncedFolder(path) {
+		return nil, false
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	e, ok := c.entries[path]
+	if !ok || !e.modTime.Equal(info.ModTime()) || e.size != info.Size() {
+		return nil, false
+	}
+	return append([]Mapping(nil), e.mappings...), true
+}
+
+// put caches mappings parsed from the file at path described by info.
+func (c *mappingCache) put(path string, info os.FileInfo, mappings []Mapping) {
+	if time.Since(info.ModTime()) < racyWindow || inSyncedFolder(path) {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.entries == nil {
+		c.entries = make(map[string]cacheEntry)
+	}
+	c.entries[path] = cacheEntry{
+		modTime:  info.ModTime(),
+		size:     info.Size(),
+		mappings: append([]Mapping(nil), mappings...),
+	}
+}
+
+// inSyncedFolder reports whether the file at path, after resolving symlinks,
+// lies inside a cloud-synced folder. A ~/.gitconfig symlinked into Dropbox is
+// rewritten by the sync client like any other file there.
+func inSyncedFolder(path string) bool {
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+	return cloudsync.IsSynced(path)
+}
+
+// Invalidate drops the cached mappings so the next ParseMappings reads the git
+// configs again. gidtree's own writes call it; other code that edits
+// ~/.gitconfig in place should too.
+func Invalidate() {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	cache.entries = nil
+}
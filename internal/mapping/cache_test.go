@@ -0,0 +1,219 @@
+package mapping
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/thuanlegit/git-identitree/internal/profile"
+	"github.com/thuanlegit/git-identitree/internal/utils"
+)
+
+// writeAgedGitConfig writes content to path with a modification time old
+// enough for the cache to trust it.
+func writeAgedGitConfig(t *testing.T, path, content string, age time.Duration) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write git config: %v", err)
+	}
+	old := time.Now().Add(-age)
+	if err := os.Chtimes(path, old, old); err != nil {
+		t.Fatalf("Failed to age git config: %v", err)
+	}
+}
+
+func TestParseMappings_Cache(t *testing.T) {
+	_, gitConfigPath, cleanup := setupMappingTestEnv(t)
+	defer cleanup()
+	Invalidate()
+
+	writeAgedGitConfig(t, gitConfigPath, "[includeIf \"gitdir/i:/work/\"]\n    path = ~/.gitconfig-work\n", time.Hour)
+
+	before := fileReads.Load()
+	for range 3 {
+		if _, err := GetMappingForDirectory("/work/project"); err != nil {
+			t.Fatalf("GetMappingForDirectory() error = %v", err)
+		}
+	}
+	if reads := fileReads.Load() - before; reads != 1 {
+		t.Errorf("unchanged git config was read %d times, want 1", reads)
+	}
+
+	// Callers get their own copy
+	mappings, _ := ParseMappings()
+	mappings[0].Profile = "changed"
+	if again, _ := ParseMappings(); again[0].Profile != "work" {
+		t.Errorf("cached mapping was modified through a returned slice: %+v", again[0])
+	}
+
+	// A changed file is read again
+	writeAgedGitConfig(t, gitConfigPath, "[includeIf \"gitdir/i:/personal/\"]\n    path = ~/.gitconfig-personal\n", 30*time.Minute)
+	mappings, err := ParseMappings()
+	if err != nil {
+		t.Fatalf("ParseMappings() error = %v", err)
+	}
+	if len(mappings) != 1 || mappings[0].Profile != "personal" {
+		t.Errorf("ParseMappings() after a change = %+v", mappings)
+	}
+}
+
+func TestParseMappings_CacheSkipsRecentFiles(t *testing.T) {
+	_, gitConfigPath, cleanup := setupMappingTestEnv(t)
+	defer cleanup()
+	Invalidate()
+
+	if err := os.WriteFile(gitConfigPath, []byte("[includeIf \"gitdir/i:/work/\"]\n    path = ~/.gitconfig-work\n"), 0644); err != nil {
+		t.Fatalf("Failed to write git config: %v", err)
+	}
+	before := fileReads.Load()
+	for range 2 {
+		if _, err := ParseMappings(); err != nil {
+			t.Fatalf("ParseMappings() error = %v", err)
+		}
+	}
+	if reads := fileReads.Load() - before; reads != 2 {
+		t.Errorf("just-written git config was read %d times, want 2", reads)
+	}
+}
+
+func TestParseMappings_CacheSkipsCloudSyncedFiles(t *testing.T) {
+	tests := []struct {
+		name   string
+		marker string
+		data   string
+	}{
+		{name: "Dropbox", marker: ".dropbox"},
+		{name: "OneDrive", marker: "desktop.ini", data: "[.ShellClassInfo]\r\nIconResource=C:\\Program Files\\Microsoft OneDrive\\OneDrive.exe,0\r\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir, gitConfigPath, cleanup := setupMappingTestEnv(t)
+			defer cleanup()
+			Invalidate()
+
+			if err := os.WriteFile(filepath.Join(tmpDir, tt.marker), []byte(tt.data), 0644); err != nil {
+				t.Fatalf("Failed to create fixture: %v", err)
+			}
+			writeAgedGitConfig(t, gitConfigPath, "[includeIf \"gitdir/i:/work/\"]\n    path = ~/.gitconfig-work\n", time.Hour)
+
+			before := fileReads.Load()
+			for range 2 {
+				if _, err := ParseMappings(); err != nil {
+					t.Fatalf("ParseMappings() error = %v", err)
+				}
+			}
+			if reads := fileReads.Load() - before; reads != 2 {
+				t.Errorf("git config in a %s folder was read %d times, want 2", tt.name, reads)
+			}
+		})
+	}
+}
+
+func TestParseMappings_CacheSkipsSymlinkIntoCloudSyncedFolder(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinks need extra privileges on Windows")
+	}
+	tmpDir, gitConfigPath, cleanup := setupMappingTestEnv(t)
+	defer cleanup()
+	Invalidate()
+
+	dropbox := filepath.Join(tmpDir, "Dropbox")
+	if err := os.Mkdir(dropbox, 0755); err != nil {
+		t.Fatalf("Failed to create fixture: %v", err)
+	}
+	target := filepath.Join(dropbox, "gitconfig")
+	writeAgedGitConfig(t, target, "[includeIf \"gitdir/i:/work/\"]\n    path = ~/.gitconfig-work\n", time.Hour)
+	if err := os.Symlink(target, gitConfigPath); err != nil {
+		t.Fatalf("Failed to link git config: %v", err)
+	}
+
+	before := fileReads.Load()
+	for range 2 {
+		if _, err := ParseMappings(); err != nil {
+			t.Fatalf("ParseMappings() error = %v", err)
+		}
+	}
+	if reads := fileReads.Load() - before; reads != 2 {
+		t.Errorf("git config linked into Dropbox was read %d times, want 2", reads)
+	}
+}
+
+func TestParseMappings_InvalidatedByWrites(t *testing.T) {
+	tmpDir, gitConfigPath, cleanup := setupMappingTestEnv(t)
+	defer cleanup()
+	Invalidate()
+
+	writeAgedGitConfig(t, gitConfigPath, "[core]\n    editor = vim\n", time.Hour)
+	if mappings, _ := ParseMappings(); len(mappings) != 0 {
+		t.Fatalf("ParseMappings() = %+v, want none", mappings)
+	}
+
+	prof := &profile.Profile{Name: "work", Email: "work@example.com"}
+	if err := MapProfileToDirectoryWithOptions(prof, tmpDir+"/work", MapOptions{AllowMissing: true}); err != nil {
+		t.Fatalf("MapProfileToDirectoryWithOptions() error = %v", err)
+	}
+	mappings, err := ParseMappings()
+	if err != nil {
+		t.Fatalf("ParseMappings() error = %v", err)
+	}
+	if len(mappings) != 1 || mappings[0].Profile != "work" {
+		t.Errorf("ParseMappings() after mapping = %+v", mappings)
+	}
+}
+
+func TestParseMappings_ConcurrentReaders(t *testing.T) {
+	_, gitConfigPath, cleanup := setupMappingTestEnv(t)
+	defer cleanup()
+	Invalidate()
+
+	writeAgedGitConfig(t, gitConfigPath, "[includeIf \"gitdir/i:/work/\"]\n    path = ~/.gitconfig-work\n", time.Hour)
+
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 50 {
+				mappings, err := ParseMappings()
+				if err != nil || len(mappings) != 1 {
+					t.Errorf("ParseMappings() = %+v, %v", mappings, err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func BenchmarkParseMappings(b *testing.B) {
+	tmpDir := b.TempDir()
+	b.Setenv(utils.RootEnv, tmpDir)
+	gitConfigPath, err := GetGitConfigPath()
+	if err != nil {
+		b.Fatalf("GetGitConfigPath() error = %v", err)
+	}
+	content := ""
+	for i := range 50 {
+		content += "[includeIf \"gitdir/i:/work/" + string(rune('a'+i%26)) + "/\"]\n    path = ~/.gitconfig-work\n"
+	}
+	if err := os.WriteFile(gitConfigPath, []byte(content), 0644); err != nil {
+		b.Fatalf("Failed to write git config: %v", err)
+	}
+	old := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(gitConfigPath, old, old); err != nil {
+		b.Fatalf("Failed to age git config: %v", err)
+	}
+	Invalidate()
+
+	before := fileReads.Load()
+	b.ResetTimer()
+	for range b.N {
+		if _, err := GetMappingForDirectory("/work/a/repo"); err != nil {
+			b.Fatalf("GetMappingForDirectory() error = %v", err)
+		}
+	}
+	b.ReportMetric(float64(fileReads.Load()-before)/float64(b.N), "reads/op")
+}
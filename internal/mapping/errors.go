@@ -0,0 +1,27 @@
+package mapping
+
+import "errors"
+
+var (
+	// ErrDirectoryAlreadyMapped is returned when mapping a directory that already has a profile.
+	ErrDirectoryAlreadyMapped = errors.New("directory already mapped")
+	// ErrDirectoryNotFound is returned when mapping a directory that does not exist.
+	ErrDirectoryNotFound = errors.New("directory does not exist")
+	// ErrNotADirectory is returned when the path given as a directory is a file.
+	ErrNotADirectory = errors.New("not a directory")
+	// ErrMappingNotFound is returned when a directory has no mapping.
+	ErrMappingNotFound = errors.New("mapping not found")
+	// ErrGitConfigNotWritable is returned when the git config, or the file its
+	// symlink points to, cannot be written.
+	ErrGitConfigNotWritable = errors.New("git config is not writable")
+	// ErrDirectoryTooBroad is returned when mapping the home directory or a
+	// filesystem root without MapOptions.AllowBroad.
+	ErrDirectoryTooBroad = errors.New("directory too broad to map")
+	// ErrGitConfigMissing is returned when mapping with
+	// MapOptions.RequireGitConfig would create ~/.gitconfig.
+	ErrGitConfigMissing = errors.New("git config does not exist")
+	// ErrNotRepository is returned when pinning outside a git repository.
+	ErrNotRepository = errors.New("not a git repository")
+	// ErrNotPinned is returned when unpinning a repository with no pinned profile.
+	ErrNotPinned = errors.New("no profile pinned")
+)
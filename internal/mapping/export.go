@@ -0,0 +1,221 @@
+package mapping
+
+import (
+	"fmt"
+	"math/rand"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/thuanlegit/git-identitree/internal/config"
+	"github.com/thuanlegit/git-identitree/internal/profile"
+	"github.com/thuanlegit/git-identitree/internal/utils"
+)
+
+// ExportEntry is a single mapping in an exported mappings file.
+type ExportEntry struct {
+	Directory string `yaml:"directory"`
+	Profile   string `yaml:"profile"`
+}
+
+// ExportFile is the document written by `gidtree mappings export`.
+type ExportFile struct {
+	Mappings []ExportEntry `yaml:"mappings"`
+}
+
+// Translation maps pseudonyms back to the path segments they replaced.
+// It is written to a separate private file by an anonymized export.
+type Translation struct {
+	Root     string            `yaml:"root"`
+	Segments map[string]string `yaml:"segments"`
+}
+
+// ExportMappings returns the current mappings with home-relative paths abbreviated to ~.
+func ExportMappings() (*ExportFile, error) {
+	mappings, err := ParseMappings()
+	if err != nil {
+		return nil, err
+	}
+
+	export := &ExportFile{Mappings: []ExportEntry{}}
+	for _, m := range mappings {
+		if !m.HasDirectory() {
+			continue
+		}
+		export.Mappings = append(export.Mappings, ExportEntry{
+			Directory: filepath.ToSlash(utils.AbbreviateHome(m.Directory)),
+			Profile:   m.Profile,
+		})
+	}
+	return export, nil
+}
+
+// Anonymize replaces every path segment below root with a pseudonym.
+// The same segment always receives the same pseudonym within one call, and the
+// assignment order is shuffled by seed so pseudonyms do not reveal the original
+// ordering. Directories outside root have all of their segments replaced.
+func Anonymize(export *ExportFile, root string, seed int64) (*ExportFile, *Translation) {
+	root = strings.TrimSuffix(filepath.ToSlash(root), "/")
+
+	// Collect unique segments in a stable order before shuffling
+	unique := make(map[string]bool)
+	for _, e := range export.Mappings {
+		_, rest := splitRoot(e.Directory, root)
+		for _, seg := range rest {
+			unique[seg] = true
+		}
+	}
+	segments := make([]string, 0, len(unique))
+	for seg := range unique {
+		segments = append(segments, seg)
+	}
+	sort.Strings(segments)
+	rand.New(rand.NewSource(seed)).Shuffle(len(segments), func(i, j int) {
+		segments[i], segments[j] = segments[j], segments[i]
+	})
+
+	pseudonyms := make(map[string]string, len(segments))
+	translation := &Translation{Root: root, Segments: make(map[string]string, len(segments))}
+	for i, seg := range segments {
+		name := pseudonym(i)
+		pseudonyms[seg] = name
+		translation.Segments[name] = seg
+	}
+
+	anonymized := &ExportFile{Mappings: []ExportEntry{}}
+	for _, e := range export.Mappings {
+		prefix, rest := splitRoot(e.Directory, root)
+		replaced := make([]string, len(rest))
+		for i, seg := range rest {
+			replaced[i] = pseudonyms[seg]
+		}
+		anonymized.Mappings = append(anonymized.Mappings, ExportEntry{
+			Directory: joinRoot(prefix, replaced, strings.HasSuffix(e.Directory, "/")),
+			Profile:   e.Profile,
+		})
+	}
+
+	return anonymized, translation
+}
+
+// Translate reverses Anonymize using a translation file.
+func Translate(export *ExportFile, translation *Translation) (*ExportFile, error) {
+	translated := &ExportFile{Mappings: []ExportEntry{}}
+	for _, e := range export.Mappings {
+		prefix, rest := splitRoot(e.Directory, translation.Root)
+		original := make([]string, len(rest))
+		for i, seg := range rest {
+			value, ok := translation.Segments[seg]
+			if !ok {
+				return nil, fmt.Errorf("unknown pseudonym '%s' in directory '%s'", seg, e.Directory)
+			}
+			original[i] = value
+		}
+		translated.Mappings = append(translated.Mappings, ExportEntry{
+			Directory: joinRoot(prefix, original, strings.HasSuffix(e.Directory, "/")),
+			Profile:   e.Profile,
+		})
+	}
+	return translated, nil
+}
+
+// ImportMappings maps every entry whose directory is not mapped yet.
+// It returns the number of mappings created.
+func ImportMappings(export *ExportFile, getProfile func(string) (*profile.Profile, error)) (int, error) {
+	existing, err := ParseMappings()
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse existing mappings: %w", err)
+	}
+	cfg, err := config.Load()
+	if err != nil {
+		return 0, fmt.Errorf("failed to load config: %w", err)
+	}
+	caseSensitive := cfg.CaseSensitiveGitdir
+
+	mapped := make(map[string]bool, len(existing))
+	for _, m := range existing {
+		mapped[m.Directory] = true
+	}
+
+	created := 0
+	for _, e := range export.Mappings {
+		normalized, err := utils.NormalizePath(e.Directory)
+		if err != nil {
+			return created, fmt.Errorf("failed to normalize directory path: %w", err)
+		}
+		if mapped[utils.EnsureTrailingSlash(normalized)] {
+			continue
+		}
+
+		prof, err := getProfile(e.Profile)
+		if err != nil {
+			return created, fmt.Errorf("profile not found: %w", err)
+		}
+		// Manifests usually come from another machine whose checkouts may not
+		// exist here yet; a broad mapping there was made with --allow-broad
+		opts := MapOptions{CaseSensitive: caseSensitive, AllowMissing: true, AllowBroad: true}
+		if err := MapProfileToDirectoryWithOptions(prof, e.Directory, opts); err != nil {
+			return created, err
+		}
+		created++
+	}
+	return created, nil
+}
+
+// splitRoot splits a directory into the root prefix and the segments below it.
+// Directories outside root get an empty prefix apart from their leading slash or ~.
+func splitRoot(dir, root string) (string, []string) {
+	dir = strings.TrimSuffix(dir, "/")
+	if root != "" && (dir == root || strings.HasPrefix(dir, root+"/")) {
+		return root, splitSegments(strings.TrimPrefix(dir, root))
+	}
+
+	prefix := ""
+	switch {
+	case strings.HasPrefix(dir, "~"):
+		prefix = "~"
+	case strings.HasPrefix(dir, "/"):
+		prefix = ""
+	case filepath.VolumeName(dir) != "":
+		prefix = filepath.VolumeName(dir)
+	}
+	return prefix, splitSegments(strings.TrimPrefix(dir, prefix))
+}
+
+// splitSegments splits a slash-separated path into its non-empty segments.
+func splitSegments(path string) []string {
+	var segments []string
+	for _, seg := range strings.Split(path, "/") {
+		if seg != "" {
+			segments = append(segments, seg)
+		}
+	}
+	return segments
+}
+
+// joinRoot reassembles a directory from its prefix and segments.
+func joinRoot(prefix string, segments []string, trailingSlash bool) string {
+	dir := prefix
+	for _, seg := range segments {
+		dir += "/" + seg
+	}
+	if dir == "" {
+		dir = "/"
+	}
+	if trailingSlash && !strings.HasSuffix(dir, "/") {
+		dir += "/"
+	}
+	return dir
+}
+
+// pseudonym returns the i-th pseudonym: client-a … client-z, client-aa, …
+func pseudonym(i int) string {
+	var letters []byte
+	for n := i; ; n = n/26 - 1 {
+		letters = append([]byte{byte('a' + n%26)}, letters...)
+		if n < 26 {
+			break
+		}
+	}
+	return "client-" + string(letters)
+}
@@ -0,0 +1,210 @@
+package mapping
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/thuanlegit/git-identitree/internal/profile"
+	"github.com/thuanlegit/git-identitree/internal/utils"
+)
+
+func TestPseudonym(t *testing.T) {
+	tests := map[int]string{0: "client-a", 25: "client-z", 26: "client-aa", 27: "client-ab", 701: "client-zz", 702: "client-aaa"}
+	for i, want := range tests {
+		if got := pseudonym(i); got != want {
+			t.Errorf("pseudonym(%d) = %q, want %q", i, got, want)
+		}
+	}
+}
+
+func TestAnonymize(t *testing.T) {
+	export := &ExportFile{Mappings: []ExportEntry{
+		{Directory: "~/code/acme/", Profile: "work"},
+		{Directory: "~/code/acme/api/", Profile: "work"},
+		{Directory: "~/code/globex/", Profile: "contract"},
+		{Directory: "/srv/shared/", Profile: "ops"},
+	}}
+
+	anonymized, translation := Anonymize(export, "~/code", 42)
+
+	if len(anonymized.Mappings) != len(export.Mappings) {
+		t.Fatalf("Anonymize() returned %d mappings, want %d", len(anonymized.Mappings), len(export.Mappings))
+	}
+	for _, e := range anonymized.Mappings {
+		for _, secret := range []string{"acme", "globex", "api", "srv", "shared"} {
+			if strings.Contains(e.Directory, secret) {
+				t.Errorf("anonymized directory %q leaks %q", e.Directory, secret)
+			}
+		}
+	}
+
+	// Structure is preserved: root kept, depth kept, same segment → same pseudonym
+	first := anonymized.Mappings[0].Directory
+	second := anonymized.Mappings[1].Directory
+	if !strings.HasPrefix(first, "~/code/client-") || !strings.HasSuffix(first, "/") {
+		t.Errorf("anonymized directory = %q, want ~/code/client-*/", first)
+	}
+	if !strings.HasPrefix(second, first) {
+		t.Errorf("nested directory %q should keep parent pseudonym %q", second, first)
+	}
+	if strings.Count(second, "/") != strings.Count("~/code/acme/api/", "/") {
+		t.Errorf("anonymized directory %q changed depth", second)
+	}
+	if anonymized.Mappings[2].Profile != "contract" {
+		t.Errorf("profile = %q, want contract", anonymized.Mappings[2].Profile)
+	}
+	if len(translation.Segments) != 5 {
+		t.Errorf("translation has %d segments, want 5", len(translation.Segments))
+	}
+
+	// Deterministic for a given seed
+	again, _ := Anonymize(export, "~/code", 42)
+	if !reflect.DeepEqual(anonymized, again) {
+		t.Error("Anonymize() should be deterministic for the same seed")
+	}
+}
+
+func TestAnonymizeTranslateRoundTrip(t *testing.T) {
+	export := &ExportFile{Mappings: []ExportEntry{
+		{Directory: "~/code/acme/", Profile: "work"},
+		{Directory: "~/code/acme/api/", Profile: "work"},
+		{Directory: "~/code/client-a/", Profile: "personal"},
+		{Directory: "/opt/x/", Profile: "ops"},
+	}}
+
+	for _, seed := range []int64{1, 2, 3, 99} {
+		anonymized, translation := Anonymize(export, "~/code/", seed)
+		translated, err := Translate(anonymized, translation)
+		if err != nil {
+			t.Fatalf("Translate() error = %v", err)
+		}
+		if !reflect.DeepEqual(translated, export) {
+			t.Errorf("seed %d: round trip = %+v, want %+v", seed, translated.Mappings, export.Mappings)
+		}
+	}
+}
+
+func TestTranslate_UnknownPseudonym(t *testing.T) {
+	export := &ExportFile{Mappings: []ExportEntry{{Directory: "~/code/client-zz/", Profile: "work"}}}
+	translation := &Translation{Root: "~/code", Segments: map[string]string{"client-a": "acme"}}
+
+	if _, err := Translate(export, translation); err == nil {
+		t.Error("Translate() should fail for an unknown pseudonym")
+	}
+}
+
+func TestExportMappings_HomePrefix(t *testing.T) {
+	tmpDir, _, cleanup := setupMappingTestEnv(t)
+	defer cleanup()
+	Invalidate()
+
+	home := filepath.Join(tmpDir, "me")
+	if err := os.Mkdir(home, 0755); err != nil {
+		t.Fatalf("Failed to create home directory: %v", err)
+	}
+	t.Setenv(utils.RootEnv, home)
+	gitConfig := "[includeIf \"gitdir/i:" + filepath.ToSlash(home) + "/x/\"]\n    path = ~/.gitconfig-work\n" +
+		"[includeIf \"gitdir/i:" + filepath.ToSlash(home) + "ow/x/\"]\n    path = ~/.gitconfig-work\n"
+	if err := os.WriteFile(filepath.Join(home, ".gitconfig"), []byte(gitConfig), 0644); err != nil {
+		t.Fatalf("Failed to write git config: %v", err)
+	}
+
+	export, err := ExportMappings()
+	if err != nil {
+		t.Fatalf("ExportMappings() error = %v", err)
+	}
+	var dirs []string
+	for _, e := range export.Mappings {
+		dirs = append(dirs, e.Directory)
+	}
+	want := []string{"~/x/", filepath.ToSlash(home) + "ow/x/"}
+	if !reflect.DeepEqual(dirs, want) {
+		t.Errorf("ExportMappings() directories = %q, want %q", dirs, want)
+	}
+}
+
+func TestExportImportRoundTrip(t *testing.T) {
+	tmpDir, gitConfigPath, cleanup := setupMappingTestEnv(t)
+	defer cleanup()
+
+	manager, err := profile.NewDefaultManager()
+	if err != nil {
+		t.Fatalf("NewDefaultManager() error = %v", err)
+	}
+	for _, p := range []profile.Profile{
+		{Name: "work", Email: "work@example.com"},
+		{Name: "personal", Email: "me@example.com"},
+	} {
+		if err := manager.AddProfile(p); err != nil {
+			t.Fatalf("AddProfile() error = %v", err)
+		}
+	}
+
+	dirs := map[string]string{
+		filepath.Join(tmpDir, "code", "acme"):        "work",
+		filepath.Join(tmpDir, "code", "acme", "api"): "work",
+		filepath.Join(tmpDir, "code", "hobby"):       "personal",
+	}
+	for dir, name := range dirs {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		prof, _ := manager.GetProfile(name)
+		if err := MapProfileToDirectory(prof, dir); err != nil {
+			t.Fatalf("MapProfileToDirectory() error = %v", err)
+		}
+	}
+
+	original, err := ParseMappings()
+	if err != nil {
+		t.Fatalf("ParseMappings() error = %v", err)
+	}
+
+	export, err := ExportMappings()
+	if err != nil {
+		t.Fatalf("ExportMappings() error = %v", err)
+	}
+	for _, e := range export.Mappings {
+		if !strings.HasPrefix(e.Directory, "~/") {
+			t.Errorf("exported directory %q should be home-relative", e.Directory)
+		}
+	}
+
+	anonymized, translation := Anonymize(export, "~/code", 7)
+
+	// Wipe mappings, then translate and import on the "other machine"
+	if err := os.Remove(gitConfigPath); err != nil {
+		t.Fatalf("Failed to remove git config: %v", err)
+	}
+	translated, err := Translate(anonymized, translation)
+	if err != nil {
+		t.Fatalf("Translate() error = %v", err)
+	}
+	created, err := ImportMappings(translated, manager.GetProfile)
+	if err != nil {
+		t.Fatalf("ImportMappings() error = %v", err)
+	}
+	if created != len(dirs) {
+		t.Errorf("ImportMappings() created %d, want %d", created, len(dirs))
+	}
+
+	restored, err := ParseMappings()
+	if err != nil {
+		t.Fatalf("ParseMappings() error = %v", err)
+	}
+	if !reflect.DeepEqual(restored, original) {
+		t.Errorf("round trip mappings = %+v, want %+v", restored, original)
+	}
+
+	// Importing again changes nothing
+	created, err = ImportMappings(translated, manager.GetProfile)
+	if err != nil {
+		t.Fatalf("ImportMappings() second run error = %v", err)
+	}
+	if created != 0 {
+		t.Errorf("ImportMappings() second run created %d, want 0", created)
+	}
+}
@@ -1,35 +1,105 @@
 package mapping
 
 import (
-	"bufio"
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
-	"regexp"
 	"strings"
+	"sync/atomic"
 
+	"github.com/thuanlegit/git-identitree/internal/config"
+	"github.com/thuanlegit/git-identitree/internal/gitconfig"
+	"github.com/thuanlegit/git-identitree/internal/logging"
 	"github.com/thuanlegit/git-identitree/internal/profile"
+	"github.com/thuanlegit/git-identitree/internal/signers"
 	"github.com/thuanlegit/git-identitree/internal/utils"
 )
 
-// MapProfileToDirectory creates a profile-specific git config and adds an includeIf block.
+// MapOptions controls how MapProfileToDirectoryWithOptions writes a mapping.
+type MapOptions struct {
+	// CaseSensitive writes a gitdir: condition instead of gitdir/i:.
+	CaseSensitive bool
+	// Force replaces existing mappings of the same directory instead of failing.
+	Force bool
+	// Create creates the directory when it does not exist.
+	Create bool
+	// AllowMissing maps a directory that does not exist yet, for setting up a
+	// machine before its checkouts are in place.
+	AllowMissing bool
+	// Note is recorded in the comment above the includeIf block, to remember
+	// why the directory was mapped.
+	Note string
+	// AllowBroad maps the home directory or the filesystem root, which every
+	// repository on the machine would inherit.
+	AllowBroad bool
+	// RequireGitConfig fails with ErrGitConfigMissing instead of creating
+	// ~/.gitconfig when it does not exist, for users who configure git
+	// elsewhere on purpose.
+	RequireGitConfig bool
+}
+
+// MapProfileToDirectory creates a profile-specific git config and adds an includeIf block,
+// taking the condition's case sensitivity from the gidtree config.
 func MapProfileToDirectory(prof *profile.Profile, dir string) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	return MapProfileToDirectoryWithOptions(prof, dir, MapOptions{CaseSensitive: cfg.CaseSensitiveGitdir})
+}
+
+// MapProfileToDirectoryWithOptions is MapProfileToDirectory with explicit options.
+func MapProfileToDirectoryWithOptions(prof *profile.Profile, dir string, opts MapOptions) error {
 	// Normalize directory path
 	normalizedDir, err := utils.NormalizePath(dir)
 	if err != nil {
 		return fmt.Errorf("failed to normalize directory path: %w", err)
 	}
+	// git never matches a file, so its includeIf block would do nothing
+	if err := checkNotFile(dir, normalizedDir); err != nil {
+		return err
+	}
+	// Every repository below would get this identity, masking other mappings
+	if !opts.AllowBroad && IsBroadDirectory(normalizedDir) {
+		return utils.WithDetail(ErrDirectoryTooBroad, "refusing to map '%s': every repository under it would use profile '%s'; map a subdirectory, or pass --allow-broad", dir, prof.Name)
+	}
 	normalizedDir = utils.EnsureTrailingSlash(normalizedDir)
 
+	// A typo in the directory would otherwise go unnoticed until commits
+	// carry the wrong identity
+	_, err = os.Stat(normalizedDir)
+	missing := os.IsNotExist(err)
+	if missing && !opts.Create && !opts.AllowMissing {
+		return utils.WithDetail(ErrDirectoryNotFound, "directory '%s' does not exist", dir)
+	}
+	// Nothing is written before the user agreed to a new ~/.gitconfig
+	if opts.RequireGitConfig {
+		if err := CheckGitConfig(); err != nil {
+			return err
+		}
+	}
+	if missing && opts.Create {
+		if err := os.MkdirAll(normalizedDir, 0755); err != nil {
+			return fmt.Errorf("failed to create directory: %w", err)
+		}
+	}
+
 	// Check if directory is already mapped
 	mappings, err := ParseMappings()
 	if err != nil {
 		return fmt.Errorf("failed to parse existing mappings: %w", err)
 	}
+	var replaced []string
 	for _, m := range mappings {
-		if m.Directory == normalizedDir {
-			return fmt.Errorf("directory '%s' is already mapped to profile '%s'", dir, m.Profile)
+		if !m.HasDirectory() || !sameDirectory(m, normalizedDir) {
+			continue
+		}
+		if !opts.Force {
+			return utils.WithDetail(ErrDirectoryAlreadyMapped, "directory '%s' is already mapped to profile '%s'", dir, m.Profile)
 		}
+		replaced = append(replaced, m.Directory)
 	}
 
 	// Generate profile-specific config file
@@ -38,204 +108,657 @@ func MapProfileToDirectory(prof *profile.Profile, dir string) error {
 		return fmt.Errorf("failed to generate profile config: %w", err)
 	}
 
+	// Forced mappings take the place of the old blocks
+	if len(replaced) > 0 {
+		if err := removeIncludeIfBlocks(replaced); err != nil {
+			return fmt.Errorf("failed to remove existing mapping: %w", err)
+		}
+	}
+
 	// Add includeIf block to main git config
-	if err := addIncludeIfBlock(normalizedDir, configPath); err != nil {
+	kind := ConditionGitDirI
+	if opts.CaseSensitive {
+		kind = ConditionGitDir
+	}
+	if err := addIncludeIfBlock(normalizedDir, configPath, kind, opts.Note); err != nil {
 		return fmt.Errorf("failed to add includeIf block: %w", err)
 	}
 
 	return nil
 }
 
-// UnmapDirectory removes the includeIf block for a directory.
-func UnmapDirectory(dir string) error {
-	// Normalize directory path
+// OverlappingMappings returns the mappings of other profiles whose directory
+// contains dir or lies inside it. git applies every includeIf that matches, so
+// the block written later in ~/.gitconfig wins for repositories covered by both.
+func OverlappingMappings(dir, profileName string) ([]Mapping, error) {
 	normalizedDir, err := utils.NormalizePath(dir)
 	if err != nil {
-		return fmt.Errorf("failed to normalize directory path: %w", err)
+		return nil, fmt.Errorf("failed to normalize directory path: %w", err)
 	}
 	normalizedDir = utils.EnsureTrailingSlash(normalizedDir)
 
-	// Remove includeIf block
-	if err := removeIncludeIfBlock(normalizedDir); err != nil {
+	mappings, err := ParseMappings()
+	if err != nil {
+		return nil, err
+	}
+
+	var overlapping []Mapping
+	for _, m := range mappings {
+		if !m.HasDirectory() || m.Profile == profileName || sameDirectory(m, normalizedDir) {
+			continue
+		}
+		existing := resolveDirectory(m.Directory)
+		candidate := normalizedDir
+		if m.ConditionKind == ConditionGitDirI {
+			existing, candidate = strings.ToLower(existing), strings.ToLower(candidate)
+		}
+		if strings.HasPrefix(candidate, existing) || strings.HasPrefix(existing, candidate) {
+			overlapping = append(overlapping, m)
+		}
+	}
+	return overlapping, nil
+}
+
+// sameDirectory reports whether mapping m covers exactly the normalized
+// directory dir. Both sides are resolved again, since the mapped directory may
+// have been created or replaced by a symlink after the block was written, and
+// gitdir/i conditions compare case-insensitively.
+func sameDirectory(m Mapping, dir string) bool {
+	existing := resolveDirectory(m.Directory)
+	dir = resolveDirectory(dir)
+	if m.ConditionKind == ConditionGitDirI {
+		return strings.EqualFold(existing, dir)
+	}
+	return existing == dir
+}
+
+// resolveDirectory normalizes dir, resolving symlinks when it exists, and
+// gives it a trailing slash.
+func resolveDirectory(dir string) string {
+	normalized, err := utils.NormalizePath(dir)
+	if err != nil {
+		normalized = dir
+	}
+	return utils.EnsureTrailingSlash(normalized)
+}
+
+// UnmapDirectory removes the includeIf block for a directory.
+func UnmapDirectory(dir string) error {
+	return UnmapDirectories([]string{dir})
+}
+
+// UnmapDirectories removes the includeIf blocks for several directories,
+// rewriting ~/.gitconfig once. Nothing is written unless every directory is mapped.
+func UnmapDirectories(dirs []string) error {
+	normalized := make([]string, 0, len(dirs))
+	for _, dir := range dirs {
+		// Normalize directory path
+		normalizedDir, err := utils.NormalizePath(dir)
+		if err != nil {
+			return fmt.Errorf("failed to normalize directory path: %w", err)
+		}
+		normalized = append(normalized, utils.EnsureTrailingSlash(normalizedDir))
+	}
+
+	// Remove includeIf blocks
+	if err := removeIncludeIfBlocks(normalized); err != nil {
+		// A file is never mapped by gidtree; say so rather than "not mapped"
+		if errors.Is(err, ErrMappingNotFound) {
+			for i, dir := range dirs {
+				if fileErr := checkNotFile(dir, filepath.Clean(normalized[i])); fileErr != nil {
+					return fileErr
+				}
+			}
+		}
 		return fmt.Errorf("failed to remove includeIf block: %w", err)
 	}
 
 	return nil
 }
 
-// generateProfileConfig creates or updates a profile-specific git config file.
+// MoveMapping rewrites the condition of the includeIf block for oldDir to
+// cover newDir, for a checkout that was moved on disk. The block keeps its
+// condition kind, path and metadata comment. newDir must be an existing
+// directory that is not mapped yet. It returns the moved mapping and the block
+// as written.
+func MoveMapping(oldDir, newDir string) (Mapping, string, error) {
+	normalizedOld, err := utils.NormalizePath(oldDir)
+	if err != nil {
+		return Mapping{}, "", fmt.Errorf("failed to normalize directory path: %w", err)
+	}
+	normalizedOld = utils.EnsureTrailingSlash(normalizedOld)
+
+	normalizedNew, err := utils.NormalizePath(newDir)
+	if err != nil {
+		return Mapping{}, "", fmt.Errorf("failed to normalize directory path: %w", err)
+	}
+	if err := checkNotFile(newDir, normalizedNew); err != nil {
+		return Mapping{}, "", err
+	}
+	normalizedNew = utils.EnsureTrailingSlash(normalizedNew)
+	if _, err := os.Stat(normalizedNew); os.IsNotExist(err) {
+		return Mapping{}, "", utils.WithDetail(ErrDirectoryNotFound, "directory '%s' does not exist", newDir)
+	}
+
+	mappings, err := ParseMappings()
+	if err != nil {
+		return Mapping{}, "", fmt.Errorf("failed to parse existing mappings: %w", err)
+	}
+	for _, m := range mappings {
+		if m.HasDirectory() && sameDirectory(m, normalizedNew) {
+			return Mapping{}, "", utils.WithDetail(ErrDirectoryAlreadyMapped, "directory '%s' is already mapped to profile '%s'", newDir, m.Profile)
+		}
+	}
+
+	paths, err := mappingFiles()
+	if err != nil {
+		return Mapping{}, "", err
+	}
+	for _, path := range paths {
+		d, err := readGitConfigDocument(path)
+		if err != nil {
+			return Mapping{}, "", err
+		}
+		// Duplicates move together, so the last block still wins
+		var moved Mapping
+		var blocks []string
+		for _, s := range includeIfSections(d) {
+			configPath, ok := s.Get("path")
+			if !ok || !blockMatchesDirectory(s.Subsection, configPath, normalizedOld) {
+				continue
+			}
+			kind, _ := parseCondition(s.Subsection)
+			logging.Logger().Debug("includeIf block matched, moving", "condition", s.Subsection, "to", normalizedNew)
+			s.SetSubsection(string(kind) + ":" + normalizedNew)
+			moved, _ = sectionMapping(s)
+			blocks = append(blocks, strings.TrimRight(string(s.Serialize()), " \t\r\n")+"\n")
+		}
+		if len(blocks) == 0 {
+			continue
+		}
+		if err := writeGitConfigData(path, d.Serialize()); err != nil {
+			return Mapping{}, "", err
+		}
+		return moved, strings.Join(blocks, ""), nil
+	}
+	return Mapping{}, "", utils.WithDetail(ErrMappingNotFound, "directory '%s' is not mapped", oldDir)
+}
+
+// MergeDuplicateMappings removes the includeIf blocks for dir that a later
+// block overrides, keeping the last one as git does. It returns the removed
+// mappings; when dir is mapped once nothing is written.
+func MergeDuplicateMappings(dir string) ([]Mapping, error) {
+	paths, err := mappingFiles()
+	if err != nil {
+		return nil, err
+	}
+
+	type block struct {
+		doc     int
+		section *gitconfig.Section
+	}
+	docs := make([]*gitconfig.Document, len(paths))
+	var blocks []block
+	var mappings []Mapping
+	for i, path := range paths {
+		if docs[i], err = readGitConfigDocument(path); err != nil {
+			return nil, err
+		}
+		for _, s := range includeIfSections(docs[i]) {
+			if m, ok := sectionMapping(s); ok {
+				blocks = append(blocks, block{doc: i, section: s})
+				mappings = append(mappings, m)
+			}
+		}
+	}
+	markDuplicates(mappings)
+
+	var removed []Mapping
+	changed := make([]bool, len(paths))
+	for i, m := range mappings {
+		if !m.Duplicate || m.Directory != dir {
+			continue
+		}
+		b := blocks[i]
+		logging.Logger().Debug("removing duplicate includeIf block", "condition", b.section.Subsection, "profile", m.Profile)
+		dropSectionMeta(b.section)
+		docs[b.doc].RemoveSection(b.section)
+		changed[b.doc] = true
+		removed = append(removed, m)
+	}
+
+	for i, path := range paths {
+		if !changed[i] {
+			continue
+		}
+		if _, err := backupGitConfig(path); err != nil {
+			return nil, fmt.Errorf("failed to back up git config: %w", err)
+		}
+		if err := writeGitConfigData(path, docs[i].Serialize()); err != nil {
+			return nil, err
+		}
+	}
+	return removed, nil
+}
+
+// IsBroadDirectory reports whether dir is the home directory or a
+// filesystem root, whose mapping every repository below would inherit.
+func IsBroadDirectory(dir string) bool {
+	resolved := filepath.Clean(resolveDirectory(dir))
+	if filepath.Dir(resolved) == resolved {
+		return true
+	}
+	home, err := utils.Root()
+	if err != nil || home == "" {
+		return false
+	}
+	return strings.EqualFold(resolved, filepath.Clean(resolveDirectory(home)))
+}
+
+// checkNotFile returns ErrNotADirectory when the normalized path of dir is an
+// existing file. Missing paths pass; callers decide what to do about those.
+func checkNotFile(dir, normalized string) error {
+	info, err := os.Stat(normalized)
+	if err != nil || info.IsDir() {
+		return nil
+	}
+	return utils.WithDetail(ErrNotADirectory, "'%s' is a file, not a directory (resolved to %s)", dir, normalized)
+}
+
+// SyncProfileConfig rewrites the config file of a mapped profile from its
+// current fields, so edits reach the repositories it is mapped to. It reports
+// whether the profile is mapped; an unmapped profile has no file to refresh.
+func SyncProfileConfig(prof *profile.Profile) (bool, error) {
+	mapped, err := IsProfileMapped(prof.Name)
+	if err != nil {
+		return false, fmt.Errorf("failed to parse mappings: %w", err)
+	}
+	if !mapped {
+		return false, nil
+	}
+	if _, err := generateProfileConfig(prof); err != nil {
+		return false, fmt.Errorf("failed to generate profile config: %w", err)
+	}
+	return true, nil
+}
+
+// RenameProfileConfig moves the mappings of the profile formerly named
+// oldName to prof, already renamed: it writes prof's config, points every
+// includeIf block that included ~/.gitconfig-<oldName> at it and removes the
+// old file. Blocks without a directory cannot be repointed, so none are
+// touched when there is one. It returns the directories repointed.
+func RenameProfileConfig(oldName string, prof *profile.Profile) ([]string, error) {
+	mappings, err := ParseMappings()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse mappings: %w", err)
+	}
+	var owned []Mapping
+	for _, m := range mappings {
+		if m.Profile != oldName {
+			continue
+		}
+		if !m.HasDirectory() {
+			return nil, fmt.Errorf("includeIf \"%s\" uses profile '%s' and cannot be repointed; edit it by hand", m.RawCondition, oldName)
+		}
+		owned = append(owned, m)
+	}
+	if len(owned) == 0 {
+		return nil, nil
+	}
+
+	configPath, err := generateProfileConfig(prof)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate profile config: %w", err)
+	}
+	var dirs []string
+	for _, m := range owned {
+		if err := RepointMapping(m, configPath); err != nil {
+			return dirs, fmt.Errorf("failed to repoint %s: %w", m.Directory, err)
+		}
+		dirs = append(dirs, m.Directory)
+	}
+
+	oldPath, err := GetProfileConfigPath(oldName)
+	if err != nil {
+		return dirs, err
+	}
+	if err := os.Remove(oldPath); err != nil && !os.IsNotExist(err) {
+		return dirs, fmt.Errorf("failed to remove %s: %w", oldPath, err)
+	}
+	return dirs, nil
+}
+
+// generateProfileConfig writes a profile-specific git config file. The file is
+// rebuilt from the profile every time, never merged with what is there, so a
+// key cleared from the profile leaves no [core] or signingkey behind.
 func generateProfileConfig(prof *profile.Profile) (string, error) {
-	home, err := utils.GetHomeDir()
+	configPath, err := GetProfileConfigPath(prof.Name)
 	if err != nil {
 		return "", err
 	}
 
-	configPath := filepath.Join(home, fmt.Sprintf(".gitconfig-%s", prof.Name))
+	if prof.SSHSigning {
+		if _, err := signers.Seed(prof); err != nil {
+			return "", fmt.Errorf("failed to seed allowed signers: %w", err)
+		}
+	}
+
+	content := RenderProfileConfig(prof)
+	if err := os.WriteFile(configPath, []byte(content), utils.PrivateFileMode); err != nil {
+		return "", fmt.Errorf("failed to write profile config: %w", err)
+	}
+	logging.Logger().Debug("wrote profile config", "path", configPath, "bytes", len(content))
+
+	return configPath, nil
+}
 
+// RenderProfileConfig returns the content gidtree writes to a profile's
+// ~/.gitconfig-<name>.
+func RenderProfileConfig(prof *profile.Profile) string {
 	var config strings.Builder
 	config.WriteString("[user]\n")
-	config.WriteString(fmt.Sprintf("    name = %s\n", prof.GetAuthorName()))
-	config.WriteString(fmt.Sprintf("    email = %s\n", prof.Email))
+	config.WriteString(fmt.Sprintf("    name = %s\n", quoteConfigValue(prof.GetAuthorName())))
+	config.WriteString(fmt.Sprintf("    email = %s\n", quoteConfigValue(prof.Email)))
 
 	if prof.GPGKeyID != "" {
 		config.WriteString(fmt.Sprintf("    signingkey = %s\n", prof.GPGKeyID))
 	}
+	if prof.SSHSigning {
+		config.WriteString(fmt.Sprintf("    signingkey = %s\n", quoteConfigValue(prof.SSHPublicKeyPath())))
+		config.WriteString("\n[gpg]\n")
+		config.WriteString("    format = ssh\n")
+		if signersPath, err := signers.Path(prof.Name); err == nil {
+			config.WriteString("\n[gpg \"ssh\"]\n")
+			config.WriteString(fmt.Sprintf("    allowedSignersFile = %s\n", quoteConfigValue(signersPath)))
+		}
+	}
 
 	// Configure SSH key if provided
 	if prof.SSHKeyPath != "" {
 		// Use core.sshCommand to specify the SSH key
 		// This approach works with Git's SSH URL rewriting
 		config.WriteString("\n[core]\n")
-		config.WriteString(fmt.Sprintf("    sshCommand = ssh -i %s -F /dev/null\n", prof.SSHKeyPath))
+		config.WriteString(fmt.Sprintf("    sshCommand = %s\n", quoteConfigValue(prof.SSHCommand())))
 	}
 
-	if err := os.WriteFile(configPath, []byte(config.String()), 0644); err != nil {
-		return "", fmt.Errorf("failed to write profile config: %w", err)
-	}
+	return config.String()
+}
 
-	return configPath, nil
+// RepointMapping changes the path the includeIf block of m includes to
+// configPath, e.g. when the file it pointed to has gone. Only mappings with a
+// directory can be repointed.
+func RepointMapping(m Mapping, configPath string) error {
+	if !m.HasDirectory() {
+		return fmt.Errorf("cannot repoint the %s block '%s'", m.ConditionKind, m.RawCondition)
+	}
+	return addIncludeIfBlock(m.Directory, configPath, m.ConditionKind, "")
 }
 
-// addIncludeIfBlock adds an includeIf block to ~/.gitconfig.
-func addIncludeIfBlock(dir, configPath string) error {
-	gitConfigPath, err := getGitConfigPath()
+// addIncludeIfBlock adds an includeIf block with a condition of the given kind to ~/.gitconfig,
+// below a metadata comment recording the profile, today's date and note.
+// An existing block for dir keeps its condition and only has its path updated;
+// its comment keeps its date and, unless note is set, its note.
+// With managed_include set, the block goes to the managed include file, which
+// ~/.gitconfig is then made to include.
+func addIncludeIfBlock(dir, configPath string, kind ConditionKind, note string) error {
+	if !managedIncludeEnabled() {
+		gitConfigPath, err := GetGitConfigPath()
+		if err != nil {
+			return err
+		}
+		return writeIncludeIfBlock(gitConfigPath, dir, configPath, kind, note)
+	}
+
+	managedPath, err := ManagedIncludePath()
 	if err != nil {
 		return err
 	}
+	if err := writeIncludeIfBlock(managedPath, dir, configPath, kind, note); err != nil {
+		return err
+	}
+	return ensureManagedInclude()
+}
 
+// writeIncludeIfBlock adds or updates the includeIf block for dir in the git
+// config at gitConfigPath.
+func writeIncludeIfBlock(gitConfigPath, dir, configPath string, kind ConditionKind, note string) error {
 	// Convert configPath to use ~ if it's in home directory
-	home, err := utils.GetHomeDir()
+	home, err := utils.Root()
 	if err == nil && strings.HasPrefix(configPath, home) {
 		configPath = strings.Replace(configPath, home, "~", 1)
 		// Convert to forward slashes for cross-platform compatibility
 		configPath = filepath.ToSlash(configPath)
 	}
 
-	// Read existing content
-	var lines []string
-	if _, err := os.Stat(gitConfigPath); err == nil {
-		file, err := os.Open(gitConfigPath)
-		if err != nil {
-			return fmt.Errorf("failed to open git config: %w", err)
-		}
-		scanner := bufio.NewScanner(file)
-		for scanner.Scan() {
-			lines = append(lines, scanner.Text())
-		}
-		if err := file.Close(); err != nil {
-			return fmt.Errorf("failed to close git config: %w", err)
-		}
-		if err := scanner.Err(); err != nil {
-			return fmt.Errorf("failed to read git config: %w", err)
-		}
+	d, err := readGitConfigDocument(gitConfigPath)
+	if err != nil {
+		return err
 	}
 
 	// Check if includeIf block already exists for this directory
-	includeIfRegex := regexp.MustCompile(`^\s*\[includeIf\s+"gitdir/i:(.+)"\]\s*$`)
-	for i, line := range lines {
-		if matches := includeIfRegex.FindStringSubmatch(line); matches != nil {
-			existingDir := matches[1]
-			normalizedExisting, _ := utils.NormalizePath(existingDir)
-			normalizedExisting = utils.EnsureTrailingSlash(normalizedExisting)
-			if normalizedExisting == dir {
-				// Already exists, update the path line
-				if i+1 < len(lines) {
-					pathRegex := regexp.MustCompile(`^\s*path\s*=\s*(.+)\s*$`)
-					if pathRegex.MatchString(lines[i+1]) {
-						lines[i+1] = fmt.Sprintf("    path = %s", configPath)
-						// Write back
-						return writeGitConfig(gitConfigPath, lines)
-					}
-				}
+	for _, s := range includeIfSections(d) {
+		path, ok := s.Get("path")
+		if !ok || !blockMatchesDirectory(s.Subsection, path, dir) {
+			continue
+		}
+		logging.Logger().Debug("includeIf block matched, updating path", "condition", s.Subsection)
+		// Already exists, update the path and drop any others, which would
+		// include further files
+		s.Set("path", configPath)
+		if line, meta, ok := sectionMeta(s); ok {
+			meta.profile = extractProfileName(configPath)
+			if note != "" {
+				meta.note = note
 			}
+			line.SetComment(meta.String())
+		} else if note != "" {
+			s.AddComment(mappingMeta{profile: extractProfileName(configPath), note: note}.String())
 		}
+		return writeGitConfigData(gitConfigPath, d.Serialize())
 	}
 
-	// Append new includeIf block
-	lines = append(lines, "")
-	lines = append(lines, fmt.Sprintf(`[includeIf "gitdir/i:%s"]`, dir))
-	lines = append(lines, fmt.Sprintf("    path = %s", configPath))
+	// Append new includeIf block, separated from the content before it by
+	// exactly one blank line
+	s := gitconfig.NewSection("includeIf", fmt.Sprintf("%s:%s", kind, dir))
+	s.AddComment(mappingMeta{profile: extractProfileName(configPath), mapped: now(), note: note}.String())
+	s.Add("path", configPath)
+	d.AppendSection(s)
 
-	return writeGitConfig(gitConfigPath, lines)
+	return writeGitConfigData(gitConfigPath, d.Serialize())
 }
 
 // removeIncludeIfBlock removes an includeIf block for a directory.
 func removeIncludeIfBlock(dir string) error {
-	gitConfigPath, err := getGitConfigPath()
-	if err != nil {
-		return err
+	return removeIncludeIfBlocks([]string{dir})
+}
+
+// removeIncludeIfBlocks removes the includeIf blocks for the normalized
+// directories dirs in a single read-modify-write of ~/.gitconfig, or of the
+// managed include file alone when managed_include is set.
+func removeIncludeIfBlocks(dirs []string) error {
+	if len(dirs) == 0 {
+		return nil
 	}
 
-	file, err := os.Open(gitConfigPath)
+	gitConfigPath, err := blocksPath()
 	if err != nil {
-		return fmt.Errorf("failed to open git config: %w", err)
+		return err
 	}
-	defer func() {
-		_ = file.Close()
-	}()
 
-	var lines []string
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		lines = append(lines, scanner.Text())
+	if _, err := os.Stat(gitConfigPath); os.IsNotExist(err) {
+		return utils.WithDetail(ErrMappingNotFound, "directory '%s' is not mapped", dirs[0])
 	}
-	if err := scanner.Err(); err != nil {
-		return fmt.Errorf("failed to read git config: %w", err)
+	d, err := readGitConfigDocument(gitConfigPath)
+	if err != nil {
+		return err
 	}
 
-	includeIfRegex := regexp.MustCompile(`^\s*\[includeIf\s+"gitdir/i:(.+)"\]\s*$`)
-
-	var newLines []string
-	var skipNext bool
-	for i, line := range lines {
-		if skipNext {
-			skipNext = false
+	// Each matching block is dropped whole, with its metadata comment
+	removed := make(map[string]bool, len(dirs))
+	for _, s := range includeIfSections(d) {
+		path, _ := s.Get("path")
+		dir, ok := matchingDirectory(s.Subsection, path, dirs)
+		if !ok {
 			continue
 		}
+		logging.Logger().Debug("includeIf block matched, removing", "condition", s.Subsection)
+		removed[dir] = true
+		dropSectionMeta(s)
+		d.RemoveSection(s)
+	}
 
-		if matches := includeIfRegex.FindStringSubmatch(line); matches != nil {
-			existingDir := matches[1]
-			normalizedExisting, _ := utils.NormalizePath(existingDir)
-			normalizedExisting = utils.EnsureTrailingSlash(normalizedExisting)
-
-			if normalizedExisting == dir {
-				// Skip this includeIf line and the next path line
-				skipNext = true
-				// Also skip empty line before if it exists
-				if i > 0 && strings.TrimSpace(lines[i-1]) == "" {
-					// Remove the last added empty line
-					if len(newLines) > 0 && strings.TrimSpace(newLines[len(newLines)-1]) == "" {
-						newLines = newLines[:len(newLines)-1]
-					}
-				}
-				continue
-			}
+	for _, dir := range dirs {
+		if !removed[dir] {
+			return utils.WithDetail(ErrMappingNotFound, "directory '%s' is not mapped", dir)
 		}
+	}
+
+	return writeGitConfigData(gitConfigPath, d.Serialize())
+}
 
-		newLines = append(newLines, line)
+// matchingDirectory returns the entry of dirs the includeIf block with the
+// given condition and first path belongs to.
+func matchingDirectory(raw, path string, dirs []string) (string, bool) {
+	for _, dir := range dirs {
+		if blockMatchesDirectory(raw, path, dir) {
+			return dir, true
+		}
 	}
+	return "", false
+}
 
-	return writeGitConfig(gitConfigPath, newLines)
+// endsWithNewline reports whether the file at path exists and ends with a newline.
+func endsWithNewline(path string) bool {
+	data, err := os.ReadFile(path)
+	return err == nil && len(data) > 0 && data[len(data)-1] == '\n'
 }
 
-// writeGitConfig writes lines to the git config file.
+// gitConfigWrites counts how often writeGitConfig rewrote a git config.
+var gitConfigWrites atomic.Int64
+
+// writeGitConfig writes lines to the git config file, keeping its final
+// newline, or its lack of one.
 func writeGitConfig(path string, lines []string) error {
+	content := strings.Join(lines, "\n")
+	if content != "" && endsWithNewline(path) {
+		content += "\n"
+	}
+	return writeGitConfigData(path, []byte(content))
+}
+
+// writeGitConfigData writes content to the git config file. A symlinked
+// config is written through to its target, so the link into a dotfiles
+// repository stays in place.
+func writeGitConfigData(path string, content []byte) error {
+	target, err := resolveGitConfigTarget(path)
+	if err != nil {
+		return err
+	}
+	if info, err := os.Stat(target); err == nil && info.Mode().Perm()&0200 == 0 {
+		return notWritableError(path, target)
+	}
+
 	// Ensure parent directory exists
-	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
 		return fmt.Errorf("failed to create config directory: %w", err)
 	}
 
-	content := strings.Join(lines, "\n")
-	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+	gitConfigWrites.Add(1)
+	err = os.WriteFile(target, content, 0644)
+	Invalidate()
+	if errors.Is(err, fs.ErrPermission) {
+		return notWritableError(path, target)
+	}
+	if err != nil {
 		return fmt.Errorf("failed to write git config: %w", err)
 	}
+	logging.Logger().Debug("wrote git config", "path", target, "bytes", len(content))
 
 	return nil
 }
 
-// getGitConfigPath returns the path to ~/.gitconfig.
-func getGitConfigPath() (string, error) {
-	home, err := utils.GetHomeDir()
+// resolveGitConfigTarget returns the file to write for the git config at path:
+// path itself, or the file it links to. A dangling link resolves to the file
+// it names, which the write then creates.
+func resolveGitConfigTarget(path string) (string, error) {
+	info, err := os.Lstat(path)
+	if err != nil || info.Mode()&os.ModeSymlink == 0 {
+		return path, nil
+	}
+	if target, err := filepath.EvalSymlinks(path); err == nil {
+		return target, nil
+	}
+	link, err := os.Readlink(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read git config symlink: %w", err)
+	}
+	if !filepath.IsAbs(link) {
+		link = filepath.Join(filepath.Dir(path), link)
+	}
+	return link, nil
+}
+
+// notWritableError explains that the git config at path, which resolves to
+// target, cannot be written.
+func notWritableError(path, target string) error {
+	if target != path {
+		return utils.WithDetail(ErrGitConfigNotWritable, "git config %s is a symlink to %s, which is not writable; pass --gitconfig <path> to have gidtree write its blocks elsewhere", path, target)
+	}
+	return utils.WithDetail(ErrGitConfigNotWritable, "git config %s is not writable; pass --gitconfig <path> to have gidtree write its blocks elsewhere", path)
+}
+
+// GetProfileConfigPath returns the path to the profile-specific config, ~/.gitconfig-<name>.
+func GetProfileConfigPath(name string) (string, error) {
+	home, err := utils.Root()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, fmt.Sprintf(".gitconfig-%s", name)), nil
+}
+
+// gitConfigOverride replaces ~/.gitconfig when set, see SetGitConfigPath.
+var gitConfigOverride string
+
+// SetGitConfigPath makes gidtree read and write its includeIf blocks in path
+// instead of ~/.gitconfig (--gitconfig). An empty path restores the default.
+// The file has to be included from the global config for git to see it.
+func SetGitConfigPath(path string) {
+	gitConfigOverride = path
+	Invalidate()
+}
+
+// MissingGitConfig returns the path of ~/.gitconfig and whether it does not
+// exist yet, so that mapping would create it. A file set with
+// SetGitConfigPath is never missing: it was named on purpose.
+func MissingGitConfig() (string, bool) {
+	path, err := GetGitConfigPath()
+	if err != nil || gitConfigOverride != "" {
+		return path, false
+	}
+	_, err = os.Lstat(path)
+	return path, errors.Is(err, fs.ErrNotExist)
+}
+
+// CheckGitConfig returns ErrGitConfigMissing when mapping would create
+// ~/.gitconfig, describing how to go on, and nil otherwise.
+func CheckGitConfig() error {
+	path, missing := MissingGitConfig()
+	if !missing {
+		return nil
+	}
+	if global := os.Getenv("GIT_CONFIG_GLOBAL"); global != "" {
+		return utils.WithDetail(ErrGitConfigMissing, "%s does not exist and git reads its global config from GIT_CONFIG_GLOBAL instead; pass --gitconfig %s to add the mapping there, or --create-gitconfig to create %s anyway", utils.AbbreviateHome(path), global, utils.AbbreviateHome(path))
+	}
+	return utils.WithDetail(ErrGitConfigMissing, "%s does not exist; pass --create-gitconfig to create it, or, if git reads its global config elsewhere (GIT_CONFIG_GLOBAL), pass --gitconfig <file> to add the mapping there", utils.AbbreviateHome(path))
+}
+
+// GetGitConfigPath returns the path to ~/.gitconfig, or the file set with
+// SetGitConfigPath.
+func GetGitConfigPath() (string, error) {
+	if gitConfigOverride != "" {
+		return gitConfigOverride, nil
+	}
+	home, err := utils.Root()
 	if err != nil {
 		return "", fmt.Errorf("failed to get home directory: %w", err)
 	}
@@ -1,15 +1,368 @@
 package mapping
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
 
+	"github.com/thuanlegit/git-identitree/internal/config"
+	"github.com/thuanlegit/git-identitree/internal/logging"
 	"github.com/thuanlegit/git-identitree/internal/profile"
 	"github.com/thuanlegit/git-identitree/internal/utils"
 )
 
+func TestGenerateProfileConfig_SSHCommand(t *testing.T) {
+	tmpDir, _, cleanup := setupMappingTestEnv(t)
+	defer cleanup()
+	t.Setenv("GIDTREE_TEST_KEYS", filepath.Join(tmpDir, "keys"))
+
+	tests := []struct {
+		name    string
+		profile profile.Profile
+		want    string
+	}{
+		{
+			name:    "path with spaces",
+			profile: profile.Profile{Name: "spaces", Email: "a@example.com", SSHKeyPath: "/Users/Jane Doe/.ssh/id work"},
+			want:    "    sshCommand = ssh -i '/Users/Jane Doe/.ssh/id work' -o IdentitiesOnly=yes\n",
+		},
+		{
+			// The generated file holds the expanded path; profiles.yaml keeps the variable
+			name:    "environment variable",
+			profile: profile.Profile{Name: "variable", Email: "a@example.com", SSHKeyPath: "${GIDTREE_TEST_KEYS}/id_work"},
+			want:    "    sshCommand = ssh -i '" + filepath.Join(tmpDir, "keys", "id_work") + "' -o IdentitiesOnly=yes\n",
+		},
+		{
+			name:    "isolated ssh config",
+			profile: profile.Profile{Name: "isolated", Email: "a@example.com", SSHKeyPath: "/keys/id", IsolateSSHConfig: true},
+			want:    "    sshCommand = ssh -i '/keys/id' -o IdentitiesOnly=yes -F /dev/null\n",
+		},
+		{
+			// Backslashes are escapes in git config values
+			name:    "windows path",
+			profile: profile.Profile{Name: "windows", Email: "a@example.com", SSHKeyPath: `C:\keys\id`},
+			want:    `    sshCommand = "ssh -i 'C:\\keys\\id' -o IdentitiesOnly=yes"` + "\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configPath, err := generateProfileConfig(&tt.profile)
+			if err != nil {
+				t.Fatalf("generateProfileConfig() error = %v", err)
+			}
+			content, err := os.ReadFile(configPath)
+			if err != nil {
+				t.Fatalf("Failed to read generated config: %v", err)
+			}
+			if !strings.Contains(string(content), tt.want) {
+				t.Errorf("generated config = %q, want line %q", content, tt.want)
+			}
+		})
+	}
+}
+
+func TestGenerateProfileConfig_AuthorName(t *testing.T) {
+	_, _, cleanup := setupMappingTestEnv(t)
+	defer cleanup()
+
+	tests := []struct {
+		name    string
+		profile profile.Profile
+		want    string
+	}{
+		{
+			name:    "author name",
+			profile: profile.Profile{Name: "work", Email: "jane@acme.com", AuthorName: "Jane Doe"},
+			want:    "[user]\n    name = Jane Doe\n    email = jane@acme.com\n",
+		},
+		{
+			name:    "falls back to profile name",
+			profile: profile.Profile{Name: "personal", Email: "me@example.com"},
+			want:    "[user]\n    name = personal\n    email = me@example.com\n",
+		},
+		{
+			name:    "committer is left to the environment",
+			profile: profile.Profile{Name: "work", Email: "jane@acme.com", AuthorName: "Jane Doe", CommitterName: "Release Bot", CommitterEmail: "bot@acme.com"},
+			want:    "[user]\n    name = Jane Doe\n    email = jane@acme.com\n",
+		},
+		{
+			name:    "quoted when git would misread it",
+			profile: profile.Profile{Name: "team", Email: "team@example.com", AuthorName: "Team #1; Ops"},
+			want:    "[user]\n    name = \"Team #1; Ops\"\n    email = team@example.com\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configPath, err := generateProfileConfig(&tt.profile)
+			if err != nil {
+				t.Fatalf("generateProfileConfig() error = %v", err)
+			}
+			content, err := os.ReadFile(configPath)
+			if err != nil {
+				t.Fatalf("Failed to read generated config: %v", err)
+			}
+			if string(content) != tt.want {
+				t.Errorf("generated config = %q, want %q", content, tt.want)
+			}
+		})
+	}
+}
+
+func TestGenerateProfileConfig_SSHSigning(t *testing.T) {
+	tmpDir, _, cleanup := setupMappingTestEnv(t)
+	defer cleanup()
+
+	keyPath := filepath.Join(tmpDir, ".ssh", "id_work")
+	if err := os.MkdirAll(filepath.Dir(keyPath), 0700); err != nil {
+		t.Fatalf("Failed to create .ssh: %v", err)
+	}
+	key := "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIK2tra2tra2tra2tra2tra2tra2tra2tra2tra2tra2t"
+	if err := os.WriteFile(keyPath+".pub", []byte(key+" jane@laptop\n"), 0644); err != nil {
+		t.Fatalf("Failed to write public key: %v", err)
+	}
+	prof := &profile.Profile{Name: "work", Email: "jane@acme.com", SSHKeyPath: keyPath, SSHSigning: true}
+
+	configPath, err := generateProfileConfig(prof)
+	if err != nil {
+		t.Fatalf("generateProfileConfig() error = %v", err)
+	}
+	content, err := os.ReadFile(configPath)
+	if err != nil {
+		t.Fatalf("Failed to read generated config: %v", err)
+	}
+	signersPath := filepath.Join(tmpDir, ".gidtree", "allowed_signers-work")
+	for _, want := range []string{
+		"    signingkey = " + keyPath + ".pub\n",
+		"[gpg]\n    format = ssh\n",
+		"[gpg \"ssh\"]\n    allowedSignersFile = " + signersPath + "\n",
+	} {
+		if !strings.Contains(string(content), want) {
+			t.Errorf("generated config = %q, want %q", content, want)
+		}
+	}
+
+	signersContent, err := os.ReadFile(signersPath)
+	if err != nil {
+		t.Fatalf("Failed to read allowed signers: %v", err)
+	}
+	if want := "jane@acme.com namespaces=\"git\" " + key + "\n"; string(signersContent) != want {
+		t.Errorf("allowed signers = %q, want %q", signersContent, want)
+	}
+}
+
+func TestSyncProfileConfig(t *testing.T) {
+	tmpDir, _, cleanup := setupMappingTestEnv(t)
+	defer cleanup()
+
+	prof := &profile.Profile{Name: "work", Email: "jane@acme.com"}
+	workDir := filepath.Join(tmpDir, "work")
+	if err := os.MkdirAll(workDir, 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	if err := MapProfileToDirectory(prof, workDir); err != nil {
+		t.Fatalf("MapProfileToDirectory() error = %v", err)
+	}
+
+	prof.AuthorName = "Jane Doe"
+	synced, err := SyncProfileConfig(prof)
+	if err != nil {
+		t.Fatalf("SyncProfileConfig() error = %v", err)
+	}
+	if !synced {
+		t.Error("SyncProfileConfig() = false for a mapped profile")
+	}
+	content, err := os.ReadFile(filepath.Join(tmpDir, ".gitconfig-work"))
+	if err != nil {
+		t.Fatalf("Failed to read profile config: %v", err)
+	}
+	if !strings.Contains(string(content), "name = Jane Doe\n") {
+		t.Errorf("profile config = %q, want the new author name", content)
+	}
+
+	unmapped := &profile.Profile{Name: "personal", Email: "me@example.com"}
+	synced, err = SyncProfileConfig(unmapped)
+	if err != nil {
+		t.Fatalf("SyncProfileConfig() error = %v", err)
+	}
+	if synced {
+		t.Error("SyncProfileConfig() = true for an unmapped profile")
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, ".gitconfig-personal")); !os.IsNotExist(err) {
+		t.Errorf("SyncProfileConfig() wrote a config for an unmapped profile: %v", err)
+	}
+}
+
+func TestSyncProfileConfig_DropsClearedKeys(t *testing.T) {
+	tmpDir, _, cleanup := setupMappingTestEnv(t)
+	defer cleanup()
+
+	keyPath := filepath.Join(tmpDir, ".ssh", "id_work")
+	if err := os.MkdirAll(filepath.Dir(keyPath), 0700); err != nil {
+		t.Fatalf("Failed to create .ssh: %v", err)
+	}
+	if err := os.WriteFile(keyPath, []byte("key"), 0600); err != nil {
+		t.Fatalf("Failed to write key: %v", err)
+	}
+	manager, err := profile.NewDefaultManager()
+	if err != nil {
+		t.Fatalf("NewDefaultManager() error = %v", err)
+	}
+	prof := profile.Profile{Name: "work", Email: "jane@acme.com", SSHKeyPath: keyPath, GPGKeyID: "ABCD1234"}
+	if err := manager.AddProfile(prof); err != nil {
+		t.Fatalf("AddProfile() error = %v", err)
+	}
+	workDir := filepath.Join(tmpDir, "work")
+	if err := MapProfileToDirectoryWithOptions(&prof, workDir, MapOptions{Create: true}); err != nil {
+		t.Fatalf("MapProfileToDirectoryWithOptions() error = %v", err)
+	}
+	configPath := filepath.Join(tmpDir, ".gitconfig-work")
+	content, err := os.ReadFile(configPath)
+	if err != nil {
+		t.Fatalf("Failed to read profile config: %v", err)
+	}
+	if !strings.Contains(string(content), "[core]") || !strings.Contains(string(content), "signingkey") {
+		t.Fatalf("profile config = %q, want the keys", content)
+	}
+
+	// Clearing the keys, as profile update does
+	prof.SSHKeyPath, prof.GPGKeyID = "", ""
+	if err := manager.UpdateProfile("work", prof); err != nil {
+		t.Fatalf("UpdateProfile() error = %v", err)
+	}
+	if _, err := SyncProfileConfig(&prof); err != nil {
+		t.Fatalf("SyncProfileConfig() error = %v", err)
+	}
+
+	content, err = os.ReadFile(configPath)
+	if err != nil {
+		t.Fatalf("Failed to read profile config: %v", err)
+	}
+	for _, leftover := range []string{"[core]", "sshCommand", "signingkey", "id_work", "ABCD1234"} {
+		if strings.Contains(string(content), leftover) {
+			t.Errorf("profile config still has %q:\n%s", leftover, content)
+		}
+	}
+	if want := RenderProfileConfig(&prof); string(content) != want {
+		t.Errorf("profile config = %q, want %q", content, want)
+	}
+}
+
+func TestRepointMapping(t *testing.T) {
+	tmpDir, _, cleanup := setupMappingTestEnv(t)
+	defer cleanup()
+
+	prof := &profile.Profile{Name: "work", Email: "jane@acme.com"}
+	workDir := filepath.Join(tmpDir, "work")
+	if err := os.MkdirAll(workDir, 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	if err := MapProfileToDirectoryWithOptions(prof, workDir, MapOptions{CaseSensitive: true}); err != nil {
+		t.Fatalf("MapProfileToDirectoryWithOptions() error = %v", err)
+	}
+	m, err := GetMappingForDirectory(workDir)
+	if err != nil || m == nil {
+		t.Fatalf("GetMappingForDirectory() = %v, %v", m, err)
+	}
+
+	target := filepath.Join(tmpDir, "dotfiles", ".gitconfig-work")
+	if err := RepointMapping(*m, target); err != nil {
+		t.Fatalf("RepointMapping() error = %v", err)
+	}
+	repointed, err := GetMappingForDirectory(workDir)
+	if err != nil || repointed == nil {
+		t.Fatalf("GetMappingForDirectory() = %v, %v", repointed, err)
+	}
+	if repointed.ConfigPath != target {
+		t.Errorf("ConfigPath = %s, want %s", repointed.ConfigPath, target)
+	}
+	if repointed.RawCondition != m.RawCondition {
+		t.Errorf("RawCondition = %s, want it kept as %s", repointed.RawCondition, m.RawCondition)
+	}
+
+	if err := RepointMapping(Mapping{RawCondition: "onbranch:main", ConditionKind: ConditionOnBranch}, target); err == nil {
+		t.Error("RepointMapping() should refuse a mapping without a directory")
+	}
+}
+
+func TestRenameProfileConfig(t *testing.T) {
+	tmpDir, gitConfigPath, cleanup := setupMappingTestEnv(t)
+	defer cleanup()
+
+	old := &profile.Profile{Name: "work client", Email: "jane@acme.com"}
+	dirs := []string{filepath.Join(tmpDir, "work"), filepath.Join(tmpDir, "acme")}
+	for _, dir := range dirs {
+		if err := MapProfileToDirectoryWithOptions(old, dir, MapOptions{Create: true}); err != nil {
+			t.Fatalf("MapProfileToDirectoryWithOptions() error = %v", err)
+		}
+	}
+	other := &profile.Profile{Name: "personal", Email: "me@example.com"}
+	if err := MapProfileToDirectoryWithOptions(other, filepath.Join(tmpDir, "me"), MapOptions{Create: true}); err != nil {
+		t.Fatalf("MapProfileToDirectoryWithOptions() error = %v", err)
+	}
+
+	renamed := &profile.Profile{Name: "work-client", Email: "jane@acme.com"}
+	repointed, err := RenameProfileConfig("work client", renamed)
+	if err != nil {
+		t.Fatalf("RenameProfileConfig() error = %v", err)
+	}
+	if len(repointed) != 2 {
+		t.Errorf("RenameProfileConfig() = %v, want both directories", repointed)
+	}
+
+	mappings, err := ParseMappings()
+	if err != nil {
+		t.Fatalf("ParseMappings() error = %v", err)
+	}
+	profiles := map[string]int{}
+	for _, m := range mappings {
+		profiles[m.Profile]++
+	}
+	if profiles["work-client"] != 2 || profiles["personal"] != 1 || profiles["work client"] != 0 {
+		t.Errorf("mapped profiles = %v, want the work directories renamed", profiles)
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, ".gitconfig-work client")); !os.IsNotExist(err) {
+		t.Errorf("old profile config still exists: %v", err)
+	}
+	content, err := os.ReadFile(filepath.Join(tmpDir, ".gitconfig-work-client"))
+	if err != nil {
+		t.Fatalf("Failed to read renamed config: %v", err)
+	}
+	if string(content) != RenderProfileConfig(renamed) {
+		t.Errorf("renamed config = %q, want %q", content, RenderProfileConfig(renamed))
+	}
+	gitConfig, err := os.ReadFile(gitConfigPath)
+	if err != nil {
+		t.Fatalf("Failed to read git config: %v", err)
+	}
+	if !strings.Contains(string(gitConfig), "# gidtree: profile=work-client") {
+		t.Errorf("git config = %q, want the metadata comments renamed", gitConfig)
+	}
+
+	// An unmapped profile has nothing to move
+	if repointed, err := RenameProfileConfig("unmapped", &profile.Profile{Name: "other"}); err != nil || repointed != nil {
+		t.Errorf("RenameProfileConfig() = %v, %v, want nothing for an unmapped profile", repointed, err)
+	}
+
+	// A block without a directory stops the rename before anything changes
+	f, err := os.OpenFile(gitConfigPath, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatalf("Failed to open git config: %v", err)
+	}
+	_, _ = f.WriteString("\n[includeIf \"onbranch:main\"]\n\tpath = ~/.gitconfig-personal\n")
+	_ = f.Close()
+	if _, err := RenameProfileConfig("personal", &profile.Profile{Name: "me", Email: "me@example.com"}); err == nil || !strings.Contains(err.Error(), "onbranch:main") {
+		t.Errorf("RenameProfileConfig() error = %v, want the onbranch block reported", err)
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, ".gitconfig-me")); !os.IsNotExist(err) {
+		t.Errorf("RenameProfileConfig() wrote a config despite failing: %v", err)
+	}
+}
+
 func TestGenerateProfileConfig(t *testing.T) {
 	tmpDir, _, cleanup := setupMappingTestEnv(t)
 	defer cleanup()
@@ -47,7 +400,7 @@ func TestGenerateProfileConfig(t *testing.T) {
 	if !strings.Contains(contentStr, "signingkey = ABC123") {
 		t.Error("Generated config missing user.signingkey")
 	}
-	if !strings.Contains(contentStr, "sshCommand = ssh -i /path/to/key") {
+	if !strings.Contains(contentStr, "sshCommand = ssh -i '/path/to/key' -o IdentitiesOnly=yes\n") {
 		t.Error("Generated config missing core.sshCommand")
 	}
 }
@@ -93,7 +446,7 @@ func TestAddIncludeIfBlock(t *testing.T) {
 
 	configPath := filepath.Join(tmpDir, ".gitconfig-test")
 
-	if err := addIncludeIfBlock(normalizedDir, configPath); err != nil {
+	if err := addIncludeIfBlock(normalizedDir, configPath, ConditionGitDirI, ""); err != nil {
 		t.Fatalf("addIncludeIfBlock() error = %v", err)
 	}
 
@@ -135,7 +488,7 @@ func TestAddIncludeIfBlock_Existing(t *testing.T) {
 	}
 
 	newConfigPath := filepath.Join(tmpDir, ".gitconfig-new")
-	if err := addIncludeIfBlock(normalizedDir, newConfigPath); err != nil {
+	if err := addIncludeIfBlock(normalizedDir, newConfigPath, ConditionGitDirI, ""); err != nil {
 		t.Fatalf("addIncludeIfBlock() error = %v", err)
 	}
 
@@ -321,6 +674,70 @@ func TestUnmapDirectory(t *testing.T) {
 	}
 }
 
+func TestUnmapDirectories_SingleWrite(t *testing.T) {
+	tmpDir, gitConfigPath, cleanup := setupMappingTestEnv(t)
+	defer cleanup()
+
+	prof := &profile.Profile{Name: "work", Email: "work@example.com"}
+	var dirs []string
+	for i := range 30 {
+		dir := filepath.Join(tmpDir, "work", fmt.Sprintf("repo%02d", i))
+		if err := MapProfileToDirectoryWithOptions(prof, dir, MapOptions{Create: true}); err != nil {
+			t.Fatalf("MapProfileToDirectory() error = %v", err)
+		}
+		dirs = append(dirs, dir)
+	}
+	other := filepath.Join(tmpDir, "personal")
+	if err := MapProfileToDirectoryWithOptions(&profile.Profile{Name: "personal", Email: "me@example.com"}, other, MapOptions{Create: true}); err != nil {
+		t.Fatalf("MapProfileToDirectory() error = %v", err)
+	}
+
+	before := gitConfigWrites.Load()
+	if err := UnmapDirectories(dirs); err != nil {
+		t.Fatalf("UnmapDirectories() error = %v", err)
+	}
+	if writes := gitConfigWrites.Load() - before; writes != 1 {
+		t.Errorf("UnmapDirectories() wrote the git config %d times, want 1", writes)
+	}
+
+	mappings, err := ParseMappings()
+	if err != nil {
+		t.Fatalf("ParseMappings() error = %v", err)
+	}
+	if len(mappings) != 1 || mappings[0].Profile != "personal" {
+		t.Errorf("mappings after UnmapDirectories() = %+v, want only personal", mappings)
+	}
+	if _, err := os.Stat(gitConfigPath); err != nil {
+		t.Fatalf("git config missing: %v", err)
+	}
+}
+
+func TestUnmapDirectories_MissingWritesNothing(t *testing.T) {
+	tmpDir, gitConfigPath, cleanup := setupMappingTestEnv(t)
+	defer cleanup()
+
+	mapped := filepath.Join(tmpDir, "work")
+	if err := MapProfileToDirectoryWithOptions(&profile.Profile{Name: "work", Email: "work@example.com"}, mapped, MapOptions{Create: true}); err != nil {
+		t.Fatalf("MapProfileToDirectory() error = %v", err)
+	}
+	original, err := os.ReadFile(gitConfigPath)
+	if err != nil {
+		t.Fatalf("Failed to read git config: %v", err)
+	}
+
+	err = UnmapDirectories([]string{mapped, filepath.Join(tmpDir, "unmapped")})
+	if !errors.Is(err, ErrMappingNotFound) {
+		t.Fatalf("UnmapDirectories() error = %v, want ErrMappingNotFound", err)
+	}
+	content, err := os.ReadFile(gitConfigPath)
+	if err != nil {
+		t.Fatalf("Failed to read git config: %v", err)
+	}
+	if string(content) != string(original) {
+		t.Errorf("git config changed after a failed UnmapDirectories():\n%s", content)
+	}
+}
+
 func TestAddIncludeIfBlock_UpdateExisting(t *testing.T) {
 	tmpDir, gitConfigPath, cleanup := setupMappingTestEnv(t)
 	defer cleanup()
@@ -344,7 +761,7 @@ func TestAddIncludeIfBlock_UpdateExisting(t *testing.T) {
 	}
 
 	newConfigPath := filepath.Join(tmpDir, ".gitconfig-new")
-	if err := addIncludeIfBlock(normalizedDir, newConfigPath); err != nil {
+	if err := addIncludeIfBlock(normalizedDir, newConfigPath, ConditionGitDirI, ""); err != nil {
 		t.Fatalf("addIncludeIfBlock() error = %v", err)
 	}
 
@@ -380,7 +797,7 @@ func TestAddIncludeIfBlock_NoPathLine(t *testing.T) {
 	}
 
 	newConfigPath := filepath.Join(tmpDir, ".gitconfig-new")
-	if err := addIncludeIfBlock(normalizedDir, newConfigPath); err != nil {
+	if err := addIncludeIfBlock(normalizedDir, newConfigPath, ConditionGitDirI, ""); err != nil {
 		t.Fatalf("addIncludeIfBlock() error = %v", err)
 	}
 
@@ -479,7 +896,7 @@ func TestGenerateProfileConfig_AllFields(t *testing.T) {
 		"name = test",
 		"email = test@example.com",
 		"signingkey = ABC123",
-		"sshCommand = ssh -i /path/to/key",
+		"sshCommand = ssh -i '/path/to/key' -o IdentitiesOnly=yes\n",
 	}
 
 	for _, check := range checks {
@@ -560,9 +977,9 @@ func TestGetGitConfigPath_Error(t *testing.T) {
 	}
 
 	// This should fail because we can't get home directory
-	_, err := getGitConfigPath()
+	_, err := GetGitConfigPath()
 	if err == nil {
-		t.Error("getGitConfigPath() should fail with invalid HOME")
+		t.Error("GetGitConfigPath() should fail with invalid HOME")
 	}
 }
 
@@ -586,7 +1003,7 @@ func TestAddIncludeIfBlock_UpdatePathLine(t *testing.T) {
 	}
 
 	newConfigPath := filepath.Join(tmpDir, ".gitconfig-new")
-	if err := addIncludeIfBlock(normalizedDir, newConfigPath); err != nil {
+	if err := addIncludeIfBlock(normalizedDir, newConfigPath, ConditionGitDirI, ""); err != nil {
 		t.Fatalf("addIncludeIfBlock() error = %v", err)
 	}
 
@@ -621,7 +1038,7 @@ func TestAddIncludeIfBlock_NoPathLineAfterIncludeIf(t *testing.T) {
 	}
 
 	newConfigPath := filepath.Join(tmpDir, ".gitconfig-new")
-	if err := addIncludeIfBlock(normalizedDir, newConfigPath); err != nil {
+	if err := addIncludeIfBlock(normalizedDir, newConfigPath, ConditionGitDirI, ""); err != nil {
 		t.Fatalf("addIncludeIfBlock() error = %v", err)
 	}
 
@@ -701,7 +1118,7 @@ func TestAddIncludeIfBlock_ReadError(t *testing.T) {
 	normalizedDir = utils.EnsureTrailingSlash(normalizedDir)
 
 	configPath := filepath.Join(tmpDir, ".gitconfig-test")
-	err := addIncludeIfBlock(normalizedDir, configPath)
+	err := addIncludeIfBlock(normalizedDir, configPath, ConditionGitDirI, "")
 	if err == nil {
 		t.Error("addIncludeIfBlock() should fail when config is a directory")
 	}
@@ -735,7 +1152,7 @@ func TestAddIncludeIfBlock_OpenError(t *testing.T) {
 		}()
 
 		configPath := filepath.Join(tmpDir, ".gitconfig-test")
-		err := addIncludeIfBlock(normalizedDir, configPath)
+		err := addIncludeIfBlock(normalizedDir, configPath, ConditionGitDirI, "")
 		if err == nil {
 			t.Log("addIncludeIfBlock() might succeed even with restricted permissions on some systems")
 		} else {
@@ -944,3 +1361,891 @@ func TestMapProfileToDirectory_GenerateConfigError(t *testing.T) {
 		t.Error("MapProfileToDirectory() should fail with invalid HOME")
 	}
 }
+
+func TestGetProfileConfigPath(t *testing.T) {
+	tmpDir, _, cleanup := setupMappingTestEnv(t)
+	defer cleanup()
+
+	path, err := GetProfileConfigPath("work")
+	if err != nil {
+		t.Fatalf("GetProfileConfigPath() error = %v", err)
+	}
+	if want := filepath.Join(tmpDir, ".gitconfig-work"); path != want {
+		t.Errorf("GetProfileConfigPath() = %q, want %q", path, want)
+	}
+}
+
+// conditionZoo holds includeIf blocks gidtree did not write, including
+// conditions it cannot interpret.
+const conditionZoo = `[user]
+	name = Jane Doe
+[includeIf "gitdir:~/CaseSensitive/"]
+	path = ~/cs.inc
+[includeIf "onbranch:release/**"]
+	path = ~/.gitconfig-release
+[includeIf "hasconfig:remote.*.url:https://github.com/acme/**"]
+	path = ~/.gitconfig-acme
+[includeIf "gitdir/i:~/quoted dir/"]
+	path = "~/quoted.inc"
+[includeIf "gitdir/i:**/vendor/"]
+	path = ~/vendor.inc
+[includeIf "future:whatever \"quoted\""]
+	path = ~/future.inc`
+
+func TestIncludeIfRoundTrip_PreservesUnfamiliarConditions(t *testing.T) {
+	tmpDir, gitConfigPath, cleanup := setupMappingTestEnv(t)
+	defer cleanup()
+
+	if err := os.WriteFile(gitConfigPath, []byte(conditionZoo), 0644); err != nil {
+		t.Fatalf("Failed to write git config: %v", err)
+	}
+
+	testDir := filepath.Join(tmpDir, "project")
+	if err := os.MkdirAll(testDir, 0755); err != nil {
+		t.Fatalf("Failed to create test directory: %v", err)
+	}
+	prof := &profile.Profile{Name: "work", Email: "work@example.com"}
+
+	for i := 0; i < 3; i++ {
+		if err := MapProfileToDirectory(prof, testDir); err != nil {
+			t.Fatalf("MapProfileToDirectory() error = %v", err)
+		}
+		data, _ := os.ReadFile(gitConfigPath)
+		if !strings.HasPrefix(string(data), conditionZoo) {
+			t.Fatalf("mapping altered existing blocks:\n%s", data)
+		}
+
+		if err := UnmapDirectory(testDir); err != nil {
+			t.Fatalf("UnmapDirectory() error = %v", err)
+		}
+		data, _ = os.ReadFile(gitConfigPath)
+		if string(data) != conditionZoo {
+			t.Fatalf("round trip %d changed the file:\n%s\nwant\n%s", i, data, conditionZoo)
+		}
+	}
+}
+
+func TestUnmapDirectory_MatchesUnmanagedBlocksVerbatim(t *testing.T) {
+	tmpDir, gitConfigPath, cleanup := setupMappingTestEnv(t)
+	defer cleanup()
+
+	testDir := filepath.Join(tmpDir, "project")
+	if err := os.MkdirAll(testDir, 0755); err != nil {
+		t.Fatalf("Failed to create test directory: %v", err)
+	}
+	dir := testDir + "/"
+
+	// A case-sensitive block for the same directory is not ours to remove,
+	// but a hand-written block with exactly our condition is
+	caseSensitive := "[includeIf \"gitdir:" + dir + "\"]\n\tpath = ~/custom.inc"
+	exact := "[includeIf \"gitdir/i:" + dir + "\"]\n\tpath = ~/custom.inc"
+	if err := os.WriteFile(gitConfigPath, []byte(caseSensitive+"\n"+exact), 0644); err != nil {
+		t.Fatalf("Failed to write git config: %v", err)
+	}
+
+	if err := UnmapDirectory(testDir); err != nil {
+		t.Fatalf("UnmapDirectory() error = %v", err)
+	}
+
+	data, _ := os.ReadFile(gitConfigPath)
+	if string(data) != caseSensitive {
+		t.Errorf("git config after unmap =\n%s\nwant\n%s", data, caseSensitive)
+	}
+}
+
+func TestMapProfileToDirectory_LogsWrites(t *testing.T) {
+	tmpDir, _, cleanup := setupMappingTestEnv(t)
+	defer cleanup()
+
+	var buf bytes.Buffer
+	logging.Enable(&buf)
+	defer logging.Disable()
+
+	testDir := filepath.Join(tmpDir, "project")
+	if err := os.MkdirAll(testDir, 0755); err != nil {
+		t.Fatalf("Failed to create test directory: %v", err)
+	}
+	prof := &profile.Profile{Name: "work", Email: "work@example.com"}
+	if err := MapProfileToDirectory(prof, testDir); err != nil {
+		t.Fatalf("MapProfileToDirectory() error = %v", err)
+	}
+	if err := UnmapDirectory(testDir); err != nil {
+		t.Fatalf("UnmapDirectory() error = %v", err)
+	}
+
+	for _, want := range []string{"wrote profile config", "wrote git config", "includeIf block matched, removing", "bytes="} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("debug log should mention %q, got:\n%s", want, buf.String())
+		}
+	}
+}
+
+func TestMapProfileToDirectory_CaseSensitive(t *testing.T) {
+	tmpDir, gitConfigPath, cleanup := setupMappingTestEnv(t)
+	defer cleanup()
+
+	testDir := filepath.Join(tmpDir, "Project")
+	if err := os.MkdirAll(testDir, 0755); err != nil {
+		t.Fatalf("Failed to create test directory: %v", err)
+	}
+	prof := &profile.Profile{Name: "work", Email: "work@example.com"}
+
+	// The configured default applies to MapProfileToDirectory
+	cfg := config.Default()
+	cfg.CaseSensitiveGitdir = true
+	if err := config.Save(&cfg); err != nil {
+		t.Fatalf("config.Save() error = %v", err)
+	}
+	if err := MapProfileToDirectory(prof, testDir); err != nil {
+		t.Fatalf("MapProfileToDirectory() error = %v", err)
+	}
+	data, _ := os.ReadFile(gitConfigPath)
+	if !strings.Contains(string(data), `[includeIf "gitdir:`+testDir+`/"]`) {
+		t.Fatalf("git config = %s, want a case-sensitive gitdir condition", data)
+	}
+
+	mappings, err := ParseMappings()
+	if err != nil {
+		t.Fatalf("ParseMappings() error = %v", err)
+	}
+	if len(mappings) != 1 || mappings[0].ConditionKind != ConditionGitDir || mappings[0].Profile != "work" {
+		t.Errorf("ParseMappings() = %+v, want one gitdir mapping for work", mappings)
+	}
+
+	// gidtree owns the case-sensitive block it wrote and can remove it
+	if err := UnmapDirectory(testDir); err != nil {
+		t.Fatalf("UnmapDirectory() error = %v", err)
+	}
+	data, _ = os.ReadFile(gitConfigPath)
+	if strings.Contains(string(data), "includeIf") {
+		t.Errorf("git config after unmap = %s, want no includeIf blocks", data)
+	}
+
+	// Explicit options override the config
+	if err := MapProfileToDirectoryWithOptions(prof, testDir, MapOptions{}); err != nil {
+		t.Fatalf("MapProfileToDirectoryWithOptions() error = %v", err)
+	}
+	data, _ = os.ReadFile(gitConfigPath)
+	if !strings.Contains(string(data), `[includeIf "gitdir/i:`+testDir+`/"]`) {
+		t.Errorf("git config = %s, want a gitdir/i condition", data)
+	}
+}
+
+func TestMapUnmap_RepeatedCyclesKeepFile(t *testing.T) {
+	originals := map[string]string{
+		"trailing newline":    "[user]\n    name = Jane Doe\n[core]\n    editor = vim\n",
+		"no trailing newline": "[user]\n    name = Jane Doe",
+		"other mappings":      "[user]\n    name = Jane Doe\n\n[includeIf \"gitdir/i:/personal/\"]\n    path = ~/.gitconfig-personal\n",
+		"crlf":                "[user]\r\n\tname = Jane Doe\r\n",
+		"hand-written":        "; dotfiles\n[user]\n\tname = \"Jane \\\"JD\\\" Doe\" # nickname\n[alias]\n\tlg = log \\\n\t\t--graph\n\n# clients\n[includeIf \"gitdir:~/clients/\"]\n\tpath = \"~/.gitconfig-clients\"\n",
+	}
+
+	for name, original := range originals {
+		t.Run(name, func(t *testing.T) {
+			tmpDir, gitConfigPath, cleanup := setupMappingTestEnv(t)
+			defer cleanup()
+
+			if err := os.WriteFile(gitConfigPath, []byte(original), 0644); err != nil {
+				t.Fatalf("Failed to write git config: %v", err)
+			}
+			prof := &profile.Profile{Name: "work", Email: "work@example.com"}
+			dir := filepath.Join(tmpDir, "work")
+			if err := os.MkdirAll(dir, 0755); err != nil {
+				t.Fatalf("Failed to create directory: %v", err)
+			}
+			for range 20 {
+				if err := MapProfileToDirectory(prof, dir); err != nil {
+					t.Fatalf("MapProfileToDirectory() error = %v", err)
+				}
+				if err := UnmapDirectory(dir); err != nil {
+					t.Fatalf("UnmapDirectory() error = %v", err)
+				}
+			}
+
+			content, err := os.ReadFile(gitConfigPath)
+			if err != nil {
+				t.Fatalf("Failed to read git config: %v", err)
+			}
+			if string(content) != original {
+				t.Errorf("git config after 20 map/unmap cycles =\n%q\nwant\n%q", content, original)
+			}
+		})
+	}
+}
+
+func TestRemoveIncludeIfBlock_LeavesNoBlankLines(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{
+			name:    "first block",
+			content: "[includeIf \"gitdir/i:/work/\"]\n    path = ~/.gitconfig-work\n\n[user]\n    name = Jane Doe\n",
+			want:    "[user]\n    name = Jane Doe\n",
+		},
+		{
+			name:    "middle block",
+			content: "[user]\n    name = Jane Doe\n\n[includeIf \"gitdir/i:/work/\"]\n    path = ~/.gitconfig-work\n\n[core]\n    editor = vim\n",
+			want:    "[user]\n    name = Jane Doe\n\n[core]\n    editor = vim\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, gitConfigPath, cleanup := setupMappingTestEnv(t)
+			defer cleanup()
+
+			if err := os.WriteFile(gitConfigPath, []byte(tt.content), 0644); err != nil {
+				t.Fatalf("Failed to write git config: %v", err)
+			}
+			if err := removeIncludeIfBlock("/work/"); err != nil {
+				t.Fatalf("removeIncludeIfBlock() error = %v", err)
+			}
+			content, err := os.ReadFile(gitConfigPath)
+			if err != nil {
+				t.Fatalf("Failed to read git config: %v", err)
+			}
+			if string(content) != tt.want {
+				t.Errorf("git config =\n%q\nwant\n%q", content, tt.want)
+			}
+		})
+	}
+}
+
+func TestAddIncludeIfBlock_CollapsesTrailingBlankLines(t *testing.T) {
+	_, gitConfigPath, cleanup := setupMappingTestEnv(t)
+	defer cleanup()
+
+	fixNow(t, "2024-05-01")
+	if err := os.WriteFile(gitConfigPath, []byte("[user]\n    name = Jane Doe\n\n\n   \n"), 0644); err != nil {
+		t.Fatalf("Failed to write git config: %v", err)
+	}
+	if err := addIncludeIfBlock("/work/", "/home/u/.gitconfig-work", ConditionGitDirI, ""); err != nil {
+		t.Fatalf("addIncludeIfBlock() error = %v", err)
+	}
+
+	content, err := os.ReadFile(gitConfigPath)
+	if err != nil {
+		t.Fatalf("Failed to read git config: %v", err)
+	}
+	want := "[user]\n    name = Jane Doe\n\n# gidtree: profile=work mapped=2024-05-01\n[includeIf \"gitdir/i:/work/\"]\n    path = /home/u/.gitconfig-work\n"
+	if string(content) != want {
+		t.Errorf("git config =\n%q\nwant\n%q", content, want)
+	}
+}
+
+func TestRemoveIncludeIfBlock_ExtraKeys(t *testing.T) {
+	tests := []struct {
+		name  string
+		block string
+	}{
+		{
+			name:  "comment before path",
+			block: "[includeIf \"gitdir/i:/work/\"]\n    # work identity\n    path = ~/.gitconfig-work\n",
+		},
+		{
+			name:  "comment after path",
+			block: "[includeIf \"gitdir/i:/work/\"]\n    path = ~/.gitconfig-work\n    ; added by gidtree\n",
+		},
+		{
+			name:  "multiple path lines",
+			block: "[includeIf \"gitdir/i:/work/\"]\n    path = ~/.gitconfig-work\n    path = ~/.gitconfig-work-extra\n",
+		},
+		{
+			name:  "unknown key",
+			block: "[includeIf \"gitdir/i:/work/\"]\n    path = ~/.gitconfig-work\n    someKey = value\n",
+		},
+	}
+
+	const before = "[user]\n    name = Jane Doe\n"
+	const after = "[core]\n    editor = vim\n"
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, gitConfigPath, cleanup := setupMappingTestEnv(t)
+			defer cleanup()
+
+			content := before + "\n" + tt.block + "\n" + after
+			if err := os.WriteFile(gitConfigPath, []byte(content), 0644); err != nil {
+				t.Fatalf("Failed to write git config: %v", err)
+			}
+			if err := removeIncludeIfBlock("/work/"); err != nil {
+				t.Fatalf("removeIncludeIfBlock() error = %v", err)
+			}
+
+			got, err := os.ReadFile(gitConfigPath)
+			if err != nil {
+				t.Fatalf("Failed to read git config: %v", err)
+			}
+			if want := before + "\n" + after; string(got) != want {
+				t.Errorf("git config =\n%q\nwant\n%q", got, want)
+			}
+			mappings, err := ParseMappings()
+			if err != nil {
+				t.Fatalf("ParseMappings() error = %v", err)
+			}
+			if len(mappings) != 0 {
+				t.Errorf("ParseMappings() = %+v, want none", mappings)
+			}
+		})
+	}
+}
+
+func TestAddIncludeIfBlock_UpdatesBlockWithExtraKeys(t *testing.T) {
+	_, gitConfigPath, cleanup := setupMappingTestEnv(t)
+	defer cleanup()
+
+	content := "[includeIf \"gitdir/i:/work/\"]\n    # work identity\n    path = ~/.gitconfig-work\n    path = ~/.gitconfig-stale\n[core]\n    editor = vim\n"
+	if err := os.WriteFile(gitConfigPath, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write git config: %v", err)
+	}
+	if err := addIncludeIfBlock("/work/", "/elsewhere/.gitconfig-work", ConditionGitDirI, ""); err != nil {
+		t.Fatalf("addIncludeIfBlock() error = %v", err)
+	}
+
+	got, err := os.ReadFile(gitConfigPath)
+	if err != nil {
+		t.Fatalf("Failed to read git config: %v", err)
+	}
+	want := "[includeIf \"gitdir/i:/work/\"]\n    # work identity\n    path = /elsewhere/.gitconfig-work\n[core]\n    editor = vim\n"
+	if string(got) != want {
+		t.Errorf("git config =\n%q\nwant\n%q", got, want)
+	}
+}
+
+func TestMapProfileToDirectory_DuplicateThroughSymlink(t *testing.T) {
+	tmpDir, _, cleanup := setupMappingTestEnv(t)
+	defer cleanup()
+
+	real := filepath.Join(tmpDir, "real", "work")
+	if err := os.MkdirAll(real, 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	link := filepath.Join(tmpDir, "link")
+	if err := os.Symlink(filepath.Join(tmpDir, "real"), link); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+
+	work := &profile.Profile{Name: "work", Email: "work@example.com"}
+	if err := MapProfileToDirectoryWithOptions(work, real, MapOptions{}); err != nil {
+		t.Fatalf("MapProfileToDirectoryWithOptions() error = %v", err)
+	}
+	other := &profile.Profile{Name: "other", Email: "other@example.com"}
+	err := MapProfileToDirectoryWithOptions(other, filepath.Join(link, "work"), MapOptions{})
+	if !errors.Is(err, ErrDirectoryAlreadyMapped) {
+		t.Fatalf("mapping through a symlink error = %v, want ErrDirectoryAlreadyMapped", err)
+	}
+}
+
+func TestMapProfileToDirectory_DuplicateHandEdited(t *testing.T) {
+	tmpDir, gitConfigPath, cleanup := setupMappingTestEnv(t)
+	defer cleanup()
+
+	work := filepath.Join(tmpDir, "Work")
+	if err := os.MkdirAll(work, 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	// Written by hand: different case and no trailing slash
+	content := "[includeIf \"gitdir/i:" + filepath.ToSlash(filepath.Join(tmpDir, "work")) + "\"]\n    path = ~/.gitconfig-work\n"
+	if err := os.WriteFile(gitConfigPath, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write git config: %v", err)
+	}
+
+	prof := &profile.Profile{Name: "other", Email: "other@example.com"}
+	if err := MapProfileToDirectoryWithOptions(prof, work, MapOptions{}); !errors.Is(err, ErrDirectoryAlreadyMapped) {
+		t.Fatalf("MapProfileToDirectoryWithOptions() error = %v, want ErrDirectoryAlreadyMapped", err)
+	}
+
+	// --force replaces the old block instead of adding a conflicting one
+	if err := MapProfileToDirectoryWithOptions(prof, work, MapOptions{Force: true}); err != nil {
+		t.Fatalf("MapProfileToDirectoryWithOptions(Force) error = %v", err)
+	}
+	mappings, err := ParseMappings()
+	if err != nil {
+		t.Fatalf("ParseMappings() error = %v", err)
+	}
+	if len(mappings) != 1 || mappings[0].Profile != "other" {
+		t.Errorf("mappings after a forced map = %+v, want only other", mappings)
+	}
+}
+
+func TestOverlappingMappings(t *testing.T) {
+	tmpDir, _, cleanup := setupMappingTestEnv(t)
+	defer cleanup()
+
+	work := &profile.Profile{Name: "work", Email: "work@example.com"}
+	personal := &profile.Profile{Name: "personal", Email: "me@example.com"}
+	if err := MapProfileToDirectoryWithOptions(work, filepath.Join(tmpDir, "src"), MapOptions{AllowMissing: true}); err != nil {
+		t.Fatalf("MapProfileToDirectoryWithOptions() error = %v", err)
+	}
+	if err := MapProfileToDirectoryWithOptions(personal, filepath.Join(tmpDir, "other", "oss"), MapOptions{AllowMissing: true}); err != nil {
+		t.Fatalf("MapProfileToDirectoryWithOptions() error = %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		dir     string
+		profile string
+		want    []string
+	}{
+		{"child of another profile", filepath.Join(tmpDir, "src", "oss"), "personal", []string{"work"}},
+		{"parent of another profile", filepath.Join(tmpDir, "other"), "work", []string{"personal"}},
+		{"same profile nested", filepath.Join(tmpDir, "src", "team"), "work", nil},
+		{"sibling with shared prefix", filepath.Join(tmpDir, "src2"), "personal", nil},
+		{"unrelated", filepath.Join(tmpDir, "elsewhere"), "personal", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := OverlappingMappings(tt.dir, tt.profile)
+			if err != nil {
+				t.Fatalf("OverlappingMappings() error = %v", err)
+			}
+			var profiles []string
+			for _, m := range got {
+				profiles = append(profiles, m.Profile)
+			}
+			if !reflect.DeepEqual(profiles, tt.want) {
+				t.Errorf("OverlappingMappings() profiles = %v, want %v", profiles, tt.want)
+			}
+		})
+	}
+}
+
+func TestMapProfileToDirectory_RejectsFiles(t *testing.T) {
+	tmpDir, _, cleanup := setupMappingTestEnv(t)
+	defer cleanup()
+
+	prof := &profile.Profile{Name: "work", Email: "work@example.com"}
+	file := filepath.Join(tmpDir, "notes.txt")
+	if err := os.WriteFile(file, []byte("notes"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	dir := filepath.Join(tmpDir, "work")
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		path    string
+		opts    MapOptions
+		wantErr error
+	}{
+		{name: "file", path: file, wantErr: ErrNotADirectory},
+		// --create and --allow-missing are about missing paths, not files
+		{name: "file with create", path: file, opts: MapOptions{Create: true}, wantErr: ErrNotADirectory},
+		{name: "directory", path: dir},
+		{name: "missing", path: filepath.Join(tmpDir, "wrok"), wantErr: ErrDirectoryNotFound},
+		{name: "missing allowed", path: filepath.Join(tmpDir, "later"), opts: MapOptions{AllowMissing: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := MapProfileToDirectoryWithOptions(prof, tt.path, tt.opts)
+			if tt.wantErr == nil {
+				if err != nil {
+					t.Fatalf("MapProfileToDirectoryWithOptions() error = %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("MapProfileToDirectoryWithOptions() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == ErrNotADirectory && !strings.Contains(err.Error(), "resolved to "+file) {
+				t.Errorf("error = %q, want the resolved path", err)
+			}
+		})
+	}
+
+	if m, _ := GetMappingForDirectory(file); m != nil {
+		t.Errorf("file was mapped: %+v", m)
+	}
+}
+
+func TestUnmapDirectory_File(t *testing.T) {
+	tmpDir, _, cleanup := setupMappingTestEnv(t)
+	defer cleanup()
+
+	file := filepath.Join(tmpDir, "notes.txt")
+	if err := os.WriteFile(file, []byte("notes"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	prof := &profile.Profile{Name: "work", Email: "work@example.com"}
+	if err := MapProfileToDirectoryWithOptions(prof, filepath.Join(tmpDir, "work"), MapOptions{Create: true}); err != nil {
+		t.Fatalf("MapProfileToDirectoryWithOptions() error = %v", err)
+	}
+
+	err := UnmapDirectory(file)
+	if !errors.Is(err, ErrNotADirectory) || !strings.Contains(err.Error(), file) {
+		t.Errorf("UnmapDirectory(file) error = %v, want ErrNotADirectory naming the path", err)
+	}
+	if err := UnmapDirectory(filepath.Join(tmpDir, "missing")); !errors.Is(err, ErrMappingNotFound) {
+		t.Errorf("UnmapDirectory(missing) error = %v, want ErrMappingNotFound", err)
+	}
+}
+
+func TestWriteGitConfig_KeepsSymlink(t *testing.T) {
+	tmpDir, gitConfigPath, cleanup := setupMappingTestEnv(t)
+	defer cleanup()
+
+	target := filepath.Join(tmpDir, "dotfiles", "gitconfig")
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		t.Fatalf("Failed to create dotfiles: %v", err)
+	}
+	if err := os.WriteFile(target, []byte("[user]\n    name = Jane Doe\n"), 0644); err != nil {
+		t.Fatalf("Failed to write git config: %v", err)
+	}
+	if err := os.Symlink(target, gitConfigPath); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+
+	if err := addIncludeIfBlock("/work/", "~/.gitconfig-work", ConditionGitDirI, ""); err != nil {
+		t.Fatalf("addIncludeIfBlock() error = %v", err)
+	}
+	info, err := os.Lstat(gitConfigPath)
+	if err != nil || info.Mode()&os.ModeSymlink == 0 {
+		t.Fatalf("~/.gitconfig should still be a symlink, got mode %v, err %v", info.Mode(), err)
+	}
+	data, _ := os.ReadFile(target)
+	if !strings.Contains(string(data), `[includeIf "gitdir/i:/work/"]`) {
+		t.Errorf("symlink target should hold the block, got:\n%s", data)
+	}
+
+	if err := removeIncludeIfBlock("/work/"); err != nil {
+		t.Fatalf("removeIncludeIfBlock() error = %v", err)
+	}
+	if info, err := os.Lstat(gitConfigPath); err != nil || info.Mode()&os.ModeSymlink == 0 {
+		t.Errorf("~/.gitconfig should still be a symlink after removal")
+	}
+	if data, _ := os.ReadFile(target); string(data) != "[user]\n    name = Jane Doe\n" {
+		t.Errorf("symlink target after removal = %q", data)
+	}
+}
+
+func TestWriteGitConfig_RelativeAndDanglingSymlink(t *testing.T) {
+	tmpDir, gitConfigPath, cleanup := setupMappingTestEnv(t)
+	defer cleanup()
+
+	// The link is relative and its target does not exist yet
+	if err := os.Symlink(filepath.Join("dotfiles", "gitconfig"), gitConfigPath); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+	if err := addIncludeIfBlock("/work/", "~/.gitconfig-work", ConditionGitDirI, ""); err != nil {
+		t.Fatalf("addIncludeIfBlock() error = %v", err)
+	}
+	if info, err := os.Lstat(gitConfigPath); err != nil || info.Mode()&os.ModeSymlink == 0 {
+		t.Errorf("~/.gitconfig should still be a symlink")
+	}
+	data, err := os.ReadFile(filepath.Join(tmpDir, "dotfiles", "gitconfig"))
+	if err != nil || !strings.Contains(string(data), "[includeIf") {
+		t.Errorf("link target = %q, %v; want the block", data, err)
+	}
+}
+
+func TestWriteGitConfig_ReadOnlyTarget(t *testing.T) {
+	tmpDir, gitConfigPath, cleanup := setupMappingTestEnv(t)
+	defer cleanup()
+
+	target := filepath.Join(tmpDir, "gitconfig-readonly")
+	original := "[user]\n    name = Jane Doe\n"
+	if err := os.WriteFile(target, []byte(original), 0444); err != nil {
+		t.Fatalf("Failed to write git config: %v", err)
+	}
+	if err := os.Symlink(target, gitConfigPath); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+
+	err := addIncludeIfBlock("/work/", "~/.gitconfig-work", ConditionGitDirI, "")
+	if !errors.Is(err, ErrGitConfigNotWritable) {
+		t.Fatalf("addIncludeIfBlock() error = %v, want ErrGitConfigNotWritable", err)
+	}
+	if !strings.Contains(err.Error(), "--gitconfig") || !strings.Contains(err.Error(), target) {
+		t.Errorf("error should name the target and suggest --gitconfig, got %q", err)
+	}
+	if info, err := os.Lstat(gitConfigPath); err != nil || info.Mode()&os.ModeSymlink == 0 {
+		t.Errorf("~/.gitconfig should still be a symlink")
+	}
+	if data, _ := os.ReadFile(target); string(data) != original {
+		t.Errorf("read-only target was changed to %q", data)
+	}
+}
+
+func TestSetGitConfigPath(t *testing.T) {
+	tmpDir, gitConfigPath, cleanup := setupMappingTestEnv(t)
+	defer cleanup()
+
+	managed := filepath.Join(tmpDir, "gidtree.gitconfig")
+	SetGitConfigPath(managed)
+	defer SetGitConfigPath("")
+
+	if got, _ := GetGitConfigPath(); got != managed {
+		t.Errorf("GetGitConfigPath() = %q, want %q", got, managed)
+	}
+	if err := addIncludeIfBlock("/work/", "~/.gitconfig-work", ConditionGitDirI, ""); err != nil {
+		t.Fatalf("addIncludeIfBlock() error = %v", err)
+	}
+	if _, err := os.Stat(gitConfigPath); !os.IsNotExist(err) {
+		t.Errorf("~/.gitconfig should not be written, stat error = %v", err)
+	}
+	mappings, err := ParseMappings()
+	if err != nil || len(mappings) != 1 {
+		t.Errorf("ParseMappings() = %+v, %v; want the block from the redirected file", mappings, err)
+	}
+
+	SetGitConfigPath("")
+	if got, _ := GetGitConfigPath(); got != gitConfigPath {
+		t.Errorf("GetGitConfigPath() after reset = %q, want %q", got, gitConfigPath)
+	}
+}
+
+// writeDuplicateMappings seeds ~/.gitconfig with three blocks for dir, mapping
+// it to a, b and c in that order.
+func writeDuplicateMappings(t *testing.T, gitConfigPath, dir string) {
+	t.Helper()
+	var b strings.Builder
+	b.WriteString("[user]\n    name = Jane Doe\n")
+	for _, name := range []string{"a", "b", "c"} {
+		fmt.Fprintf(&b, "\n[includeIf \"gitdir/i:%s\"]\n    path = ~/.gitconfig-%s\n", dir, name)
+	}
+	if err := os.WriteFile(gitConfigPath, []byte(b.String()), 0644); err != nil {
+		t.Fatalf("Failed to write git config: %v", err)
+	}
+}
+
+func TestParseMappings_MarksDuplicates(t *testing.T) {
+	tmpDir, gitConfigPath, cleanup := setupMappingTestEnv(t)
+	defer cleanup()
+
+	dir := utils.EnsureTrailingSlash(filepath.Join(tmpDir, "work"))
+	writeDuplicateMappings(t, gitConfigPath, dir)
+
+	mappings, err := ParseMappings()
+	if err != nil {
+		t.Fatalf("ParseMappings() error = %v", err)
+	}
+	var duplicates []bool
+	for _, m := range mappings {
+		duplicates = append(duplicates, m.Duplicate)
+	}
+	if want := []bool{true, true, false}; !reflect.DeepEqual(duplicates, want) {
+		t.Errorf("Duplicate = %v, want %v", duplicates, want)
+	}
+
+	// git applies the last block, so lookups must too
+	m, err := GetMappingForDirectory(filepath.Join(dir, "repo"))
+	if err != nil || m == nil || m.Profile != "c" {
+		t.Errorf("GetMappingForDirectory() = %+v, %v; want profile c", m, err)
+	}
+}
+
+func TestUnmapDirectory_RemovesAllDuplicates(t *testing.T) {
+	tmpDir, gitConfigPath, cleanup := setupMappingTestEnv(t)
+	defer cleanup()
+
+	dir := filepath.Join(tmpDir, "work")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	writeDuplicateMappings(t, gitConfigPath, utils.EnsureTrailingSlash(dir))
+
+	if err := UnmapDirectory(dir); err != nil {
+		t.Fatalf("UnmapDirectory() error = %v", err)
+	}
+	content, err := os.ReadFile(gitConfigPath)
+	if err != nil {
+		t.Fatalf("Failed to read git config: %v", err)
+	}
+	if want := "[user]\n    name = Jane Doe\n"; string(content) != want {
+		t.Errorf("git config after unmap =\n%q\nwant\n%q", content, want)
+	}
+}
+
+func TestMergeDuplicateMappings(t *testing.T) {
+	tmpDir, gitConfigPath, cleanup := setupMappingTestEnv(t)
+	defer cleanup()
+
+	dir := utils.EnsureTrailingSlash(filepath.Join(tmpDir, "work"))
+	writeDuplicateMappings(t, gitConfigPath, dir)
+
+	removed, err := MergeDuplicateMappings(dir)
+	if err != nil {
+		t.Fatalf("MergeDuplicateMappings() error = %v", err)
+	}
+	if len(removed) != 2 || removed[0].Profile != "a" || removed[1].Profile != "b" {
+		t.Errorf("MergeDuplicateMappings() = %+v, want a and b", removed)
+	}
+
+	content, err := os.ReadFile(gitConfigPath)
+	if err != nil {
+		t.Fatalf("Failed to read git config: %v", err)
+	}
+	want := "[user]\n    name = Jane Doe\n\n[includeIf \"gitdir/i:" + dir + "\"]\n    path = ~/.gitconfig-c\n"
+	if string(content) != want {
+		t.Errorf("git config after merge =\n%q\nwant\n%q", content, want)
+	}
+
+	// Nothing left to merge, nothing written
+	removed, err = MergeDuplicateMappings(dir)
+	if err != nil || len(removed) != 0 {
+		t.Errorf("MergeDuplicateMappings() again = %+v, %v; want none", removed, err)
+	}
+}
+
+func TestMoveMapping(t *testing.T) {
+	fixNow(t, "2024-05-01")
+	tmpDir, gitConfigPath, cleanup := setupMappingTestEnv(t)
+	defer cleanup()
+
+	oldDir := filepath.Join(tmpDir, "src", "acme")
+	newDir := filepath.Join(tmpDir, "clients", "acme")
+	other := filepath.Join(tmpDir, "personal")
+	prof := &profile.Profile{Name: "work", Email: "work@example.com"}
+	for _, dir := range []string{oldDir, other} {
+		if err := MapProfileToDirectoryWithOptions(prof, dir, MapOptions{Create: true, Note: "acme"}); err != nil {
+			t.Fatalf("MapProfileToDirectoryWithOptions() error = %v", err)
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(newDir), 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	if err := os.Rename(oldDir, newDir); err != nil {
+		t.Fatalf("Failed to move directory: %v", err)
+	}
+
+	m, block, err := MoveMapping(oldDir, newDir)
+	if err != nil {
+		t.Fatalf("MoveMapping() error = %v", err)
+	}
+	wantDir := utils.EnsureTrailingSlash(newDir)
+	if m.Directory != wantDir || m.Profile != "work" || m.Note != "acme" {
+		t.Errorf("MoveMapping() = %+v", m)
+	}
+	wantBlock := "# gidtree: profile=work mapped=2024-05-01 note=\"acme\"\n" +
+		"[includeIf \"gitdir/i:" + wantDir + "\"]\n    path = ~/.gitconfig-work\n"
+	if block != wantBlock {
+		t.Errorf("MoveMapping() block = %q, want %q", block, wantBlock)
+	}
+	if got, err := GetMappingForDirectory(newDir); err != nil || got == nil || got.Profile != "work" {
+		t.Errorf("GetMappingForDirectory(new) = %+v, %v", got, err)
+	}
+	content, err := os.ReadFile(gitConfigPath)
+	if err != nil {
+		t.Fatalf("Failed to read git config: %v", err)
+	}
+	if strings.Contains(string(content), "src") {
+		t.Errorf("git config still mentions the old directory:\n%s", content)
+	}
+
+	tests := []struct {
+		name    string
+		from    string
+		to      string
+		wantErr error
+	}{
+		{name: "not mapped", from: filepath.Join(tmpDir, "nowhere"), to: filepath.Join(tmpDir, "clients"), wantErr: ErrMappingNotFound},
+		{name: "missing target", from: newDir, to: filepath.Join(tmpDir, "gone"), wantErr: ErrDirectoryNotFound},
+		{name: "target mapped", from: newDir, to: other, wantErr: ErrDirectoryAlreadyMapped},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := MoveMapping(tt.from, tt.to); !errors.Is(err, tt.wantErr) {
+				t.Errorf("MoveMapping() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestMapProfileToDirectory_Broad(t *testing.T) {
+	tmpDir, _, cleanup := setupMappingTestEnv(t)
+	defer cleanup()
+
+	code := filepath.Join(tmpDir, "code")
+	if err := os.MkdirAll(code, 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	prof := &profile.Profile{Name: "personal", Email: "me@example.com"}
+
+	tests := []struct {
+		name      string
+		dir       string
+		wantBroad bool
+	}{
+		{name: "home as ~", dir: "~", wantBroad: true},
+		{name: "home with trailing slash", dir: tmpDir + string(filepath.Separator), wantBroad: true},
+		{name: "filesystem root", dir: string(filepath.Separator), wantBroad: true},
+		{name: "subdirectory", dir: code},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsBroadDirectory(tt.dir); got != tt.wantBroad {
+				t.Errorf("IsBroadDirectory(%q) = %v, want %v", tt.dir, got, tt.wantBroad)
+			}
+			err := MapProfileToDirectoryWithOptions(prof, tt.dir, MapOptions{})
+			if tt.wantBroad && !errors.Is(err, ErrDirectoryTooBroad) {
+				t.Errorf("MapProfileToDirectoryWithOptions(%q) error = %v, want ErrDirectoryTooBroad", tt.dir, err)
+			}
+			if !tt.wantBroad && err != nil {
+				t.Errorf("MapProfileToDirectoryWithOptions(%q) error = %v", tt.dir, err)
+			}
+		})
+	}
+
+	// --allow-broad maps it anyway
+	if err := MapProfileToDirectoryWithOptions(prof, "~", MapOptions{AllowBroad: true}); err != nil {
+		t.Fatalf("MapProfileToDirectoryWithOptions(~, AllowBroad) error = %v", err)
+	}
+	mappings, err := ParseMappings()
+	if err != nil {
+		t.Fatalf("ParseMappings() error = %v", err)
+	}
+	if len(mappings) != 2 {
+		t.Errorf("ParseMappings() = %+v, want the subdirectory and the home directory", mappings)
+	}
+}
+
+func TestMapProfileToDirectory_RequireGitConfig(t *testing.T) {
+	tmpDir, gitConfigPath, cleanup := setupMappingTestEnv(t)
+	defer cleanup()
+	t.Setenv("GIT_CONFIG_GLOBAL", "")
+
+	if path, missing := MissingGitConfig(); !missing || path != gitConfigPath {
+		t.Fatalf("MissingGitConfig() = %q, %v; want %q, true", path, missing, gitConfigPath)
+	}
+	err := CheckGitConfig()
+	if !errors.Is(err, ErrGitConfigMissing) || !strings.Contains(err.Error(), "--create-gitconfig") || !strings.Contains(err.Error(), "GIT_CONFIG_GLOBAL") {
+		t.Errorf("CheckGitConfig() = %v, want ErrGitConfigMissing naming the alternatives", err)
+	}
+
+	prof := &profile.Profile{Name: "work", Email: "work@example.com"}
+	dir := filepath.Join(tmpDir, "work")
+	opts := MapOptions{Create: true, RequireGitConfig: true}
+	if err := MapProfileToDirectoryWithOptions(prof, dir, opts); !errors.Is(err, ErrGitConfigMissing) {
+		t.Fatalf("MapProfileToDirectoryWithOptions() error = %v, want ErrGitConfigMissing", err)
+	}
+	for _, path := range []string{gitConfigPath, dir, filepath.Join(tmpDir, ".gitconfig-work")} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("%s should not be created, stat error = %v", path, err)
+		}
+	}
+
+	// git reading another file is named in the error
+	t.Setenv("GIT_CONFIG_GLOBAL", filepath.Join(tmpDir, "dotfiles", "gitconfig"))
+	if err := CheckGitConfig(); err == nil || !strings.Contains(err.Error(), "--gitconfig "+filepath.Join(tmpDir, "dotfiles", "gitconfig")) {
+		t.Errorf("CheckGitConfig() = %v, want a --gitconfig suggestion for GIT_CONFIG_GLOBAL", err)
+	}
+
+	// A redirected file was named on purpose
+	SetGitConfigPath(filepath.Join(tmpDir, "gidtree.gitconfig"))
+	defer SetGitConfigPath("")
+	if _, missing := MissingGitConfig(); missing {
+		t.Error("a file set with SetGitConfigPath should never be missing")
+	}
+	SetGitConfigPath("")
+
+	if err := MapProfileToDirectoryWithOptions(prof, dir, MapOptions{Create: true}); err != nil {
+		t.Fatalf("MapProfileToDirectoryWithOptions() error = %v", err)
+	}
+	if err := CheckGitConfig(); err != nil {
+		t.Errorf("CheckGitConfig() after mapping = %v, want nil", err)
+	}
+}
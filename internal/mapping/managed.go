@@ -0,0 +1,175 @@
+package mapping
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/thuanlegit/git-identitree/internal/config"
+	"github.com/thuanlegit/git-identitree/internal/gitconfig"
+	"github.com/thuanlegit/git-identitree/internal/logging"
+	"github.com/thuanlegit/git-identitree/internal/profile"
+	"github.com/thuanlegit/git-identitree/internal/utils"
+)
+
+// managedIncludeFile is the file in ~/.gidtree that holds the includeIf blocks
+// when managed_include is set.
+const managedIncludeFile = "gitconfig"
+
+// ManagedIncludePath returns the path to ~/.gidtree/gitconfig.
+func ManagedIncludePath() (string, error) {
+	dir, err := profile.GetProfilesDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, managedIncludeFile), nil
+}
+
+// managedIncludeEnabled reports whether the includeIf blocks live in the
+// managed include file. --gitconfig takes precedence over the setting.
+func managedIncludeEnabled() bool {
+	if gitConfigOverride != "" {
+		return false
+	}
+	cfg, err := config.Load()
+	return err == nil && cfg.ManagedInclude
+}
+
+// blocksPath returns the file gidtree adds its includeIf blocks to and
+// removes them from.
+func blocksPath() (string, error) {
+	if managedIncludeEnabled() {
+		return ManagedIncludePath()
+	}
+	return GetGitConfigPath()
+}
+
+// managedIncludeValue is the path ~/.gitconfig includes the managed file with.
+func managedIncludeValue() (string, error) {
+	path, err := ManagedIncludePath()
+	if err != nil {
+		return "", err
+	}
+	home, err := utils.Root()
+	if err == nil && strings.HasPrefix(path, home) {
+		path = filepath.ToSlash(strings.Replace(path, home, "~", 1))
+	}
+	return path, nil
+}
+
+// includesManagedFile reports whether d has an [include] section with a path
+// to the managed include file.
+func includesManagedFile(d *gitconfig.Document, managedPath string) bool {
+	for _, s := range d.Sections {
+		if !s.Is("include") || s.HasSubsection {
+			continue
+		}
+		for _, value := range s.GetAll("path") {
+			path, err := utils.ExpandPath(value)
+			if err == nil && filepath.Clean(path) == filepath.Clean(managedPath) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// withManagedInclude appends an [include] of the managed file to d, unless it
+// already includes it, and reports whether it did. It comes last so the
+// blocks in the managed file win over any left in ~/.gitconfig.
+func withManagedInclude(d *gitconfig.Document) (bool, error) {
+	managedPath, err := ManagedIncludePath()
+	if err != nil {
+		return false, err
+	}
+	if includesManagedFile(d, managedPath) {
+		return false, nil
+	}
+	value, err := managedIncludeValue()
+	if err != nil {
+		return false, err
+	}
+	s := gitconfig.NewSection("include", "")
+	s.Add("path", value)
+	d.AppendSection(s)
+	return true, nil
+}
+
+// ensureManagedInclude adds the [include] of the managed file to ~/.gitconfig
+// once.
+func ensureManagedInclude() error {
+	gitConfigPath, err := GetGitConfigPath()
+	if err != nil {
+		return err
+	}
+	d, err := readGitConfigDocument(gitConfigPath)
+	if err != nil {
+		return err
+	}
+	added, err := withManagedInclude(d)
+	if err != nil || !added {
+		return err
+	}
+	logging.Logger().Debug("adding include of the managed git config", "path", gitConfigPath)
+	return writeGitConfigData(gitConfigPath, d.Serialize())
+}
+
+// MigrateIncludes moves the includeIf blocks gidtree wrote into ~/.gitconfig,
+// with their metadata comments, to the managed include file and makes
+// ~/.gitconfig include that file. Blocks gidtree did not write stay where they
+// are. It returns the moved mappings; with dryRun nothing is written.
+func MigrateIncludes(dryRun bool) ([]Mapping, error) {
+	gitConfigPath, err := GetGitConfigPath()
+	if err != nil {
+		return nil, err
+	}
+	managedPath, err := ManagedIncludePath()
+	if err != nil {
+		return nil, err
+	}
+
+	d, err := readGitConfigDocument(gitConfigPath)
+	if err != nil {
+		return nil, err
+	}
+	managed, err := readGitConfigDocument(managedPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var mappings []Mapping
+	for _, s := range includeIfSections(d) {
+		path, ok := s.Get("path")
+		if !ok || !isManagedBlock(s.Subsection, path) {
+			continue
+		}
+		m, _ := sectionMapping(s)
+		mappings = append(mappings, m)
+
+		meta := dropSectionMeta(s)
+		d.RemoveSection(s)
+		s.Leading = meta
+		managed.AppendSection(s)
+	}
+
+	if _, err := withManagedInclude(d); err != nil {
+		return nil, err
+	}
+	if dryRun {
+		return mappings, nil
+	}
+
+	// Write the blocks to their new home before taking them out of the old one
+	if len(mappings) > 0 {
+		if _, err := backupGitConfig(gitConfigPath); err != nil {
+			return nil, fmt.Errorf("failed to back up git config: %w", err)
+		}
+		if err := writeGitConfigData(managedPath, managed.Serialize()); err != nil {
+			return nil, err
+		}
+	}
+	if err := writeGitConfigData(gitConfigPath, d.Serialize()); err != nil {
+		return nil, err
+	}
+	return mappings, nil
+}
@@ -0,0 +1,191 @@
+package mapping
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/thuanlegit/git-identitree/internal/config"
+	"github.com/thuanlegit/git-identitree/internal/profile"
+)
+
+// enableManagedInclude turns managed_include on in the test's gidtree config.
+func enableManagedInclude(t *testing.T) {
+	t.Helper()
+	cfg := config.Default()
+	cfg.ManagedInclude = true
+	if err := config.Save(&cfg); err != nil {
+		t.Fatalf("Failed to save config: %v", err)
+	}
+	Invalidate()
+}
+
+func TestManagedInclude_MapAndUnmap(t *testing.T) {
+	tmpDir, gitConfigPath, cleanup := setupMappingTestEnv(t)
+	defer cleanup()
+	enableManagedInclude(t)
+
+	user := "[user]\n    name = Jane Doe\n"
+	if err := os.WriteFile(gitConfigPath, []byte(user), 0644); err != nil {
+		t.Fatalf("Failed to write git config: %v", err)
+	}
+	prof := &profile.Profile{Name: "work", Email: "jane@acme.com"}
+	for _, name := range []string{"acme", "globex"} {
+		dir := filepath.Join(tmpDir, name)
+		if err := MapProfileToDirectoryWithOptions(prof, dir, MapOptions{Create: true}); err != nil {
+			t.Fatalf("MapProfileToDirectoryWithOptions(%s) error = %v", name, err)
+		}
+	}
+
+	managedPath, _ := ManagedIncludePath()
+	managed, err := os.ReadFile(managedPath)
+	if err != nil || strings.Count(string(managed), "[includeIf") != 2 {
+		t.Errorf("managed include file = %q, %v; want both blocks", managed, err)
+	}
+	main, _ := os.ReadFile(gitConfigPath)
+	want := user + "\n[include]\n    path = ~/.gidtree/gitconfig\n"
+	if string(main) != want {
+		t.Errorf("~/.gitconfig =\n%q\nwant the include added once\n%q", main, want)
+	}
+
+	mappings, err := ParseMappings()
+	if err != nil || len(mappings) != 2 {
+		t.Fatalf("ParseMappings() = %+v, %v; want both mappings", mappings, err)
+	}
+
+	if err := UnmapDirectory(filepath.Join(tmpDir, "acme")); err != nil {
+		t.Fatalf("UnmapDirectory() error = %v", err)
+	}
+	if managed, _ := os.ReadFile(managedPath); strings.Contains(string(managed), "acme") {
+		t.Errorf("unmap should remove the block from the managed file, got:\n%s", managed)
+	}
+	if main, _ := os.ReadFile(gitConfigPath); string(main) != want {
+		t.Errorf("unmap should leave ~/.gitconfig alone, got:\n%s", main)
+	}
+}
+
+func TestManagedInclude_ReadsBothFiles(t *testing.T) {
+	_, gitConfigPath, cleanup := setupMappingTestEnv(t)
+	defer cleanup()
+	enableManagedInclude(t)
+
+	legacy := "[includeIf \"gitdir/i:/legacy/\"]\n    path = ~/.gitconfig-old\n"
+	if err := os.WriteFile(gitConfigPath, []byte(legacy), 0644); err != nil {
+		t.Fatalf("Failed to write git config: %v", err)
+	}
+	managedPath, _ := ManagedIncludePath()
+	if err := os.MkdirAll(filepath.Dir(managedPath), 0700); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	if err := os.WriteFile(managedPath, []byte("[includeIf \"gitdir/i:/work/\"]\n    path = ~/.gitconfig-work\n"), 0644); err != nil {
+		t.Fatalf("Failed to write managed include: %v", err)
+	}
+
+	mappings, err := ParseMappings()
+	if err != nil || len(mappings) != 2 || mappings[0].Profile != "old" || mappings[1].Profile != "work" {
+		t.Fatalf("ParseMappings() = %+v, %v; want ~/.gitconfig's mapping, then the managed one", mappings, err)
+	}
+
+	// Only the managed file is edited
+	if err := removeIncludeIfBlock("/legacy/"); !errors.Is(err, ErrMappingNotFound) {
+		t.Errorf("removeIncludeIfBlock() of a block in ~/.gitconfig error = %v, want ErrMappingNotFound", err)
+	}
+	if got, _ := os.ReadFile(gitConfigPath); string(got) != legacy {
+		t.Errorf("~/.gitconfig was changed to %q", got)
+	}
+}
+
+func TestManagedInclude_Off(t *testing.T) {
+	_, gitConfigPath, cleanup := setupMappingTestEnv(t)
+	defer cleanup()
+
+	if err := addIncludeIfBlock("/work/", "~/.gitconfig-work", ConditionGitDirI, ""); err != nil {
+		t.Fatalf("addIncludeIfBlock() error = %v", err)
+	}
+	data, _ := os.ReadFile(gitConfigPath)
+	if !strings.Contains(string(data), "[includeIf") || strings.Contains(string(data), "[include]") {
+		t.Errorf("~/.gitconfig = %q, want the block and no include", data)
+	}
+	managedPath, _ := ManagedIncludePath()
+	if _, err := os.Stat(managedPath); !os.IsNotExist(err) {
+		t.Errorf("managed include file should not exist, stat error = %v", err)
+	}
+}
+
+func TestMigrateIncludes(t *testing.T) {
+	_, gitConfigPath, cleanup := setupMappingTestEnv(t)
+	defer cleanup()
+
+	original := strings.Join([]string{
+		"[user]",
+		"    name = Jane Doe",
+		"",
+		`# gidtree: profile=work mapped=2024-05-01 note="acme"`,
+		`[includeIf "gitdir/i:/work/"]`,
+		"    path = ~/.gitconfig-work",
+		"",
+		`[includeIf "onbranch:release"]`,
+		"    path = ~/.gitconfig-release-signing",
+		"",
+		`[includeIf "gitdir:/oss/"]`,
+		"    path = ~/.gitconfig-oss",
+		"",
+	}, "\n")
+	if err := os.WriteFile(gitConfigPath, []byte(original), 0644); err != nil {
+		t.Fatalf("Failed to write git config: %v", err)
+	}
+
+	moved, err := MigrateIncludes(true)
+	if err != nil || len(moved) != 2 {
+		t.Fatalf("MigrateIncludes(dry run) = %+v, %v; want two blocks", moved, err)
+	}
+	if got, _ := os.ReadFile(gitConfigPath); string(got) != original {
+		t.Errorf("dry run changed ~/.gitconfig to %q", got)
+	}
+
+	moved, err = MigrateIncludes(false)
+	if err != nil {
+		t.Fatalf("MigrateIncludes() error = %v", err)
+	}
+	if len(moved) != 2 || moved[0].Profile != "work" || moved[0].Note != "acme" || moved[1].Directory != "/oss/" {
+		t.Errorf("MigrateIncludes() = %+v", moved)
+	}
+
+	want := strings.Join([]string{
+		"[user]",
+		"    name = Jane Doe",
+		"",
+		`[includeIf "onbranch:release"]`,
+		"    path = ~/.gitconfig-release-signing",
+		"",
+		"[include]",
+		"    path = ~/.gidtree/gitconfig",
+		"",
+	}, "\n")
+	if got, _ := os.ReadFile(gitConfigPath); string(got) != want {
+		t.Errorf("~/.gitconfig =\n%q\nwant\n%q", got, want)
+	}
+	managedPath, _ := ManagedIncludePath()
+	managed, _ := os.ReadFile(managedPath)
+	wantManaged := strings.Join([]string{
+		`# gidtree: profile=work mapped=2024-05-01 note="acme"`,
+		`[includeIf "gitdir/i:/work/"]`,
+		"    path = ~/.gitconfig-work",
+		"",
+		`[includeIf "gitdir:/oss/"]`,
+		"    path = ~/.gitconfig-oss",
+	}, "\n")
+	if string(managed) != wantManaged {
+		t.Errorf("managed include file =\n%q\nwant\n%q", managed, wantManaged)
+	}
+
+	// Running it again moves nothing and adds no second include
+	if moved, err := MigrateIncludes(false); err != nil || len(moved) != 0 {
+		t.Errorf("second MigrateIncludes() = %+v, %v", moved, err)
+	}
+	if got, _ := os.ReadFile(gitConfigPath); string(got) != want {
+		t.Errorf("second migration changed ~/.gitconfig to %q", got)
+	}
+}
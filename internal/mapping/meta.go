@@ -0,0 +1,87 @@
+package mapping
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// metaCommentPrefix starts the comment gidtree writes above each includeIf
+// block it adds, recording when and why the directory was mapped:
+//
+//	# gidtree: profile=work mapped=2024-05-01 note="acme contract"
+const metaCommentPrefix = "# gidtree:"
+
+// metaDateLayout is the layout of the mapped= date.
+const metaDateLayout = "2006-01-02"
+
+// now returns the current time. Replaced in tests.
+var now = time.Now
+
+// mappingMeta is the metadata comment above an includeIf block.
+type mappingMeta struct {
+	profile string
+	mapped  time.Time
+	note    string
+}
+
+// String returns the comment line.
+func (m mappingMeta) String() string {
+	var b strings.Builder
+	b.WriteString(metaCommentPrefix)
+	if m.profile != "" {
+		fmt.Fprintf(&b, " profile=%s", m.profile)
+	}
+	if !m.mapped.IsZero() {
+		fmt.Fprintf(&b, " mapped=%s", m.mapped.Format(metaDateLayout))
+	}
+	if m.note != "" {
+		fmt.Fprintf(&b, " note=%s", strconv.Quote(m.note))
+	}
+	return b.String()
+}
+
+// isMetaComment reports whether line is a metadata comment.
+func isMetaComment(line string) bool {
+	return strings.HasPrefix(strings.TrimSpace(line), metaCommentPrefix)
+}
+
+// parseMappingMeta reads a metadata comment line. Unknown keys are skipped and
+// a value that does not parse is left empty, so a hand-edited comment never
+// stops the mapping itself from being read.
+func parseMappingMeta(line string) (mappingMeta, bool) {
+	rest, ok := strings.CutPrefix(strings.TrimSpace(line), metaCommentPrefix)
+	if !ok {
+		return mappingMeta{}, false
+	}
+
+	var meta mappingMeta
+	for rest = strings.TrimSpace(rest); rest != ""; rest = strings.TrimSpace(rest) {
+		key, value, found := strings.Cut(rest, "=")
+		if !found || strings.ContainsAny(key, " \t") {
+			break
+		}
+		rest = value
+		if strings.HasPrefix(value, `"`) {
+			quoted, err := strconv.QuotedPrefix(value)
+			if err != nil {
+				break
+			}
+			rest = value[len(quoted):]
+			value, _ = strconv.Unquote(quoted)
+		} else {
+			value, rest, _ = strings.Cut(value, " ")
+		}
+
+		switch key {
+		case "profile":
+			meta.profile = value
+		case "mapped":
+			meta.mapped, _ = time.Parse(metaDateLayout, value)
+		case "note":
+			meta.note = value
+		}
+	}
+	return meta, true
+}
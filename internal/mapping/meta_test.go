@@ -0,0 +1,190 @@
+package mapping
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/thuanlegit/git-identitree/internal/profile"
+)
+
+// fixNow makes now return the given date for the rest of the test.
+func fixNow(t *testing.T, date string) {
+	t.Helper()
+	day, err := time.Parse(metaDateLayout, date)
+	if err != nil {
+		t.Fatalf("invalid date %q: %v", date, err)
+	}
+	original := now
+	now = func() time.Time { return day }
+	t.Cleanup(func() { now = original })
+}
+
+func TestMappingMetaRoundTrip(t *testing.T) {
+	mapped, _ := time.Parse(metaDateLayout, "2024-05-01")
+	tests := []struct {
+		meta mappingMeta
+		want string
+	}{
+		{mappingMeta{profile: "work", mapped: mapped, note: "acme contract"}, `# gidtree: profile=work mapped=2024-05-01 note="acme contract"`},
+		{mappingMeta{profile: "work", mapped: mapped}, "# gidtree: profile=work mapped=2024-05-01"},
+		{mappingMeta{profile: "oss", note: `say "hi"`}, `# gidtree: profile=oss note="say \"hi\""`},
+	}
+	for _, tt := range tests {
+		if got := tt.meta.String(); got != tt.want {
+			t.Errorf("String() = %q, want %q", got, tt.want)
+		}
+		got, ok := parseMappingMeta("  " + tt.want)
+		if !ok || got != tt.meta {
+			t.Errorf("parseMappingMeta(%q) = %+v, %v; want %+v", tt.want, got, ok, tt.meta)
+		}
+	}
+}
+
+func TestParseMappingMetaTolerant(t *testing.T) {
+	tests := []struct {
+		line    string
+		ok      bool
+		profile string
+		note    string
+	}{
+		{"# gidtree: profile=work mapped=soon", true, "work", ""},
+		{"# gidtree: owner=me profile=work", true, "work", ""},
+		{`# gidtree: note="unterminated`, true, "", ""},
+		{"# gidtree:", true, "", ""},
+		{"# some other comment", false, "", ""},
+		{"; gidtree: profile=work", false, "", ""},
+	}
+	for _, tt := range tests {
+		got, ok := parseMappingMeta(tt.line)
+		if ok != tt.ok || got.profile != tt.profile || got.note != tt.note || !got.mapped.IsZero() {
+			t.Errorf("parseMappingMeta(%q) = %+v, %v", tt.line, got, ok)
+		}
+	}
+}
+
+func TestMappingNoteAndDate(t *testing.T) {
+	tmpDir, gitConfigPath, cleanup := setupMappingTestEnv(t)
+	defer cleanup()
+	fixNow(t, "2024-05-01")
+
+	workDir := filepath.Join(tmpDir, "work")
+	if err := os.MkdirAll(workDir, 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	prof := &profile.Profile{Name: "work", Email: "jane@acme.com"}
+	if err := MapProfileToDirectoryWithOptions(prof, workDir, MapOptions{Note: "acme contract"}); err != nil {
+		t.Fatalf("MapProfileToDirectoryWithOptions() error = %v", err)
+	}
+
+	content, err := os.ReadFile(gitConfigPath)
+	if err != nil {
+		t.Fatalf("Failed to read git config: %v", err)
+	}
+	if !strings.Contains(string(content), "# gidtree: profile=work mapped=2024-05-01 note=\"acme contract\"\n[includeIf") {
+		t.Errorf("git config has no metadata comment above the block:\n%s", content)
+	}
+
+	mappings, err := ParseMappings()
+	if err != nil {
+		t.Fatalf("ParseMappings() error = %v", err)
+	}
+	if len(mappings) != 1 {
+		t.Fatalf("ParseMappings() = %+v, want one mapping", mappings)
+	}
+	if mappings[0].Note != "acme contract" || mappings[0].CreatedAt.Format(metaDateLayout) != "2024-05-01" {
+		t.Errorf("mapping note = %q, created = %v", mappings[0].Note, mappings[0].CreatedAt)
+	}
+}
+
+func TestMappingWithoutComment(t *testing.T) {
+	_, gitConfigPath, cleanup := setupMappingTestEnv(t)
+	defer cleanup()
+
+	content := "[includeIf \"gitdir/i:/work/\"]\n    path = ~/.gitconfig-work\n"
+	if err := os.WriteFile(gitConfigPath, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write git config: %v", err)
+	}
+	mappings, err := ParseMappings()
+	if err != nil {
+		t.Fatalf("ParseMappings() error = %v", err)
+	}
+	if len(mappings) != 1 || mappings[0].Profile != "work" || mappings[0].Note != "" || !mappings[0].CreatedAt.IsZero() {
+		t.Errorf("ParseMappings() = %+v", mappings)
+	}
+
+	// Repointing a legacy block adds no comment
+	if err := addIncludeIfBlock("/work/", "~/.gitconfig-work", ConditionGitDirI, ""); err != nil {
+		t.Fatalf("addIncludeIfBlock() error = %v", err)
+	}
+	got, _ := os.ReadFile(gitConfigPath)
+	if string(got) != content {
+		t.Errorf("git config =\n%q\nwant\n%q", got, content)
+	}
+}
+
+func TestUpdateKeepsMappingDate(t *testing.T) {
+	_, gitConfigPath, cleanup := setupMappingTestEnv(t)
+	defer cleanup()
+	fixNow(t, "2025-01-01")
+
+	content := "# gidtree: profile=old mapped=2024-05-01 note=\"acme\"\n[includeIf \"gitdir/i:/work/\"]\n    path = ~/.gitconfig-old\n"
+	if err := os.WriteFile(gitConfigPath, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write git config: %v", err)
+	}
+	if err := addIncludeIfBlock("/work/", "~/.gitconfig-work", ConditionGitDirI, ""); err != nil {
+		t.Fatalf("addIncludeIfBlock() error = %v", err)
+	}
+	want := "# gidtree: profile=work mapped=2024-05-01 note=\"acme\"\n[includeIf \"gitdir/i:/work/\"]\n    path = ~/.gitconfig-work\n"
+	if got, _ := os.ReadFile(gitConfigPath); string(got) != want {
+		t.Errorf("git config =\n%q\nwant\n%q", got, want)
+	}
+}
+
+func TestRemoveDeletesMappingComment(t *testing.T) {
+	_, gitConfigPath, cleanup := setupMappingTestEnv(t)
+	defer cleanup()
+
+	content := strings.Join([]string{
+		"[user]",
+		"    name = Jane Doe",
+		"",
+		"# gidtree: profile=work mapped=2024-05-01",
+		`[includeIf "gitdir/i:/work/"]`,
+		"    path = ~/.gitconfig-work",
+		"",
+		`# gidtree: profile=oss mapped=2024-06-01 note="side projects"`,
+		`[includeIf "gitdir/i:/oss/"]`,
+		"    path = ~/.gitconfig-oss",
+		"",
+	}, "\n")
+	if err := os.WriteFile(gitConfigPath, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write git config: %v", err)
+	}
+
+	if err := removeIncludeIfBlock("/work/"); err != nil {
+		t.Fatalf("removeIncludeIfBlock() error = %v", err)
+	}
+	want := strings.Join([]string{
+		"[user]",
+		"    name = Jane Doe",
+		"",
+		`# gidtree: profile=oss mapped=2024-06-01 note="side projects"`,
+		`[includeIf "gitdir/i:/oss/"]`,
+		"    path = ~/.gitconfig-oss",
+		"",
+	}, "\n")
+	if got, _ := os.ReadFile(gitConfigPath); string(got) != want {
+		t.Errorf("git config =\n%q\nwant\n%q", got, want)
+	}
+
+	if err := removeIncludeIfBlock("/oss/"); err != nil {
+		t.Fatalf("removeIncludeIfBlock() error = %v", err)
+	}
+	want = "[user]\n    name = Jane Doe\n"
+	if got, _ := os.ReadFile(gitConfigPath); string(got) != want {
+		t.Errorf("git config =\n%q\nwant\n%q", got, want)
+	}
+}
@@ -1,110 +1,282 @@
 package mapping
 
 import (
-	"bufio"
 	"fmt"
 	"os"
 	"path/filepath"
-	"regexp"
+	"sort"
 	"strings"
+	"time"
 
+	"github.com/thuanlegit/git-identitree/internal/gitconfig"
+	"github.com/thuanlegit/git-identitree/internal/logging"
 	"github.com/thuanlegit/git-identitree/internal/utils"
 )
 
+// ConditionKind is the keyword of an includeIf condition.
+type ConditionKind string
+
+const (
+	// ConditionGitDir matches the repository path case-sensitively. gidtree writes
+	// these when case_sensitive_gitdir is set.
+	ConditionGitDir ConditionKind = "gitdir"
+	// ConditionGitDirI matches the repository path case-insensitively. gidtree writes these by default.
+	ConditionGitDirI ConditionKind = "gitdir/i"
+	// ConditionOnBranch matches the checked-out branch.
+	ConditionOnBranch ConditionKind = "onbranch"
+	// ConditionHasConfig matches on a remote URL or other config value.
+	ConditionHasConfig ConditionKind = "hasconfig"
+	// ConditionUnknown is any condition gidtree does not recognize.
+	ConditionUnknown ConditionKind = "unknown"
+)
+
 // Mapping represents a directory-to-profile mapping.
 type Mapping struct {
-	Directory string
-	Profile   string
+	Directory  string
+	Profile    string
 	ConfigPath string
+	// RawCondition is the exact text between the quotes of the includeIf header.
+	RawCondition string
+	// ConditionKind is the parsed keyword of RawCondition.
+	ConditionKind ConditionKind
+	// Note and CreatedAt come from the "# gidtree:" comment above the block,
+	// when there is one.
+	Note      string
+	CreatedAt time.Time
+	// Duplicate is set when a later includeIf block maps the same directory.
+	// git reads both and the later one wins, so this one has no effect.
+	Duplicate bool
+}
+
+// HasDirectory reports whether the mapping applies to a directory tree.
+// Blocks with onbranch, hasconfig or unknown conditions have no directory.
+func (m Mapping) HasDirectory() bool {
+	return m.Directory != ""
+}
+
+// parseCondition splits an includeIf condition into its kind and argument.
+func parseCondition(raw string) (ConditionKind, string) {
+	keyword, arg, ok := strings.Cut(raw, ":")
+	if !ok {
+		return ConditionUnknown, raw
+	}
+	switch ConditionKind(keyword) {
+	case ConditionGitDir, ConditionGitDirI, ConditionOnBranch, ConditionHasConfig:
+		return ConditionKind(keyword), arg
+	}
+	return ConditionUnknown, arg
+}
+
+// conditionDirectory returns the normalized directory of a gitdir condition,
+// or an empty string for conditions that are not about directories.
+func conditionDirectory(kind ConditionKind, arg string) string {
+	if kind != ConditionGitDir && kind != ConditionGitDirI {
+		return ""
+	}
+	normalized, err := utils.NormalizePath(arg)
+	if err != nil {
+		// If normalization fails, use original
+		normalized = arg
+	}
+	return utils.EnsureTrailingSlash(normalized)
+}
+
+// isManagedBlock reports whether an includeIf block has the shape gidtree writes:
+// a gitdir or gitdir/i condition including a ~/.gitconfig-<profile> file.
+func isManagedBlock(raw, path string) bool {
+	kind, _ := parseCondition(raw)
+	if kind != ConditionGitDirI && kind != ConditionGitDir {
+		return false
+	}
+	return extractProfileName(path) != ""
+}
+
+// blockMatchesDirectory reports whether the includeIf block with the given
+// condition and first path belongs to the normalized directory dir.
+// Managed blocks compare normalized directories; any other block only matches
+// when its condition is exactly the one gidtree would write, so conditions
+// gidtree cannot interpret are never rewritten or removed by accident.
+func blockMatchesDirectory(raw, path, dir string) bool {
+	if isManagedBlock(raw, path) {
+		kind, arg := parseCondition(raw)
+		return conditionDirectory(kind, arg) == dir
+	}
+	return raw == string(ConditionGitDirI)+":"+dir
+}
+
+// includeIfSections returns the includeIf sections of d, in file order.
+func includeIfSections(d *gitconfig.Document) []*gitconfig.Section {
+	var sections []*gitconfig.Section
+	for _, s := range d.Sections {
+		if s.Is("includeIf") && s.HasSubsection {
+			sections = append(sections, s)
+		}
+	}
+	return sections
+}
+
+// sectionMeta returns the metadata comment directly above s, if any.
+func sectionMeta(s *gitconfig.Section) (*gitconfig.Line, mappingMeta, bool) {
+	if len(s.Leading) == 0 {
+		return nil, mappingMeta{}, false
+	}
+	line := s.Leading[len(s.Leading)-1]
+	meta, ok := parseMappingMeta(line.Text())
+	return line, meta, ok
 }
 
-// ParseMappings extracts all directory-to-profile mappings from ~/.gitconfig.
+// dropSectionMeta removes the metadata comment above s and returns it.
+func dropSectionMeta(s *gitconfig.Section) []*gitconfig.Line {
+	line, _, ok := sectionMeta(s)
+	if !ok {
+		return nil
+	}
+	s.Leading = s.Leading[:len(s.Leading)-1]
+	return []*gitconfig.Line{line}
+}
+
+// sectionMapping describes an includeIf section. Sections without a path
+// include nothing and are not mappings.
+func sectionMapping(s *gitconfig.Section) (Mapping, bool) {
+	configPath, ok := s.Get("path")
+	if !ok {
+		return Mapping{}, false
+	}
+	// Expand ~ in config path
+	if strings.HasPrefix(configPath, "~") {
+		home, err := utils.Root()
+		if err == nil {
+			configPath = strings.Replace(configPath, "~", home, 1)
+		}
+	}
+
+	// The profile name comes from the file name, ~/.gitconfig-${profile_name}
+	kind, arg := parseCondition(s.Subsection)
+	m := Mapping{
+		Directory:     conditionDirectory(kind, arg),
+		Profile:       extractProfileName(configPath),
+		ConfigPath:    configPath,
+		RawCondition:  s.Subsection,
+		ConditionKind: kind,
+	}
+	if _, meta, ok := sectionMeta(s); ok {
+		m.Note, m.CreatedAt = meta.note, meta.mapped
+	}
+	return m, true
+}
+
+// readGitConfigDocument parses the git config at path. A missing file is an
+// empty document.
+func readGitConfigDocument(path string) (*gitconfig.Document, error) {
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read git config: %w", err)
+	}
+	return gitconfig.Parse(data), nil
+}
+
+// ParseMappings extracts all directory-to-profile mappings from ~/.gitconfig
+// and, with managed_include set, from the managed include file after it.
+// Every includeIf block with a path is returned; blocks whose condition is not
+// a gitdir condition have an empty Directory, and blocks a later one for the
+// same directory overrides are marked Duplicate. Results are cached until the
+// files change.
 func ParseMappings() ([]Mapping, error) {
-	gitConfigPath, err := getGitConfigPath()
+	paths, err := mappingFiles()
 	if err != nil {
 		return nil, err
 	}
+	var mappings []Mapping
+	for _, path := range paths {
+		parsed, err := parseMappingsCached(path)
+		if err != nil {
+			return nil, err
+		}
+		mappings = append(mappings, parsed...)
+	}
+	markDuplicates(mappings)
+	return mappings, nil
+}
 
-	// If file doesn't exist, return empty slice
-	if _, err := os.Stat(gitConfigPath); os.IsNotExist(err) {
+// mappingFiles returns the git configs gidtree reads includeIf blocks from,
+// in the order git includes them.
+func mappingFiles() ([]string, error) {
+	gitConfigPath, err := GetGitConfigPath()
+	if err != nil {
+		return nil, err
+	}
+	if !managedIncludeEnabled() {
+		return []string{gitConfigPath}, nil
+	}
+	managedPath, err := ManagedIncludePath()
+	if err != nil {
+		return nil, err
+	}
+	return []string{gitConfigPath, managedPath}, nil
+}
+
+// markDuplicates sets Duplicate on every mapping a later one for the same
+// directory overrides.
+func markDuplicates(mappings []Mapping) {
+	last := make(map[string]int)
+	for i, m := range mappings {
+		if m.HasDirectory() {
+			last[m.Directory] = i
+		}
+	}
+	for i := range mappings {
+		if mappings[i].HasDirectory() {
+			mappings[i].Duplicate = last[mappings[i].Directory] != i
+		}
+	}
+}
+
+// parseMappingsCached returns the mappings of the git config at path, reading
+// it only when it changed since the last call. A missing file has none.
+func parseMappingsCached(gitConfigPath string) ([]Mapping, error) {
+	info, err := os.Stat(gitConfigPath)
+	if os.IsNotExist(err) {
 		return []Mapping{}, nil
 	}
+	if err == nil {
+		if mappings, ok := cache.get(gitConfigPath, info); ok {
+			return mappings, nil
+		}
+	}
 
-	file, err := os.Open(gitConfigPath)
+	mappings, err := parseMappingsFile(gitConfigPath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open git config: %w", err)
+		return nil, err
+	}
+	if info != nil {
+		cache.put(gitConfigPath, info, mappings)
+	}
+	return mappings, nil
+}
+
+// parseMappingsFile reads the includeIf blocks of the git config at path.
+func parseMappingsFile(gitConfigPath string) ([]Mapping, error) {
+	fileReads.Add(1)
+
+	data, err := os.ReadFile(gitConfigPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read git config: %w", err)
 	}
-	defer func() {
-		_ = file.Close()
-	}()
 
 	var mappings []Mapping
-	scanner := bufio.NewScanner(file)
-	
-	// Regex to match includeIf blocks
-	// [includeIf "gitdir/i:/path/to/dir/"]
-	includeIfRegex := regexp.MustCompile(`^\s*\[includeIf\s+"gitdir/i:(.+)"\]\s*$`)
-	pathRegex := regexp.MustCompile(`^\s*path\s*=\s*(.+)\s*$`)
-
-	var currentDir string
-	var inIncludeIfBlock bool
-
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-		
-		// Check for includeIf block
-		if matches := includeIfRegex.FindStringSubmatch(line); matches != nil {
-			dir := matches[1]
-			// Normalize the directory path
-			normalized, err := utils.NormalizePath(dir)
-			if err != nil {
-				// If normalization fails, use original
-				normalized = dir
-			}
-			currentDir = utils.EnsureTrailingSlash(normalized)
-			inIncludeIfBlock = true
-			continue
+	for _, s := range includeIfSections(gitconfig.Parse(data)) {
+		if m, ok := sectionMapping(s); ok {
+			mappings = append(mappings, m)
 		}
-
-		// Check for path line within includeIf block
-		if inIncludeIfBlock {
-			if matches := pathRegex.FindStringSubmatch(line); matches != nil {
-				configPath := strings.TrimSpace(matches[1])
-				// Expand ~ in config path
-				if strings.HasPrefix(configPath, "~") {
-					home, err := utils.GetHomeDir()
-					if err == nil {
-						configPath = strings.Replace(configPath, "~", home, 1)
-					}
-				}
-				
-				// Extract profile name from config path
-				// ~/.gitconfig-${profile_name}
-				profileName := extractProfileName(configPath)
-				
-				mappings = append(mappings, Mapping{
-					Directory:  currentDir,
-					Profile:    profileName,
-					ConfigPath: configPath,
-				})
-				inIncludeIfBlock = false
-				currentDir = ""
-			} else if strings.HasPrefix(line, "[") {
-				// New section started, reset
-				inIncludeIfBlock = false
-				currentDir = ""
-			}
-		}
-	}
-
-	if err := scanner.Err(); err != nil {
-		return nil, fmt.Errorf("failed to scan git config: %w", err)
 	}
 
+	logging.Logger().Debug("parsed git config", "path", gitConfigPath, "mappings", len(mappings))
 	return mappings, nil
 }
 
 // extractProfileName extracts the profile name from a config path like ~/.gitconfig-${profile_name}.
+// Profile names cannot contain path separators, so the whole file name after
+// the prefix is the name, dots and all.
 func extractProfileName(configPath string) string {
 	base := filepath.Base(configPath)
 	if strings.HasPrefix(base, ".gitconfig-") {
@@ -129,33 +301,117 @@ func IsProfileMapped(profileName string) (bool, error) {
 }
 
 // GetMappingForDirectory returns the mapping for a given directory, if any.
+// An exact match wins over a prefix match, and of several prefix matches the
+// longest (the most specific mapping) wins.
 func GetMappingForDirectory(dir string) (*Mapping, error) {
 	normalized, err := utils.NormalizePath(dir)
 	if err != nil {
 		return nil, err
 	}
-	normalized = utils.EnsureTrailingSlash(normalized)
 
 	mappings, err := ParseMappings()
 	if err != nil {
 		return nil, err
 	}
 
-	// Check for exact match first
-	for _, m := range mappings {
-		if m.Directory == normalized {
+	for _, c := range candidatesFor(utils.EnsureTrailingSlash(normalized), mappings) {
+		if c.Winner {
+			m := c.Mapping
 			return &m, nil
 		}
 	}
+	return nil, nil
+}
 
-	// Check for prefix match (directory is within mapped directory)
-	for _, m := range mappings {
-		if strings.HasPrefix(normalized, m.Directory) {
-			return &m, nil
+// MatchKind says how a mapped directory relates to a directory being resolved.
+type MatchKind string
+
+const (
+	// MatchNone means the directory is outside the mapped directory.
+	MatchNone MatchKind = "none"
+	// MatchExact means the directory is the mapped directory.
+	MatchExact MatchKind = "exact"
+	// MatchPrefix means the directory is inside the mapped directory.
+	MatchPrefix MatchKind = "prefix"
+)
+
+// Candidate is a mapping considered while resolving a directory.
+type Candidate struct {
+	Mapping Mapping
+	Match   MatchKind
+	// Winner is set on the one mapping that applies to the directory. A
+	// matching candidate that is not the winner either is a Duplicate of it
+	// or lost to a more specific mapping.
+	Winner bool
+}
+
+// ExplainMappingForDirectory returns every directory mapping considered when
+// resolving dir, with how each matched. dir does not have to exist. The
+// winner comes first, then the other matches from the most to the least
+// specific, then the mappings that do not match in file order.
+func ExplainMappingForDirectory(dir string) (string, []Candidate, error) {
+	normalized, err := utils.NormalizePlannedPath(dir)
+	if err != nil {
+		return "", nil, err
+	}
+	normalized = utils.EnsureTrailingSlash(normalized)
+
+	mappings, err := ParseMappings()
+	if err != nil {
+		return "", nil, err
+	}
+
+	candidates := candidatesFor(normalized, mappings)
+	rank := func(c Candidate) int {
+		switch {
+		case c.Winner:
+			return 0
+		case c.Match != MatchNone:
+			return 1
 		}
+		return 2
 	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if rank(a) != rank(b) {
+			return rank(a) < rank(b)
+		}
+		if rank(a) == 1 && len(a.Mapping.Directory) != len(b.Mapping.Directory) {
+			return len(a.Mapping.Directory) > len(b.Mapping.Directory)
+		}
+		return false
+	})
+	return normalized, candidates, nil
+}
 
-	return nil, nil
+// candidatesFor matches every directory mapping against normalized, which
+// must end in a separator, and marks the winner. Candidates are in file order.
+func candidatesFor(normalized string, mappings []Mapping) []Candidate {
+	var candidates []Candidate
+	winner := -1
+	for _, m := range mappings {
+		if !m.HasDirectory() {
+			continue
+		}
+		c := Candidate{Mapping: m, Match: MatchNone}
+		switch {
+		case m.Directory == normalized:
+			c.Match = MatchExact
+		case strings.HasPrefix(normalized, m.Directory):
+			c.Match = MatchPrefix
+		}
+		candidates = append(candidates, c)
+		if c.Match == MatchNone || m.Duplicate {
+			continue
+		}
+		if winner == -1 || len(m.Directory) > len(candidates[winner].Mapping.Directory) {
+			winner = len(candidates) - 1
+		}
+	}
+	if winner != -1 {
+		candidates[winner].Winner = true
+	}
+	return candidates
 }
 
 // GetDirectoriesForProfile returns all directories mapped to a specific profile.
@@ -167,11 +423,10 @@ func GetDirectoriesForProfile(profileName string) ([]string, error) {
 
 	var directories []string
 	for _, m := range mappings {
-		if m.Profile == profileName {
+		if m.Profile == profileName && m.HasDirectory() {
 			directories = append(directories, m.Directory)
 		}
 	}
 
 	return directories, nil
 }
-
@@ -1,12 +1,15 @@
 package mapping
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
 
+	"github.com/thuanlegit/git-identitree/internal/gitconfig"
 	"github.com/thuanlegit/git-identitree/internal/utils"
 )
 
@@ -25,43 +28,14 @@ func setupMappingTestEnv(t *testing.T) (string, string, func()) {
 		t.Logf("Warning: Failed to resolve tmpDir symlinks: %v", err)
 	}
 
-	// Override home directory for testing
-	// On Windows, os.UserHomeDir() uses USERPROFILE, HOMEDRIVE+HOMEPATH, or HOME
-	// We need to override all of them to ensure tests work correctly
-	originalHome := os.Getenv("HOME")
-	originalUserProfile := os.Getenv("USERPROFILE")
-	originalHomeDrive := os.Getenv("HOMEDRIVE")
-	originalHomePath := os.Getenv("HOMEPATH")
-
-	if err := os.Setenv("HOME", tmpDir); err != nil {
-		t.Fatalf("Failed to set HOME: %v", err)
-	}
-	if err := os.Setenv("USERPROFILE", tmpDir); err != nil {
-		t.Fatalf("Failed to set USERPROFILE: %v", err)
-	}
-	// Clear HOMEDRIVE and HOMEPATH to prevent them from interfering
-	if err := os.Setenv("HOMEDRIVE", ""); err != nil {
-		t.Fatalf("Failed to clear HOMEDRIVE: %v", err)
-	}
-	if err := os.Setenv("HOMEPATH", ""); err != nil {
-		t.Fatalf("Failed to clear HOMEPATH: %v", err)
-	}
+	// Point gidtree at the temporary directory instead of the home directory
+	// and keep the host's gidtree config out of the way
+	t.Setenv(utils.RootEnv, tmpDir)
+	t.Setenv("XDG_CONFIG_HOME", "")
 
 	gitConfigPath := filepath.Join(tmpDir, ".gitconfig")
 
 	cleanup := func() {
-		if err := os.Setenv("HOME", originalHome); err != nil {
-			t.Logf("Failed to restore HOME: %v", err)
-		}
-		if err := os.Setenv("USERPROFILE", originalUserProfile); err != nil {
-			t.Logf("Failed to restore USERPROFILE: %v", err)
-		}
-		if err := os.Setenv("HOMEDRIVE", originalHomeDrive); err != nil {
-			t.Logf("Failed to restore HOMEDRIVE: %v", err)
-		}
-		if err := os.Setenv("HOMEPATH", originalHomePath); err != nil {
-			t.Logf("Failed to restore HOMEPATH: %v", err)
-		}
 		if err := os.RemoveAll(tmpDir); err != nil {
 			t.Logf("Failed to remove temp directory: %v", err)
 		}
@@ -277,6 +251,123 @@ func TestGetMappingForDirectory(t *testing.T) {
 	}
 }
 
+// writeNestedMappings maps root/code to outer, root/code/acme to inner (twice,
+// first to old) and root/oss to oss, parent before child.
+func writeNestedMappings(t *testing.T, gitConfigPath, root string) {
+	t.Helper()
+	var b strings.Builder
+	for _, m := range []struct{ dir, profile string }{
+		{"code", "outer"},
+		{"code/acme", "old"},
+		{"oss", "oss"},
+		{"code/acme", "inner"},
+	} {
+		dir := utils.EnsureTrailingSlash(filepath.Join(root, filepath.FromSlash(m.dir)))
+		fmt.Fprintf(&b, "[includeIf \"gitdir/i:%s\"]\n    path = ~/.gitconfig-%s\n", dir, m.profile)
+	}
+	if err := os.WriteFile(gitConfigPath, []byte(b.String()), 0644); err != nil {
+		t.Fatalf("Failed to write git config: %v", err)
+	}
+}
+
+func TestGetMappingForDirectory_Nested(t *testing.T) {
+	tmpDir, gitConfigPath, cleanup := setupMappingTestEnv(t)
+	defer cleanup()
+	root, _ := utils.NormalizePath(tmpDir)
+	writeNestedMappings(t, gitConfigPath, root)
+
+	tests := []struct {
+		dir  string
+		want string
+	}{
+		{"code/acme/api", "inner"},
+		{"code/acme", "inner"},
+		{"code/blog", "outer"},
+		{"elsewhere", ""},
+	}
+	for _, tt := range tests {
+		m, err := GetMappingForDirectory(filepath.Join(root, tt.dir))
+		if err != nil {
+			t.Fatalf("GetMappingForDirectory(%s) error = %v", tt.dir, err)
+		}
+		got := ""
+		if m != nil {
+			got = m.Profile
+		}
+		if got != tt.want {
+			t.Errorf("GetMappingForDirectory(%s) = %q, want %q", tt.dir, got, tt.want)
+		}
+	}
+}
+
+func TestExplainMappingForDirectory(t *testing.T) {
+	tmpDir, gitConfigPath, cleanup := setupMappingTestEnv(t)
+	defer cleanup()
+	root, _ := utils.NormalizePath(tmpDir)
+	writeNestedMappings(t, gitConfigPath, root)
+
+	type step struct {
+		Profile string
+		Match   MatchKind
+		Winner  bool
+	}
+	tests := []struct {
+		name string
+		dir  string
+		want []step
+	}{
+		{
+			name: "planned directory under nested mappings",
+			dir:  "code/acme/new-service",
+			want: []step{
+				{"inner", MatchPrefix, true},
+				{"old", MatchPrefix, false},
+				{"outer", MatchPrefix, false},
+				{"oss", MatchNone, false},
+			},
+		},
+		{
+			name: "exact match",
+			dir:  "code/acme",
+			want: []step{
+				{"inner", MatchExact, true},
+				{"old", MatchExact, false},
+				{"outer", MatchPrefix, false},
+				{"oss", MatchNone, false},
+			},
+		},
+		{
+			name: "outer mapping only",
+			dir:  "code/blog",
+			want: []step{
+				{"outer", MatchPrefix, true},
+				{"old", MatchNone, false},
+				{"oss", MatchNone, false},
+				{"inner", MatchNone, false},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := filepath.Join(root, filepath.FromSlash(tt.dir))
+			normalized, candidates, err := ExplainMappingForDirectory(dir)
+			if err != nil {
+				t.Fatalf("ExplainMappingForDirectory() error = %v", err)
+			}
+			if normalized != utils.EnsureTrailingSlash(dir) {
+				t.Errorf("ExplainMappingForDirectory() path = %q, want %q", normalized, utils.EnsureTrailingSlash(dir))
+			}
+			var got []step
+			for _, c := range candidates {
+				got = append(got, step{c.Mapping.Profile, c.Match, c.Winner})
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ExplainMappingForDirectory() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
 func TestParseMappings_ErrorReadingFile(t *testing.T) {
 	_, gitConfigPath, cleanup := setupMappingTestEnv(t)
 	defer cleanup()
@@ -396,6 +487,11 @@ func TestExtractProfileName_EdgeCases(t *testing.T) {
 			configPath: ".gitconfig-test",
 			want:       "test",
 		},
+		{
+			name:       "name with dots and underscores",
+			configPath: "/home/user/.gitconfig-acme_corp.eu",
+			want:       "acme_corp.eu",
+		},
 	}
 
 	for _, tt := range tests {
@@ -432,13 +528,10 @@ func TestIsProfileMapped_ParseError(t *testing.T) {
 }
 
 func TestGetMappingForDirectory_NormalizeError(t *testing.T) {
-	// Test with path that causes normalization error
-	// This is hard to trigger in practice, but tests the error path
+	// An empty path must not stand for the working directory
 	_, err := GetMappingForDirectory("")
-	if err == nil {
-		t.Log("GetMappingForDirectory() might succeed with empty string on some systems")
-	} else {
-		t.Logf("GetMappingForDirectory() handled empty path: %v", err)
+	if !errors.Is(err, utils.ErrEmptyPath) {
+		t.Errorf("GetMappingForDirectory(\"\") error = %v, want ErrEmptyPath", err)
 	}
 }
 
@@ -582,3 +675,134 @@ func TestGetDirectoriesForProfile_EmptyConfig(t *testing.T) {
 	}
 }
 
+
+func TestParseCondition(t *testing.T) {
+	tests := []struct {
+		raw  string
+		kind ConditionKind
+		arg  string
+	}{
+		{"gitdir/i:~/work/", ConditionGitDirI, "~/work/"},
+		{"gitdir:/srv/code/", ConditionGitDir, "/srv/code/"},
+		{"onbranch:release/**", ConditionOnBranch, "release/**"},
+		{"hasconfig:remote.*.url:https://github.com/acme/**", ConditionHasConfig, "remote.*.url:https://github.com/acme/**"},
+		{"future:whatever", ConditionUnknown, "whatever"},
+		{"no colon", ConditionUnknown, "no colon"},
+	}
+
+	for _, tt := range tests {
+		kind, arg := parseCondition(tt.raw)
+		if kind != tt.kind || arg != tt.arg {
+			t.Errorf("parseCondition(%q) = %q, %q; want %q, %q", tt.raw, kind, arg, tt.kind, tt.arg)
+		}
+	}
+}
+
+func TestParseMappings_ConditionKinds(t *testing.T) {
+	tmpDir, gitConfigPath, cleanup := setupMappingTestEnv(t)
+	defer cleanup()
+
+	configContent := `[includeIf "gitdir/i:` + tmpDir + `/work/"]
+    path = ~/.gitconfig-work
+[includeIf "gitdir:` + tmpDir + `/CaseSensitive/"]
+    path = ~/.gitconfig-cs
+[includeIf "onbranch:release/**"]
+    path = ~/.gitconfig-release
+[includeIf "hasconfig:remote.*.url:https://github.com/acme/**"]
+    path = ~/.gitconfig-acme
+[includeIf "future:whatever"]
+    path = ~/future.inc
+`
+	if err := os.WriteFile(gitConfigPath, []byte(configContent), 0644); err != nil {
+		t.Fatalf("Failed to write git config: %v", err)
+	}
+
+	mappings, err := ParseMappings()
+	if err != nil {
+		t.Fatalf("ParseMappings() error = %v", err)
+	}
+
+	want := []struct {
+		raw     string
+		kind    ConditionKind
+		hasDir  bool
+		profile string
+	}{
+		{"gitdir/i:" + tmpDir + "/work/", ConditionGitDirI, true, "work"},
+		{"gitdir:" + tmpDir + "/CaseSensitive/", ConditionGitDir, true, "cs"},
+		{"onbranch:release/**", ConditionOnBranch, false, "release"},
+		{"hasconfig:remote.*.url:https://github.com/acme/**", ConditionHasConfig, false, "acme"},
+		{"future:whatever", ConditionUnknown, false, ""},
+	}
+	if len(mappings) != len(want) {
+		t.Fatalf("ParseMappings() returned %d mappings, want %d: %+v", len(mappings), len(want), mappings)
+	}
+	for i, w := range want {
+		m := mappings[i]
+		if m.RawCondition != w.raw || m.ConditionKind != w.kind || m.HasDirectory() != w.hasDir || m.Profile != w.profile {
+			t.Errorf("mapping %d = %+v, want raw %q kind %q directory %v profile %q", i, m, w.raw, w.kind, w.hasDir, w.profile)
+		}
+	}
+
+	// Conditions without a directory never match a directory lookup
+	m, err := GetMappingForDirectory(filepath.Join(tmpDir, "elsewhere"))
+	if err != nil {
+		t.Fatalf("GetMappingForDirectory() error = %v", err)
+	}
+	if m != nil {
+		t.Errorf("GetMappingForDirectory() = %+v, want nil", m)
+	}
+
+	dirs, err := GetDirectoriesForProfile("release")
+	if err != nil {
+		t.Fatalf("GetDirectoriesForProfile() error = %v", err)
+	}
+	if len(dirs) != 0 {
+		t.Errorf("GetDirectoriesForProfile(release) = %v, want none", dirs)
+	}
+}
+
+func TestSectionMapping(t *testing.T) {
+	d := gitconfig.Parse([]byte(strings.Join([]string{
+		"[user]",
+		"    name = Jane Doe",
+		"",
+		"# gidtree: profile=work mapped=2024-05-01 note=\"client\"",
+		`[includeIf "gitdir/i:/work/"]`,
+		"    # comment",
+		`    path = "~/.gitconfig-work" ; quoted`,
+		"    path = ~/.gitconfig-extra",
+		"",
+		`[includeIf "onbranch:main"]`,
+		"[core]",
+		"    editor = vim",
+		`  [IncludeIf "gitdir:/last/"]`,
+		"    path = ~/.gitconfig-last",
+	}, "\n")))
+
+	sections := includeIfSections(d)
+	var conditions []string
+	for _, s := range sections {
+		conditions = append(conditions, s.Subsection)
+	}
+	if want := []string{"gitdir/i:/work/", "onbranch:main", "gitdir:/last/"}; !reflect.DeepEqual(conditions, want) {
+		t.Fatalf("includeIfSections() = %v, want %v", conditions, want)
+	}
+
+	m, ok := sectionMapping(sections[0])
+	if !ok {
+		t.Fatal("sectionMapping() of a block with a path = false")
+	}
+	if m.Profile != "work" || m.Directory != "/work/" || m.ConditionKind != ConditionGitDirI {
+		t.Errorf("sectionMapping() = %+v", m)
+	}
+	if m.Note != "client" || m.CreatedAt.Format(metaDateLayout) != "2024-05-01" {
+		t.Errorf("sectionMapping() metadata = %q %v", m.Note, m.CreatedAt)
+	}
+	if _, ok := sectionMapping(sections[1]); ok {
+		t.Error("sectionMapping() of a block without path = true")
+	}
+	if m, _ := sectionMapping(sections[2]); m.Profile != "last" || !m.CreatedAt.IsZero() {
+		t.Errorf("sectionMapping() = %+v", m)
+	}
+}
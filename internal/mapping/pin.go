@@ -0,0 +1,212 @@
+package mapping
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/thuanlegit/git-identitree/internal/gitconfig"
+	"github.com/thuanlegit/git-identitree/internal/logging"
+	"github.com/thuanlegit/git-identitree/internal/profile"
+	"github.com/thuanlegit/git-identitree/internal/utils"
+)
+
+// pinCommentPrefix starts the comment gidtree writes above each section it
+// adds to a repository's own config when pinning a profile:
+//
+//	# gidtree: pinned profile=work; remove with 'gidtree unpin'
+const pinCommentPrefix = "# gidtree: pinned"
+
+// Pin writes prof's identity into the local config of the repository holding
+// dir: user.name and user.email and, for a profile with an SSH key,
+// core.sshCommand. They go into sections of their own below a marker comment,
+// so they override any mapping, leave the repository's other settings alone
+// and can be removed again with Unpin. Pinning again replaces the previous
+// pin. It returns the config file written.
+func Pin(prof *profile.Profile, dir string) (string, error) {
+	path, err := LocalConfigPath(dir)
+	if err != nil {
+		return "", err
+	}
+	doc, mode, err := readLocalConfig(path)
+	if err != nil {
+		return "", err
+	}
+	removePinned(doc)
+
+	marker := fmt.Sprintf("%s profile=%s; remove with 'gidtree unpin'", pinCommentPrefix, prof.Name)
+	user := gitconfig.NewSection("user", "")
+	user.AddComment(marker)
+	user.Add("name", prof.GetAuthorName())
+	user.Add("email", prof.Email)
+	doc.AppendSection(user)
+	if prof.SSHKeyPath != "" {
+		core := gitconfig.NewSection("core", "")
+		core.AddComment(marker)
+		core.Add("sshCommand", prof.SSHCommand())
+		doc.AppendSection(core)
+	}
+
+	if err := os.WriteFile(path, doc.Serialize(), mode); err != nil {
+		return "", fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	logging.Logger().Debug("pinned profile", "profile", prof.Name, "path", path)
+	return path, nil
+}
+
+// Unpin removes the sections Pin wrote from the local config of the
+// repository holding dir and returns the name of the profile that was pinned.
+// It returns ErrNotPinned when there are none.
+func Unpin(dir string) (string, error) {
+	path, err := LocalConfigPath(dir)
+	if err != nil {
+		return "", err
+	}
+	doc, mode, err := readLocalConfig(path)
+	if err != nil {
+		return "", err
+	}
+	name, pinned := pinnedProfile(doc)
+	if !pinned {
+		return "", utils.WithDetail(ErrNotPinned, "no profile is pinned in %s", path)
+	}
+	removePinned(doc)
+
+	if err := os.WriteFile(path, doc.Serialize(), mode); err != nil {
+		return "", fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	logging.Logger().Debug("unpinned profile", "profile", name, "path", path)
+	return name, nil
+}
+
+// PinnedProfile returns the name of the profile pinned in the repository
+// holding dir, or "" when there is none or dir is not in a repository.
+func PinnedProfile(dir string) string {
+	name, _ := PinnedConfig(dir)
+	return name
+}
+
+// PinnedConfig returns the name of the profile pinned in the repository
+// holding dir and the entries the pin wrote, keyed by lowercase
+// section.key such as "user.email". The name is "" when nothing is pinned.
+func PinnedConfig(dir string) (string, map[string]string) {
+	path, err := LocalConfigPath(dir)
+	if err != nil {
+		return "", nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", nil
+	}
+	doc := gitconfig.Parse(data)
+	name, ok := pinnedProfile(doc)
+	if !ok {
+		return "", nil
+	}
+	entries := make(map[string]string)
+	for _, s := range doc.Sections {
+		if _, ok := pinMarker(s); !ok {
+			continue
+		}
+		for _, l := range s.Body {
+			if l.Kind == gitconfig.Entry {
+				entries[strings.ToLower(s.Name+"."+l.Key)] = l.Value
+			}
+		}
+	}
+	return name, entries
+}
+
+// LocalConfigPath returns the config file of the repository holding dir. A
+// .git file, as in worktrees and submodules, is followed to the git
+// directory, and a worktree's to the config it shares with the main
+// repository.
+func LocalConfigPath(dir string) (string, error) {
+	root, ok := utils.FindRepoRoot(dir)
+	if !ok {
+		return "", utils.WithDetail(ErrNotRepository, "%s is not inside a git repository", dir)
+	}
+	gitDir := filepath.Join(root, ".git")
+	info, err := os.Stat(gitDir)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", gitDir, err)
+	}
+	if !info.IsDir() {
+		data, err := os.ReadFile(gitDir)
+		if err != nil {
+			return "", fmt.Errorf("failed to read %s: %w", gitDir, err)
+		}
+		target, ok := strings.CutPrefix(strings.TrimSpace(string(data)), "gitdir:")
+		if !ok {
+			return "", utils.WithDetail(ErrNotRepository, "%s does not point to a git directory", gitDir)
+		}
+		gitDir = resolveGitPath(root, strings.TrimSpace(target))
+	}
+	if data, err := os.ReadFile(filepath.Join(gitDir, "commondir")); err == nil {
+		gitDir = resolveGitPath(gitDir, strings.TrimSpace(string(data)))
+	}
+	return filepath.Join(gitDir, "config"), nil
+}
+
+// resolveGitPath returns path, which git writes relative to base unless it
+// is absolute.
+func resolveGitPath(base, path string) string {
+	if filepath.IsAbs(path) {
+		return filepath.Clean(path)
+	}
+	return filepath.Join(base, path)
+}
+
+// readLocalConfig parses the config file at path and returns it with the
+// mode to write it back with. A missing file is empty.
+func readLocalConfig(path string) (*gitconfig.Document, os.FileMode, error) {
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, 0, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return gitconfig.Parse(data), mode, nil
+}
+
+// pinnedProfile returns the profile named by the first pin marker in doc.
+func pinnedProfile(doc *gitconfig.Document) (string, bool) {
+	for _, s := range doc.Sections {
+		if rest, ok := pinMarker(s); ok {
+			name, _, _ := strings.Cut(strings.TrimPrefix(rest, "profile="), ";")
+			return strings.TrimSpace(name), true
+		}
+	}
+	return "", false
+}
+
+// pinMarker returns what follows the prefix of s's pin marker comment, and
+// whether s has one.
+func pinMarker(s *gitconfig.Section) (string, bool) {
+	for _, l := range s.Leading {
+		if rest, ok := strings.CutPrefix(strings.TrimSpace(l.Text()), pinCommentPrefix); ok {
+			return strings.TrimSpace(rest), true
+		}
+	}
+	return "", false
+}
+
+// removePinned removes the sections Pin wrote, marker comments included.
+func removePinned(doc *gitconfig.Document) {
+	for _, s := range append([]*gitconfig.Section(nil), doc.Sections...) {
+		if _, ok := pinMarker(s); !ok {
+			continue
+		}
+		var leading []*gitconfig.Line
+		for _, l := range s.Leading {
+			if !strings.HasPrefix(strings.TrimSpace(l.Text()), pinCommentPrefix) {
+				leading = append(leading, l)
+			}
+		}
+		s.Leading = leading
+		doc.RemoveSection(s)
+	}
+}
@@ -0,0 +1,138 @@
+package mapping
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/thuanlegit/git-identitree/internal/profile"
+)
+
+// localConfig is the config of a fixture repository, with settings pinning
+// must leave alone, including a [user] section of its own.
+const localConfig = `[core]
+	repositoryformatversion = 0
+	bare = false
+[remote "origin"]
+	url = git@github.com:acme/api.git
+	fetch = +refs/heads/*:refs/remotes/origin/*
+[user]
+	signingkey = ABC123
+`
+
+// fixtureRepo creates a repository with localConfig and returns its root.
+func fixtureRepo(t *testing.T) string {
+	t.Helper()
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, ".git"), 0755); err != nil {
+		t.Fatalf("Failed to create .git: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(root, ".git", "config"), []byte(localConfig), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	return root
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	return string(data)
+}
+
+func TestPinAndUnpin(t *testing.T) {
+	root := fixtureRepo(t)
+	sub := filepath.Join(root, "cmd", "api")
+	if err := os.MkdirAll(sub, 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	prof := &profile.Profile{Name: "work", Email: "jane@work.example", AuthorName: "Jane Doe", SSHKeyPath: "/keys/id_work"}
+
+	path, err := Pin(prof, sub)
+	if err != nil {
+		t.Fatalf("Pin() error = %v", err)
+	}
+	if want := filepath.Join(root, ".git", "config"); path != want {
+		t.Errorf("Pin() path = %q, want %q", path, want)
+	}
+	content := readFile(t, path)
+	if !strings.HasPrefix(content, localConfig) {
+		t.Errorf("Pin() changed the existing config:\n%s", content)
+	}
+	for _, want := range []string{
+		"# gidtree: pinned profile=work; remove with 'gidtree unpin'\n[user]\n    name = Jane Doe\n    email = jane@work.example\n",
+		"[core]\n    sshCommand = ",
+	} {
+		if !strings.Contains(content, want) {
+			t.Errorf("config missing %q:\n%s", want, content)
+		}
+	}
+	if got := PinnedProfile(root); got != "work" {
+		t.Errorf("PinnedProfile() = %q, want work", got)
+	}
+	name, entries := PinnedConfig(sub)
+	if name != "work" || entries["user.email"] != "jane@work.example" || entries["core.sshcommand"] != prof.SSHCommand() || len(entries) != 3 {
+		t.Errorf("PinnedConfig() = %q, %v", name, entries)
+	}
+
+	// Pinning again replaces the pin rather than adding a second one
+	personal := &profile.Profile{Name: "personal", Email: "jane@example.com"}
+	if _, err := Pin(personal, root); err != nil {
+		t.Fatalf("Pin() again error = %v", err)
+	}
+	content = readFile(t, path)
+	if strings.Count(content, pinCommentPrefix) != 1 || strings.Contains(content, "sshCommand") {
+		t.Errorf("Pin() again should replace the previous pin:\n%s", content)
+	}
+	if got := PinnedProfile(root); got != "personal" {
+		t.Errorf("PinnedProfile() = %q, want personal", got)
+	}
+
+	name, err = Unpin(sub)
+	if err != nil || name != "personal" {
+		t.Fatalf("Unpin() = %q, %v, want personal", name, err)
+	}
+	if got := readFile(t, path); got != localConfig {
+		t.Errorf("Unpin() left\n%s\nwant the original config\n%s", got, localConfig)
+	}
+	if _, err := Unpin(root); !errors.Is(err, ErrNotPinned) {
+		t.Errorf("Unpin() without a pin error = %v, want ErrNotPinned", err)
+	}
+}
+
+func TestPin_OutsideRepository(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := Pin(&profile.Profile{Name: "work", Email: "work@example.com"}, dir); !errors.Is(err, ErrNotRepository) {
+		t.Errorf("Pin() error = %v, want ErrNotRepository", err)
+	}
+	if got := PinnedProfile(dir); got != "" {
+		t.Errorf("PinnedProfile() = %q outside a repository", got)
+	}
+}
+
+func TestLocalConfigPath_Worktree(t *testing.T) {
+	main := fixtureRepo(t)
+	worktreeDir := filepath.Join(main, ".git", "worktrees", "feature")
+	if err := os.MkdirAll(worktreeDir, 0755); err != nil {
+		t.Fatalf("Failed to create worktree dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(worktreeDir, "commondir"), []byte("../..\n"), 0644); err != nil {
+		t.Fatalf("Failed to write commondir: %v", err)
+	}
+	worktree := t.TempDir()
+	if err := os.WriteFile(filepath.Join(worktree, ".git"), []byte("gitdir: "+worktreeDir+"\n"), 0644); err != nil {
+		t.Fatalf("Failed to write .git: %v", err)
+	}
+
+	got, err := LocalConfigPath(worktree)
+	if err != nil {
+		t.Fatalf("LocalConfigPath() error = %v", err)
+	}
+	if want := filepath.Join(main, ".git", "config"); got != want {
+		t.Errorf("LocalConfigPath() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,118 @@
+package mapping
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/thuanlegit/git-identitree/internal/profile"
+)
+
+// Remote is a git remote URL reduced to what profiles are matched on.
+type Remote struct {
+	Host string
+	// Path is the repository path without a leading slash or .git suffix,
+	// usually "<org>/<repo>".
+	Path string
+}
+
+// String returns the remote as "<host>/<path>", the form patterns match.
+func (r Remote) String() string {
+	if r.Path == "" {
+		return r.Host
+	}
+	return r.Host + "/" + r.Path
+}
+
+// ParseRemoteURL parses the URL forms git accepts for a remote: URLs with a
+// scheme (https, ssh, git) and the scp-like user@host:org/repo syntax.
+func ParseRemoteURL(raw string) (Remote, error) {
+	raw = strings.TrimSpace(raw)
+	var host, p string
+	if strings.Contains(raw, "://") {
+		u, err := url.Parse(raw)
+		if err != nil {
+			return Remote{}, fmt.Errorf("invalid remote URL %q: %w", raw, err)
+		}
+		host, p = u.Hostname(), u.Path
+	} else if at, rest, ok := strings.Cut(raw, ":"); ok && len(at) > 1 && !strings.Contains(at, "/") {
+		// scp-like: [user@]host:path. A single letter is a Windows drive.
+		if i := strings.LastIndex(at, "@"); i >= 0 {
+			at = at[i+1:]
+		}
+		host, p = at, rest
+	}
+	if host == "" {
+		return Remote{}, fmt.Errorf("invalid remote URL %q: no host", raw)
+	}
+
+	p = strings.Trim(p, "/")
+	p = strings.TrimSuffix(p, ".git")
+	return Remote{Host: strings.ToLower(host), Path: p}, nil
+}
+
+// Suggestion is a profile whose remote patterns match a remote.
+type Suggestion struct {
+	Profile string
+	// Pattern is the most specific of the profile's patterns that matched.
+	Pattern string
+	// Score ranks suggestions; higher is more specific.
+	Score int
+}
+
+// SuggestProfiles ranks the profiles whose remote patterns match remote, most
+// specific first. Profiles without a matching pattern are left out.
+func SuggestProfiles(remote Remote, profiles []profile.Profile) []Suggestion {
+	var suggestions []Suggestion
+	for _, p := range profiles {
+		best := Suggestion{Profile: p.Name}
+		for _, pattern := range p.RemotePatterns {
+			if score := MatchRemotePattern(pattern, remote); score > best.Score {
+				best.Pattern, best.Score = pattern, score
+			}
+		}
+		if best.Score > 0 {
+			suggestions = append(suggestions, best)
+		}
+	}
+	sort.SliceStable(suggestions, func(i, j int) bool {
+		if suggestions[i].Score != suggestions[j].Score {
+			return suggestions[i].Score > suggestions[j].Score
+		}
+		return suggestions[i].Profile < suggestions[j].Profile
+	})
+	return suggestions
+}
+
+// MatchRemotePattern matches a glob such as "github.com/acme" or
+// "*.corp.com/*/infra-*" against a remote, segment by segment from the host.
+// A pattern with fewer segments matches everything below it, so
+// "github.com/acme" matches every repository of the acme organization.
+// Hosts and organizations compare case-insensitively, as the services do.
+//
+// It returns 0 when the pattern does not match. Otherwise the score grows
+// with the number of segments and is higher for literal segments than for
+// wildcards, so the most specific pattern ranks first.
+func MatchRemotePattern(pattern string, remote Remote) int {
+	patternSegments := strings.Split(strings.Trim(strings.ToLower(pattern), "/"), "/")
+	remoteSegments := strings.Split(strings.ToLower(remote.String()), "/")
+	if len(patternSegments) > len(remoteSegments) {
+		return 0
+	}
+
+	score := 0
+	for i, seg := range patternSegments {
+		ok, err := path.Match(seg, remoteSegments[i])
+		if err != nil || !ok {
+			return 0
+		}
+		if seg == remoteSegments[i] {
+			score += 2
+		} else {
+			score++
+		}
+	}
+	return score
+}
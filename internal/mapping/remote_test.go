@@ -0,0 +1,106 @@
+package mapping
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/thuanlegit/git-identitree/internal/profile"
+)
+
+func TestParseRemoteURL(t *testing.T) {
+	tests := []struct {
+		raw     string
+		want    Remote
+		wantErr bool
+	}{
+		{raw: "git@github.com:acme/api.git", want: Remote{Host: "github.com", Path: "acme/api"}},
+		{raw: "github.com:acme/api", want: Remote{Host: "github.com", Path: "acme/api"}},
+		{raw: "https://github.com/acme/api.git", want: Remote{Host: "github.com", Path: "acme/api"}},
+		{raw: "https://user@GitHub.com/Acme/api/", want: Remote{Host: "github.com", Path: "Acme/api"}},
+		{raw: "ssh://git@gitlab.corp.com:2222/platform/infra/tools.git", want: Remote{Host: "gitlab.corp.com", Path: "platform/infra/tools"}},
+		{raw: "git://example.org/repo.git", want: Remote{Host: "example.org", Path: "repo"}},
+		{raw: "/srv/git/repo.git", wantErr: true},
+		{raw: "../other", wantErr: true},
+		{raw: "C:/src/repo.git", wantErr: true},
+		{raw: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			got, err := ParseRemoteURL(tt.raw)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseRemoteURL() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseRemoteURL() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatchRemotePattern(t *testing.T) {
+	remote := Remote{Host: "github.com", Path: "Acme/infra-tools"}
+	tests := []struct {
+		pattern string
+		want    int
+	}{
+		{"github.com", 2},
+		{"github.com/acme", 4},
+		{"github.com/acme/", 4},
+		{"GitHub.com/ACME", 4},
+		{"github.com/acme/infra-tools", 6},
+		{"github.com/acme/infra-*", 5},
+		{"github.com/*", 3},
+		{"*.com/acme", 3},
+		{"*", 1},
+		{"gitlab.com", 0},
+		{"github.com/other", 0},
+		{"github.com/acme/infra-tools/extra", 0},
+		{"github.com/[", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pattern, func(t *testing.T) {
+			if got := MatchRemotePattern(tt.pattern, remote); got != tt.want {
+				t.Errorf("MatchRemotePattern(%q) = %d, want %d", tt.pattern, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSuggestProfiles(t *testing.T) {
+	profiles := []profile.Profile{
+		{Name: "personal", RemotePatterns: []string{"github.com"}},
+		{Name: "work", RemotePatterns: []string{"gitlab.corp.com", "github.com/acme"}},
+		{Name: "oss", RemotePatterns: []string{"github.com/*"}},
+		{Name: "unrelated", RemotePatterns: []string{"bitbucket.org"}},
+		{Name: "none"},
+	}
+
+	tests := []struct {
+		remote Remote
+		want   []Suggestion
+	}{
+		{
+			remote: Remote{Host: "github.com", Path: "acme/api"},
+			want: []Suggestion{
+				{Profile: "work", Pattern: "github.com/acme", Score: 4},
+				{Profile: "oss", Pattern: "github.com/*", Score: 3},
+				{Profile: "personal", Pattern: "github.com", Score: 2},
+			},
+		},
+		{
+			remote: Remote{Host: "gitlab.corp.com", Path: "platform/api"},
+			want:   []Suggestion{{Profile: "work", Pattern: "gitlab.corp.com", Score: 2}},
+		},
+		{
+			remote: Remote{Host: "example.org", Path: "repo"},
+			want:   nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.remote.String(), func(t *testing.T) {
+			if got := SuggestProfiles(tt.remote, profiles); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SuggestProfiles() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,314 @@
+package mapping
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/thuanlegit/git-identitree/internal/config"
+	"github.com/thuanlegit/git-identitree/internal/logging"
+	"github.com/thuanlegit/git-identitree/internal/profile"
+	"github.com/thuanlegit/git-identitree/internal/utils"
+)
+
+// GlobalIdentity is the name/email from the [user] section of ~/.gitconfig.
+// Git falls back to it in repositories outside any mapped directory.
+type GlobalIdentity struct {
+	Name  string `json:"name"`
+	Email string `json:"email"`
+}
+
+var (
+	sectionHeaderRegex = regexp.MustCompile(`^\s*\[([^\]]+)\]`)
+	keyValueRegex      = regexp.MustCompile(`^\s*([A-Za-z][A-Za-z0-9-]*)\s*=\s*(.*)$`)
+)
+
+// ParseGlobalUser reads the [user] section of ~/.gitconfig.
+// It returns nil when the file or the section does not exist.
+func ParseGlobalUser() (*GlobalIdentity, error) {
+	gitConfigPath, err := GetGitConfigPath()
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := os.Stat(gitConfigPath); os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	lines, err := readGitConfigLines(gitConfigPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var identity *GlobalIdentity
+	inUser := false
+	for _, line := range lines {
+		if matches := sectionHeaderRegex.FindStringSubmatch(line); matches != nil {
+			inUser = isUserSection(matches[1])
+			if inUser && identity == nil {
+				identity = &GlobalIdentity{}
+			}
+			continue
+		}
+		if !inUser {
+			continue
+		}
+		if matches := keyValueRegex.FindStringSubmatch(line); matches != nil {
+			value := parseConfigValue(matches[2])
+			switch strings.ToLower(matches[1]) {
+			case "name":
+				identity.Name = value
+			case "email":
+				identity.Email = value
+			}
+		}
+	}
+
+	return identity, nil
+}
+
+// SetGlobalUser writes name and email into the [user] section of ~/.gitconfig,
+// creating the section if needed. The existing file is backed up first.
+func SetGlobalUser(name, email string) error {
+	gitConfigPath, err := GetGitConfigPath()
+	if err != nil {
+		return err
+	}
+
+	var lines []string
+	if _, err := os.Stat(gitConfigPath); err == nil {
+		lines, err = readGitConfigLines(gitConfigPath)
+		if err != nil {
+			return err
+		}
+		if _, err := backupGitConfig(gitConfigPath); err != nil {
+			return fmt.Errorf("failed to back up git config: %w", err)
+		}
+	}
+
+	nameLine := fmt.Sprintf("    name = %s", quoteConfigValue(name))
+	emailLine := fmt.Sprintf("    email = %s", quoteConfigValue(email))
+
+	// Find the first [user] section
+	start := -1
+	for i, line := range lines {
+		if matches := sectionHeaderRegex.FindStringSubmatch(line); matches != nil && isUserSection(matches[1]) {
+			start = i
+			break
+		}
+	}
+
+	if start == -1 {
+		// Prepend so that includeIf blocks further down still take precedence
+		header := []string{"[user]", nameLine, emailLine}
+		if len(lines) > 0 {
+			header = append(header, "")
+		}
+		return writeGitConfig(gitConfigPath, append(header, lines...))
+	}
+
+	end := len(lines)
+	for i := start + 1; i < len(lines); i++ {
+		if sectionHeaderRegex.MatchString(lines[i]) {
+			end = i
+			break
+		}
+	}
+
+	nameSet, emailSet := false, false
+	var section []string
+	for _, line := range lines[start+1 : end] {
+		if matches := keyValueRegex.FindStringSubmatch(line); matches != nil {
+			switch strings.ToLower(matches[1]) {
+			case "name":
+				if !nameSet {
+					section = append(section, nameLine)
+					nameSet = true
+				}
+				continue
+			case "email":
+				if !emailSet {
+					section = append(section, emailLine)
+					emailSet = true
+				}
+				continue
+			}
+		}
+		section = append(section, line)
+	}
+
+	var missing []string
+	if !nameSet {
+		missing = append(missing, nameLine)
+	}
+	if !emailSet {
+		missing = append(missing, emailLine)
+	}
+	section = append(missing, section...)
+
+	newLines := append([]string{}, lines[:start+1]...)
+	newLines = append(newLines, section...)
+	newLines = append(newLines, lines[end:]...)
+
+	return writeGitConfig(gitConfigPath, newLines)
+}
+
+// IdentityWarnings reports problems with how the global identity interacts with mappings.
+func IdentityWarnings(global *GlobalIdentity, mappings []Mapping, profiles []profile.Profile) []string {
+	var warnings []string
+
+	if global == nil || (global.Name == "" && global.Email == "") {
+		warnings = append(warnings, "No default identity in ~/.gitconfig: repositories outside mapped directories have no user.name/user.email")
+		return warnings
+	}
+
+	seen := make(map[string]bool)
+	for _, m := range mappings {
+		if seen[m.Profile] {
+			continue
+		}
+		for _, p := range profiles {
+			if p.Name == m.Profile && global.Email != "" && strings.EqualFold(p.Email, global.Email) {
+				warnings = append(warnings, fmt.Sprintf("Profile '%s' uses the same email as the default identity (%s); its mappings change nothing", p.Name, p.Email))
+				seen[m.Profile] = true
+			}
+		}
+	}
+
+	return warnings
+}
+
+// isUserSection reports whether a section header name is the plain [user] section.
+func isUserSection(header string) bool {
+	return strings.EqualFold(strings.TrimSpace(header), "user")
+}
+
+// parseConfigValue strips inline comments and surrounding quotes from a config value.
+func parseConfigValue(raw string) string {
+	var b strings.Builder
+	inQuotes := false
+	escaped := false
+	for _, r := range strings.TrimSpace(raw) {
+		switch {
+		case escaped:
+			b.WriteRune(r)
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == '"':
+			inQuotes = !inQuotes
+		case (r == '#' || r == ';') && !inQuotes:
+			return strings.TrimSpace(b.String())
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// quoteConfigValue quotes a value when git would otherwise misread it.
+func quoteConfigValue(value string) string {
+	needsQuotes := strings.ContainsAny(value, "#;\"\\") ||
+		strings.TrimSpace(value) != value
+	if !needsQuotes {
+		return value
+	}
+	escaped := strings.ReplaceAll(value, `\`, `\\`)
+	escaped = strings.ReplaceAll(escaped, `"`, `\"`)
+	return `"` + escaped + `"`
+}
+
+// readGitConfigLines reads a git config file into lines.
+func readGitConfigLines(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open git config: %w", err)
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	var lines []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read git config: %w", err)
+	}
+	return lines, nil
+}
+
+// backupTimeFormat is the timestamp suffix of backup file names. It sorts chronologically.
+const backupTimeFormat = "20060102-150405.000000000"
+
+// backupGitConfig copies the git config into ~/.gidtree/backups and returns the backup path.
+// Only the newest backup_retention backups of the file are kept.
+func backupGitConfig(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	profilesDir, err := profile.GetProfilesDir()
+	if err != nil {
+		return "", err
+	}
+	backupDir := filepath.Join(profilesDir, "backups")
+	if err := os.MkdirAll(backupDir, utils.PrivateDirMode); err != nil {
+		return "", err
+	}
+
+	name := fmt.Sprintf("%s-%s", filepath.Base(path), time.Now().Format(backupTimeFormat))
+	backupPath := filepath.Join(backupDir, name)
+	if err := os.WriteFile(backupPath, data, utils.PrivateFileMode); err != nil {
+		return "", err
+	}
+	logging.Logger().Debug("backed up git config", "path", path, "backup", backupPath, "bytes", len(data))
+
+	cfg, err := config.Load()
+	if err != nil {
+		return "", fmt.Errorf("failed to load config: %w", err)
+	}
+	if err := pruneBackups(backupDir, filepath.Base(path), cfg.BackupRetention); err != nil {
+		return "", fmt.Errorf("failed to prune backups: %w", err)
+	}
+	return backupPath, nil
+}
+
+// pruneBackups removes all but the newest keep backups of the file named base.
+func pruneBackups(backupDir, base string, keep int) error {
+	entries, err := os.ReadDir(backupDir)
+	if err != nil {
+		return err
+	}
+
+	var backups []string
+	prefix := base + "-"
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		// Skip other files sharing the prefix, e.g. .gitconfig-work backups
+		if _, err := time.Parse(backupTimeFormat, strings.TrimPrefix(name, prefix)); err != nil {
+			continue
+		}
+		backups = append(backups, name)
+	}
+
+	// ReadDir sorts by name, so the oldest backups come first
+	for len(backups) > keep {
+		old := filepath.Join(backupDir, backups[0])
+		if err := os.Remove(old); err != nil {
+			return err
+		}
+		logging.Logger().Debug("removed old backup", "path", old)
+		backups = backups[1:]
+	}
+	return nil
+}
@@ -0,0 +1,269 @@
+package mapping
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/thuanlegit/git-identitree/internal/config"
+	"github.com/thuanlegit/git-identitree/internal/profile"
+)
+
+func TestParseGlobalUser(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    *GlobalIdentity
+	}{
+		{
+			name:    "no user section",
+			content: "[core]\n    editor = vim\n",
+			want:    nil,
+		},
+		{
+			name:    "plain values",
+			content: "[user]\n    name = Jane Doe\n    email = jane@example.com\n",
+			want:    &GlobalIdentity{Name: "Jane Doe", Email: "jane@example.com"},
+		},
+		{
+			name:    "quoted values and comments",
+			content: "[User]\n\tName = \"Jane ; Doe\" # comment\n\temail=jane@example.com ; trailing\n",
+			want:    &GlobalIdentity{Name: "Jane ; Doe", Email: "jane@example.com"},
+		},
+		{
+			name:    "ignores includeIf blocks",
+			content: "[user]\n    email = jane@example.com\n[includeIf \"gitdir/i:/work/\"]\n    path = ~/.gitconfig-work\n",
+			want:    &GlobalIdentity{Email: "jane@example.com"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, gitConfigPath, cleanup := setupMappingTestEnv(t)
+			defer cleanup()
+
+			if err := os.WriteFile(gitConfigPath, []byte(tt.content), 0644); err != nil {
+				t.Fatalf("Failed to write git config: %v", err)
+			}
+
+			got, err := ParseGlobalUser()
+			if err != nil {
+				t.Fatalf("ParseGlobalUser() error = %v", err)
+			}
+			if (got == nil) != (tt.want == nil) {
+				t.Fatalf("ParseGlobalUser() = %v, want %v", got, tt.want)
+			}
+			if got != nil && *got != *tt.want {
+				t.Errorf("ParseGlobalUser() = %+v, want %+v", *got, *tt.want)
+			}
+		})
+	}
+}
+
+func TestParseGlobalUser_NoFile(t *testing.T) {
+	_, _, cleanup := setupMappingTestEnv(t)
+	defer cleanup()
+
+	got, err := ParseGlobalUser()
+	if err != nil {
+		t.Fatalf("ParseGlobalUser() error = %v", err)
+	}
+	if got != nil {
+		t.Errorf("ParseGlobalUser() = %v, want nil", got)
+	}
+}
+
+func TestSetGlobalUser(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{
+			name:    "no file",
+			content: "",
+			want:    "[user]\n    name = Jane Doe\n    email = jane@example.com",
+		},
+		{
+			name:    "no user section",
+			content: "[includeIf \"gitdir/i:/work/\"]\n    path = ~/.gitconfig-work",
+			want:    "[user]\n    name = Jane Doe\n    email = jane@example.com\n\n[includeIf \"gitdir/i:/work/\"]\n    path = ~/.gitconfig-work",
+		},
+		{
+			name:    "existing user section",
+			content: "[user]\n    name = Old\n    signingkey = ABC\n    email = old@example.com\n[core]\n    editor = vim",
+			want:    "[user]\n    name = Jane Doe\n    signingkey = ABC\n    email = jane@example.com\n[core]\n    editor = vim",
+		},
+		{
+			name:    "user section missing email",
+			content: "[user]\n    name = Old",
+			want:    "[user]\n    email = jane@example.com\n    name = Jane Doe",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, gitConfigPath, cleanup := setupMappingTestEnv(t)
+			defer cleanup()
+
+			if tt.content != "" {
+				if err := os.WriteFile(gitConfigPath, []byte(tt.content), 0644); err != nil {
+					t.Fatalf("Failed to write git config: %v", err)
+				}
+			}
+
+			if err := SetGlobalUser("Jane Doe", "jane@example.com"); err != nil {
+				t.Fatalf("SetGlobalUser() error = %v", err)
+			}
+
+			content, err := os.ReadFile(gitConfigPath)
+			if err != nil {
+				t.Fatalf("Failed to read git config: %v", err)
+			}
+			if string(content) != tt.want {
+				t.Errorf("SetGlobalUser() content =\n%q\nwant\n%q", string(content), tt.want)
+			}
+
+			got, err := ParseGlobalUser()
+			if err != nil {
+				t.Fatalf("ParseGlobalUser() error = %v", err)
+			}
+			if got == nil || got.Name != "Jane Doe" || got.Email != "jane@example.com" {
+				t.Errorf("ParseGlobalUser() after set = %v", got)
+			}
+		})
+	}
+}
+
+func TestSetGlobalUser_Backup(t *testing.T) {
+	tmpDir, gitConfigPath, cleanup := setupMappingTestEnv(t)
+	defer cleanup()
+
+	original := "[user]\n    name = Old\n"
+	if err := os.WriteFile(gitConfigPath, []byte(original), 0644); err != nil {
+		t.Fatalf("Failed to write git config: %v", err)
+	}
+
+	if err := SetGlobalUser("New", "new@example.com"); err != nil {
+		t.Fatalf("SetGlobalUser() error = %v", err)
+	}
+
+	entries, err := os.ReadDir(filepath.Join(tmpDir, ".gidtree", "backups"))
+	if err != nil {
+		t.Fatalf("Failed to read backups directory: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("Expected 1 backup, got %d", len(entries))
+	}
+	backup, err := os.ReadFile(filepath.Join(tmpDir, ".gidtree", "backups", entries[0].Name()))
+	if err != nil {
+		t.Fatalf("Failed to read backup: %v", err)
+	}
+	if string(backup) != original {
+		t.Errorf("Backup content = %q, want %q", string(backup), original)
+	}
+}
+
+func TestSetGlobalUser_BackupRetention(t *testing.T) {
+	tmpDir, gitConfigPath, cleanup := setupMappingTestEnv(t)
+	defer cleanup()
+
+	cfg := config.Default()
+	cfg.BackupRetention = 2
+	if err := config.Save(&cfg); err != nil {
+		t.Fatalf("config.Save() error = %v", err)
+	}
+
+	backupDir := filepath.Join(tmpDir, ".gidtree", "backups")
+	// Unrelated files sharing the prefix are left alone
+	unrelated := filepath.Join(backupDir, ".gitconfig-work-notes")
+	if err := os.MkdirAll(backupDir, 0755); err != nil {
+		t.Fatalf("Failed to create backups directory: %v", err)
+	}
+	if err := os.WriteFile(unrelated, []byte("keep"), 0644); err != nil {
+		t.Fatalf("Failed to write unrelated file: %v", err)
+	}
+
+	if err := os.WriteFile(gitConfigPath, []byte("[user]\n    name = v0\n"), 0644); err != nil {
+		t.Fatalf("Failed to write git config: %v", err)
+	}
+	for i := 1; i <= 4; i++ {
+		if err := SetGlobalUser(fmt.Sprintf("v%d", i), "me@example.com"); err != nil {
+			t.Fatalf("SetGlobalUser() error = %v", err)
+		}
+	}
+
+	entries, err := os.ReadDir(backupDir)
+	if err != nil {
+		t.Fatalf("Failed to read backups directory: %v", err)
+	}
+	var backups []string
+	for _, e := range entries {
+		if e.Name() != filepath.Base(unrelated) {
+			backups = append(backups, e.Name())
+		}
+	}
+	if len(backups) != 2 {
+		t.Fatalf("backups = %v, want the newest 2", backups)
+	}
+	newest, err := os.ReadFile(filepath.Join(backupDir, backups[1]))
+	if err != nil {
+		t.Fatalf("Failed to read backup: %v", err)
+	}
+	if !strings.Contains(string(newest), "name = v3") {
+		t.Errorf("newest backup = %q, want the config before the last write", newest)
+	}
+	if _, err := os.Stat(unrelated); err != nil {
+		t.Errorf("unrelated file was removed: %v", err)
+	}
+}
+
+func TestQuoteConfigValue(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"Jane Doe", "Jane Doe"},
+		{"Jane; Doe", `"Jane; Doe"`},
+		{` padded `, `" padded "`},
+		{`say "hi"`, `"say \"hi\""`},
+	}
+
+	for _, tt := range tests {
+		if got := quoteConfigValue(tt.value); got != tt.want {
+			t.Errorf("quoteConfigValue(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+		if got := parseConfigValue(quoteConfigValue(tt.value)); got != strings.TrimSpace(tt.value) && got != tt.value {
+			t.Errorf("parseConfigValue(quoteConfigValue(%q)) = %q", tt.value, got)
+		}
+	}
+}
+
+func TestIdentityWarnings(t *testing.T) {
+	profiles := []profile.Profile{
+		{Name: "work", Email: "work@example.com"},
+		{Name: "personal", Email: "me@example.com"},
+	}
+	mappings := []Mapping{
+		{Directory: "/work/", Profile: "work"},
+		{Directory: "/personal/", Profile: "personal"},
+		{Directory: "/personal2/", Profile: "personal"},
+	}
+
+	if got := IdentityWarnings(nil, mappings, profiles); len(got) != 1 || !strings.Contains(got[0], "No default identity") {
+		t.Errorf("IdentityWarnings(nil) = %v, want missing default warning", got)
+	}
+
+	global := &GlobalIdentity{Name: "Me", Email: "ME@example.com"}
+	got := IdentityWarnings(global, mappings, profiles)
+	if len(got) != 1 || !strings.Contains(got[0], "'personal'") {
+		t.Errorf("IdentityWarnings() = %v, want one no-op warning for personal", got)
+	}
+
+	global = &GlobalIdentity{Name: "Other", Email: "other@example.com"}
+	if got := IdentityWarnings(global, mappings, profiles); len(got) != 0 {
+		t.Errorf("IdentityWarnings() = %v, want none", got)
+	}
+}
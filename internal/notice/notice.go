@@ -0,0 +1,92 @@
+package notice
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/thuanlegit/git-identitree/internal/profile"
+	"github.com/thuanlegit/git-identitree/internal/utils"
+)
+
+const ackFile = "acknowledged"
+
+// CloudSync is the key for the warning about mapping inside cloud-synced folders.
+const CloudSync = "cloud-sync"
+
+// GetAckPath returns the path to the file recording acknowledged notices.
+func GetAckPath() (string, error) {
+	profilesDir, err := profile.GetProfilesDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(profilesDir, ackFile), nil
+}
+
+// Acknowledged reports whether the user has already seen or dismissed a notice.
+func Acknowledged(key string) (bool, error) {
+	ackPath, err := GetAckPath()
+	if err != nil {
+		return false, err
+	}
+
+	file, err := os.Open(ackPath)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to open acknowledgements: %w", err)
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if strings.TrimSpace(scanner.Text()) == key {
+			return true, nil
+		}
+	}
+	return false, scanner.Err()
+}
+
+// Acknowledge records a notice so it is not shown again.
+func Acknowledge(key string) error {
+	acked, err := Acknowledged(key)
+	if err != nil || acked {
+		return err
+	}
+
+	ackPath, err := GetAckPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(ackPath), utils.PrivateDirMode); err != nil {
+		return fmt.Errorf("failed to create acknowledgements directory: %w", err)
+	}
+
+	file, err := os.OpenFile(ackPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, utils.PrivateFileMode)
+	if err != nil {
+		return fmt.Errorf("failed to open acknowledgements: %w", err)
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	if _, err := file.WriteString(key + "\n"); err != nil {
+		return fmt.Errorf("failed to write acknowledgements: %w", err)
+	}
+	return nil
+}
+
+// ShowOnce returns true the first time it is called for key and records the
+// acknowledgement, so one-time warnings are printed exactly once.
+func ShowOnce(key string) bool {
+	acked, err := Acknowledged(key)
+	if err != nil || acked {
+		return false
+	}
+	return Acknowledge(key) == nil
+}
@@ -0,0 +1,63 @@
+package notice
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/thuanlegit/git-identitree/internal/utils"
+)
+
+func setupNoticeTestEnv(t *testing.T) string {
+	t.Helper()
+
+	tmpDir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to resolve temp directory: %v", err)
+	}
+
+	// Override home directory for testing on all platforms
+	t.Setenv(utils.RootEnv, tmpDir)
+
+	return tmpDir
+}
+
+func TestAcknowledge(t *testing.T) {
+	setupNoticeTestEnv(t)
+
+	acked, err := Acknowledged(CloudSync)
+	if err != nil {
+		t.Fatalf("Acknowledged() error = %v", err)
+	}
+	if acked {
+		t.Error("Acknowledged() = true before any acknowledgement")
+	}
+
+	if err := Acknowledge(CloudSync); err != nil {
+		t.Fatalf("Acknowledge() error = %v", err)
+	}
+	if err := Acknowledge(CloudSync); err != nil {
+		t.Fatalf("Acknowledge() second call error = %v", err)
+	}
+
+	acked, err = Acknowledged(CloudSync)
+	if err != nil {
+		t.Fatalf("Acknowledged() error = %v", err)
+	}
+	if !acked {
+		t.Error("Acknowledged() = false after Acknowledge()")
+	}
+	if acked, _ := Acknowledged("other"); acked {
+		t.Error("Acknowledged() should be per key")
+	}
+}
+
+func TestShowOnce(t *testing.T) {
+	setupNoticeTestEnv(t)
+
+	if !ShowOnce(CloudSync) {
+		t.Error("ShowOnce() = false on first call")
+	}
+	if ShowOnce(CloudSync) {
+		t.Error("ShowOnce() = true on second call")
+	}
+}
@@ -0,0 +1,177 @@
+package profile
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/thuanlegit/git-identitree/internal/vault"
+)
+
+// PassphraseEnv names the environment variable that supplies the passphrase
+// of an encrypted profiles.yaml to non-interactive runs.
+const PassphraseEnv = "GIDTREE_PASSPHRASE"
+
+// ErrPassphraseRequired is returned when profiles are encrypted and no passphrase is available.
+var ErrPassphraseRequired = errors.New("profiles are encrypted; run in a terminal or set " + PassphraseEnv)
+
+// PassphraseSource supplies the passphrase of an encrypted profiles.yaml.
+// The CLI replaces it with one that prompts on a terminal.
+var PassphraseSource = func() (string, error) {
+	if p := os.Getenv(PassphraseEnv); p != "" {
+		return p, nil
+	}
+	return "", ErrPassphraseRequired
+}
+
+// cachedPassphrase keeps the passphrase for the rest of the process so a
+// load followed by a save asks only once. The mutex is held while asking, so
+// concurrent loads share one prompt.
+var cachedPassphrase struct {
+	mu    sync.Mutex
+	value string
+}
+
+// Storage converts between the profiles.yaml document and the bytes kept on disk.
+type Storage interface {
+	// Decode returns the document held in stored.
+	Decode(stored []byte) ([]byte, error)
+	// Encode returns the bytes to store for doc.
+	Encode(doc []byte) ([]byte, error)
+}
+
+// PlainStorage keeps profiles.yaml as readable YAML.
+type PlainStorage struct{}
+
+// Decode implements Storage.
+func (PlainStorage) Decode(stored []byte) ([]byte, error) { return stored, nil }
+
+// Encode implements Storage.
+func (PlainStorage) Encode(doc []byte) ([]byte, error) { return doc, nil }
+
+// EncryptedStorage keeps profiles.yaml encrypted with a passphrase-derived key.
+type EncryptedStorage struct{}
+
+// Decode implements Storage.
+func (EncryptedStorage) Decode(stored []byte) ([]byte, error) {
+	passphrase, err := passphrase()
+	if err != nil {
+		return nil, err
+	}
+	doc, err := vault.Open(stored, passphrase)
+	if err != nil {
+		// Ask again next time instead of repeating a wrong answer
+		setCachedPassphrase("")
+		return nil, err
+	}
+	return doc, nil
+}
+
+// Encode implements Storage.
+func (EncryptedStorage) Encode(doc []byte) ([]byte, error) {
+	passphrase, err := passphrase()
+	if err != nil {
+		return nil, err
+	}
+	return vault.Seal(doc, passphrase)
+}
+
+// storageFor picks the Storage that reads stored.
+func storageFor(stored []byte) Storage {
+	if vault.IsSealed(stored) {
+		return EncryptedStorage{}
+	}
+	return PlainStorage{}
+}
+
+// passphrase returns the cached passphrase or asks PassphraseSource for one.
+func passphrase() (string, error) {
+	cachedPassphrase.mu.Lock()
+	defer cachedPassphrase.mu.Unlock()
+
+	if cachedPassphrase.value != "" {
+		return cachedPassphrase.value, nil
+	}
+	p, err := PassphraseSource()
+	if err != nil {
+		return "", err
+	}
+	if p == "" {
+		return "", vault.ErrEmptyPassphrase
+	}
+	cachedPassphrase.value = p
+	return p, nil
+}
+
+// setCachedPassphrase replaces the cached passphrase; "" forgets it.
+func setCachedPassphrase(p string) {
+	cachedPassphrase.mu.Lock()
+	defer cachedPassphrase.mu.Unlock()
+
+	cachedPassphrase.value = p
+}
+
+// IsEncrypted reports whether ~/.gidtree/profiles.yaml is stored encrypted.
+func IsEncrypted() (bool, error) {
+	return FileStore{}.IsEncrypted()
+}
+
+// EncryptStore rewrites ~/.gidtree/profiles.yaml encrypted with passphrase.
+func EncryptStore(passphrase string) error {
+	return FileStore{}.Encrypt(passphrase)
+}
+
+// DecryptStore rewrites an encrypted ~/.gidtree/profiles.yaml as plain YAML.
+func DecryptStore() error {
+	return FileStore{}.Decrypt()
+}
+
+// IsEncrypted reports whether the store's profiles.yaml is stored encrypted.
+func (s FileStore) IsEncrypted() (bool, error) {
+	path, err := s.Path()
+	if err != nil {
+		return false, err
+	}
+	data, err := readProfilesFile(path)
+	if err != nil || data == nil {
+		return false, err
+	}
+	return vault.IsSealed(data), nil
+}
+
+// Encrypt rewrites the store's profiles.yaml encrypted with passphrase.
+func (s FileStore) Encrypt(passphrase string) error {
+	if passphrase == "" {
+		return vault.ErrEmptyPassphrase
+	}
+	path, err := s.Path()
+	if err != nil {
+		return err
+	}
+	profiles, err := loadProfilesFile(path)
+	if err != nil {
+		return err
+	}
+	setCachedPassphrase(passphrase)
+	if err := writeProfiles(path, profiles, EncryptedStorage{}); err != nil {
+		return fmt.Errorf("failed to encrypt profiles: %w", err)
+	}
+	return nil
+}
+
+// Decrypt rewrites the store's encrypted profiles.yaml as plain YAML.
+func (s FileStore) Decrypt() error {
+	path, err := s.Path()
+	if err != nil {
+		return err
+	}
+	profiles, err := loadProfilesFile(path)
+	if err != nil {
+		return err
+	}
+	if err := writeProfiles(path, profiles, PlainStorage{}); err != nil {
+		return fmt.Errorf("failed to decrypt profiles: %w", err)
+	}
+	return nil
+}
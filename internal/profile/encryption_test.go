@@ -0,0 +1,141 @@
+package profile
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/thuanlegit/git-identitree/internal/vault"
+)
+
+// usePassphrase makes PassphraseSource answer with p for one test.
+func usePassphrase(t *testing.T, p string) {
+	t.Helper()
+	original := PassphraseSource
+	PassphraseSource = func() (string, error) { return p, nil }
+	setCachedPassphrase("")
+	t.Cleanup(func() {
+		PassphraseSource = original
+		setCachedPassphrase("")
+	})
+}
+
+func TestEncryptStore_RoundTrip(t *testing.T) {
+	store := newTestStore(t)
+
+	profiles := []Profile{{Name: "work", Email: "jane@company.com", SSHKeyPath: "~/.ssh/id_work"}}
+	if err := store.Save(profiles); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	usePassphrase(t, "s3cret")
+
+	if err := store.Encrypt("s3cret"); err != nil {
+		t.Fatalf("Encrypt() error = %v", err)
+	}
+	path := storePath(t, store)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read profiles file: %v", err)
+	}
+	if !vault.IsSealed(data) || strings.Contains(string(data), "jane@company.com") {
+		t.Fatalf("profiles.yaml is not encrypted:\n%s", data)
+	}
+	if encrypted, err := store.IsEncrypted(); err != nil || !encrypted {
+		t.Errorf("IsEncrypted() = %v, %v; want true", encrypted, err)
+	}
+
+	// Saves keep the file encrypted
+	setCachedPassphrase("")
+	profiles = append([]Profile{{Name: "personal", Email: "jane@example.com"}}, profiles...)
+	if err := store.Save(profiles); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	setCachedPassphrase("")
+	loaded, err := store.Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if !reflect.DeepEqual(loaded, profiles) {
+		t.Errorf("Load() = %+v, want %+v", loaded, profiles)
+	}
+	if encrypted, _ := store.IsEncrypted(); !encrypted {
+		t.Error("Save() should keep an encrypted file encrypted")
+	}
+
+	if err := store.Decrypt(); err != nil {
+		t.Fatalf("Decrypt() error = %v", err)
+	}
+	data, _ = os.ReadFile(path)
+	if vault.IsSealed(data) || !strings.Contains(string(data), "jane@company.com") {
+		t.Errorf("Decrypt() left:\n%s", data)
+	}
+}
+
+func TestLoadProfiles_WrongPassphrase(t *testing.T) {
+	store := newTestStore(t)
+
+	usePassphrase(t, "right")
+	if err := store.Encrypt("right"); err != nil {
+		t.Fatalf("Encrypt() error = %v", err)
+	}
+
+	usePassphrase(t, "wrong")
+	_, err := store.Load()
+	if !errors.Is(err, vault.ErrWrongPassphrase) {
+		t.Fatalf("Load() error = %v, want ErrWrongPassphrase", err)
+	}
+	if strings.Contains(err.Error(), "parse") {
+		t.Errorf("Load() error = %q, should not look like a YAML problem", err)
+	}
+}
+
+func TestLoadProfiles_NoPassphrase(t *testing.T) {
+	store := newTestStore(t)
+
+	usePassphrase(t, "right")
+	if err := store.Encrypt("right"); err != nil {
+		t.Fatalf("Encrypt() error = %v", err)
+	}
+
+	setCachedPassphrase("")
+	t.Setenv(PassphraseEnv, "")
+	PassphraseSource = func() (string, error) { return "", ErrPassphraseRequired }
+	if _, err := store.Load(); !errors.Is(err, ErrPassphraseRequired) {
+		t.Errorf("Load() error = %v, want ErrPassphraseRequired", err)
+	}
+}
+
+func TestLoadProfiles_ConcurrentPassphrase(t *testing.T) {
+	store := newTestStore(t)
+
+	usePassphrase(t, "s3cret")
+	if err := store.Encrypt("s3cret"); err != nil {
+		t.Fatalf("Encrypt() error = %v", err)
+	}
+
+	var prompts atomic.Int32
+	PassphraseSource = func() (string, error) {
+		prompts.Add(1)
+		return "s3cret", nil
+	}
+	setCachedPassphrase("")
+
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := store.Load(); err != nil {
+				t.Errorf("Load() error = %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := prompts.Load(); n != 1 {
+		t.Errorf("concurrent loads asked for the passphrase %d times, want 1", n)
+	}
+}
@@ -0,0 +1,27 @@
+package profile
+
+import "errors"
+
+var (
+	// ErrProfileNotFound is returned when no profile has the requested name.
+	ErrProfileNotFound = errors.New("profile not found")
+	// ErrAmbiguousName is returned when a name matches several profiles
+	// that differ only in case.
+	ErrAmbiguousName = errors.New("ambiguous profile name")
+	// ErrProfileExists is returned when adding a profile whose name is taken.
+	ErrProfileExists = errors.New("profile already exists")
+	// ErrInvalidName is returned for a profile name that cannot be used in a
+	// file name or git config.
+	ErrInvalidName = errors.New("invalid profile name")
+	// ErrSSHKeyMissing is returned when a profile's SSH key file does not exist.
+	ErrSSHKeyMissing = errors.New("SSH key does not exist")
+	// ErrPuTTYKey is returned when a profile's SSH key file is a key saved
+	// by PuTTY, which OpenSSH cannot read.
+	ErrPuTTYKey = errors.New("SSH key is a PuTTY key")
+	// ErrMissingPlaceholder is returned when rendering a template without a
+	// value for one of its placeholders.
+	ErrMissingPlaceholder = errors.New("template placeholder has no value")
+	// ErrProfilesChanged is returned when saving would overwrite changes
+	// another process made to the store since the manager loaded it.
+	ErrProfilesChanged = errors.New("profiles changed on disk, re-run")
+)
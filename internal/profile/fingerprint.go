@@ -0,0 +1,83 @@
+package profile
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/thuanlegit/git-identitree/internal/utils"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// KeyFingerprint returns the SHA256 fingerprint of the SSH key at path, as
+// ssh-keygen -l prints it: "SHA256:" followed by unpadded base64. It reads
+// the private key, whose public half OpenSSH keeps readable without the
+// passphrase; keys it cannot read that way, such as encrypted PEM keys, are
+// fingerprinted from the .pub file next to them.
+func KeyFingerprint(path string) (string, error) {
+	expanded, err := utils.ExpandPath(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to expand SSH key path: %w", err)
+	}
+	data, err := os.ReadFile(expanded)
+	if err != nil {
+		return "", fmt.Errorf("failed to read SSH key: %w", err)
+	}
+	pub, err := privateKeyPublicHalf(data)
+	if err != nil {
+		pubData, pubErr := os.ReadFile(expanded + ".pub")
+		if pubErr != nil {
+			return "", fmt.Errorf("failed to read SSH key %s: %w", path, err)
+		}
+		if pub, _, _, _, err = ssh.ParseAuthorizedKey(pubData); err != nil {
+			return "", fmt.Errorf("failed to read SSH public key %s.pub: %w", path, err)
+		}
+	}
+	return ssh.FingerprintSHA256(pub), nil
+}
+
+// privateKeyPublicHalf returns the public key of the private key in data.
+func privateKeyPublicHalf(data []byte) (ssh.PublicKey, error) {
+	signer, err := ssh.ParsePrivateKey(data)
+	if err == nil {
+		return signer.PublicKey(), nil
+	}
+	var missing *ssh.PassphraseMissingError
+	if errors.As(err, &missing) && missing.PublicKey != nil {
+		return missing.PublicKey, nil
+	}
+	return nil, err
+}
+
+// RefreshFingerprint records the fingerprint of the profile's SSH key in
+// SSHKeyFingerprint and reports whether it changed. A profile without a key
+// gets none. When the key cannot be read, the recorded fingerprint is kept
+// and the error returned.
+func (p *Profile) RefreshFingerprint() (bool, error) {
+	fingerprint := ""
+	if p.SSHKeyPath != "" {
+		var err error
+		if fingerprint, err = KeyFingerprint(p.SSHKeyPath); err != nil {
+			return false, err
+		}
+	}
+	changed := fingerprint != p.SSHKeyFingerprint
+	p.SSHKeyFingerprint = fingerprint
+	return changed, nil
+}
+
+// KeyRotated reports whether the SSH key file now holds a different key than
+// the one whose fingerprint was recorded, as after rotating the key in place,
+// and returns the current fingerprint. Profiles with no fingerprint recorded,
+// or whose key cannot be read, are not reported.
+func (p *Profile) KeyRotated() (string, bool) {
+	if p.SSHKeyPath == "" || p.SSHKeyFingerprint == "" {
+		return "", false
+	}
+	current, err := KeyFingerprint(p.SSHKeyPath)
+	if err != nil {
+		return "", false
+	}
+	return current, current != p.SSHKeyFingerprint
+}
@@ -0,0 +1,187 @@
+package profile
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// writeTestKey generates an ed25519 key at path, encrypted with passphrase
+// unless it is empty, with its .pub file next to it, and returns its
+// fingerprint.
+func writeTestKey(t *testing.T, path, passphrase string) string {
+	t.Helper()
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+	var block *pem.Block
+	if passphrase == "" {
+		block, err = ssh.MarshalPrivateKey(priv, "test")
+	} else {
+		block, err = ssh.MarshalPrivateKeyWithPassphrase(priv, "test", []byte(passphrase))
+	}
+	if err != nil {
+		t.Fatalf("MarshalPrivateKey() error = %v", err)
+	}
+	if err := os.WriteFile(path, pem.EncodeToMemory(block), 0600); err != nil {
+		t.Fatalf("Failed to write key: %v", err)
+	}
+	sshPub, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		t.Fatalf("NewPublicKey() error = %v", err)
+	}
+	if err := os.WriteFile(path+".pub", ssh.MarshalAuthorizedKey(sshPub), 0644); err != nil {
+		t.Fatalf("Failed to write public key: %v", err)
+	}
+	return ssh.FingerprintSHA256(sshPub)
+}
+
+func TestKeyFingerprint(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+
+	plain := filepath.Join(dir, "id_plain")
+	want := writeTestKey(t, plain, "")
+	if got, err := KeyFingerprint(plain); err != nil || got != want {
+		t.Errorf("KeyFingerprint() = %q, %v; want %q", got, err, want)
+	}
+
+	// OpenSSH keeps the public half readable without the passphrase
+	encrypted := filepath.Join(dir, "id_encrypted")
+	want = writeTestKey(t, encrypted, "secret")
+	if got, err := KeyFingerprint(encrypted); err != nil || got != want {
+		t.Errorf("KeyFingerprint() of an encrypted key = %q, %v; want %q", got, err, want)
+	}
+
+	// Keys x/crypto cannot read fall back to the .pub file
+	unreadable := filepath.Join(dir, "id_other")
+	want = writeTestKey(t, unreadable, "")
+	if err := os.WriteFile(unreadable, []byte("not a key"), 0600); err != nil {
+		t.Fatalf("Failed to write key: %v", err)
+	}
+	if got, err := KeyFingerprint(unreadable); err != nil || got != want {
+		t.Errorf("KeyFingerprint() from the .pub file = %q, %v; want %q", got, err, want)
+	}
+
+	if err := os.Remove(unreadable + ".pub"); err != nil {
+		t.Fatalf("Failed to remove public key: %v", err)
+	}
+	if _, err := KeyFingerprint(unreadable); err == nil {
+		t.Error("KeyFingerprint() of an unreadable key without a .pub file should fail")
+	}
+	if _, err := KeyFingerprint(filepath.Join(dir, "missing")); err == nil {
+		t.Error("KeyFingerprint() of a missing key should fail")
+	}
+}
+
+func TestManager_KeyRotation(t *testing.T) {
+	t.Parallel()
+	keyPath := filepath.Join(t.TempDir(), "id_work")
+	original := writeTestKey(t, keyPath, "")
+
+	manager, err := NewManager(&memoryStore{})
+	if err != nil {
+		t.Fatalf("NewManager() error = %v", err)
+	}
+	if err := manager.AddProfile(Profile{Name: "work", Email: "work@example.com", SSHKeyPath: keyPath}); err != nil {
+		t.Fatalf("AddProfile() error = %v", err)
+	}
+	if err := manager.AddProfile(Profile{Name: "personal", Email: "me@example.com"}); err != nil {
+		t.Fatalf("AddProfile() error = %v", err)
+	}
+	prof, _ := manager.GetProfile("work")
+	if prof.SSHKeyFingerprint != original {
+		t.Fatalf("SSHKeyFingerprint = %q, want %q", prof.SSHKeyFingerprint, original)
+	}
+	if _, rotated := prof.KeyRotated(); rotated {
+		t.Error("KeyRotated() = true before the key changed")
+	}
+
+	// Rotate the key in place
+	rotatedKey := writeTestKey(t, keyPath, "")
+	if current, rotated := prof.KeyRotated(); !rotated || current != rotatedKey {
+		t.Errorf("KeyRotated() = %q, %v; want %q, true", current, rotated, rotatedKey)
+	}
+
+	// Unrelated edits keep the recorded fingerprint
+	prof.Email = "jane@example.com"
+	prof.SSHKeyFingerprint = ""
+	if err := manager.UpdateProfile("work", *prof); err != nil {
+		t.Fatalf("UpdateProfile() error = %v", err)
+	}
+	if prof, _ = manager.GetProfile("work"); prof.SSHKeyFingerprint != original {
+		t.Errorf("SSHKeyFingerprint after an update = %q, want %q kept", prof.SSHKeyFingerprint, original)
+	}
+
+	updates, err := manager.RefreshFingerprints()
+	if err != nil {
+		t.Fatalf("RefreshFingerprints() error = %v", err)
+	}
+	if len(updates) != 2 {
+		t.Fatalf("RefreshFingerprints() = %+v, want both profiles", updates)
+	}
+	for _, u := range updates {
+		switch u.Profile {
+		case "work":
+			if !u.Changed() || u.Old != original || u.New != rotatedKey {
+				t.Errorf("work update = %+v, want %q to %q", u, original, rotatedKey)
+			}
+		case "personal":
+			if u.Changed() || u.New != "" || u.Err != nil {
+				t.Errorf("personal update = %+v, want no fingerprint", u)
+			}
+		}
+	}
+	if prof, _ = manager.GetProfile("work"); prof.SSHKeyFingerprint != rotatedKey {
+		t.Errorf("SSHKeyFingerprint after refresh = %q, want %q", prof.SSHKeyFingerprint, rotatedKey)
+	}
+
+	if _, err := manager.RefreshFingerprints("nobody"); err == nil {
+		t.Error("RefreshFingerprints() of an unknown profile should fail")
+	}
+}
+
+func TestManager_FingerprintFollowsKeyPath(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	keyPath := filepath.Join(dir, "id_work")
+	original := writeTestKey(t, keyPath, "")
+	unreadable := filepath.Join(dir, "id_other")
+	if err := os.WriteFile(unreadable, []byte("not a key"), 0600); err != nil {
+		t.Fatalf("Failed to write key: %v", err)
+	}
+
+	manager, err := NewManager(&memoryStore{})
+	if err != nil {
+		t.Fatalf("NewManager() error = %v", err)
+	}
+	if err := manager.AddProfile(Profile{Name: "work", Email: "work@example.com", SSHKeyPath: keyPath}); err != nil {
+		t.Fatalf("AddProfile() error = %v", err)
+	}
+	source, _ := manager.GetProfile("work")
+
+	// A clone with a key that cannot be read does not inherit the source's
+	clone := source.CloneAs("client")
+	clone.SSHKeyPath = unreadable
+	if err := manager.AddProfile(clone); err != nil {
+		t.Fatalf("AddProfile() error = %v", err)
+	}
+	if prof, _ := manager.GetProfile("client"); prof.SSHKeyFingerprint != "" {
+		t.Errorf("clone SSHKeyFingerprint = %q, want none", prof.SSHKeyFingerprint)
+	}
+
+	// Nor does an update pointing the profile at such a key
+	source.SSHKeyPath = unreadable
+	if err := manager.UpdateProfile("work", *source); err != nil {
+		t.Fatalf("UpdateProfile() error = %v", err)
+	}
+	if prof, _ := manager.GetProfile("work"); prof.SSHKeyFingerprint != "" {
+		t.Errorf("SSHKeyFingerprint after changing the key = %q, want %q dropped", prof.SSHKeyFingerprint, original)
+	}
+}
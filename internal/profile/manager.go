@@ -2,99 +2,257 @@ package profile
 
 import (
 	"fmt"
-	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/thuanlegit/git-identitree/internal/utils"
 )
 
-// Manager handles profile CRUD operations.
+// Manager handles profile CRUD operations. It is safe for concurrent use.
+//
+// When the store is a WatchedStore, the manager remembers its state at load and
+// refuses to save over changes made since then by another process, returning
+// ErrProfilesChanged.
 type Manager struct {
+	// AutoReload reloads the profiles before each change if the store was
+	// modified since they were loaded, for long-running sessions that should
+	// apply their changes on top of other processes' instead of failing.
+	AutoReload bool
+	// Force saves even if the store changed since the profiles were loaded,
+	// overwriting those changes.
+	Force bool
+
+	mu       sync.Mutex
+	store    ProfileStore
 	profiles []Profile
+	// state is the store's state when the profiles were last loaded or saved.
+	state StoreState
 }
 
-// NewManager creates a new profile manager and loads existing profiles.
-func NewManager() (*Manager, error) {
-	profiles, err := LoadProfiles()
-	if err != nil {
+// now returns the current time. Replaced in tests.
+var now = time.Now
+
+// NewManager creates a profile manager backed by store and loads its profiles.
+func NewManager(store ProfileStore) (*Manager, error) {
+	m := &Manager{store: store}
+	if err := m.load(); err != nil {
 		return nil, err
 	}
-	return &Manager{profiles: profiles}, nil
+	return m, nil
+}
+
+// NewDefaultManager creates a profile manager backed by ~/.gidtree/profiles.yaml.
+func NewDefaultManager() (*Manager, error) {
+	return NewManager(FileStore{})
+}
+
+// Reload reads the profiles from the store again, dropping what was loaded.
+func (m *Manager) Reload() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.load()
 }
 
-// GetProfile retrieves a profile by name.
+// GetProfile retrieves a profile by name. Surrounding whitespace is ignored,
+// and so is case when exactly one profile matches that way. The profile is a
+// copy: changes to it are not seen by the manager until passed to
+// UpdateProfile.
 func (m *Manager) GetProfile(name string) (*Profile, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i, err := m.find(name)
+	if err != nil {
+		return nil, err
+	}
+	prof := m.profiles[i].Clone()
+	return &prof, nil
+}
+
+// find returns the index of the profile name refers to: the one with exactly
+// that name once trimmed, or else the only one whose name differs in case.
+func (m *Manager) find(name string) (int, error) {
+	name = strings.TrimSpace(name)
+	match := -1
+	var matches []string
 	for i := range m.profiles {
 		if m.profiles[i].Name == name {
-			return &m.profiles[i], nil
+			return i, nil
+		}
+		if strings.EqualFold(m.profiles[i].Name, name) {
+			match = i
+			matches = append(matches, m.profiles[i].Name)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return -1, utils.WithDetail(ErrProfileNotFound, "profile '%s' not found", name)
+	case 1:
+		return match, nil
+	default:
+		return -1, utils.WithDetail(ErrAmbiguousName, "profile name '%s' matches %s; use the exact name", name, strings.Join(matches, ", "))
+	}
+}
+
+// checkNameFree fails with ErrProfileExists when a profile other than the one
+// at index except has name, ignoring case.
+func (m *Manager) checkNameFree(name string, except int) error {
+	for i, p := range m.profiles {
+		if i != except && strings.EqualFold(p.Name, name) {
+			return utils.WithDetail(ErrProfileExists, "profile '%s' already exists", p.Name)
 		}
 	}
-	return nil, fmt.Errorf("profile '%s' not found", name)
+	return nil
 }
 
-// ListProfiles returns all profiles.
+// ListProfiles returns all profiles, sorted by name.
 func (m *Manager) ListProfiles() []Profile {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	return m.profiles
 }
 
 // AddProfile adds a new profile.
 func (m *Manager) AddProfile(profile Profile) error {
-	// Check if profile with same name already exists
-	for _, p := range m.profiles {
-		if p.Name == profile.Name {
-			return fmt.Errorf("profile '%s' already exists", profile.Name)
-		}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.reloadIfChanged(); err != nil {
+		return err
 	}
 
-	// Validate SSH key path if provided
-	if profile.SSHKeyPath != "" {
-		expandedPath, err := utils.ExpandPath(profile.SSHKeyPath)
-		if err != nil {
-			return fmt.Errorf("failed to expand SSH key path: %w", err)
-		}
-		if _, err := os.Stat(expandedPath); os.IsNotExist(err) {
-			return fmt.Errorf("SSH key path does not exist: %s", profile.SSHKeyPath)
-		}
+	if err := ValidateName(profile.Name); err != nil {
+		return err
+	}
+
+	// Names differing only in case would be ambiguous to look up
+	if err := m.checkNameFree(profile.Name, -1); err != nil {
+		return err
 	}
 
-	m.profiles = append(m.profiles, profile)
-	return m.save()
+	if err := ValidateSSHKeyPath(profile.SSHKeyPath); err != nil {
+		return err
+	}
+
+	if err := ValidateRemotePatterns(profile.RemotePatterns); err != nil {
+		return err
+	}
+	if err := validateCommitter(profile); err != nil {
+		return err
+	}
+	if err := ValidateCoAuthors(profile.CoAuthors); err != nil {
+		return err
+	}
+	if err := validateSigning(profile); err != nil {
+		return err
+	}
+
+	if profile.CreatedAt.IsZero() {
+		profile.CreatedAt = now().UTC().Truncate(time.Second)
+	}
+	// A key that cannot be read keeps the fingerprint given, if any
+	_, _ = profile.RefreshFingerprint()
+	return m.save(append(slices.Clone(m.profiles), profile))
 }
 
-// UpdateProfile updates an existing profile.
+// UpdateProfile updates an existing profile, found by name as GetProfile does.
 func (m *Manager) UpdateProfile(name string, profile Profile) error {
-	for i := range m.profiles {
-		if m.profiles[i].Name == name {
-			// Validate SSH key path if provided
-			if profile.SSHKeyPath != "" {
-				expandedPath, err := utils.ExpandPath(profile.SSHKeyPath)
-				if err != nil {
-					return fmt.Errorf("failed to expand SSH key path: %w", err)
-				}
-				if _, err := os.Stat(expandedPath); os.IsNotExist(err) {
-					return fmt.Errorf("SSH key path does not exist: %s", profile.SSHKeyPath)
-				}
-			}
-			m.profiles[i] = profile
-			return m.save()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.reloadIfChanged(); err != nil {
+		return err
+	}
+
+	i, err := m.find(name)
+	if err != nil {
+		return err
+	}
+	// Names from before validation stay usable until renamed
+	if profile.Name != m.profiles[i].Name {
+		if err := ValidateName(profile.Name); err != nil {
+			return err
+		}
+		if err := m.checkNameFree(profile.Name, i); err != nil {
+			return err
 		}
 	}
-	return fmt.Errorf("profile '%s' not found", name)
+	if err := ValidateSSHKeyPath(profile.SSHKeyPath); err != nil {
+		return err
+	}
+	if err := ValidateRemotePatterns(profile.RemotePatterns); err != nil {
+		return err
+	}
+	if err := validateCommitter(profile); err != nil {
+		return err
+	}
+	if err := ValidateCoAuthors(profile.CoAuthors); err != nil {
+		return err
+	}
+	if err := validateSigning(profile); err != nil {
+		return err
+	}
+	// Updates keep the creation and last-use times unless they set them
+	if profile.CreatedAt.IsZero() {
+		profile.CreatedAt = m.profiles[i].CreatedAt
+	}
+	if profile.LastUsed.IsZero() {
+		profile.LastUsed = m.profiles[i].LastUsed
+	}
+	// The recorded fingerprint stays with its key file, so that a key
+	// rotated in place is still noticed after unrelated edits
+	if profile.SSHKeyPath != m.profiles[i].SSHKeyPath {
+		profile.SSHKeyFingerprint = ""
+		_, _ = profile.RefreshFingerprint()
+	} else if profile.SSHKeyFingerprint == "" {
+		profile.SSHKeyFingerprint = m.profiles[i].SSHKeyFingerprint
+		if profile.SSHKeyFingerprint == "" {
+			_, _ = profile.RefreshFingerprint()
+		}
+	}
+	profiles := slices.Clone(m.profiles)
+	profiles[i] = profile
+	return m.save(profiles)
+}
+
+// RenameProfile gives the profile named oldName the name newName. It only
+// renames the profile; the files named after it are the caller's to move.
+func (m *Manager) RenameProfile(oldName, newName string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.reloadIfChanged(); err != nil {
+		return err
+	}
+
+	index, err := m.find(oldName)
+	if err != nil {
+		return err
+	}
+	if err := ValidateName(newName); err != nil {
+		return err
+	}
+	// Renaming may change only the case of the name
+	if err := m.checkNameFree(newName, index); err != nil {
+		return err
+	}
+	profiles := slices.Clone(m.profiles)
+	profiles[index].Name = newName
+	return m.save(profiles)
 }
 
 // DeleteProfile removes a profile by name.
 // It returns an error if the profile is mapped to any directories.
 func (m *Manager) DeleteProfile(name string, isMapped func(string) (bool, error)) error {
-	// Check if profile exists
-	exists := false
-	for i := range m.profiles {
-		if m.profiles[i].Name == name {
-			exists = true
-			break
-		}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.reloadIfChanged(); err != nil {
+		return err
 	}
-	if !exists {
-		return fmt.Errorf("profile '%s' not found", name)
+
+	i, err := m.find(name)
+	if err != nil {
+		return err
 	}
+	name = m.profiles[i].Name
 
 	// Check if profile is mapped
 	if isMapped != nil {
@@ -107,19 +265,173 @@ func (m *Manager) DeleteProfile(name string, isMapped func(string) (bool, error)
 		}
 	}
 
-	// Remove profile
-	newProfiles := []Profile{}
-	for _, p := range m.profiles {
-		if p.Name != name {
-			newProfiles = append(newProfiles, p)
+	return m.save(slices.Delete(slices.Clone(m.profiles), i, i+1))
+}
+
+// RecordUse sets LastUsed of the named profiles to at and saves them in a
+// single write. Profiles already used on the day of at are left alone, so
+// nothing is written when all of them were; names of profiles that no longer
+// exist are ignored. The profiles are reloaded first if another process
+// changed them, but a change made while saving still fails with
+// ErrProfilesChanged.
+func (m *Manager) RecordUse(at time.Time, names ...string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.reloadChanged(); err != nil {
+		return err
+	}
+
+	profiles := slices.Clone(m.profiles)
+	changed := false
+	for _, name := range names {
+		i, err := m.find(name)
+		if err != nil || profiles[i].UsedOn(at) {
+			continue
+		}
+		profiles[i].LastUsed = at.UTC().Truncate(time.Second)
+		changed = true
+	}
+	if !changed {
+		return nil
+	}
+	return m.save(profiles)
+}
+
+// FingerprintUpdate is the outcome of refreshing the recorded fingerprint of
+// one profile's SSH key.
+type FingerprintUpdate struct {
+	Profile string
+	// Old and New are the fingerprints recorded before and after; both are
+	// empty for a profile without a key.
+	Old, New string
+	// Err is why the key could not be read, in which case Old is kept.
+	Err error
+}
+
+// Changed reports whether the recorded fingerprint changed.
+func (u FingerprintUpdate) Changed() bool {
+	return u.Err == nil && u.Old != u.New
+}
+
+// RefreshFingerprints records the current fingerprint of the SSH key of the
+// named profiles, or of every profile when no names are given, in a single
+// write, and returns the outcome for each.
+func (m *Manager) RefreshFingerprints(names ...string) ([]FingerprintUpdate, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.reloadIfChanged(); err != nil {
+		return nil, err
+	}
+
+	indexes := make([]int, 0, len(names))
+	for _, name := range names {
+		i, err := m.find(name)
+		if err != nil {
+			return nil, err
+		}
+		indexes = append(indexes, i)
+	}
+	if len(names) == 0 {
+		for i := range m.profiles {
+			indexes = append(indexes, i)
 		}
 	}
-	m.profiles = newProfiles
-	return m.save()
+
+	profiles := slices.Clone(m.profiles)
+	updates := make([]FingerprintUpdate, 0, len(indexes))
+	changed := false
+	for _, i := range indexes {
+		u := FingerprintUpdate{Profile: profiles[i].Name, Old: profiles[i].SSHKeyFingerprint}
+		refreshed, err := profiles[i].RefreshFingerprint()
+		u.New, u.Err = profiles[i].SSHKeyFingerprint, err
+		changed = changed || refreshed
+		updates = append(updates, u)
+	}
+	if !changed {
+		return updates, nil
+	}
+	return updates, m.save(profiles)
 }
 
-// save persists profiles to disk.
-func (m *Manager) save() error {
-	return SaveProfiles(m.profiles)
+// load reads the profiles and the state of the store. The state is read first,
+// so a change made in between shows up as a change on the next check.
+func (m *Manager) load() error {
+	state, err := m.storeState()
+	if err != nil {
+		return err
+	}
+	profiles, err := m.store.Load()
+	if err != nil {
+		return err
+	}
+	Sort(profiles, SortByName)
+	m.profiles = profiles
+	m.state = state
+	return nil
+}
+
+// reloadIfChanged reloads the profiles when AutoReload is set and the store
+// was modified since they were loaded.
+func (m *Manager) reloadIfChanged() error {
+	if !m.AutoReload {
+		return nil
+	}
+	return m.reloadChanged()
+}
+
+// reloadChanged reloads the profiles when the store was modified since they
+// were loaded.
+func (m *Manager) reloadChanged() error {
+	state, err := m.storeState()
+	if err != nil {
+		return err
+	}
+	if state.ModTime.Equal(m.state.ModTime) && state.Hash == m.state.Hash {
+		return nil
+	}
+	if err := m.load(); err != nil {
+		return fmt.Errorf("failed to reload profiles: %w", err)
+	}
+	return nil
+}
+
+// save persists profiles to the store and makes them the manager's profiles.
+// Unless Force is set, it fails with ErrProfilesChanged when the store's
+// content changed since the profiles were loaded.
+func (m *Manager) save(profiles []Profile) error {
+	if !m.Force {
+		state, err := m.storeState()
+		if err != nil {
+			return err
+		}
+		if state.Hash != m.state.Hash {
+			return utils.WithDetail(ErrProfilesChanged, "profiles changed on disk since they were read; re-run the command, or use --force to overwrite the changes")
+		}
+	}
+	Sort(profiles, SortByName)
+	if err := m.store.Save(profiles); err != nil {
+		return err
+	}
+	state, err := m.storeState()
+	if err != nil {
+		return err
+	}
+	m.profiles = profiles
+	m.state = state
+	return nil
+}
+
+// storeState returns the state of a WatchedStore, or the zero state for a
+// store that cannot report one, which is then never seen as changed.
+func (m *Manager) storeState() (StoreState, error) {
+	watched, ok := m.store.(WatchedStore)
+	if !ok {
+		return StoreState{}, nil
+	}
+	state, err := watched.State()
+	if err != nil {
+		return StoreState{}, fmt.Errorf("failed to check profiles for changes: %w", err)
+	}
+	return state, nil
 }
 
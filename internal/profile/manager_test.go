@@ -1,15 +1,44 @@
 package profile
 
 import (
+	"errors"
 	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
+	"time"
+
+	"github.com/thuanlegit/git-identitree/internal/utils"
 )
 
-func TestManager_AddProfile(t *testing.T) {
-	_, cleanup := setupTestEnv(t)
-	defer cleanup()
+// memoryStore is a ProfileStore that keeps profiles in memory.
+type memoryStore struct {
+	profiles []Profile
+	loadErr  error
+	saveErr  error
+	saves    int
+}
+
+func (s *memoryStore) Load() ([]Profile, error) {
+	if s.loadErr != nil {
+		return nil, s.loadErr
+	}
+	return append([]Profile(nil), s.profiles...), nil
+}
+
+func (s *memoryStore) Save(profiles []Profile) error {
+	if s.saveErr != nil {
+		return s.saveErr
+	}
+	s.saves++
+	s.profiles = append([]Profile(nil), profiles...)
+	return nil
+}
 
-	manager, err := NewManager()
+func TestManager_AddProfile(t *testing.T) {
+	t.Parallel()
+	manager, err := NewManager(&memoryStore{})
 	if err != nil {
 		t.Fatalf("NewManager() error = %v", err)
 	}
@@ -45,11 +74,73 @@ func TestManager_AddProfile(t *testing.T) {
 	}
 }
 
-func TestManager_AddProfile_InvalidSSHKey(t *testing.T) {
-	_, cleanup := setupTestEnv(t)
-	defer cleanup()
+func TestManager_InvalidCommitterEmail(t *testing.T) {
+	t.Parallel()
+	manager, err := NewManager(&memoryStore{})
+	if err != nil {
+		t.Fatalf("NewManager() error = %v", err)
+	}
+
+	if err := manager.AddProfile(Profile{Name: "work", Email: "work@example.com", CommitterEmail: "not an email"}); err == nil || !strings.Contains(err.Error(), "committer email") {
+		t.Errorf("AddProfile() error = %v, want an invalid committer email", err)
+	}
+	if err := manager.AddProfile(Profile{Name: "work", Email: "work@example.com", CommitterEmail: "bot@example.com"}); err != nil {
+		t.Fatalf("AddProfile() error = %v", err)
+	}
+	if err := manager.UpdateProfile("work", Profile{Name: "work", Email: "work@example.com", CommitterEmail: "bot"}); err == nil {
+		t.Error("UpdateProfile() should reject an invalid committer email")
+	}
+}
+
+func TestManager_InvalidCoAuthor(t *testing.T) {
+	t.Parallel()
+	manager, err := NewManager(&memoryStore{})
+	if err != nil {
+		t.Fatalf("NewManager() error = %v", err)
+	}
+	if err := manager.AddProfile(Profile{Name: "work", Email: "work@example.com", CoAuthors: []string{"ada@example.com"}}); err == nil || !strings.Contains(err.Error(), "co-author") {
+		t.Errorf("AddProfile() error = %v, want an invalid co-author", err)
+	}
+}
+
+func TestManager_SSHSigning(t *testing.T) {
+	t.Parallel()
+	manager, err := NewManager(&memoryStore{})
+	if err != nil {
+		t.Fatalf("NewManager() error = %v", err)
+	}
+	keyPath := filepath.Join(t.TempDir(), "id_work")
+	if err := os.WriteFile(keyPath, []byte("key"), 0600); err != nil {
+		t.Fatalf("Failed to write key: %v", err)
+	}
+	prof := Profile{Name: "work", Email: "work@example.com", SSHSigning: true}
+
+	if err := manager.AddProfile(prof); err == nil || !strings.Contains(err.Error(), "SSH key path") {
+		t.Errorf("AddProfile() error = %v, want a missing SSH key", err)
+	}
+	prof.SSHKeyPath = keyPath
+	if err := manager.AddProfile(prof); !errors.Is(err, ErrSSHKeyMissing) {
+		t.Errorf("AddProfile() error = %v, want ErrSSHKeyMissing without a public key", err)
+	}
+	if err := os.WriteFile(keyPath+".pub", []byte("ssh-ed25519 AAAA"), 0644); err != nil {
+		t.Fatalf("Failed to write public key: %v", err)
+	}
+	prof.GPGKeyID = "ABCD1234"
+	if err := manager.AddProfile(prof); err == nil || !strings.Contains(err.Error(), "GPG") {
+		t.Errorf("AddProfile() error = %v, want a conflict with the GPG key", err)
+	}
+	prof.GPGKeyID = ""
+	if err := manager.AddProfile(prof); err != nil {
+		t.Fatalf("AddProfile() error = %v", err)
+	}
+	if got := prof.SSHPublicKeyPath(); got != keyPath+".pub" {
+		t.Errorf("SSHPublicKeyPath() = %q, want %q", got, keyPath+".pub")
+	}
+}
 
-	manager, err := NewManager()
+func TestManager_AddProfile_InvalidSSHKey(t *testing.T) {
+	t.Parallel()
+	manager, err := NewManager(&memoryStore{})
 	if err != nil {
 		t.Fatalf("NewManager() error = %v", err)
 	}
@@ -66,10 +157,8 @@ func TestManager_AddProfile_InvalidSSHKey(t *testing.T) {
 }
 
 func TestManager_GetProfile(t *testing.T) {
-	_, cleanup := setupTestEnv(t)
-	defer cleanup()
-
-	manager, err := NewManager()
+	t.Parallel()
+	manager, err := NewManager(&memoryStore{})
 	if err != nil {
 		t.Fatalf("NewManager() error = %v", err)
 	}
@@ -99,11 +188,124 @@ func TestManager_GetProfile(t *testing.T) {
 	}
 }
 
-func TestManager_ListProfiles(t *testing.T) {
-	_, cleanup := setupTestEnv(t)
-	defer cleanup()
+func TestManager_GetProfile_ReturnsCopy(t *testing.T) {
+	t.Parallel()
+	manager, err := NewManager(&memoryStore{profiles: []Profile{
+		{Name: "work", Email: "work@example.com", RemotePatterns: []string{"github.com/acme/*"}},
+	}})
+	if err != nil {
+		t.Fatalf("NewManager() error = %v", err)
+	}
+
+	got, err := manager.GetProfile("work")
+	if err != nil {
+		t.Fatalf("GetProfile() error = %v", err)
+	}
+	got.Email = "changed@example.com"
+	got.RemotePatterns[0] = "gitlab.com/*"
+
+	again, err := manager.GetProfile("work")
+	if err != nil {
+		t.Fatalf("GetProfile() error = %v", err)
+	}
+	if again.Email != "work@example.com" || again.RemotePatterns[0] != "github.com/acme/*" {
+		t.Errorf("GetProfile() after changing the returned profile = %+v, want it unchanged", *again)
+	}
+	if listed := manager.ListProfiles(); listed[0].Email != "work@example.com" {
+		t.Errorf("ListProfiles() = %+v, want it unchanged", listed)
+	}
+
+	if err := manager.UpdateProfile("work", *got); err != nil {
+		t.Fatalf("UpdateProfile() error = %v", err)
+	}
+	if again, _ := manager.GetProfile("work"); again.Email != "changed@example.com" || again.RemotePatterns[0] != "gitlab.com/*" {
+		t.Errorf("GetProfile() after UpdateProfile() = %+v, want the change", *again)
+	}
+}
+
+func TestManager_GetProfile_Normalized(t *testing.T) {
+	t.Parallel()
+	manager, err := NewManager(&memoryStore{profiles: []Profile{
+		{Name: "work", Email: "work@example.com"},
+		{Name: "Acme", Email: "acme@example.com"},
+		{Name: "acme", Email: "acme2@example.com"},
+	}})
+	if err != nil {
+		t.Fatalf("NewManager() error = %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		wantEmail string
+		wantErr   error
+	}{
+		{name: "work", wantEmail: "work@example.com"},
+		{name: "Work", wantEmail: "work@example.com"},
+		{name: " WORK\n", wantEmail: "work@example.com"},
+		{name: "acme", wantEmail: "acme2@example.com"},
+		{name: "Acme", wantEmail: "acme@example.com"},
+		{name: "ACME", wantErr: ErrAmbiguousName},
+		{name: "personal", wantErr: ErrProfileNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			p, err := manager.GetProfile(tt.name)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("GetProfile(%q) error = %v, want %v", tt.name, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("GetProfile(%q) error = %v", tt.name, err)
+			}
+			if p.Email != tt.wantEmail {
+				t.Errorf("GetProfile(%q) = %s, want %s", tt.name, p.Email, tt.wantEmail)
+			}
+		})
+	}
+	if _, err := manager.GetProfile("ACME"); err == nil || !strings.Contains(err.Error(), "Acme, acme") {
+		t.Errorf("GetProfile() error = %v, want the candidates listed", err)
+	}
+}
 
-	manager, err := NewManager()
+func TestManager_NamesDifferingInCase(t *testing.T) {
+	t.Parallel()
+	store := &memoryStore{profiles: []Profile{{Name: "work", Email: "work@example.com"}, {Name: "personal", Email: "me@example.com"}}}
+	manager, err := NewManager(store)
+	if err != nil {
+		t.Fatalf("NewManager() error = %v", err)
+	}
+
+	if err := manager.AddProfile(Profile{Name: "Work", Email: "other@example.com"}); !errors.Is(err, ErrProfileExists) {
+		t.Errorf("AddProfile() error = %v, want ErrProfileExists", err)
+	}
+	if err := manager.RenameProfile("personal", "WORK"); !errors.Is(err, ErrProfileExists) {
+		t.Errorf("RenameProfile() error = %v, want ErrProfileExists", err)
+	}
+	if err := manager.UpdateProfile("personal", Profile{Name: "wOrk", Email: "me@example.com"}); !errors.Is(err, ErrProfileExists) {
+		t.Errorf("UpdateProfile() error = %v, want ErrProfileExists", err)
+	}
+
+	// Changing only the case of a name is fine, and so is updating by another case
+	if err := manager.RenameProfile("WORK ", "Work"); err != nil {
+		t.Fatalf("RenameProfile() error = %v", err)
+	}
+	if err := manager.UpdateProfile("work", Profile{Name: "Work", Email: "new@example.com"}); err != nil {
+		t.Fatalf("UpdateProfile() error = %v", err)
+	}
+	if err := manager.DeleteProfile(" PERSONAL", nil); err != nil {
+		t.Fatalf("DeleteProfile() error = %v", err)
+	}
+	if len(store.profiles) != 1 || store.profiles[0].Name != "Work" || store.profiles[0].Email != "new@example.com" {
+		t.Errorf("stored profiles = %+v, want only the updated Work", store.profiles)
+	}
+}
+
+func TestManager_ListProfiles(t *testing.T) {
+	t.Parallel()
+	manager, err := NewManager(&memoryStore{})
 	if err != nil {
 		t.Fatalf("NewManager() error = %v", err)
 	}
@@ -125,11 +327,70 @@ func TestManager_ListProfiles(t *testing.T) {
 	}
 }
 
-func TestManager_UpdateProfile(t *testing.T) {
-	_, cleanup := setupTestEnv(t)
-	defer cleanup()
+func TestManager_ListProfiles_Sorted(t *testing.T) {
+	t.Parallel()
+	store := &memoryStore{profiles: []Profile{{Name: "work", Email: "work@example.com"}, {Name: "Acme", Email: "acme@example.com"}}}
+	manager, err := NewManager(store)
+	if err != nil {
+		t.Fatalf("NewManager() error = %v", err)
+	}
+	clock := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	original := now
+	now = func() time.Time { return clock }
+	t.Cleanup(func() { now = original })
+
+	names := func(profiles []Profile) string {
+		var names []string
+		for _, p := range profiles {
+			names = append(names, p.Name)
+		}
+		return strings.Join(names, ",")
+	}
+	if got := names(manager.ListProfiles()); got != "Acme,work" {
+		t.Errorf("ListProfiles() = %s, want the loaded profiles sorted", got)
+	}
+
+	// The order does not depend on the order profiles come and go in
+	for range 3 {
+		for _, name := range []string{"zeta", "beta", "Mid"} {
+			if err := manager.AddProfile(Profile{Name: name, Email: name + "@example.com"}); err != nil {
+				t.Fatalf("AddProfile() error = %v", err)
+			}
+		}
+		if got := names(store.profiles); got != "Acme,beta,Mid,work,zeta" {
+			t.Errorf("stored profiles = %s, want them sorted by name", got)
+		}
+		for _, name := range []string{"beta", "zeta", "Mid"} {
+			if err := manager.DeleteProfile(name, nil); err != nil {
+				t.Fatalf("DeleteProfile() error = %v", err)
+			}
+		}
+		if got := names(manager.ListProfiles()); got != "Acme,work" {
+			t.Errorf("ListProfiles() = %s, want Acme,work", got)
+		}
+	}
+	if err := manager.AddProfile(Profile{Name: "new", Email: "new@example.com"}); err != nil {
+		t.Fatalf("AddProfile() error = %v", err)
+	}
+	p, err := manager.GetProfile("new")
+	if err != nil {
+		t.Fatalf("GetProfile() error = %v", err)
+	}
+	if !p.CreatedAt.Equal(clock) {
+		t.Errorf("CreatedAt = %v, want %v", p.CreatedAt, clock)
+	}
+	clock = clock.Add(time.Hour)
+	if err := manager.UpdateProfile("new", Profile{Name: "new", Email: "other@example.com"}); err != nil {
+		t.Fatalf("UpdateProfile() error = %v", err)
+	}
+	if p, _ := manager.GetProfile("new"); !p.CreatedAt.Equal(clock.Add(-time.Hour)) {
+		t.Errorf("CreatedAt after update = %v, want it kept", p.CreatedAt)
+	}
+}
 
-	manager, err := NewManager()
+func TestManager_UpdateProfile(t *testing.T) {
+	t.Parallel()
+	manager, err := NewManager(&memoryStore{})
 	if err != nil {
 		t.Fatalf("NewManager() error = %v", err)
 	}
@@ -163,10 +424,8 @@ func TestManager_UpdateProfile(t *testing.T) {
 }
 
 func TestManager_DeleteProfile(t *testing.T) {
-	_, cleanup := setupTestEnv(t)
-	defer cleanup()
-
-	manager, err := NewManager()
+	t.Parallel()
+	manager, err := NewManager(&memoryStore{})
 	if err != nil {
 		t.Fatalf("NewManager() error = %v", err)
 	}
@@ -197,10 +456,8 @@ func TestManager_DeleteProfile(t *testing.T) {
 }
 
 func TestManager_DeleteProfile_Mapped(t *testing.T) {
-	_, cleanup := setupTestEnv(t)
-	defer cleanup()
-
-	manager, err := NewManager()
+	t.Parallel()
+	manager, err := NewManager(&memoryStore{})
 	if err != nil {
 		t.Fatalf("NewManager() error = %v", err)
 	}
@@ -225,10 +482,8 @@ func TestManager_DeleteProfile_Mapped(t *testing.T) {
 }
 
 func TestManager_DeleteProfile_NonExistent(t *testing.T) {
-	_, cleanup := setupTestEnv(t)
-	defer cleanup()
-
-	manager, err := NewManager()
+	t.Parallel()
+	manager, err := NewManager(&memoryStore{})
 	if err != nil {
 		t.Fatalf("NewManager() error = %v", err)
 	}
@@ -243,10 +498,8 @@ func TestManager_DeleteProfile_NonExistent(t *testing.T) {
 }
 
 func TestManager_UpdateProfile_NonExistent(t *testing.T) {
-	_, cleanup := setupTestEnv(t)
-	defer cleanup()
-
-	manager, err := NewManager()
+	t.Parallel()
+	manager, err := NewManager(&memoryStore{})
 	if err != nil {
 		t.Fatalf("NewManager() error = %v", err)
 	}
@@ -262,10 +515,8 @@ func TestManager_UpdateProfile_NonExistent(t *testing.T) {
 }
 
 func TestManager_UpdateProfile_InvalidSSHKey(t *testing.T) {
-	_, cleanup := setupTestEnv(t)
-	defer cleanup()
-
-	manager, err := NewManager()
+	t.Parallel()
+	manager, err := NewManager(&memoryStore{})
 	if err != nil {
 		t.Fatalf("NewManager() error = %v", err)
 	}
@@ -290,11 +541,34 @@ func TestManager_UpdateProfile_InvalidSSHKey(t *testing.T) {
 	}
 }
 
-func TestManager_DeleteProfile_NoCheck(t *testing.T) {
-	_, cleanup := setupTestEnv(t)
-	defer cleanup()
+func TestManager_PuTTYKey(t *testing.T) {
+	t.Parallel()
+	manager, err := NewManager(&memoryStore{})
+	if err != nil {
+		t.Fatalf("NewManager() error = %v", err)
+	}
+	keyPath := filepath.Join(t.TempDir(), "work.ppk")
+	if err := os.WriteFile(keyPath, []byte("PuTTY-User-Key-File-2: ssh-rsa\n"), 0600); err != nil {
+		t.Fatalf("Failed to write key: %v", err)
+	}
+
+	prof := Profile{Name: "work", Email: "work@example.com", SSHKeyPath: keyPath}
+	if err := manager.AddProfile(prof); !errors.Is(err, ErrPuTTYKey) {
+		t.Errorf("AddProfile() error = %v, want ErrPuTTYKey", err)
+	}
+	prof.SSHKeyPath = ""
+	if err := manager.AddProfile(prof); err != nil {
+		t.Fatalf("AddProfile() error = %v", err)
+	}
+	prof.SSHKeyPath = keyPath
+	if err := manager.UpdateProfile("work", prof); !errors.Is(err, ErrPuTTYKey) {
+		t.Errorf("UpdateProfile() error = %v, want ErrPuTTYKey", err)
+	}
+}
 
-	manager, err := NewManager()
+func TestManager_DeleteProfile_NoCheck(t *testing.T) {
+	t.Parallel()
+	manager, err := NewManager(&memoryStore{})
 	if err != nil {
 		t.Fatalf("NewManager() error = %v", err)
 	}
@@ -321,10 +595,8 @@ func TestManager_DeleteProfile_NoCheck(t *testing.T) {
 }
 
 func TestManager_DeleteProfile_CheckError(t *testing.T) {
-	_, cleanup := setupTestEnv(t)
-	defer cleanup()
-
-	manager, err := NewManager()
+	t.Parallel()
+	manager, err := NewManager(&memoryStore{})
 	if err != nil {
 		t.Fatalf("NewManager() error = %v", err)
 	}
@@ -348,61 +620,275 @@ func TestManager_DeleteProfile_CheckError(t *testing.T) {
 	}
 }
 
-func TestManager_AddProfile_EmptyName(t *testing.T) {
-	_, cleanup := setupTestEnv(t)
-	defer cleanup()
+func TestManager_AddProfile_InvalidName(t *testing.T) {
+	t.Parallel()
+	manager, err := NewManager(&memoryStore{})
+	if err != nil {
+		t.Fatalf("NewManager() error = %v", err)
+	}
 
-	manager, err := NewManager()
+	for _, name := range []string{"", "work/client", "work client"} {
+		if err := manager.AddProfile(Profile{Name: name, Email: "test@example.com"}); !errors.Is(err, ErrInvalidName) {
+			t.Errorf("AddProfile(%q) error = %v, want ErrInvalidName", name, err)
+		}
+	}
+	if len(manager.ListProfiles()) != 0 {
+		t.Errorf("ListProfiles() = %v, want none added", manager.ListProfiles())
+	}
+}
+
+func TestManager_UpdateProfile_LegacyName(t *testing.T) {
+	t.Parallel()
+	store := &memoryStore{profiles: []Profile{{Name: "work client", Email: "work@example.com"}}}
+	manager, err := NewManager(store)
 	if err != nil {
 		t.Fatalf("NewManager() error = %v", err)
 	}
 
-	// This would be caught by validation in the form, but test the manager logic
-	profile := Profile{
-		Name:  "",
-		Email: "test@example.com",
+	// A name from before validation can still be updated in place
+	if err := manager.UpdateProfile("work client", Profile{Name: "work client", Email: "new@example.com"}); err != nil {
+		t.Errorf("UpdateProfile() error = %v", err)
+	}
+	if err := manager.UpdateProfile("work client", Profile{Name: "work/client", Email: "new@example.com"}); !errors.Is(err, ErrInvalidName) {
+		t.Errorf("UpdateProfile() to an invalid name error = %v, want ErrInvalidName", err)
 	}
+}
 
-	// Manager doesn't validate empty name, but it's a valid test case
-	if err := manager.AddProfile(profile); err != nil {
-		t.Logf("AddProfile() handled empty name: %v", err)
+func TestManager_RenameProfile(t *testing.T) {
+	t.Parallel()
+	store := &memoryStore{profiles: []Profile{
+		{Name: "work client", Email: "work@example.com"},
+		{Name: "personal", Email: "me@example.com"},
+	}}
+	manager, err := NewManager(store)
+	if err != nil {
+		t.Fatalf("NewManager() error = %v", err)
+	}
+
+	if err := manager.RenameProfile("work client", "work/client"); !errors.Is(err, ErrInvalidName) {
+		t.Errorf("RenameProfile() error = %v, want ErrInvalidName", err)
+	}
+	if err := manager.RenameProfile("work client", "personal"); !errors.Is(err, ErrProfileExists) {
+		t.Errorf("RenameProfile() error = %v, want ErrProfileExists", err)
+	}
+	if err := manager.RenameProfile("missing", "other"); !errors.Is(err, ErrProfileNotFound) {
+		t.Errorf("RenameProfile() error = %v, want ErrProfileNotFound", err)
+	}
+
+	if err := manager.RenameProfile("work client", "work-client"); err != nil {
+		t.Fatalf("RenameProfile() error = %v", err)
+	}
+	if _, err := manager.GetProfile("work client"); !errors.Is(err, ErrProfileNotFound) {
+		t.Errorf("GetProfile() of the old name error = %v, want ErrProfileNotFound", err)
+	}
+	p, err := manager.GetProfile("work-client")
+	if err != nil {
+		t.Fatalf("GetProfile() error = %v", err)
+	}
+	if p.Email != "work@example.com" {
+		t.Errorf("renamed profile email = %q, want it kept", p.Email)
+	}
+	if store.profiles[1].Name != "work-client" {
+		t.Errorf("stored profiles = %v, want the rename saved", store.profiles)
 	}
 }
 
 func TestNewManager_LoadError(t *testing.T) {
-	// This tests the error path in NewManager when LoadProfiles fails
-	_, cleanup := setupTestEnv(t)
-	defer cleanup()
+	t.Parallel()
+	_, err := NewManager(&memoryStore{loadErr: os.ErrPermission})
+	if !errors.Is(err, os.ErrPermission) {
+		t.Errorf("NewDefaultManager() error = %v, want the store's load error", err)
+	}
+}
 
-	profilesPath, err := GetProfilesPath()
+func TestManager_SavesToStore(t *testing.T) {
+	t.Parallel()
+	store := &memoryStore{profiles: []Profile{{Name: "existing", Email: "old@example.com"}}}
+	manager, err := NewManager(store)
 	if err != nil {
-		t.Fatalf("GetProfilesPath() error = %v", err)
+		t.Fatalf("NewManager() error = %v", err)
+	}
+	if len(manager.ListProfiles()) != 1 {
+		t.Fatalf("ListProfiles() = %v, want the stored profile", manager.ListProfiles())
 	}
 
-	// Create a file that causes read error (directory)
-	if err := os.Remove(profilesPath); err != nil && !os.IsNotExist(err) {
-		t.Fatalf("Failed to remove profiles file: %v", err)
+	if err := manager.AddProfile(Profile{Name: "new", Email: "new@example.com"}); err != nil {
+		t.Fatalf("AddProfile() error = %v", err)
 	}
-	if err := os.MkdirAll(profilesPath, 0755); err != nil {
-		t.Fatalf("Failed to create directory: %v", err)
+	if store.saves != 1 || len(store.profiles) != 2 {
+		t.Errorf("store after AddProfile = %d saves, %v", store.saves, store.profiles)
 	}
-	defer func() {
-		if err := os.RemoveAll(profilesPath); err != nil {
-			t.Logf("Failed to remove profiles path: %v", err)
-		}
-	}()
 
-	_, err = NewManager()
-	if err == nil {
-		t.Error("NewManager() should fail when profiles file is unreadable")
+	store.saveErr = os.ErrPermission
+	if err := manager.DeleteProfile("new", nil); !errors.Is(err, os.ErrPermission) {
+		t.Errorf("DeleteProfile() error = %v, want the store's save error", err)
+	}
+}
+
+// watchedStore is a memoryStore that reports a new state on every save, and
+// on every external change made through modify.
+type watchedStore struct {
+	memoryStore
+	state StoreState
+}
+
+func (s *watchedStore) Save(profiles []Profile) error {
+	if err := s.memoryStore.Save(profiles); err != nil {
+		return err
+	}
+	s.bump()
+	return nil
+}
+
+func (s *watchedStore) State() (StoreState, error) { return s.state, nil }
+
+// modify changes the profiles as another process would.
+func (s *watchedStore) modify(profiles []Profile) {
+	s.profiles = profiles
+	s.bump()
+}
+
+func (s *watchedStore) bump() {
+	s.state.ModTime = s.state.ModTime.Add(time.Second)
+	s.state.Hash[0]++
+}
+
+func TestManager_ChangedOnDisk(t *testing.T) {
+	t.Parallel()
+	store := &watchedStore{memoryStore: memoryStore{profiles: []Profile{{Name: "work", Email: "work@example.com"}}}}
+	manager, err := NewManager(store)
+	if err != nil {
+		t.Fatalf("NewManager() error = %v", err)
+	}
+	store.modify([]Profile{{Name: "work", Email: "work@example.com"}, {Name: "other", Email: "other@example.com"}})
+
+	err = manager.AddProfile(Profile{Name: "new", Email: "new@example.com"})
+	if !errors.Is(err, ErrProfilesChanged) {
+		t.Fatalf("AddProfile() error = %v, want ErrProfilesChanged", err)
+	}
+	if len(store.profiles) != 2 {
+		t.Errorf("store = %v, want the external change kept", store.profiles)
+	}
+	if len(manager.ListProfiles()) != 1 {
+		t.Errorf("ListProfiles() = %v, want the refused change left out", manager.ListProfiles())
+	}
+	if err := manager.UpdateProfile("work", Profile{Name: "work", Email: "new@example.com"}); !errors.Is(err, ErrProfilesChanged) {
+		t.Errorf("UpdateProfile() error = %v, want ErrProfilesChanged", err)
+	}
+	if err := manager.DeleteProfile("work", nil); !errors.Is(err, ErrProfilesChanged) {
+		t.Errorf("DeleteProfile() error = %v, want ErrProfilesChanged", err)
+	}
+
+	manager.Force = true
+	if err := manager.AddProfile(Profile{Name: "new", Email: "new@example.com"}); err != nil {
+		t.Fatalf("AddProfile() with Force error = %v", err)
+	}
+	if len(store.profiles) != 2 || store.profiles[0].Name != "new" {
+		t.Errorf("store = %v, want the external change overwritten", store.profiles)
+	}
+}
+
+func TestManager_SaveUpdatesState(t *testing.T) {
+	t.Parallel()
+	store := &watchedStore{}
+	manager, err := NewManager(store)
+	if err != nil {
+		t.Fatalf("NewManager() error = %v", err)
+	}
+	if err := manager.AddProfile(Profile{Name: "work", Email: "work@example.com"}); err != nil {
+		t.Fatalf("AddProfile() error = %v", err)
+	}
+	// The manager's own save is not a change on disk
+	if err := manager.AddProfile(Profile{Name: "personal", Email: "me@example.com"}); err != nil {
+		t.Errorf("second AddProfile() error = %v", err)
+	}
+}
+
+func TestManager_AutoReload(t *testing.T) {
+	t.Parallel()
+	store := &watchedStore{memoryStore: memoryStore{profiles: []Profile{{Name: "work", Email: "work@example.com"}}}}
+	manager, err := NewManager(store)
+	if err != nil {
+		t.Fatalf("NewManager() error = %v", err)
+	}
+	manager.AutoReload = true
+	store.modify([]Profile{{Name: "work", Email: "work@example.com"}, {Name: "other", Email: "other@example.com"}})
+
+	if err := manager.AddProfile(Profile{Name: "new", Email: "new@example.com"}); err != nil {
+		t.Fatalf("AddProfile() error = %v", err)
+	}
+	var names []string
+	for _, p := range store.profiles {
+		names = append(names, p.Name)
+	}
+	if strings.Join(names, ",") != "new,other,work" {
+		t.Errorf("store = %v, want the change applied on top of the external one", names)
+	}
+
+	store.modify([]Profile{{Name: "new", Email: "new@example.com"}})
+	if err := manager.AddProfile(Profile{Name: "new", Email: "new@example.com"}); !errors.Is(err, ErrProfileExists) {
+		t.Errorf("AddProfile() error = %v, want ErrProfileExists from the reloaded profiles", err)
+	}
+}
+
+func TestManager_Reload(t *testing.T) {
+	t.Parallel()
+	store := &watchedStore{}
+	manager, err := NewManager(store)
+	if err != nil {
+		t.Fatalf("NewManager() error = %v", err)
+	}
+	store.modify([]Profile{{Name: "work", Email: "work@example.com"}})
+	if _, err := manager.GetProfile("work"); !errors.Is(err, ErrProfileNotFound) {
+		t.Fatalf("GetProfile() before Reload error = %v, want ErrProfileNotFound", err)
+	}
+
+	if err := manager.Reload(); err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+	if _, err := manager.GetProfile("work"); err != nil {
+		t.Errorf("GetProfile() after Reload error = %v", err)
+	}
+	if err := manager.AddProfile(Profile{Name: "new", Email: "new@example.com"}); err != nil {
+		t.Errorf("AddProfile() after Reload error = %v", err)
+	}
+
+	store.loadErr = os.ErrPermission
+	if err := manager.Reload(); !errors.Is(err, os.ErrPermission) {
+		t.Errorf("Reload() error = %v, want the store's load error", err)
+	}
+}
+
+func TestManager_Concurrent(t *testing.T) {
+	t.Parallel()
+	store := &watchedStore{}
+	manager, err := NewManager(store)
+	if err != nil {
+		t.Fatalf("NewManager() error = %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for i := range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			name := string(rune('a' + i))
+			if err := manager.AddProfile(Profile{Name: name, Email: name + "@example.com"}); err != nil {
+				t.Errorf("AddProfile(%s) error = %v", name, err)
+			}
+			_ = manager.ListProfiles()
+		}()
+	}
+	wg.Wait()
+	if len(store.profiles) != 20 {
+		t.Errorf("store has %d profiles, want 20", len(store.profiles))
 	}
 }
 
 func TestManager_AddProfile_SSHKeyPathWithTilde(t *testing.T) {
-	tmpDir, cleanup := setupTestEnv(t)
-	defer cleanup()
+	tmpDir := useRoot(t)
 
-	manager, err := NewManager()
+	manager, err := NewManager(&memoryStore{})
 	if err != nil {
 		t.Fatalf("NewManager() error = %v", err)
 	}
@@ -441,11 +927,40 @@ func TestManager_AddProfile_SSHKeyPathWithTilde(t *testing.T) {
 	}
 }
 
-func TestManager_AddProfile_SSHKeyPathWithTilde_NonExistent(t *testing.T) {
-	_, cleanup := setupTestEnv(t)
-	defer cleanup()
+func TestManager_AddProfile_SSHKeyPathWithVariable(t *testing.T) {
+	tmpDir := useRoot(t)
+	t.Setenv("GIDTREE_TEST_KEY_DIR", "")
+	_ = os.Unsetenv("GIDTREE_TEST_KEY_DIR")
 
-	manager, err := NewManager()
+	manager, err := NewManager(&memoryStore{})
+	if err != nil {
+		t.Fatalf("NewManager() error = %v", err)
+	}
+	if err := os.MkdirAll(filepath.Join(tmpDir, ".ssh"), 0700); err != nil {
+		t.Fatalf("Failed to create .ssh directory: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, ".ssh", "id_work"), []byte("test key"), 0600); err != nil {
+		t.Fatalf("Failed to create test key file: %v", err)
+	}
+
+	prof := Profile{Name: "work", Email: "work@example.com", SSHKeyPath: "$HOME/.ssh/id_work"}
+	if err := manager.AddProfile(prof); err != nil {
+		t.Fatalf("AddProfile() with $HOME error = %v", err)
+	}
+	// The path is stored as written, so a shared template keeps working
+	if got, _ := manager.GetProfile("work"); got.SSHKeyPath != "$HOME/.ssh/id_work" {
+		t.Errorf("stored SSHKeyPath = %q, want it unexpanded", got.SSHKeyPath)
+	}
+
+	prof = Profile{Name: "other", Email: "other@example.com", SSHKeyPath: "$GIDTREE_TEST_KEY_DIR/id_work"}
+	if err := manager.AddProfile(prof); !errors.Is(err, utils.ErrUndefinedVariable) {
+		t.Errorf("AddProfile() with an undefined variable error = %v, want ErrUndefinedVariable", err)
+	}
+}
+
+func TestManager_AddProfile_SSHKeyPathWithTilde_NonExistent(t *testing.T) {
+	t.Parallel()
+	manager, err := NewManager(&memoryStore{})
 	if err != nil {
 		t.Fatalf("NewManager() error = %v", err)
 	}
@@ -464,10 +979,9 @@ func TestManager_AddProfile_SSHKeyPathWithTilde_NonExistent(t *testing.T) {
 }
 
 func TestManager_UpdateProfile_SSHKeyPathWithTilde(t *testing.T) {
-	tmpDir, cleanup := setupTestEnv(t)
-	defer cleanup()
+	tmpDir := useRoot(t)
 
-	manager, err := NewManager()
+	manager, err := NewManager(&memoryStore{})
 	if err != nil {
 		t.Fatalf("NewManager() error = %v", err)
 	}
@@ -514,3 +1028,53 @@ func TestManager_UpdateProfile_SSHKeyPathWithTilde(t *testing.T) {
 		t.Errorf("Profile SSHKeyPath = %v, want ~/.ssh/id_rsa_updated", got.SSHKeyPath)
 	}
 }
+
+func TestManager_RecordUse(t *testing.T) {
+	t.Parallel()
+	store := &watchedStore{memoryStore: memoryStore{profiles: []Profile{
+		{Name: "work", Email: "work@example.com"},
+		{Name: "personal", Email: "me@example.com"},
+	}}}
+	manager, err := NewManager(store)
+	if err != nil {
+		t.Fatalf("NewManager() error = %v", err)
+	}
+	morning := time.Date(2025, 3, 4, 9, 0, 0, 0, time.UTC)
+
+	if err := manager.RecordUse(morning, "work", "deleted"); err != nil {
+		t.Fatalf("RecordUse() error = %v", err)
+	}
+	if store.saves != 1 {
+		t.Fatalf("RecordUse() saved %d times, want 1", store.saves)
+	}
+	work, _ := manager.GetProfile("work")
+	if !work.LastUsed.Equal(morning) {
+		t.Errorf("LastUsed = %v, want %v", work.LastUsed, morning)
+	}
+
+	// Another use the same day writes nothing; both profiles in one write otherwise
+	if err := manager.RecordUse(morning.Add(8*time.Hour), "work"); err != nil || store.saves != 1 {
+		t.Errorf("RecordUse() the same day = %v with %d saves, want no write", err, store.saves)
+	}
+	nextDay := morning.Add(24 * time.Hour)
+	if err := manager.RecordUse(nextDay, "work", "personal"); err != nil || store.saves != 2 {
+		t.Errorf("RecordUse() the next day = %v with %d saves, want one write", err, store.saves)
+	}
+
+	// A change by another process is reloaded rather than overwritten
+	store.modify(append(append([]Profile(nil), store.profiles...), Profile{Name: "other", Email: "other@example.com"}))
+	if err := manager.RecordUse(nextDay.Add(24*time.Hour), "other"); err != nil {
+		t.Fatalf("RecordUse() after an external change error = %v", err)
+	}
+	if other, err := manager.GetProfile("other"); err != nil || len(store.profiles) != 3 || other.LastUsed.IsZero() {
+		t.Errorf("store = %+v, want the external profile kept and marked used", store.profiles)
+	}
+
+	// Updates keep the last use
+	if err := manager.UpdateProfile("work", Profile{Name: "work", Email: "new@example.com"}); err != nil {
+		t.Fatalf("UpdateProfile() error = %v", err)
+	}
+	if work, _ := manager.GetProfile("work"); !work.LastUsed.Equal(nextDay) {
+		t.Errorf("LastUsed after UpdateProfile() = %v, want %v", work.LastUsed, nextDay)
+	}
+}
@@ -1,5 +1,18 @@
 package profile
 
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/thuanlegit/git-identitree/internal/utils"
+)
+
 // Profile represents a Git identity profile.
 type Profile struct {
 	Name       string `yaml:"name"`
@@ -7,6 +20,137 @@ type Profile struct {
 	AuthorName string `yaml:"author_name,omitempty"`
 	SSHKeyPath string `yaml:"ssh_key_path,omitempty"`
 	GPGKeyID   string `yaml:"gpg_key_id,omitempty"`
+	// EmailAliases are other addresses the user has committed with, such as
+	// one on an old domain. Audits accept them; git is only given Email.
+	EmailAliases []string `yaml:"email_aliases,omitempty"`
+	// CommitterName and CommitterEmail set a committer other than the author,
+	// as for patch stacks or pairing. Git config has no committer setting, so
+	// they only apply where Env is used: exec, with and env.
+	CommitterName  string `yaml:"committer_name,omitempty"`
+	CommitterEmail string `yaml:"committer_email,omitempty"`
+	// CoAuthors are "Name <email>" entries added as Co-authored-by trailers
+	// by the prepare-commit-msg hook, for pairing.
+	CoAuthors []string `yaml:"co_authors,omitempty"`
+	// RemotePatterns are globs such as "github.com/acme" or "*.corp.com" matched
+	// against a repository's origin URL to suggest this profile for it.
+	RemotePatterns []string `yaml:"remote_patterns,omitempty"`
+	// IsolateSSHConfig makes ssh ignore ~/.ssh/config when using the profile's key.
+	IsolateSSHConfig bool `yaml:"isolate_ssh_config,omitempty"`
+	// SSHSigning signs commits with the SSH key instead of a GPG key, which
+	// needs git 2.34 or later. Signatures are verified against the profile's
+	// allowed signers file.
+	SSHSigning bool `yaml:"ssh_signing,omitempty"`
+	// CreatedAt is when the profile was added, for listing with --sort
+	// created. Profiles from older versions have none.
+	CreatedAt time.Time `yaml:"created_at,omitempty"`
+	// LastUsed is when the profile was last activated or resolved, or had
+	// its SSH key loaded. It is recorded at most once a day; profiles not
+	// used since it was introduced have none.
+	LastUsed time.Time `yaml:"last_used,omitempty"`
+	// SSHKeyFingerprint is the SHA256 fingerprint of the SSH key when it was
+	// added or changed, to notice a key file rotated in place. It is empty
+	// when the key could not be read then.
+	SSHKeyFingerprint string `yaml:"ssh_key_fingerprint,omitempty"`
+}
+
+// EnvVar is a single environment variable derived from a profile.
+type EnvVar struct {
+	Name  string
+	Value string
+}
+
+// String formats the variable as NAME=value for use in a process environment.
+func (v EnvVar) String() string {
+	return v.Name + "=" + v.Value
+}
+
+// Clone returns an independent copy of the profile. Manager hands out copies
+// made with it, so reference fields added to Profile must be deep-copied here.
+func (p *Profile) Clone() Profile {
+	c := *p
+	if p.RemotePatterns != nil {
+		c.RemotePatterns = append([]string(nil), p.RemotePatterns...)
+	}
+	if p.EmailAliases != nil {
+		c.EmailAliases = append([]string(nil), p.EmailAliases...)
+	}
+	if p.CoAuthors != nil {
+		c.CoAuthors = append([]string(nil), p.CoAuthors...)
+	}
+	return c
+}
+
+// CloneAs returns a copy of the profile to create as a new profile called
+// name. The new profile has not been created or used yet, so its creation and
+// last-use times start empty, and it records the fingerprint of its own key
+// when added.
+func (p *Profile) CloneAs(name string) Profile {
+	c := p.Clone()
+	c.Name = name
+	c.CreatedAt = time.Time{}
+	c.LastUsed = time.Time{}
+	c.SSHKeyFingerprint = ""
+	return c
+}
+
+// UsedOn reports whether the profile was last used on the day of t, in t's
+// time zone, so another use that day need not be recorded.
+func (p *Profile) UsedOn(t time.Time) bool {
+	if p.LastUsed.IsZero() {
+		return false
+	}
+	y1, m1, d1 := p.LastUsed.In(t.Location()).Date()
+	y2, m2, d2 := t.Date()
+	return y1 == y2 && m1 == m2 && d1 == d2
+}
+
+// UnusedSince reports whether the profile has not been used since cutoff.
+// A profile never used counts from its creation, so one added after cutoff
+// is not reported.
+func (p *Profile) UnusedSince(cutoff time.Time) bool {
+	last := p.LastUsed
+	if last.IsZero() {
+		last = p.CreatedAt
+	}
+	return last.Before(cutoff)
+}
+
+// LastUsedLabel returns the local date of the last use as YYYY-MM-DD, or
+// "never".
+func (p *Profile) LastUsedLabel() string {
+	if p.LastUsed.IsZero() {
+		return "never"
+	}
+	return p.LastUsed.Local().Format(time.DateOnly)
+}
+
+// Emails returns the primary email followed by the aliases.
+func (p *Profile) Emails() []string {
+	return append([]string{p.Email}, p.EmailAliases...)
+}
+
+// HasEmail reports whether email is the profile's email or one of its
+// aliases, ignoring case as mail servers do.
+func (p *Profile) HasEmail(email string) bool {
+	for _, e := range p.Emails() {
+		if e != "" && strings.EqualFold(e, email) {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateRemotePatterns checks that every remote pattern is a valid glob.
+func ValidateRemotePatterns(patterns []string) error {
+	for _, pattern := range patterns {
+		if strings.TrimSpace(pattern) == "" {
+			return fmt.Errorf("remote pattern cannot be empty")
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid remote pattern %q: %w", pattern, err)
+		}
+	}
+	return nil
 }
 
 // GetAuthorName returns the author name, falling back to the profile name if not set.
@@ -17,3 +161,253 @@ func (p *Profile) GetAuthorName() string {
 	return p.Name
 }
 
+// GetCommitterName returns the committer name, falling back to the author name.
+func (p *Profile) GetCommitterName() string {
+	if p.CommitterName != "" {
+		return p.CommitterName
+	}
+	return p.GetAuthorName()
+}
+
+// GetCommitterEmail returns the committer email, falling back to the email.
+func (p *Profile) GetCommitterEmail() string {
+	if p.CommitterEmail != "" {
+		return p.CommitterEmail
+	}
+	return p.Email
+}
+
+// HasSeparateCommitter reports whether the committer differs from the author.
+func (p *Profile) HasSeparateCommitter() bool {
+	return p.GetCommitterName() != p.GetAuthorName() || p.GetCommitterEmail() != p.Email
+}
+
+// MaxNameLength is the longest profile name accepted.
+const MaxNameLength = 64
+
+// ValidateName checks that name can be used in ~/.gitconfig-<name> and the
+// comment above each includeIf block: letters, digits, dashes, underscores and
+// dots, at most MaxNameLength of them, and not "." or "..".
+func ValidateName(name string) error {
+	if name == "" {
+		return utils.WithDetail(ErrInvalidName, "profile name is required")
+	}
+	if len(name) > MaxNameLength {
+		return utils.WithDetail(ErrInvalidName, "invalid profile name %q: longer than %d characters", name, MaxNameLength)
+	}
+	if name == "." || name == ".." {
+		return utils.WithDetail(ErrInvalidName, "invalid profile name %q", name)
+	}
+	for _, r := range name {
+		if !isNameRune(r) {
+			return utils.WithDetail(ErrInvalidName, "invalid profile name %q: use only letters, digits, '-', '_' and '.'", name)
+		}
+	}
+	return nil
+}
+
+// isNameRune reports whether r may appear in a profile name.
+func isNameRune(r rune) bool {
+	return r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' || r == '.'
+}
+
+// ValidateEmail checks that email looks like an address: a local part and a
+// domain around a single @, without spaces or angle brackets, which would
+// break the "Name <email>" form git writes into commits.
+func ValidateEmail(email string) error {
+	if strings.ContainsAny(email, " \t\n<>") {
+		return fmt.Errorf("invalid email %q: must not contain spaces or angle brackets", email)
+	}
+	local, domain, ok := strings.Cut(email, "@")
+	if !ok || local == "" || domain == "" || strings.Contains(domain, "@") {
+		return fmt.Errorf("invalid email %q: want name@domain", email)
+	}
+	return nil
+}
+
+// ParseCoAuthor splits a "Name <email>" entry, checking both parts.
+func ParseCoAuthor(entry string) (name, email string, err error) {
+	entry = strings.TrimSpace(entry)
+	open := strings.LastIndex(entry, "<")
+	if open < 0 || !strings.HasSuffix(entry, ">") {
+		return "", "", fmt.Errorf("invalid co-author %q: want Name <email>", entry)
+	}
+	name = strings.TrimSpace(entry[:open])
+	email = entry[open+1 : len(entry)-1]
+	if name == "" {
+		return "", "", fmt.Errorf("invalid co-author %q: name is empty", entry)
+	}
+	if err := ValidateEmail(email); err != nil {
+		return "", "", fmt.Errorf("invalid co-author %q: %w", entry, err)
+	}
+	return name, email, nil
+}
+
+// FormatCoAuthor returns a co-author entry in the form the trailers use.
+func FormatCoAuthor(name, email string) string {
+	return name + " <" + email + ">"
+}
+
+// ToggleCoAuthor adds entry to the co-authors, or removes the co-author with
+// the same email, ignoring case. It reports whether entry was added.
+func (p *Profile) ToggleCoAuthor(entry string) (bool, error) {
+	name, email, err := ParseCoAuthor(entry)
+	if err != nil {
+		return false, err
+	}
+	for i, existing := range p.CoAuthors {
+		if _, e, err := ParseCoAuthor(existing); err == nil && strings.EqualFold(e, email) {
+			p.CoAuthors = append(p.CoAuthors[:i:i], p.CoAuthors[i+1:]...)
+			return false, nil
+		}
+	}
+	p.CoAuthors = append(p.CoAuthors, FormatCoAuthor(name, email))
+	return true, nil
+}
+
+// ValidateCoAuthors checks that every co-author is a "Name <email>" entry.
+func ValidateCoAuthors(coAuthors []string) error {
+	for _, entry := range coAuthors {
+		if _, _, err := ParseCoAuthor(entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SSHPublicKeyPath returns the expanded path of the public half of the
+// profile's SSH key, or "" without a key.
+func (p *Profile) SSHPublicKeyPath() string {
+	if p.SSHKeyPath == "" {
+		return ""
+	}
+	keyPath := p.SSHKeyPath
+	if expanded, err := utils.ExpandPath(keyPath); err == nil {
+		keyPath = expanded
+	}
+	return keyPath + ".pub"
+}
+
+// ValidateSSHKeyPath checks that the SSH key file at path exists and is not
+// a PuTTY key. The path may start with ~ or hold environment variables; an
+// empty path is valid.
+func ValidateSSHKeyPath(path string) error {
+	if path == "" {
+		return nil
+	}
+	expanded, err := utils.ExpandPath(path)
+	if err != nil {
+		return fmt.Errorf("failed to expand SSH key path: %w", err)
+	}
+	if _, err := os.Stat(expanded); os.IsNotExist(err) {
+		return utils.WithDetail(ErrSSHKeyMissing, "SSH key file %s does not exist", path)
+	}
+	if isPuTTYKey(expanded) {
+		return PuTTYKeyError(path)
+	}
+	return nil
+}
+
+// isPuTTYKey reports whether the file at path starts like a key saved by
+// PuTTY, allowing for the byte order mark editors on Windows may add.
+func isPuTTYKey(path string) bool {
+	file, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer func() { _ = file.Close() }()
+	head := make([]byte, 64)
+	n, _ := io.ReadFull(file, head)
+	return bytes.HasPrefix(bytes.TrimPrefix(head[:n], []byte("\xef\xbb\xbf")), []byte("PuTTY-User-Key-File-"))
+}
+
+// PuTTYKeyError returns ErrPuTTYKey for the PuTTY key at path, explaining
+// how to convert it with puttygen.
+func PuTTYKeyError(path string) error {
+	return utils.WithDetail(ErrPuTTYKey, "SSH key file %s is a PuTTY key, which OpenSSH cannot read; convert it with: puttygen %s -O private-openssh -o %s", path, path, ConvertedKeyPath(path))
+}
+
+// ConvertedKeyPath returns where the OpenSSH copy of the PuTTY key at path
+// goes: the same path without .ppk, or with .openssh added when it has no
+// .ppk extension.
+func ConvertedKeyPath(path string) string {
+	if ext := filepath.Ext(path); strings.EqualFold(ext, ".ppk") {
+		return strings.TrimSuffix(path, ext)
+	}
+	return path + ".openssh"
+}
+
+// validateSigning checks that a profile signing with SSH has a key with a
+// public half, and no GPG key competing for user.signingkey.
+func validateSigning(p Profile) error {
+	if !p.SSHSigning {
+		return nil
+	}
+	if p.SSHKeyPath == "" {
+		return fmt.Errorf("ssh_signing needs an SSH key path")
+	}
+	if p.GPGKeyID != "" {
+		return fmt.Errorf("ssh_signing and a GPG key ID cannot be combined; choose one way of signing")
+	}
+	if _, err := os.Stat(p.SSHPublicKeyPath()); err != nil {
+		return utils.WithDetail(ErrSSHKeyMissing, "SSH public key does not exist: %s", p.SSHPublicKeyPath())
+	}
+	return nil
+}
+
+// validateCommitter checks the committer email, when one is set.
+func validateCommitter(p Profile) error {
+	if p.CommitterEmail == "" {
+		return nil
+	}
+	if err := ValidateEmail(p.CommitterEmail); err != nil {
+		return fmt.Errorf("committer email: %w", err)
+	}
+	return nil
+}
+
+// SSHCommand returns the ssh invocation that forces the profile's key.
+// It is used both for core.sshCommand and GIT_SSH_COMMAND, which git runs
+// through a shell, so the key path is expanded and quoted. IdentitiesOnly
+// stops the agent from offering other keys first and authenticating as the
+// wrong account. ~/.ssh/config is skipped only with IsolateSSHConfig.
+func (p *Profile) SSHCommand() string {
+	if p.SSHKeyPath == "" {
+		return ""
+	}
+	keyPath := p.SSHKeyPath
+	if expanded, err := utils.ExpandPath(keyPath); err == nil {
+		keyPath = expanded
+	}
+	command := "ssh -i " + utils.ShellQuote(keyPath) + " -o IdentitiesOnly=yes"
+	if p.IsolateSSHConfig {
+		command += " -F /dev/null"
+	}
+	return command
+}
+
+// Env returns the git identity environment variables for the profile.
+func (p *Profile) Env() []EnvVar {
+	env := []EnvVar{
+		{Name: "GIT_AUTHOR_NAME", Value: p.GetAuthorName()},
+		{Name: "GIT_AUTHOR_EMAIL", Value: p.Email},
+		{Name: "GIT_COMMITTER_NAME", Value: p.GetCommitterName()},
+		{Name: "GIT_COMMITTER_EMAIL", Value: p.GetCommitterEmail()},
+	}
+	if p.SSHKeyPath != "" {
+		env = append(env, EnvVar{Name: "GIT_SSH_COMMAND", Value: p.SSHCommand()})
+	}
+	return env
+}
+
+// EnvNames returns the name of every variable Env can produce, so callers can
+// clear a previously exported identity regardless of which profile set it.
+func EnvNames() []string {
+	return []string{
+		"GIT_AUTHOR_NAME",
+		"GIT_AUTHOR_EMAIL",
+		"GIT_COMMITTER_NAME",
+		"GIT_COMMITTER_EMAIL",
+		"GIT_SSH_COMMAND",
+	}
+}
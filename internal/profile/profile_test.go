@@ -0,0 +1,404 @@
+package profile
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProfile_GetAuthorName(t *testing.T) {
+	t.Parallel()
+	p := &Profile{Name: "work"}
+	if got := p.GetAuthorName(); got != "work" {
+		t.Errorf("GetAuthorName() = %q, want fallback to name", got)
+	}
+	p.AuthorName = "Jane Doe"
+	if got := p.GetAuthorName(); got != "Jane Doe" {
+		t.Errorf("GetAuthorName() = %q, want Jane Doe", got)
+	}
+}
+
+func TestProfile_Committer(t *testing.T) {
+	t.Parallel()
+	p := &Profile{Name: "work", Email: "work@example.com", AuthorName: "Jane"}
+	if p.GetCommitterName() != "Jane" || p.GetCommitterEmail() != "work@example.com" || p.HasSeparateCommitter() {
+		t.Errorf("committer = %s <%s>, want the author", p.GetCommitterName(), p.GetCommitterEmail())
+	}
+
+	p.CommitterEmail = "bot@example.com"
+	if p.GetCommitterName() != "Jane" || p.GetCommitterEmail() != "bot@example.com" || !p.HasSeparateCommitter() {
+		t.Errorf("committer = %s <%s>, want Jane <bot@example.com>", p.GetCommitterName(), p.GetCommitterEmail())
+	}
+
+	p.CommitterEmail = ""
+	p.CommitterName = "Release Bot"
+	if p.GetCommitterName() != "Release Bot" || p.GetCommitterEmail() != "work@example.com" || !p.HasSeparateCommitter() {
+		t.Errorf("committer = %s <%s>, want Release Bot <work@example.com>", p.GetCommitterName(), p.GetCommitterEmail())
+	}
+}
+
+func TestValidateName(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name    string
+		wantErr bool
+	}{
+		{name: "work"},
+		{name: "Work-2024"},
+		{name: "acme_corp.eu"},
+		{name: ".hidden"},
+		{name: strings.Repeat("a", MaxNameLength)},
+		{name: "", wantErr: true},
+		{name: "work/client", wantErr: true},
+		{name: `work\client`, wantErr: true},
+		{name: "work client", wantErr: true},
+		{name: "work\tclient", wantErr: true},
+		{name: `"work"`, wantErr: true},
+		{name: "work#1", wantErr: true},
+		{name: "travail-é", wantErr: true},
+		{name: ".", wantErr: true},
+		{name: "..", wantErr: true},
+		{name: strings.Repeat("a", MaxNameLength+1), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			err := ValidateName(tt.name)
+			if tt.wantErr && !errors.Is(err, ErrInvalidName) {
+				t.Errorf("ValidateName(%q) error = %v, want ErrInvalidName", tt.name, err)
+			}
+			if !tt.wantErr && err != nil {
+				t.Errorf("ValidateName(%q) error = %v", tt.name, err)
+			}
+		})
+	}
+}
+
+func TestValidateEmail(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		email   string
+		wantErr bool
+	}{
+		{"jane@example.com", false},
+		{"jane+git@sub.example.com", false},
+		{"12345+jane@users.noreply.github.com", false},
+		{"", true},
+		{"jane", true},
+		{"@example.com", true},
+		{"jane@", true},
+		{"jane@a@b", true},
+		{"Jane <jane@example.com>", true},
+		{"jane @example.com", true},
+	}
+	for _, tt := range tests {
+		if err := ValidateEmail(tt.email); (err != nil) != tt.wantErr {
+			t.Errorf("ValidateEmail(%q) error = %v, wantErr %v", tt.email, err, tt.wantErr)
+		}
+	}
+}
+
+func TestValidateSSHKeyPath(t *testing.T) {
+	home := useRoot(t)
+	if err := os.MkdirAll(filepath.Join(home, ".ssh"), 0700); err != nil {
+		t.Fatalf("MkdirAll() error = %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(home, ".ssh", "id_work"), []byte("key"), 0600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	if err := ValidateSSHKeyPath(""); err != nil {
+		t.Errorf("ValidateSSHKeyPath(\"\") error = %v", err)
+	}
+	if err := ValidateSSHKeyPath("~/.ssh/id_work"); err != nil {
+		t.Errorf("ValidateSSHKeyPath() of an existing key error = %v", err)
+	}
+	err := ValidateSSHKeyPath("~/.ssh/id_rsa_work")
+	if !errors.Is(err, ErrSSHKeyMissing) || err.Error() != "SSH key file ~/.ssh/id_rsa_work does not exist" {
+		t.Errorf("ValidateSSHKeyPath() of a missing key error = %v", err)
+	}
+}
+
+func TestValidateSSHKeyPath_PuTTYKey(t *testing.T) {
+	home := useRoot(t)
+	if err := os.MkdirAll(filepath.Join(home, ".ssh"), 0700); err != nil {
+		t.Fatalf("MkdirAll() error = %v", err)
+	}
+	ppk := "\xef\xbb\xbfPuTTY-User-Key-File-3: ssh-ed25519\r\nEncryption: none\r\n"
+	if err := os.WriteFile(filepath.Join(home, ".ssh", "work.ppk"), []byte(ppk), 0600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	err := ValidateSSHKeyPath("~/.ssh/work.ppk")
+	if !errors.Is(err, ErrPuTTYKey) || !strings.Contains(err.Error(), "puttygen ~/.ssh/work.ppk -O private-openssh -o ~/.ssh/work") {
+		t.Errorf("ValidateSSHKeyPath() of a PuTTY key error = %v, want ErrPuTTYKey with the conversion", err)
+	}
+}
+
+func TestConvertedKeyPath(t *testing.T) {
+	t.Parallel()
+	tests := map[string]string{
+		"~/.ssh/work.ppk":  "~/.ssh/work",
+		`C:\keys\WORK.PPK`: `C:\keys\WORK`,
+		"~/.ssh/work":      "~/.ssh/work.openssh",
+	}
+	for path, want := range tests {
+		if got := ConvertedKeyPath(path); got != want {
+			t.Errorf("ConvertedKeyPath(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestParseCoAuthor(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		entry     string
+		wantName  string
+		wantEmail string
+		wantErr   bool
+	}{
+		{"Ada Lovelace <ada@example.com>", "Ada Lovelace", "ada@example.com", false},
+		{"  Ada   <ada@example.com>  ", "Ada", "ada@example.com", false},
+		{"Ada<ada@example.com>", "Ada", "ada@example.com", false},
+		{"ada@example.com", "", "", true},
+		{"<ada@example.com>", "", "", true},
+		{"Ada <ada>", "", "", true},
+		{"Ada <ada@example.com", "", "", true},
+	}
+	for _, tt := range tests {
+		name, email, err := ParseCoAuthor(tt.entry)
+		if (err != nil) != tt.wantErr || name != tt.wantName || email != tt.wantEmail {
+			t.Errorf("ParseCoAuthor(%q) = %q, %q, %v; want %q, %q, error %v", tt.entry, name, email, err, tt.wantName, tt.wantEmail, tt.wantErr)
+		}
+	}
+}
+
+func TestProfile_ToggleCoAuthor(t *testing.T) {
+	t.Parallel()
+	p := &Profile{Name: "work", CoAuthors: []string{"Ada Lovelace <ada@example.com>"}}
+	shared := p.CoAuthors
+
+	added, err := p.ToggleCoAuthor("Alan Turing  <alan@example.com>")
+	if err != nil || !added {
+		t.Fatalf("ToggleCoAuthor() = %v, %v; want added", added, err)
+	}
+	added, err = p.ToggleCoAuthor("Ada <ADA@example.com>")
+	if err != nil || added {
+		t.Fatalf("ToggleCoAuthor() = %v, %v; want removed by email", added, err)
+	}
+	if !reflect.DeepEqual(p.CoAuthors, []string{"Alan Turing <alan@example.com>"}) {
+		t.Errorf("CoAuthors = %v, want only Alan, normalized", p.CoAuthors)
+	}
+	if shared[0] != "Ada Lovelace <ada@example.com>" {
+		t.Error("ToggleCoAuthor() changed a slice it shares with a copy")
+	}
+	if _, err := p.ToggleCoAuthor("alan@example.com"); err == nil {
+		t.Error("ToggleCoAuthor() should reject an entry without a name")
+	}
+}
+
+func TestProfile_Env(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name    string
+		profile Profile
+		want    []EnvVar
+	}{
+		{
+			name:    "email only",
+			profile: Profile{Name: "work", Email: "work@example.com"},
+			want: []EnvVar{
+				{Name: "GIT_AUTHOR_NAME", Value: "work"},
+				{Name: "GIT_AUTHOR_EMAIL", Value: "work@example.com"},
+				{Name: "GIT_COMMITTER_NAME", Value: "work"},
+				{Name: "GIT_COMMITTER_EMAIL", Value: "work@example.com"},
+			},
+		},
+		{
+			name:    "author name and ssh key",
+			profile: Profile{Name: "work", Email: "work@example.com", AuthorName: "Jane Doe", SSHKeyPath: "/keys/id_work"},
+			want: []EnvVar{
+				{Name: "GIT_AUTHOR_NAME", Value: "Jane Doe"},
+				{Name: "GIT_AUTHOR_EMAIL", Value: "work@example.com"},
+				{Name: "GIT_COMMITTER_NAME", Value: "Jane Doe"},
+				{Name: "GIT_COMMITTER_EMAIL", Value: "work@example.com"},
+				{Name: "GIT_SSH_COMMAND", Value: "ssh -i '/keys/id_work' -o IdentitiesOnly=yes"},
+			},
+		},
+		{
+			name:    "separate committer",
+			profile: Profile{Name: "work", Email: "work@example.com", AuthorName: "Jane Doe", CommitterName: "Release Bot", CommitterEmail: "bot@example.com"},
+			want: []EnvVar{
+				{Name: "GIT_AUTHOR_NAME", Value: "Jane Doe"},
+				{Name: "GIT_AUTHOR_EMAIL", Value: "work@example.com"},
+				{Name: "GIT_COMMITTER_NAME", Value: "Release Bot"},
+				{Name: "GIT_COMMITTER_EMAIL", Value: "bot@example.com"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if got := tt.profile.Env(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Env() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProfile_SSHCommand(t *testing.T) {
+	home := useRoot(t)
+
+	tests := []struct {
+		name    string
+		profile Profile
+		want    string
+	}{
+		{"no key", Profile{}, ""},
+		{"absolute path", Profile{SSHKeyPath: "/keys/id_work"}, "ssh -i '/keys/id_work' -o IdentitiesOnly=yes"},
+		{"spaces", Profile{SSHKeyPath: "/Users/Jane Doe/.ssh/id work"}, "ssh -i '/Users/Jane Doe/.ssh/id work' -o IdentitiesOnly=yes"},
+		{"quote", Profile{SSHKeyPath: "/keys/jane's key"}, `ssh -i '/keys/jane'\''s key' -o IdentitiesOnly=yes`},
+		{"home", Profile{SSHKeyPath: "~/.ssh/id work"}, "ssh -i '" + filepath.Join(home, ".ssh", "id work") + "' -o IdentitiesOnly=yes"},
+		{"variable", Profile{SSHKeyPath: "$HOME/.ssh/id_work"}, "ssh -i '" + filepath.Join(home, ".ssh", "id_work") + "' -o IdentitiesOnly=yes"},
+		{"isolated", Profile{SSHKeyPath: "/keys/id_work", IsolateSSHConfig: true}, "ssh -i '/keys/id_work' -o IdentitiesOnly=yes -F /dev/null"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.profile.SSHCommand(); got != tt.want {
+				t.Errorf("SSHCommand() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEnvVar_String(t *testing.T) {
+	t.Parallel()
+	v := EnvVar{Name: "GIT_AUTHOR_NAME", Value: "Jane Doe"}
+	if got := v.String(); got != "GIT_AUTHOR_NAME=Jane Doe" {
+		t.Errorf("String() = %q", got)
+	}
+}
+
+func TestEnvNames_CoverEnv(t *testing.T) {
+	t.Parallel()
+	names := make(map[string]bool)
+	for _, name := range EnvNames() {
+		names[name] = true
+	}
+
+	p := &Profile{Name: "work", Email: "work@example.com", SSHKeyPath: "~/.ssh/id_work"}
+	for _, v := range p.Env() {
+		if !names[v.Name] {
+			t.Errorf("EnvNames() is missing %s", v.Name)
+		}
+	}
+}
+
+func TestProfile_Clone(t *testing.T) {
+	t.Parallel()
+	source := &Profile{Name: "work", Email: "work@example.com", AuthorName: "Jane", SSHKeyPath: "~/.ssh/id_work", RemotePatterns: []string{"github.com/acme"}, EmailAliases: []string{"jane@old.example.com"}, CoAuthors: []string{"Ada Lovelace <ada@example.com>"}}
+
+	clone := source.Clone()
+	if !reflect.DeepEqual(clone, *source) {
+		t.Fatalf("Clone() = %+v, want %+v", clone, *source)
+	}
+
+	clone.Name = "work2"
+	clone.Email = "other@example.com"
+	clone.RemotePatterns[0] = "gitlab.com/*"
+	clone.EmailAliases[0] = "other@old.example.com"
+	clone.CoAuthors[0] = "Alan Turing <alan@example.com>"
+	if source.Name != "work" || source.Email != "work@example.com" || source.RemotePatterns[0] != "github.com/acme" || source.EmailAliases[0] != "jane@old.example.com" || source.CoAuthors[0] != "Ada Lovelace <ada@example.com>" {
+		t.Errorf("changing the clone changed the source: %+v", *source)
+	}
+}
+
+func TestProfile_CloneAs(t *testing.T) {
+	t.Parallel()
+	used := time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC)
+	source := &Profile{Name: "work", Email: "work@example.com", CreatedAt: used.AddDate(-1, 0, 0), LastUsed: used, SSHKeyFingerprint: "SHA256:abc", CoAuthors: []string{"Ada Lovelace <ada@example.com>"}}
+
+	clone := source.CloneAs("client")
+	want := Profile{Name: "client", Email: "work@example.com", CoAuthors: []string{"Ada Lovelace <ada@example.com>"}}
+	if !reflect.DeepEqual(clone, want) {
+		t.Errorf("CloneAs() = %+v, want %+v", clone, want)
+	}
+	clone.CoAuthors[0] = "Alan Turing <alan@example.com>"
+	if source.CoAuthors[0] != "Ada Lovelace <ada@example.com>" {
+		t.Errorf("changing the clone changed the source: %+v", *source)
+	}
+}
+
+func TestProfile_HasEmail(t *testing.T) {
+	t.Parallel()
+	p := &Profile{Name: "work", Email: "jane@acme.com", EmailAliases: []string{"jane@acme-old.com"}}
+	tests := []struct {
+		email string
+		want  bool
+	}{
+		{"jane@acme.com", true},
+		{"Jane@ACME.com", true},
+		{"jane@acme-old.com", true},
+		{"JANE@acme-old.com", true},
+		{"jane@example.com", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := p.HasEmail(tt.email); got != tt.want {
+			t.Errorf("HasEmail(%q) = %v, want %v", tt.email, got, tt.want)
+		}
+	}
+}
+
+func TestProfile_UsedOn(t *testing.T) {
+	t.Parallel()
+	zone := time.FixedZone("UTC+10", 10*60*60)
+	p := Profile{Name: "work", LastUsed: time.Date(2025, 3, 4, 20, 0, 0, 0, time.UTC)}
+
+	tests := []struct {
+		at   time.Time
+		want bool
+	}{
+		{time.Date(2025, 3, 4, 23, 59, 0, 0, time.UTC), true},
+		{time.Date(2025, 3, 5, 0, 1, 0, 0, time.UTC), false},
+		// 20:00 UTC is already the next day ten hours east
+		{time.Date(2025, 3, 5, 9, 0, 0, 0, zone), true},
+		{time.Date(2025, 3, 4, 12, 0, 0, 0, zone), false},
+	}
+	for _, tt := range tests {
+		if got := p.UsedOn(tt.at); got != tt.want {
+			t.Errorf("UsedOn(%v) = %v, want %v", tt.at, got, tt.want)
+		}
+	}
+	if (&Profile{}).UsedOn(time.Now()) {
+		t.Error("UsedOn() = true for a profile never used")
+	}
+}
+
+func TestProfile_UnusedSince(t *testing.T) {
+	t.Parallel()
+	cutoff := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	before, after := cutoff.Add(-time.Hour), cutoff.Add(time.Hour)
+
+	tests := []struct {
+		name string
+		p    Profile
+		want bool
+	}{
+		{"used before", Profile{LastUsed: before}, true},
+		{"used after", Profile{LastUsed: after, CreatedAt: before}, false},
+		{"never used, old", Profile{CreatedAt: before}, true},
+		{"never used, new", Profile{CreatedAt: after}, false},
+		{"no dates", Profile{}, true},
+	}
+	for _, tt := range tests {
+		if got := tt.p.UnusedSince(cutoff); got != tt.want {
+			t.Errorf("%s: UnusedSince() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package profile
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/thuanlegit/git-identitree/internal/utils"
+)
+
+// Query selects profiles by the values of their fields. A profile matches
+// when every non-empty field matches; an empty query matches nothing.
+type Query struct {
+	Email  string
+	SSHKey string
+	GPGKey string
+	// Contains matches a substring of the field rather than the whole value.
+	Contains bool
+}
+
+// IsEmpty reports whether the query has no field to match.
+func (q Query) IsEmpty() bool {
+	return q.Email == "" && q.SSHKey == "" && q.GPGKey == ""
+}
+
+// Matches reports whether p matches the query. The email matches the
+// profile's email or any alias. Emails and GPG key IDs are compared
+// case-insensitively, GPG key IDs without a leading 0x, and SSH key
+// paths after expanding ~ so either spelling of a path finds the profile.
+func (q Query) Matches(p Profile) bool {
+	if q.IsEmpty() {
+		return false
+	}
+	if q.Email != "" && !q.matchEmail(p) {
+		return false
+	}
+	if q.SSHKey != "" && (p.SSHKeyPath == "" || !q.match(keyPath(p.SSHKeyPath), keyPath(q.SSHKey))) {
+		return false
+	}
+	if q.GPGKey != "" && (p.GPGKeyID == "" || !q.match(gpgKeyID(p.GPGKeyID), gpgKeyID(q.GPGKey))) {
+		return false
+	}
+	return true
+}
+
+// match compares a normalized field value with a normalized query value.
+func (q Query) match(value, want string) bool {
+	if q.Contains {
+		return strings.Contains(value, want)
+	}
+	return value == want
+}
+
+// matchEmail reports whether the query email matches any email of p.
+func (q Query) matchEmail(p Profile) bool {
+	for _, email := range p.Emails() {
+		if email != "" && q.match(strings.ToLower(email), strings.ToLower(q.Email)) {
+			return true
+		}
+	}
+	return false
+}
+
+// keyPath normalizes an SSH key path for comparison.
+func keyPath(path string) string {
+	if expanded, err := utils.ExpandPath(path); err == nil {
+		path = expanded
+	}
+	return filepath.ToSlash(filepath.Clean(path))
+}
+
+// gpgKeyID normalizes a GPG key ID for comparison.
+func gpgKeyID(id string) string {
+	id = strings.ToUpper(strings.TrimSpace(id))
+	return strings.TrimPrefix(id, "0X")
+}
+
+// FindProfiles returns copies of the profiles matching q, in stored order.
+func (m *Manager) FindProfiles(q Query) []Profile {
+	var matches []Profile
+	for i := range m.profiles {
+		if q.Matches(m.profiles[i]) {
+			matches = append(matches, m.profiles[i].Clone())
+		}
+	}
+	return matches
+}
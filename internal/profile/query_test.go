@@ -0,0 +1,68 @@
+package profile
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestManager_FindProfiles(t *testing.T) {
+	home := useRoot(t)
+
+	manager, err := NewManager(&memoryStore{profiles: []Profile{
+		{Name: "work", Email: "Jane@Acme.com", EmailAliases: []string{"jane@acme-old.com"}, SSHKeyPath: "~/.ssh/id_work", GPGKeyID: "0xABCDEF0123456789"},
+		{Name: "acme-ci", Email: "ci@acme.com", SSHKeyPath: filepath.Join(home, ".ssh", "id_ci")},
+		{Name: "personal", Email: "jane@example.com", GPGKeyID: "1234ABCD"},
+	}})
+	if err != nil {
+		t.Fatalf("NewManager() error = %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		query Query
+		want  []string
+	}{
+		{name: "email exact", query: Query{Email: "jane@acme.com"}, want: []string{"work"}},
+		{name: "email not a substring by default", query: Query{Email: "acme.com"}},
+		{name: "email contains", query: Query{Email: "acme.com", Contains: true}, want: []string{"acme-ci", "work"}},
+		{name: "email alias", query: Query{Email: "Jane@Acme-Old.com"}, want: []string{"work"}},
+		{name: "email alias contains", query: Query{Email: "old.com", Contains: true}, want: []string{"work"}},
+		{name: "ssh key through ~", query: Query{SSHKey: "~/.ssh/id_ci"}, want: []string{"acme-ci"}},
+		{name: "ssh key absolute", query: Query{SSHKey: filepath.Join(home, ".ssh", "id_work")}, want: []string{"work"}},
+		{name: "ssh key contains", query: Query{SSHKey: "id_", Contains: true}, want: []string{"acme-ci", "work"}},
+		{name: "gpg key without 0x", query: Query{GPGKey: "abcdef0123456789"}, want: []string{"work"}},
+		{name: "gpg key contains", query: Query{GPGKey: "0x4ab", Contains: true}, want: []string{"personal"}},
+		{name: "gpg key skips profiles without one", query: Query{GPGKey: "a", Contains: true}, want: []string{"personal", "work"}},
+		{name: "fields combine", query: Query{Email: "jane", GPGKey: "4ab", Contains: true}, want: []string{"personal"}},
+		{name: "empty query", query: Query{Contains: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, p := range manager.FindProfiles(tt.query) {
+				got = append(got, p.Name)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("FindProfiles(%+v) = %v, want %v", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestManager_FindProfilesReturnsCopies(t *testing.T) {
+	t.Parallel()
+	manager, err := NewManager(&memoryStore{profiles: []Profile{
+		{Name: "work", Email: "work@example.com", RemotePatterns: []string{"github.com/acme"}},
+	}})
+	if err != nil {
+		t.Fatalf("NewManager() error = %v", err)
+	}
+
+	found := manager.FindProfiles(Query{Email: "work@example.com"})
+	found[0].Name = "changed"
+	found[0].RemotePatterns[0] = "changed"
+	if p := manager.ListProfiles()[0]; p.Name != "work" || p.RemotePatterns[0] != "github.com/acme" {
+		t.Errorf("changing a found profile changed the manager's: %+v", p)
+	}
+}
@@ -0,0 +1,110 @@
+package profile
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/thuanlegit/git-identitree/internal/logging"
+	"gopkg.in/yaml.v3"
+)
+
+// CurrentVersion is the profiles.yaml schema version SaveProfiles writes.
+//
+// Version 1 was a bare list of profiles. Version 2 wraps the list in a
+// document with a version marker.
+const CurrentVersion = 2
+
+// document is the on-disk layout of profiles.yaml from version 2 on.
+type document struct {
+	Version  int       `yaml:"version"`
+	Profiles []Profile `yaml:"profiles"`
+}
+
+// migration upgrades decoded profiles.yaml content by one version.
+type migration func(raw any) (any, error)
+
+// migrations maps a schema version to the migration that upgrades it to the
+// next one. Adding a version means bumping CurrentVersion and registering the
+// step from the previous version here; older files then chain through every step.
+var migrations = map[int]migration{
+	1: migrateV1ToV2,
+}
+
+// migrateV1ToV2 wraps the bare profile list in a versioned document.
+func migrateV1ToV2(raw any) (any, error) {
+	return map[string]any{"version": 2, "profiles": raw}, nil
+}
+
+// decodeProfiles parses profiles.yaml content of any known version, migrating
+// it to CurrentVersion in memory.
+func decodeProfiles(data []byte) ([]Profile, error) {
+	var raw any
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+	if raw == nil {
+		return []Profile{}, nil
+	}
+
+	version, err := schemaVersion(raw)
+	if err != nil {
+		return nil, err
+	}
+	if version > CurrentVersion {
+		return nil, fmt.Errorf("profiles file has version %d, newer than the supported version %d; upgrade gidtree", version, CurrentVersion)
+	}
+
+	for v := version; v < CurrentVersion; v++ {
+		migrate, ok := migrations[v]
+		if !ok {
+			return nil, fmt.Errorf("no migration from profiles file version %d", v)
+		}
+		if raw, err = migrate(raw); err != nil {
+			return nil, fmt.Errorf("failed to migrate profiles file from version %d: %w", v, err)
+		}
+	}
+	if version < CurrentVersion {
+		logging.Logger().Debug("migrated profiles in memory", "from", version, "to", CurrentVersion)
+	}
+
+	// Round-trip through YAML to decode the migrated content into typed profiles
+	migrated, err := yaml.Marshal(raw)
+	if err != nil {
+		return nil, err
+	}
+	var doc document
+	if err := yaml.Unmarshal(migrated, &doc); err != nil {
+		return nil, err
+	}
+	if doc.Profiles == nil {
+		doc.Profiles = []Profile{}
+	}
+	return doc.Profiles, nil
+}
+
+// encodeProfiles renders profiles as a CurrentVersion document.
+func encodeProfiles(profiles []Profile) ([]byte, error) {
+	// Sorted, so the file does not churn as profiles come and go
+	profiles = slices.Clone(profiles)
+	if profiles == nil {
+		profiles = []Profile{}
+	}
+	Sort(profiles, SortByName)
+	return yaml.Marshal(document{Version: CurrentVersion, Profiles: profiles})
+}
+
+// schemaVersion returns the version of decoded profiles.yaml content.
+func schemaVersion(raw any) (int, error) {
+	switch content := raw.(type) {
+	case []any:
+		// The bare list predates the version marker
+		return 1, nil
+	case map[string]any:
+		version, ok := content["version"].(int)
+		if !ok {
+			return 0, fmt.Errorf("profiles file has no valid version")
+		}
+		return version, nil
+	}
+	return 0, fmt.Errorf("profiles file has an unexpected layout")
+}
@@ -0,0 +1,156 @@
+package profile
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const v1Profiles = `- name: work
+  email: jane@company.com
+  author_name: Jane Doe
+  ssh_key_path: ~/.ssh/id_work
+  gpg_key_id: ABC123
+- name: personal
+  email: jane@example.com
+`
+
+func writeProfilesFile(t *testing.T, store FileStore, content string) string {
+	t.Helper()
+	path := storePath(t, store)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("Failed to create profiles directory: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write profiles file: %v", err)
+	}
+	return path
+}
+
+func TestLoadProfiles_MigratesV1(t *testing.T) {
+	t.Parallel()
+	store := newTestStore(t)
+	path := writeProfilesFile(t, store, v1Profiles)
+
+	want := []Profile{
+		{Name: "work", Email: "jane@company.com", AuthorName: "Jane Doe", SSHKeyPath: "~/.ssh/id_work", GPGKeyID: "ABC123"},
+		{Name: "personal", Email: "jane@example.com"},
+	}
+
+	loaded, err := store.Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if !reflect.DeepEqual(loaded, want) {
+		t.Fatalf("Load() = %+v, want %+v", loaded, want)
+	}
+
+	// Loading alone leaves the legacy file untouched
+	if data, _ := os.ReadFile(path); string(data) != v1Profiles {
+		t.Errorf("Load() rewrote the file:\n%s", data)
+	}
+
+	if err := store.Save(loaded); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read profiles file: %v", err)
+	}
+	if !strings.HasPrefix(string(data), "version: 2\nprofiles:\n") {
+		t.Errorf("Save() wrote:\n%s\nwant a version 2 document", data)
+	}
+
+	reloaded, err := store.Load()
+	if err != nil {
+		t.Fatalf("Load() after save error = %v", err)
+	}
+	// Saving sorts the profiles by name
+	if sorted := []Profile{want[1], want[0]}; !reflect.DeepEqual(reloaded, sorted) {
+		t.Errorf("Load() after save = %+v, want %+v", reloaded, sorted)
+	}
+}
+
+func TestDecodeProfiles(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name    string
+		content string
+		want    []Profile
+		wantErr string
+	}{
+		{name: "empty file", content: "", want: []Profile{}},
+		{name: "empty v1 list", content: "[]\n", want: []Profile{}},
+		{name: "v2 without profiles", content: "version: 2\n", want: []Profile{}},
+		{name: "v2", content: "version: 2\nprofiles:\n  - name: a\n    email: a@example.com\n", want: []Profile{{Name: "a", Email: "a@example.com"}}},
+		{name: "v2 with email aliases", content: "version: 2\nprofiles:\n  - name: a\n    email: a@example.com\n    email_aliases: [a@old.example.com]\n", want: []Profile{{Name: "a", Email: "a@example.com", EmailAliases: []string{"a@old.example.com"}}}},
+		{name: "newer version", content: "version: 3\nprofiles: []\n", wantErr: "newer than the supported version"},
+		{name: "missing version", content: "profiles: []\n", wantErr: "no valid version"},
+		{name: "scalar", content: "hello\n", wantErr: "unexpected layout"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got, err := decodeProfiles([]byte(tt.content))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("decodeProfiles() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("decodeProfiles() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("decodeProfiles() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMigrations_ChainToCurrentVersion(t *testing.T) {
+	t.Parallel()
+	// Every version below the current one needs a step, or old files cannot load
+	for v := 1; v < CurrentVersion; v++ {
+		if _, ok := migrations[v]; !ok {
+			t.Errorf("no migration registered from version %d", v)
+		}
+	}
+}
+
+func TestEncodeProfiles_Empty(t *testing.T) {
+	t.Parallel()
+	data, err := encodeProfiles(nil)
+	if err != nil {
+		t.Fatalf("encodeProfiles() error = %v", err)
+	}
+	if string(data) != "version: 2\nprofiles: []\n" {
+		t.Errorf("encodeProfiles(nil) = %q", data)
+	}
+}
+
+func TestEncodeProfiles_EmailAliases(t *testing.T) {
+	t.Parallel()
+	data, err := encodeProfiles([]Profile{{Name: "a", Email: "a@example.com"}})
+	if err != nil {
+		t.Fatalf("encodeProfiles() error = %v", err)
+	}
+	// Files written without aliases stay readable by older versions
+	if strings.Contains(string(data), "email_aliases") {
+		t.Errorf("encodeProfiles() = %q, want no email_aliases key", data)
+	}
+
+	data, err = encodeProfiles([]Profile{{Name: "a", Email: "a@example.com", EmailAliases: []string{"a@old.example.com"}}})
+	if err != nil {
+		t.Fatalf("encodeProfiles() error = %v", err)
+	}
+	got, err := decodeProfiles(data)
+	if err != nil {
+		t.Fatalf("decodeProfiles() error = %v", err)
+	}
+	if len(got) != 1 || !reflect.DeepEqual(got[0].EmailAliases, []string{"a@old.example.com"}) {
+		t.Errorf("round trip = %+v, want the alias kept", got)
+	}
+}
@@ -0,0 +1,55 @@
+package profile
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// SortKey is an order profiles can be listed in.
+type SortKey string
+
+const (
+	// SortByName orders by name, ignoring case. profiles.yaml is kept in
+	// this order so that adding a profile does not reorder the file.
+	SortByName SortKey = "name"
+	// SortByCreated orders by CreatedAt, oldest first. Profiles from before
+	// the timestamp was recorded come first.
+	SortByCreated SortKey = "created"
+	// SortByEmail orders by email, ignoring case.
+	SortByEmail SortKey = "email"
+)
+
+// SortKeys are the accepted sort keys.
+var SortKeys = []SortKey{SortByName, SortByCreated, SortByEmail}
+
+// ParseSortKey returns the sort key named s.
+func ParseSortKey(s string) (SortKey, error) {
+	for _, key := range SortKeys {
+		if string(key) == s {
+			return key, nil
+		}
+	}
+	return "", fmt.Errorf("invalid sort key %q: want name, created or email", s)
+}
+
+// Sort orders profiles by key in place. Ties are broken by name, so the order
+// does not depend on the order profiles were added in.
+func Sort(profiles []Profile, key SortKey) {
+	slices.SortStableFunc(profiles, func(a, b Profile) int {
+		var c int
+		switch key {
+		case SortByCreated:
+			c = a.CreatedAt.Compare(b.CreatedAt)
+		case SortByEmail:
+			c = strings.Compare(strings.ToLower(a.Email), strings.ToLower(b.Email))
+		}
+		if c != 0 {
+			return c
+		}
+		if c := strings.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name)); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Name, b.Name)
+	})
+}
@@ -0,0 +1,52 @@
+package profile
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSort(t *testing.T) {
+	t.Parallel()
+	day := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	profiles := []Profile{
+		{Name: "work", Email: "b@example.com", CreatedAt: day.Add(48 * time.Hour)},
+		{Name: "Personal", Email: "C@example.com", CreatedAt: day},
+		{Name: "acme", Email: "a@example.com", CreatedAt: day.Add(24 * time.Hour)},
+		{Name: "legacy", Email: "b@example.com"},
+		{Name: "personal", Email: "c@example.com", CreatedAt: day},
+	}
+
+	tests := []struct {
+		key  SortKey
+		want string
+	}{
+		{SortByName, "acme,legacy,Personal,personal,work"},
+		{SortByCreated, "legacy,Personal,personal,acme,work"},
+		{SortByEmail, "acme,legacy,work,Personal,personal"},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.key), func(t *testing.T) {
+			Sort(profiles, tt.key)
+			var names []string
+			for _, p := range profiles {
+				names = append(names, p.Name)
+			}
+			if got := strings.Join(names, ","); got != tt.want {
+				t.Errorf("Sort(%s) = %s, want %s", tt.key, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseSortKey(t *testing.T) {
+	t.Parallel()
+	for _, key := range SortKeys {
+		if got, err := ParseSortKey(string(key)); err != nil || got != key {
+			t.Errorf("ParseSortKey(%q) = %q, %v", key, got, err)
+		}
+	}
+	if _, err := ParseSortKey("size"); err == nil {
+		t.Error("ParseSortKey() should reject an unknown key")
+	}
+}
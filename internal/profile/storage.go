@@ -1,12 +1,13 @@
 package profile
 
 import (
+	"crypto/sha256"
 	"fmt"
 	"os"
 	"path/filepath"
 
+	"github.com/thuanlegit/git-identitree/internal/logging"
 	"github.com/thuanlegit/git-identitree/internal/utils"
-	"gopkg.in/yaml.v3"
 )
 
 const (
@@ -16,7 +17,7 @@ const (
 
 // GetProfilesPath returns the path to the profiles.yaml file.
 func GetProfilesPath() (string, error) {
-	home, err := utils.GetHomeDir()
+	home, err := utils.Root()
 	if err != nil {
 		return "", fmt.Errorf("failed to get home directory: %w", err)
 	}
@@ -25,63 +26,105 @@ func GetProfilesPath() (string, error) {
 
 // GetProfilesDir returns the path to the .gidtree directory.
 func GetProfilesDir() (string, error) {
-	home, err := utils.GetHomeDir()
+	home, err := utils.Root()
 	if err != nil {
 		return "", fmt.Errorf("failed to get home directory: %w", err)
 	}
 	return filepath.Join(home, profilesDir), nil
 }
 
-// LoadProfiles reads and parses the profiles.yaml file.
+// LoadProfiles reads and parses ~/.gidtree/profiles.yaml; see FileStore.Load.
 func LoadProfiles() ([]Profile, error) {
-	profilesPath, err := GetProfilesPath()
+	return FileStore{}.Load()
+}
+
+// SaveProfiles writes profiles to ~/.gidtree/profiles.yaml; see FileStore.Save.
+func SaveProfiles(profiles []Profile) error {
+	return FileStore{}.Save(profiles)
+}
+
+// loadProfilesFile reads and parses the profiles file at path. Files written
+// by older versions are migrated in memory; the next save writes the new
+// format. An encrypted file is decrypted with the passphrase from
+// PassphraseSource.
+func loadProfilesFile(path string) ([]Profile, error) {
+	data, err := readProfilesFile(path)
 	if err != nil {
 		return nil, err
 	}
 
 	// If file doesn't exist, return empty slice
-	if _, err := os.Stat(profilesPath); os.IsNotExist(err) {
+	if data == nil {
 		return []Profile{}, nil
 	}
 
-	data, err := os.ReadFile(profilesPath)
+	doc, err := storageFor(data).Decode(data)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read profiles file: %w", err)
+		return nil, fmt.Errorf("failed to decrypt profiles file: %w", err)
 	}
 
-	var profiles []Profile
-	if err := yaml.Unmarshal(data, &profiles); err != nil {
+	profiles, err := decodeProfiles(doc)
+	if err != nil {
 		return nil, fmt.Errorf("failed to parse profiles file: %w", err)
 	}
 
 	return profiles, nil
 }
 
-// SaveProfiles writes profiles to the profiles.yaml file.
-func SaveProfiles(profiles []Profile) error {
-	profilesPath, err := GetProfilesPath()
+// readProfilesFile returns the raw content of the profiles file at path, or
+// nil when it does not exist.
+func readProfilesFile(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
 	if err != nil {
-		return err
+		return nil, fmt.Errorf("failed to read profiles file: %w", err)
 	}
+	logging.Logger().Debug("read profiles", "path", path, "bytes", len(data))
+	return data, nil
+}
 
-	// Ensure directory exists
-	profilesDir, err := GetProfilesDir()
+// profilesFileState returns the modification time and hash of the profiles
+// file at path, or the zero StoreState when it does not exist.
+func profilesFileState(path string) (StoreState, error) {
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return StoreState{}, nil
+	}
 	if err != nil {
-		return err
+		return StoreState{}, fmt.Errorf("failed to stat profiles file: %w", err)
+	}
+	data, err := readProfilesFile(path)
+	if err != nil {
+		return StoreState{}, err
+	}
+	if data == nil {
+		return StoreState{}, nil
 	}
-	if err := os.MkdirAll(profilesDir, 0755); err != nil {
+	return StoreState{ModTime: info.ModTime(), Hash: sha256.Sum256(data)}, nil
+}
+
+// writeProfiles encodes profiles through storage and writes them to the
+// profiles file at path, creating its directory.
+func writeProfiles(path string, profiles []Profile, storage Storage) error {
+	if err := os.MkdirAll(filepath.Dir(path), utils.PrivateDirMode); err != nil {
 		return fmt.Errorf("failed to create profiles directory: %w", err)
 	}
 
-	data, err := yaml.Marshal(profiles)
+	doc, err := encodeProfiles(profiles)
 	if err != nil {
 		return fmt.Errorf("failed to marshal profiles: %w", err)
 	}
+	data, err := storage.Encode(doc)
+	if err != nil {
+		return fmt.Errorf("failed to encode profiles: %w", err)
+	}
 
-	if err := os.WriteFile(profilesPath, data, 0644); err != nil {
+	if err := os.WriteFile(path, data, utils.PrivateFileMode); err != nil {
 		return fmt.Errorf("failed to write profiles file: %w", err)
 	}
+	logging.Logger().Debug("wrote profiles", "path", path, "bytes", len(data), "profiles", len(profiles))
 
 	return nil
 }
-
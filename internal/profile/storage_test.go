@@ -4,69 +4,43 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+
+	"github.com/thuanlegit/git-identitree/internal/utils"
 )
 
-func setupTestEnv(t *testing.T) (string, func()) {
-	tmpDir, err := os.MkdirTemp("", "gidtree-test-*")
-	if err != nil {
-		t.Fatalf("Failed to create temp directory: %v", err)
-	}
+// newTestStore returns a FileStore in a temporary directory of its own, so
+// tests using it do not touch the environment and can run in parallel.
+func newTestStore(t *testing.T) FileStore {
+	t.Helper()
+	return FileStore{Dir: filepath.Join(t.TempDir(), profilesDir)}
+}
 
-	// On Windows, resolve short paths (e.g., RUNNER~1) to long paths
-	// This ensures consistent path comparisons in tests
-	tmpDir, err = filepath.EvalSymlinks(tmpDir)
+// useRoot points utils.Root at a temporary directory and returns it, for
+// tests of the defaults taken from the environment, such as ~ in SSH key
+// paths. Such tests cannot run in parallel.
+func useRoot(t *testing.T) string {
+	t.Helper()
+	dir, err := filepath.EvalSymlinks(t.TempDir())
 	if err != nil {
-		// If symlink resolution fails, continue with original path
-		// This shouldn't happen for newly created directories
-		t.Logf("Warning: Failed to resolve tmpDir symlinks: %v", err)
-	}
-
-	// Override home directory for testing
-	// On Windows, os.UserHomeDir() uses USERPROFILE, HOMEDRIVE+HOMEPATH, or HOME
-	// We need to override all of them to ensure tests work correctly
-	originalHome := os.Getenv("HOME")
-	originalUserProfile := os.Getenv("USERPROFILE")
-	originalHomeDrive := os.Getenv("HOMEDRIVE")
-	originalHomePath := os.Getenv("HOMEPATH")
-
-	if err := os.Setenv("HOME", tmpDir); err != nil {
-		t.Fatalf("Failed to set HOME: %v", err)
-	}
-	if err := os.Setenv("USERPROFILE", tmpDir); err != nil {
-		t.Fatalf("Failed to set USERPROFILE: %v", err)
-	}
-	// Clear HOMEDRIVE and HOMEPATH to prevent them from interfering
-	if err := os.Setenv("HOMEDRIVE", ""); err != nil {
-		t.Fatalf("Failed to clear HOMEDRIVE: %v", err)
-	}
-	if err := os.Setenv("HOMEPATH", ""); err != nil {
-		t.Fatalf("Failed to clear HOMEPATH: %v", err)
+		t.Fatalf("Failed to resolve temp directory: %v", err)
 	}
+	t.Setenv(utils.RootEnv, dir)
+	return dir
+}
 
-	cleanup := func() {
-		if err := os.Setenv("HOME", originalHome); err != nil {
-			t.Logf("Failed to restore HOME: %v", err)
-		}
-		if err := os.Setenv("USERPROFILE", originalUserProfile); err != nil {
-			t.Logf("Failed to restore USERPROFILE: %v", err)
-		}
-		if err := os.Setenv("HOMEDRIVE", originalHomeDrive); err != nil {
-			t.Logf("Failed to restore HOMEDRIVE: %v", err)
-		}
-		if err := os.Setenv("HOMEPATH", originalHomePath); err != nil {
-			t.Logf("Failed to restore HOMEPATH: %v", err)
-		}
-		if err := os.RemoveAll(tmpDir); err != nil {
-			t.Logf("Failed to remove temp dir: %v", err)
-		}
+// storePath returns the location of store's profiles.yaml.
+func storePath(t *testing.T, store FileStore) string {
+	t.Helper()
+	path, err := store.Path()
+	if err != nil {
+		t.Fatalf("Path() error = %v", err)
 	}
-
-	return tmpDir, cleanup
+	return path
 }
 
 func TestSaveAndLoadProfiles(t *testing.T) {
-	_, cleanup := setupTestEnv(t)
-	defer cleanup()
+	t.Parallel()
+	store := newTestStore(t)
 
 	profiles := []Profile{
 		{
@@ -84,18 +58,18 @@ func TestSaveAndLoadProfiles(t *testing.T) {
 	}
 
 	// Save profiles
-	if err := SaveProfiles(profiles); err != nil {
-		t.Fatalf("SaveProfiles() error = %v", err)
+	if err := store.Save(profiles); err != nil {
+		t.Fatalf("Save() error = %v", err)
 	}
 
 	// Load profiles
-	loaded, err := LoadProfiles()
+	loaded, err := store.Load()
 	if err != nil {
-		t.Fatalf("LoadProfiles() error = %v", err)
+		t.Fatalf("Load() error = %v", err)
 	}
 
 	if len(loaded) != len(profiles) {
-		t.Fatalf("LoadProfiles() loaded %d profiles, want %d", len(loaded), len(profiles))
+		t.Fatalf("Load() loaded %d profiles, want %d", len(loaded), len(profiles))
 	}
 
 	for i, p := range profiles {
@@ -115,22 +89,21 @@ func TestSaveAndLoadProfiles(t *testing.T) {
 }
 
 func TestLoadProfilesNonExistent(t *testing.T) {
-	_, cleanup := setupTestEnv(t)
-	defer cleanup()
+	t.Parallel()
+	store := newTestStore(t)
 
-	profiles, err := LoadProfiles()
+	profiles, err := store.Load()
 	if err != nil {
-		t.Fatalf("LoadProfiles() error = %v, want no error for non-existent file", err)
+		t.Fatalf("Load() error = %v, want no error for non-existent file", err)
 	}
 
 	if len(profiles) != 0 {
-		t.Errorf("LoadProfiles() = %v, want empty slice", profiles)
+		t.Errorf("Load() = %v, want empty slice", profiles)
 	}
 }
 
 func TestGetProfilesPath(t *testing.T) {
-	tmpDir, cleanup := setupTestEnv(t)
-	defer cleanup()
+	tmpDir := useRoot(t)
 
 	path, err := GetProfilesPath()
 	if err != nil {
@@ -141,11 +114,13 @@ func TestGetProfilesPath(t *testing.T) {
 	if path != expected {
 		t.Errorf("GetProfilesPath() = %v, want %v", path, expected)
 	}
+	if got, _ := (FileStore{}).Path(); got != expected {
+		t.Errorf("FileStore{}.Path() = %v, want %v", got, expected)
+	}
 }
 
 func TestGetProfilesDir(t *testing.T) {
-	tmpDir, cleanup := setupTestEnv(t)
-	defer cleanup()
+	tmpDir := useRoot(t)
 
 	dir, err := GetProfilesDir()
 	if err != nil {
@@ -158,83 +133,64 @@ func TestGetProfilesDir(t *testing.T) {
 	}
 }
 
-func TestLoadProfiles_InvalidYAML(t *testing.T) {
-	_, cleanup := setupTestEnv(t)
-	defer cleanup()
+func TestLoadProfiles_Default(t *testing.T) {
+	tmpDir := useRoot(t)
 
-	profilesDir, err := GetProfilesDir()
-	if err != nil {
-		t.Fatalf("GetProfilesDir() error = %v", err)
+	if err := SaveProfiles([]Profile{{Name: "work", Email: "work@example.com"}}); err != nil {
+		t.Fatalf("SaveProfiles() error = %v", err)
 	}
-	if err := os.MkdirAll(profilesDir, 0755); err != nil {
-		t.Fatalf("Failed to create profiles directory: %v", err)
+	if _, err := os.Stat(filepath.Join(tmpDir, profilesDir, profilesFile)); err != nil {
+		t.Errorf("SaveProfiles() should write below GIDTREE_ROOT: %v", err)
+	}
+	if loaded, err := LoadProfiles(); err != nil || len(loaded) != 1 {
+		t.Errorf("LoadProfiles() = %v, %v", loaded, err)
 	}
+}
 
-	profilesPath, err := GetProfilesPath()
-	if err != nil {
-		t.Fatalf("GetProfilesPath() error = %v", err)
+func TestLoadProfiles_InvalidYAML(t *testing.T) {
+	t.Parallel()
+	store := newTestStore(t)
+
+	if err := os.MkdirAll(store.Dir, 0755); err != nil {
+		t.Fatalf("Failed to create profiles directory: %v", err)
 	}
 
 	// Create invalid YAML (tab character which is invalid in YAML)
 	invalidYAML := "- name: test\n\temail: test@example.com"
-	if err := os.WriteFile(profilesPath, []byte(invalidYAML), 0644); err != nil {
+	if err := os.WriteFile(storePath(t, store), []byte(invalidYAML), 0644); err != nil {
 		t.Fatalf("Failed to write invalid YAML: %v", err)
 	}
 
-	_, err = LoadProfiles()
+	_, err := store.Load()
 	if err == nil {
 		// YAML parser might be lenient, so we'll just log if it doesn't fail
-		t.Log("LoadProfiles() might accept some invalid YAML formats")
+		t.Log("Load() might accept some invalid YAML formats")
 	} else {
-		t.Logf("LoadProfiles() correctly rejected invalid YAML: %v", err)
+		t.Logf("Load() correctly rejected invalid YAML: %v", err)
 	}
 }
 
 func TestSaveProfiles_WriteError(t *testing.T) {
-	_, cleanup := setupTestEnv(t)
-	defer cleanup()
+	t.Parallel()
+	store := newTestStore(t)
 
 	// Create a file where the directory should be
-	profilesDir, err := GetProfilesDir()
-	if err != nil {
-		t.Fatalf("GetProfilesDir() error = %v", err)
-	}
-
-	// Remove directory and create a file with the same name
-	if err := os.RemoveAll(profilesDir); err != nil {
-		t.Fatalf("Failed to remove profiles directory: %v", err)
-	}
-	if err := os.WriteFile(profilesDir, []byte("file"), 0644); err != nil {
+	if err := os.WriteFile(store.Dir, []byte("file"), 0644); err != nil {
 		t.Fatalf("Failed to create file: %v", err)
 	}
-	defer func() {
-		if err := os.RemoveAll(profilesDir); err != nil {
-			t.Logf("Failed to remove profiles directory: %v", err)
-		}
-	}()
 
 	profiles := []Profile{
 		{Name: "test", Email: "test@example.com"},
 	}
 
-	err = SaveProfiles(profiles)
-	if err == nil {
-		t.Error("SaveProfiles() should fail when directory is a file")
+	if err := store.Save(profiles); err == nil {
+		t.Error("Save() should fail when directory is a file")
 	}
 }
 
 func TestGetProfilesPath_HomeDirError(t *testing.T) {
-	// Save original HOME
-	originalHome := os.Getenv("HOME")
-	defer func() {
-		if err := os.Setenv("HOME", originalHome); err != nil {
-			t.Logf("Failed to restore HOME: %v", err)
-		}
-	}()
-
-	// Set invalid HOME
-	if err := os.Setenv("HOME", ""); err != nil {
-		t.Fatalf("Failed to set HOME: %v", err)
+	for _, name := range []string{"HOME", "USERPROFILE", "HOMEDRIVE", "HOMEPATH", utils.RootEnv} {
+		t.Setenv(name, "")
 	}
 
 	_, err := GetProfilesPath()
@@ -243,22 +199,11 @@ func TestGetProfilesPath_HomeDirError(t *testing.T) {
 	} else {
 		t.Logf("GetProfilesPath() handled invalid HOME: %v", err)
 	}
-
-	// Restore HOME already handled by defer
 }
 
 func TestGetProfilesDir_HomeDirError(t *testing.T) {
-	// Save original HOME
-	originalHome := os.Getenv("HOME")
-	defer func() {
-		if err := os.Setenv("HOME", originalHome); err != nil {
-			t.Logf("Failed to restore HOME: %v", err)
-		}
-	}()
-
-	// Set invalid HOME
-	if err := os.Setenv("HOME", ""); err != nil {
-		t.Fatalf("Failed to set HOME: %v", err)
+	for _, name := range []string{"HOME", "USERPROFILE", "HOMEDRIVE", "HOMEPATH", utils.RootEnv} {
+		t.Setenv(name, "")
 	}
 
 	_, err := GetProfilesDir()
@@ -267,42 +212,27 @@ func TestGetProfilesDir_HomeDirError(t *testing.T) {
 	} else {
 		t.Logf("GetProfilesDir() handled invalid HOME: %v", err)
 	}
-
-	// Restore HOME already handled by defer
 }
 
 func TestLoadProfiles_ReadError(t *testing.T) {
-	_, cleanup := setupTestEnv(t)
-	defer cleanup()
-
-	profilesPath, err := GetProfilesPath()
-	if err != nil {
-		t.Fatalf("GetProfilesPath() error = %v", err)
-	}
+	t.Parallel()
+	store := newTestStore(t)
 
 	// Create directory with same name as file
-	if err := os.Remove(profilesPath); err != nil && !os.IsNotExist(err) {
-		t.Fatalf("Failed to remove profiles file: %v", err)
-	}
-	if err := os.MkdirAll(profilesPath, 0755); err != nil {
+	if err := os.MkdirAll(storePath(t, store), 0755); err != nil {
 		t.Fatalf("Failed to create directory: %v", err)
 	}
-	defer func() {
-		if err := os.RemoveAll(profilesPath); err != nil {
-			t.Logf("Failed to remove profiles path: %v", err)
-		}
-	}()
 
-	_, err = LoadProfiles()
+	_, err := store.Load()
 	// Should handle gracefully (treat as non-existent)
 	if err != nil {
-		t.Logf("LoadProfiles() handled directory-as-file: %v", err)
+		t.Logf("Load() handled directory-as-file: %v", err)
 	}
 }
 
 func TestSaveProfiles_MarshalError(t *testing.T) {
-	_, cleanup := setupTestEnv(t)
-	defer cleanup()
+	t.Parallel()
+	store := newTestStore(t)
 
 	// Create profiles that might cause marshal issues
 	// (In practice, Profile struct should always marshal correctly)
@@ -311,47 +241,35 @@ func TestSaveProfiles_MarshalError(t *testing.T) {
 	}
 
 	// This should succeed
-	err := SaveProfiles(profiles)
-	if err != nil {
-		t.Fatalf("SaveProfiles() error = %v", err)
+	if err := store.Save(profiles); err != nil {
+		t.Fatalf("Save() error = %v", err)
 	}
 
 	// Verify it was saved
-	loaded, err := LoadProfiles()
+	loaded, err := store.Load()
 	if err != nil {
-		t.Fatalf("LoadProfiles() error = %v", err)
+		t.Fatalf("Load() error = %v", err)
 	}
 
 	if len(loaded) != 1 {
-		t.Errorf("LoadProfiles() returned %d profiles, want 1", len(loaded))
+		t.Errorf("Load() returned %d profiles, want 1", len(loaded))
 	}
 }
 
 func TestSaveProfiles_CreateDirectory(t *testing.T) {
-	_, cleanup := setupTestEnv(t)
-	defer cleanup()
-
-	// Remove profiles directory
-	profilesDir, err := GetProfilesDir()
-	if err != nil {
-		t.Fatalf("GetProfilesDir() error = %v", err)
-	}
-	if err := os.RemoveAll(profilesDir); err != nil {
-		t.Fatalf("Failed to remove profiles directory: %v", err)
-	}
+	t.Parallel()
+	store := newTestStore(t)
 
 	profiles := []Profile{
 		{Name: "test", Email: "test@example.com"},
 	}
 
-	err = SaveProfiles(profiles)
-	if err != nil {
-		t.Fatalf("SaveProfiles() error = %v", err)
+	if err := store.Save(profiles); err != nil {
+		t.Fatalf("Save() error = %v", err)
 	}
 
 	// Verify directory was created
-	if _, err := os.Stat(profilesDir); os.IsNotExist(err) {
-		t.Error("SaveProfiles() should create profiles directory")
+	if _, err := os.Stat(store.Dir); os.IsNotExist(err) {
+		t.Error("Save() should create profiles directory")
 	}
 }
-
@@ -0,0 +1,76 @@
+package profile
+
+import (
+	"crypto/sha256"
+	"path/filepath"
+	"time"
+)
+
+// ProfileStore loads and saves the complete list of profiles. Manager works
+// against this interface so the backing storage can be swapped out.
+type ProfileStore interface {
+	Load() ([]Profile, error)
+	Save(profiles []Profile) error
+}
+
+// StoreState identifies the content of a store at one point in time. The zero
+// value stands for a store that holds nothing yet.
+type StoreState struct {
+	ModTime time.Time
+	Hash    [sha256.Size]byte
+}
+
+// WatchedStore is a ProfileStore that can tell whether its content changed,
+// for example because another process saved profiles. Manager uses it to
+// reload and to refuse overwriting changes it has not seen.
+type WatchedStore interface {
+	ProfileStore
+	State() (StoreState, error)
+}
+
+// FileStore keeps profiles in a profiles.yaml file, encrypted or not.
+type FileStore struct {
+	// Dir is the directory holding profiles.yaml. Empty means ~/.gidtree,
+	// below utils.Root, which is what the CLI uses.
+	Dir string
+}
+
+// Path returns the location of the store's profiles.yaml.
+func (s FileStore) Path() (string, error) {
+	if s.Dir != "" {
+		return filepath.Join(s.Dir, profilesFile), nil
+	}
+	return GetProfilesPath()
+}
+
+// Load implements ProfileStore.
+func (s FileStore) Load() ([]Profile, error) {
+	path, err := s.Path()
+	if err != nil {
+		return nil, err
+	}
+	return loadProfilesFile(path)
+}
+
+// Save implements ProfileStore, keeping the file encrypted if it already is.
+func (s FileStore) Save(profiles []Profile) error {
+	path, err := s.Path()
+	if err != nil {
+		return err
+	}
+	current, err := readProfilesFile(path)
+	if err != nil {
+		return err
+	}
+	return writeProfiles(path, profiles, storageFor(current))
+}
+
+// State implements WatchedStore with the modification time and SHA-256 of
+// profiles.yaml.
+func (s FileStore) State() (StoreState, error) {
+	path, err := s.Path()
+	if err != nil {
+		return StoreState{}, err
+	}
+	return profilesFileState(path)
+}
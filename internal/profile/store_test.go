@@ -0,0 +1,104 @@
+package profile
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestFileStore(t *testing.T) {
+	t.Parallel()
+	var store ProfileStore = newTestStore(t)
+	profiles := []Profile{{Name: "work", Email: "work@example.com"}}
+	if err := store.Save(profiles); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	got, err := store.Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, profiles) {
+		t.Errorf("Load() = %+v, want %+v", got, profiles)
+	}
+
+	manager, err := NewManager(store)
+	if err != nil {
+		t.Fatalf("NewManager() error = %v", err)
+	}
+	if _, err := manager.GetProfile("work"); err != nil {
+		t.Errorf("GetProfile(work) error = %v", err)
+	}
+}
+
+func TestFileStore_State(t *testing.T) {
+	t.Parallel()
+	store := newTestStore(t)
+	state, err := store.State()
+	if err != nil {
+		t.Fatalf("State() error = %v", err)
+	}
+	if state != (StoreState{}) {
+		t.Errorf("State() without profiles.yaml = %+v, want the zero state", state)
+	}
+
+	if err := store.Save([]Profile{{Name: "work", Email: "work@example.com"}}); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	saved, err := store.State()
+	if err != nil {
+		t.Fatalf("State() error = %v", err)
+	}
+	if saved == (StoreState{}) {
+		t.Error("State() after Save is the zero state")
+	}
+	again, err := store.State()
+	if err != nil {
+		t.Fatalf("State() error = %v", err)
+	}
+	if again != saved {
+		t.Errorf("State() = %+v, then %+v without a change", saved, again)
+	}
+}
+
+func TestManager_FileChangedBetweenLoadAndSave(t *testing.T) {
+	t.Parallel()
+	store := newTestStore(t)
+
+	if err := store.Save([]Profile{{Name: "work", Email: "work@example.com"}}); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	manager, err := NewManager(store)
+	if err != nil {
+		t.Fatalf("NewManager() error = %v", err)
+	}
+
+	// Another terminal adds a profile while this manager is open
+	if err := store.Save([]Profile{{Name: "work", Email: "work@example.com"}, {Name: "other", Email: "other@example.com"}}); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	err = manager.UpdateProfile("work", Profile{Name: "work", Email: "new@example.com"})
+	if !errors.Is(err, ErrProfilesChanged) {
+		t.Fatalf("UpdateProfile() error = %v, want ErrProfilesChanged", err)
+	}
+	profiles, err := store.Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if len(profiles) != 2 {
+		t.Errorf("profiles = %v, want the other terminal's change kept", profiles)
+	}
+
+	manager.AutoReload = true
+	if err := manager.UpdateProfile("work", Profile{Name: "work", Email: "new@example.com"}); err != nil {
+		t.Fatalf("UpdateProfile() with AutoReload error = %v", err)
+	}
+	profiles, err = store.Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if len(profiles) != 2 || profiles[1].Email != "new@example.com" {
+		t.Errorf("profiles = %v, want the update on top of the other change", profiles)
+	}
+}
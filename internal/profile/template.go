@@ -0,0 +1,234 @@
+package profile
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"text/template"
+	"text/template/parse"
+	"time"
+
+	"github.com/thuanlegit/git-identitree/internal/utils"
+	"gopkg.in/yaml.v3"
+)
+
+// Template holds a team's conventions for new profiles. A non-empty field is
+// pinned: it is not asked for, and its value may use text/template
+// placeholders such as {{ .Username }} that are asked for instead.
+type Template struct {
+	Name       string `yaml:"name,omitempty"`
+	Email      string `yaml:"email,omitempty"`
+	AuthorName string `yaml:"author_name,omitempty"`
+	SSHKeyPath string `yaml:"ssh_key_path,omitempty"`
+	GPGKeyID   string `yaml:"gpg_key_id,omitempty"`
+	// SigningRequired makes the GPG key ID mandatory.
+	SigningRequired bool `yaml:"signing_required,omitempty"`
+	// DirectoryPrefix is offered as the start of the directory to map the new
+	// profile to. It may use placeholders too.
+	DirectoryPrefix string `yaml:"directory_prefix,omitempty"`
+}
+
+// templateFetchLimit caps the size of a template downloaded from a URL.
+const templateFetchLimit = 1 << 20
+
+// templateClient fetches templates given as a URL.
+var templateClient = &http.Client{Timeout: 10 * time.Second}
+
+// LoadTemplate reads a template from a file path or an http(s) URL.
+func LoadTemplate(source string) (*Template, error) {
+	var data []byte
+	if strings.HasPrefix(source, "https://") || strings.HasPrefix(source, "http://") {
+		resp, err := templateClient.Get(source)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch template: %w", err)
+		}
+		defer func() { _ = resp.Body.Close() }()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("failed to fetch template: %s returned %s", source, resp.Status)
+		}
+		data, err = io.ReadAll(io.LimitReader(resp.Body, templateFetchLimit))
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch template: %w", err)
+		}
+	} else {
+		path, err := utils.ExpandPath(source)
+		if err != nil {
+			return nil, err
+		}
+		data, err = os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read template: %w", err)
+		}
+	}
+	return ParseTemplate(data)
+}
+
+// ParseTemplate decodes a YAML template. Unknown keys and malformed
+// placeholders are rejected up front rather than when rendering.
+func ParseTemplate(data []byte) (*Template, error) {
+	var t Template
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&t); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("failed to parse template: %w", err)
+	}
+	for _, f := range t.fields() {
+		if _, err := parseField(f.key, *f.value); err != nil {
+			return nil, err
+		}
+	}
+	return &t, nil
+}
+
+// templateField pairs a template value with its YAML key.
+type templateField struct {
+	key   string
+	value *string
+}
+
+// fields returns the templated fields in form order.
+func (t *Template) fields() []templateField {
+	return []templateField{
+		{"name", &t.Name},
+		{"email", &t.Email},
+		{"author_name", &t.AuthorName},
+		{"ssh_key_path", &t.SSHKeyPath},
+		{"gpg_key_id", &t.GPGKeyID},
+		{"directory_prefix", &t.DirectoryPrefix},
+	}
+}
+
+// Placeholders returns the names the template's fields refer to, in order of
+// first use.
+func (t *Template) Placeholders() []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, f := range t.fields() {
+		tmpl, err := parseField(f.key, *f.value)
+		if err != nil || tmpl.Tree == nil {
+			continue
+		}
+		collectFields(tmpl.Tree.Root, func(name string) {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		})
+	}
+	return names
+}
+
+// Render fills in the template with values for its placeholders. Fields the
+// template does not pin are taken from base.
+func (t *Template) Render(values map[string]string, base Profile) (Profile, error) {
+	prof := base
+	for _, f := range []struct {
+		key  string
+		tmpl string
+		dst  *string
+	}{
+		{"name", t.Name, &prof.Name},
+		{"email", t.Email, &prof.Email},
+		{"author_name", t.AuthorName, &prof.AuthorName},
+		{"ssh_key_path", t.SSHKeyPath, &prof.SSHKeyPath},
+		{"gpg_key_id", t.GPGKeyID, &prof.GPGKeyID},
+	} {
+		if f.tmpl == "" {
+			continue
+		}
+		rendered, err := renderField(f.key, f.tmpl, values)
+		if err != nil {
+			return Profile{}, err
+		}
+		*f.dst = rendered
+	}
+	if t.SigningRequired && prof.GPGKeyID == "" {
+		return Profile{}, errors.New("template requires a GPG key ID for signing")
+	}
+	return prof, nil
+}
+
+// RenderDirectoryPrefix fills in the directory prefix. It is empty when the
+// template has none.
+func (t *Template) RenderDirectoryPrefix(values map[string]string) (string, error) {
+	return renderField("directory_prefix", t.DirectoryPrefix, values)
+}
+
+func parseField(key, text string) (*template.Template, error) {
+	tmpl, err := template.New(key).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid template for %s: %w", key, err)
+	}
+	return tmpl, nil
+}
+
+// renderField executes a single field. A placeholder without a value, or with
+// an empty one, is reported by name.
+func renderField(key, text string, values map[string]string) (string, error) {
+	tmpl, err := parseField(key, text)
+	if err != nil {
+		return "", err
+	}
+	if tmpl.Tree != nil {
+		var missing string
+		collectFields(tmpl.Tree.Root, func(name string) {
+			if missing == "" && values[name] == "" {
+				missing = name
+			}
+		})
+		if missing != "" {
+			return "", utils.WithDetail(ErrMissingPlaceholder, "template placeholder {{ .%s }} in %s has no value", missing, key)
+		}
+	}
+
+	var out strings.Builder
+	if err := tmpl.Execute(&out, values); err != nil {
+		return "", fmt.Errorf("failed to render %s: %w", key, err)
+	}
+	return out.String(), nil
+}
+
+// collectFields calls fn with the first identifier of every .Field reference
+// under node.
+func collectFields(node parse.Node, fn func(string)) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			collectFields(child, fn)
+		}
+	case *parse.ActionNode:
+		collectFields(n.Pipe, fn)
+	case *parse.PipeNode:
+		if n == nil {
+			return
+		}
+		for _, cmd := range n.Cmds {
+			collectFields(cmd, fn)
+		}
+	case *parse.CommandNode:
+		for _, arg := range n.Args {
+			collectFields(arg, fn)
+		}
+	case *parse.FieldNode:
+		fn(n.Ident[0])
+	case *parse.IfNode:
+		collectBranch(&n.BranchNode, fn)
+	case *parse.RangeNode:
+		collectBranch(&n.BranchNode, fn)
+	case *parse.WithNode:
+		collectBranch(&n.BranchNode, fn)
+	}
+}
+
+func collectBranch(n *parse.BranchNode, fn func(string)) {
+	collectFields(n.Pipe, fn)
+	collectFields(n.List, fn)
+	collectFields(n.ElseList, fn)
+}
@@ -0,0 +1,132 @@
+package profile
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const corpTemplate = `
+email: "{{ .Username }}@corp.com"
+ssh_key_path: "~/.ssh/id_ed25519_{{ .Username }}"
+signing_required: true
+directory_prefix: "~/work/{{ .Team }}/"
+`
+
+func TestParseTemplate(t *testing.T) {
+	t.Parallel()
+	tmpl, err := ParseTemplate([]byte(corpTemplate))
+	if err != nil {
+		t.Fatalf("ParseTemplate() error = %v", err)
+	}
+	if !tmpl.SigningRequired || tmpl.Email == "" || tmpl.Name != "" {
+		t.Errorf("ParseTemplate() = %+v", tmpl)
+	}
+	if got, want := tmpl.Placeholders(), []string{"Username", "Team"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Placeholders() = %v, want %v", got, want)
+	}
+
+	tests := []struct {
+		name string
+		yaml string
+	}{
+		{"unknown key", "emial: a@b.c\n"},
+		{"bad placeholder", "email: \"{{ .Username @corp.com\"\n"},
+		{"not yaml", "email: [\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if _, err := ParseTemplate([]byte(tt.yaml)); err == nil {
+				t.Errorf("ParseTemplate(%q) should fail", tt.yaml)
+			}
+		})
+	}
+
+	if tmpl, err := ParseTemplate(nil); err != nil || len(tmpl.Placeholders()) != 0 {
+		t.Errorf("ParseTemplate(empty) = %+v, %v; want an empty template", tmpl, err)
+	}
+}
+
+func TestTemplateRender(t *testing.T) {
+	t.Parallel()
+	tmpl, err := ParseTemplate([]byte(corpTemplate))
+	if err != nil {
+		t.Fatalf("ParseTemplate() error = %v", err)
+	}
+	base := Profile{Name: "work", Email: "ignored@example.com", AuthorName: "Jane Doe", GPGKeyID: "ABC123"}
+
+	prof, err := tmpl.Render(map[string]string{"Username": "jdoe", "Team": "platform"}, base)
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+	want := Profile{Name: "work", Email: "jdoe@corp.com", AuthorName: "Jane Doe", SSHKeyPath: "~/.ssh/id_ed25519_jdoe", GPGKeyID: "ABC123"}
+	if !reflect.DeepEqual(prof, want) {
+		t.Errorf("Render() = %+v, want %+v", prof, want)
+	}
+	prefix, err := tmpl.RenderDirectoryPrefix(map[string]string{"Username": "jdoe", "Team": "platform"})
+	if err != nil || prefix != "~/work/platform/" {
+		t.Errorf("RenderDirectoryPrefix() = %q, %v; want ~/work/platform/", prefix, err)
+	}
+
+	tests := []struct {
+		name    string
+		values  map[string]string
+		base    Profile
+		wantErr string
+	}{
+		{"missing placeholder", map[string]string{}, base, "{{ .Username }} in email"},
+		{"empty placeholder", map[string]string{"Username": ""}, base, "{{ .Username }}"},
+		{"signing required", map[string]string{"Username": "jdoe"}, Profile{Name: "work"}, "GPG key"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			_, err := tmpl.Render(tt.values, tt.base)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Render() error = %v, want it to mention %q", err, tt.wantErr)
+			}
+		})
+	}
+	if _, err := tmpl.Render(map[string]string{}, base); !errors.Is(err, ErrMissingPlaceholder) {
+		t.Errorf("Render() error = %v, want ErrMissingPlaceholder", err)
+	}
+	if _, err := tmpl.RenderDirectoryPrefix(map[string]string{"Username": "jdoe"}); !errors.Is(err, ErrMissingPlaceholder) {
+		t.Errorf("RenderDirectoryPrefix() error = %v, want ErrMissingPlaceholder", err)
+	}
+}
+
+func TestLoadTemplate(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "corp.yaml")
+	if err := os.WriteFile(path, []byte(corpTemplate), 0644); err != nil {
+		t.Fatalf("Failed to write template: %v", err)
+	}
+	if tmpl, err := LoadTemplate(path); err != nil || tmpl.Email != "{{ .Username }}@corp.com" {
+		t.Errorf("LoadTemplate(file) = %+v, %v", tmpl, err)
+	}
+	if _, err := LoadTemplate(filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Error("LoadTemplate() of a missing file should fail")
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/corp.yaml" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(corpTemplate))
+	}))
+	defer server.Close()
+
+	if tmpl, err := LoadTemplate(server.URL + "/corp.yaml"); err != nil || !tmpl.SigningRequired {
+		t.Errorf("LoadTemplate(url) = %+v, %v", tmpl, err)
+	}
+	if _, err := LoadTemplate(server.URL + "/other.yaml"); err == nil || !strings.Contains(err.Error(), "404") {
+		t.Errorf("LoadTemplate() of a missing URL error = %v, want the status", err)
+	}
+}
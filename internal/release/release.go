@@ -0,0 +1,106 @@
+// Package release looks up published gidtree releases, for the version check
+// and for anything that updates the binary.
+package release
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// fetchLimit caps how much of the releases API response is read.
+const fetchLimit = 1 << 20
+
+// LatestURL is the releases API endpoint for the newest release. Replaced in tests.
+var LatestURL = "https://api.github.com/repos/thuanlegit/git-identitree/releases/latest"
+
+// client queries the releases API.
+var client = &http.Client{Timeout: 10 * time.Second}
+
+// Release is a published release.
+type Release struct {
+	// Version is the release tag, such as v1.4.0.
+	Version string `json:"tag_name"`
+	// URL is the release page.
+	URL string `json:"html_url"`
+}
+
+// Latest returns the newest published release.
+func Latest() (*Release, error) {
+	req, err := http.NewRequest(http.MethodGet, LatestURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check for releases: %w", err)
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("User-Agent", "gidtree")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check for releases: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to check for releases: %s returned %s", LatestURL, resp.Status)
+	}
+
+	var r Release
+	if err := json.NewDecoder(io.LimitReader(resp.Body, fetchLimit)).Decode(&r); err != nil {
+		return nil, fmt.Errorf("failed to read release: %w", err)
+	}
+	if r.Version == "" {
+		return nil, fmt.Errorf("failed to read release: no tag name")
+	}
+	return &r, nil
+}
+
+// Newer reports whether version latest is newer than current. Both are
+// semantic versions with an optional leading v; a pre-release is older than
+// its release. ok is false when either is not a version, as for a "dev" build.
+func Newer(current, latest string) (newer, ok bool) {
+	c, ok := parse(current)
+	if !ok {
+		return false, false
+	}
+	l, ok := parse(latest)
+	if !ok {
+		return false, false
+	}
+	for i := range c.core {
+		if l.core[i] != c.core[i] {
+			return l.core[i] > c.core[i], true
+		}
+	}
+	// Same core version: only a release is newer than a pre-release
+	return c.pre != "" && l.pre == "", true
+}
+
+// semver is the part of a semantic version Newer compares.
+type semver struct {
+	core [3]int
+	pre  string
+}
+
+// parse reads MAJOR.MINOR.PATCH with an optional leading v, pre-release and
+// build metadata. Pre-releases are not ordered among themselves.
+func parse(v string) (semver, bool) {
+	v = strings.TrimPrefix(v, "v")
+	v, _, _ = strings.Cut(v, "+")
+	v, pre, _ := strings.Cut(v, "-")
+	parts := strings.Split(v, ".")
+	if len(parts) != 3 {
+		return semver{}, false
+	}
+	s := semver{pre: pre}
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return semver{}, false
+		}
+		s.core[i] = n
+	}
+	return s, true
+}
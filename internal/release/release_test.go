@@ -0,0 +1,85 @@
+package release
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// serveLatest points LatestURL at a server answering with status and body.
+func serveLatest(t *testing.T, status int, body string) {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("User-Agent") == "" {
+			t.Error("request has no User-Agent")
+		}
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+	original := LatestURL
+	LatestURL = server.URL
+	t.Cleanup(func() { LatestURL = original })
+}
+
+func TestLatest(t *testing.T) {
+	serveLatest(t, http.StatusOK, `{"tag_name": "v1.4.0", "html_url": "https://example.com/v1.4.0", "name": "1.4.0"}`)
+
+	r, err := Latest()
+	if err != nil {
+		t.Fatalf("Latest() error = %v", err)
+	}
+	if r.Version != "v1.4.0" || r.URL != "https://example.com/v1.4.0" {
+		t.Errorf("Latest() = %+v, want v1.4.0", r)
+	}
+}
+
+func TestLatest_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		wantErr string
+	}{
+		{"not found", http.StatusNotFound, `{}`, "404"},
+		{"invalid JSON", http.StatusOK, `not json`, "failed to read release"},
+		{"no tag", http.StatusOK, `{"html_url": "https://example.com"}`, "no tag name"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			serveLatest(t, tt.status, tt.body)
+			_, err := Latest()
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Latest() error = %v, want it to mention %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNewer(t *testing.T) {
+	tests := []struct {
+		current   string
+		latest    string
+		wantNewer bool
+		wantOK    bool
+	}{
+		{"1.2.3", "v1.2.4", true, true},
+		{"v1.2.3", "v1.3.0", true, true},
+		{"1.2.3", "2.0.0", true, true},
+		{"1.2.3", "v1.2.3", false, true},
+		{"1.10.0", "v1.9.0", false, true},
+		{"1.3.0-beta.1", "v1.3.0", true, true},
+		{"1.3.0", "v1.3.0-beta.1", false, true},
+		{"1.2.3+build.5", "v1.2.3", false, true},
+		{"dev", "v1.2.3", false, false},
+		{"1.2.3", "latest", false, false},
+		{"1.2", "v1.2.3", false, false},
+	}
+	for _, tt := range tests {
+		newer, ok := Newer(tt.current, tt.latest)
+		if newer != tt.wantNewer || ok != tt.wantOK {
+			t.Errorf("Newer(%q, %q) = %v, %v, want %v, %v", tt.current, tt.latest, newer, ok, tt.wantNewer, tt.wantOK)
+		}
+	}
+}
@@ -0,0 +1,315 @@
+// Package signers maintains the allowed signers files git uses to verify
+// SSH-signed commits, one per profile, in the format described under
+// ALLOWED SIGNERS in ssh-keygen(1).
+package signers
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/thuanlegit/git-identitree/internal/profile"
+	"github.com/thuanlegit/git-identitree/internal/ssh"
+	"github.com/thuanlegit/git-identitree/internal/utils"
+)
+
+// filePrefix starts the name of every allowed signers file in ~/.gidtree.
+const filePrefix = "allowed_signers-"
+
+// gitNamespace limits a profile's own key to git signatures.
+const gitNamespace = `namespaces="git"`
+
+// ErrInvalidEntry is returned for a line that is not an allowed signers entry.
+var ErrInvalidEntry = errors.New("invalid allowed signers entry")
+
+// Entry is one line of an allowed signers file.
+type Entry struct {
+	// Principals are the emails the key may sign as, or patterns of them.
+	Principals []string
+	// Options such as namespaces="git", comma-separated, as written.
+	Options string
+	// KeyType is the key algorithm, such as ssh-ed25519.
+	KeyType string
+	// Key is the base64-encoded public key.
+	Key string
+}
+
+// Path returns ~/.gidtree/allowed_signers-<name>.
+func Path(profileName string) (string, error) {
+	dir, err := profile.GetProfilesDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, filePrefix+profileName), nil
+}
+
+// Parse reads an entry: principals, optional options, the key type and the
+// base64 key. Anything after the key, such as a comment, is ignored.
+func Parse(line string) (Entry, error) {
+	fields, err := splitFields(strings.TrimSpace(line))
+	if err != nil {
+		return Entry{}, utils.WithDetail(ErrInvalidEntry, "invalid allowed signers entry %q: %v", line, err)
+	}
+	if len(fields) < 3 {
+		return Entry{}, utils.WithDetail(ErrInvalidEntry, "invalid allowed signers entry %q: want principals [options] keytype key", line)
+	}
+
+	e := Entry{Principals: strings.Split(fields[0], ",")}
+	rest := fields[1:]
+	if !ssh.IsKeyType(rest[0]) {
+		e.Options = rest[0]
+		rest = rest[1:]
+	}
+	if len(rest) < 2 || !ssh.IsKeyType(rest[0]) {
+		return Entry{}, utils.WithDetail(ErrInvalidEntry, "invalid allowed signers entry %q: no key type such as ssh-ed25519", line)
+	}
+	e.KeyType, e.Key = rest[0], rest[1]
+	if _, err := base64.StdEncoding.DecodeString(e.Key); err != nil {
+		return Entry{}, utils.WithDetail(ErrInvalidEntry, "invalid allowed signers entry %q: key is not base64", line)
+	}
+	for _, p := range e.Principals {
+		if p == "" {
+			return Entry{}, utils.WithDetail(ErrInvalidEntry, "invalid allowed signers entry %q: empty principal", line)
+		}
+	}
+	return e, nil
+}
+
+// String formats the entry as a line of an allowed signers file.
+func (e Entry) String() string {
+	fields := []string{strings.Join(e.Principals, ",")}
+	if e.Options != "" {
+		fields = append(fields, e.Options)
+	}
+	return strings.Join(append(fields, e.KeyType, e.Key), " ")
+}
+
+// HasPrincipal reports whether email is one of the entry's principals,
+// ignoring case.
+func (e Entry) HasPrincipal(email string) bool {
+	for _, p := range e.Principals {
+		if strings.EqualFold(p, email) {
+			return true
+		}
+	}
+	return false
+}
+
+// SameKey reports whether two entries hold the same public key.
+func (e Entry) SameKey(other Entry) bool {
+	return e.KeyType == other.KeyType && e.Key == other.Key
+}
+
+// List returns the entries of a profile's allowed signers file; none when it
+// does not exist.
+func List(profileName string) ([]Entry, error) {
+	path, err := Path(profileName)
+	if err != nil {
+		return nil, err
+	}
+	lines, err := readLines(path)
+	if err != nil {
+		return nil, err
+	}
+	var entries []Entry
+	for i, line := range lines {
+		if isComment(line) {
+			continue
+		}
+		e, err := Parse(line)
+		if err != nil {
+			return nil, fmt.Errorf("%s line %d: %w", path, i+1, err)
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// Add adds line to a profile's allowed signers file, in normalized form. It
+// reports false when the same principals already have that key.
+func Add(profileName, line string) (bool, error) {
+	e, err := Parse(line)
+	if err != nil {
+		return false, err
+	}
+	return addEntry(profileName, e)
+}
+
+// Remove removes every entry of a profile's allowed signers file that has
+// match as a principal, or whose "keytype key" is match. Comments and other
+// lines are kept. It returns the number of entries removed.
+func Remove(profileName, match string) (int, error) {
+	path, err := Path(profileName)
+	if err != nil {
+		return 0, err
+	}
+	lines, err := readLines(path)
+	if err != nil {
+		return 0, err
+	}
+
+	var kept []string
+	removed := 0
+	for _, line := range lines {
+		if e, err := Parse(line); err == nil && !isComment(line) {
+			if e.HasPrincipal(match) || e.KeyType+" "+e.Key == strings.Join(strings.Fields(match), " ") {
+				removed++
+				continue
+			}
+		}
+		kept = append(kept, line)
+	}
+	if removed == 0 {
+		return 0, nil
+	}
+	return removed, writeLines(path, kept)
+}
+
+// Seed makes sure the profile's allowed signers file lets its own key sign as
+// its email and aliases, reading the key from the .pub file next to the
+// private key. Other entries are left alone, so keys rotated out still verify
+// old commits. It returns the file's path.
+func Seed(prof *profile.Profile) (string, error) {
+	path, err := Path(prof.Name)
+	if err != nil {
+		return "", err
+	}
+	pubPath := prof.SSHPublicKeyPath()
+	data, err := os.ReadFile(pubPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read SSH public key: %w", err)
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) < 2 || !ssh.IsKeyType(fields[0]) {
+		return "", fmt.Errorf("%s is not an SSH public key", pubPath)
+	}
+
+	var principals []string
+	for _, email := range prof.Emails() {
+		if email != "" {
+			principals = append(principals, email)
+		}
+	}
+	own := Entry{Principals: principals, Options: gitNamespace, KeyType: fields[0], Key: fields[1]}
+	if _, err := addEntry(prof.Name, own); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// Rename moves a profile's allowed signers file along with the profile.
+// Nothing happens when the profile has none.
+func Rename(oldName, newName string) error {
+	oldPath, err := Path(oldName)
+	if err != nil {
+		return err
+	}
+	newPath, err := Path(newName)
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(oldPath, newPath); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to rename allowed signers file: %w", err)
+	}
+	return nil
+}
+
+// addEntry appends e unless an entry with the same key already covers all of
+// its principals.
+func addEntry(profileName string, e Entry) (bool, error) {
+	path, err := Path(profileName)
+	if err != nil {
+		return false, err
+	}
+	lines, err := readLines(path)
+	if err != nil {
+		return false, err
+	}
+	for _, line := range lines {
+		existing, err := Parse(line)
+		if err != nil || isComment(line) || !existing.SameKey(e) {
+			continue
+		}
+		covered := true
+		for _, p := range e.Principals {
+			if !existing.HasPrincipal(p) {
+				covered = false
+				break
+			}
+		}
+		if covered {
+			return false, nil
+		}
+	}
+	return true, writeLines(path, append(lines, e.String()))
+}
+
+// readLines returns the lines of the file at path, without blank trailing
+// ones; none when it does not exist.
+func readLines(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read allowed signers file: %w", err)
+	}
+	content := strings.TrimRight(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n")
+	if content == "" {
+		return nil, nil
+	}
+	return strings.Split(content, "\n"), nil
+}
+
+// writeLines writes lines to path, creating ~/.gidtree when needed.
+func writeLines(path string, lines []string) error {
+	if err := os.MkdirAll(filepath.Dir(path), utils.PrivateDirMode); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+	content := ""
+	if len(lines) > 0 {
+		content = strings.Join(lines, "\n") + "\n"
+	}
+	if err := os.WriteFile(path, []byte(content), utils.PrivateFileMode); err != nil {
+		return fmt.Errorf("failed to write allowed signers file: %w", err)
+	}
+	return nil
+}
+
+// isComment reports whether line is blank or a comment, which ssh-keygen skips.
+func isComment(line string) bool {
+	line = strings.TrimSpace(line)
+	return line == "" || strings.HasPrefix(line, "#")
+}
+
+// splitFields splits line on whitespace outside double quotes, as in
+// namespaces="git,file" valid-after="20240101".
+func splitFields(line string) ([]string, error) {
+	var fields []string
+	var b strings.Builder
+	quoted := false
+	for _, r := range line {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			b.WriteRune(r)
+		case (r == ' ' || r == '\t') && !quoted:
+			if b.Len() > 0 {
+				fields = append(fields, b.String())
+				b.Reset()
+			}
+		default:
+			b.WriteRune(r)
+		}
+	}
+	if quoted {
+		return nil, errors.New("unterminated quote")
+	}
+	if b.Len() > 0 {
+		fields = append(fields, b.String())
+	}
+	return fields, nil
+}
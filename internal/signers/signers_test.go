@@ -0,0 +1,227 @@
+package signers
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/thuanlegit/git-identitree/internal/profile"
+	"github.com/thuanlegit/git-identitree/internal/utils"
+)
+
+const (
+	adaKey  = "AAAAC3NzaC1lZDI1NTE5AAAAIK2tra2tra2tra2tra2tra2tra2tra2tra2tra2tra2t"
+	alanKey = "AAAAC3NzaC1lZDI1NTE5AAAAIKGhoaGhoaGhoaGhoaGhoaGhoaGhoaGhoaGhoaGhoaGh"
+)
+
+// setupHome points HOME at a temporary directory and returns it.
+func setupHome(t *testing.T) string {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv(utils.RootEnv, home)
+	t.Setenv("XDG_CONFIG_HOME", "")
+	return home
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		line    string
+		want    Entry
+		wantErr bool
+	}{
+		{
+			name: "principal and key",
+			line: "ada@example.com ssh-ed25519 " + adaKey,
+			want: Entry{Principals: []string{"ada@example.com"}, KeyType: "ssh-ed25519", Key: adaKey},
+		},
+		{
+			name: "principals, quoted options and a comment",
+			line: `  ada@example.com,ada@home.example namespaces="git,file" ssh-ed25519 ` + adaKey + " ada@laptop ",
+			want: Entry{Principals: []string{"ada@example.com", "ada@home.example"}, Options: `namespaces="git,file"`, KeyType: "ssh-ed25519", Key: adaKey},
+		},
+		{name: "no key", line: "ada@example.com ssh-ed25519", wantErr: true},
+		{name: "no key type", line: "ada@example.com " + adaKey + " more", wantErr: true},
+		{name: "key is not base64", line: "ada@example.com ssh-ed25519 not-base64!", wantErr: true},
+		{name: "empty principal", line: "ada@example.com, ssh-ed25519 " + adaKey, wantErr: true},
+		{name: "unterminated quote", line: `ada@example.com namespaces="git ssh-ed25519 ` + adaKey, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(tt.line)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidEntry) {
+					t.Errorf("Parse() error = %v, want ErrInvalidEntry", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEntry_String(t *testing.T) {
+	e := Entry{Principals: []string{"a@example.com", "b@example.com"}, Options: `namespaces="git"`, KeyType: "ssh-ed25519", Key: adaKey}
+	want := `a@example.com,b@example.com namespaces="git" ssh-ed25519 ` + adaKey
+	if got := e.String(); got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+	e.Options = ""
+	if got := e.String(); got != "a@example.com,b@example.com ssh-ed25519 "+adaKey {
+		t.Errorf("String() without options = %q", got)
+	}
+}
+
+func TestAddListRemove(t *testing.T) {
+	setupHome(t)
+
+	entries, err := List("work")
+	if err != nil || len(entries) != 0 {
+		t.Fatalf("List() = %v, %v, want no entries without a file", entries, err)
+	}
+
+	ada := "ada@example.com ssh-ed25519 " + adaKey
+	alan := "alan@example.com ssh-ed25519 " + alanKey
+	for _, line := range []string{ada, alan} {
+		added, err := Add("work", line)
+		if err != nil || !added {
+			t.Fatalf("Add(%q) = %v, %v", line, added, err)
+		}
+	}
+	if added, err := Add("work", "ADA@example.com   ssh-ed25519 "+adaKey+" comment"); err != nil || added {
+		t.Errorf("Add() of a known entry = %v, %v, want false", added, err)
+	}
+	if _, err := Add("work", "ada@example.com"); !errors.Is(err, ErrInvalidEntry) {
+		t.Errorf("Add() error = %v, want ErrInvalidEntry", err)
+	}
+
+	entries, err = List("work")
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(entries) != 2 || entries[0].String() != ada || entries[1].String() != alan {
+		t.Errorf("List() = %v, want Ada and Alan", entries)
+	}
+
+	path, err := Path("work")
+	if err != nil {
+		t.Fatalf("Path() error = %v", err)
+	}
+	if info, err := os.Stat(path); err != nil {
+		t.Fatalf("Stat() error = %v", err)
+	} else if info.Mode().Perm() != 0600 && os.PathSeparator == '/' {
+		t.Errorf("allowed signers file mode = %v, want 0600", info.Mode().Perm())
+	}
+
+	// Comments survive a removal
+	content, _ := os.ReadFile(path)
+	if err := os.WriteFile(path, append([]byte("# team keys\n"), content...), 0600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	if removed, err := Remove("work", "Ada@Example.com"); err != nil || removed != 1 {
+		t.Fatalf("Remove() by email = %d, %v, want 1", removed, err)
+	}
+	if removed, err := Remove("work", "ssh-ed25519  "+alanKey); err != nil || removed != 1 {
+		t.Fatalf("Remove() by key = %d, %v, want 1", removed, err)
+	}
+	if removed, err := Remove("work", "nobody@example.com"); err != nil || removed != 0 {
+		t.Errorf("Remove() of an unknown email = %d, %v, want 0", removed, err)
+	}
+	content, err = os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	if string(content) != "# team keys\n" {
+		t.Errorf("allowed signers file = %q, want only the comment", content)
+	}
+}
+
+func TestList_InvalidLine(t *testing.T) {
+	setupHome(t)
+	path, err := Path("work")
+	if err != nil {
+		t.Fatalf("Path() error = %v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		t.Fatalf("MkdirAll() error = %v", err)
+	}
+	if err := os.WriteFile(path, []byte("\n# keys\ngarbage\n"), 0600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	if _, err := List("work"); !errors.Is(err, ErrInvalidEntry) || !strings.Contains(err.Error(), "line 3") {
+		t.Errorf("List() error = %v, want ErrInvalidEntry on line 3", err)
+	}
+}
+
+func TestSeed(t *testing.T) {
+	home := setupHome(t)
+	keyPath := filepath.Join(home, ".ssh", "id_work")
+	if err := os.MkdirAll(filepath.Dir(keyPath), 0700); err != nil {
+		t.Fatalf("MkdirAll() error = %v", err)
+	}
+	if err := os.WriteFile(keyPath+".pub", []byte("ssh-ed25519 "+adaKey+" ada@laptop\n"), 0644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	prof := &profile.Profile{Name: "work", Email: "ada@example.com", EmailAliases: []string{"ada@users.example"}, SSHKeyPath: keyPath, SSHSigning: true}
+
+	if _, err := Add("work", "alan@example.com ssh-ed25519 "+alanKey); err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+	path, err := Seed(prof)
+	if err != nil {
+		t.Fatalf("Seed() error = %v", err)
+	}
+	// Seeding again changes nothing
+	if _, err := Seed(prof); err != nil {
+		t.Fatalf("Seed() error = %v", err)
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	want := "alan@example.com ssh-ed25519 " + alanKey + "\n" +
+		`ada@example.com,ada@users.example namespaces="git" ssh-ed25519 ` + adaKey + "\n"
+	if string(content) != want {
+		t.Errorf("allowed signers file = %q, want %q", content, want)
+	}
+
+	if err := os.WriteFile(keyPath+".pub", []byte("not a key"), 0644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	if _, err := Seed(prof); err == nil {
+		t.Error("Seed() should fail for a file that is not a public key")
+	}
+	prof.SSHKeyPath = filepath.Join(home, ".ssh", "missing")
+	if _, err := Seed(prof); err == nil {
+		t.Error("Seed() should fail without a public key")
+	}
+}
+
+func TestRename(t *testing.T) {
+	setupHome(t)
+	if err := Rename("old", "new"); err != nil {
+		t.Errorf("Rename() without a file error = %v", err)
+	}
+
+	line := "ada@example.com ssh-ed25519 " + adaKey
+	if _, err := Add("old", line); err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+	if err := Rename("old", "new"); err != nil {
+		t.Fatalf("Rename() error = %v", err)
+	}
+	if entries, err := List("old"); err != nil || len(entries) != 0 {
+		t.Errorf("List(old) = %v, %v, want none", entries, err)
+	}
+	if entries, err := List("new"); err != nil || len(entries) != 1 || entries[0].String() != line {
+		t.Errorf("List(new) = %v, %v, want the entry", entries, err)
+	}
+}
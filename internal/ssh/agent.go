@@ -1,17 +1,47 @@
 package ssh
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
+	"runtime"
 	"strings"
 
+	"github.com/thuanlegit/git-identitree/internal/logging"
 	"github.com/thuanlegit/git-identitree/internal/profile"
 	"github.com/thuanlegit/git-identitree/internal/utils"
 )
 
+// LoadOptions controls how LoadKeyWithOptions adds a key to the agent.
+type LoadOptions struct {
+	// UseKeychain stores the key's passphrase in the macOS keychain. It is
+	// ignored on other platforms, whose ssh-add has no keychain support.
+	UseKeychain bool
+	// StrictPermissions fails with ErrKeyPermissions instead of warning when
+	// group or others can access the key file; IgnorePermissions does
+	// neither.
+	StrictPermissions bool
+	IgnorePermissions bool
+	// Warnings receives warnings about the key file; nil discards them.
+	Warnings io.Writer
+}
+
 // LoadKey adds an SSH key to the SSH agent.
 func LoadKey(keyPath string) error {
+	return LoadKeyWithOptions(keyPath, LoadOptions{})
+}
+
+// LoadKeyWithOptions adds an SSH key to the SSH agent with the given options.
+// In offline mode it only warns.
+func LoadKeyWithOptions(keyPath string, opts LoadOptions) error {
+	if Offline {
+		warnOffline("loading SSH key " + keyPath)
+		return nil
+	}
+
 	// Normalize key path
 	normalized, err := utils.NormalizePath(keyPath)
 	if err != nil {
@@ -20,7 +50,14 @@ func LoadKey(keyPath string) error {
 
 	// Check if key exists
 	if _, err := os.Stat(normalized); os.IsNotExist(err) {
-		return fmt.Errorf("SSH key does not exist: %s", normalized)
+		return utils.WithDetail(profile.ErrSSHKeyMissing, "SSH key does not exist: %s", normalized)
+	}
+	if err := checkKeyBeforeLoad(normalized, opts); err != nil {
+		return err
+	}
+	// Fail fast rather than leave ssh-add waiting on a dead agent
+	if err := CheckAgent(); err != nil {
+		return err
 	}
 
 	// Check if key is already loaded
@@ -33,16 +70,98 @@ func LoadKey(keyPath string) error {
 	}
 
 	// Add key to agent
-	cmd := exec.Command("ssh-add", normalized)
-	if err := cmd.Run(); err != nil {
+	cmd, err := command(context.Background(), sshAdd, sshAddArgs(normalized, opts, runtime.GOOS)...)
+	if err != nil {
+		return err
+	}
+	err = cmd.Run()
+	logging.Command(cmd, err)
+	if err != nil {
 		return fmt.Errorf("failed to add SSH key to agent: %w", err)
 	}
 
 	return nil
 }
 
-// UnloadKey removes an SSH key from the SSH agent.
+// checkKeyBeforeLoad fails when the file at path is not a private key, and
+// warns or fails, as opts say, when others can access it.
+func checkKeyBeforeLoad(path string, opts LoadOptions) error {
+	exposed, err := CheckKeyFile(path)
+	if err != nil || !exposed || opts.IgnorePermissions {
+		return err
+	}
+	mode := "unknown"
+	if info, err := os.Stat(path); err == nil {
+		mode = fmt.Sprintf("%04o", info.Mode().Perm())
+	}
+	shown := utils.AbbreviateHome(path)
+	if opts.StrictPermissions {
+		return utils.WithDetail(ErrKeyPermissions, "SSH key %s can be accessed by others (mode %s); run: chmod 600 %s", shown, mode, shown)
+	}
+	if opts.Warnings != nil {
+		_, _ = fmt.Fprintf(opts.Warnings, "⚠ SSH key %s can be accessed by others (mode %s), so ssh-add may refuse it\n  Run: chmod 600 %s\n", shown, mode, shown)
+	}
+	return nil
+}
+
+// sshAddArgs returns the ssh-add arguments that load the key at path on goos.
+func sshAddArgs(path string, opts LoadOptions, goos string) []string {
+	if opts.UseKeychain && goos == "darwin" {
+		return []string{"--apple-use-keychain", path}
+	}
+	return []string{path}
+}
+
+// UnloadOtherKeys removes from the agent the loaded keys of every profile whose
+// key is not keep. Profiles whose key cannot be checked are skipped. It fails
+// with ErrAgentReadOnly when such a key is loaded in an agent that cannot
+// remove keys. In offline mode it only warns.
+func UnloadOtherKeys(keep string, profiles []profile.Profile) error {
+	if Offline {
+		warnOffline("unloading other profiles' SSH keys")
+		return nil
+	}
+	keepNormalized, err := utils.NormalizePath(keep)
+	if err != nil {
+		return fmt.Errorf("failed to normalize key path: %w", err)
+	}
+	if err := CheckAgent(); err != nil {
+		return err
+	}
+	agent := DetectAgent()
+
+	for _, p := range profiles {
+		if p.SSHKeyPath == "" {
+			continue
+		}
+		normalized, err := utils.NormalizePath(p.SSHKeyPath)
+		if err != nil || normalized == keepNormalized {
+			continue
+		}
+		loaded, err := CheckKeyLoaded(normalized)
+		if err != nil || !loaded {
+			continue
+		}
+		if !agent.CanRemoveKeys() {
+			return agent.readOnlyError()
+		}
+		if err := UnloadKey(normalized); err != nil {
+			return fmt.Errorf("failed to unload SSH key of profile '%s': %w", p.Name, err)
+		}
+	}
+	return nil
+}
+
+// UnloadKey removes an SSH key from the SSH agent. It fails with
+// ErrAgentReadOnly, without running ssh-add, when the agent is one that
+// cannot remove keys, where ssh-add -d would fail or do nothing. In offline
+// mode it only warns.
 func UnloadKey(keyPath string) error {
+	if Offline {
+		warnOffline("unloading SSH key " + keyPath)
+		return nil
+	}
+
 	// Normalize key path
 	normalized, err := utils.NormalizePath(keyPath)
 	if err != nil {
@@ -50,8 +169,12 @@ func UnloadKey(keyPath string) error {
 	}
 
 	// Get key fingerprint to identify it in the agent
-	cmd := exec.Command("ssh-keygen", "-lf", normalized)
+	cmd, err := command(context.Background(), sshKeygen, "-lf", normalized)
+	if err != nil {
+		return err
+	}
 	output, err := cmd.Output()
+	logging.Command(cmd, err)
 	if err != nil {
 		return fmt.Errorf("failed to get key fingerprint: %w", err)
 	}
@@ -63,12 +186,21 @@ func UnloadKey(keyPath string) error {
 	}
 	fingerprint := fields[1]
 
+	if err := CheckAgent(); err != nil {
+		return err
+	}
+	if agent := DetectAgent(); !agent.CanRemoveKeys() {
+		return agent.readOnlyError()
+	}
+
 	// Remove key by fingerprint
-	cmd = exec.Command("ssh-add", "-d", fingerprint)
-	if err := cmd.Run(); err != nil {
+	_, err = agentCommand("-d", fingerprint)
+	if errors.Is(err, ErrAgentUnreachable) {
+		return err
+	}
+	if err != nil {
 		// Try removing by path as fallback
-		cmd = exec.Command("ssh-add", "-d", normalized)
-		if err := cmd.Run(); err != nil {
+		if _, err := agentCommand("-d", normalized); err != nil {
 			return fmt.Errorf("failed to remove SSH key from agent: %w", err)
 		}
 	}
@@ -76,8 +208,13 @@ func UnloadKey(keyPath string) error {
 	return nil
 }
 
-// CheckKeyLoaded verifies if an SSH key is loaded in the agent.
+// CheckKeyLoaded verifies if an SSH key is loaded in the agent. It fails with
+// ErrAgentUnreachable when the agent does not answer in time, and with
+// ErrOffline in offline mode.
 func CheckKeyLoaded(keyPath string) (bool, error) {
+	if Offline {
+		return false, offlineError()
+	}
 	// Normalize key path
 	normalized, err := utils.NormalizePath(keyPath)
 	if err != nil {
@@ -85,8 +222,12 @@ func CheckKeyLoaded(keyPath string) (bool, error) {
 	}
 
 	// Get key fingerprint
-	cmd := exec.Command("ssh-keygen", "-lf", normalized)
+	cmd, err := command(context.Background(), sshKeygen, "-lf", normalized)
+	if err != nil {
+		return false, err
+	}
 	output, err := cmd.Output()
+	logging.Command(cmd, err)
 	if err != nil {
 		return false, fmt.Errorf("failed to get key fingerprint: %w", err)
 	}
@@ -99,8 +240,13 @@ func CheckKeyLoaded(keyPath string) (bool, error) {
 	fingerprint := fields[1]
 
 	// List keys in agent
-	cmd = exec.Command("ssh-add", "-l")
-	output, err = cmd.Output()
+	if err := CheckAgent(); err != nil {
+		return false, err
+	}
+	output, err = agentCommand("-l")
+	if errors.Is(err, ErrAgentUnreachable) {
+		return false, err
+	}
 	if err != nil {
 		// SSH agent might not be running
 		return false, nil
@@ -110,6 +256,21 @@ func CheckKeyLoaded(keyPath string) (bool, error) {
 	return strings.Contains(string(output), fingerprint), nil
 }
 
+// AgentReachable reports whether ssh-add can talk to an SSH agent. ssh-add -l
+// exits 1 for an agent without keys and 2 when it cannot connect. In offline
+// mode the agent is not asked and counts as unreachable.
+func AgentReachable() bool {
+	if Offline || CheckAgent() != nil {
+		return false
+	}
+	_, err := agentCommand("-l")
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode() == 1
+	}
+	return err == nil
+}
+
 // LoadKeyForProfile loads the SSH key for a profile if it has one.
 func LoadKeyForProfile(prof *profile.Profile) error {
 	if prof.SSHKeyPath == "" {
@@ -1,11 +1,14 @@
 package ssh
 
 import (
+	"errors"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/thuanlegit/git-identitree/internal/profile"
+	"github.com/thuanlegit/git-identitree/internal/utils"
 )
 
 func TestLoadKeyForProfile(t *testing.T) {
@@ -107,11 +110,9 @@ func TestAutoLoadForDirectory(t *testing.T) {
 }
 
 func TestLoadKey_NormalizeError(t *testing.T) {
-	// Test with invalid path that causes normalization error
-	// Using a path that might cause issues
 	err := LoadKey("")
-	if err == nil {
-		t.Error("LoadKey() should fail for empty path")
+	if !errors.Is(err, utils.ErrEmptyPath) {
+		t.Errorf("LoadKey(\"\") error = %v, want ErrEmptyPath", err)
 	}
 }
 
@@ -143,8 +144,8 @@ func TestLoadKey_AlreadyLoaded(t *testing.T) {
 
 func TestUnloadKey_NormalizeError(t *testing.T) {
 	err := UnloadKey("")
-	if err == nil {
-		t.Error("UnloadKey() should fail for empty path")
+	if !errors.Is(err, utils.ErrEmptyPath) {
+		t.Errorf("UnloadKey(\"\") error = %v, want ErrEmptyPath", err)
 	}
 }
 
@@ -174,8 +175,8 @@ func TestUnloadKey_FallbackPath(t *testing.T) {
 
 func TestCheckKeyLoaded_NormalizeError(t *testing.T) {
 	_, err := CheckKeyLoaded("")
-	if err == nil {
-		t.Error("CheckKeyLoaded() should fail for empty path")
+	if !errors.Is(err, utils.ErrEmptyPath) {
+		t.Errorf("CheckKeyLoaded(\"\") error = %v, want ErrEmptyPath", err)
 	}
 }
 
@@ -325,3 +326,42 @@ func TestUnloadKey_InvalidFingerprintFormat(t *testing.T) {
 	}
 }
 
+
+func TestSSHAddArgs(t *testing.T) {
+	tests := []struct {
+		name string
+		opts LoadOptions
+		goos string
+		want []string
+	}{
+		{name: "plain", goos: "linux", want: []string{"/k"}},
+		{name: "keychain on macOS", opts: LoadOptions{UseKeychain: true}, goos: "darwin", want: []string{"--apple-use-keychain", "/k"}},
+		{name: "keychain elsewhere", opts: LoadOptions{UseKeychain: true}, goos: "linux", want: []string{"/k"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := sshAddArgs("/k", tt.opts, tt.goos)
+			if strings.Join(got, " ") != strings.Join(tt.want, " ") {
+				t.Errorf("sshAddArgs() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnloadOtherKeys_SkipsUncheckableKeys(t *testing.T) {
+	profiles := []profile.Profile{
+		{Name: "keep", SSHKeyPath: "/nonexistent/keep"},
+		{Name: "missing", SSHKeyPath: "/nonexistent/other"},
+		{Name: "none"},
+	}
+	if err := UnloadOtherKeys("/nonexistent/keep", profiles); err != nil {
+		t.Errorf("UnloadOtherKeys() error = %v, want missing keys skipped", err)
+	}
+}
+
+func TestAgentReachable_NoAgent(t *testing.T) {
+	t.Setenv("SSH_AUTH_SOCK", filepath.Join(t.TempDir(), "no-agent.sock"))
+	if AgentReachable() {
+		t.Error("AgentReachable() = true with SSH_AUTH_SOCK pointing nowhere")
+	}
+}
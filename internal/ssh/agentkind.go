@@ -0,0 +1,181 @@
+package ssh
+
+import (
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/thuanlegit/git-identitree/internal/utils"
+
+	gossh "golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// ErrAgentReadOnly is returned when removing a key from an agent that does
+// not support it, such as 1Password or gpg-agent.
+var ErrAgentReadOnly = errors.New("SSH agent cannot remove keys")
+
+// AgentKind is the program serving the SSH agent at SSH_AUTH_SOCK.
+type AgentKind string
+
+const (
+	// AgentUnknown is an agent DetectAgent does not recognize.
+	AgentUnknown AgentKind = "unknown"
+	// AgentOpenSSH is OpenSSH's ssh-agent, including the one launchd starts
+	// on macOS and the Windows service.
+	AgentOpenSSH AgentKind = "openssh"
+	// AgentGnomeKeyring is the SSH agent of GNOME Keyring.
+	AgentGnomeKeyring AgentKind = "gnome-keyring"
+	// AgentGPG is gpg-agent with enable-ssh-support.
+	AgentGPG AgentKind = "gpg-agent"
+	// Agent1Password is the SSH agent of 1Password.
+	Agent1Password AgentKind = "1password"
+	// AgentSecretive is Secretive, which keeps keys in the Secure Enclave.
+	AgentSecretive AgentKind = "secretive"
+	// AgentBitwarden is the SSH agent of Bitwarden.
+	AgentBitwarden AgentKind = "bitwarden"
+)
+
+// agentKinds describes the known agents, the more specific first: the
+// substrings of the lower-cased socket path that mark them, the domain in
+// the names of their own protocol extensions, and whether ssh-add -d can
+// remove keys from them. Password managers only hand out the keys of their
+// vault, and gpg-agent keeps its keys in sshcontrol.
+var agentKinds = []struct {
+	kind        AgentKind
+	name        string
+	socketHints []string
+	domain      string
+	readOnly    bool
+}{
+	{kind: Agent1Password, name: "1Password", socketHints: []string{"1password"}, readOnly: true},
+	{kind: AgentSecretive, name: "Secretive", socketHints: []string{"secretive"}, readOnly: true},
+	{kind: AgentBitwarden, name: "Bitwarden", socketHints: []string{"bitwarden"}, readOnly: true},
+	{kind: AgentGPG, name: "gpg-agent", socketHints: []string{"s.gpg-agent.ssh"}, domain: "gnupg.org", readOnly: true},
+	{kind: AgentGnomeKeyring, name: "GNOME Keyring", socketHints: []string{"/keyring/ssh", "/gcr/ssh"}},
+	{kind: AgentOpenSSH, name: "OpenSSH ssh-agent", socketHints: []string{"/ssh-", "com.apple.launchd.", "openssh-ssh-agent"}, domain: "openssh.com"},
+}
+
+// Agent is the SSH agent SSH_AUTH_SOCK points at.
+type Agent struct {
+	// Socket is SSH_AUTH_SOCK; it is empty when the variable is not set.
+	Socket string
+	Kind   AgentKind
+}
+
+// Name returns the name of the agent program, as shown to the user.
+func (a Agent) Name() string {
+	for _, k := range agentKinds {
+		if k.kind == a.Kind {
+			return k.name
+		}
+	}
+	return "unknown agent"
+}
+
+// CanRemoveKeys reports whether ssh-add -d works with the agent. Unknown
+// agents are assumed to support it.
+func (a Agent) CanRemoveKeys() bool {
+	for _, k := range agentKinds {
+		if k.kind == a.Kind {
+			return !k.readOnly
+		}
+	}
+	return true
+}
+
+// readOnlyError returns ErrAgentReadOnly explaining why that is harmless.
+func (a Agent) readOnlyError() error {
+	return utils.WithDetail(ErrAgentReadOnly, "the SSH agent is %s, which does not let ssh-add remove keys; git still offers only each profile's key, as its sshCommand sets IdentitiesOnly", a.Name())
+}
+
+// DetectAgent tells which agent SSH_AUTH_SOCK points at, from the socket
+// path and the path it links to or, failing that, from the extensions the
+// agent lists when asked, within AgentTimeout. In offline mode the agent is
+// not asked.
+func DetectAgent() Agent {
+	socket := os.Getenv("SSH_AUTH_SOCK")
+	if socket == "" {
+		return Agent{Kind: AgentUnknown}
+	}
+	a := Agent{Socket: socket, Kind: kindFromSocket(socket)}
+	if a.Kind == AgentUnknown && runtime.GOOS != "windows" && !Offline {
+		if names, err := queryExtensions(socket, AgentTimeout); err == nil {
+			a.Kind = kindFromExtensions(names)
+		}
+	}
+	return a
+}
+
+// kindFromSocket recognizes an agent from its socket path, or the path the
+// socket links to, as when ~/.ssh/agent.sock points at 1Password's socket.
+func kindFromSocket(socket string) AgentKind {
+	paths := []string{socket}
+	if resolved, err := filepath.EvalSymlinks(socket); err == nil && resolved != socket {
+		paths = append(paths, resolved)
+	}
+	for _, k := range agentKinds {
+		for _, path := range paths {
+			path = strings.ToLower(filepath.ToSlash(path))
+			for _, hint := range k.socketHints {
+				if strings.Contains(path, hint) {
+					return k.kind
+				}
+			}
+		}
+	}
+	return AgentUnknown
+}
+
+// kindFromExtensions recognizes an agent from the names of the extensions it
+// supports, such as ssh-env-names@gnupg.org. Agents also list extensions of
+// others, OpenSSH's in particular, so a more specific domain wins.
+func kindFromExtensions(names []string) AgentKind {
+	for _, k := range agentKinds {
+		for _, name := range names {
+			if _, domain, ok := strings.Cut(name, "@"); ok && k.domain != "" && domain == k.domain {
+				return k.kind
+			}
+		}
+	}
+	return AgentUnknown
+}
+
+// queryExtensions asks the agent at socket for the extensions it supports
+// with the query extension. The answer is SSH_AGENT_SUCCESS, or
+// SSH_AGENT_EXTENSION_RESPONSE in later drafts of the protocol, followed by
+// the names.
+func queryExtensions(socket string, timeout time.Duration) ([]string, error) {
+	conn, err := net.DialTimeout("unix", socket, timeout)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = conn.Close() }()
+	_ = conn.SetDeadline(time.Now().Add(timeout))
+
+	response, err := agent.NewClient(conn).Extension("query", nil)
+	if err != nil {
+		return nil, err
+	}
+	if response[0] != 6 && response[0] != 29 {
+		return nil, errors.New("unexpected answer to query extension")
+	}
+	var names []string
+	rest := response[1:]
+	for len(rest) > 0 {
+		var name struct {
+			Name string
+			Rest []byte `ssh:"rest"`
+		}
+		if err := gossh.Unmarshal(rest, &name); err != nil {
+			return nil, err
+		}
+		names = append(names, name.Name)
+		rest = name.Rest
+	}
+	return names, nil
+}
@@ -0,0 +1,202 @@
+package ssh
+
+import (
+	"errors"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/thuanlegit/git-identitree/internal/profile"
+
+	gossh "golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// queryAgent is an in-memory agent that answers the query extension with
+// extensions, or does not support it when extensions is nil.
+type queryAgent struct {
+	agent.ExtendedAgent
+	extensions []string
+}
+
+func (a queryAgent) Extension(extensionType string, contents []byte) ([]byte, error) {
+	if extensionType != "query" || a.extensions == nil {
+		return nil, agent.ErrExtensionUnsupported
+	}
+	response := []byte{6}
+	for _, name := range a.extensions {
+		response = append(response, gossh.Marshal(struct{ Name string }{name})...)
+	}
+	return response, nil
+}
+
+// serveAgent serves a on a Unix socket at name, under a new temporary
+// directory, and points SSH_AUTH_SOCK at it.
+func serveAgent(t *testing.T, name string, a agent.Agent) string {
+	t.Helper()
+	listener := listenAgent(t, name)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer func() { _ = conn.Close() }()
+				_ = agent.ServeAgent(a, conn)
+			}(conn)
+		}
+	}()
+	return listener.Addr().String()
+}
+
+// newQueryAgent returns a queryAgent holding the keys at paths.
+func newQueryAgent(t *testing.T, extensions []string, paths ...string) queryAgent {
+	t.Helper()
+	keyring := agent.NewKeyring().(agent.ExtendedAgent)
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("ReadFile() error = %v", err)
+		}
+		key, err := gossh.ParseRawPrivateKey(data)
+		if err != nil {
+			t.Fatalf("ParseRawPrivateKey() error = %v", err)
+		}
+		if err := keyring.Add(agent.AddedKey{PrivateKey: key}); err != nil {
+			t.Fatalf("Add() error = %v", err)
+		}
+	}
+	return queryAgent{ExtendedAgent: keyring, extensions: extensions}
+}
+
+func TestKindFromSocket(t *testing.T) {
+	tests := []struct {
+		socket string
+		want   AgentKind
+	}{
+		{"/Users/jane/Library/Group Containers/2BUA8C4S2C.com.1password/t/agent.sock", Agent1Password},
+		{"/home/jane/.1password/agent.sock", Agent1Password},
+		{"/run/user/1000/gnupg/S.gpg-agent.ssh", AgentGPG},
+		{"/Users/jane/.gnupg/S.gpg-agent.ssh", AgentGPG},
+		{"/Users/jane/Library/Containers/com.maxgoedjen.Secretive.SecretAgent/Data/socket.ssh", AgentSecretive},
+		{"/home/jane/.bitwarden-ssh-agent.sock", AgentBitwarden},
+		{"/run/user/1000/keyring/ssh", AgentGnomeKeyring},
+		{"/run/user/1000/gcr/ssh", AgentGnomeKeyring},
+		{"/tmp/ssh-XXXXabcd1234/agent.4321", AgentOpenSSH},
+		{"/private/tmp/com.apple.launchd.AbCdEf/Listeners", AgentOpenSSH},
+		{`\\.\pipe\openssh-ssh-agent`, AgentOpenSSH},
+		{"/home/jane/.ssh/agent.sock", AgentUnknown},
+	}
+	for _, tt := range tests {
+		if got := kindFromSocket(tt.socket); got != tt.want {
+			t.Errorf("kindFromSocket(%q) = %s, want %s", tt.socket, got, tt.want)
+		}
+	}
+
+	// A stable link to an agent's socket is followed
+	dir := t.TempDir()
+	target := filepath.Join(dir, ".1password", "agent.sock")
+	if err := os.MkdirAll(filepath.Dir(target), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(target, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(dir, "agent.sock")
+	if err := os.Symlink(target, link); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+	if got := kindFromSocket(link); got != Agent1Password {
+		t.Errorf("kindFromSocket(link to 1Password) = %s, want %s", got, Agent1Password)
+	}
+}
+
+func TestKindFromExtensions(t *testing.T) {
+	tests := []struct {
+		name       string
+		extensions []string
+		want       AgentKind
+	}{
+		{"gpg-agent", []string{"query", "session-bind@openssh.com", "ssh-env-names@gnupg.org"}, AgentGPG},
+		{"openssh", []string{"query", "session-bind@openssh.com"}, AgentOpenSSH},
+		{"other vendor", []string{"query", "lock@example.com"}, AgentUnknown},
+		{"none", nil, AgentUnknown},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := kindFromExtensions(tt.extensions); got != tt.want {
+				t.Errorf("kindFromExtensions(%v) = %s, want %s", tt.extensions, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAgent_CanRemoveKeys(t *testing.T) {
+	tests := []struct {
+		kind AgentKind
+		want bool
+	}{
+		{AgentOpenSSH, true},
+		{AgentGnomeKeyring, true},
+		{AgentUnknown, true},
+		{AgentGPG, false},
+		{Agent1Password, false},
+		{AgentSecretive, false},
+		{AgentBitwarden, false},
+	}
+	for _, tt := range tests {
+		if got := (Agent{Kind: tt.kind}).CanRemoveKeys(); got != tt.want {
+			t.Errorf("Agent{%s}.CanRemoveKeys() = %v, want %v", tt.kind, got, tt.want)
+		}
+	}
+}
+
+func TestDetectAgent(t *testing.T) {
+	serveAgent(t, "agent.sock", newQueryAgent(t, []string{"query", "session-bind@openssh.com", "ssh-env-names@gnupg.org"}))
+	if got := DetectAgent(); got.Kind != AgentGPG || got.Name() != "gpg-agent" {
+		t.Errorf("DetectAgent() with gpg-agent's extensions = %+v, want gpg-agent", got)
+	}
+
+	serveAgent(t, "agent.sock", newQueryAgent(t, nil))
+	if got := DetectAgent(); got.Kind != AgentUnknown || !got.CanRemoveKeys() {
+		t.Errorf("DetectAgent() without the query extension = %+v, want an unknown agent that can remove keys", got)
+	}
+
+	socket := serveAgent(t, filepath.Join("1password", "agent.sock"), newQueryAgent(t, nil))
+	if got := DetectAgent(); got.Kind != Agent1Password || got.Socket != socket {
+		t.Errorf("DetectAgent() = %+v, want 1Password at %s", got, socket)
+	}
+
+	t.Setenv("SSH_AUTH_SOCK", "")
+	if got := DetectAgent(); got.Socket != "" {
+		t.Errorf("DetectAgent() without SSH_AUTH_SOCK = %+v, want no socket", got)
+	}
+}
+
+func TestUnload_ReadOnlyAgent(t *testing.T) {
+	for _, tool := range []string{"ssh-keygen", "ssh-add"} {
+		if _, err := exec.LookPath(tool); err != nil {
+			t.Skipf("%s not available", tool)
+		}
+	}
+	dir := copyKeyFixtures(t, 0600, "id_ed25519", "id_rsa")
+	work, personal := filepath.Join(dir, "id_ed25519"), filepath.Join(dir, "id_rsa")
+	profiles := []profile.Profile{{Name: "work", SSHKeyPath: work}, {Name: "personal", SSHKeyPath: personal}}
+
+	serveAgent(t, filepath.Join(".1password", "agent.sock"), newQueryAgent(t, nil, work, personal))
+	if err := UnloadKey(work); !errors.Is(err, ErrAgentReadOnly) {
+		t.Errorf("UnloadKey() error = %v, want ErrAgentReadOnly", err)
+	}
+	if err := UnloadOtherKeys(work, profiles); !errors.Is(err, ErrAgentReadOnly) {
+		t.Errorf("UnloadOtherKeys() error = %v, want ErrAgentReadOnly", err)
+	}
+
+	// Nothing to remove, nothing to report
+	serveAgent(t, filepath.Join(".1password", "agent.sock"), newQueryAgent(t, nil, work))
+	if err := UnloadOtherKeys(work, profiles); err != nil {
+		t.Errorf("UnloadOtherKeys() with only the kept key loaded error = %v", err)
+	}
+}
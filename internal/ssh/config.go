@@ -0,0 +1,157 @@
+package ssh
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/thuanlegit/git-identitree/internal/utils"
+)
+
+// IdentityFile is an IdentityFile entry of an ssh_config file.
+type IdentityFile struct {
+	// Host is the pattern of the Host block holding the entry, such as
+	// "github-work", or "*" for entries before any Host line.
+	Host string
+	// Path is the key file, with ~ and %d expanded.
+	Path string
+}
+
+// ConfigIdentityFiles returns the IdentityFile entries of the ssh_config file
+// at path, in order and without duplicates. Include directives are followed
+// one level deep; relative ones are relative to the file's directory, as
+// ~/.ssh is for ~/.ssh/config. Paths using tokens other than %d, such as %h,
+// are left out since they depend on the host connected to. ~ and %d stand
+// for home. A missing file has no entries.
+func ConfigIdentityFiles(path, home string) ([]IdentityFile, error) {
+	var files []IdentityFile
+	seen := make(map[string]bool)
+	add := func(f IdentityFile) {
+		if !seen[f.Path] {
+			seen[f.Path] = true
+			files = append(files, f)
+		}
+	}
+	if err := readIdentityFiles(path, filepath.Dir(path), home, "*", 1, add); err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+// readIdentityFiles passes the IdentityFile entries of the config file at path
+// to add, starting in the Host block host and following up to includes levels
+// of Include directives, relative to dir. ~ and %d stand for home.
+func readIdentityFiles(path, dir, home, host string, includes int, add func(IdentityFile)) error {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read SSH config: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		keyword, args := splitConfigLine(scanner.Text())
+		switch strings.ToLower(keyword) {
+		case "host":
+			host = strings.Join(args, " ")
+		case "match":
+			host = "match " + strings.Join(args, " ")
+		case "identityfile":
+			if len(args) == 0 || strings.EqualFold(args[0], "none") {
+				continue
+			}
+			if expanded, ok := expandIdentityFile(args[0], home); ok {
+				add(IdentityFile{Host: host, Path: expanded})
+			}
+		case "include":
+			if includes == 0 {
+				continue
+			}
+			for _, pattern := range args {
+				pattern, ok := expandIdentityFile(pattern, home)
+				if !ok {
+					continue
+				}
+				if !filepath.IsAbs(pattern) {
+					pattern = filepath.Join(dir, pattern)
+				}
+				matches, _ := filepath.Glob(pattern)
+				for _, match := range matches {
+					if err := readIdentityFiles(match, dir, home, host, includes-1, add); err != nil {
+						return err
+					}
+				}
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read SSH config: %w", err)
+	}
+	return nil
+}
+
+// splitConfigLine splits an ssh_config line into its keyword and arguments.
+// The keyword may be followed by = instead of whitespace, arguments may be
+// double-quoted to hold spaces, and # starts a comment.
+func splitConfigLine(line string) (string, []string) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return "", nil
+	}
+	end := strings.IndexAny(line, " \t=")
+	if end < 0 {
+		return line, nil
+	}
+	keyword := line[:end]
+	rest := strings.TrimLeft(line[end:], " \t")
+	rest = strings.TrimLeft(strings.TrimPrefix(rest, "="), " \t")
+
+	var args []string
+	var b strings.Builder
+	quoted, inArg := false, false
+	for _, r := range rest {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			inArg = true
+		case (r == ' ' || r == '\t') && !quoted:
+			if inArg {
+				args = append(args, b.String())
+				b.Reset()
+				inArg = false
+			}
+		case r == '#' && !quoted && !inArg:
+			return keyword, args
+		default:
+			b.WriteRune(r)
+			inArg = true
+		}
+	}
+	if inArg {
+		args = append(args, b.String())
+	}
+	return keyword, args
+}
+
+// expandIdentityFile expands ~ and %d, the home directory, in path. It
+// reports false for a path with other tokens.
+func expandIdentityFile(path, home string) (string, bool) {
+	if strings.Contains(path, "%") {
+		path = strings.ReplaceAll(path, "%d", home)
+		path = strings.ReplaceAll(path, "%%", "\x00")
+		if strings.Contains(path, "%") {
+			return "", false
+		}
+		path = strings.ReplaceAll(path, "\x00", "%")
+	}
+	expanded, err := utils.ExpandPathIn(path, home)
+	if err != nil {
+		return "", false
+	}
+	return expanded, true
+}
@@ -0,0 +1,55 @@
+package ssh
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestConfigIdentityFiles(t *testing.T) {
+	t.Parallel()
+	home := t.TempDir()
+	keys := func(name string) string { return filepath.Join(home, "Keys", name) }
+
+	got, err := ConfigIdentityFiles(filepath.Join("testdata", "ssh_config", "config"), home)
+	if err != nil {
+		t.Fatalf("ConfigIdentityFiles() error = %v", err)
+	}
+	want := []IdentityFile{
+		{Host: "*", Path: keys("default")},
+		{Host: "github-work", Path: keys("work_ed25519")},
+		{Host: "github-work", Path: keys("work_rsa")},
+		{Host: "gitlab.com *.gitlab.example", Path: filepath.Join(home, "Keys", "with space", "gitlab")},
+		{Host: "client-a", Path: keys("client_a")},
+		{Host: `match host build.example exec true`, Path: keys("build")},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ConfigIdentityFiles() =\n%+v\nwant\n%+v", got, want)
+	}
+
+	if got, err := ConfigIdentityFiles(filepath.Join(home, ".ssh", "config"), home); err != nil || len(got) != 0 {
+		t.Errorf("ConfigIdentityFiles() of a missing file = %v, %v", got, err)
+	}
+}
+
+func TestSplitConfigLine(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		line    string
+		keyword string
+		args    []string
+	}{
+		{"  IdentityFile ~/.ssh/id_work", "IdentityFile", []string{"~/.ssh/id_work"}},
+		{"IdentityFile=~/.ssh/id_work", "IdentityFile", []string{"~/.ssh/id_work"}},
+		{"IdentityFile = \"~/My Keys/work\" # work", "IdentityFile", []string{"~/My Keys/work"}},
+		{"Host a b\tc", "Host", []string{"a", "b", "c"}},
+		{"# comment", "", nil},
+		{"", "", nil},
+	}
+	for _, tt := range tests {
+		keyword, args := splitConfigLine(tt.line)
+		if keyword != tt.keyword || !reflect.DeepEqual(args, tt.args) {
+			t.Errorf("splitConfigLine(%q) = %q, %q, want %q, %q", tt.line, keyword, args, tt.keyword, tt.args)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package ssh
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+
+	"github.com/thuanlegit/git-identitree/internal/profile"
+	"github.com/thuanlegit/git-identitree/internal/utils"
+)
+
+var (
+	// ErrNotPrivateKey is returned when loading a file that holds a public
+	// key or a certificate instead of a private key.
+	ErrNotPrivateKey = errors.New("not a private key")
+	// ErrKeyPermissions is returned when loading a key file that group or
+	// others can access, with LoadOptions.StrictPermissions.
+	ErrKeyPermissions = errors.New("SSH key accessible by others")
+)
+
+// KeyFormat is what an SSH key file holds, as told by its content.
+type KeyFormat int
+
+const (
+	// FormatUnknown is content SniffKey does not recognize. ssh-add may
+	// still read it.
+	FormatUnknown KeyFormat = iota
+	// FormatPrivate is a private key in OpenSSH or PEM format.
+	FormatPrivate
+	// FormatPublic is a public key, as in a .pub file.
+	FormatPublic
+	// FormatCertificate is an OpenSSH certificate, as in a -cert.pub file.
+	FormatCertificate
+	// FormatPuTTY is a key saved by PuTTY, usually a .ppk file.
+	FormatPuTTY
+)
+
+// String returns the name of the format as used in messages.
+func (f KeyFormat) String() string {
+	switch f {
+	case FormatPrivate:
+		return "private key"
+	case FormatPublic:
+		return "public key"
+	case FormatCertificate:
+		return "certificate"
+	case FormatPuTTY:
+		return "PuTTY key"
+	}
+	return "unknown"
+}
+
+// SniffKey tells the format of an SSH key file from its content.
+func SniffKey(data []byte) KeyFormat {
+	// Editors on Windows may start the file with a byte order mark
+	data = bytes.TrimLeft(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")), " \t\r\n")
+	line, _, _ := strings.Cut(string(data), "\n")
+	line = strings.TrimSpace(line)
+	switch {
+	case strings.HasPrefix(line, "-----BEGIN ") && strings.HasSuffix(line, "PRIVATE KEY-----"):
+		return FormatPrivate
+	case strings.HasPrefix(line, "PuTTY-User-Key-File-"):
+		return FormatPuTTY
+	case strings.HasPrefix(line, "---- BEGIN SSH2 PUBLIC KEY ----"):
+		return FormatPublic
+	}
+	key, err := ParsePublicKey(line)
+	if err != nil {
+		return FormatUnknown
+	}
+	if strings.Contains(key.Type, "-cert-") {
+		return FormatCertificate
+	}
+	return FormatPublic
+}
+
+// SniffKeyFile tells the format of the SSH key file at path.
+func SniffKeyFile(path string) (KeyFormat, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return FormatUnknown, err
+	}
+	return SniffKey(data), nil
+}
+
+// CheckKeyFile checks the SSH key file at path before it is handed to
+// ssh-add, whose errors do not say what is wrong. It fails with
+// ErrNotPrivateKey, naming the private key that was probably meant, when
+// the file holds a public key or a certificate, and with
+// profile.ErrPuTTYKey for a PuTTY key. It also
+// reports whether group or others can access the file, which ssh-add
+// refuses; permissions are not checked on Windows.
+func CheckKeyFile(path string) (exposed bool, err error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return false, err
+	}
+	format, err := SniffKeyFile(path)
+	if err != nil {
+		return false, fmt.Errorf("failed to read SSH key: %w", err)
+	}
+	shown := utils.AbbreviateHome(path)
+	switch format {
+	case FormatPublic, FormatCertificate:
+		private := strings.TrimSuffix(strings.TrimSuffix(path, ".pub"), "-cert")
+		if _, err := os.Stat(private); err == nil && private != path {
+			return false, utils.WithDetail(ErrNotPrivateKey, "%s looks like a %s; did you mean %s?", shown, format, utils.AbbreviateHome(private))
+		}
+		return false, utils.WithDetail(ErrNotPrivateKey, "%s looks like a %s; point the profile at the private key instead", shown, format)
+	case FormatPuTTY:
+		return false, profile.PuTTYKeyError(shown)
+	}
+	return runtime.GOOS != "windows" && info.Mode().Perm()&0077 != 0, nil
+}
@@ -0,0 +1,236 @@
+package ssh
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"hash"
+	"math/big"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/thuanlegit/git-identitree/internal/profile"
+	"github.com/thuanlegit/git-identitree/internal/utils"
+
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// ErrPPKEncrypted is returned when converting a PuTTY key protected by a
+// passphrase, which only PuTTYgen can remove.
+var ErrPPKEncrypted = errors.New("PuTTY key is encrypted")
+
+// ppkFile is the content of a PuTTY key file.
+type ppkFile struct {
+	version    int
+	algorithm  string
+	encryption string
+	comment    string
+	public     []byte
+	private    []byte
+	mac        []byte
+}
+
+// parsePPK reads a PuTTY key file of format 2, written by PuTTYgen before
+// 0.75, or 3. Headers it does not need, such as the key derivation of
+// encrypted format 3 keys, are skipped.
+func parsePPK(data []byte) (*ppkFile, error) {
+	text := strings.ReplaceAll(string(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))), "\r\n", "\n")
+	lines := strings.Split(strings.TrimSpace(text), "\n")
+
+	header, algorithm, ok := strings.Cut(lines[0], ": ")
+	version, err := strconv.Atoi(strings.TrimPrefix(header, "PuTTY-User-Key-File-"))
+	if !ok || !strings.HasPrefix(header, "PuTTY-User-Key-File-") || err != nil {
+		return nil, fmt.Errorf("not a PuTTY key file")
+	}
+	if version != 2 && version != 3 {
+		return nil, fmt.Errorf("PuTTY key format %d is not supported; save the key again with a current PuTTYgen", version)
+	}
+
+	f := &ppkFile{version: version, algorithm: algorithm}
+	for i := 1; i < len(lines); i++ {
+		name, value, ok := strings.Cut(lines[i], ": ")
+		if !ok {
+			return nil, fmt.Errorf("malformed PuTTY key line %d", i+1)
+		}
+		switch name {
+		case "Encryption":
+			f.encryption = value
+		case "Comment":
+			f.comment = value
+		case "Public-Lines", "Private-Lines":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 || i+n >= len(lines) {
+				return nil, fmt.Errorf("malformed PuTTY key: bad %s", name)
+			}
+			blob, err := base64.StdEncoding.DecodeString(strings.Join(lines[i+1:i+1+n], ""))
+			if err != nil {
+				return nil, fmt.Errorf("malformed PuTTY key: %s: %w", name, err)
+			}
+			if name == "Public-Lines" {
+				f.public = blob
+			} else {
+				f.private = blob
+			}
+			i += n
+		case "Private-MAC":
+			if f.mac, err = hex.DecodeString(value); err != nil {
+				return nil, fmt.Errorf("malformed PuTTY key: Private-MAC: %w", err)
+			}
+		}
+	}
+	if f.public == nil || f.private == nil || f.mac == nil {
+		return nil, fmt.Errorf("malformed PuTTY key: missing public key, private key or MAC")
+	}
+	return f, nil
+}
+
+// verifyMAC checks the MAC over the key, which PuTTY computes without a
+// passphrase for unencrypted keys: HMAC-SHA1 keyed with the SHA-1 of a fixed
+// string in format 2, HMAC-SHA256 with an empty key in format 3.
+func (f *ppkFile) verifyMAC() error {
+	var mac hash.Hash
+	if f.version == 2 {
+		key := sha1.Sum([]byte("putty-private-key-file-mac-key"))
+		mac = hmac.New(sha1.New, key[:])
+	} else {
+		mac = hmac.New(sha256.New, nil)
+	}
+	mac.Write(gossh.Marshal(struct {
+		Algorithm, Encryption, Comment string
+		Public, Private                []byte
+	}{f.algorithm, f.encryption, f.comment, f.public, f.private}))
+	if !hmac.Equal(mac.Sum(nil), f.mac) {
+		return fmt.Errorf("PuTTY key is corrupt: its MAC does not match")
+	}
+	return nil
+}
+
+// privateKey returns the key in f as a crypto.PrivateKey of the kind
+// gossh.MarshalPrivateKey takes, checking it against the public key.
+func (f *ppkFile) privateKey(public gossh.PublicKey) (crypto.PrivateKey, error) {
+	cryptoKey, ok := public.(gossh.CryptoPublicKey)
+	if !ok {
+		return nil, fmt.Errorf("unsupported PuTTY key type %s", f.algorithm)
+	}
+	switch pub := cryptoKey.CryptoPublicKey().(type) {
+	case ed25519.PublicKey:
+		var priv struct{ Seed []byte }
+		if err := gossh.Unmarshal(f.private, &priv); err != nil || len(priv.Seed) != ed25519.SeedSize {
+			return nil, fmt.Errorf("malformed PuTTY ed25519 key")
+		}
+		key := ed25519.NewKeyFromSeed(priv.Seed)
+		if !pub.Equal(key.Public()) {
+			return nil, fmt.Errorf("PuTTY key is corrupt: the private key does not match the public key")
+		}
+		return key, nil
+	case *rsa.PublicKey:
+		var priv struct{ D, P, Q, Iqmp *big.Int }
+		if err := gossh.Unmarshal(f.private, &priv); err != nil {
+			return nil, fmt.Errorf("malformed PuTTY RSA key: %w", err)
+		}
+		key := &rsa.PrivateKey{PublicKey: *pub, D: priv.D, Primes: []*big.Int{priv.P, priv.Q}}
+		if err := key.Validate(); err != nil {
+			return nil, fmt.Errorf("PuTTY key is corrupt: %w", err)
+		}
+		key.Precompute()
+		return key, nil
+	case *ecdsa.PublicKey:
+		var priv struct{ D *big.Int }
+		if err := gossh.Unmarshal(f.private, &priv); err != nil {
+			return nil, fmt.Errorf("malformed PuTTY ECDSA key: %w", err)
+		}
+		return &ecdsa.PrivateKey{PublicKey: *pub, D: priv.D}, nil
+	}
+	return nil, fmt.Errorf("unsupported PuTTY key type %s", f.algorithm)
+}
+
+// ConvertPPK converts the PuTTY key in data to an OpenSSH private key and
+// returns it together with its public key line, keeping the comment. Only
+// unencrypted ed25519, RSA and ECDSA keys can be converted; a key with a
+// passphrase fails with ErrPPKEncrypted.
+func ConvertPPK(data []byte) (private, public []byte, err error) {
+	f, err := parsePPK(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	if f.encryption != "none" {
+		return nil, nil, ErrPPKEncrypted
+	}
+	if err := f.verifyMAC(); err != nil {
+		return nil, nil, err
+	}
+	pub, err := gossh.ParsePublicKey(f.public)
+	if err != nil {
+		return nil, nil, fmt.Errorf("malformed PuTTY public key: %w", err)
+	}
+	if pub.Type() != f.algorithm {
+		return nil, nil, fmt.Errorf("PuTTY key is corrupt: header says %s, key is %s", f.algorithm, pub.Type())
+	}
+	key, err := f.privateKey(pub)
+	if err != nil {
+		return nil, nil, err
+	}
+	block, err := gossh.MarshalPrivateKey(key, f.comment)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to encode OpenSSH key: %w", err)
+	}
+
+	public = bytes.TrimSuffix(gossh.MarshalAuthorizedKey(pub), []byte("\n"))
+	if f.comment != "" {
+		public = append(public, " "+f.comment...)
+	}
+	return pem.EncodeToMemory(block), append(public, '\n'), nil
+}
+
+// ConvertPPKFile converts the PuTTY key at path, writing the OpenSSH private
+// key, readable only by its owner, to profile.ConvertedKeyPath(path) and its public
+// key next to that with .pub added. The PuTTY key is left in place. An
+// existing private key is never overwritten; an existing .pub file is kept.
+func ConvertPPKFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read PuTTY key: %w", err)
+	}
+	private, public, err := ConvertPPK(data)
+	if errors.Is(err, ErrPPKEncrypted) {
+		return "", utils.WithDetail(ErrPPKEncrypted, "%s has a passphrase; remove it in PuTTYgen, or convert the key there with Conversions > Export OpenSSH key", utils.AbbreviateHome(path))
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to convert %s: %w", utils.AbbreviateHome(path), err)
+	}
+
+	target := profile.ConvertedKeyPath(path)
+	file, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if errors.Is(err, os.ErrExist) {
+		return "", fmt.Errorf("cannot convert %s: %s already exists", utils.AbbreviateHome(path), utils.AbbreviateHome(target))
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to write OpenSSH key: %w", err)
+	}
+	if _, err := file.Write(private); err != nil {
+		_ = file.Close()
+		_ = os.Remove(target)
+		return "", fmt.Errorf("failed to write OpenSSH key: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		_ = os.Remove(target)
+		return "", fmt.Errorf("failed to write OpenSSH key: %w", err)
+	}
+	if _, err := os.Stat(target + ".pub"); os.IsNotExist(err) {
+		if err := os.WriteFile(target+".pub", public, 0644); err != nil {
+			return "", fmt.Errorf("failed to write OpenSSH public key: %w", err)
+		}
+	}
+	return target, nil
+}
@@ -0,0 +1,152 @@
+package ssh
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ed25519"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// readFixture returns the content of testdata/keys/name.
+func readFixture(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", "keys", name))
+	if err != nil {
+		t.Fatalf("Failed to read fixture: %v", err)
+	}
+	return data
+}
+
+// samePrivateKey reports whether two keys parsed by gossh.ParseRawPrivateKey
+// are equal. ed25519 keys come as pointers, which Equal does not take.
+func samePrivateKey(a, b crypto.PrivateKey) bool {
+	for _, k := range []*crypto.PrivateKey{&a, &b} {
+		if p, ok := (*k).(*ed25519.PrivateKey); ok {
+			*k = *p
+		}
+	}
+	key, ok := a.(interface{ Equal(crypto.PrivateKey) bool })
+	return ok && key.Equal(b)
+}
+
+func TestConvertPPK(t *testing.T) {
+	t.Parallel()
+	// Each PuTTY fixture holds the same key as the OpenSSH fixture it is
+	// named after, as PuTTYgen would import it
+	tests := []struct {
+		ppk, openssh string
+	}{
+		{"id_ed25519.ppk", "id_ed25519"},
+		{"id_rsa.ppk", "id_rsa"},
+		{"id_ecdsa.ppk", "id_ecdsa"},
+	}
+	for _, tt := range tests {
+		for _, crlf := range []bool{false, true} {
+			data := readFixture(t, tt.ppk)
+			if crlf {
+				data = bytes.ReplaceAll(data, []byte("\n"), []byte("\r\n"))
+			}
+			private, public, err := ConvertPPK(data)
+			if err != nil {
+				t.Errorf("ConvertPPK(%s, crlf %v) error = %v", tt.ppk, crlf, err)
+				continue
+			}
+
+			got, err := gossh.ParseRawPrivateKey(private)
+			if err != nil {
+				t.Errorf("ConvertPPK(%s) gave a key ssh cannot read: %v", tt.ppk, err)
+				continue
+			}
+			want, err := gossh.ParseRawPrivateKey(readFixture(t, tt.openssh))
+			if err != nil {
+				t.Fatalf("Failed to parse fixture: %v", err)
+			}
+			if !samePrivateKey(got, want) {
+				t.Errorf("ConvertPPK(%s) private key differs from %s", tt.ppk, tt.openssh)
+			}
+			if wantPublic := readFixture(t, tt.openssh+".pub"); !bytes.Equal(public, wantPublic) {
+				t.Errorf("ConvertPPK(%s) public key = %q, want %q", tt.ppk, public, wantPublic)
+			}
+		}
+	}
+}
+
+func TestConvertPPK_Invalid(t *testing.T) {
+	t.Parallel()
+	ppk := string(readFixture(t, "id_ed25519.ppk"))
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{"tampered", strings.Replace(ppk, "Comment: test@gidtree", "Comment: mallory", 1), "MAC does not match"},
+		{"format 1", strings.Replace(ppk, "PuTTY-User-Key-File-3", "PuTTY-User-Key-File-1", 1), "format 1 is not supported"},
+		{"truncated", ppk[:strings.Index(ppk, "Private-Lines")], "missing"},
+		{"openssh", string(readFixture(t, "id_ed25519")), "not a PuTTY key"},
+	}
+	for _, tt := range tests {
+		if _, _, err := ConvertPPK([]byte(tt.data)); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("ConvertPPK(%s) error = %v, want it to mention %q", tt.name, err, tt.want)
+		}
+	}
+
+	encrypted := strings.Replace(ppk, "Encryption: none", "Encryption: aes256-cbc", 1)
+	if _, _, err := ConvertPPK([]byte(encrypted)); !errors.Is(err, ErrPPKEncrypted) {
+		t.Errorf("ConvertPPK(encrypted) error = %v, want ErrPPKEncrypted", err)
+	}
+}
+
+func TestConvertPPKFile(t *testing.T) {
+	t.Parallel()
+	dir := copyKeyFixtures(t, 0600, "id_rsa.ppk")
+	path := filepath.Join(dir, "id_rsa.ppk")
+
+	converted, err := ConvertPPKFile(path)
+	if err != nil {
+		t.Fatalf("ConvertPPKFile() error = %v", err)
+	}
+	if want := filepath.Join(dir, "id_rsa"); converted != want {
+		t.Errorf("ConvertPPKFile() = %s, want %s", converted, want)
+	}
+	if format, err := SniffKeyFile(converted); err != nil || format != FormatPrivate {
+		t.Errorf("converted key format = %v, %v; want a private key", format, err)
+	}
+	if exposed, err := CheckKeyFile(converted); err != nil || (exposed && runtime.GOOS != "windows") {
+		t.Errorf("CheckKeyFile(converted) = %v, %v; want a key only its owner can read", exposed, err)
+	}
+	if public, err := ReadPublicKey(converted + ".pub"); err != nil || public.Describe() != "rsa, test@gidtree" {
+		t.Errorf("converted public key = %+v, %v", public, err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("the PuTTY key should be kept: %v", err)
+	}
+
+	// A second conversion must not overwrite the first
+	if _, err := ConvertPPKFile(path); err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Errorf("ConvertPPKFile() again error = %v, want already exists", err)
+	}
+}
+
+func TestConvertPPKFile_Encrypted(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "work.ppk")
+	encrypted := strings.Replace(string(readFixture(t, "id_ed25519.ppk")), "Encryption: none", "Encryption: aes256-cbc", 1)
+	if err := os.WriteFile(path, []byte(encrypted), 0600); err != nil {
+		t.Fatalf("Failed to write key: %v", err)
+	}
+
+	_, err := ConvertPPKFile(path)
+	if !errors.Is(err, ErrPPKEncrypted) || !strings.Contains(err.Error(), "PuTTYgen") {
+		t.Errorf("ConvertPPKFile(encrypted) error = %v, want ErrPPKEncrypted pointing at PuTTYgen", err)
+	}
+	if _, err := os.Stat(filepath.Join(filepath.Dir(path), "work")); !os.IsNotExist(err) {
+		t.Errorf("nothing should be written for an encrypted key: %v", err)
+	}
+}
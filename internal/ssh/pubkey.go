@@ -0,0 +1,100 @@
+package ssh
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// PublicKey is an SSH public key as written in a .pub file or listed by
+// ssh-add -L: the key type, the base64 key and an optional comment.
+type PublicKey struct {
+	Type    string
+	Key     string
+	Comment string
+}
+
+// ParsePublicKey reads a public key line. The comment may contain spaces.
+func ParsePublicKey(line string) (PublicKey, error) {
+	fields := strings.Fields(line)
+	if len(fields) < 2 || !IsKeyType(fields[0]) {
+		return PublicKey{}, fmt.Errorf("not an SSH public key: %q", strings.TrimSpace(line))
+	}
+	return PublicKey{Type: fields[0], Key: fields[1], Comment: strings.Join(fields[2:], " ")}, nil
+}
+
+// IsKeyType reports whether field names an SSH key algorithm, including
+// security keys and certificates.
+func IsKeyType(field string) bool {
+	for _, prefix := range []string{"ssh-", "ecdsa-sha2-", "sk-ssh-", "sk-ecdsa-sha2-"} {
+		if strings.HasPrefix(field, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// ReadPublicKey reads the public key in the .pub file at path.
+func ReadPublicKey(path string) (PublicKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return PublicKey{}, err
+	}
+	line, _, _ := strings.Cut(strings.TrimSpace(string(data)), "\n")
+	key, err := ParsePublicKey(line)
+	if err != nil {
+		return PublicKey{}, fmt.Errorf("%s: %w", path, err)
+	}
+	return key, nil
+}
+
+// Algorithm returns the short name of the key type, such as ed25519 for
+// ssh-ed25519, ecdsa for ecdsa-sha2-nistp256 and ed25519-sk for a security
+// key.
+func (k PublicKey) Algorithm() string {
+	t := strings.TrimSuffix(k.Type, "@openssh.com")
+	t = strings.TrimSuffix(t, "-cert-v01")
+	switch {
+	case strings.HasPrefix(t, "sk-ssh-"):
+		return strings.TrimPrefix(t, "sk-ssh-") + "-sk"
+	case strings.HasPrefix(t, "sk-ecdsa-"):
+		return "ecdsa-sk"
+	case strings.HasPrefix(t, "ecdsa-"):
+		return "ecdsa"
+	}
+	return strings.TrimPrefix(t, "ssh-")
+}
+
+// Describe returns the algorithm and the comment, when there is one, as in
+// "ed25519, jane@laptop".
+func (k PublicKey) Describe() string {
+	if k.Comment == "" {
+		return k.Algorithm()
+	}
+	return k.Algorithm() + ", " + k.Comment
+}
+
+// AgentPublicKeys returns the keys the SSH agent holds, from ssh-add -L.
+// An agent without keys gives none. In offline mode it fails with ErrOffline.
+func AgentPublicKeys() ([]PublicKey, error) {
+	if err := CheckAgent(); err != nil {
+		return nil, err
+	}
+	output, err := agentCommand("-L")
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list agent keys: %w", err)
+	}
+	var keys []PublicKey
+	for _, line := range strings.Split(string(output), "\n") {
+		if key, err := ParsePublicKey(line); err == nil {
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
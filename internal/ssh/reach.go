@@ -0,0 +1,87 @@
+package ssh
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"runtime"
+	"time"
+
+	"github.com/thuanlegit/git-identitree/internal/logging"
+	"github.com/thuanlegit/git-identitree/internal/utils"
+)
+
+// AgentTimeout bounds each exchange with the SSH agent, so that an agent
+// that accepts connections but never answers, such as the forwarded agent of
+// an SSH session that has ended, cannot hang a shell hook. The CLI sets it
+// from agent_timeout in config.yaml.
+var AgentTimeout = time.Second
+
+// ErrAgentUnreachable is returned when SSH_AUTH_SOCK names an agent that
+// cannot be connected to or does not answer within AgentTimeout.
+var ErrAgentUnreachable = errors.New("SSH agent unreachable")
+
+// requestIdentities is an SSH_AGENTC_REQUEST_IDENTITIES message: a length
+// of 1 followed by the message type. Every agent answers it.
+var requestIdentities = []byte{0, 0, 0, 1, 11}
+
+// CheckAgent asks the agent at SSH_AUTH_SOCK for its keys, without running
+// ssh-add, and fails with ErrAgentUnreachable when it cannot connect or the
+// agent does not answer within AgentTimeout. Without SSH_AUTH_SOCK, and on
+// Windows, whose agent listens on a named pipe, nothing is checked and
+// ssh-add is left to find the agent. In offline mode it fails with
+// ErrOffline.
+func CheckAgent() error {
+	if Offline {
+		return offlineError()
+	}
+	socket := os.Getenv("SSH_AUTH_SOCK")
+	if socket == "" || runtime.GOOS == "windows" {
+		return nil
+	}
+	return probeAgent(socket, AgentTimeout)
+}
+
+// probeAgent sends requestIdentities to the agent listening at socket and
+// waits up to timeout for the start of its answer.
+func probeAgent(socket string, timeout time.Duration) error {
+	shown := utils.AbbreviateHome(socket)
+	conn, err := net.DialTimeout("unix", socket, timeout)
+	if err != nil {
+		logging.Logger().Debug("SSH agent unreachable", "socket", socket, "error", err)
+		return utils.WithDetail(ErrAgentUnreachable, "cannot connect to the SSH agent at %s (SSH_AUTH_SOCK); if it was forwarded, the SSH session it came from may have ended", shown)
+	}
+	defer func() { _ = conn.Close() }()
+
+	_ = conn.SetDeadline(time.Now().Add(timeout))
+	header := make([]byte, 5)
+	if _, err = conn.Write(requestIdentities); err == nil {
+		_, err = io.ReadFull(conn, header)
+	}
+	if err != nil {
+		logging.Logger().Debug("SSH agent did not answer", "socket", socket, "error", err)
+		return utils.WithDetail(ErrAgentUnreachable, "the SSH agent at %s (SSH_AUTH_SOCK) did not answer within %s; if it was forwarded, the SSH session it came from may have ended", shown, timeout)
+	}
+	return nil
+}
+
+// agentCommand runs ssh-add with args, such as -l, and returns its output.
+// ssh-add is killed after AgentTimeout, failing with ErrAgentUnreachable,
+// so it must not be used where ssh-add may ask for a passphrase.
+func agentCommand(args ...string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), AgentTimeout)
+	defer cancel()
+	cmd, err := command(ctx, sshAdd, args...)
+	if err != nil {
+		return nil, err
+	}
+	cmd.WaitDelay = AgentTimeout
+	output, err := cmd.Output()
+	logging.Command(cmd, err)
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return nil, utils.WithDetail(ErrAgentUnreachable, "ssh-add did not finish within %s; the SSH agent may not be answering", AgentTimeout)
+	}
+	return output, err
+}
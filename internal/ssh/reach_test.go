@@ -0,0 +1,145 @@
+package ssh
+
+import (
+	"errors"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// listenAgent listens on a Unix socket at name, under a new temporary
+// directory, and points SSH_AUTH_SOCK at it.
+func listenAgent(t *testing.T, name string) net.Listener {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("the SSH agent is not probed on Windows")
+	}
+	// Socket paths are limited to about 100 bytes, which t.TempDir can exceed
+	dir, err := os.MkdirTemp("", "agent")
+	if err != nil {
+		t.Fatalf("MkdirTemp() error = %v", err)
+	}
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+	socket := filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Dir(socket), 0700); err != nil {
+		t.Fatalf("MkdirAll() error = %v", err)
+	}
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	t.Cleanup(func() { _ = listener.Close() })
+	t.Setenv("SSH_AUTH_SOCK", socket)
+	return listener
+}
+
+// fakeAgent listens on a Unix socket, points SSH_AUTH_SOCK at it and returns
+// its path. An answering agent replies to every request with an empty list
+// of identities; otherwise it accepts connections and never says anything,
+// like the forwarded agent of a session that hung up.
+func fakeAgent(t *testing.T, answer bool) string {
+	t.Helper()
+	listener := listenAgent(t, "agent.sock")
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer func() { _ = conn.Close() }()
+				request := make([]byte, 5)
+				for {
+					if _, err := io.ReadFull(conn, request); err != nil {
+						return
+					}
+					if answer {
+						// SSH_AGENT_IDENTITIES_ANSWER with no keys
+						_, _ = conn.Write([]byte{0, 0, 0, 5, 12, 0, 0, 0, 0})
+					}
+				}
+			}()
+		}
+	}()
+	return listener.Addr().String()
+}
+
+// useAgentTimeout sets AgentTimeout for the test.
+func useAgentTimeout(t *testing.T, timeout time.Duration) {
+	t.Helper()
+	previous := AgentTimeout
+	AgentTimeout = timeout
+	t.Cleanup(func() { AgentTimeout = previous })
+}
+
+func TestCheckAgent(t *testing.T) {
+	useAgentTimeout(t, 100*time.Millisecond)
+
+	fakeAgent(t, true)
+	if err := CheckAgent(); err != nil {
+		t.Errorf("CheckAgent() with an answering agent error = %v", err)
+	}
+
+	fakeAgent(t, false)
+	start := time.Now()
+	err := CheckAgent()
+	if !errors.Is(err, ErrAgentUnreachable) {
+		t.Errorf("CheckAgent() with a silent agent error = %v, want ErrAgentUnreachable", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("CheckAgent() took %s, want it to give up after AgentTimeout", elapsed)
+	}
+
+	t.Setenv("SSH_AUTH_SOCK", filepath.Join(t.TempDir(), "gone.sock"))
+	if err := CheckAgent(); !errors.Is(err, ErrAgentUnreachable) {
+		t.Errorf("CheckAgent() with a removed socket error = %v, want ErrAgentUnreachable", err)
+	}
+
+	t.Setenv("SSH_AUTH_SOCK", "")
+	if err := CheckAgent(); err != nil {
+		t.Errorf("CheckAgent() without SSH_AUTH_SOCK error = %v, want ssh-add left to find the agent", err)
+	}
+}
+
+func TestCheckKeyLoaded_SilentAgent(t *testing.T) {
+	if _, err := exec.LookPath("ssh-keygen"); err != nil {
+		t.Skip("ssh-keygen not available")
+	}
+	useAgentTimeout(t, 100*time.Millisecond)
+	fakeAgent(t, false)
+	path := filepath.Join(copyKeyFixtures(t, 0600, "id_ed25519"), "id_ed25519")
+
+	start := time.Now()
+	if _, err := CheckKeyLoaded(path); !errors.Is(err, ErrAgentUnreachable) {
+		t.Errorf("CheckKeyLoaded() error = %v, want ErrAgentUnreachable", err)
+	}
+	if err := LoadKey(path); !errors.Is(err, ErrAgentUnreachable) {
+		t.Errorf("LoadKey() error = %v, want ErrAgentUnreachable before ssh-add runs", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("asking a silent agent took %s", elapsed)
+	}
+}
+
+func TestAgentCommand_Timeout(t *testing.T) {
+	if _, err := exec.LookPath("ssh-add"); err != nil {
+		t.Skip("ssh-add not available")
+	}
+	useAgentTimeout(t, 200*time.Millisecond)
+	fakeAgent(t, false)
+
+	// ssh-add itself would wait on the silent agent forever
+	start := time.Now()
+	if _, err := agentCommand("-l"); !errors.Is(err, ErrAgentUnreachable) {
+		t.Errorf("agentCommand() error = %v, want ErrAgentUnreachable", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("agentCommand() took %s, want ssh-add killed after AgentTimeout", elapsed)
+	}
+}
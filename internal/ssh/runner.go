@@ -0,0 +1,77 @@
+package ssh
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+
+	"github.com/thuanlegit/git-identitree/internal/utils"
+)
+
+// The OpenSSH tools this package runs, by the names command takes.
+const (
+	sshAdd    = "ssh-add"
+	sshKeygen = "ssh-keygen"
+)
+
+// SSHAddPath and SSHKeygenPath are the programs run as ssh-add and
+// ssh-keygen, looked up in PATH unless they are paths, for machines that
+// install OpenSSH elsewhere. The CLI sets them from ssh_add_path and
+// ssh_keygen_path in config.yaml.
+var (
+	SSHAddPath    = sshAdd
+	SSHKeygenPath = sshKeygen
+)
+
+// Offline makes loading and unloading keys no-ops that warn, and checking
+// the agent fail with ErrOffline, without running anything or connecting to
+// the agent, for setups that only want the generated git config. The CLI sets
+// it from offline in config.yaml.
+var Offline bool
+
+// OfflineWarnings receives, once per process, the warning that an agent
+// action was skipped in offline mode; nil discards it.
+var OfflineWarnings io.Writer = os.Stderr
+
+// offlineWarned records that the offline warning has been written.
+var offlineWarned bool
+
+// ErrOffline is returned when the SSH agent would be asked something in
+// offline mode.
+var ErrOffline = errors.New("SSH agent disabled by offline mode")
+
+// command returns the command that runs tool, ssh-add or ssh-keygen, with
+// args, killed when ctx is done. Every program this package runs goes
+// through it, so that the configured paths and offline mode apply
+// everywhere; in offline mode it fails with ErrOffline.
+func command(ctx context.Context, tool string, args ...string) (*exec.Cmd, error) {
+	if Offline {
+		return nil, offlineError()
+	}
+	path := tool
+	switch tool {
+	case sshAdd:
+		path = SSHAddPath
+	case sshKeygen:
+		path = SSHKeygenPath
+	}
+	return utils.CommandContext(ctx, path, args...), nil
+}
+
+// offlineError returns ErrOffline saying how to turn offline mode off.
+func offlineError() error {
+	return utils.WithDetail(ErrOffline, "offline is set in config.yaml, so gidtree does not talk to the SSH agent; turn it off with: gidtree config set offline false")
+}
+
+// warnOffline writes to OfflineWarnings, the first time only, that action on
+// the SSH agent was skipped.
+func warnOffline(action string) {
+	if offlineWarned || OfflineWarnings == nil {
+		return
+	}
+	offlineWarned = true
+	_, _ = fmt.Fprintf(OfflineWarnings, "⚠ Offline mode: %s skipped; gidtree does not talk to the SSH agent (gidtree config set offline false)\n", action)
+}
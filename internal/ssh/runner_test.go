@@ -0,0 +1,123 @@
+package ssh
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/thuanlegit/git-identitree/internal/profile"
+)
+
+// fakeTool writes a shell script standing in for an OpenSSH tool: it appends
+// its name and arguments to the log file in dir, prints output and exits with
+// code. It returns the script's path.
+func fakeTool(t *testing.T, dir, name, output string, code int) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("fake tools are shell scripts")
+	}
+	path := filepath.Join(dir, name)
+	script := "#!/bin/sh\necho \"" + name + " $*\" >> '" + filepath.Join(dir, "log") + "'\nprintf '%s\\n' '" + output + "'\nexit " + strconv.Itoa(code) + "\n"
+	if err := os.WriteFile(path, []byte(script), 0755); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	return path
+}
+
+// toolLog returns what the fake tools in dir have logged.
+func toolLog(t *testing.T, dir string) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(dir, "log"))
+	if errors.Is(err, os.ErrNotExist) {
+		return ""
+	}
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	return string(data)
+}
+
+// useTools sets the tool paths and offline mode for the test, with
+// OfflineWarnings going to the returned buffer.
+func useTools(t *testing.T, sshAddPath, sshKeygenPath string, offline bool) *bytes.Buffer {
+	t.Helper()
+	prevAdd, prevKeygen, prevOffline, prevWarnings := SSHAddPath, SSHKeygenPath, Offline, OfflineWarnings
+	t.Cleanup(func() {
+		SSHAddPath, SSHKeygenPath, Offline, OfflineWarnings = prevAdd, prevKeygen, prevOffline, prevWarnings
+		offlineWarned = false
+	})
+	var warnings bytes.Buffer
+	SSHAddPath, SSHKeygenPath, Offline, OfflineWarnings = sshAddPath, sshKeygenPath, offline, &warnings
+	offlineWarned = false
+	return &warnings
+}
+
+func TestCommand_ConfiguredPaths(t *testing.T) {
+	dir := t.TempDir()
+	fingerprint := "SHA256:fakefingerprint"
+	useTools(t,
+		fakeTool(t, dir, "my-ssh-add", "256 "+fingerprint+" work (ED25519)", 0),
+		fakeTool(t, dir, "my-ssh-keygen", "256 "+fingerprint+" work (ED25519)", 0),
+		false)
+	t.Setenv("SSH_AUTH_SOCK", "")
+	key := filepath.Join(copyKeyFixtures(t, 0600, "id_ed25519"), "id_ed25519")
+
+	loaded, err := CheckKeyLoaded(key)
+	if err != nil || !loaded {
+		t.Fatalf("CheckKeyLoaded() = %v, %v; want the key the fake ssh-add lists", loaded, err)
+	}
+	log := toolLog(t, dir)
+	for _, want := range []string{"my-ssh-keygen -lf " + key, "my-ssh-add -l"} {
+		if !strings.Contains(log, want) {
+			t.Errorf("tool log = %q, want %q", log, want)
+		}
+	}
+}
+
+func TestOffline(t *testing.T) {
+	dir := t.TempDir()
+	warnings := useTools(t,
+		fakeTool(t, dir, "ssh-add", "", 0),
+		fakeTool(t, dir, "ssh-keygen", "", 0),
+		true)
+	// Offline mode does not even connect to the agent
+	t.Setenv("SSH_AUTH_SOCK", filepath.Join(t.TempDir(), "gone.sock"))
+	key := filepath.Join(copyKeyFixtures(t, 0600, "id_ed25519"), "id_ed25519")
+
+	if err := LoadKey(key); err != nil {
+		t.Errorf("LoadKey() error = %v, want a no-op", err)
+	}
+	if err := UnloadKey(key); err != nil {
+		t.Errorf("UnloadKey() error = %v, want a no-op", err)
+	}
+	if err := UnloadOtherKeys(key, []profile.Profile{{Name: "other", SSHKeyPath: key + ".other"}}); err != nil {
+		t.Errorf("UnloadOtherKeys() error = %v, want a no-op", err)
+	}
+	if _, err := CheckKeyLoaded(key); !errors.Is(err, ErrOffline) {
+		t.Errorf("CheckKeyLoaded() error = %v, want ErrOffline", err)
+	}
+	if _, err := AgentPublicKeys(); !errors.Is(err, ErrOffline) {
+		t.Errorf("AgentPublicKeys() error = %v, want ErrOffline", err)
+	}
+	if err := CheckAgent(); !errors.Is(err, ErrOffline) {
+		t.Errorf("CheckAgent() error = %v, want ErrOffline", err)
+	}
+	if AgentReachable() {
+		t.Error("AgentReachable() = true in offline mode")
+	}
+	if _, err := agentCommand("-l"); !errors.Is(err, ErrOffline) {
+		t.Errorf("agentCommand() error = %v, want ErrOffline", err)
+	}
+
+	if log := toolLog(t, dir); log != "" {
+		t.Errorf("tools ran in offline mode: %q", log)
+	}
+	if got := strings.Count(warnings.String(), "Offline mode"); got != 1 {
+		t.Errorf("warnings = %q, want one warning", warnings.String())
+	}
+}
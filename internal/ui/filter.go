@@ -0,0 +1,43 @@
+package ui
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/thuanlegit/git-identitree/internal/profile"
+)
+
+// FilterProfiles returns the profiles whose name, email or author name fuzzy-match
+// query, keeping their original order. A query matches when its characters appear
+// in the field in order, ignoring case and whitespace in the query. An empty
+// query matches every profile.
+func FilterProfiles(profiles []profile.Profile, query string) []profile.Profile {
+	query = strings.ToLower(strings.Join(strings.Fields(query), ""))
+	if query == "" {
+		return profiles
+	}
+
+	matched := []profile.Profile{}
+	for _, p := range profiles {
+		if fuzzyMatch(p.Name, query) || fuzzyMatch(p.Email, query) || fuzzyMatch(p.GetAuthorName(), query) {
+			matched = append(matched, p)
+		}
+	}
+	return matched
+}
+
+// fuzzyMatch reports whether the runes of query occur in s in order.
+// query must already be lower case.
+func fuzzyMatch(s, query string) bool {
+	q := []rune(query)
+	i := 0
+	for _, r := range s {
+		if i == len(q) {
+			break
+		}
+		if unicode.ToLower(r) == q[i] {
+			i++
+		}
+	}
+	return i == len(q)
+}
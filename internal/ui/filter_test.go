@@ -0,0 +1,48 @@
+package ui
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/thuanlegit/git-identitree/internal/profile"
+)
+
+func TestFilterProfiles(t *testing.T) {
+	profiles := []profile.Profile{
+		{Name: "work", Email: "jane@acme.example", AuthorName: "Jane Doe"},
+		{Name: "personal", Email: "me@home.example"},
+		{Name: "oss", Email: "jd@opensource.example", AuthorName: "Jane D. (OSS)"},
+	}
+
+	names := func(ps []profile.Profile) []string {
+		out := []string{}
+		for _, p := range ps {
+			out = append(out, p.Name)
+		}
+		return out
+	}
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"", []string{"work", "personal", "oss"}},
+		{"wrk", []string{"work"}},
+		{"PERS", []string{"personal"}},
+		{"acme", []string{"work"}},
+		{"jane", []string{"work", "oss"}},
+		{"jane doe", []string{"work"}},
+		{"opensrc", []string{"oss"}},
+		{"example", []string{"work", "personal", "oss"}},
+		{"zzz", []string{}},
+		{"krow", []string{}}, // order matters
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			if got := names(FilterProfiles(profiles, tt.query)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("FilterProfiles(%q) = %v, want %v", tt.query, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package ui
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// ellipsis marks a value that was cut to fit its column.
+const ellipsis = "…"
+
+// truncate shortens s to at most width terminal cells, ending it with an
+// ellipsis when anything was cut.
+func truncate(s string, width int) string {
+	if lipgloss.Width(s) <= width {
+		return s
+	}
+	if width <= 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	used := 0
+	for _, r := range s {
+		w := lipgloss.Width(string(r))
+		if used+w > width-1 {
+			break
+		}
+		b.WriteRune(r)
+		used += w
+	}
+	return b.String() + ellipsis
+}
+
+// fitLine truncates s so that, with indent cells of styling around it, it fits
+// in width. A width of zero means the terminal size is unknown and s is kept.
+func fitLine(s string, indent, width int) string {
+	if width <= 0 {
+		return s
+	}
+	return truncate(s, width-indent)
+}
+
+// padRight pads s with spaces to width cells.
+func padRight(s string, width int) string {
+	if gap := width - lipgloss.Width(s); gap > 0 {
+		return s + strings.Repeat(" ", gap)
+	}
+	return s
+}
+
+// fitColumns shrinks the preferred column widths proportionally so that they
+// add up to at most available cells. Every column keeps at least one cell.
+func fitColumns(preferred []int, available int) []int {
+	widths := make([]int, len(preferred))
+	copy(widths, preferred)
+
+	total := 0
+	for _, w := range preferred {
+		total += w
+	}
+	if available <= 0 || total <= available {
+		return widths
+	}
+
+	used := 0
+	for i, w := range preferred {
+		widths[i] = max(w*available/total, 1)
+		used += widths[i]
+	}
+
+	// Take back cells given to columns that were bumped up to one
+	for used > available {
+		widest := 0
+		for i := range widths {
+			if widths[i] > widths[widest] {
+				widest = i
+			}
+		}
+		if widths[widest] == 1 {
+			break
+		}
+		widths[widest]--
+		used--
+	}
+
+	// Hand out cells lost to rounding to the columns that lost the most
+	for used < available {
+		best := 0
+		for i := range widths {
+			if preferred[i]-widths[i] > preferred[best]-widths[best] {
+				best = i
+			}
+		}
+		widths[best]++
+		used++
+	}
+	return widths
+}
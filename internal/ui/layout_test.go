@@ -0,0 +1,78 @@
+package ui
+
+import (
+	"testing"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		in    string
+		width int
+		want  string
+	}{
+		{"short", 10, "short"},
+		{"exactly", 7, "exactly"},
+		{"truncated", 6, "trunc…"},
+		{"héllo wörld", 6, "héllo…"},
+		{"abc", 1, "…"},
+		{"abc", 0, ""},
+	}
+
+	for _, tt := range tests {
+		if got := truncate(tt.in, tt.width); got != tt.want {
+			t.Errorf("truncate(%q, %d) = %q, want %q", tt.in, tt.width, got, tt.want)
+		}
+		if got := truncate(tt.in, tt.width); lipgloss.Width(got) > tt.width {
+			t.Errorf("truncate(%q, %d) is %d cells wide", tt.in, tt.width, lipgloss.Width(got))
+		}
+	}
+}
+
+func TestFitLine_UnknownWidth(t *testing.T) {
+	long := "a line much longer than any terminal we pretend to have"
+	if got := fitLine(long, 4, 0); got != long {
+		t.Errorf("fitLine() with unknown width = %q, want the line unchanged", got)
+	}
+	if got := fitLine(long, 4, 20); lipgloss.Width(got) != 16 {
+		t.Errorf("fitLine() = %q, want 16 cells", got)
+	}
+}
+
+func TestFitColumns(t *testing.T) {
+	preferred := []int{20, 30, 30, 20, 40}
+
+	if got := fitColumns(preferred, 200); !equalInts(got, preferred) {
+		t.Errorf("fitColumns() with room to spare = %v, want %v", got, preferred)
+	}
+	if got := fitColumns(preferred, 0); !equalInts(got, preferred) {
+		t.Errorf("fitColumns() with unknown width = %v, want %v", got, preferred)
+	}
+
+	for _, available := range []int{5, 13, 54, 94, 139} {
+		got := fitColumns(preferred, available)
+		total := 0
+		for _, w := range got {
+			if w < 1 {
+				t.Errorf("fitColumns(%d) = %v, every column needs a cell", available, got)
+			}
+			total += w
+		}
+		if total != available {
+			t.Errorf("fitColumns(%d) = %v, adds up to %d", available, got, total)
+		}
+	}
+}
+
+func equalInts(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -3,41 +3,63 @@ package ui
 import (
 	"fmt"
 	"strings"
+	"text/tabwriter"
 
 	"github.com/thuanlegit/git-identitree/internal/profile"
+	"github.com/thuanlegit/git-identitree/internal/utils"
 	"github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 )
 
-var (
-	titleStyle = lipgloss.NewStyle().
-			Bold(true).
-			Foreground(lipgloss.Color("62")).
-			Padding(1, 0)
-
-	headerStyle = lipgloss.NewStyle().
-			Bold(true).
-			Foreground(lipgloss.Color("230")).
-			BorderBottom(true).
-			BorderStyle(lipgloss.NormalBorder()).
-			Padding(0, 1)
-
-	rowStyle = lipgloss.NewStyle().
-			Padding(0, 1)
+// listColumnWidths are the preferred widths of the Name, Author Name, Email,
+// GPG Key, SSH Key Path and Last Used columns. Narrow terminals shrink them
+// proportionally.
+var listColumnWidths = []int{20, 30, 30, 20, 40, 10}
+
+// ListAction is the action chosen in the profile list when it exits.
+type ListAction int
+
+const (
+	// ListActionNone means the list was closed without choosing an action.
+	ListActionNone ListAction = iota
+	// ListActionEdit opens the update form for the selected profile.
+	ListActionEdit
+	// ListActionDelete deletes the selected profile after confirmation.
+	ListActionDelete
+	// ListActionMap maps the selected profile to a directory.
+	ListActionMap
 )
 
 // ListModel is the Bubble Tea model for listing profiles.
 type ListModel struct {
-	profiles []profile.Profile
-	width    int
-	height   int
+	profiles      []profile.Profile
+	visible       []profile.Profile
+	filter        string
+	filtering     bool
+	cursor        int
+	showDetail    bool
+	confirmDelete bool
+	action        ListAction
+	width         int
+	height        int
+	// styles overrides the package styles; nil uses them.
+	styles *Styles
 }
 
 // NewListModel creates a new list model.
 func NewListModel(profiles []profile.Profile) *ListModel {
 	return &ListModel{
 		profiles: profiles,
+		visible:  profiles,
+	}
+}
+
+// theme returns the styles the model renders with.
+func (m *ListModel) theme() *Styles {
+	if m.styles != nil {
+		return m.styles
 	}
+	return &defaultStyles
 }
 
 // Init implements the tea.Model interface.
@@ -53,48 +75,282 @@ func (m *ListModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.height = msg.Height
 		return m, nil
 	case tea.KeyMsg:
+		if m.confirmDelete {
+			return m.updateConfirmDelete(msg)
+		}
+		if m.filtering {
+			return m.updateFilter(msg)
+		}
 		switch msg.String() {
-		case "q", "ctrl+c", "esc":
+		case "esc":
+			// Esc clears an active filter before it quits
+			if m.filter != "" {
+				m.setFilter("")
+				return m, nil
+			}
 			return m, tea.Quit
+		case "q", "ctrl+c":
+			return m, tea.Quit
+		case "/":
+			m.filtering = true
+			m.showDetail = false
+		case "up", "k":
+			if m.cursor > 0 {
+				m.cursor--
+			}
+		case "down", "j":
+			if m.cursor < len(m.visible)-1 {
+				m.cursor++
+			}
+		case "enter":
+			if len(m.visible) > 0 {
+				m.showDetail = !m.showDetail
+			}
+		case "e":
+			return m.choose(ListActionEdit)
+		case "m":
+			return m.choose(ListActionMap)
+		case "d":
+			if len(m.visible) > 0 {
+				m.confirmDelete = true
+			}
+		}
+	}
+	return m, nil
+}
+
+// updateFilter handles typing into the filter input.
+func (m *ListModel) updateFilter(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.Type {
+	case tea.KeyCtrlC:
+		return m, tea.Quit
+	case tea.KeyEsc:
+		m.filtering = false
+		m.setFilter("")
+	case tea.KeyEnter:
+		m.filtering = false
+	case tea.KeyBackspace:
+		if runes := []rune(m.filter); len(runes) > 0 {
+			m.setFilter(string(runes[:len(runes)-1]))
+		}
+	case tea.KeyRunes, tea.KeySpace:
+		m.setFilter(m.filter + string(msg.Runes))
+	case tea.KeyUp:
+		if m.cursor > 0 {
+			m.cursor--
+		}
+	case tea.KeyDown:
+		if m.cursor < len(m.visible)-1 {
+			m.cursor++
 		}
 	}
 	return m, nil
 }
 
+// setFilter updates the filter and the visible rows, keeping the cursor in range.
+func (m *ListModel) setFilter(filter string) {
+	m.filter = filter
+	m.visible = FilterProfiles(m.profiles, filter)
+	if m.cursor >= len(m.visible) {
+		m.cursor = max(len(m.visible)-1, 0)
+	}
+}
+
+// updateConfirmDelete handles the y/N prompt shown before deleting a profile.
+func (m *ListModel) updateConfirmDelete(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	m.confirmDelete = false
+	switch msg.String() {
+	case "y", "Y":
+		return m.choose(ListActionDelete)
+	case "ctrl+c":
+		return m, tea.Quit
+	}
+	return m, nil
+}
+
+// choose records an action for the selected profile and exits the program.
+func (m *ListModel) choose(action ListAction) (tea.Model, tea.Cmd) {
+	if len(m.visible) == 0 {
+		return m, nil
+	}
+	m.action = action
+	return m, tea.Quit
+}
+
+// Selected returns the action chosen when the list exited and the profile it applies to.
+// The profile is nil when no action was chosen.
+func (m *ListModel) Selected() (ListAction, *profile.Profile) {
+	if m.action == ListActionNone || m.cursor >= len(m.visible) {
+		return ListActionNone, nil
+	}
+	prof := m.visible[m.cursor]
+	return m.action, &prof
+}
+
 // View implements the tea.Model interface.
 func (m *ListModel) View() string {
+	st := m.theme()
 	if len(m.profiles) == 0 {
-		return titleStyle.Render("No profiles found. Create one with 'gidtree profile create'")
+		return st.Title.Render("No profiles found. Create one with 'gidtree profile create'")
 	}
 
 	var b strings.Builder
-	b.WriteString(titleStyle.Render("Git Identitree Profiles\n"))
+	b.WriteString(st.Title.Render("Git Identitree Profiles\n"))
 	b.WriteString("\n")
 
 	// Table header
-	header := headerStyle.Render(fmt.Sprintf("%-20s %-30s %-30s %-20s %-40s", "Name", "Author Name", "Email", "GPG Key", "SSH Key Path"))
+	widths := m.columnWidths()
+	header := st.Header.Render(m.formatRow(widths, "Name", "Author Name", "Email", "GPG Key", "SSH Key Path", "Last Used"))
 	b.WriteString(header)
 	b.WriteString("\n")
 
 	// Table rows
-	for _, prof := range m.profiles {
+	if len(m.visible) == 0 {
+		b.WriteString(st.Row.Render(m.formatRow(widths, "(no matches)", "", "", "", "", "")))
+		b.WriteString("\n")
+	}
+	for i, prof := range m.visible {
 		authorName := prof.GetAuthorName()
 		sshKey := prof.SSHKeyPath
 		if sshKey == "" {
 			sshKey = "(none)"
+		} else if m.width > 0 && lipgloss.Width(sshKey) > widths[4] {
+			// Spend the space on the key name rather than the home directory
+			sshKey = utils.AbbreviateHome(sshKey)
 		}
 		gpgKey := prof.GPGKeyID
 		if gpgKey == "" {
 			gpgKey = "(none)"
 		}
-		row := rowStyle.Render(fmt.Sprintf("%-20s %-30s %-30s %-20s %-40s", prof.Name, authorName, prof.Email, gpgKey, sshKey))
+		style := st.Row
+		if i == m.cursor {
+			style = st.SelectedRow
+		}
+		row := style.Render(m.formatRow(widths, prof.Name, authorName, emailLabel(prof), gpgKey, sshKey, prof.LastUsedLabel()))
 		b.WriteString(row)
 		b.WriteString("\n")
 	}
 
+	if m.showDetail && m.cursor < len(m.visible) {
+		b.WriteString("\n")
+		// The detail pane's border and padding take four cells
+		b.WriteString(st.Detail.Render(renderProfileDetail(m.visible[m.cursor], m.width-4)))
+		b.WriteString("\n")
+	}
+
 	b.WriteString("\n")
-	b.WriteString("Press 'q' to quit")
+	count := fmt.Sprintf("%d of %d profiles", len(m.visible), len(m.profiles))
+	if m.filtering || m.filter != "" {
+		prompt := "/" + m.filter
+		if m.filtering {
+			prompt += "█"
+		}
+		prompt = fitLine(prompt, lipgloss.Width(count)+2, m.width)
+		b.WriteString(prompt)
+		b.WriteString("  ")
+	}
+	b.WriteString(st.Help.Render(fitLine(count, 0, m.width)))
+	b.WriteString("\n")
+	switch {
+	case m.confirmDelete:
+		b.WriteString(st.Warning.Render(fitLine(fmt.Sprintf("Delete profile '%s'? (y/N)", m.visible[m.cursor].Name), 0, m.width)))
+	case m.filtering:
+		b.WriteString(st.Help.Render(fitLine("type to filter • enter apply • esc clear", 0, m.width)))
+	default:
+		b.WriteString(st.Help.Render(fitLine("↑/↓ move • / filter • enter details • e edit • d delete • m map • q quit", 0, m.width)))
+	}
 
 	return b.String()
 }
 
+// columnWidths returns the width of each table column for the current terminal.
+// Until the terminal size is known the columns keep their preferred widths.
+func (m *ListModel) columnWidths() []int {
+	// Row padding takes two cells and the column separators one each
+	return fitColumns(listColumnWidths, m.width-2-(len(listColumnWidths)-1))
+}
+
+// formatRow lays out one table row, truncating values that do not fit their
+// column once the terminal width is known.
+func (m *ListModel) formatRow(widths []int, values ...string) string {
+	cells := make([]string, len(values))
+	for i, v := range values {
+		if m.width > 0 {
+			v = truncate(v, widths[i])
+		}
+		cells[i] = padRight(v, widths[i])
+	}
+	return strings.Join(cells, " ")
+}
+
+// emailLabel returns the profile's email for the table, with a count of its
+// aliases, which the detail pane lists.
+func emailLabel(prof profile.Profile) string {
+	switch len(prof.EmailAliases) {
+	case 0:
+		return prof.Email
+	case 1:
+		return prof.Email + " (+1 alias)"
+	}
+	return fmt.Sprintf("%s (+%d aliases)", prof.Email, len(prof.EmailAliases))
+}
+
+// renderProfileDetail formats every setting of a profile for the detail pane,
+// truncating lines longer than width. A width of zero or less keeps them whole.
+func renderProfileDetail(prof profile.Profile, width int) string {
+	lines := []string{
+		fmt.Sprintf("Name:        %s", prof.Name),
+		fmt.Sprintf("Email:       %s", prof.Email),
+	}
+	if len(prof.EmailAliases) > 0 {
+		lines = append(lines, fmt.Sprintf("Aliases:     %s", strings.Join(prof.EmailAliases, ", ")))
+	}
+	lines = append(lines, fmt.Sprintf("Author Name: %s", prof.GetAuthorName()))
+	if prof.HasSeparateCommitter() {
+		lines = append(lines, fmt.Sprintf("Committer:   %s <%s>", prof.GetCommitterName(), prof.GetCommitterEmail()))
+	}
+	if prof.SSHKeyPath != "" {
+		sshKey := prof.SSHKeyPath
+		if width > 0 && lipgloss.Width(sshKey)+13 > width {
+			sshKey = utils.AbbreviateHome(sshKey)
+		}
+		lines = append(lines, fmt.Sprintf("SSH Key:     %s", sshKey))
+	}
+	if prof.GPGKeyID != "" {
+		lines = append(lines, fmt.Sprintf("GPG Key:     %s", prof.GPGKeyID))
+	}
+	if !prof.LastUsed.IsZero() {
+		lines = append(lines, fmt.Sprintf("Last Used:   %s", prof.LastUsedLabel()))
+	}
+	if width > 0 {
+		for i, line := range lines {
+			lines[i] = truncate(line, width)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// RenderProfilesPlain renders profiles as an unstyled table for output that
+// is not a terminal, with the columns of the interactive list.
+func RenderProfilesPlain(profiles []profile.Profile) string {
+	if len(profiles) == 0 {
+		return "No profiles found. Create one with 'gidtree profile create'\n"
+	}
+
+	var b strings.Builder
+	w := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "NAME\tAUTHOR NAME\tEMAIL\tGPG KEY\tSSH KEY PATH\tLAST USED")
+	for _, prof := range profiles {
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", prof.Name, prof.GetAuthorName(), prof.Email, orNone(prof.GPGKeyID), orNone(utils.AbbreviateHome(prof.SSHKeyPath)), prof.LastUsedLabel())
+	}
+	_ = w.Flush()
+	return b.String()
+}
+
+// orNone returns value, or "(none)" when it is empty.
+func orNone(value string) string {
+	if value == "" {
+		return "(none)"
+	}
+	return value
+}
@@ -3,9 +3,12 @@ package ui
 import (
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/thuanlegit/git-identitree/internal/profile"
+	"github.com/thuanlegit/git-identitree/internal/utils"
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
 )
 
 func TestNewListModel(t *testing.T) {
@@ -162,3 +165,340 @@ func TestListModel_View_Headers(t *testing.T) {
 	}
 }
 
+
+func keyMsg(key string) tea.KeyMsg {
+	switch key {
+	case "up":
+		return tea.KeyMsg{Type: tea.KeyUp}
+	case "down":
+		return tea.KeyMsg{Type: tea.KeyDown}
+	case "enter":
+		return tea.KeyMsg{Type: tea.KeyEnter}
+	case "esc":
+		return tea.KeyMsg{Type: tea.KeyEsc}
+	}
+	return tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(key)}
+}
+
+func TestListModel_Update_Navigation(t *testing.T) {
+	model := NewListModel([]profile.Profile{
+		{Name: "a", Email: "a@example.com"},
+		{Name: "b", Email: "b@example.com"},
+		{Name: "c", Email: "c@example.com"},
+	})
+
+	steps := []struct {
+		key    string
+		cursor int
+	}{
+		{"up", 0}, // stays at the top
+		{"down", 1},
+		{"j", 2},
+		{"j", 2}, // stays at the bottom
+		{"k", 1},
+		{"up", 0},
+	}
+	for _, step := range steps {
+		if _, cmd := model.Update(keyMsg(step.key)); cmd != nil {
+			t.Errorf("Update(%s) should not return a command", step.key)
+		}
+		if model.cursor != step.cursor {
+			t.Errorf("after %s cursor = %d, want %d", step.key, model.cursor, step.cursor)
+		}
+	}
+}
+
+func TestListModel_Update_Detail(t *testing.T) {
+	model := NewListModel([]profile.Profile{{Name: "work", Email: "work@example.com", GPGKeyID: "ABC123"}})
+
+	model.Update(keyMsg("enter"))
+	if !model.showDetail {
+		t.Fatal("enter should open the detail pane")
+	}
+	if view := model.View(); !strings.Contains(view, "ABC123") {
+		t.Error("detail pane should show the GPG key")
+	}
+
+	model.Update(keyMsg("enter"))
+	if model.showDetail {
+		t.Error("enter should close the detail pane again")
+	}
+}
+
+func TestListModel_View_EmailAliases(t *testing.T) {
+	model := NewListModel([]profile.Profile{
+		{Name: "work", Email: "work@example.com", EmailAliases: []string{"work@old.example.com", "jane@old.example.com"}},
+		{Name: "oss", Email: "oss@example.com", EmailAliases: []string{"oss@old.example.com"}},
+		{Name: "personal", Email: "me@example.com"},
+	})
+
+	view := model.View()
+	if !strings.Contains(view, "work@example.com (+2 aliases)") {
+		t.Errorf("list should count the aliases:\n%s", view)
+	}
+	if !strings.Contains(view, "oss@example.com (+1 alias)") {
+		t.Errorf("list should count a single alias:\n%s", view)
+	}
+	if strings.Contains(view, "me@example.com (+") || strings.Contains(view, "work@old.example.com") {
+		t.Errorf("list should show only the count, and only for profiles with aliases:\n%s", view)
+	}
+
+	model.Update(keyMsg("enter"))
+	if view := model.View(); !strings.Contains(view, "Aliases:     work@old.example.com, jane@old.example.com") {
+		t.Errorf("detail pane should list the aliases:\n%s", view)
+	}
+}
+
+func TestRenderProfileDetail_Committer(t *testing.T) {
+	prof := profile.Profile{Name: "work", Email: "work@example.com", AuthorName: "Jane"}
+	if detail := renderProfileDetail(prof, 0); strings.Contains(detail, "Committer") {
+		t.Errorf("detail should not show a committer that is the author:\n%s", detail)
+	}
+	prof.CommitterName = "Release Bot"
+	prof.CommitterEmail = "bot@example.com"
+	if detail := renderProfileDetail(prof, 0); !strings.Contains(detail, "Committer:   Release Bot <bot@example.com>") {
+		t.Errorf("detail should show the committer:\n%s", detail)
+	}
+}
+
+func TestListModel_Update_Actions(t *testing.T) {
+	profiles := []profile.Profile{
+		{Name: "a", Email: "a@example.com"},
+		{Name: "b", Email: "b@example.com"},
+	}
+
+	tests := []struct {
+		name    string
+		keys    []string
+		action  ListAction
+		profile string
+		quits   bool
+	}{
+		{name: "edit", keys: []string{"down", "e"}, action: ListActionEdit, profile: "b", quits: true},
+		{name: "map", keys: []string{"m"}, action: ListActionMap, profile: "a", quits: true},
+		{name: "delete confirmed", keys: []string{"down", "d", "y"}, action: ListActionDelete, profile: "b", quits: true},
+		{name: "delete declined", keys: []string{"d", "n"}, action: ListActionNone},
+		{name: "quit", keys: []string{"q"}, action: ListActionNone, quits: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			model := NewListModel(profiles)
+			var cmd tea.Cmd
+			for _, key := range tt.keys {
+				_, cmd = model.Update(keyMsg(key))
+			}
+			if (cmd != nil) != tt.quits {
+				t.Errorf("last Update() returned command = %v, want quit %v", cmd != nil, tt.quits)
+			}
+
+			action, prof := model.Selected()
+			if action != tt.action {
+				t.Errorf("Selected() action = %v, want %v", action, tt.action)
+			}
+			if tt.profile == "" {
+				if prof != nil {
+					t.Errorf("Selected() profile = %v, want nil", prof.Name)
+				}
+				return
+			}
+			if prof == nil || prof.Name != tt.profile {
+				t.Errorf("Selected() profile = %v, want %s", prof, tt.profile)
+			}
+		})
+	}
+}
+
+func TestListModel_Update_DeletePrompt(t *testing.T) {
+	model := NewListModel([]profile.Profile{{Name: "work", Email: "work@example.com"}})
+
+	model.Update(keyMsg("d"))
+	if !model.confirmDelete {
+		t.Fatal("d should ask for confirmation")
+	}
+	if view := model.View(); !strings.Contains(view, "Delete profile 'work'?") {
+		t.Error("View() should show the delete confirmation")
+	}
+
+	// Any key other than y cancels, including q
+	if _, cmd := model.Update(keyMsg("q")); cmd != nil {
+		t.Error("q in the confirmation should cancel, not quit")
+	}
+	if model.confirmDelete {
+		t.Error("confirmation should be dismissed")
+	}
+}
+
+func TestListModel_Update_ActionsWithoutProfiles(t *testing.T) {
+	model := NewListModel([]profile.Profile{})
+	for _, key := range []string{"e", "m", "d", "enter", "j"} {
+		if _, cmd := model.Update(keyMsg(key)); cmd != nil {
+			t.Errorf("Update(%s) on an empty list should do nothing", key)
+		}
+	}
+	if action, _ := model.Selected(); action != ListActionNone {
+		t.Errorf("Selected() = %v, want none", action)
+	}
+}
+
+func TestListModel_Update_Filter(t *testing.T) {
+	model := NewListModel([]profile.Profile{
+		{Name: "work", Email: "work@acme.example"},
+		{Name: "personal", Email: "me@home.example"},
+		{Name: "oss", Email: "oss@example.org"},
+	})
+
+	model.Update(keyMsg("down"))
+	model.Update(keyMsg("down"))
+	model.Update(keyMsg("/"))
+	if !model.filtering {
+		t.Fatal("/ should open the filter input")
+	}
+
+	// Keys that normally act are typed into the filter
+	for _, key := range []string{"q", "w"} {
+		if _, cmd := model.Update(keyMsg(key)); cmd != nil {
+			t.Errorf("typing %q into the filter should not quit", key)
+		}
+	}
+	if model.filter != "qw" || len(model.visible) != 0 {
+		t.Errorf("filter = %q with %d visible, want qw with none", model.filter, len(model.visible))
+	}
+	if view := model.View(); !strings.Contains(view, "(no matches)") || !strings.Contains(view, "0 of 3 profiles") {
+		t.Errorf("View() should show no matches and the count, got:\n%s", view)
+	}
+
+	model.Update(tea.KeyMsg{Type: tea.KeyBackspace})
+	model.Update(tea.KeyMsg{Type: tea.KeyBackspace})
+	model.Update(keyMsg("wrk"))
+	if len(model.visible) != 1 || model.visible[0].Name != "work" {
+		t.Fatalf("visible = %v, want work only", model.visible)
+	}
+	if model.cursor != 0 {
+		t.Errorf("cursor = %d, want clamped to 0", model.cursor)
+	}
+
+	// Enter applies the filter; actions then refer to the filtered rows
+	model.Update(keyMsg("enter"))
+	if model.filtering {
+		t.Error("enter should close the filter input")
+	}
+	if view := model.View(); !strings.Contains(view, "1 of 3 profiles") {
+		t.Errorf("View() should show the filtered count, got:\n%s", view)
+	}
+
+	// Esc clears the filter instead of quitting
+	if _, cmd := model.Update(keyMsg("esc")); cmd != nil {
+		t.Error("esc with an active filter should clear it, not quit")
+	}
+	if model.filter != "" || len(model.visible) != 3 {
+		t.Errorf("filter = %q with %d visible, want cleared", model.filter, len(model.visible))
+	}
+
+	model.Update(keyMsg("/"))
+	model.Update(keyMsg("oss"))
+	model.Update(keyMsg("enter"))
+	_, cmd := model.Update(keyMsg("e"))
+	if cmd == nil {
+		t.Fatal("e should choose the edit action")
+	}
+	if action, prof := model.Selected(); action != ListActionEdit || prof.Name != "oss" {
+		t.Errorf("Selected() = %v %v, want edit oss", action, prof)
+	}
+}
+
+func TestListModel_Update_FilterEscClears(t *testing.T) {
+	model := NewListModel([]profile.Profile{{Name: "work"}, {Name: "personal"}})
+
+	model.Update(keyMsg("/"))
+	model.Update(keyMsg("per"))
+	model.Update(keyMsg("esc"))
+	if model.filtering || model.filter != "" || len(model.visible) != 2 {
+		t.Errorf("esc in the filter input should clear it: filtering=%v filter=%q visible=%d",
+			model.filtering, model.filter, len(model.visible))
+	}
+}
+
+// assertFitsWidth fails the test when any line of view is wider than width.
+func assertFitsWidth(t *testing.T, view string, width int) {
+	t.Helper()
+	for i, line := range strings.Split(view, "\n") {
+		if w := lipgloss.Width(line); w > width {
+			t.Errorf("line %d is %d cells wide, terminal is %d:\n%s", i, w, width, line)
+		}
+	}
+}
+
+func TestListModel_View_FitsTerminalWidth(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv(utils.RootEnv, home)
+
+	profiles := []profile.Profile{
+		{
+			Name:       "work-with-a-rather-long-profile-name",
+			Email:      "firstname.lastname@a-very-long-company-domain.example.com",
+			AuthorName: "Firstname Middlename Lastname",
+			SSHKeyPath: home + "/.ssh/keys/company/id_ed25519_work_laptop",
+			GPGKeyID:   "0123456789ABCDEF0123456789ABCDEF",
+		},
+		{Name: "personal", Email: "me@example.com"},
+	}
+
+	for _, width := range []int{60, 100, 160} {
+		model := NewListModel(profiles)
+		model.Update(tea.WindowSizeMsg{Width: width, Height: 40})
+		assertFitsWidth(t, model.View(), width)
+
+		// The detail pane and filter prompt must fit as well
+		model.Update(keyMsg("enter"))
+		assertFitsWidth(t, model.View(), width)
+		model.Update(keyMsg("/"))
+		model.Update(keyMsg("a-filter-longer-than-the-narrowest-terminal-can-show"))
+		assertFitsWidth(t, model.View(), width)
+	}
+}
+
+func TestListModel_View_ShortensSSHKeyPath(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv(utils.RootEnv, home)
+
+	model := NewListModel([]profile.Profile{
+		{Name: "work", Email: "work@example.com", SSHKeyPath: home + "/.ssh/id_work"},
+	})
+	model.Update(tea.WindowSizeMsg{Width: 100, Height: 40})
+
+	if view := model.View(); !strings.Contains(view, "~/.ssh/id_work") {
+		t.Errorf("ListModel.View() should abbreviate the home directory in SSH key paths, got:\n%s", view)
+	}
+}
+
+func TestRenderProfilesPlain(t *testing.T) {
+	out := RenderProfilesPlain([]profile.Profile{
+		{Name: "work", Email: "work@example.com", AuthorName: "Jane Doe", SSHKeyPath: "/keys/id_work", LastUsed: time.Date(2025, 3, 4, 12, 0, 0, 0, time.Local)},
+		{Name: "personal", Email: "me@example.com"},
+	})
+
+	lines := strings.Split(strings.TrimSuffix(out, "\n"), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("RenderProfilesPlain() = %d lines, want a header and 2 rows:\n%s", len(lines), out)
+	}
+	if !strings.HasPrefix(lines[0], "NAME") {
+		t.Errorf("header = %q, want it to start with NAME", lines[0])
+	}
+	if !strings.Contains(lines[1], "Jane Doe") || !strings.Contains(lines[1], "/keys/id_work") {
+		t.Errorf("row = %q, want the author name and key path", lines[1])
+	}
+	if !strings.Contains(lines[2], "(none)") {
+		t.Errorf("row = %q, want (none) for unset fields", lines[2])
+	}
+	if !strings.HasSuffix(lines[1], "2025-03-04") || !strings.HasSuffix(lines[2], "never") {
+		t.Errorf("rows = %q, want the last use, or never", lines[1:])
+	}
+	if strings.Contains(out, "\x1b[") {
+		t.Errorf("RenderProfilesPlain() should not contain escape codes:\n%q", out)
+	}
+
+	if out := RenderProfilesPlain(nil); !strings.Contains(out, "No profiles found") {
+		t.Errorf("RenderProfilesPlain(nil) = %q, want a hint", out)
+	}
+}
@@ -1,167 +1,677 @@
 package ui
 
 import (
+	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 
+	"github.com/thuanlegit/git-identitree/internal/mapping"
 	"github.com/thuanlegit/git-identitree/internal/profile"
+	"github.com/thuanlegit/git-identitree/internal/ssh"
+	"github.com/thuanlegit/git-identitree/internal/utils"
 	"github.com/charmbracelet/huh"
 )
 
-// getSSHKeySuggestions returns a list of SSH key paths from ~/.ssh directory.
-func getSSHKeySuggestions() []string {
-	homeDir, err := os.UserHomeDir()
-	if err != nil {
-		return []string{}
+// ErrCancelled is returned by a profile form answered with cancel on its
+// summary.
+var ErrCancelled = errors.New("cancelled")
+
+// agentPublicKeys lists the keys of the SSH agent; replaced in tests.
+var agentPublicKeys = ssh.AgentPublicKeys
+
+// customKeyPath is the choice of the SSH key select that asks for a path.
+const customKeyPath = "\x00custom"
+
+// getSSHKeySuggestions returns a list of SSH key paths: private keys and
+// *.pem files in ~/.ssh, the existing IdentityFile keys of ~/.ssh/config,
+// then keys the SSH agent holds whose comment is the path of a file, as
+// ssh-add records for keys without a comment. hosts maps the paths taken from
+// ~/.ssh/config to the Host they are configured for.
+func getSSHKeySuggestions() (suggestions []string, hosts map[string]string) {
+	hosts = make(map[string]string)
+	add := func(path string) string {
+		path = filepath.ToSlash(utils.AbbreviateHome(path))
+		if !slices.Contains(suggestions, path) {
+			suggestions = append(suggestions, path)
+		}
+		return path
+	}
+	isFile := func(path string) bool {
+		info, err := os.Stat(path)
+		return err == nil && !info.IsDir()
 	}
 
-	sshDir := filepath.Join(homeDir, ".ssh")
-	entries, err := os.ReadDir(sshDir)
-	if err != nil {
-		return []string{}
+	if homeDir, err := utils.Root(); err == nil {
+		entries, _ := os.ReadDir(filepath.Join(homeDir, ".ssh"))
+		for _, entry := range entries {
+			if entry.IsDir() {
+				continue
+			}
+			name := entry.Name()
+			// Filter for common private key files (exclude .pub files)
+			if !strings.HasSuffix(name, ".pub") &&
+				(strings.HasPrefix(name, "id_") || strings.HasSuffix(name, ".pem") || name == "github" || name == "gitlab" || name == "bitbucket") {
+				// Use forward slashes for cross-platform compatibility
+				suggestions = append(suggestions, "~/.ssh/"+name)
+			}
+		}
+
+		files, _ := ssh.ConfigIdentityFiles(filepath.Join(homeDir, ".ssh", "config"), homeDir)
+		for _, f := range files {
+			if isFile(f.Path) {
+				path := add(f.Path)
+				if _, ok := hosts[path]; !ok {
+					hosts[path] = f.Host
+				}
+			}
+		}
 	}
 
-	var suggestions []string
-	for _, entry := range entries {
-		if entry.IsDir() {
-			continue
+	keys, _ := agentPublicKeys()
+	for _, key := range keys {
+		expanded, err := utils.ExpandPath(key.Comment)
+		if err == nil && filepath.IsAbs(expanded) && isFile(expanded) {
+			add(expanded)
 		}
-		name := entry.Name()
-		// Filter for common private key files (exclude .pub files)
-		if !strings.HasSuffix(name, ".pub") &&
-			(strings.HasPrefix(name, "id_") || name == "github" || name == "gitlab" || name == "bitbucket") {
-			// Use forward slashes for cross-platform compatibility
-			suggestions = append(suggestions, "~/.ssh/"+name)
+	}
+
+	return suggestions, hosts
+}
+
+// sshKeyPaths returns the paths of getSSHKeySuggestions.
+func sshKeyPaths() []string {
+	paths, _ := getSSHKeySuggestions()
+	return paths
+}
+
+// sshKeyOptions returns the choices of the SSH key select: no key, each
+// suggested key described by its public key and the Host in hosts it is
+// configured for, current when it is not one of them, and entering a path.
+func sshKeyOptions(suggestions []string, hosts map[string]string, current string) []huh.Option[string] {
+	if current != "" && current != customKeyPath && !slices.Contains(suggestions, current) {
+		suggestions = append(slices.Clone(suggestions), current)
+	}
+	options := []huh.Option[string]{huh.NewOption("(none)", "")}
+	for _, path := range suggestions {
+		options = append(options, huh.NewOption(sshKeyLabel(path, hosts[path]), path))
+	}
+	return append(options, huh.NewOption("(enter custom path…)", customKeyPath))
+}
+
+// sshKeyLabel returns path with the type and comment of the public key next
+// to it, when there is one, and the Host from ~/.ssh/config it is for.
+func sshKeyLabel(path, host string) string {
+	var notes []string
+	if expanded, err := utils.ExpandPath(path); err == nil {
+		if key, err := ssh.ReadPublicKey(expanded + ".pub"); err == nil {
+			notes = append(notes, key.Describe())
 		}
 	}
+	if host != "" {
+		notes = append(notes, "Host "+host)
+	}
+	if len(notes) == 0 {
+		return path
+	}
+	return fmt.Sprintf("%s (%s)", path, strings.Join(notes, "; "))
+}
+
+// CreateProfileForm creates an interactive form for profile creation. The
+// form starts out with the values of initial, such as the answers to a form
+// whose profile could not be saved, and ends with a summary to save, edit or
+// cancel; cancelling returns ErrCancelled.
+func CreateProfileForm(initial profile.Profile) (*profile.Profile, error) {
+	answers := answersFrom(initial)
+	groups := func() []*huh.Group {
+		return answers.groups(huh.NewInput().
+			Title("Profile Name").
+			Description("A unique name for this profile: letters, digits, '-', '_' and '.'").
+			Value(&answers.Name).
+			Validate(profile.ValidateName))
+	}
+	if err := runWithSummary(groups, &answers, initial); err != nil {
+		return nil, err
+	}
+
+	prof := answers.profile(initial)
+	return &prof, nil
+}
+
+// TemplateProfileForm creates an interactive form for a profile based on tmpl.
+// It asks for the template's placeholders and for the fields the template
+// does not pin, and returns the rendered profile with the placeholder values.
+func TemplateProfileForm(tmpl *profile.Template) (*profile.Profile, map[string]string, error) {
+	placeholders := tmpl.Placeholders()
+	answers := make([]string, len(placeholders))
+	var fields []huh.Field
+	for i, name := range placeholders {
+		fields = append(fields, huh.NewInput().
+			Title(name).
+			Description("Value for {{ ."+name+" }} in the template").
+			Value(&answers[i]).
+			Validate(required("a value for "+name)))
+	}
+
+	var base profile.Profile
+	if tmpl.Name == "" {
+		fields = append(fields, huh.NewInput().
+			Title("Profile Name").
+			Description("A unique name for this profile: letters, digits, '-', '_' and '.'").
+			Value(&base.Name).
+			Validate(profile.ValidateName))
+	}
+	if tmpl.Email == "" {
+		fields = append(fields, huh.NewInput().
+			Title("Email").
+			Description("Git email address for this profile").
+			Value(&base.Email).
+			Validate(validateEmail))
+	}
+	if tmpl.AuthorName == "" {
+		fields = append(fields, huh.NewInput().
+			Title("Author Name").
+			Description("Git author name (optional, defaults to profile name)").
+			Value(&base.AuthorName))
+	}
+	if tmpl.SSHKeyPath == "" {
+		fields = append(fields, huh.NewInput().
+			Title("SSH Key Path").
+			Description("Path to SSH private key (optional)").
+			Placeholder("~/.ssh/id_rsa").
+			Suggestions(sshKeyPaths()).
+			Value(&base.SSHKeyPath).
+			Validate(profile.ValidateSSHKeyPath))
+	}
+	if tmpl.GPGKeyID == "" {
+		gpg := huh.NewInput().
+			Title("GPG Key ID").
+			Description("GPG key ID for signing commits (optional)").
+			Value(&base.GPGKeyID)
+		if tmpl.SigningRequired {
+			gpg.Description("GPG key ID for signing commits (required by the template)").Validate(required("a GPG key ID"))
+		}
+		fields = append(fields, gpg)
+	}
+
+	if len(fields) > 0 {
+		if err := huh.NewForm(huh.NewGroup(fields...)).Run(); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	values := make(map[string]string, len(placeholders))
+	for i, name := range placeholders {
+		values[name] = answers[i]
+	}
+	prof, err := tmpl.Render(values, base)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &prof, values, nil
+}
+
+// UpdateProfileForm creates an interactive form for updating an existing profile.
+// The form is pre-populated with the current profile values and ends with a
+// summary to save, edit or cancel; cancelling returns ErrCancelled.
+func UpdateProfileForm(currentProfile *profile.Profile) (*profile.Profile, error) {
+	answers := answersFrom(*currentProfile)
+	groups := func() []*huh.Group {
+		return answers.groups(huh.NewInput().
+			Title("Profile Name").
+			Description("A unique name for this profile (cannot be changed)").
+			Value(&answers.Name).
+			Validate(unchangedName(currentProfile.Name)))
+	}
+	if err := runWithSummary(groups, &answers, *currentProfile); err != nil {
+		return nil, err
+	}
 
-	return suggestions
+	// Fields the form does not show are kept
+	prof := answers.profile(*currentProfile)
+	return &prof, nil
 }
 
-// CreateProfileForm creates an interactive form for profile creation.
-func CreateProfileForm() (*profile.Profile, error) {
-	var name, email, authorName, sshKeyPath, gpgKeyID string
+// MapDirectoryForm asks for the directory a profile should be mapped to.
+// The profile is already chosen, so only the directory is prompted for.
+// The input starts out as initial, such as a template's directory prefix.
+func MapDirectoryForm(profileName, initial string) (string, error) {
+	dir := initial
 
 	form := huh.NewForm(
 		huh.NewGroup(
 			huh.NewInput().
-				Title("Profile Name").
-				Description("A unique name for this profile").
-				Value(&name).
-				Validate(func(s string) error {
-					if s == "" {
-						return os.ErrInvalid
-					}
-					return nil
-				}),
-			huh.NewInput().
-				Title("Email").
-				Description("Git email address for this profile").
-				Value(&email).
-				Validate(func(s string) error {
-					if s == "" {
-						return os.ErrInvalid
-					}
-					return nil
-				}),
-			huh.NewInput().
-				Title("Author Name").
-				Description("Git author name (optional, defaults to profile name)").
-				Value(&authorName),
-			huh.NewInput().
-				Title("SSH Key Path").
-				Description("Path to SSH private key (optional)").
-				Placeholder("~/.ssh/id_rsa").
-				Suggestions(getSSHKeySuggestions()).
-				Value(&sshKeyPath),
-			huh.NewInput().
-				Title("GPG Key ID").
-				Description("GPG key ID for signing commits (optional)").
-				Value(&gpgKeyID),
+				Title("Directory").
+				Description("Directory to map to profile '" + profileName + "'").
+				Placeholder("~/projects/" + profileName).
+				Value(&dir).
+				Validate(required("a directory")),
 		),
 	)
 
 	if err := form.Run(); err != nil {
-		return nil, err
+		return "", err
 	}
 
-	prof := &profile.Profile{
-		Name:       name,
-		Email:      email,
-		AuthorName: authorName,
-		SSHKeyPath: sshKeyPath,
-		GPGKeyID:   gpgKeyID,
+	return strings.TrimSpace(dir), nil
+}
+
+// MoveDirectoryForm asks where a mapped directory that no longer exists has
+// moved to. An empty answer, or leaving the form, returns "" to skip it.
+func MoveDirectoryForm(dir, profileName string) (string, error) {
+	var moved string
+
+	form := huh.NewForm(
+		huh.NewGroup(
+			huh.NewInput().
+				Title("New location of "+dir).
+				Description("Mapped to profile '"+profileName+"'; leave empty to skip").
+				Value(&moved),
+		),
+	)
+
+	if err := form.Run(); err != nil {
+		if errors.Is(err, huh.ErrUserAborted) {
+			return "", nil
+		}
+		return "", err
 	}
 
-	return prof, nil
+	return strings.TrimSpace(moved), nil
 }
 
-// UpdateProfileForm creates an interactive form for updating an existing profile.
-// The form is pre-populated with the current profile values.
-func UpdateProfileForm(currentProfile *profile.Profile) (*profile.Profile, error) {
-	// Pre-populate with current values
-	name := currentProfile.Name
-	email := currentProfile.Email
-	authorName := currentProfile.AuthorName
-	sshKeyPath := currentProfile.SSHKeyPath
-	gpgKeyID := currentProfile.GPGKeyID
+// ConfirmForm asks a yes/no question, defaulting to no.
+func ConfirmForm(title, description string) (bool, error) {
+	var confirmed bool
 
 	form := huh.NewForm(
 		huh.NewGroup(
-			huh.NewInput().
-				Title("Profile Name").
-				Description("A unique name for this profile (cannot be changed)").
-				Value(&name).
-				Validate(func(s string) error {
-					if s == "" {
-						return os.ErrInvalid
-					}
-					// Ensure name hasn't changed
-					if s != currentProfile.Name {
-						return os.ErrInvalid
-					}
-					return nil
-				}),
+			huh.NewConfirm().
+				Title(title).
+				Description(description).
+				Affirmative("Yes").
+				Negative("No").
+				Value(&confirmed),
+		),
+	)
+
+	if err := form.Run(); err != nil {
+		return false, err
+	}
+
+	return confirmed, nil
+}
+
+// PassphraseForm asks for a passphrase without echoing it. With confirm set
+// the passphrase has to be typed twice, for choosing a new one.
+func PassphraseForm(title string, confirm bool) (string, error) {
+	var passphrase, repeated string
+
+	fields := []huh.Field{
+		huh.NewInput().
+			Title(title).
+			EchoMode(huh.EchoModePassword).
+			Value(&passphrase).
+			Validate(func(s string) error {
+				if s == "" {
+					return errors.New("passphrase cannot be empty")
+				}
+				return nil
+			}),
+	}
+	if confirm {
+		fields = append(fields, huh.NewInput().
+			Title("Repeat passphrase").
+			EchoMode(huh.EchoModePassword).
+			Value(&repeated).
+			Validate(func(s string) error {
+				if s != passphrase {
+					return errors.New("passphrases do not match")
+				}
+				return nil
+			}))
+	}
+
+	if err := huh.NewForm(huh.NewGroup(fields...)).Run(); err != nil {
+		return "", err
+	}
+
+	return passphrase, nil
+}
+
+// CloneProfileForm creates an interactive form for a new profile based on source.
+// Every field is pre-populated from source except the name, which starts as
+// name (usually empty) and must differ from the source's.
+func CloneProfileForm(source *profile.Profile, name string) (*profile.Profile, error) {
+	base := source.CloneAs(name)
+	answers := answersFrom(base)
+
+	form := huh.NewForm(answers.groups(huh.NewInput().
+		Title("Profile Name").
+		Description("A unique name for the new profile").
+		Value(&answers.Name).
+		Validate(cloneName(source.Name)))...)
+
+	if err := form.Run(); err != nil {
+		return nil, err
+	}
+
+	prof := answers.profile(base)
+	return &prof, nil
+}
+
+// profileAnswers holds the answers of a profile form, with lists entered as
+// comma-separated text.
+type profileAnswers struct {
+	Name             string
+	Email            string
+	EmailAliases     string
+	AuthorName       string
+	CommitterName    string
+	CommitterEmail   string
+	SSHKeyPath       string
+	GPGKeyID         string
+	RemotePatterns   string
+	IsolateSSHConfig bool
+	SSHSigning       bool
+	// SSHKeyChoice is the key picked from the list: a path, "" for none or
+	// customKeyPath to use SSHKeyPath instead.
+	SSHKeyChoice string
+}
+
+// answersFrom returns the answers that leave prof as it is.
+func answersFrom(prof profile.Profile) profileAnswers {
+	return profileAnswers{
+		Name:             prof.Name,
+		Email:            prof.Email,
+		EmailAliases:     strings.Join(prof.EmailAliases, ", "),
+		AuthorName:       prof.AuthorName,
+		CommitterName:    prof.CommitterName,
+		CommitterEmail:   prof.CommitterEmail,
+		SSHKeyPath:       prof.SSHKeyPath,
+		GPGKeyID:         prof.GPGKeyID,
+		RemotePatterns:   strings.Join(prof.RemotePatterns, ", "),
+		IsolateSSHConfig: prof.IsolateSSHConfig,
+		SSHSigning:       prof.SSHSigning,
+		SSHKeyChoice:     prof.SSHKeyPath,
+	}
+}
+
+// profile returns base with the answers applied. Settings the form does not
+// ask for are kept from base.
+func (a *profileAnswers) profile(base profile.Profile) profile.Profile {
+	prof := base.Clone()
+	prof.Name = a.Name
+	prof.Email = a.Email
+	prof.EmailAliases = splitPatterns(a.EmailAliases)
+	prof.AuthorName = a.AuthorName
+	prof.CommitterName = a.CommitterName
+	prof.CommitterEmail = a.CommitterEmail
+	prof.SSHKeyPath = a.SSHKeyPath
+	if a.SSHKeyChoice != customKeyPath {
+		prof.SSHKeyPath = a.SSHKeyChoice
+	}
+	prof.GPGKeyID = a.GPGKeyID
+	prof.RemotePatterns = splitPatterns(a.RemotePatterns)
+	prof.IsolateSSHConfig = a.IsolateSSHConfig
+	prof.SSHSigning = a.SSHSigning
+	return prof
+}
+
+// groups returns the groups of a profile form: the fields, starting with
+// the name input, which differs between creating, updating and cloning, and
+// the SSH key path input, shown when the key list does not have the key.
+func (a *profileAnswers) groups(name *huh.Input) []*huh.Group {
+	suggestions, hosts := getSSHKeySuggestions()
+	return []*huh.Group{
+		huh.NewGroup(
+			name,
 			huh.NewInput().
 				Title("Email").
 				Description("Git email address for this profile").
-				Value(&email).
+				Value(&a.Email).
+				Validate(validateEmail),
+			emailAliasesInput(&a.EmailAliases),
+			huh.NewInput().
+				Title("Author Name").
+				Description("Git author name (optional, defaults to profile name)").
+				Value(&a.AuthorName),
+			committerNameInput(&a.CommitterName),
+			committerEmailInput(&a.CommitterEmail),
+			huh.NewSelect[string]().
+				Title("SSH Key").
+				Description("SSH private key for this profile").
+				Options(sshKeyOptions(suggestions, hosts, a.SSHKeyChoice)...).
+				Value(&a.SSHKeyChoice).
 				Validate(func(s string) error {
-					if s == "" {
-						return os.ErrInvalid
+					if s == customKeyPath {
+						return nil
 					}
-					return nil
+					return profile.ValidateSSHKeyPath(s)
 				}),
 			huh.NewInput().
-				Title("Author Name").
-				Description("Git author name (optional, defaults to profile name)").
-				Value(&authorName),
+				Title("GPG Key ID").
+				Description("GPG key ID for signing commits (optional)").
+				Value(&a.GPGKeyID),
+			remotePatternsInput(&a.RemotePatterns),
+			isolateSSHConfigInput(&a.IsolateSSHConfig),
+			sshSigningInput(&a.SSHSigning),
+		),
+		huh.NewGroup(
 			huh.NewInput().
 				Title("SSH Key Path").
 				Description("Path to SSH private key (optional)").
 				Placeholder("~/.ssh/id_rsa").
-				Suggestions(getSSHKeySuggestions()).
-				Value(&sshKeyPath),
-			huh.NewInput().
-				Title("GPG Key ID").
-				Description("GPG key ID for signing commits (optional)").
-				Value(&gpgKeyID),
-		),
-	)
+				Suggestions(suggestions).
+				Value(&a.SSHKeyPath).
+				Validate(profile.ValidateSSHKeyPath),
+		).WithHideFunc(func() bool {
+			return a.SSHKeyChoice != customKeyPath
+		}),
+	}
+}
 
-	if err := form.Run(); err != nil {
-		return nil, err
+// summaryChoice is the answer to the summary that ends a profile form.
+type summaryChoice int
+
+const (
+	summarySave summaryChoice = iota
+	summaryEdit
+	summaryCancel
+)
+
+// runWithSummary runs a form of the given groups followed by a summary of the
+// profile they describe, until the summary is answered with save. Edit runs
+// the form again with the answers kept; cancel returns ErrCancelled.
+func runWithSummary(groups func() []*huh.Group, answers *profileAnswers, base profile.Profile) error {
+	for {
+		choice := summarySave
+		form := huh.NewForm(append(groups(),
+			huh.NewGroup(
+				huh.NewNote().
+					Title("Summary").
+					DescriptionFunc(func() string {
+						prof := answers.profile(base)
+						return RenderProfileSummary(prof, profileConfigPath(prof.Name))
+					}, answers),
+				huh.NewSelect[summaryChoice]().
+					Title("Save this profile?").
+					Options(
+						huh.NewOption("Save", summarySave),
+						huh.NewOption("Edit", summaryEdit),
+						huh.NewOption("Cancel", summaryCancel),
+					).
+					Value(&choice),
+			))...)
+		if err := form.Run(); err != nil {
+			return err
+		}
+		switch choice {
+		case summaryEdit:
+			continue
+		case summaryCancel:
+			return ErrCancelled
+		}
+		return nil
+	}
+}
+
+// profileConfigPath returns where the git config of the named profile is
+// written, with the home directory as ~.
+func profileConfigPath(name string) string {
+	path, err := mapping.GetProfileConfigPath(name)
+	if err != nil {
+		return "~/.gitconfig-" + name
+	}
+	return utils.AbbreviateHome(path)
+}
+
+// RenderProfileSummary formats every setting of prof for a last look before
+// it is saved, followed by configPath, the git config file written for it
+// once a directory is mapped to it.
+func RenderProfileSummary(prof profile.Profile, configPath string) string {
+	lines := []string{renderProfileDetail(prof, 0)}
+	if len(prof.RemotePatterns) > 0 {
+		lines = append(lines, fmt.Sprintf("Remotes:     %s", strings.Join(prof.RemotePatterns, ", ")))
+	}
+	if prof.IsolateSSHConfig {
+		lines = append(lines, "SSH Config:  ignored (-F /dev/null)")
+	}
+	if prof.SSHSigning {
+		lines = append(lines, "Signing:     SSH key")
+	}
+	lines = append(lines, "", fmt.Sprintf("Git config %s is written when a directory is mapped to the profile.", configPath))
+	return strings.Join(lines, "\n")
+}
+
+// validateEmail checks a required email address.
+func validateEmail(s string) error {
+	if s == "" {
+		return errors.New("enter an email address")
 	}
+	return profile.ValidateEmail(s)
+}
 
-	prof := &profile.Profile{
-		Name:       name,
-		Email:      email,
-		AuthorName: authorName,
-		SSHKeyPath: sshKeyPath,
-		GPGKeyID:   gpgKeyID,
+// validateEmailList checks each email of a comma-separated list.
+func validateEmailList(s string) error {
+	for _, email := range splitPatterns(s) {
+		if err := profile.ValidateEmail(email); err != nil {
+			return err
+		}
 	}
+	return nil
+}
 
-	return prof, nil
+// required returns a validator rejecting an empty answer, naming what is
+// missing.
+func required(what string) func(string) error {
+	return func(s string) error {
+		if strings.TrimSpace(s) == "" {
+			return fmt.Errorf("enter %s", what)
+		}
+		return nil
+	}
 }
 
+// unchangedName returns a validator for the name field of the update form,
+// which can only show the name.
+func unchangedName(current string) func(string) error {
+	return func(s string) error {
+		if s != current {
+			return fmt.Errorf("the name cannot be changed here; use: gidtree profile rename %q <new-name>", current)
+		}
+		return nil
+	}
+}
+
+// cloneName returns a validator for the name of a clone of source.
+func cloneName(source string) func(string) error {
+	return func(s string) error {
+		if strings.EqualFold(strings.TrimSpace(s), source) {
+			return fmt.Errorf("choose a name other than %q", source)
+		}
+		return profile.ValidateName(s)
+	}
+}
+
+// emailAliasesInput returns the input for a profile's email aliases, entered
+// as a comma-separated list.
+func emailAliasesInput(value *string) *huh.Input {
+	return huh.NewInput().
+		Title("Email Aliases").
+		Description("Other emails you commit with, accepted by audit and check-identity, comma-separated (optional)").
+		Value(value).
+		Validate(validateEmailList)
+}
+
+// committerNameInput returns the input for a committer name other than the
+// author's.
+func committerNameInput(value *string) *huh.Input {
+	return huh.NewInput().
+		Title("Committer Name").
+		Description("Committer if not the author, used by exec, with and env (optional)").
+		Value(value)
+}
+
+// committerEmailInput returns the input for a committer email other than the
+// author's.
+func committerEmailInput(value *string) *huh.Input {
+	return huh.NewInput().
+		Title("Committer Email").
+		Description("Committer email if not the author's (optional)").
+		Value(value).
+		Validate(func(s string) error {
+			if s == "" {
+				return nil
+			}
+			return profile.ValidateEmail(s)
+		})
+}
+
+// remotePatternsInput returns the input for a profile's remote patterns,
+// entered as a comma-separated list.
+func remotePatternsInput(value *string) *huh.Input {
+	return huh.NewInput().
+		Title("Remote Patterns").
+		Description("Origin URLs to suggest this profile for, comma-separated (optional)").
+		Placeholder("github.com/acme, *.corp.com").
+		Value(value).
+		Validate(func(s string) error {
+			return profile.ValidateRemotePatterns(splitPatterns(s))
+		})
+}
+
+// isolateSSHConfigInput returns the toggle for ignoring ~/.ssh/config when
+// using the profile's key.
+func isolateSSHConfigInput(value *bool) *huh.Confirm {
+	return huh.NewConfirm().
+		Title("Ignore ~/.ssh/config").
+		Description("Run ssh with -F /dev/null when using this profile's key").
+		Affirmative("Yes").
+		Negative("No").
+		Value(value)
+}
+
+// sshSigningInput returns the toggle for signing commits with the profile's
+// SSH key.
+func sshSigningInput(value *bool) *huh.Confirm {
+	return huh.NewConfirm().
+		Title("Sign with SSH key").
+		Description("Sign commits with the SSH key instead of GPG (git 2.34 or later)").
+		Affirmative("Yes").
+		Negative("No").
+		Value(value)
+}
+
+// splitPatterns splits a comma-separated list, dropping empty entries.
+func splitPatterns(s string) []string {
+	var patterns []string
+	for _, p := range strings.Split(s, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			patterns = append(patterns, p)
+		}
+	}
+	return patterns
+}
@@ -3,9 +3,13 @@ package ui
 import (
 	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/thuanlegit/git-identitree/internal/profile"
+	"github.com/thuanlegit/git-identitree/internal/ssh"
+	"github.com/thuanlegit/git-identitree/internal/utils"
 )
 
 // Note: CreateProfileForm is an interactive form that requires user input.
@@ -76,40 +80,8 @@ func TestGetSSHKeySuggestions(t *testing.T) {
 		}
 	}()
 
-	// Override HOME to use temp directory
-	// On Windows, os.UserHomeDir() uses USERPROFILE, HOMEDRIVE+HOMEPATH, or HOME
-	originalHome := os.Getenv("HOME")
-	originalUserProfile := os.Getenv("USERPROFILE")
-	originalHomeDrive := os.Getenv("HOMEDRIVE")
-	originalHomePath := os.Getenv("HOMEPATH")
-
-	if err := os.Setenv("HOME", tmpDir); err != nil {
-		t.Fatalf("Failed to set HOME: %v", err)
-	}
-	if err := os.Setenv("USERPROFILE", tmpDir); err != nil {
-		t.Fatalf("Failed to set USERPROFILE: %v", err)
-	}
-	if err := os.Setenv("HOMEDRIVE", ""); err != nil {
-		t.Fatalf("Failed to clear HOMEDRIVE: %v", err)
-	}
-	if err := os.Setenv("HOMEPATH", ""); err != nil {
-		t.Fatalf("Failed to clear HOMEPATH: %v", err)
-	}
-
-	defer func() {
-		if err := os.Setenv("HOME", originalHome); err != nil {
-			t.Logf("Failed to restore HOME: %v", err)
-		}
-		if err := os.Setenv("USERPROFILE", originalUserProfile); err != nil {
-			t.Logf("Failed to restore USERPROFILE: %v", err)
-		}
-		if err := os.Setenv("HOMEDRIVE", originalHomeDrive); err != nil {
-			t.Logf("Failed to restore HOMEDRIVE: %v", err)
-		}
-		if err := os.Setenv("HOMEPATH", originalHomePath); err != nil {
-			t.Logf("Failed to restore HOMEPATH: %v", err)
-		}
-	}()
+	// Point gidtree at the temporary directory instead of the home directory
+	t.Setenv(utils.RootEnv, tmpDir)
 
 	sshDir := filepath.Join(tmpDir, ".ssh")
 	if err := os.MkdirAll(sshDir, 0755); err != nil {
@@ -136,7 +108,7 @@ func TestGetSSHKeySuggestions(t *testing.T) {
 	}
 
 	// Get suggestions
-	suggestions := getSSHKeySuggestions()
+	suggestions, _ := getSSHKeySuggestions()
 
 	// Expected suggestions (excluding .pub files and non-matching files)
 	expected := map[string]bool{
@@ -178,43 +150,11 @@ func TestGetSSHKeySuggestions_NoSSHDir(t *testing.T) {
 		}
 	}()
 
-	// Override HOME to use temp directory (without .ssh)
-	// On Windows, os.UserHomeDir() uses USERPROFILE, HOMEDRIVE+HOMEPATH, or HOME
-	originalHome := os.Getenv("HOME")
-	originalUserProfile := os.Getenv("USERPROFILE")
-	originalHomeDrive := os.Getenv("HOMEDRIVE")
-	originalHomePath := os.Getenv("HOMEPATH")
-
-	if err := os.Setenv("HOME", tmpDir); err != nil {
-		t.Fatalf("Failed to set HOME: %v", err)
-	}
-	if err := os.Setenv("USERPROFILE", tmpDir); err != nil {
-		t.Fatalf("Failed to set USERPROFILE: %v", err)
-	}
-	if err := os.Setenv("HOMEDRIVE", ""); err != nil {
-		t.Fatalf("Failed to clear HOMEDRIVE: %v", err)
-	}
-	if err := os.Setenv("HOMEPATH", ""); err != nil {
-		t.Fatalf("Failed to clear HOMEPATH: %v", err)
-	}
-
-	defer func() {
-		if err := os.Setenv("HOME", originalHome); err != nil {
-			t.Logf("Failed to restore HOME: %v", err)
-		}
-		if err := os.Setenv("USERPROFILE", originalUserProfile); err != nil {
-			t.Logf("Failed to restore USERPROFILE: %v", err)
-		}
-		if err := os.Setenv("HOMEDRIVE", originalHomeDrive); err != nil {
-			t.Logf("Failed to restore HOMEDRIVE: %v", err)
-		}
-		if err := os.Setenv("HOMEPATH", originalHomePath); err != nil {
-			t.Logf("Failed to restore HOMEPATH: %v", err)
-		}
-	}()
+	// Point gidtree at the temporary directory (without .ssh)
+	t.Setenv(utils.RootEnv, tmpDir)
 
 	// Get suggestions
-	suggestions := getSSHKeySuggestions()
+	suggestions, _ := getSSHKeySuggestions()
 
 	// Should return empty list when .ssh directory doesn't exist
 	if len(suggestions) != 0 {
@@ -250,3 +190,234 @@ func TestUpdateProfileForm_Exists(t *testing.T) {
 	}
 }
 
+func TestMapDirectoryForm_Exists(t *testing.T) {
+	// The form is interactive; verify the signature like the other forms
+	var form func(string, string) (string, error) = MapDirectoryForm
+	_ = form
+}
+
+func TestConfirmForm_Exists(t *testing.T) {
+	// The form is interactive; verify the signature like the other forms
+	var form func(string, string) (bool, error) = ConfirmForm
+	_ = form
+}
+
+func TestCloneProfileForm_Exists(t *testing.T) {
+	// The form is interactive; verify the signature like the other forms
+	var form func(*profile.Profile, string) (*profile.Profile, error) = CloneProfileForm
+	_ = form
+}
+
+func TestPassphraseForm_Exists(t *testing.T) {
+	// The form is interactive; verify the signature like the other forms
+	var form func(string, bool) (string, error) = PassphraseForm
+	_ = form
+}
+
+func TestTemplateProfileForm_FullyPinned(t *testing.T) {
+	// A template pinning every field needs no input at all
+	tmpl, err := profile.ParseTemplate([]byte(`
+name: ci
+email: ci@corp.com
+author_name: CI Bot
+ssh_key_path: ~/.ssh/id_ci
+gpg_key_id: ABC123
+`))
+	if err != nil {
+		t.Fatalf("ParseTemplate() error = %v", err)
+	}
+
+	prof, values, err := TemplateProfileForm(tmpl)
+	if err != nil {
+		t.Fatalf("TemplateProfileForm() error = %v", err)
+	}
+	if prof.Name != "ci" || prof.Email != "ci@corp.com" || prof.GPGKeyID != "ABC123" || len(values) != 0 {
+		t.Errorf("TemplateProfileForm() = %+v, %v", prof, values)
+	}
+}
+
+func TestFormValidators(t *testing.T) {
+	tests := []struct {
+		name     string
+		validate func(string) error
+		value    string
+		wantErr  string
+	}{
+		{"email missing", validateEmail, "", "enter an email address"},
+		{"email malformed", validateEmail, "jane", "want name@domain"},
+		{"email", validateEmail, "jane@example.com", ""},
+		{"required", required("a directory"), "  ", "enter a directory"},
+		{"required given", required("a directory"), "~/code", ""},
+		{"update keeps the name", unchangedName("work"), "work", ""},
+		{"update renames", unchangedName("work"), "job", `gidtree profile rename "work" <new-name>`},
+		{"clone takes the source name", cloneName("work"), "Work", `other than "work"`},
+		{"clone name invalid", cloneName("work"), "a b", "invalid profile name"},
+		{"clone name", cloneName("work"), "client", ""},
+		{"aliases", validateEmailList, "a@example.com, b@example.com", ""},
+		{"alias malformed", validateEmailList, "a@example.com, b", `invalid email "b"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.validate(tt.value)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("error = %v, want none", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestProfileAnswers_RoundTrip(t *testing.T) {
+	prof := profile.Profile{
+		Name:             "work",
+		Email:            "jane@acme.com",
+		EmailAliases:     []string{"jane@old.acme.com", "j@acme.com"},
+		AuthorName:       "Jane",
+		SSHKeyPath:       "~/.ssh/id_work",
+		RemotePatterns:   []string{"github.com/acme"},
+		CoAuthors:        []string{"Ada <ada@example.com>"},
+		IsolateSSHConfig: true,
+	}
+	answers := answersFrom(prof)
+	if answers.EmailAliases != "jane@old.acme.com, j@acme.com" {
+		t.Errorf("answers.EmailAliases = %q", answers.EmailAliases)
+	}
+	if got := answers.profile(prof); !reflect.DeepEqual(got, prof) {
+		t.Errorf("profile() = %+v, want %+v", got, prof)
+	}
+
+	// Settings the form does not show come from the base
+	answers.Email = "jane@example.com"
+	got := answers.profile(prof)
+	if got.Email != "jane@example.com" || !reflect.DeepEqual(got.CoAuthors, prof.CoAuthors) {
+		t.Errorf("profile() = %+v, want the new email and the base's co-authors", got)
+	}
+}
+
+func TestProfileAnswers_CustomSSHKey(t *testing.T) {
+	answers := answersFrom(profile.Profile{Name: "work", SSHKeyPath: "~/.ssh/id_work"})
+	answers.SSHKeyPath = "/keys/work"
+	if got := answers.profile(profile.Profile{}); got.SSHKeyPath != "~/.ssh/id_work" {
+		t.Errorf("SSHKeyPath = %q, want the picked key", got.SSHKeyPath)
+	}
+	answers.SSHKeyChoice = customKeyPath
+	if got := answers.profile(profile.Profile{}); got.SSHKeyPath != "/keys/work" {
+		t.Errorf("SSHKeyPath = %q, want the entered path", got.SSHKeyPath)
+	}
+	answers.SSHKeyChoice = ""
+	if got := answers.profile(profile.Profile{}); got.SSHKeyPath != "" {
+		t.Errorf("SSHKeyPath = %q, want none", got.SSHKeyPath)
+	}
+}
+
+func TestGetSSHKeySuggestions_ConfigPemAndAgent(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv(utils.RootEnv, home)
+	sshDir := filepath.Join(home, ".ssh")
+	keysDir := filepath.Join(home, "keys")
+	for _, dir := range []string{sshDir, keysDir} {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			t.Fatalf("MkdirAll() error = %v", err)
+		}
+	}
+	for _, path := range []string{filepath.Join(sshDir, "id_work"), filepath.Join(sshDir, "aws.pem"), filepath.Join(keysDir, "deploy"), filepath.Join(keysDir, "client")} {
+		if err := os.WriteFile(path, []byte("key"), 0600); err != nil {
+			t.Fatalf("WriteFile() error = %v", err)
+		}
+	}
+	config := "Host github-work\n  IdentityFile ~/.ssh/id_work\n  IdentityFile ~/keys/client\nHost old\n  IdentityFile ~/keys/gone\n"
+	if err := os.WriteFile(filepath.Join(sshDir, "config"), []byte(config), 0600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	orig := agentPublicKeys
+	defer func() { agentPublicKeys = orig }()
+	agentPublicKeys = func() ([]ssh.PublicKey, error) {
+		return []ssh.PublicKey{
+			{Type: "ssh-ed25519", Key: "AAAA", Comment: filepath.Join(keysDir, "deploy")},
+			{Type: "ssh-ed25519", Key: "BBBB", Comment: filepath.Join(sshDir, "id_work")},
+			{Type: "ssh-ed25519", Key: "CCCC", Comment: "jane@laptop"},
+			{Type: "ssh-ed25519", Key: "DDDD", Comment: filepath.Join(keysDir, "gone")},
+		}, nil
+	}
+
+	got, hosts := getSSHKeySuggestions()
+	want := []string{"~/.ssh/aws.pem", "~/.ssh/id_work", "~/keys/client", "~/keys/deploy"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("getSSHKeySuggestions() = %v, want %v", got, want)
+	}
+	wantHosts := map[string]string{"~/.ssh/id_work": "github-work", "~/keys/client": "github-work"}
+	if !reflect.DeepEqual(hosts, wantHosts) {
+		t.Errorf("getSSHKeySuggestions() hosts = %v, want %v", hosts, wantHosts)
+	}
+}
+
+func TestSSHKeyOptions(t *testing.T) {
+	dir := t.TempDir()
+	key := filepath.Join(dir, "id_work")
+	if err := os.WriteFile(key+".pub", []byte("ssh-ed25519 AAAAC3Nza jane@work\n"), 0644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	other := filepath.Join(dir, "id_other")
+
+	options := sshKeyOptions([]string{key, other}, map[string]string{other: "github-work"}, "/keys/legacy")
+	var keys, values []string
+	for _, o := range options {
+		keys = append(keys, o.Key)
+		values = append(values, o.Value)
+	}
+	wantKeys := []string{"(none)", key + " (ed25519, jane@work)", other + " (Host github-work)", "/keys/legacy", "(enter custom path…)"}
+	if !reflect.DeepEqual(keys, wantKeys) {
+		t.Errorf("option labels = %q, want %q", keys, wantKeys)
+	}
+	wantValues := []string{"", key, other, "/keys/legacy", customKeyPath}
+	if !reflect.DeepEqual(values, wantValues) {
+		t.Errorf("option values = %q, want %q", values, wantValues)
+	}
+
+	if got := sshKeyOptions([]string{key}, nil, key); len(got) != 3 {
+		t.Errorf("sshKeyOptions() with a listed key = %d options, want it listed once", len(got))
+	}
+}
+
+func TestRenderProfileSummary(t *testing.T) {
+	got := RenderProfileSummary(profile.Profile{
+		Name:           "work",
+		Email:          "jane@acme.com",
+		SSHKeyPath:     "~/.ssh/id_work",
+		RemotePatterns: []string{"github.com/acme", "*.corp.com"},
+		SSHSigning:     true,
+	}, "~/.gitconfig-work")
+	want := "Name:        work\n" +
+		"Email:       jane@acme.com\n" +
+		"Author Name: work\n" +
+		"SSH Key:     ~/.ssh/id_work\n" +
+		"Remotes:     github.com/acme, *.corp.com\n" +
+		"Signing:     SSH key\n" +
+		"\n" +
+		"Git config ~/.gitconfig-work is written when a directory is mapped to the profile."
+	if got != want {
+		t.Errorf("RenderProfileSummary() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestSplitPatterns(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+	}{
+		{"", nil},
+		{"github.com/acme", []string{"github.com/acme"}},
+		{" github.com/acme , *.corp.com,, ", []string{"github.com/acme", "*.corp.com"}},
+	}
+	for _, tt := range tests {
+		if got := splitPatterns(tt.in); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("splitPatterns(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
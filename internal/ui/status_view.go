@@ -1,49 +1,62 @@
 package ui
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
+	"github.com/thuanlegit/git-identitree/internal/cloudsync"
+	"github.com/thuanlegit/git-identitree/internal/config"
+	"github.com/thuanlegit/git-identitree/internal/identity"
 	"github.com/thuanlegit/git-identitree/internal/mapping"
 	"github.com/thuanlegit/git-identitree/internal/profile"
+	"github.com/thuanlegit/git-identitree/internal/ssh"
 	"github.com/thuanlegit/git-identitree/internal/utils"
 	"github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 )
 
-var (
-	statusTitleStyle = lipgloss.NewStyle().
-				Bold(true).
-				Foreground(lipgloss.Color("62")).
-				Padding(1, 0)
-
-	sectionStyle = lipgloss.NewStyle().
-			Bold(true).
-			Foreground(lipgloss.Color("230")).
-			Padding(1, 0)
-
-	infoStyle = lipgloss.NewStyle().
-			Padding(0, 2)
-
-	activeStyle = lipgloss.NewStyle().
-			Foreground(lipgloss.Color("42")).
-			Bold(true)
-
-	inactiveStyle = lipgloss.NewStyle().
-			Foreground(lipgloss.Color("240"))
+const (
+	// infoIndent is the horizontal padding the Info style adds around a line.
+	infoIndent = 4
+	// statusFooterHeight is the blank line and help line below the scrolled body.
+	statusFooterHeight = 2
+	// minMappingDirWidth is the narrowest a mapped directory is cut to.
+	minMappingDirWidth = 8
 )
 
 // StatusModel is the Bubble Tea model for displaying status.
 type StatusModel struct {
-	mappings      []mapping.Mapping
-	currentDir    string
-	activeProfile *profile.Profile
-	width         int
-	height        int
+	mappings    []mapping.Mapping
+	cloudSynced map[string]bool
+	// broad holds the mapped directories every repository below inherits:
+	// the home directory and the filesystem root.
+	broad       map[string]bool
+	currentDir  string
+	summary    identity.Summary
+	globalUser *mapping.GlobalIdentity
+	defaultProfile string
+	warnings   []string
+	width      int
+	height     int
+	offset     int
+	// notice is the result of the last key action or refresh, shown below
+	// the active profile until the next one.
+	notice    string
+	noticeErr bool
+	// styles overrides the package styles; nil uses them.
+	styles *Styles
 }
 
+var (
+	// loadKey and unloadKey change the agent for the l and u keys. Replaced in tests.
+	loadKey   = ssh.LoadKeyForProfile
+	unloadKey = ssh.UnloadKeyForProfile
+)
+
 // NewStatusModel creates a new status model.
 func NewStatusModel() (*StatusModel, error) {
 	mappings, err := mapping.ParseMappings()
@@ -57,32 +70,90 @@ func NewStatusModel() (*StatusModel, error) {
 		currentDir = ""
 	}
 
-	// Find active profile for current directory
-	var activeProfile *profile.Profile
+	// Resolve the identity for the current directory; Init asks the SSH agent
+	// so a slow agent does not hold up the view
+	var summary identity.Summary
 	if currentDir != "" {
-		m, err := mapping.GetMappingForDirectory(currentDir)
-		if err == nil && m != nil {
-			// Load profile
-			manager, err := profile.NewManager()
-			if err == nil {
-				prof, err := manager.GetProfile(m.Profile)
-				if err == nil {
-					activeProfile = prof
-				}
-			}
+		summary, err = identity.SummarizeWithoutAgent(currentDir)
+		if err != nil {
+			// Keep the rest of the status usable if resolution fails
+			summary = identity.Summary{Directory: currentDir}
+		}
+	}
+
+	// Default identity from the global [user] section
+	globalUser, err := mapping.ParseGlobalUser()
+	if err != nil {
+		return nil, err
+	}
+	var profiles []profile.Profile
+	if manager, err := profile.NewDefaultManager(); err == nil {
+		profiles = manager.ListProfiles()
+	}
+
+	// Profile used where no mapping applies
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	// Flag mappings that live inside cloud-synced folders or cover everything
+	cloudSynced := make(map[string]bool)
+	broad := make(map[string]bool)
+	for _, m := range mappings {
+		if m.HasDirectory() && cloudsync.IsSynced(m.Directory) {
+			cloudSynced[m.Directory] = true
+		}
+		if m.HasDirectory() && mapping.IsBroadDirectory(m.Directory) {
+			broad[m.Directory] = true
 		}
 	}
 
 	return &StatusModel{
-		mappings:      mappings,
-		currentDir:    currentDir,
-		activeProfile: activeProfile,
+		mappings:    mappings,
+		cloudSynced: cloudSynced,
+		broad:       broad,
+		currentDir: currentDir,
+		summary:    summary,
+		globalUser: globalUser,
+		defaultProfile: cfg.DefaultProfile,
+		warnings:   mapping.IdentityWarnings(globalUser, mappings, profiles),
 	}, nil
 }
 
-// Init implements the tea.Model interface.
+// keyStateMsg carries the agent state of the active profile's key.
+type keyStateMsg identity.KeyState
+
+// keyActionMsg is the result of loading (or unloading) the active profile's key.
+type keyActionMsg struct {
+	load bool
+	err  error
+}
+
+// refreshMsg carries freshly gathered status data.
+type refreshMsg struct {
+	model *StatusModel
+	err   error
+}
+
+// theme returns the styles the model renders with.
+func (m *StatusModel) theme() *Styles {
+	if m.styles != nil {
+		return m.styles
+	}
+	return &defaultStyles
+}
+
+// Init implements the tea.Model interface. It checks in the background
+// whether the active profile's SSH key is in the agent.
 func (m *StatusModel) Init() tea.Cmd {
-	return nil
+	if m.summary.Profile == nil || m.summary.KeyState != identity.KeyUnknown {
+		return nil
+	}
+	keyPath := m.summary.Profile.SSHKeyPath
+	return func() tea.Msg {
+		return keyStateMsg(identity.CheckKeyState(keyPath))
+	}
 }
 
 // Update implements the tea.Model interface.
@@ -91,86 +162,473 @@ func (m *StatusModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case tea.WindowSizeMsg:
 		m.width = msg.Width
 		m.height = msg.Height
+		m.clampOffset()
+		return m, nil
+	case keyStateMsg:
+		m.summary.SetKeyState(identity.KeyState(msg))
+		return m, nil
+	case keyActionMsg:
+		m.applyKeyAction(msg)
+		m.clampOffset()
 		return m, nil
+	case refreshMsg:
+		cmd := m.applyRefresh(msg)
+		m.clampOffset()
+		return m, cmd
 	case tea.KeyMsg:
 		switch msg.String() {
 		case "q", "ctrl+c", "esc":
 			return m, tea.Quit
+		case "l":
+			return m, m.keyAction(true)
+		case "u":
+			return m, m.keyAction(false)
+		case "r":
+			return m, refreshStatus
+		case "up", "k":
+			m.offset--
+		case "down", "j":
+			m.offset++
+		case "pgup", "b":
+			m.offset -= m.pageHeight()
+		case "pgdown", "f", " ":
+			m.offset += m.pageHeight()
+		case "home", "g":
+			m.offset = 0
+		case "end", "G":
+			m.offset = m.maxOffset()
 		}
+		m.clampOffset()
 	}
 	return m, nil
 }
 
 // View implements the tea.Model interface.
 func (m *StatusModel) View() string {
+	st := m.theme()
 	var b strings.Builder
-	b.WriteString(statusTitleStyle.Render("Git Identitree Status\n"))
+	b.WriteString(m.renderHeader())
+
+	lines := strings.Split(m.renderBody(), "\n")
+	page := m.pageHeight()
+	if page <= 0 || len(lines) <= page {
+		b.WriteString(strings.Join(lines, "\n"))
+		b.WriteString("\n\n")
+		b.WriteString(st.Help.Render(fitLine(m.keyHelp(), 0, m.width)))
+		return b.String()
+	}
+
+	// Only the sections below the header scroll
+	end := min(m.offset+page, len(lines))
+	b.WriteString(strings.Join(lines[m.offset:end], "\n"))
+	b.WriteString("\n\n")
+	indicator := fmt.Sprintf("lines %d–%d of %d • ↑/↓ scroll • pgup/pgdn page • %s", m.offset+1, end, len(lines), m.keyHelp())
+	b.WriteString(st.Help.Render(fitLine(indicator, 0, m.width)))
+
+	return b.String()
+}
+
+// renderHeader renders the title, current directory and active profile, which
+// stay pinned while the rest of the status scrolls.
+func (m *StatusModel) renderHeader() string {
+	st := m.theme()
+	var b strings.Builder
+	b.WriteString(st.StatusTitle.Render("Git Identitree Status\n"))
 	b.WriteString("\n")
 
 	// Current directory and active profile
-	b.WriteString(sectionStyle.Render("Current Directory"))
+	b.WriteString(st.Section.Render("Current Directory"))
 	b.WriteString("\n")
-	b.WriteString(infoStyle.Render(fmt.Sprintf("Path: %s", m.currentDir)))
+	b.WriteString(st.Info.Render(fitLine(fmt.Sprintf("Path: %s", utils.AbbreviateHome(m.currentDir)), infoIndent, m.width)))
 	b.WriteString("\n\n")
 
-	if m.activeProfile != nil {
-		b.WriteString(activeStyle.Render(fmt.Sprintf("✓ Active Profile: %s", m.activeProfile.Name)))
-		b.WriteString("\n")
-		b.WriteString(infoStyle.Render(fmt.Sprintf("  Email: %s", m.activeProfile.Email)))
-		if m.activeProfile.SSHKeyPath != "" {
-			b.WriteString("\n")
-			b.WriteString(infoStyle.Render(fmt.Sprintf("  SSH Key: %s", m.activeProfile.SSHKeyPath)))
+	b.WriteString(renderSummary(m.summary, m.width, st))
+	b.WriteString("\n\n")
+
+	if m.notice != "" {
+		style := st.Active
+		if m.noticeErr {
+			style = st.Warning
 		}
-		if m.activeProfile.GPGKeyID != "" {
-			b.WriteString("\n")
-			b.WriteString(infoStyle.Render(fmt.Sprintf("  GPG Key: %s", m.activeProfile.GPGKeyID)))
+		b.WriteString(style.Render(fitLine(m.notice, 0, m.width)))
+		b.WriteString("\n\n")
+	}
+
+	return b.String()
+}
+
+// keyHelp lists the keys that act on the status, leaving out the key
+// actions when the active profile has no SSH key or in offline mode.
+func (m *StatusModel) keyHelp() string {
+	if m.summary.Profile == nil || m.summary.Profile.SSHKeyPath == "" || ssh.Offline {
+		return "r refresh • q quit"
+	}
+	return "l load key • u unload key • r refresh • q quit"
+}
+
+// keyAction returns a command that loads or unloads the active profile's SSH
+// key, or nil when there is no key to act on or in offline mode.
+func (m *StatusModel) keyAction(load bool) tea.Cmd {
+	if m.summary.Profile == nil || m.summary.Profile.SSHKeyPath == "" {
+		m.notice, m.noticeErr = "The active profile has no SSH key", true
+		return nil
+	}
+	if ssh.Offline {
+		m.notice, m.noticeErr = "Offline mode: gidtree does not talk to the SSH agent", true
+		return nil
+	}
+	prof := m.summary.Profile.Clone()
+	return func() tea.Msg {
+		if load {
+			return keyActionMsg{load: true, err: loadKey(&prof)}
 		}
-	} else {
-		b.WriteString(inactiveStyle.Render("No active profile for current directory"))
+		return keyActionMsg{load: false, err: unloadKey(&prof)}
 	}
-	b.WriteString("\n\n")
+}
+
+// applyKeyAction records the outcome of a key action and the key's new state.
+func (m *StatusModel) applyKeyAction(msg keyActionMsg) {
+	name := m.summary.Profile.Name
+	switch {
+	case msg.err != nil && msg.load:
+		m.notice, m.noticeErr = fmt.Sprintf("✗ Failed to load SSH key: %v", msg.err), true
+	case errors.Is(msg.err, ssh.ErrAgentReadOnly):
+		m.notice, m.noticeErr = fmt.Sprintf("- SSH key left loaded: %v", msg.err), false
+	case msg.err != nil:
+		m.notice, m.noticeErr = fmt.Sprintf("✗ Failed to unload SSH key: %v", msg.err), true
+	case msg.load:
+		m.notice, m.noticeErr = fmt.Sprintf("✓ SSH key loaded for profile '%s'", name), false
+		m.summary.SetKeyState(identity.KeyLoaded)
+	default:
+		m.notice, m.noticeErr = fmt.Sprintf("✓ SSH key unloaded for profile '%s'", name), false
+		m.summary.SetKeyState(identity.KeyNotLoaded)
+	}
+}
+
+// refreshStatus gathers the status again, as when the view was opened.
+func refreshStatus() tea.Msg {
+	model, err := NewStatusModel()
+	return refreshMsg{model: model, err: err}
+}
+
+// applyRefresh replaces the displayed data with freshly gathered data,
+// keeping the terminal size and scroll position. It returns the command that
+// checks the key state again.
+func (m *StatusModel) applyRefresh(msg refreshMsg) tea.Cmd {
+	if msg.err != nil {
+		m.notice, m.noticeErr = fmt.Sprintf("✗ Failed to refresh: %v", msg.err), true
+		return nil
+	}
+	fresh := msg.model
+	fresh.width, fresh.height, fresh.offset = m.width, m.height, m.offset
+	fresh.notice, fresh.noticeErr = "✓ Refreshed", false
+	*m = *fresh
+	return m.Init()
+}
+
+// renderBody renders the scrollable sections: mappings, default profile,
+// default identity and git config.
+func (m *StatusModel) renderBody() string {
+	st := m.theme()
+	var b strings.Builder
 
 	// Directory mappings
-	b.WriteString(sectionStyle.Render("Directory Mappings"))
+	b.WriteString(st.Section.Render("Directory Mappings"))
 	b.WriteString("\n")
 
 	if len(m.mappings) == 0 {
-		b.WriteString(infoStyle.Render("No directory mappings found."))
+		b.WriteString(st.Info.Render("No directory mappings found."))
 		b.WriteString("\n")
 	} else {
-		for _, m := range m.mappings {
-			// Shorten directory path for display
-			home, _ := utils.GetHomeDir()
-			displayDir := m.Directory
-			if strings.HasPrefix(displayDir, home) {
-				displayDir = strings.Replace(displayDir, home, "~", 1)
-			}
-			b.WriteString(infoStyle.Render(fmt.Sprintf("  %s → %s", displayDir, m.Profile)))
+		for _, mp := range m.mappings {
+			b.WriteString(m.renderMapping(mp))
 			b.WriteString("\n")
 		}
 	}
 	b.WriteString("\n")
 
+	// Default profile
+	b.WriteString(st.Section.Render("Default Profile"))
+	b.WriteString("\n")
+	if m.defaultProfile != "" {
+		b.WriteString(st.Info.Render(fitLine(fmt.Sprintf("Default profile: %s", m.defaultProfile), infoIndent, m.width)))
+	} else {
+		b.WriteString(st.Inactive.Render("No default profile set"))
+	}
+	b.WriteString("\n\n")
+
+	// Default identity
+	b.WriteString(st.Section.Render("Default Identity"))
+	b.WriteString("\n")
+	if m.globalUser != nil && (m.globalUser.Name != "" || m.globalUser.Email != "") {
+		b.WriteString(st.Info.Render(fitLine(fmt.Sprintf("%s <%s>", m.globalUser.Name, m.globalUser.Email), infoIndent, m.width)))
+	} else {
+		b.WriteString(st.Inactive.Render("No default identity in ~/.gitconfig"))
+	}
+	b.WriteString("\n")
+	for _, warning := range m.warnings {
+		b.WriteString(st.Warning.Render(fitLine(fmt.Sprintf("⚠ %s", warning), 0, m.width)))
+		b.WriteString("\n")
+	}
+	b.WriteString("\n")
+
 	// Git config status
-	b.WriteString(sectionStyle.Render("Git Config"))
+	b.WriteString(st.Section.Render("Git Config"))
 	b.WriteString("\n")
 	gitConfigPath, err := getGitConfigPath()
 	if err == nil {
 		if _, err := os.Stat(gitConfigPath); err == nil {
-			b.WriteString(infoStyle.Render(fmt.Sprintf("✓ Main config: %s", gitConfigPath)))
+			b.WriteString(st.Info.Render(fitLine(fmt.Sprintf("✓ Main config: %s", utils.AbbreviateHome(gitConfigPath)), infoIndent, m.width)))
 		} else {
-			b.WriteString(infoStyle.Render(fmt.Sprintf("✗ Main config not found: %s", gitConfigPath)))
+			b.WriteString(st.Info.Render(fitLine(fmt.Sprintf("✗ Main config not found: %s", utils.AbbreviateHome(gitConfigPath)), infoIndent, m.width)))
 		}
 	}
-	b.WriteString("\n\n")
 
-	b.WriteString("Press 'q' to quit")
+	return b.String()
+}
+
+// pageHeight returns how many body lines fit below the pinned header, or zero
+// when the terminal height is unknown and everything is shown.
+func (m *StatusModel) pageHeight() int {
+	if m.height <= 0 {
+		return 0
+	}
+	// The footer takes a blank line and the scroll indicator
+	return max(m.height-strings.Count(m.renderHeader(), "\n")-statusFooterHeight, 1)
+}
+
+// maxOffset returns the largest scroll offset that still fills the page.
+func (m *StatusModel) maxOffset() int {
+	page := m.pageHeight()
+	if page <= 0 {
+		return 0
+	}
+	return max(lipgloss.Height(m.renderBody())-page, 0)
+}
+
+// clampOffset keeps the scroll offset within the content.
+func (m *StatusModel) clampOffset() {
+	m.offset = min(max(m.offset, 0), m.maxOffset())
+}
+
+// renderMapping renders one mapping line, shortening the directory first so
+// the profile name and badge stay visible on narrow terminals.
+func (m *StatusModel) renderMapping(mp mapping.Mapping) string {
+	st := m.theme()
+	displayDir := utils.AbbreviateHome(mp.Directory)
+	if !mp.HasDirectory() {
+		// Conditions such as onbranch or hasconfig are shown verbatim
+		displayDir = mp.RawCondition
+	}
+
+	badge := mappingBadge(m.cloudSynced[mp.Directory], mp.Duplicate, m.broad[mp.Directory])
+
+	if m.width > 0 {
+		room := m.width - infoIndent - lipgloss.Width(fmt.Sprintf("  %s → %s", "", mp.Profile))
+		if badge != "" && room-lipgloss.Width(badge)-1 < minMappingDirWidth {
+			// Too narrow for the badge, keep the mapping itself
+			badge = ""
+		}
+		if badge != "" {
+			room -= lipgloss.Width(badge) + 1
+		}
+		displayDir = truncate(displayDir, max(room, minMappingDirWidth))
+	}
+
+	line := st.Info.Render(fitLine(fmt.Sprintf("  %s → %s", displayDir, mp.Profile), infoIndent, m.width))
+	if badge != "" {
+		line += " " + st.Warning.Render(badge)
+	}
+	if detail := mappingDetail(mp.CreatedAt, mp.Note); detail != "" {
+		line += "\n" + st.Detail.Render(fitLine("    "+detail, infoIndent, m.width))
+	}
+	return line
+}
+
+// mappingBadge returns the warnings shown after a mapping, or "" when there
+// are none.
+func mappingBadge(cloudSynced, duplicate, broad bool) string {
+	var badges []string
+	if duplicate {
+		// A later block for the same directory wins
+		badges = append(badges, "[duplicate, overridden]")
+	}
+	if broad {
+		// Every repository on the machine inherits it
+		badges = append(badges, "[broad]")
+	}
+	if cloudSynced {
+		badges = append(badges, "[cloud-synced]")
+	}
+	return strings.Join(badges, " ")
+}
+
+// mappingDetail describes when and why a directory was mapped, or returns ""
+// when its block has no metadata comment.
+func mappingDetail(created time.Time, note string) string {
+	var parts []string
+	if !created.IsZero() {
+		parts = append(parts, "mapped "+created.Format(time.DateOnly))
+	}
+	if note != "" {
+		parts = append(parts, note)
+	}
+	return strings.Join(parts, " · ")
+}
+
+// RenderSummary renders the active identity section for a summary.
+// The activate command prints the same facts, so both stay in sync.
+func RenderSummary(s identity.Summary) string {
+	return renderSummary(s, 0, &defaultStyles)
+}
 
+// renderSummary renders the active identity section, truncating lines to width.
+func renderSummary(s identity.Summary, width int, st *Styles) string {
+	if s.Profile == nil {
+		return st.Inactive.Render(fitLine("No active profile for current directory", 0, width))
+	}
+
+	var b strings.Builder
+	b.WriteString(st.Active.Render(fitLine(fmt.Sprintf("✓ Active Profile: %s", s.Profile.Name), 0, width)))
+	for _, fact := range s.Facts() {
+		style := st.Info
+		if fact.Label == "SSH Key" {
+			style = keyStateStyle(st, s.KeyState)
+		}
+		b.WriteString("\n")
+		b.WriteString(style.Render(fitLine(fmt.Sprintf("  %s: %s", fact.Label, fact.Value), infoIndent, width)))
+	}
 	return b.String()
 }
 
+// StatusReport is the status without the interactive view, for output that
+// is not a terminal.
+type StatusReport struct {
+	CurrentDirectory string                  `json:"current_directory"`
+	Identity         identity.Summary        `json:"identity"`
+	Mappings         []StatusMapping         `json:"mappings"`
+	DefaultProfile   string                  `json:"default_profile,omitempty"`
+	DefaultIdentity  *mapping.GlobalIdentity `json:"default_identity,omitempty"`
+	Warnings         []string                `json:"warnings,omitempty"`
+}
+
+// StatusMapping is one mapping in a StatusReport.
+type StatusMapping struct {
+	// Directory is empty for conditions that are not about a directory.
+	Directory   string `json:"directory,omitempty"`
+	Condition   string `json:"condition"`
+	Profile     string `json:"profile"`
+	CloudSynced bool   `json:"cloud_synced,omitempty"`
+	// Broad is set for a mapping of the home directory or the filesystem
+	// root, which every repository below inherits.
+	Broad bool `json:"broad,omitempty"`
+	// Duplicate is set when a later mapping of the same directory overrides
+	// this one.
+	Duplicate bool `json:"duplicate,omitempty"`
+	// CreatedAt is the date the directory was mapped, as YYYY-MM-DD.
+	CreatedAt string `json:"created_at,omitempty"`
+	Note      string `json:"note,omitempty"`
+}
+
+// Report returns the status data. The key state is checked first if the view
+// would have checked it in the background.
+func (m *StatusModel) Report() StatusReport {
+	if cmd := m.Init(); cmd != nil {
+		m.Update(cmd())
+	}
+
+	r := StatusReport{
+		CurrentDirectory: m.currentDir,
+		Identity:         m.summary,
+		Mappings:         []StatusMapping{},
+		DefaultProfile:   m.defaultProfile,
+		Warnings:         m.warnings,
+	}
+	if m.globalUser != nil && (m.globalUser.Name != "" || m.globalUser.Email != "") {
+		r.DefaultIdentity = m.globalUser
+	}
+	for _, mp := range m.mappings {
+		sm := StatusMapping{
+			Directory:   mp.Directory,
+			Condition:   mp.RawCondition,
+			Profile:     mp.Profile,
+			CloudSynced: m.cloudSynced[mp.Directory],
+			Broad:       m.broad[mp.Directory],
+			Duplicate:   mp.Duplicate,
+			Note:        mp.Note,
+		}
+		if !mp.CreatedAt.IsZero() {
+			sm.CreatedAt = mp.CreatedAt.Format(time.DateOnly)
+		}
+		r.Mappings = append(r.Mappings, sm)
+	}
+	return r
+}
+
+// RenderStatusPlain renders a status report as unstyled text.
+func RenderStatusPlain(r StatusReport) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Current directory: %s\n", utils.AbbreviateHome(r.CurrentDirectory))
+	if r.Identity.Profile == nil {
+		b.WriteString("Active profile: none\n")
+	} else {
+		fmt.Fprintf(&b, "Active profile: %s\n", r.Identity.Profile.Name)
+		for _, fact := range r.Identity.Facts() {
+			fmt.Fprintf(&b, "  %s: %s\n", fact.Label, fact.Value)
+		}
+	}
+
+	b.WriteString("\nDirectory mappings:\n")
+	if len(r.Mappings) == 0 {
+		b.WriteString("  none\n")
+	}
+	for _, mp := range r.Mappings {
+		target := utils.AbbreviateHome(mp.Directory)
+		if mp.Directory == "" {
+			target = mp.Condition
+		}
+		badge := mappingBadge(mp.CloudSynced, mp.Duplicate, mp.Broad)
+		if badge != "" {
+			badge = " " + badge
+		}
+		fmt.Fprintf(&b, "  %s → %s%s\n", target, mp.Profile, badge)
+		created, _ := time.Parse(time.DateOnly, mp.CreatedAt)
+		if detail := mappingDetail(created, mp.Note); detail != "" {
+			fmt.Fprintf(&b, "    %s\n", detail)
+		}
+	}
+
+	b.WriteString("\n")
+	if r.DefaultProfile != "" {
+		fmt.Fprintf(&b, "Default profile: %s\n", r.DefaultProfile)
+	} else {
+		b.WriteString("Default profile: none\n")
+	}
+	if r.DefaultIdentity != nil {
+		fmt.Fprintf(&b, "Default identity: %s <%s>\n", r.DefaultIdentity.Name, r.DefaultIdentity.Email)
+	} else {
+		b.WriteString("Default identity: none\n")
+	}
+	for _, warning := range r.Warnings {
+		fmt.Fprintf(&b, "⚠ %s\n", warning)
+	}
+	return b.String()
+}
+
+// keyStateStyle colors the SSH key line: green when the key is in the agent,
+// yellow when pushing would fail for want of it or the agent cannot be asked.
+func keyStateStyle(st *Styles, state identity.KeyState) lipgloss.Style {
+	switch state {
+	case identity.KeyLoaded:
+		return st.KeyLoaded
+	case identity.KeyNotLoaded, identity.KeyAgentUnreachable:
+		return st.KeyMissing
+	}
+	return st.Info
+}
+
 func getGitConfigPath() (string, error) {
-	home, err := utils.GetHomeDir()
+	home, err := utils.Root()
 	if err != nil {
 		return "", err
 	}
@@ -1,13 +1,19 @@
 package ui
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/thuanlegit/git-identitree/internal/identity"
 	"github.com/thuanlegit/git-identitree/internal/mapping"
 	"github.com/thuanlegit/git-identitree/internal/profile"
+	"github.com/thuanlegit/git-identitree/internal/ssh"
+	"github.com/thuanlegit/git-identitree/internal/utils"
+	"github.com/thuanlegit/git-identitree/pkg/gidtree/gidtreetest"
 	tea "github.com/charmbracelet/bubbletea"
 )
 
@@ -26,41 +32,12 @@ func setupStatusTestEnv(t *testing.T) (string, func()) {
 		t.Logf("Warning: Failed to resolve tmpDir symlinks: %v", err)
 	}
 
-	// Override home directory for testing
-	// On Windows, os.UserHomeDir() uses USERPROFILE, HOMEDRIVE+HOMEPATH, or HOME
-	// We need to override all of them to ensure tests work correctly
-	originalHome := os.Getenv("HOME")
-	originalUserProfile := os.Getenv("USERPROFILE")
-	originalHomeDrive := os.Getenv("HOMEDRIVE")
-	originalHomePath := os.Getenv("HOMEPATH")
-
-	if err := os.Setenv("HOME", tmpDir); err != nil {
-		t.Fatalf("Failed to set HOME: %v", err)
-	}
-	if err := os.Setenv("USERPROFILE", tmpDir); err != nil {
-		t.Fatalf("Failed to set USERPROFILE: %v", err)
-	}
-	// Clear HOMEDRIVE and HOMEPATH to prevent them from interfering
-	if err := os.Setenv("HOMEDRIVE", ""); err != nil {
-		t.Fatalf("Failed to clear HOMEDRIVE: %v", err)
-	}
-	if err := os.Setenv("HOMEPATH", ""); err != nil {
-		t.Fatalf("Failed to clear HOMEPATH: %v", err)
-	}
+	// Point gidtree at the temporary directory instead of the home directory
+	// and keep the host's gidtree config out of the way
+	t.Setenv(utils.RootEnv, tmpDir)
+	t.Setenv("XDG_CONFIG_HOME", "")
 
 	cleanup := func() {
-		if err := os.Setenv("HOME", originalHome); err != nil {
-			t.Logf("Failed to restore HOME: %v", err)
-		}
-		if err := os.Setenv("USERPROFILE", originalUserProfile); err != nil {
-			t.Logf("Failed to restore USERPROFILE: %v", err)
-		}
-		if err := os.Setenv("HOMEDRIVE", originalHomeDrive); err != nil {
-			t.Logf("Failed to restore HOMEDRIVE: %v", err)
-		}
-		if err := os.Setenv("HOMEPATH", originalHomePath); err != nil {
-			t.Logf("Failed to restore HOMEPATH: %v", err)
-		}
 		if err := os.RemoveAll(tmpDir); err != nil {
 			t.Logf("Failed to remove temp directory: %v", err)
 		}
@@ -70,46 +47,11 @@ func setupStatusTestEnv(t *testing.T) (string, func()) {
 }
 
 func TestNewStatusModel(t *testing.T) {
-	tmpDir, cleanup := setupStatusTestEnv(t)
-	defer cleanup()
-
-	// Initialize profiles
-	profilesDir, err := profile.GetProfilesDir()
-	if err != nil {
-		t.Fatalf("GetProfilesDir() error = %v", err)
-	}
-	if err := os.MkdirAll(profilesDir, 0755); err != nil {
-		t.Fatalf("Failed to create profiles directory: %v", err)
-	}
-
-	// Create a profile
-	manager, err := profile.NewManager()
-	if err != nil {
-		t.Fatalf("NewManager() error = %v", err)
-	}
-
-	testProfile := profile.Profile{
-		Name:  "test",
-		Email: "test@example.com",
-	}
-	if err := manager.AddProfile(testProfile); err != nil {
-		t.Fatalf("AddProfile() error = %v", err)
-	}
-
-	// Create test directory and map it
-	testDir := filepath.Join(tmpDir, "project")
-	if err := os.MkdirAll(testDir, 0755); err != nil {
-		t.Fatalf("Failed to create test directory: %v", err)
-	}
-
-	prof, err := manager.GetProfile("test")
-	if err != nil {
-		t.Fatalf("GetProfile() error = %v", err)
-	}
-
-	if err := mapping.MapProfileToDirectory(prof, testDir); err != nil {
-		t.Fatalf("MapProfileToDirectory() error = %v", err)
-	}
+	env := gidtreetest.NewEnv(t).
+		WithProfile(profile.Profile{Name: "test", Email: "test@example.com"}).
+		WithMapping("test", "project").
+		Build()
+	testDir := env.Path("project")
 
 	// Change to test directory
 	originalDir, err := os.Getwd()
@@ -135,7 +77,7 @@ func TestNewStatusModel(t *testing.T) {
 		t.Fatal("NewStatusModel() returned nil")
 	}
 
-	if model.activeProfile == nil {
+	if model.summary.Profile == nil {
 		t.Error("NewStatusModel() should find active profile for mapped directory")
 	}
 
@@ -177,7 +119,7 @@ func TestNewStatusModel_NoMapping(t *testing.T) {
 		t.Fatalf("NewStatusModel() error = %v", err)
 	}
 
-	if model.activeProfile != nil {
+	if model.summary.Profile != nil {
 		t.Error("NewStatusModel() should not find active profile for unmapped directory")
 	}
 }
@@ -232,11 +174,16 @@ func TestStatusModel_View_WithActiveProfile(t *testing.T) {
 
 	model := &StatusModel{
 		currentDir: tmpDir,
-		activeProfile: &profile.Profile{
-			Name:       "test",
-			Email:      "test@example.com",
-			SSHKeyPath: "/path/to/key",
-			GPGKeyID:   "ABC123",
+		summary: identity.Summary{
+			Profile: &profile.Profile{
+				Name:       "test",
+				Email:      "test@example.com",
+				SSHKeyPath: "/path/to/key",
+				GPGKeyID:   "ABC123",
+			},
+			Source:          identity.SourceMapping,
+			MappedDirectory: tmpDir + "/",
+			KeyState:        identity.KeyMissing,
 		},
 		mappings: []mapping.Mapping{
 			{Directory: tmpDir + "/", Profile: "test"},
@@ -265,7 +212,6 @@ func TestStatusModel_View_WithActiveProfile(t *testing.T) {
 func TestStatusModel_View_NoActiveProfile(t *testing.T) {
 	model := &StatusModel{
 		currentDir: "/some/dir",
-		activeProfile: nil,
 		mappings: []mapping.Mapping{},
 	}
 
@@ -330,3 +276,465 @@ func TestGetGitConfigPath(t *testing.T) {
 	}
 }
 
+
+func TestStatusModel_View_DefaultIdentity(t *testing.T) {
+	model := &StatusModel{
+		globalUser: &mapping.GlobalIdentity{Name: "Jane Doe", Email: "jane@example.com"},
+		warnings:   []string{"Profile 'work' uses the same email as the default identity"},
+	}
+
+	view := model.View()
+	if !strings.Contains(view, "Default Identity") {
+		t.Error("StatusModel.View() should show default identity section")
+	}
+	if !strings.Contains(view, "Jane Doe <jane@example.com>") {
+		t.Error("StatusModel.View() should show default identity values")
+	}
+	if !strings.Contains(view, "uses the same email") {
+		t.Error("StatusModel.View() should show identity warnings")
+	}
+
+	model = &StatusModel{}
+	if !strings.Contains(model.View(), "No default identity") {
+		t.Error("StatusModel.View() should note a missing default identity")
+	}
+}
+
+func TestStatusModel_View_DefaultProfile(t *testing.T) {
+	model := &StatusModel{defaultProfile: "personal"}
+	view := model.View()
+	if !strings.Contains(view, "Default Profile") {
+		t.Error("StatusModel.View() should show default profile section")
+	}
+	if !strings.Contains(view, "Default profile: personal") {
+		t.Error("StatusModel.View() should show the default profile name")
+	}
+
+	model = &StatusModel{}
+	if !strings.Contains(model.View(), "No default profile set") {
+		t.Error("StatusModel.View() should note a missing default profile")
+	}
+}
+
+func TestStatusModel_View_CloudSyncedBadge(t *testing.T) {
+	tmpDir, cleanup := setupStatusTestEnv(t)
+	defer cleanup()
+
+	synced := tmpDir + "/Dropbox/project/"
+	model := &StatusModel{
+		mappings: []mapping.Mapping{
+			{Directory: synced, Profile: "work"},
+			{Directory: tmpDir + "/code/project/", Profile: "personal"},
+		},
+		cloudSynced: map[string]bool{synced: true},
+	}
+
+	view := model.View()
+
+	if strings.Count(view, "[cloud-synced]") != 1 {
+		t.Errorf("StatusModel.View() should badge exactly one mapping as cloud-synced, got:\n%s", view)
+	}
+}
+
+func TestStatusModel_View_MappingNote(t *testing.T) {
+	model := &StatusModel{
+		mappings: []mapping.Mapping{
+			{Directory: "/code/acme/", Profile: "work", Note: "acme contract", CreatedAt: time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)},
+			{Directory: "/code/oss/", Profile: "personal"},
+		},
+	}
+
+	view := model.View()
+	if !strings.Contains(view, "mapped 2024-05-01 · acme contract") {
+		t.Errorf("StatusModel.View() should show the mapping's date and note, got:\n%s", view)
+	}
+	if strings.Count(view, "mapped ") != 1 {
+		t.Errorf("StatusModel.View() should show no details for a mapping without comment, got:\n%s", view)
+	}
+}
+
+func TestStatusModel_View_FitsTerminalWidth(t *testing.T) {
+	tmpDir, cleanup := setupStatusTestEnv(t)
+	defer cleanup()
+
+	deep := tmpDir + "/Dropbox/clients/acme-corporation/engineering/platform/services/billing/"
+	model := &StatusModel{
+		currentDir: "/outside/home/a/deeply/nested/checkout/of/some/repository/somewhere/else",
+		summary: identity.Summary{
+			Profile: &profile.Profile{
+				Name:       "work",
+				Email:      "firstname.lastname@a-very-long-company-domain.example.com",
+				SSHKeyPath: tmpDir + "/.ssh/keys/company/id_ed25519_work_laptop",
+			},
+		},
+		mappings: []mapping.Mapping{
+			{Directory: deep, Profile: "work"},
+			{Directory: tmpDir + "/code/", Profile: "a-profile-name-that-is-long-too"},
+			{RawCondition: "hasconfig:remote.*.url:https://github.com/acme-corporation/**", ConditionKind: mapping.ConditionHasConfig, Profile: "acme"},
+		},
+		cloudSynced: map[string]bool{deep: true},
+		globalUser:  &mapping.GlobalIdentity{Name: "Firstname Lastname", Email: "firstname.lastname@example.com"},
+		warnings:    []string{"Profile 'work' uses the same email as the default identity, commits outside mapped directories look identical"},
+	}
+
+	for _, width := range []int{60, 100, 160} {
+		model.Update(tea.WindowSizeMsg{Width: width, Height: 40})
+		view := model.View()
+		assertFitsWidth(t, view, width)
+
+		if !strings.Contains(view, "→ work") {
+			t.Errorf("width %d: mapping profile should stay visible, got:\n%s", width, view)
+		}
+	}
+}
+
+func manyMappings(n int) []mapping.Mapping {
+	mappings := make([]mapping.Mapping, n)
+	for i := range mappings {
+		mappings[i] = mapping.Mapping{Directory: fmt.Sprintf("/code/project-%02d/", i), Profile: "work"}
+	}
+	return mappings
+}
+
+func TestStatusModel_Update_Scroll(t *testing.T) {
+	model := &StatusModel{currentDir: "/code", mappings: manyMappings(40)}
+	model.Update(tea.WindowSizeMsg{Width: 100, Height: 24})
+
+	maxOffset := model.maxOffset()
+	if maxOffset <= 0 {
+		t.Fatalf("maxOffset() = %d, 40 mappings should overflow a 24 line terminal", maxOffset)
+	}
+
+	steps := []struct {
+		key  tea.KeyMsg
+		want int
+	}{
+		{tea.KeyMsg{Type: tea.KeyUp}, 0},
+		{tea.KeyMsg{Type: tea.KeyDown}, 1},
+		{tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("j")}, 2},
+		{tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("k")}, 1},
+		{tea.KeyMsg{Type: tea.KeyPgDown}, min(1+model.pageHeight(), maxOffset)},
+		{tea.KeyMsg{Type: tea.KeyEnd}, maxOffset},
+		{tea.KeyMsg{Type: tea.KeyDown}, maxOffset},
+		{tea.KeyMsg{Type: tea.KeyPgDown}, maxOffset},
+		{tea.KeyMsg{Type: tea.KeyPgUp}, max(maxOffset-model.pageHeight(), 0)},
+		{tea.KeyMsg{Type: tea.KeyHome}, 0},
+	}
+	for i, step := range steps {
+		model.Update(step.key)
+		if model.offset != step.want {
+			t.Errorf("step %d (%s): offset = %d, want %d", i, step.key, model.offset, step.want)
+		}
+	}
+
+	// Growing the terminal until everything fits resets the offset
+	model.Update(tea.KeyMsg{Type: tea.KeyEnd})
+	model.Update(tea.WindowSizeMsg{Width: 100, Height: 200})
+	if model.offset != 0 {
+		t.Errorf("offset = %d after resizing to fit the content, want 0", model.offset)
+	}
+}
+
+func TestStatusModel_View_ScrollKeepsHeaderPinned(t *testing.T) {
+	model := &StatusModel{currentDir: "/code", mappings: manyMappings(40)}
+	model.Update(tea.WindowSizeMsg{Width: 100, Height: 24})
+	model.Update(tea.KeyMsg{Type: tea.KeyEnd})
+
+	view := model.View()
+	if got := strings.Count(view, "\n") + 1; got > 24 {
+		t.Errorf("View() is %d lines tall, terminal is 24:\n%s", got, view)
+	}
+	if !strings.Contains(view, "Path: /code") {
+		t.Error("View() should keep the current directory pinned while scrolled")
+	}
+	if strings.Contains(view, "project-00") {
+		t.Error("View() scrolled to the end should not show the first mapping")
+	}
+	if !strings.Contains(view, "Main config") {
+		t.Error("View() scrolled to the end should show the last section")
+	}
+	if !strings.Contains(view, "of ") || !strings.Contains(view, "scroll") {
+		t.Errorf("View() should show a scroll indicator, got:\n%s", view)
+	}
+
+	model.Update(tea.KeyMsg{Type: tea.KeyHome})
+	if view := model.View(); !strings.Contains(view, "project-00") || strings.Contains(view, "project-39") {
+		t.Errorf("View() at the top should show only the first mappings, got:\n%s", view)
+	}
+}
+
+func TestStatusModel_KeyStateLoadsAsynchronously(t *testing.T) {
+	model := &StatusModel{
+		summary: identity.Summary{
+			Profile:  &profile.Profile{Name: "work", Email: "work@example.com", SSHKeyPath: "/nonexistent/id_work"},
+			Source:   identity.SourceMapping,
+			KeyState: identity.KeyUnknown,
+		},
+	}
+	if view := model.View(); !strings.Contains(view, "/nonexistent/id_work (checking agent…)") {
+		t.Errorf("View() before the check = %q, want the key marked as being checked", view)
+	}
+
+	cmd := model.Init()
+	if cmd == nil {
+		t.Fatal("Init() = nil, want a key check")
+	}
+	// The key does not exist, so the check answers without running ssh-add
+	if msg := cmd(); msg != keyStateMsg(identity.KeyMissing) {
+		t.Errorf("key check = %v, want %v", msg, identity.KeyMissing)
+	}
+
+	model.Update(keyStateMsg(identity.KeyNotLoaded))
+	if view := model.View(); !strings.Contains(view, "(not loaded — run gidtree ssh load work)") {
+		t.Errorf("View() = %q, want a load hint", view)
+	}
+	model.Update(keyStateMsg(identity.KeyLoaded))
+	if !model.summary.SSHKeyLoaded {
+		t.Error("SSHKeyLoaded = false after the key was found in the agent")
+	}
+	if view := model.View(); !strings.Contains(view, "/nonexistent/id_work (loaded)") {
+		t.Errorf("View() = %q, want the key loaded", view)
+	}
+
+	if cmd := model.Init(); cmd != nil {
+		t.Error("Init() with a known key state should not check again")
+	}
+}
+
+// stubKeyActions replaces the ssh layer behind the l and u keys.
+func stubKeyActions(t *testing.T, loadErr, unloadErr error) *[]string {
+	t.Helper()
+	var calls []string
+	origLoad, origUnload := loadKey, unloadKey
+	loadKey = func(p *profile.Profile) error { calls = append(calls, "load "+p.Name); return loadErr }
+	unloadKey = func(p *profile.Profile) error { calls = append(calls, "unload "+p.Name); return unloadErr }
+	t.Cleanup(func() { loadKey, unloadKey = origLoad, origUnload })
+	return &calls
+}
+
+// pressKey sends a key to the model and feeds the message of any command it
+// returns back in, as the Bubble Tea runtime would.
+func pressKey(m *StatusModel, key string) {
+	_, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(key)})
+	if cmd != nil {
+		m.Update(cmd())
+	}
+}
+
+func TestStatusModel_LoadAndUnloadKeys(t *testing.T) {
+	calls := stubKeyActions(t, nil, nil)
+	model := &StatusModel{
+		summary: identity.Summary{
+			Profile:  &profile.Profile{Name: "work", Email: "work@example.com", SSHKeyPath: "~/.ssh/id_work"},
+			Source:   identity.SourceMapping,
+			KeyState: identity.KeyNotLoaded,
+		},
+	}
+	if view := model.View(); !strings.Contains(view, "l load key • u unload key • r refresh • q quit") {
+		t.Errorf("View() = %q, want the key hints", view)
+	}
+
+	pressKey(model, "l")
+	if model.summary.KeyState != identity.KeyLoaded {
+		t.Errorf("KeyState after l = %v, want %v", model.summary.KeyState, identity.KeyLoaded)
+	}
+	if view := model.View(); !strings.Contains(view, "✓ SSH key loaded for profile 'work'") || !strings.Contains(view, "(loaded)") {
+		t.Errorf("View() after l = %q, want a success line and the loaded key", view)
+	}
+
+	pressKey(model, "u")
+	if model.summary.KeyState != identity.KeyNotLoaded {
+		t.Errorf("KeyState after u = %v, want %v", model.summary.KeyState, identity.KeyNotLoaded)
+	}
+	if view := model.View(); !strings.Contains(view, "✓ SSH key unloaded for profile 'work'") {
+		t.Errorf("View() after u = %q, want a success line", view)
+	}
+
+	if strings.Join(*calls, ",") != "load work,unload work" {
+		t.Errorf("ssh calls = %v", *calls)
+	}
+}
+
+func TestStatusModel_LoadKeyFails(t *testing.T) {
+	stubKeyActions(t, fmt.Errorf("agent refused"), nil)
+	model := &StatusModel{
+		summary: identity.Summary{
+			Profile:  &profile.Profile{Name: "work", Email: "work@example.com", SSHKeyPath: "~/.ssh/id_work"},
+			KeyState: identity.KeyNotLoaded,
+		},
+	}
+
+	pressKey(model, "l")
+	if model.summary.KeyState != identity.KeyNotLoaded {
+		t.Errorf("KeyState after a failed load = %v, want it unchanged", model.summary.KeyState)
+	}
+	if !model.noticeErr || !strings.Contains(model.View(), "✗ Failed to load SSH key: agent refused") {
+		t.Errorf("View() = %q, want the error inline", model.View())
+	}
+}
+
+func TestStatusModel_UnloadFromReadOnlyAgent(t *testing.T) {
+	stubKeyActions(t, nil, fmt.Errorf("unload: %w", ssh.ErrAgentReadOnly))
+	model := &StatusModel{
+		summary: identity.Summary{
+			Profile:  &profile.Profile{Name: "work", Email: "work@example.com", SSHKeyPath: "~/.ssh/id_work"},
+			KeyState: identity.KeyLoaded,
+		},
+	}
+
+	pressKey(model, "u")
+	if model.summary.KeyState != identity.KeyLoaded {
+		t.Errorf("KeyState after u = %v, want the key still loaded", model.summary.KeyState)
+	}
+	if model.noticeErr || !strings.Contains(model.View(), "- SSH key left loaded") {
+		t.Errorf("View() = %q, want a note rather than an error", model.View())
+	}
+}
+
+func TestStatusModel_KeyActionsWithoutKey(t *testing.T) {
+	calls := stubKeyActions(t, nil, nil)
+	model := &StatusModel{
+		summary: identity.Summary{Profile: &profile.Profile{Name: "personal", Email: "me@example.com"}},
+	}
+
+	pressKey(model, "l")
+	if len(*calls) != 0 {
+		t.Errorf("ssh calls = %v, want none for a profile without a key", *calls)
+	}
+	view := model.View()
+	if !strings.Contains(view, "The active profile has no SSH key") {
+		t.Errorf("View() = %q, want an explanation", view)
+	}
+	if strings.Contains(view, "l load key") {
+		t.Errorf("View() = %q, should not offer key actions", view)
+	}
+}
+
+func TestStatusModel_Refresh(t *testing.T) {
+	env := gidtreetest.NewEnv(t).
+		WithProfile(profile.Profile{Name: "test", Email: "test@example.com"}).
+		Build()
+	t.Chdir(env.Home())
+
+	model, err := NewStatusModel()
+	if err != nil {
+		t.Fatalf("NewStatusModel() error = %v", err)
+	}
+	model.Update(tea.WindowSizeMsg{Width: 100, Height: 60})
+	if strings.Contains(model.View(), "project") {
+		t.Fatal("View() shows a mapping before one was added")
+	}
+
+	if err := os.Mkdir(env.Path("project"), 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	if err := env.Client().Map("test", env.Path("project")); err != nil {
+		t.Fatalf("Map() error = %v", err)
+	}
+
+	pressKey(model, "r")
+	view := model.View()
+	if !strings.Contains(view, "project/ → test") {
+		t.Errorf("View() after r = %q, want the new mapping", view)
+	}
+	if !strings.Contains(view, "✓ Refreshed") {
+		t.Errorf("View() after r = %q, want a refresh notice", view)
+	}
+	if model.width != 100 || model.height != 60 {
+		t.Errorf("size after refresh = %dx%d, want it kept", model.width, model.height)
+	}
+}
+
+func TestStatusModel_Report(t *testing.T) {
+	model := &StatusModel{
+		currentDir: "/code/acme",
+		summary: identity.Summary{
+			Profile: &profile.Profile{Name: "work", Email: "work@example.com"},
+		},
+		mappings: []mapping.Mapping{
+			{Directory: "/code/", Profile: "work", Note: "acme contract", CreatedAt: time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)},
+			{RawCondition: "hasconfig:remote.*.url:https://github.com/acme/**", ConditionKind: mapping.ConditionHasConfig, Profile: "acme"},
+		},
+		cloudSynced:    map[string]bool{"/code/": true},
+		defaultProfile: "personal",
+		globalUser:     &mapping.GlobalIdentity{},
+		warnings:       []string{"something is off"},
+	}
+
+	r := model.Report()
+	if len(r.Mappings) != 2 || !r.Mappings[0].CloudSynced || r.Mappings[1].Directory != "" ||
+		r.Mappings[0].CreatedAt != "2024-05-01" || r.Mappings[0].Note != "acme contract" || r.Mappings[1].CreatedAt != "" {
+		t.Errorf("Report().Mappings = %+v", r.Mappings)
+	}
+	if r.DefaultIdentity != nil {
+		t.Errorf("Report().DefaultIdentity = %+v, want nil for an empty [user] section", r.DefaultIdentity)
+	}
+
+	out := RenderStatusPlain(r)
+	for _, want := range []string{
+		"Current directory: /code/acme",
+		"Active profile: work",
+		"/code/ → work [cloud-synced]\n    mapped 2024-05-01 · acme contract\n",
+		"hasconfig:remote.*.url:https://github.com/acme/** → acme",
+		"Default profile: personal",
+		"Default identity: none",
+		"⚠ something is off",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("RenderStatusPlain() missing %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "\x1b[") {
+		t.Errorf("RenderStatusPlain() should not contain escape codes:\n%q", out)
+	}
+}
+
+func TestStatusModel_ReportDuplicate(t *testing.T) {
+	model := &StatusModel{
+		currentDir: "/code/acme",
+		mappings: []mapping.Mapping{
+			{Directory: "/code/", Profile: "old", Duplicate: true},
+			{Directory: "/code/", Profile: "work"},
+		},
+		cloudSynced: map[string]bool{"/code/": true},
+	}
+
+	r := model.Report()
+	if len(r.Mappings) != 2 || !r.Mappings[0].Duplicate || r.Mappings[1].Duplicate {
+		t.Errorf("Report().Mappings = %+v", r.Mappings)
+	}
+	out := RenderStatusPlain(r)
+	for _, want := range []string{
+		"/code/ → old [duplicate, overridden] [cloud-synced]\n",
+		"/code/ → work [cloud-synced]\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("RenderStatusPlain() missing %q:\n%s", want, out)
+		}
+	}
+	if view := model.renderMapping(model.mappings[0]); !strings.Contains(view, "[duplicate, overridden]") {
+		t.Errorf("renderMapping() = %q, want the duplicate badge", view)
+	}
+}
+
+func TestStatusModel_BroadMapping(t *testing.T) {
+	model := &StatusModel{
+		mappings: []mapping.Mapping{
+			{Directory: "/", Profile: "personal"},
+			{Directory: "/code/", Profile: "work"},
+		},
+		broad: map[string]bool{"/": true},
+	}
+
+	r := model.Report()
+	if !r.Mappings[0].Broad || r.Mappings[1].Broad {
+		t.Errorf("Report().Mappings = %+v, want only / broad", r.Mappings)
+	}
+	out := RenderStatusPlain(r)
+	if !strings.Contains(out, "/ → personal [broad]\n") || strings.Contains(out, "work [broad]") {
+		t.Errorf("RenderStatusPlain() should badge only the broad mapping:\n%s", out)
+	}
+	if view := model.renderMapping(model.mappings[0]); !strings.Contains(view, "[broad]") {
+		t.Errorf("renderMapping() = %q, want the broad badge", view)
+	}
+}
@@ -0,0 +1,87 @@
+package ui
+
+import (
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
+)
+
+// Styles are the lipgloss styles of the interactive views.
+type Styles struct {
+	Title       lipgloss.Style
+	Header      lipgloss.Style
+	Row         lipgloss.Style
+	SelectedRow lipgloss.Style
+	Detail      lipgloss.Style
+	Help        lipgloss.Style
+
+	StatusTitle lipgloss.Style
+	Section     lipgloss.Style
+	Info        lipgloss.Style
+	Active      lipgloss.Style
+	Inactive    lipgloss.Style
+	Warning     lipgloss.Style
+	// KeyLoaded and KeyMissing color the SSH key line of the active profile.
+	KeyLoaded  lipgloss.Style
+	KeyMissing lipgloss.Style
+}
+
+// NewStyles returns the view styles. Without color they keep their padding
+// and borders, so both renderings have the same layout, but emit no colors or
+// bold text; the selected row is shown in reverse video instead.
+func NewStyles(color bool) Styles {
+	s := Styles{
+		Title:       lipgloss.NewStyle().Padding(1, 0),
+		Header:      lipgloss.NewStyle().BorderBottom(true).BorderStyle(lipgloss.NormalBorder()).Padding(0, 1),
+		Row:         lipgloss.NewStyle().Padding(0, 1),
+		SelectedRow: lipgloss.NewStyle().Padding(0, 1),
+		Detail:      lipgloss.NewStyle().BorderStyle(lipgloss.RoundedBorder()).Padding(0, 1),
+		Help:        lipgloss.NewStyle(),
+
+		StatusTitle: lipgloss.NewStyle().Padding(1, 0),
+		Section:     lipgloss.NewStyle().Padding(1, 0),
+		Info:        lipgloss.NewStyle().Padding(0, 2),
+		Active:      lipgloss.NewStyle(),
+		Inactive:    lipgloss.NewStyle(),
+		Warning:     lipgloss.NewStyle(),
+		KeyLoaded:   lipgloss.NewStyle().Padding(0, 2),
+		KeyMissing:  lipgloss.NewStyle().Padding(0, 2),
+	}
+	if !color {
+		s.SelectedRow = s.SelectedRow.Reverse(true)
+		return s
+	}
+
+	s.Title = s.Title.Bold(true).Foreground(lipgloss.Color("62"))
+	s.Header = s.Header.Bold(true).Foreground(lipgloss.Color("230"))
+	s.SelectedRow = s.SelectedRow.Bold(true).Foreground(lipgloss.Color("230")).Background(lipgloss.Color("62"))
+	s.Detail = s.Detail.BorderForeground(lipgloss.Color("62"))
+	s.Help = s.Help.Foreground(lipgloss.Color("241"))
+
+	s.StatusTitle = s.StatusTitle.Bold(true).Foreground(lipgloss.Color("62"))
+	s.Section = s.Section.Bold(true).Foreground(lipgloss.Color("230"))
+	s.Active = s.Active.Foreground(lipgloss.Color("42")).Bold(true)
+	s.Inactive = s.Inactive.Foreground(lipgloss.Color("240"))
+	s.Warning = s.Warning.Foreground(lipgloss.Color("214"))
+	s.KeyLoaded = s.KeyLoaded.Foreground(lipgloss.Color("42"))
+	s.KeyMissing = s.KeyMissing.Foreground(lipgloss.Color("214"))
+	return s
+}
+
+// defaultStyles are the styles of models that were not given their own.
+var defaultStyles = NewStyles(true)
+
+// SetColor switches every view, including prompts and forms, to colored or
+// plain rendering. Call it once at startup, before any view is created.
+func SetColor(enabled bool) {
+	defaultStyles = NewStyles(enabled)
+	if !enabled {
+		lipgloss.SetColorProfile(termenv.Ascii)
+	}
+}
+
+// ColorEnabled reports whether output should be colored: not when noColor is
+// set (--no-color) nor when the NO_COLOR environment variable is non-empty,
+// as https://no-color.org asks.
+func ColorEnabled(noColor bool, getenv func(string) string) bool {
+	return !noColor && getenv("NO_COLOR") == ""
+}
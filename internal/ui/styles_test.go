@@ -0,0 +1,128 @@
+package ui
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/thuanlegit/git-identitree/internal/identity"
+	"github.com/thuanlegit/git-identitree/internal/profile"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
+)
+
+// forceColorProfile makes lipgloss emit colors although tests do not run in a
+// terminal.
+func forceColorProfile(t *testing.T) {
+	t.Helper()
+	original := lipgloss.ColorProfile()
+	lipgloss.SetColorProfile(termenv.ANSI256)
+	t.Cleanup(func() { lipgloss.SetColorProfile(original) })
+}
+
+// hasColor reports whether s sets a foreground or background color.
+func hasColor(s string) bool {
+	return strings.Contains(s, "38;5;") || strings.Contains(s, "48;5;")
+}
+
+func TestStyles_ListModel(t *testing.T) {
+	forceColorProfile(t)
+	model := NewListModel([]profile.Profile{
+		{Name: "work", Email: "work@example.com"},
+		{Name: "personal", Email: "me@example.com"},
+	})
+	model.Update(tea.WindowSizeMsg{Width: 120, Height: 40})
+
+	colored := NewStyles(true)
+	model.styles = &colored
+	coloredView := model.View()
+	if !hasColor(coloredView) {
+		t.Errorf("colored view should contain color codes:\n%q", coloredView)
+	}
+
+	plain := NewStyles(false)
+	model.styles = &plain
+	plainView := model.View()
+	if hasColor(plainView) {
+		t.Errorf("plain view should not contain color codes:\n%q", plainView)
+	}
+	// The selected row stays visible without color
+	if !strings.Contains(plainView, "\x1b[7m") {
+		t.Errorf("plain view should show the selected row in reverse video:\n%q", plainView)
+	}
+	if lipgloss.Width(coloredView) != lipgloss.Width(plainView) || lipgloss.Height(coloredView) != lipgloss.Height(plainView) {
+		t.Error("colored and plain views should have the same layout")
+	}
+}
+
+func TestStyles_StatusModel(t *testing.T) {
+	forceColorProfile(t)
+	model := &StatusModel{
+		currentDir: "/code",
+		summary: identity.Summary{
+			Profile:  &profile.Profile{Name: "work", Email: "work@example.com", SSHKeyPath: "/keys/id_work"},
+			KeyState: identity.KeyNotLoaded,
+		},
+		warnings: []string{"something is off"},
+	}
+	model.Update(tea.WindowSizeMsg{Width: 100, Height: 40})
+
+	colored := NewStyles(true)
+	model.styles = &colored
+	if view := model.View(); !hasColor(view) {
+		t.Errorf("colored view should contain color codes:\n%q", view)
+	}
+
+	plain := NewStyles(false)
+	model.styles = &plain
+	if view := model.View(); strings.Contains(view, "\x1b[") {
+		t.Errorf("plain view should not contain escape codes:\n%q", view)
+	}
+}
+
+func TestSetColor(t *testing.T) {
+	forceColorProfile(t)
+	t.Cleanup(func() { defaultStyles = NewStyles(true) })
+
+	summary := identity.Summary{Profile: &profile.Profile{Name: "work", Email: "work@example.com"}}
+	if out := RenderSummary(summary); !hasColor(out) {
+		t.Errorf("RenderSummary() should be colored by default:\n%q", out)
+	}
+
+	SetColor(false)
+	if out := RenderSummary(summary); strings.Contains(out, "\x1b[") {
+		t.Errorf("RenderSummary() after SetColor(false) should be plain:\n%q", out)
+	}
+	// Prompts and forms render through lipgloss directly
+	if out := lipgloss.NewStyle().Foreground(lipgloss.Color("62")).Render("x"); out != "x" {
+		t.Errorf("lipgloss should drop colors after SetColor(false), got %q", out)
+	}
+}
+
+func TestColorEnabled(t *testing.T) {
+	tests := []struct {
+		name    string
+		noColor bool
+		env     string
+		want    bool
+	}{
+		{name: "default", want: true},
+		{name: "flag", noColor: true, want: false},
+		{name: "NO_COLOR", env: "1", want: false},
+		{name: "empty NO_COLOR", env: "", want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			getenv := func(key string) string {
+				if key == "NO_COLOR" {
+					return tt.env
+				}
+				return ""
+			}
+			if got := ColorEnabled(tt.noColor, getenv); got != tt.want {
+				t.Errorf("ColorEnabled() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,30 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseAge(t *testing.T) {
+	t.Parallel()
+	day := 24 * time.Hour
+	for input, want := range map[string]time.Duration{
+		"180d":  180 * day,
+		"0d":    0,
+		"4w":    28 * day,
+		"1y":    365 * day,
+		" 2d ":  2 * day,
+		"36h":   36 * time.Hour,
+		"1h30m": 90 * time.Minute,
+	} {
+		if got, err := ParseAge(input); err != nil || got != want {
+			t.Errorf("ParseAge(%q) = %v, %v; want %v", input, got, err, want)
+		}
+	}
+
+	for _, input := range []string{"", "d", "-3d", "1.5d", "soon", "-1h"} {
+		if got, err := ParseAge(input); err == nil {
+			t.Errorf("ParseAge(%q) = %v, want an error", input, got)
+		}
+	}
+}
@@ -0,0 +1,24 @@
+package utils
+
+import "fmt"
+
+// detailError is an error with its own message that still matches a sentinel.
+type detailError struct {
+	msg      string
+	sentinel error
+}
+
+func (e *detailError) Error() string {
+	return e.msg
+}
+
+func (e *detailError) Unwrap() error {
+	return e.sentinel
+}
+
+// WithDetail returns an error with the formatted message that matches sentinel
+// with errors.Is, so callers keep a specific message without repeating the
+// sentinel's text.
+func WithDetail(sentinel error, format string, args ...any) error {
+	return &detailError{msg: fmt.Sprintf(format, args...), sentinel: sentinel}
+}
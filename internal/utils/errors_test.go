@@ -0,0 +1,22 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestWithDetail(t *testing.T) {
+	sentinel := errors.New("profile not found")
+	err := WithDetail(sentinel, "profile '%s' not found", "work")
+
+	if err.Error() != "profile 'work' not found" {
+		t.Errorf("Error() = %q, want the detailed message", err.Error())
+	}
+	if !errors.Is(err, sentinel) {
+		t.Error("errors.Is() should match the sentinel")
+	}
+	if wrapped := fmt.Errorf("failed to map: %w", err); !errors.Is(wrapped, sentinel) {
+		t.Error("errors.Is() should match the sentinel through further wrapping")
+	}
+}
@@ -1,17 +1,41 @@
 package utils
 
 import (
+	"errors"
 	"os"
 	"path/filepath"
+	"regexp"
+	"runtime"
 	"strings"
 )
 
+// ErrEmptyPath is returned by NormalizePath for an empty path, which would
+// otherwise silently stand for the working directory.
+var ErrEmptyPath = errors.New("path must not be empty")
+
+// ErrUndefinedVariable is returned for a path that refers to an environment
+// variable that is not set; expanding it to nothing would point elsewhere.
+var ErrUndefinedVariable = errors.New("undefined environment variable")
+
+// windowsVar matches a %NAME% reference to an environment variable.
+var windowsVar = regexp.MustCompile(`%([A-Za-z_][A-Za-z0-9_]*)%`)
+
 // NormalizePath converts a path to an absolute, canonical path.
-// It resolves ~ to the user's home directory and ensures the path is absolute.
+// It expands environment variables as ExpandPath does, resolves ~ to the
+// user's home directory and ensures the path is absolute.
+// An empty path is an error rather than the working directory.
 func NormalizePath(path string) (string, error) {
+	if path == "" {
+		return "", ErrEmptyPath
+	}
+	path, err := expandEnv(path)
+	if err != nil {
+		return "", err
+	}
+
 	// Expand ~ to home directory
 	if strings.HasPrefix(path, "~") {
-		home, err := os.UserHomeDir()
+		home, err := Root()
 		if err != nil {
 			return "", err
 		}
@@ -35,6 +59,34 @@ func NormalizePath(path string) (string, error) {
 	return cleanPath, nil
 }
 
+// NormalizePlannedPath is NormalizePath for a path that may not exist yet.
+// Symlinks are resolved in its deepest existing ancestor and the missing
+// components are appended, so a planned directory under a symlinked parent
+// compares equal to the mappings of that parent.
+func NormalizePlannedPath(path string) (string, error) {
+	absPath, err := NormalizePath(path)
+	if err != nil {
+		return "", err
+	}
+	if _, err := os.Lstat(absPath); err == nil {
+		return absPath, nil
+	}
+
+	var missing []string
+	dir := absPath
+	for {
+		parent := filepath.Dir(dir)
+		missing = append([]string{filepath.Base(dir)}, missing...)
+		if parent == dir {
+			return absPath, nil
+		}
+		dir = parent
+		if resolved, err := filepath.EvalSymlinks(dir); err == nil {
+			return filepath.Join(append([]string{resolved}, missing...)...), nil
+		}
+	}
+}
+
 // EnsureTrailingSlash ensures a directory path ends with a trailing slash.
 func EnsureTrailingSlash(path string) string {
 	if path == "" {
@@ -47,21 +99,56 @@ func EnsureTrailingSlash(path string) string {
 	return path
 }
 
-// GetHomeDir returns the user's home directory.
-func GetHomeDir() (string, error) {
-	return os.UserHomeDir()
+// AbbreviateHome replaces the home directory prefix of path with ~, the
+// inverse of ExpandPath, for showing paths without the user name. Paths outside
+// the home directory, including /home/janet when home is /home/jane, are
+// returned unchanged.
+func AbbreviateHome(path string) string {
+	home, err := Root()
+	if err != nil || home == "" || path == "" {
+		return path
+	}
+	home = strings.TrimRight(filepath.ToSlash(home), "/")
+	slashed := filepath.ToSlash(path)
+	if home == "" || !strings.HasPrefix(slashed, home) {
+		return path
+	}
+	rest := path[len(home):]
+	if rest != "" && rest[0] != '/' && rest[0] != '\\' {
+		return path
+	}
+	return "~" + rest
 }
 
-// ExpandPath expands ~ in a path to the user's home directory.
+// ExpandPath expands environment variables and then ~ in a path, so
+// "$HOME/.ssh/id_work" and "~/.ssh/id_work" name the same file. $NAME and
+// ${NAME} are expanded everywhere and %NAME% on Windows; a variable that is
+// not set is an error, and $$ stands for a literal $.
 // Unlike NormalizePath, this does not resolve symlinks or make the path absolute.
 func ExpandPath(path string) (string, error) {
+	return expandPath(path, Root)
+}
+
+// ExpandPathIn is ExpandPath with home standing for ~, $HOME and
+// %USERPROFILE%, for code that is handed its home directory instead of
+// using Root.
+func ExpandPathIn(path, home string) (string, error) {
+	return expandPath(path, func() (string, error) { return home, nil })
+}
+
+// expandPath implements ExpandPath, with root returning the home directory.
+func expandPath(path string, root func() (string, error)) (string, error) {
 	if path == "" {
 		return path, nil
 	}
+	path, err := expandEnvFor(path, runtime.GOOS, pathEnv(root))
+	if err != nil {
+		return "", err
+	}
 
 	// Expand ~ to home directory
 	if strings.HasPrefix(path, "~") {
-		home, err := os.UserHomeDir()
+		home, err := root()
 		if err != nil {
 			return "", err
 		}
@@ -88,3 +175,53 @@ func ExpandPath(path string) (string, error) {
 	return path, nil
 }
 
+// expandEnv expands the environment variables in path for the current OS.
+func expandEnv(path string) (string, error) {
+	return expandEnvFor(path, runtime.GOOS, pathEnv(Root))
+}
+
+// pathEnv returns os.LookupEnv, except that $HOME and %USERPROFILE% stand for
+// the directory root returns, so they agree with ~ under --home and
+// GIDTREE_ROOT.
+func pathEnv(root func() (string, error)) func(string) (string, bool) {
+	return func(name string) (string, bool) {
+		if name == "HOME" || name == "USERPROFILE" {
+			if home, err := root(); err == nil {
+				return home, true
+			}
+		}
+		return os.LookupEnv(name)
+	}
+}
+
+// expandEnvFor expands the environment variables in path as on goos, looking
+// them up with lookup. The first variable that is not set is reported.
+func expandEnvFor(path, goos string, lookup func(string) (string, bool)) (string, error) {
+	var undefined string
+	resolve := func(name string) string {
+		value, ok := lookup(name)
+		if !ok && undefined == "" {
+			undefined = name
+		}
+		return value
+	}
+
+	if goos == "windows" {
+		path = windowsVar.ReplaceAllStringFunc(path, func(ref string) string {
+			return resolve(strings.Trim(ref, "%"))
+		})
+	}
+	if strings.Contains(path, "$") {
+		path = os.Expand(path, func(name string) string {
+			if name == "$" {
+				return "$"
+			}
+			return resolve(name)
+		})
+	}
+
+	if undefined != "" {
+		return "", WithDetail(ErrUndefinedVariable, "environment variable %s in path is not set", undefined)
+	}
+	return path, nil
+}
@@ -1,6 +1,7 @@
 package utils
 
 import (
+	"errors"
 	"os"
 	"path/filepath"
 	"strings"
@@ -106,14 +107,40 @@ func TestEnsureTrailingSlash(t *testing.T) {
 	}
 }
 
-func TestGetHomeDir(t *testing.T) {
-	home, err := GetHomeDir()
+func TestRoot(t *testing.T) {
+	home, err := Root()
 	if err != nil {
-		t.Fatalf("GetHomeDir() error = %v", err)
+		t.Fatalf("Root() error = %v", err)
 	}
 
 	if !filepath.IsAbs(home) {
-		t.Errorf("GetHomeDir() = %v, want absolute path", home)
+		t.Errorf("Root() = %v, want absolute path", home)
+	}
+}
+
+func TestAbbreviateHome(t *testing.T) {
+	home := filepath.Join(t.TempDir(), "jane")
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+
+	tests := []struct {
+		name string
+		path string
+		want string
+	}{
+		{"home itself", home, "~"},
+		{"under home", filepath.Join(home, ".ssh", "id_work"), filepath.Join("~", ".ssh", "id_work")},
+		{"trailing separator", home + string(filepath.Separator), "~" + string(filepath.Separator)},
+		{"shared prefix", home + "t" + string(filepath.Separator) + "code", home + "t" + string(filepath.Separator) + "code"},
+		{"outside home", filepath.Join(filepath.Dir(home), "srv"), filepath.Join(filepath.Dir(home), "srv")},
+		{"empty", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := AbbreviateHome(tt.path); got != tt.want {
+				t.Errorf("AbbreviateHome(%q) = %q, want %q", tt.path, got, tt.want)
+			}
+		})
 	}
 }
 
@@ -247,7 +274,7 @@ func TestEnsureTrailingSlash_AllSeparators(t *testing.T) {
 	}
 }
 
-func TestGetHomeDir_Error(t *testing.T) {
+func TestRoot_Error(t *testing.T) {
 	// Save original HOME
 	originalHome := os.Getenv("HOME")
 	defer func() {
@@ -258,12 +285,12 @@ func TestGetHomeDir_Error(t *testing.T) {
 
 	// On Unix systems, UserHomeDir() should still work even with empty HOME
 	// as it falls back to other methods
-	home, err := GetHomeDir()
+	home, err := Root()
 	if err != nil {
-		t.Logf("GetHomeDir() error (might be expected on some systems): %v", err)
+		t.Logf("Root() error (might be expected on some systems): %v", err)
 	} else {
 		if !filepath.IsAbs(home) {
-			t.Errorf("GetHomeDir() = %v, want absolute path", home)
+			t.Errorf("Root() = %v, want absolute path", home)
 		}
 	}
 }
@@ -391,3 +418,138 @@ func TestExpandPath_ErrorHandling(t *testing.T) {
 	}
 }
 
+
+func TestNormalizePlannedPath(t *testing.T) {
+	tmpDir := t.TempDir()
+	real := filepath.Join(tmpDir, "real")
+	if err := os.Mkdir(real, 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	link := filepath.Join(tmpDir, "link")
+	if err := os.Symlink(real, link); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+	resolvedReal, err := filepath.EvalSymlinks(real)
+	if err != nil {
+		t.Fatalf("EvalSymlinks() error = %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"existing path", link, resolvedReal},
+		{"missing under a symlink", filepath.Join(link, "new", "service"), filepath.Join(resolvedReal, "new", "service")},
+		{"missing with dots", filepath.Join(link, "new", "..", "other"), filepath.Join(resolvedReal, "other")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NormalizePlannedPath(tt.input)
+			if err != nil {
+				t.Fatalf("NormalizePlannedPath() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("NormalizePlannedPath() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizePath_Empty(t *testing.T) {
+	if _, err := NormalizePath(""); !errors.Is(err, ErrEmptyPath) {
+		t.Errorf("NormalizePath(\"\") error = %v, want ErrEmptyPath", err)
+	}
+	if _, err := NormalizePlannedPath(""); !errors.Is(err, ErrEmptyPath) {
+		t.Errorf("NormalizePlannedPath(\"\") error = %v, want ErrEmptyPath", err)
+	}
+}
+
+func TestExpandPath_EnvironmentVariables(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, "config"))
+	t.Setenv("KEY_DIR", ".ssh")
+	// Setenv restores the variable after the test; it is unset during it
+	t.Setenv("GIDTREE_TEST_UNSET", "")
+	_ = os.Unsetenv("GIDTREE_TEST_UNSET")
+
+	tests := []struct {
+		name    string
+		input   string
+		want    string
+		wantErr error
+	}{
+		{"HOME", "$HOME/.ssh/id_work", filepath.Join(home, ".ssh", "id_work"), nil},
+		{"braces", "${XDG_CONFIG_HOME}/gidtree", filepath.Join(home, "config") + "/gidtree", nil},
+		{"tilde and variable", "~/$KEY_DIR/id_work", filepath.Join(home, ".ssh", "id_work"), nil},
+		{"literal dollar", "/keys/$$work", "/keys/$work", nil},
+		{"undefined", "$GIDTREE_TEST_UNSET/id_work", "", ErrUndefinedVariable},
+		{"no variables", "/keys/id_work", "/keys/id_work", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ExpandPath(tt.input)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ExpandPath(%q) error = %v, want %v", tt.input, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ExpandPath(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+
+	if _, err := NormalizePath("$GIDTREE_TEST_UNSET/work"); !errors.Is(err, ErrUndefinedVariable) || !strings.Contains(err.Error(), "GIDTREE_TEST_UNSET") {
+		t.Errorf("NormalizePath() with an undefined variable error = %v, want one naming it", err)
+	}
+	if got, err := NormalizePath("$HOME/work"); err != nil || got != filepath.Join(home, "work") {
+		t.Errorf("NormalizePath($HOME/work) = %q, %v; want %q", got, err, filepath.Join(home, "work"))
+	}
+}
+
+func TestExpandPathIn(t *testing.T) {
+	t.Parallel()
+	home := filepath.Join(t.TempDir(), "someone")
+
+	for input, want := range map[string]string{
+		"~":                 home,
+		"~/.ssh/id_work":    filepath.Join(home, ".ssh", "id_work"),
+		"$HOME/.ssh/id_rsa": filepath.Join(home, ".ssh", "id_rsa"),
+		"/keys/id_work":     "/keys/id_work",
+	} {
+		if got, err := ExpandPathIn(input, home); err != nil || got != want {
+			t.Errorf("ExpandPathIn(%q) = %q, %v; want %q", input, got, err, want)
+		}
+	}
+}
+
+func TestExpandEnvFor_Windows(t *testing.T) {
+	env := map[string]string{"USERPROFILE": `C:\Users\jane`, "HOME": `C:\Users\jane`}
+	lookup := func(name string) (string, bool) {
+		v, ok := env[name]
+		return v, ok
+	}
+
+	tests := []struct {
+		goos    string
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{"windows", `%USERPROFILE%\.ssh\id_work`, `C:\Users\jane\.ssh\id_work`, false},
+		{"windows", `$HOME\.ssh\id_work`, `C:\Users\jane\.ssh\id_work`, false},
+		{"windows", `%MISSING%\id_work`, "", true},
+		{"windows", `C:\100% done`, `C:\100% done`, false},
+		{"linux", "/keys/%USERPROFILE%", "/keys/%USERPROFILE%", false},
+	}
+	for _, tt := range tests {
+		got, err := expandEnvFor(tt.input, tt.goos, lookup)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("expandEnvFor(%q, %s) error = %v, wantErr %v", tt.input, tt.goos, err, tt.wantErr)
+		}
+		if got != tt.want {
+			t.Errorf("expandEnvFor(%q, %s) = %q, want %q", tt.input, tt.goos, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,17 @@
+package utils
+
+import "os"
+
+// Files and directories gidtree creates hold email addresses, key paths and
+// audit records, so they are readable by their owner only.
+const (
+	PrivateFileMode os.FileMode = 0600
+	PrivateDirMode  os.FileMode = 0700
+	// PrivateExecMode is for scripts gidtree installs, such as guard hooks.
+	PrivateExecMode os.FileMode = 0700
+)
+
+// IsShared reports whether mode grants any access to group or others.
+func IsShared(mode os.FileMode) bool {
+	return mode.Perm()&0077 != 0
+}
@@ -0,0 +1,28 @@
+package utils
+
+import (
+	"os"
+	"testing"
+)
+
+func TestIsShared(t *testing.T) {
+	tests := []struct {
+		mode os.FileMode
+		want bool
+	}{
+		{PrivateFileMode, false},
+		{PrivateDirMode, false},
+		{0400, false},
+		{0644, true},
+		{0755, true},
+		{0604, true},
+		{0640, true},
+		{os.ModeDir | 0700, false},
+		{os.ModeDir | 0750, true},
+	}
+	for _, tt := range tests {
+		if got := IsShared(tt.mode); got != tt.want {
+			t.Errorf("IsShared(%v) = %v, want %v", tt.mode, got, tt.want)
+		}
+	}
+}
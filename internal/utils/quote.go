@@ -0,0 +1,9 @@
+package utils
+
+import "strings"
+
+// ShellQuote wraps s in single quotes for /bin/sh, the shell git runs hooks
+// and core.sshCommand with on every platform.
+func ShellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
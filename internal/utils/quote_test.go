@@ -0,0 +1,21 @@
+package utils
+
+import "testing"
+
+func TestShellQuote(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"", "''"},
+		{"/home/me/.ssh/id_work", "'/home/me/.ssh/id_work'"},
+		{"/Users/Jane Doe/.ssh/id work", "'/Users/Jane Doe/.ssh/id work'"},
+		{"it's", `'it'\''s'`},
+		{`C:\keys\$id`, `'C:\keys\$id'`},
+	}
+	for _, tt := range tests {
+		if got := ShellQuote(tt.in); got != tt.want {
+			t.Errorf("ShellQuote(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
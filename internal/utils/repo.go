@@ -0,0 +1,57 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// IsRepoRoot reports whether dir is the top of a git working tree: it holds a
+// .git directory, or a .git file as worktrees and submodules do.
+func IsRepoRoot(dir string) bool {
+	_, err := os.Stat(filepath.Join(dir, ".git"))
+	return err == nil
+}
+
+// FindRepoRoot returns the closest directory at or above dir that is a
+// repository root.
+func FindRepoRoot(dir string) (string, bool) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", false
+	}
+	for {
+		if IsRepoRoot(dir) {
+			return dir, true
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", false
+		}
+		dir = parent
+	}
+}
+
+// ContainsRepos reports whether dir is a repository root or has one among its
+// immediate subdirectories.
+func ContainsRepos(dir string) bool {
+	return len(FindRepos(dir)) > 0
+}
+
+// FindRepos returns dir when it is a repository root, or otherwise the
+// repository roots among its immediate subdirectories.
+func FindRepos(dir string) []string {
+	if IsRepoRoot(dir) {
+		return []string{dir}
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	var repos []string
+	for _, e := range entries {
+		if e.IsDir() && IsRepoRoot(filepath.Join(dir, e.Name())) {
+			repos = append(repos, filepath.Join(dir, e.Name()))
+		}
+	}
+	return repos
+}
@@ -0,0 +1,60 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRepoDetection(t *testing.T) {
+	root := t.TempDir()
+	repo := filepath.Join(root, "src", "repo")
+	worktree := filepath.Join(root, "worktree")
+	deep := filepath.Join(root, "deep", "a", "b")
+	for _, dir := range []string{filepath.Join(repo, ".git"), filepath.Join(repo, "pkg", "sub"), worktree, deep} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatalf("Failed to create %s: %v", dir, err)
+		}
+	}
+	// Worktrees and submodules have a .git file instead of a directory
+	if err := os.WriteFile(filepath.Join(worktree, ".git"), []byte("gitdir: /elsewhere\n"), 0644); err != nil {
+		t.Fatalf("Failed to write .git file: %v", err)
+	}
+
+	if !IsRepoRoot(repo) || !IsRepoRoot(worktree) {
+		t.Error("IsRepoRoot() = false for a .git directory or file")
+	}
+	if IsRepoRoot(filepath.Join(repo, "pkg")) {
+		t.Error("IsRepoRoot() = true inside a repository")
+	}
+
+	if got, ok := FindRepoRoot(filepath.Join(repo, "pkg", "sub")); !ok || got != repo {
+		t.Errorf("FindRepoRoot() = %q, %v; want %q", got, ok, repo)
+	}
+	if got, ok := FindRepoRoot(deep); ok {
+		t.Errorf("FindRepoRoot() outside a repository = %q", got)
+	}
+
+	tests := []struct {
+		dir  string
+		want bool
+	}{
+		{repo, true},
+		{filepath.Join(root, "src"), true},
+		{root, true}, // worktree
+		{filepath.Join(root, "deep"), false},
+		{filepath.Join(root, "missing"), false},
+	}
+	for _, tt := range tests {
+		if got := ContainsRepos(tt.dir); got != tt.want {
+			t.Errorf("ContainsRepos(%s) = %v, want %v", tt.dir, got, tt.want)
+		}
+	}
+
+	if got := FindRepos(filepath.Join(root, "src")); len(got) != 1 || got[0] != repo {
+		t.Errorf("FindRepos() = %v, want [%s]", got, repo)
+	}
+	if got := FindRepos(repo); len(got) != 1 || got[0] != repo {
+		t.Errorf("FindRepos() of a repository = %v, want itself", got)
+	}
+}
@@ -0,0 +1,67 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+)
+
+// RootEnv names the environment variable that points gidtree at another
+// directory in place of the home directory, such as a scratch home for trying
+// out dotfiles in a container: GIDTREE_ROOT=/tmp/fakehome gidtree ...
+const RootEnv = "GIDTREE_ROOT"
+
+// ErrHomeNotSet is returned by Root when neither --home, GIDTREE_ROOT nor the
+// environment tells where the home directory is, as in some containers and
+// systemd services.
+var ErrHomeNotSet = errors.New("home directory not set")
+
+// homeOverride replaces the home directory when set, see SetHomeDir.
+var homeOverride string
+
+// SetHomeDir makes Root, and so everything gidtree reads and writes under the
+// home directory, use dir instead (--home). An empty dir restores the default.
+func SetHomeDir(dir string) {
+	homeOverride = dir
+}
+
+// Root returns the directory gidtree treats as the home directory: where
+// ~/.gidtree, ~/.gitconfig and ~/.ssh are and what ~ expands to in paths the
+// user enters. It is the one set with SetHomeDir, else $GIDTREE_ROOT, else the
+// user's home directory.
+func Root() (string, error) {
+	if homeOverride != "" {
+		return homeOverride, nil
+	}
+	if root := os.Getenv(RootEnv); root != "" {
+		return filepath.Abs(root)
+	}
+	home, err := os.UserHomeDir()
+	if err != nil || home == "" {
+		return "", WithDetail(ErrHomeNotSet, "HOME is not set; set HOME or run with --home <dir>")
+	}
+	return home, nil
+}
+
+// Command is exec.Command for the git, ssh and gpg tools gidtree runs. When
+// Root is not the environment's home directory, the tool gets HOME set to
+// Root, so it reads ~/.gitconfig and ~/.ssh and expands ~ where gidtree does.
+func Command(name string, args ...string) *exec.Cmd {
+	return CommandContext(context.Background(), name, args...)
+}
+
+// CommandContext is Command with a context that kills the tool when done,
+// as exec.CommandContext does.
+func CommandContext(ctx context.Context, name string, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, name, args...)
+	root, err := Root()
+	if err != nil {
+		return cmd
+	}
+	if home, err := os.UserHomeDir(); err != nil || home != root {
+		cmd.Env = append(os.Environ(), "HOME="+root)
+	}
+	return cmd
+}
@@ -0,0 +1,73 @@
+package utils
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// unsetHome clears every variable the home directory is looked up from.
+func unsetHome(t *testing.T) {
+	t.Helper()
+	for _, name := range []string{"HOME", "USERPROFILE", "HOMEDRIVE", "HOMEPATH", "home", RootEnv} {
+		t.Setenv(name, "")
+	}
+}
+
+func TestRoot_Unset(t *testing.T) {
+	unsetHome(t)
+
+	_, err := Root()
+	if !errors.Is(err, ErrHomeNotSet) {
+		t.Fatalf("Root() error = %v, want ErrHomeNotSet", err)
+	}
+	if !strings.Contains(err.Error(), "--home") {
+		t.Errorf("Root() error = %q, should mention --home", err)
+	}
+}
+
+func TestRoot_Env(t *testing.T) {
+	unsetHome(t)
+	t.Setenv("HOME", t.TempDir())
+	root := t.TempDir()
+	t.Setenv(RootEnv, root)
+
+	if got, err := Root(); err != nil || got != root {
+		t.Errorf("Root() = %q, %v, want %q", got, err, root)
+	}
+	if got, err := ExpandPath("~/.ssh/id_work"); err != nil || got != filepath.Join(root, ".ssh", "id_work") {
+		t.Errorf("ExpandPath() under GIDTREE_ROOT = %q, %v", got, err)
+	}
+	if got := AbbreviateHome(filepath.Join(root, "src")); got != "~/src" {
+		t.Errorf("AbbreviateHome() under GIDTREE_ROOT = %q, want ~/src", got)
+	}
+}
+
+func TestRoot_Override(t *testing.T) {
+	unsetHome(t)
+	t.Setenv(RootEnv, t.TempDir())
+	home := t.TempDir()
+	SetHomeDir(home)
+	defer SetHomeDir("")
+
+	// --home wins over GIDTREE_ROOT
+	if got, err := Root(); err != nil || got != home {
+		t.Errorf("Root() with override = %q, %v, want %q", got, err, home)
+	}
+	if got, err := ExpandPath("~/src"); err != nil || got != filepath.Join(home, "src") {
+		t.Errorf("ExpandPath() with override = %q, %v", got, err)
+	}
+}
+
+func TestRoot_HomeVariable(t *testing.T) {
+	unsetHome(t)
+	t.Setenv("HOME", t.TempDir())
+	root := t.TempDir()
+	t.Setenv(RootEnv, root)
+
+	// $HOME in a path the user entered agrees with ~
+	if got, err := ExpandPath("$HOME/.ssh/id_work"); err != nil || got != filepath.Join(root, ".ssh", "id_work") {
+		t.Errorf("ExpandPath($HOME) under GIDTREE_ROOT = %q, %v", got, err)
+	}
+}
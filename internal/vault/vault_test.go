@@ -0,0 +1,89 @@
+package vault
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func init() {
+	iterations = 1000
+}
+
+func TestSealOpen(t *testing.T) {
+	plain := []byte("version: 2\nprofiles:\n  - name: work\n    email: jane@company.com\n")
+
+	sealed, err := Seal(plain, "correct horse")
+	if err != nil {
+		t.Fatalf("Seal() error = %v", err)
+	}
+	if !IsSealed(sealed) {
+		t.Fatalf("Seal() output lacks the header: %q", sealed)
+	}
+	if bytes.Contains(sealed, []byte("jane@company.com")) {
+		t.Error("Seal() output contains the plaintext")
+	}
+
+	opened, err := Open(sealed, "correct horse")
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	if !bytes.Equal(opened, plain) {
+		t.Errorf("Open() = %q, want %q", opened, plain)
+	}
+
+	// Fresh salt and nonce every time
+	again, _ := Seal(plain, "correct horse")
+	if bytes.Equal(sealed, again) {
+		t.Error("Seal() produced identical output twice")
+	}
+}
+
+func TestOpen_WrongPassphrase(t *testing.T) {
+	sealed, err := Seal([]byte("secret"), "right")
+	if err != nil {
+		t.Fatalf("Seal() error = %v", err)
+	}
+	if _, err := Open(sealed, "wrong"); !errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("Open() with the wrong passphrase error = %v, want ErrWrongPassphrase", err)
+	}
+
+	// Tampering with the ciphertext is reported the same way
+	payload, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(sealed[len(Header):])))
+	if err != nil {
+		t.Fatalf("DecodeString() error = %v", err)
+	}
+	payload[len(payload)-1] ^= 0xff
+	tampered := Header + base64.StdEncoding.EncodeToString(payload) + "\n"
+	if _, err := Open([]byte(tampered), "right"); !errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("Open() of tampered data error = %v, want ErrWrongPassphrase", err)
+	}
+}
+
+func TestOpen_Invalid(t *testing.T) {
+	tests := []struct {
+		name       string
+		data       string
+		passphrase string
+	}{
+		{name: "plaintext", data: "version: 2\n", passphrase: "x"},
+		{name: "empty passphrase", data: Header + "AAAA\n", passphrase: ""},
+		{name: "bad base64", data: Header + "!!!\n", passphrase: "x"},
+		{name: "truncated", data: Header + "AAAA\n", passphrase: "x"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Open([]byte(tt.data), tt.passphrase); err == nil {
+				t.Errorf("Open(%q) should fail", tt.data)
+			}
+		})
+	}
+}
+
+func TestSeal_EmptyPassphrase(t *testing.T) {
+	if _, err := Seal([]byte("x"), ""); !errors.Is(err, ErrEmptyPassphrase) {
+		t.Errorf("Seal() with an empty passphrase error = %v, want ErrEmptyPassphrase", err)
+	}
+}
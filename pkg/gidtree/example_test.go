@@ -0,0 +1,40 @@
+package gidtree_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/thuanlegit/git-identitree/pkg/gidtree"
+)
+
+func Example() {
+	// Work in a throwaway home directory so the example does not touch real config
+	home, _ := os.MkdirTemp("", "gidtree-example-*")
+	defer func() { _ = os.RemoveAll(home) }()
+	home, _ = filepath.EvalSymlinks(home)
+	oldRoot := os.Getenv("GIDTREE_ROOT")
+	_ = os.Setenv("GIDTREE_ROOT", home)
+	defer func() { _ = os.Setenv("GIDTREE_ROOT", oldRoot) }()
+	oldXDG := os.Getenv("XDG_CONFIG_HOME")
+	_ = os.Setenv("XDG_CONFIG_HOME", "")
+	defer func() { _ = os.Setenv("XDG_CONFIG_HOME", oldXDG) }()
+
+	client, err := gidtree.New()
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	_ = client.AddProfile(gidtree.Profile{Name: "work", Email: "me@work.example"})
+
+	dir := filepath.Join(home, "code", "work")
+	_ = os.MkdirAll(dir, 0755)
+	if err := client.Map("work", dir); err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	summary, _ := client.Resolve(dir)
+	fmt.Println(summary.Profile.Name, summary.Profile.Email)
+	// Output: work me@work.example
+}
@@ -0,0 +1,87 @@
+// Package gidtree is the public client for embedding Git Identitree in other
+// tools. It wraps the same profile and mapping code the CLI uses, so files
+// written through a Client are indistinguishable from those written by gidtree.
+//
+// A Client operates on the home directory of the current process, as reported
+// by os.UserHomeDir, or on $GIDTREE_ROOT when that is set.
+package gidtree
+
+import (
+	"fmt"
+
+	"github.com/thuanlegit/git-identitree/internal/identity"
+	"github.com/thuanlegit/git-identitree/internal/mapping"
+	"github.com/thuanlegit/git-identitree/internal/profile"
+)
+
+// Profile is a Git identity profile.
+type Profile = profile.Profile
+
+// Mapping is a directory-to-profile mapping read from ~/.gitconfig.
+type Mapping = mapping.Mapping
+
+// ProfileStore loads and saves profiles; see NewWithStore.
+type ProfileStore = profile.ProfileStore
+
+// Summary describes the identity that applies to a directory.
+type Summary = identity.Summary
+
+// Client manages profiles and directory mappings.
+type Client struct {
+	manager *profile.Manager
+}
+
+// New creates a client and loads the stored profiles.
+func New() (*Client, error) {
+	return NewWithStore(profile.FileStore{})
+}
+
+// NewWithStore creates a client that keeps profiles in store instead of
+// ~/.gidtree/profiles.yaml. Mappings still live in ~/.gitconfig.
+func NewWithStore(store ProfileStore) (*Client, error) {
+	manager, err := profile.NewManager(store)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize profile manager: %w", err)
+	}
+	return &Client{manager: manager}, nil
+}
+
+// Profiles returns all stored profiles.
+func (c *Client) Profiles() []Profile {
+	return c.manager.ListProfiles()
+}
+
+// Profile returns a copy of the profile with the given name; changing it
+// does not change the stored profile.
+func (c *Client) Profile(name string) (*Profile, error) {
+	return c.manager.GetProfile(name)
+}
+
+// AddProfile stores a new profile.
+func (c *Client) AddProfile(p Profile) error {
+	return c.manager.AddProfile(p)
+}
+
+// Map maps a profile to a directory.
+func (c *Client) Map(profileName, dir string) error {
+	prof, err := c.manager.GetProfile(profileName)
+	if err != nil {
+		return fmt.Errorf("profile not found: %w", err)
+	}
+	return mapping.MapProfileToDirectory(prof, dir)
+}
+
+// Unmap removes the mapping for a directory.
+func (c *Client) Unmap(dir string) error {
+	return mapping.UnmapDirectory(dir)
+}
+
+// Mappings returns all directory mappings.
+func (c *Client) Mappings() ([]Mapping, error) {
+	return mapping.ParseMappings()
+}
+
+// Resolve describes the identity that applies to dir.
+func (c *Client) Resolve(dir string) (Summary, error) {
+	return identity.Summarize(dir)
+}
@@ -0,0 +1,98 @@
+package gidtree
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/thuanlegit/git-identitree/internal/utils"
+)
+
+func setupClientTestEnv(t *testing.T) string {
+	t.Helper()
+
+	tmpDir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to resolve temp directory: %v", err)
+	}
+
+	// Override home directory for testing on all platforms
+	t.Setenv(utils.RootEnv, tmpDir)
+	t.Setenv("XDG_CONFIG_HOME", "")
+
+	return tmpDir
+}
+
+func TestClient(t *testing.T) {
+	tmpDir := setupClientTestEnv(t)
+
+	client, err := New()
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if err := client.AddProfile(Profile{Name: "work", Email: "work@example.com"}); err != nil {
+		t.Fatalf("AddProfile() error = %v", err)
+	}
+	if got := len(client.Profiles()); got != 1 {
+		t.Errorf("Profiles() returned %d profiles, want 1", got)
+	}
+
+	dir := filepath.Join(tmpDir, "work")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	if err := client.Map("work", dir); err != nil {
+		t.Fatalf("Map() error = %v", err)
+	}
+	if err := client.Map("missing", dir); err == nil {
+		t.Error("Map() should fail for an unknown profile")
+	}
+
+	summary, err := client.Resolve(dir)
+	if err != nil {
+		t.Fatalf("Resolve() error = %v", err)
+	}
+	if summary.Profile == nil || summary.Profile.Name != "work" {
+		t.Errorf("Resolve() profile = %v, want work", summary.Profile)
+	}
+
+	if err := client.Unmap(dir); err != nil {
+		t.Fatalf("Unmap() error = %v", err)
+	}
+	mappings, err := client.Mappings()
+	if err != nil {
+		t.Fatalf("Mappings() error = %v", err)
+	}
+	if len(mappings) != 0 {
+		t.Errorf("Mappings() = %v, want none after Unmap()", mappings)
+	}
+}
+
+// sliceStore is a ProfileStore backed by a slice.
+type sliceStore struct{ profiles []Profile }
+
+func (s *sliceStore) Load() ([]Profile, error) { return s.profiles, nil }
+
+func (s *sliceStore) Save(profiles []Profile) error {
+	s.profiles = profiles
+	return nil
+}
+
+func TestNewWithStore(t *testing.T) {
+	tmpDir := setupClientTestEnv(t)
+
+	store := &sliceStore{}
+	client, err := NewWithStore(store)
+	if err != nil {
+		t.Fatalf("NewWithStore() error = %v", err)
+	}
+	if err := client.AddProfile(Profile{Name: "work", Email: "work@example.com"}); err != nil {
+		t.Fatalf("AddProfile() error = %v", err)
+	}
+	if len(store.profiles) != 1 {
+		t.Errorf("store holds %v, want the added profile", store.profiles)
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, ".gidtree", "profiles.yaml")); !os.IsNotExist(err) {
+		t.Errorf("profiles.yaml was written with a custom store: %v", err)
+	}
+}
@@ -0,0 +1,37 @@
+package gidtreetest_test
+
+import (
+	"testing"
+
+	"github.com/thuanlegit/git-identitree/pkg/gidtree"
+	"github.com/thuanlegit/git-identitree/pkg/gidtree/gidtreetest"
+)
+
+func TestResolveWorkRepo(t *testing.T) {
+	env := gidtreetest.NewEnv(t).
+		WithProfile(gidtree.Profile{Name: "work", Email: "me@work.example"}).
+		WithGitRepo("code/work/api").
+		WithMapping("work", "code/work").
+		Build()
+
+	summary, err := env.Client().Resolve(env.Path("code/work/api"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Profile == nil || summary.Profile.Email != "me@work.example" {
+		t.Errorf("resolved %v, want the work profile", summary.Profile)
+	}
+}
+
+// A test that needs a mapped repository builds it in one expression and then
+// drives the client against it. TestResolveWorkRepo in this file runs it.
+func ExampleNewEnv() {
+	var t *testing.T // provided by the test function
+
+	env := gidtreetest.NewEnv(t).
+		WithProfile(gidtree.Profile{Name: "work", Email: "me@work.example"}).
+		WithMapping("work", "code/work").
+		Build()
+
+	_, _ = env.Client().Resolve(env.Path("code/work"))
+}
@@ -0,0 +1,185 @@
+// Package gidtreetest builds isolated Git Identitree environments for tests.
+//
+// The builder writes profiles.yaml, ~/.gitconfig includeIf blocks and the
+// per-profile fragments through the same code the CLI uses, so fixtures stay in
+// sync with the real file formats:
+//
+//	env := gidtreetest.NewEnv(t).
+//		WithProfile(gidtree.Profile{Name: "work", Email: "me@work.example"}).
+//		WithGitRepo("code/work/api").
+//		WithMapping("work", "code/work").
+//		Build()
+//	summary, _ := env.Client().Resolve(env.Path("code/work/api"))
+//
+// Build points GIDTREE_ROOT at a temporary directory with t.Setenv, so tests
+// using it cannot call t.Parallel. HOME is left alone.
+package gidtreetest
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/thuanlegit/git-identitree/internal/mapping"
+	"github.com/thuanlegit/git-identitree/internal/profile"
+	"github.com/thuanlegit/git-identitree/internal/utils"
+	"github.com/thuanlegit/git-identitree/pkg/gidtree"
+)
+
+// Env describes a test environment to build.
+type Env struct {
+	t        testing.TB
+	profiles []gidtree.Profile
+	repos    []string
+	mappings []pendingMapping
+}
+
+type pendingMapping struct {
+	profile string
+	dir     string
+}
+
+// Built is a constructed environment with its client and file locations.
+type Built struct {
+	t      testing.TB
+	home   string
+	client *gidtree.Client
+}
+
+// NewEnv starts building an empty environment.
+func NewEnv(t testing.TB) *Env {
+	return &Env{t: t}
+}
+
+// WithProfile adds a profile to profiles.yaml.
+func (e *Env) WithProfile(p gidtree.Profile) *Env {
+	e.profiles = append(e.profiles, p)
+	return e
+}
+
+// WithMapping maps a profile to dir. Relative directories are resolved against
+// the temporary home and created if missing.
+func (e *Env) WithMapping(profileName, dir string) *Env {
+	e.mappings = append(e.mappings, pendingMapping{profile: profileName, dir: dir})
+	return e
+}
+
+// WithGitRepo initializes a real git repository in dir. Relative directories are
+// resolved against the temporary home. The test is skipped if git is not installed.
+func (e *Env) WithGitRepo(dir string) *Env {
+	e.repos = append(e.repos, dir)
+	return e
+}
+
+// Build creates the environment and fails the test on any error.
+func (e *Env) Build() *Built {
+	e.t.Helper()
+
+	home, err := filepath.EvalSymlinks(e.t.TempDir())
+	if err != nil {
+		e.t.Fatalf("gidtreetest: failed to resolve temp directory: %v", err)
+	}
+
+	// Point gidtree at the temporary root and keep the host's git config out;
+	// git run through utils.Command gets the root as its HOME
+	e.t.Setenv(utils.RootEnv, home)
+	e.t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, ".config"))
+	e.t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+
+	b := &Built{t: e.t, home: home}
+
+	profilesDir, err := profile.GetProfilesDir()
+	if err != nil {
+		e.t.Fatalf("gidtreetest: failed to get profiles directory: %v", err)
+	}
+	if err := os.MkdirAll(profilesDir, utils.PrivateDirMode); err != nil {
+		e.t.Fatalf("gidtreetest: failed to create profiles directory: %v", err)
+	}
+
+	client, err := gidtree.New()
+	if err != nil {
+		e.t.Fatalf("gidtreetest: %v", err)
+	}
+	for _, p := range e.profiles {
+		if err := client.AddProfile(p); err != nil {
+			e.t.Fatalf("gidtreetest: failed to add profile '%s': %v", p.Name, err)
+		}
+	}
+
+	if len(e.repos) > 0 {
+		if _, err := exec.LookPath("git"); err != nil {
+			e.t.Skip("gidtreetest: git not available")
+		}
+	}
+	for _, repo := range e.repos {
+		dir := b.Path(repo)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			e.t.Fatalf("gidtreetest: failed to create repository directory: %v", err)
+		}
+		if out, err := utils.Command("git", "init", "-q", dir).CombinedOutput(); err != nil {
+			e.t.Fatalf("gidtreetest: git init %s failed: %v\n%s", dir, err, out)
+		}
+	}
+
+	for _, m := range e.mappings {
+		dir := b.Path(m.dir)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			e.t.Fatalf("gidtreetest: failed to create mapped directory: %v", err)
+		}
+		if err := client.Map(m.profile, dir); err != nil {
+			e.t.Fatalf("gidtreetest: failed to map '%s' to %s: %v", m.profile, dir, err)
+		}
+	}
+
+	b.client = client
+	return b
+}
+
+// Client returns a client for the built environment.
+func (b *Built) Client() *gidtree.Client {
+	return b.client
+}
+
+// Home returns the temporary home directory.
+func (b *Built) Home() string {
+	return b.home
+}
+
+// Path resolves rel against the temporary home. Absolute paths are returned unchanged.
+func (b *Built) Path(rel string) string {
+	if filepath.IsAbs(rel) {
+		return rel
+	}
+	return filepath.Join(b.home, filepath.FromSlash(rel))
+}
+
+// ProfilesPath returns the location of profiles.yaml.
+func (b *Built) ProfilesPath() string {
+	b.t.Helper()
+	path, err := profile.GetProfilesPath()
+	if err != nil {
+		b.t.Fatalf("gidtreetest: %v", err)
+	}
+	return path
+}
+
+// GitConfigPath returns the location of the global git config holding the includeIf blocks.
+func (b *Built) GitConfigPath() string {
+	b.t.Helper()
+	path, err := mapping.GetGitConfigPath()
+	if err != nil {
+		b.t.Fatalf("gidtreetest: %v", err)
+	}
+	return path
+}
+
+// FragmentPath returns the location of a profile's ~/.gitconfig-<name> fragment.
+func (b *Built) FragmentPath(profileName string) string {
+	b.t.Helper()
+	path, err := mapping.GetProfileConfigPath(profileName)
+	if err != nil {
+		b.t.Fatalf("gidtreetest: %v", err)
+	}
+	return path
+}
@@ -0,0 +1,78 @@
+package gidtreetest
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/thuanlegit/git-identitree/internal/utils"
+	"github.com/thuanlegit/git-identitree/pkg/gidtree"
+)
+
+func TestBuild(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+
+	env := NewEnv(t).
+		WithProfile(gidtree.Profile{Name: "work", Email: "work@example.com", AuthorName: "Jane Doe"}).
+		WithProfile(gidtree.Profile{Name: "personal", Email: "me@example.com"}).
+		WithGitRepo("code/work/api").
+		WithMapping("work", "code/work").
+		Build()
+
+	if root, _ := utils.Root(); root != env.Home() {
+		t.Errorf("Root() = %q, want %q", root, env.Home())
+	}
+	if home, _ := os.UserHomeDir(); home == env.Home() {
+		t.Errorf("HOME should be left alone, got %q", home)
+	}
+
+	profiles, err := os.ReadFile(env.ProfilesPath())
+	if err != nil {
+		t.Fatalf("profiles.yaml not written: %v", err)
+	}
+	if !strings.Contains(string(profiles), "personal") {
+		t.Errorf("profiles.yaml missing profile:\n%s", profiles)
+	}
+
+	gitConfig, err := os.ReadFile(env.GitConfigPath())
+	if err != nil {
+		t.Fatalf("gitconfig not written: %v", err)
+	}
+	if !strings.Contains(string(gitConfig), `includeIf "gitdir/i:`+env.Path("code/work")) {
+		t.Errorf("gitconfig missing includeIf block:\n%s", gitConfig)
+	}
+	if _, err := os.Stat(env.FragmentPath("work")); err != nil {
+		t.Errorf("fragment not written: %v", err)
+	}
+
+	// Real git sees the mapped identity inside the repository
+	out, err := utils.Command("git", "-C", env.Path("code/work/api"), "config", "user.email").Output()
+	if err != nil {
+		t.Fatalf("git config failed: %v", err)
+	}
+	if got := strings.TrimSpace(string(out)); got != "work@example.com" {
+		t.Errorf("git user.email = %q, want work@example.com", got)
+	}
+
+	summary, err := env.Client().Resolve(env.Path("code/work/api"))
+	if err != nil {
+		t.Fatalf("Resolve() error = %v", err)
+	}
+	if summary.Profile == nil || summary.Profile.Name != "work" {
+		t.Errorf("Resolve() profile = %v, want work", summary.Profile)
+	}
+}
+
+func TestBuild_Empty(t *testing.T) {
+	env := NewEnv(t).Build()
+
+	if got := env.Client().Profiles(); len(got) != 0 {
+		t.Errorf("Profiles() = %v, want none", got)
+	}
+	if got := env.Path("/abs/path"); got != "/abs/path" {
+		t.Errorf("Path() = %q, want absolute path unchanged", got)
+	}
+}